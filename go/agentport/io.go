@@ -66,6 +66,22 @@ func New(p js.Value) *AgentPort {
 	return ap
 }
 
+// Origin returns a description of the client connected to the port, taken
+// from the port's sender. The origin is preferred, falling back to the URL
+// or extension ID. Empty string is returned if none is available.
+func (ap *AgentPort) Origin() string {
+	sender := ap.p.Get("sender")
+	if sender.IsUndefined() || sender.IsNull() {
+		return ""
+	}
+	for _, field := range []string{"origin", "url", "id"} {
+		if v := sender.Get(field); v.Type() == js.TypeString && v.String() != "" {
+			return v.String()
+		}
+	}
+	return ""
+}
+
 func (ap *AgentPort) OnDisconnect() {
 	jsutil.LogDebug("AgentPort.OnDisconnect: closing input writer")
 	ap.inWriter.Close()
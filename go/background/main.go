@@ -18,10 +18,18 @@ package main
 
 import (
 	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"syscall/js"
+	"time"
 
 	"github.com/google/chrome-ssh-agent/go/agentport"
 	"github.com/google/chrome-ssh-agent/go/app"
+	"github.com/google/chrome-ssh-agent/go/chrome"
 	"github.com/google/chrome-ssh-agent/go/jsutil"
 	"github.com/google/chrome-ssh-agent/go/keys"
 	"github.com/google/chrome-ssh-agent/go/storage"
@@ -30,24 +38,71 @@ import (
 
 type background struct {
 	// agent is keyring with the loaded keys.
-	agent agent.Agent
+	agent *keys.ConfirmAgent
+	// idle wraps the agent served to clients, and unloads all keys when
+	// it has not been used for the timeout in the user's preferences. It
+	// also records each use of a configured key.
+	idle *keys.IdleAgent
 	// ports manages opened ports for communicating with the agent.
 	ports agentport.AgentPorts
 	// manager is a wrapper that can manage loaded keys.
 	manager *keys.DefaultManager
 	// server exposes an API for the manager.
 	server *keys.Server
+
+	// confirmMu guards fields below.
+	confirmMu sync.Mutex
+	// confirmations are the pending prompts for the user to confirm use
+	// of a key, indexed by notification ID.
+	confirmations map[string]chan bool
+	// nextConfirmation is used to generate unique notification IDs.
+	nextConfirmation int
+
+	// menuMu guards fields below.
+	menuMu sync.Mutex
+	// menuItems are the items currently displayed in the context menu.
+	menuItems []*chrome.ContextMenuItem
+	// menuSynced indicates that menuItems reflects the context menu.
+	// Items persist when the background worker is suspended, so they
+	// are unknown until first set.
+	menuSynced bool
+
+	// nativeMu guards fields below.
+	nativeMu sync.Mutex
+	// nativeEnabled indicates that the agent is served to the native
+	// messaging host, as selected in the user's preferences.
+	nativeEnabled bool
+	// nativePort is the connection to the native messaging host, or nil
+	// if not connected.
+	nativePort *chrome.NativePort
+	// nativeRetry is the delay before reconnecting to the native
+	// messaging host if it disconnects.
+	nativeRetry time.Duration
+	// nativeGen is incremented whenever the native messaging host is
+	// enabled or disabled, so that stale reconnection attempts are
+	// ignored.
+	nativeGen int
 }
 
 func newBackground() *background {
-	agt := agent.NewKeyring()
-	mgr := keys.NewManager(agt, storage.DefaultSync(), storage.DefaultSession())
-	return &background{
-		agent:   agt,
-		ports:   agentport.AgentPorts{},
-		manager: mgr,
-		server:  keys.NewServer(mgr),
+	agt := keys.NewConfirmAgent(keys.NewRSASignatureAgent(agent.NewKeyring().(agent.ExtendedAgent)))
+	a := &background{
+		agent:         agt,
+		ports:         agentport.AgentPorts{},
+		confirmations: map[string]chan bool{},
 	}
+	// The manager uses the same extensions and lock as clients of the
+	// agent, so that it reports the extensions that are actually
+	// supported, and whether clients may use keys.
+	lockable := keys.NewLockableAgent(keys.NewExtensionAgent(agt), a.onAgentLockChanged)
+	a.manager = keys.NewManager(lockable, storage.DefaultSync(), storage.DefaultLocal(), storage.DefaultSession())
+	a.server = keys.NewServer(a.manager)
+	a.idle = keys.NewIdleAgent(keys.NewPriorityAgent(keys.NewUsageAgent(lockable, a.onUsed), a.orderIdentities), a.scheduleIdleCheck, a.onActive, a.onIdle)
+	agt.SetConfirm(a.confirm)
+	a.server.SetNotify(chrome.Notify)
+	a.server.SetBadge(chrome.SetBadgeText)
+	a.server.SetOnLock(a.onLock)
+	return a
 }
 
 func (a *background) Name() string {
@@ -62,14 +117,375 @@ func (a *background) Init(ctx jsutil.AsyncContext, cleanup *jsutil.CleanupFuncs)
 	if err := a.manager.LoadFromSession(ctx); err != nil {
 		jsutil.LogError("failed to load keys into agent: %v", err)
 	}
+	// The idle timeout continues from the last use of the agent before
+	// the background worker was suspended.
+	if lastActive, err := a.manager.LastActive(ctx); err != nil {
+		jsutil.LogError("failed to read agent activity: %v", err)
+	} else {
+		a.idle.SetLastUsed(lastActive)
+	}
+	jsutil.Log("Loading keys marked for automatic loading")
+	if err := a.manager.LoadAutoLoad(ctx); err != nil {
+		jsutil.LogError("failed to automatically load keys: %v", err)
+	}
+	a.server.UpdateBadge(ctx)
+	a.applyPreferences(ctx)
+	a.updateContextMenu(ctx)
 
 	jsutil.LogDebug("Attaching event handlers")
+	cleanup.Add(storage.OnChanged(storage.DefaultOnChanged(), "sync", a.onStorageChanged))
+	// Keys may be stored on this device only.
+	cleanup.Add(storage.OnChanged(storage.DefaultOnChanged(), "local", a.onLocalStorageChanged))
 	cleanup.Add(jsutil.DefineAsyncFunc(js.Global(), "handleOnMessage", a.onMessage))
 	cleanup.Add(jsutil.DefineAsyncFunc(js.Global(), "handleConnectionMessage", a.onConnectionMessage))
 	cleanup.Add(jsutil.DefineAsyncFunc(js.Global(), "handleConnectionDisconnect", a.onConnectionDisconnect))
+	cleanup.Add(jsutil.DefineAsyncFunc(js.Global(), "handleNotificationButtonClicked", a.onNotificationButtonClicked))
+	cleanup.Add(jsutil.DefineAsyncFunc(js.Global(), "handleNotificationClosed", a.onNotificationClosed))
+	cleanup.Add(jsutil.DefineAsyncFunc(js.Global(), "handleContextMenuClicked", a.onContextMenuClicked))
+	cleanup.Add(jsutil.DefineAsyncFunc(js.Global(), "handleAlarm", a.onAlarm))
 	return nil
 }
 
+// applyPreferences applies the user's preferences that are enforced by the
+// background worker.
+func (a *background) applyPreferences(ctx jsutil.AsyncContext) {
+	prefs, err := a.manager.Preferences(ctx)
+	if err != nil {
+		jsutil.LogError("failed to read preferences: %v", err)
+		return
+	}
+	a.idle.SetIdleTimeout(prefs.IdleTimeout())
+	a.manager.SetPassphraseCacheTTL(prefs.PassphraseCacheTTL())
+	a.setNativeHostEnabled(ctx, prefs.NativeHost)
+	jsutil.SetLogLevel(prefs.LogLevel())
+}
+
+// onStorageChanged is invoked when synced storage changes, which may
+// include the user's preferences and configured keys.
+func (a *background) onStorageChanged(ctx jsutil.AsyncContext, _ map[string]js.Value) {
+	a.applyPreferences(ctx)
+	a.updateContextMenu(ctx)
+}
+
+// onLocalStorageChanged is invoked when local storage changes, which may
+// include configured keys.
+func (a *background) onLocalStorageChanged(ctx jsutil.AsyncContext, _ map[string]js.Value) {
+	a.updateContextMenu(ctx)
+}
+
+const (
+	// menuParentID is the ID of the context menu item under which keys
+	// are listed.
+	menuParentID = "load-key"
+	// menuKeyPrefix prefixes the key ID in the ID of the context menu
+	// item that loads the key.
+	menuKeyPrefix = "load-key:"
+	// menuMoreID is the ID of the context menu item that opens the
+	// options page when there are too many keys to list.
+	menuMoreID = "load-key-more"
+	// maxMenuKeys is the maximum number of keys listed in the context
+	// menu.
+	maxMenuKeys = 10
+)
+
+// contextMenuItems returns the context menu items offering to load the
+// configured keys. Keys are listed in the order in which they are displayed
+// in the options page; keys beyond maxMenuKeys are reachable via the options
+// page.
+func contextMenuItems(configured []*keys.ConfiguredKey) []*chrome.ContextMenuItem {
+	var loadable []*keys.ConfiguredKey
+	for _, k := range configured {
+		if k.Disabled || k.Unsupported != "" {
+			continue
+		}
+		loadable = append(loadable, k)
+	}
+	if len(loadable) == 0 {
+		return nil
+	}
+	sort.SliceStable(loadable, func(i, j int) bool {
+		a, b := loadable[i], loadable[j]
+		if a.Position != b.Position {
+			if a.Position == 0 || b.Position == 0 {
+				return b.Position == 0
+			}
+			return a.Position < b.Position
+		}
+		return a.Name < b.Name
+	})
+
+	items := []*chrome.ContextMenuItem{
+		{ID: menuParentID, Title: "Load SSH Key"},
+	}
+	for i, k := range loadable {
+		if i == maxMenuKeys {
+			items = append(items, &chrome.ContextMenuItem{
+				ID:       menuMoreID,
+				ParentID: menuParentID,
+				Title:    "More keys…",
+			})
+			break
+		}
+		items = append(items, &chrome.ContextMenuItem{
+			ID:       menuKeyPrefix + k.ID,
+			ParentID: menuParentID,
+			Title:    k.Name,
+		})
+	}
+	return items
+}
+
+// updateContextMenu refreshes the context menu to reflect the configured
+// keys. Keys are not listed while the agent is locked. The menu is left
+// untouched if the keys listed are unchanged.
+func (a *background) updateContextMenu(ctx jsutil.AsyncContext) {
+	var items []*chrome.ContextMenuItem
+	if !a.server.Locked(ctx) {
+		configured, err := a.manager.Configured(ctx)
+		if err != nil {
+			jsutil.LogError("failed to read configured keys for context menu: %v", err)
+			return
+		}
+		items = contextMenuItems(configured)
+	}
+
+	a.menuMu.Lock()
+	defer a.menuMu.Unlock()
+	if a.menuSynced && reflect.DeepEqual(items, a.menuItems) {
+		return
+	}
+	if err := chrome.SetContextMenu(ctx, items); err != nil {
+		jsutil.LogError("failed to update context menu: %v", err)
+		return
+	}
+	a.menuItems = items
+	a.menuSynced = true
+}
+
+// onLock is invoked when the agent is locked or unlocked.
+func (a *background) onLock() {
+	jsutil.Async(func(ctx jsutil.AsyncContext) (js.Value, error) {
+		a.updateContextMenu(ctx)
+		return js.Undefined(), nil
+	})
+}
+
+// onContextMenuClicked is invoked when the user selects an item in the
+// context menu. Keys that require a passphrase are loaded via a popup
+// prompting for it.
+func (a *background) onContextMenuClicked(ctx jsutil.AsyncContext, _ js.Value, args []js.Value) (js.Value, error) {
+	itemID := jsutil.SingleArg(args).String()
+	if itemID == menuMoreID {
+		chrome.OpenOptionsPage()
+		return js.Undefined(), nil
+	}
+	if !strings.HasPrefix(itemID, menuKeyPrefix) {
+		return js.Undefined(), nil
+	}
+	if a.server.Locked(ctx) {
+		// The menu may not yet reflect that the agent was locked.
+		chrome.OpenOptionsPage()
+		return js.Undefined(), nil
+	}
+
+	id := keys.ID(strings.TrimPrefix(itemID, menuKeyPrefix))
+	ok, err := a.server.LoadWithoutPrompt(ctx, id)
+	if err != nil {
+		jsutil.LogError("failed to load key ID %s from context menu: %v", id, err)
+		chrome.Notify("Failed to load SSH key", err.Error())
+		return js.Undefined(), nil
+	}
+	if !ok {
+		chrome.OpenPopup("html/options.html?" + url.Values{"load": {string(id)}}.Encode())
+	}
+	return js.Undefined(), nil
+}
+
+const (
+	// idleAlarm is the name of the alarm at which the agent is checked
+	// for idleness.
+	idleAlarm = "idle"
+)
+
+// scheduleIdleCheck is invoked to check whether the agent is idle at the
+// supplied time. An alarm is used so that the check occurs even if the
+// background worker is suspended in the meantime.
+func (a *background) scheduleIdleCheck(when time.Time) {
+	chrome.SetAlarm(idleAlarm, when)
+}
+
+// onAlarm is invoked when an alarm fires.
+func (a *background) onAlarm(ctx jsutil.AsyncContext, _ js.Value, args []js.Value) (js.Value, error) {
+	name := jsutil.SingleArg(args).String()
+	if name == idleAlarm {
+		a.idle.CheckIdle()
+	}
+	return js.Undefined(), nil
+}
+
+// onActive is invoked when the agent has been used.
+func (a *background) onActive(lastUsed time.Time) {
+	jsutil.Async(func(ctx jsutil.AsyncContext) (js.Value, error) {
+		if err := a.manager.MarkActive(ctx, lastUsed); err != nil {
+			jsutil.LogError("failed to record agent activity: %v", err)
+		}
+		return js.Undefined(), nil
+	})
+}
+
+// onIdle is invoked when the agent has not been used for the idle timeout.
+func (a *background) onIdle() {
+	jsutil.Async(func(ctx jsutil.AsyncContext) (js.Value, error) {
+		jsutil.Log("Unloading keys after agent was idle")
+		a.server.UnloadIdle(ctx)
+		return js.Undefined(), nil
+	})
+}
+
+// onAgentLockChanged is invoked when the agent is locked or unlocked (e.g.,
+// by 'ssh-add -x'), and records the lock so that it is restored if the
+// background worker restarts.
+func (a *background) onAgentLockChanged() {
+	jsutil.Async(func(ctx jsutil.AsyncContext) (js.Value, error) {
+		if err := a.manager.SaveAgentLock(ctx); err != nil {
+			jsutil.LogError("failed to record agent lock: %v", err)
+		}
+		return js.Undefined(), nil
+	})
+}
+
+// onUsed is invoked when a key has been used to sign data.
+func (a *background) onUsed(key *keys.LoadedKey) {
+	jsutil.Async(func(ctx jsutil.AsyncContext) (js.Value, error) {
+		a.server.KeyUsed(ctx, key)
+		return js.Undefined(), nil
+	})
+}
+
+// checkOrigin is invoked before signing data for a client, and returns an
+// error if the client may not use the key.
+func (a *background) checkOrigin(key *keys.LoadedKey, origin string) error {
+	ch := make(chan error, 1)
+	jsutil.Async(func(ctx jsutil.AsyncContext) (js.Value, error) {
+		ch <- a.server.CheckOrigin(ctx, key, origin)
+		return js.Undefined(), nil
+	})
+	return <-ch
+}
+
+// orderIdentities is invoked when a client lists the loaded keys, and returns
+// them in the order in which they are offered to servers.
+func (a *background) orderIdentities(loaded []*agent.Key) []*agent.Key {
+	ch := make(chan []*agent.Key, 1)
+	jsutil.Async(func(ctx jsutil.AsyncContext) (js.Value, error) {
+		ch <- a.server.OrderIdentities(ctx, loaded)
+		return js.Undefined(), nil
+	})
+	return <-ch
+}
+
+// onSignRequest is invoked when a client has requested a signature.
+func (a *background) onSignRequest(key *keys.LoadedKey, origin string, err error) {
+	jsutil.Async(func(ctx jsutil.AsyncContext) (js.Value, error) {
+		a.server.RecordSignRequest(ctx, key, origin, err)
+		return js.Undefined(), nil
+	})
+}
+
+const (
+	// confirmTimeout is the time after which an unanswered prompt to
+	// confirm use of a key is treated as denied.
+	confirmTimeout = time.Minute
+	// confirmAllowButton is the index of the button in the notification
+	// that allows use of the key.
+	confirmAllowButton = 0
+)
+
+// keyName returns a human-readable name for the key, suitable for display
+// to the user.
+func (a *background) keyName(key *keys.LoadedKey) string {
+	result := make(chan string, 1)
+	jsutil.Async(func(ctx jsutil.AsyncContext) (js.Value, error) {
+		name := key.Comment
+		if configured, err := a.manager.Configured(ctx); err == nil {
+			for _, k := range configured {
+				if keys.ID(k.ID) == key.ID() {
+					name = k.Name
+					break
+				}
+			}
+		}
+		result <- name
+		return js.Undefined(), nil
+	})
+	return <-result
+}
+
+// confirm displays a notification prompting the user to confirm use of the
+// key. It blocks until the user responds, or the prompt times out.
+func (a *background) confirm(key *keys.LoadedKey) bool {
+	ch := make(chan bool, 1)
+	a.confirmMu.Lock()
+	a.nextConfirmation++
+	id := fmt.Sprintf("confirm-%d", a.nextConfirmation)
+	a.confirmations[id] = ch
+	a.confirmMu.Unlock()
+
+	defer func() {
+		a.confirmMu.Lock()
+		delete(a.confirmations, id)
+		a.confirmMu.Unlock()
+	}()
+
+	notifications := js.Global().Get("chrome").Get("notifications")
+	notifications.Call("create", id, map[string]interface{}{
+		"type":    "basic",
+		"iconUrl": "/img/icon128.png",
+		"title":   "Confirm SSH key use",
+		"message": fmt.Sprintf("Allow the '%s' key to be used for signing?", a.keyName(key)),
+		"buttons": []interface{}{
+			map[string]interface{}{"title": "Allow"},
+			map[string]interface{}{"title": "Deny"},
+		},
+		"requireInteraction": true,
+	})
+	defer notifications.Call("clear", id)
+
+	select {
+	case allowed := <-ch:
+		jsutil.LogDebug("confirm: notification %s answered: allowed=%v", id, allowed)
+		return allowed
+	case <-time.After(confirmTimeout):
+		jsutil.LogDebug("confirm: notification %s timed out", id)
+		return false
+	}
+}
+
+// resolveConfirmation completes the pending prompt for the notification,
+// if any.
+func (a *background) resolveConfirmation(id string, allowed bool) {
+	a.confirmMu.Lock()
+	defer a.confirmMu.Unlock()
+	if ch, ok := a.confirmations[id]; ok {
+		delete(a.confirmations, id)
+		ch <- allowed
+	}
+}
+
+func (a *background) onNotificationButtonClicked(_ jsutil.AsyncContext, _ js.Value, args []js.Value) (js.Value, error) {
+	var id, buttonIndex js.Value
+	jsutil.ExpandArgs(args, &id, &buttonIndex)
+	a.resolveConfirmation(id.String(), buttonIndex.Int() == confirmAllowButton)
+	return js.Undefined(), nil
+}
+
+func (a *background) onNotificationClosed(_ jsutil.AsyncContext, _ js.Value, args []js.Value) (js.Value, error) {
+	var id js.Value
+	jsutil.ExpandArgs(args, &id)
+	a.resolveConfirmation(id.String(), false)
+	return js.Undefined(), nil
+}
+
 func (a *background) onMessage(ctx jsutil.AsyncContext, _ js.Value, args []js.Value) (js.Value, error) {
 	var message, sender, sendResponse js.Value
 	jsutil.ExpandArgs(args, &message, &sender, &sendResponse)
@@ -81,16 +497,21 @@ func (a *background) onMessage(ctx jsutil.AsyncContext, _ js.Value, args []js.Va
 func (a *background) addPort(port js.Value) *agentport.AgentPort {
 	ap := agentport.New(port)
 	a.ports.Add(port, ap)
+	a.serveAgent(ap, ap.Origin())
+	return ap
+}
 
+// serveAgent serves the agent to the client connected to ap until the
+// connection is closed. origin identifies the client in the audit log.
+func (a *background) serveAgent(ap *agentport.AgentPort, origin string) {
 	go func() {
 		jsutil.LogDebug("ServeAgent: starting for new port")
 		defer jsutil.LogDebug("ServeAgent: finished")
-		if err := agent.ServeAgent(a.agent, ap); err != nil {
+		agt := keys.NewAuditAgent(a.idle, origin, a.checkOrigin, a.onSignRequest)
+		if err := keys.ServeAgent(agt, ap); err != nil {
 			jsutil.LogDebug("ServeAgent: finished with error: %v", err)
 		}
 	}()
-
-	return ap
 }
 
 func (a *background) onConnectionMessage(_ jsutil.AsyncContext, _ js.Value, args []js.Value) (js.Value, error) {
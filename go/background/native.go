@@ -0,0 +1,146 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"syscall/js"
+	"time"
+
+	"github.com/google/chrome-ssh-agent/go/agentport"
+	"github.com/google/chrome-ssh-agent/go/chrome"
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	"github.com/google/chrome-ssh-agent/go/keys"
+)
+
+const (
+	// nativeMinRetry is the initial delay before reconnecting to the
+	// native messaging host after it disconnects.
+	nativeMinRetry = 5 * time.Second
+	// nativeMaxRetry is the maximum delay before reconnecting to the
+	// native messaging host. The delay doubles after each consecutive
+	// failure, up to this limit.
+	nativeMaxRetry = 5 * time.Minute
+	// nativeOrigin identifies the native messaging host in the audit log.
+	nativeOrigin = "native:" + keys.NativeHostName
+)
+
+// setNativeHostEnabled connects to or disconnects from the native messaging
+// host, as selected in the user's preferences.
+func (a *background) setNativeHostEnabled(ctx jsutil.AsyncContext, enabled bool) {
+	a.nativeMu.Lock()
+	if enabled == a.nativeEnabled {
+		a.nativeMu.Unlock()
+		return
+	}
+	a.nativeEnabled = enabled
+	// Invalidate any pending reconnection attempt.
+	a.nativeGen++
+	a.nativeRetry = nativeMinRetry
+	port := a.nativePort
+	a.nativePort = nil
+	a.nativeMu.Unlock()
+
+	if port != nil {
+		jsutil.Log("Disconnecting from native messaging host")
+		port.Disconnect()
+	}
+	if enabled {
+		a.connectNative()
+		return
+	}
+	a.setNativeHostStatus(ctx, &keys.NativeHostStatus{})
+}
+
+// connectNative connects to the native messaging host, and serves the agent
+// over the connection.
+func (a *background) connectNative() {
+	a.nativeMu.Lock()
+	defer a.nativeMu.Unlock()
+	if !a.nativeEnabled || a.nativePort != nil {
+		return
+	}
+
+	jsutil.Log("Connecting to native messaging host %s", keys.NativeHostName)
+	gen := a.nativeGen
+	var ap *agentport.AgentPort
+	port := chrome.ConnectNative(keys.NativeHostName,
+		func(msg js.Value) {
+			a.nativeMu.Lock()
+			// The host is responsive, so reconnect promptly if it
+			// later disconnects.
+			a.nativeRetry = nativeMinRetry
+			a.nativeMu.Unlock()
+			ap.OnMessage(msg)
+		},
+		func(err error) {
+			ap.OnDisconnect()
+			a.onNativeDisconnect(gen, err)
+		})
+	ap = agentport.New(port.Port())
+	a.nativePort = port
+	a.serveAgent(ap, nativeOrigin)
+
+	jsutil.Async(func(ctx jsutil.AsyncContext) (js.Value, error) {
+		a.setNativeHostStatus(ctx, &keys.NativeHostStatus{Connected: true})
+		return js.Undefined(), nil
+	})
+}
+
+// onNativeDisconnect is invoked when the native messaging host disconnects.
+// gen is the generation at which the connection was made; if the host has
+// since been disabled or reconnected, the disconnect is ignored. Otherwise,
+// a reconnection attempt is scheduled.
+func (a *background) onNativeDisconnect(gen int, err error) {
+	a.nativeMu.Lock()
+	if gen != a.nativeGen || !a.nativeEnabled {
+		a.nativeMu.Unlock()
+		return
+	}
+	a.nativePort = nil
+	delay := a.nativeRetry
+	a.nativeRetry *= 2
+	if a.nativeRetry > nativeMaxRetry {
+		a.nativeRetry = nativeMaxRetry
+	}
+	a.nativeMu.Unlock()
+
+	jsutil.LogWarn("Native messaging host disconnected: %v; reconnecting in %s", err, delay)
+	jsutil.SetTimeout(delay, func() {
+		a.nativeMu.Lock()
+		current := gen == a.nativeGen
+		a.nativeMu.Unlock()
+		if current {
+			a.connectNative()
+		}
+	})
+
+	jsutil.Async(func(ctx jsutil.AsyncContext) (js.Value, error) {
+		a.setNativeHostStatus(ctx, &keys.NativeHostStatus{
+			Err:     err.Error(),
+			RetryAt: time.Now().Add(delay).Unix(),
+		})
+		return js.Undefined(), nil
+	})
+}
+
+// setNativeHostStatus records the status of the connection to the native
+// messaging host, so that it can be displayed in the options page.
+func (a *background) setNativeHostStatus(ctx jsutil.AsyncContext, status *keys.NativeHostStatus) {
+	if err := a.manager.SetNativeHostStatus(ctx, status); err != nil {
+		jsutil.LogError("failed to record native host status: %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chrome
+
+import (
+	"syscall/js"
+	"time"
+)
+
+// SetAlarm schedules the alarm with the supplied name to fire at the
+// supplied time, replacing any existing alarm with the same name. Unlike
+// timers, alarms persist when the background worker is suspended. See:
+//
+//	https://developer.chrome.com/docs/extensions/reference/alarms/#method-create
+func SetAlarm(name string, when time.Time) {
+	alarms := js.Global().Get("chrome").Get("alarms")
+	alarms.Call("create", name, map[string]interface{}{
+		"when": float64(when.UnixMilli()),
+	})
+}
@@ -0,0 +1,32 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chrome
+
+import (
+	"syscall/js"
+)
+
+// SetBadgeText sets the text of the badge displayed on the extension's
+// toolbar icon. The empty string removes the badge. See:
+//
+//	https://developer.chrome.com/docs/extensions/reference/action/#method-setBadgeText
+func SetBadgeText(text string) {
+	action := js.Global().Get("chrome").Get("action")
+	action.Call("setBadgeText", map[string]interface{}{
+		"text": text,
+	})
+}
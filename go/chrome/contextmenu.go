@@ -0,0 +1,60 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chrome
+
+import (
+	"fmt"
+	"syscall/js"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+)
+
+// ContextMenuItem is an item displayed in the context menu.
+type ContextMenuItem struct {
+	// ID uniquely identifies the item. It is supplied to the callback
+	// registered via OnContextMenuClicked.
+	ID string
+	// ParentID is the ID of the item under which this item is displayed,
+	// or empty for a top-level item.
+	ParentID string
+	// Title is the text displayed for the item.
+	Title string
+}
+
+// SetContextMenu replaces all items in the context menu added by the
+// extension. Parents must precede their children. See:
+//
+//	https://developer.chrome.com/docs/extensions/reference/contextMenus/#method-create
+func SetContextMenu(ctx jsutil.AsyncContext, items []*ContextMenuItem) error {
+	menus := js.Global().Get("chrome").Get("contextMenus")
+	if _, err := jsutil.AsPromise(menus.Call("removeAll")).Await(ctx); err != nil {
+		return fmt.Errorf("failed to remove context menu items: %w", err)
+	}
+
+	for _, item := range items {
+		props := map[string]interface{}{
+			"id":       item.ID,
+			"title":    item.Title,
+			"contexts": []interface{}{"all"},
+		}
+		if item.ParentID != "" {
+			props["parentId"] = item.ParentID
+		}
+		menus.Call("create", props)
+	}
+	return nil
+}
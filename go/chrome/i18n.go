@@ -0,0 +1,40 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chrome
+
+import (
+	"syscall/js"
+)
+
+// GetMessage returns the localized string for the specified message in the
+// user's locale, as defined by the extension's message catalogs. ok is
+// false if no catalog defines the message (or the i18n API is unavailable),
+// in which case the caller is expected to fall back to a default. See:
+//
+//	https://developer.chrome.com/docs/extensions/reference/i18n/#method-getMessage
+func GetMessage(key string) (msg string, ok bool) {
+	chromeObj := js.Global().Get("chrome")
+	if chromeObj.IsUndefined() {
+		return "", false
+	}
+	i18n := chromeObj.Get("i18n")
+	if i18n.IsUndefined() {
+		return "", false
+	}
+	msg = i18n.Call("getMessage", key).String()
+	return msg, msg != ""
+}
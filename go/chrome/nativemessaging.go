@@ -0,0 +1,94 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chrome
+
+import (
+	"errors"
+	"sync"
+	"syscall/js"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+)
+
+var (
+	// errNativeHostDisconnected is reported when a native messaging host
+	// disconnects without Chrome reporting a reason.
+	errNativeHostDisconnected = errors.New("native messaging host disconnected")
+)
+
+// NativePort is a connection to a native messaging host. See:
+//
+//	https://developer.chrome.com/docs/extensions/develop/concepts/native-messaging
+type NativePort struct {
+	port    js.Value
+	cleanup jsutil.CleanupFuncs
+	release sync.Once
+}
+
+// ConnectNative connects to the native messaging host with the specified
+// name, which Chrome launches if required. onMessage is invoked for each
+// message received from the host. onDisconnect is invoked once the
+// connection is closed, either because the host exited or because it could
+// not be launched (e.g., if it is not installed), with the reason reported
+// by Chrome.
+//
+// Connecting requires the 'nativeMessaging' permission.
+func ConnectNative(host string, onMessage func(msg js.Value), onDisconnect func(err error)) *NativePort {
+	p := &NativePort{
+		port: js.Global().Get("chrome").Get("runtime").Call("connectNative", host),
+	}
+
+	msgFunc := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		onMessage(jsutil.SingleArg(args))
+		return nil
+	})
+	p.port.Get("onMessage").Call("addListener", msgFunc)
+	p.cleanup.Add(msgFunc.Release)
+
+	disconnectFunc := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		// The reason is only available from within the listener.
+		err := errNativeHostDisconnected
+		if lastErr := js.Global().Get("chrome").Get("runtime").Get("lastError"); lastErr.Truthy() {
+			err = errors.New(lastErr.Get("message").String())
+		}
+		p.releaseFuncs()
+		onDisconnect(err)
+		return nil
+	})
+	p.port.Get("onDisconnect").Call("addListener", disconnectFunc)
+	p.cleanup.Add(disconnectFunc.Release)
+
+	return p
+}
+
+// Port returns the underlying chrome.runtime.Port.
+func (p *NativePort) Port() js.Value {
+	return p.port
+}
+
+// Disconnect closes the connection to the native messaging host. The
+// onDisconnect callback supplied to ConnectNative is not invoked.
+func (p *NativePort) Disconnect() {
+	p.port.Call("disconnect")
+	p.releaseFuncs()
+}
+
+// releaseFuncs releases the listeners registered on the port. It is safe to
+// invoke multiple times.
+func (p *NativePort) releaseFuncs() {
+	p.release.Do(p.cleanup.Do)
+}
@@ -0,0 +1,40 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package chrome provides thin wrappers around Chrome extension APIs.
+package chrome
+
+import (
+	"syscall/js"
+)
+
+const (
+	// notificationIcon is the icon displayed in notifications.
+	notificationIcon = "/img/icon128.png"
+)
+
+// Notify displays a basic notification to the user. See:
+//
+//	https://developer.chrome.com/docs/extensions/reference/notifications/#method-create
+func Notify(title, message string) {
+	notifications := js.Global().Get("chrome").Get("notifications")
+	notifications.Call("create", map[string]interface{}{
+		"type":    "basic",
+		"iconUrl": notificationIcon,
+		"title":   title,
+		"message": message,
+	})
+}
@@ -0,0 +1,49 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chrome
+
+import (
+	"syscall/js"
+)
+
+const (
+	// popupWidth and popupHeight are the dimensions of popup windows, in
+	// pixels.
+	popupWidth  = 640
+	popupHeight = 480
+)
+
+// OpenPopup opens the extension page at the specified path (relative to the
+// extension's root) in a new popup window. See:
+//
+//	https://developer.chrome.com/docs/extensions/reference/windows/#method-create
+func OpenPopup(path string) {
+	windows := js.Global().Get("chrome").Get("windows")
+	windows.Call("create", map[string]interface{}{
+		"url":    path,
+		"type":   "popup",
+		"width":  popupWidth,
+		"height": popupHeight,
+	})
+}
+
+// OpenOptionsPage opens the extension's options page. See:
+//
+//	https://developer.chrome.com/docs/extensions/reference/runtime/#method-openOptionsPage
+func OpenOptionsPage() {
+	js.Global().Get("chrome").Get("runtime").Call("openOptionsPage")
+}
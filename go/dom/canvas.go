@@ -0,0 +1,48 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dom
+
+import (
+	"syscall/js"
+)
+
+// DrawGrid draws a square grid of cells onto a canvas element (e.g., the
+// modules of a QR code). The canvas is resized so that each cell is scale
+// pixels across, with a border margin cells wide. Cells for which dark
+// returns true are filled black, and the remainder of the canvas white.
+func DrawGrid(canvas js.Value, size, margin, scale int, dark func(x, y int) bool) {
+	px := (size + 2*margin) * scale
+	canvas.Set("width", px)
+	canvas.Set("height", px)
+
+	ctx := canvas.Call("getContext", "2d")
+	if ctx.IsNull() || ctx.IsUndefined() {
+		// Canvas rendering is unavailable (e.g., disabled by the
+		// browser).
+		return
+	}
+	ctx.Set("fillStyle", "#ffffff")
+	ctx.Call("fillRect", 0, 0, px, px)
+	ctx.Set("fillStyle", "#000000")
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if dark(x, y) {
+				ctx.Call("fillRect", (x+margin)*scale, (y+margin)*scale, scale, scale)
+			}
+		}
+	}
+}
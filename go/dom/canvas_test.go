@@ -0,0 +1,80 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dom
+
+import (
+	"syscall/js"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// newFakeCanvas returns an object standing in for a canvas element, whose
+// 2D context records the rectangles filled in each color.
+func newFakeCanvas() js.Value {
+	return js.Global().Call("eval", `(() => {
+		const ctx = {
+			fillStyle: '',
+			filled: [],
+			fillRect(x, y, w, h) {
+				this.filled.push([this.fillStyle, x, y, w, h].join(' '));
+			},
+		};
+		return {
+			getContext(type) { return type === '2d' ? ctx : null; },
+			ctx: ctx,
+		};
+	})()`)
+}
+
+func TestDrawGrid(t *testing.T) {
+	t.Parallel()
+
+	canvas := newFakeCanvas()
+	// A 2x2 grid with dark cells on the diagonal.
+	DrawGrid(canvas, 2, 1, 3, func(x, y int) bool { return x == y })
+
+	if diff := cmp.Diff(canvas.Get("width").Int(), 12); diff != "" {
+		t.Errorf("incorrect width; -got +want: %s", diff)
+	}
+	if diff := cmp.Diff(canvas.Get("height").Int(), 12); diff != "" {
+		t.Errorf("incorrect height; -got +want: %s", diff)
+	}
+
+	var filled []string
+	f := canvas.Get("ctx").Get("filled")
+	for i := 0; i < f.Length(); i++ {
+		filled = append(filled, f.Index(i).String())
+	}
+	want := []string{
+		"#ffffff 0 0 12 12",
+		"#000000 3 3 3 3",
+		"#000000 6 6 3 3",
+	}
+	if diff := cmp.Diff(filled, want); diff != "" {
+		t.Errorf("incorrect rectangles filled; -got +want: %s", diff)
+	}
+}
+
+func TestDrawGridWithoutContext(t *testing.T) {
+	t.Parallel()
+
+	// The canvas is still sized if it cannot be drawn on.
+	canvas := js.Global().Call("eval", `({getContext() { return null; }})`)
+	DrawGrid(canvas, 2, 1, 3, func(x, y int) bool { return true })
+	if diff := cmp.Diff(canvas.Get("width").Int(), 12); diff != "" {
+		t.Errorf("incorrect width; -got +want: %s", diff)
+	}
+}
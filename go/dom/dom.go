@@ -18,7 +18,11 @@
 package dom
 
 import (
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"syscall/js"
 
 	"github.com/google/chrome-ssh-agent/go/jsutil"
@@ -34,6 +38,38 @@ type Event struct {
 	js.Value
 }
 
+// Type returns the type of the event (e.g., 'click', 'submit').
+func (e Event) Type() string {
+	return e.Get("type").String()
+}
+
+// Target returns the object to which the event was dispatched.
+func (e Event) Target() js.Value {
+	return e.Get("target")
+}
+
+// Key returns the key pressed for a keyboard event (e.g., 'Enter', 'Escape').
+func (e Event) Key() string {
+	return e.Get("key").String()
+}
+
+// ShiftKey indicates if the Shift key was held for a keyboard event.
+func (e Event) ShiftKey() bool {
+	return e.Get("shiftKey").Truthy()
+}
+
+// PreventDefault prevents the browser's default handling of the event. It is
+// only effective if invoked before the event handler returns.
+func (e Event) PreventDefault() {
+	e.Call("preventDefault")
+}
+
+// StopPropagation prevents the event from propagating to other objects. It is
+// only effective if invoked before the event handler returns.
+func (e Event) StopPropagation() {
+	e.Call("stopPropagation")
+}
+
 // Doc provides an API for interacting with the DOM for a Document.
 type Doc struct {
 	doc js.Value
@@ -48,6 +84,22 @@ func New(doc js.Value) *Doc {
 	return &Doc{doc: doc}
 }
 
+// ActiveElement returns the element that currently has focus. It returns
+// null if no element has focus.
+func (d *Doc) ActiveElement() js.Value {
+	return d.doc.Get("activeElement")
+}
+
+// URLQuery returns the query string (including the leading '?') of the
+// document's URL. It returns the empty string if the URL has no query string.
+func (d *Doc) URLQuery() string {
+	location := d.doc.Get("location")
+	if location.IsUndefined() || location.IsNull() {
+		return ""
+	}
+	return location.Get("search").String()
+}
+
 // NewElement returns a new element with the specified tag (e.g., 'tr', 'td').
 func (d *Doc) NewElement(tag string) js.Value {
 	return d.doc.Call("createElement", tag)
@@ -58,6 +110,21 @@ func (d *Doc) NewText(text string) js.Value {
 	return d.doc.Call("createTextNode", text)
 }
 
+// ParseHTML returns a new, detached element parsed from the specified
+// markup, which must contain a single top-level element. The markup is parsed
+// within a template element, so elements that are only valid within a
+// particular parent (e.g., 'tr') may be parsed, and scripts are not executed.
+//
+// The markup must be a template supplied by the developer; it must never
+// contain user input, which is not escaped and could therefore inject
+// arbitrary elements. Values supplied by the user should instead be filled in
+// as text (e.g., using NewText) after the template is parsed.
+func (d *Doc) ParseHTML(markup string) js.Value {
+	tmpl := d.NewElement("template")
+	tmpl.Set("innerHTML", strings.TrimSpace(markup))
+	return tmpl.Get("content").Get("firstElementChild")
+}
+
 // OnDOMContentLoaded registers a callback to be invoked when the DOM has
 // finished loading.
 func (d *Doc) OnDOMContentLoaded(callback func(ctx jsutil.AsyncContext)) jsutil.CleanupFunc {
@@ -81,6 +148,53 @@ func (d *Doc) OnDOMContentLoaded(callback func(ctx jsutil.AsyncContext)) jsutil.
 		})
 }
 
+// OnPageHide registers a callback to be invoked when the page displaying the
+// document is hidden, such as when it is closed or the user navigates away.
+// The page may be discarded soon after; the callback should only start work
+// that does not rely on the page remaining open (e.g., sending a message).
+func (d *Doc) OnPageHide(callback func(ctx jsutil.AsyncContext)) jsutil.CleanupFunc {
+	view := d.doc.Get("defaultView")
+	if view.IsUndefined() || view.IsNull() {
+		// Document is not displayed in a window.
+		return func() {}
+	}
+
+	return On(view, "pagehide", func(ctx jsutil.AsyncContext, _ Event) {
+		callback(ctx)
+	})
+}
+
+// DoPageHide simulates hiding the page displaying the document.
+func (d *Doc) DoPageHide() {
+	view := d.doc.Get("defaultView")
+	view.Call("dispatchEvent", view.Get("Event").New("pagehide"))
+}
+
+// Hidden indicates whether the page displaying the document is hidden (e.g.,
+// it is in a background tab, or the window is minimized).
+func (d *Doc) Hidden() bool {
+	return d.doc.Get("hidden").Truthy()
+}
+
+// OnVisibilityChange registers a callback to be invoked when the page
+// displaying the document is hidden or becomes visible again. hidden
+// indicates whether the page is now hidden.
+func (d *Doc) OnVisibilityChange(callback func(ctx jsutil.AsyncContext, hidden bool)) jsutil.CleanupFunc {
+	return On(d.doc, "visibilitychange", func(ctx jsutil.AsyncContext, _ Event) {
+		callback(ctx, d.Hidden())
+	})
+}
+
+// DoVisibilityChange simulates the page displaying the document being hidden
+// or becoming visible again.
+func (d *Doc) DoVisibilityChange(hidden bool) {
+	js.Global().Get("Object").Call("defineProperty", d.doc, "hidden", map[string]any{
+		"value":        hidden,
+		"configurable": true,
+	})
+	d.doc.Call("dispatchEvent", d.doc.Get("defaultView").Get("Event").New("visibilitychange"))
+}
+
 // GetElement returns the element with the specified ID.
 func (d *Doc) GetElement(id string) js.Value {
 	return d.doc.Call("getElementById", id)
@@ -96,6 +210,98 @@ func (d *Doc) GetElementsByTag(tag string) []js.Value {
 	return result
 }
 
+// Reload reloads the page displaying the document.
+func (d *Doc) Reload() {
+	d.doc.Get("location").Call("reload")
+}
+
+// DownloadBlob prompts the browser to save the supplied data as a file with
+// the specified name and MIME type.
+func (d *Doc) DownloadBlob(filename, mimeType string, data []byte) {
+	link := d.NewElement("a")
+	link.Set("href", fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(data)))
+	link.Set("download", filename)
+	link.Get("style").Set("display", "none")
+
+	// The link must be part of the document for the click to trigger the
+	// download.
+	body := d.doc.Get("body")
+	body.Call("appendChild", link)
+	DoClick(link)
+	body.Call("removeChild", link)
+}
+
+var (
+	// ErrClipboardUnavailable indicates that the clipboard cannot be
+	// accessed (e.g., the page is not in a secure context).
+	ErrClipboardUnavailable = errors.New("clipboard unavailable")
+)
+
+// WriteClipboard writes the supplied text to the clipboard.
+func (d *Doc) WriteClipboard(ctx jsutil.AsyncContext, text string) error {
+	view := d.doc.Get("defaultView")
+	if view.IsUndefined() || view.IsNull() {
+		return ErrClipboardUnavailable
+	}
+	clipboard := view.Get("navigator").Get("clipboard")
+	if clipboard.IsUndefined() || clipboard.IsNull() {
+		return ErrClipboardUnavailable
+	}
+	if _, err := jsutil.AsPromise(clipboard.Call("writeText", text)).Await(ctx); err != nil {
+		return fmt.Errorf("failed to write clipboard: %w", err)
+	}
+	return nil
+}
+
+// ReadFile returns the contents of the supplied File (e.g., as selected by the
+// user via a file input).
+func ReadFile(ctx jsutil.AsyncContext, file js.Value) ([]byte, error) {
+	buf, err := jsutil.AsPromise(file.Call("arrayBuffer")).Await(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	arr := js.Global().Get("Uint8Array").New(buf)
+	data := make([]byte, arr.Length())
+	js.CopyBytesToGo(data, arr)
+	return data, nil
+}
+
+var (
+	// ErrNoDroppedFile indicates that no file was dropped (e.g., an
+	// element within the page was dropped instead).
+	ErrNoDroppedFile = errors.New("no file dropped")
+	// ErrFileTooLarge indicates that a file exceeds the maximum size
+	// permitted.
+	ErrFileTooLarge = errors.New("file too large")
+)
+
+// ReadFileFromDrop returns the name and contents of the file dropped by the
+// user, as conveyed by the DataTransfer of a drop event. If multiple files
+// were dropped, only the first is read. Files larger than maxBytes are not
+// read; ErrFileTooLarge is returned instead.
+func ReadFileFromDrop(ctx jsutil.AsyncContext, evt Event, maxBytes int) (name string, data []byte, err error) {
+	dt := evt.Get("dataTransfer")
+	if dt.IsUndefined() || dt.IsNull() {
+		return "", nil, ErrNoDroppedFile
+	}
+	files := dt.Get("files")
+	if files.IsUndefined() || files.IsNull() || files.Length() == 0 {
+		return "", nil, ErrNoDroppedFile
+	}
+
+	file := files.Index(0)
+	name = file.Get("name").String()
+	if size := file.Get("size").Int(); size > maxBytes {
+		return name, nil, fmt.Errorf("%w: %s is %d bytes; maximum is %d bytes", ErrFileTooLarge, name, size, maxBytes)
+	}
+	data, err = ReadFile(ctx, file)
+	if err != nil {
+		return name, nil, err
+	}
+	return name, data, nil
+}
+
 // RemoveChildren removes all children of the specified node.
 func RemoveChildren(p js.Value) {
 	for p.Call("hasChildNodes").Bool() {
@@ -109,6 +315,88 @@ func DoClick(o js.Value) {
 	o.Call("click")
 }
 
+// DoDragAndDrop simulates dragging the src object and dropping it on the dst
+// object. Any callbacks registered by OnDragStart(), OnDragOver() and OnDrop()
+// will be invoked.
+func DoDragAndDrop(src, dst js.Value) {
+	newEvent := func(o js.Value, event string) js.Value {
+		return o.Get("ownerDocument").Get("defaultView").Get("Event").New(event, map[string]interface{}{
+			"bubbles":    true,
+			"cancelable": true,
+		})
+	}
+	src.Call("dispatchEvent", newEvent(src, "dragstart"))
+	dst.Call("dispatchEvent", newEvent(dst, "dragover"))
+	dst.Call("dispatchEvent", newEvent(dst, "drop"))
+	src.Call("dispatchEvent", newEvent(src, "dragend"))
+}
+
+// DoInput simulates the user modifying the value of the object. Any callback
+// registered by OnInput() will be invoked.
+func DoInput(o js.Value) {
+	evt := o.Get("ownerDocument").Get("defaultView").Get("Event").New("input", map[string]interface{}{
+		"bubbles": true,
+	})
+	o.Call("dispatchEvent", evt)
+}
+
+// DoChange simulates the user committing a new value for the object (e.g.,
+// selecting an option). Any callback registered by OnChange() will be invoked.
+func DoChange(o js.Value) {
+	evt := o.Get("ownerDocument").Get("defaultView").Get("Event").New("change", map[string]interface{}{
+		"bubbles": true,
+	})
+	o.Call("dispatchEvent", evt)
+}
+
+// DoKeyDown simulates pressing the specified key (e.g., 'Enter') while the
+// object has focus. Any callback registered by OnKeyDown() will be invoked.
+func DoKeyDown(o js.Value, key string) {
+	doKeyDown(o, key, false)
+}
+
+// DoShiftKeyDown simulates pressing a key while the Shift key is held.
+func DoShiftKeyDown(o js.Value, key string) {
+	doKeyDown(o, key, true)
+}
+
+func doKeyDown(o js.Value, key string, shift bool) {
+	evt := o.Get("ownerDocument").Get("defaultView").Get("KeyboardEvent").New("keydown", map[string]interface{}{
+		"key":        key,
+		"shiftKey":   shift,
+		"bubbles":    true,
+		"cancelable": true,
+	})
+	o.Call("dispatchEvent", evt)
+}
+
+// DoDropFiles simulates dropping the specified files (e.g., File objects) on
+// the object. Any callbacks registered by OnDragOver() and OnDrop() will be
+// invoked.
+func DoDropFiles(o js.Value, files ...js.Value) {
+	window := o.Get("ownerDocument").Get("defaultView")
+	newEvent := func(event string) js.Value {
+		evt := window.Get("Event").New(event, map[string]interface{}{
+			"bubbles":    true,
+			"cancelable": true,
+		})
+		// jsdom (which is used in tests) does not support DataTransfer,
+		// so supply an object with the same shape.
+		fileList := make([]interface{}, len(files))
+		for i, f := range files {
+			fileList[i] = f
+		}
+		dt := jsutil.NewObject()
+		dt.Set("files", js.ValueOf(fileList))
+		js.Global().Get("Object").Call("defineProperty", evt, "dataTransfer", map[string]interface{}{
+			"value": dt,
+		})
+		return evt
+	}
+	o.Call("dispatchEvent", newEvent("dragover"))
+	o.Call("dispatchEvent", newEvent("drop"))
+}
+
 // addEventListener adds a function that will be invoked on the specified event
 // for an object.  The returned cleanup function must be invoked to cleanup the
 // function.
@@ -121,11 +409,13 @@ func addEventListener(o js.Value, event string, f func(this js.Value, args []js.
 	}
 }
 
-// OnClick registers a callback to be invoked when the specified object is
-// clicked.
-func OnClick(o js.Value, callback func(ctx jsutil.AsyncContext, evt Event)) jsutil.CleanupFunc {
+// On registers a callback to be invoked when the specified event (e.g.,
+// 'click', 'focus') is dispatched to the object. The callback is invoked
+// asynchronously; see OnKeyDown for an example of handling an event
+// synchronously.
+func On(o js.Value, eventType string, callback func(ctx jsutil.AsyncContext, evt Event)) jsutil.CleanupFunc {
 	return addEventListener(
-		o, "click",
+		o, eventType,
 		func(this js.Value, args []js.Value) interface{} {
 			jsutil.Async(func(ctx jsutil.AsyncContext) (js.Value, error) {
 				callback(ctx, Event{Value: jsutil.SingleArg(args)})
@@ -135,20 +425,127 @@ func OnClick(o js.Value, callback func(ctx jsutil.AsyncContext, evt Event)) jsut
 		})
 }
 
+// OnClick registers a callback to be invoked when the specified object is
+// clicked.
+func OnClick(o js.Value, callback func(ctx jsutil.AsyncContext, evt Event)) jsutil.CleanupFunc {
+	return On(o, "click", callback)
+}
+
 // OnSubmit registers a callback to be invoked when the specified form is
 // submitted.
 func OnSubmit(o js.Value, callback func(ctx jsutil.AsyncContext, evt Event)) jsutil.CleanupFunc {
+	return On(o, "submit", callback)
+}
+
+// OnChange registers a callback to be invoked when the value of the specified
+// object is changed by the user.
+func OnChange(o js.Value, callback func(ctx jsutil.AsyncContext, evt Event)) jsutil.CleanupFunc {
+	return On(o, "change", callback)
+}
+
+// OnInput registers a callback to be invoked whenever the value of the
+// specified object is modified by the user. Unlike OnChange, it is invoked
+// for each modification rather than once the user commits the value.
+func OnInput(o js.Value, callback func(ctx jsutil.AsyncContext, evt Event)) jsutil.CleanupFunc {
+	return On(o, "input", callback)
+}
+
+// OnDragStart registers a callback to be invoked when the user starts
+// dragging the specified object.
+func OnDragStart(o js.Value, callback func(ctx jsutil.AsyncContext, evt Event)) jsutil.CleanupFunc {
+	return On(o, "dragstart", callback)
+}
+
+// OnDragOver registers a callback to be invoked when an object is dragged
+// over the specified object. Registering the callback marks the object as a
+// valid drop target.
+func OnDragOver(o js.Value, callback func(ctx jsutil.AsyncContext, evt Event)) jsutil.CleanupFunc {
 	return addEventListener(
-		o, "submit",
+		o, "dragover",
 		func(this js.Value, args []js.Value) interface{} {
+			// The default action must be prevented synchronously for the
+			// browser to permit a drop.
+			evt := Event{Value: jsutil.SingleArg(args)}
+			evt.PreventDefault()
 			jsutil.Async(func(ctx jsutil.AsyncContext) (js.Value, error) {
-				callback(ctx, Event{Value: jsutil.SingleArg(args)})
+				callback(ctx, evt)
+				return js.Undefined(), nil
+			})
+			return nil
+		})
+}
+
+// OnDrop registers a callback to be invoked when an object is dropped on the
+// specified object.
+func OnDrop(o js.Value, callback func(ctx jsutil.AsyncContext, evt Event)) jsutil.CleanupFunc {
+	return addEventListener(
+		o, "drop",
+		func(this js.Value, args []js.Value) interface{} {
+			// Prevent the browser from handling the drop itself (e.g.,
+			// navigating to dropped content).
+			evt := Event{Value: jsutil.SingleArg(args)}
+			evt.PreventDefault()
+			jsutil.Async(func(ctx jsutil.AsyncContext) (js.Value, error) {
+				callback(ctx, evt)
 				return js.Undefined(), nil
 			})
 			return nil
 		})
 }
 
+// OnKeyDown registers a callback to be invoked when a key is pressed while the
+// specified object has focus. Unlike other callbacks, it is invoked
+// synchronously so that it may prevent the browser's default handling of the
+// key; it must not block.
+func OnKeyDown(o js.Value, callback func(evt Event)) jsutil.CleanupFunc {
+	return addEventListener(
+		o, "keydown",
+		func(this js.Value, args []js.Value) interface{} {
+			callback(Event{Value: jsutil.SingleArg(args)})
+			return nil
+		})
+}
+
+// Focus moves focus to the specified element. It is a no-op if the object
+// cannot be focused.
+func Focus(o js.Value) {
+	if o.IsUndefined() || o.IsNull() || o.Get("focus").Type() != js.TypeFunction {
+		return
+	}
+	o.Call("focus")
+}
+
+// focusableSelector matches the elements that may receive focus when the
+// user navigates with the Tab key.
+const focusableSelector = "input:not([disabled]), textarea:not([disabled]), select:not([disabled]), button:not([disabled]), a[href], [tabindex]:not([tabindex='-1'])"
+
+// TrapFocus confines navigation with the Tab key to the focusable elements
+// within the specified container (e.g., a dialog). Pressing Tab on the last
+// element moves focus to the first, and Shift+Tab on the first element moves
+// focus to the last.
+func TrapFocus(container js.Value) jsutil.CleanupFunc {
+	return OnKeyDown(container, func(evt Event) {
+		if evt.Key() != "Tab" {
+			return
+		}
+		elems := container.Call("querySelectorAll", focusableSelector)
+		n := elems.Length()
+		if n == 0 {
+			return
+		}
+		first, last := elems.Index(0), elems.Index(n-1)
+		active := container.Get("ownerDocument").Get("activeElement")
+		switch {
+		case evt.ShiftKey() && active.Equal(first):
+			evt.PreventDefault()
+			Focus(last)
+		case !evt.ShiftKey() && active.Equal(last):
+			evt.PreventDefault()
+			Focus(first)
+		}
+	})
+}
+
 // ID returns the element ID of an object as a string.
 func ID(o js.Value) string {
 	return o.Get("id").String()
@@ -164,12 +561,129 @@ func SetValue(o js.Value, value string) {
 	o.Set("value", value)
 }
 
+// Type returns the type of an input element.
+func Type(o js.Value) string {
+	return o.Get("type").String()
+}
+
+// SetType sets the type of an input element (e.g., to switch between
+// "password" and "text").
+func SetType(o js.Value, t string) {
+	o.Set("type", t)
+}
+
+// Checked returns the checked state of an object (e.g., a checkbox).
+func Checked(o js.Value) bool {
+	return o.Get("checked").Bool()
+}
+
+// SetChecked sets the checked state of an object (e.g., a checkbox).
+func SetChecked(o js.Value, checked bool) {
+	o.Set("checked", checked)
+}
+
+// SetAttribute sets the named attribute of an element.
+func SetAttribute(o js.Value, name, value string) {
+	o.Call("setAttribute", name, value)
+}
+
+// GetAttribute returns the named attribute of an element, or the empty string
+// if the attribute is not set.
+func GetAttribute(o js.Value, name string) string {
+	v := o.Call("getAttribute", name)
+	if v.IsNull() {
+		return ""
+	}
+	return v.String()
+}
+
+// classes returns the classes assigned to an element, as listed in its
+// className.
+func classes(o js.Value) []string {
+	if cn := o.Get("className"); cn.Type() == js.TypeString {
+		return strings.Fields(cn.String())
+	}
+	return nil
+}
+
+// AddClass adds a class to an element, if not already present. The element's
+// classList is used if available; otherwise its className is modified
+// directly.
+func AddClass(o js.Value, class string) {
+	if cl := o.Get("classList"); cl.Truthy() {
+		cl.Call("add", class)
+		return
+	}
+	cs := classes(o)
+	if !slices.Contains(cs, class) {
+		o.Set("className", strings.Join(append(cs, class), " "))
+	}
+}
+
+// RemoveClass removes a class from an element, if present. The element's
+// classList is used if available; otherwise its className is modified
+// directly.
+func RemoveClass(o js.Value, class string) {
+	if cl := o.Get("classList"); cl.Truthy() {
+		cl.Call("remove", class)
+		return
+	}
+	cs := slices.DeleteFunc(classes(o), func(c string) bool { return c == class })
+	o.Set("className", strings.Join(cs, " "))
+}
+
+// Show makes an element visible by clearing its hidden attribute.
+func Show(o js.Value) {
+	o.Set("hidden", false)
+}
+
+// Hide hides an element by setting its hidden attribute. Unlike an element
+// that is merely empty, a hidden element does not occupy any space in the
+// layout.
+func Hide(o js.Value) {
+	o.Set("hidden", true)
+}
+
+// SetVisible shows or hides an element.
+func SetVisible(o js.Value, visible bool) {
+	o.Set("hidden", !visible)
+}
+
+// IsVisible indicates if an element is visible; that is, it is not hidden.
+func IsVisible(o js.Value) bool {
+	return !o.Get("hidden").Truthy()
+}
+
 // TextContent returns the text content of the specified object (and its
 // children).
 func TextContent(o js.Value) string {
 	return o.Get("textContent").String()
 }
 
+// CloneNode returns a deep copy of the specified node, including its
+// descendants. Event listeners are not copied.
+func CloneNode(o js.Value) js.Value {
+	return o.Call("cloneNode", true)
+}
+
+// QuerySelector returns the first descendant of the specified element
+// matching a CSS selector (e.g., '#id', '.class'). It returns null if no
+// descendant matches.
+func QuerySelector(o js.Value, selector string) js.Value {
+	return o.Call("querySelector", selector)
+}
+
+// QuerySelectorAll returns the descendants of the specified element matching
+// a CSS selector, in document order.
+func QuerySelectorAll(o js.Value, selector string) []js.Value {
+	var result []js.Value
+	elts := o.Call("querySelectorAll", selector)
+	for i := 0; i < elts.Length(); i++ {
+		result = append(result, elts.Index(i))
+	}
+	return result
+}
+
 // AppendChild adds the child object.  If non-nil, the populate() function is
 // invoked on the child to initialize it.
 func AppendChild(parent, child js.Value, populate func(child js.Value)) {
@@ -179,6 +693,16 @@ func AppendChild(parent, child js.Value, populate func(child js.Value)) {
 	parent.Call("appendChild", child)
 }
 
+// InsertAfter inserts a new element immediately after an existing element.
+// If populate is non-nil, it is invoked to populate the new element before it
+// is inserted.
+func InsertAfter(existing, elem js.Value, populate func(elem js.Value)) {
+	if populate != nil {
+		populate(elem)
+	}
+	existing.Call("after", elem)
+}
+
 // Dialog represents an HTML dialog.
 type Dialog struct {
 	dialog js.Value
@@ -244,13 +768,5 @@ func (d *Dialog) OnClose(callback func(ctx jsutil.AsyncContext, evt Event)) jsut
 		return d.simOnClose.Release
 	}
 
-	return addEventListener(
-		d.dialog, "close",
-		func(this js.Value, args []js.Value) interface{} {
-			jsutil.Async(func(ctx jsutil.AsyncContext) (js.Value, error) {
-				callback(ctx, Event{Value: jsutil.SingleArg(args)})
-				return js.Undefined(), nil
-			})
-			return nil
-		})
+	return On(d.dialog, "close", callback)
 }
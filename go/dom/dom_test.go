@@ -15,13 +15,16 @@
 package dom
 
 import (
+	"errors"
 	"syscall/js"
 	"testing"
 	"time"
 
 	dt "github.com/google/chrome-ssh-agent/go/dom/testing"
 	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 )
 
 func TestTextContent(t *testing.T) {
@@ -72,6 +75,95 @@ func TestNewElement(t *testing.T) {
 	}
 }
 
+func TestInsertAfter(t *testing.T) {
+	t.Parallel()
+
+	d := New(dt.NewDocForTesting(`
+		<div id="list"><div id="first">first</div><div>third</div></div>
+	`))
+	InsertAfter(d.GetElement("first"), d.NewElement("div"), func(elem js.Value) {
+		AppendChild(elem, d.NewText("second"), nil)
+	})
+	if diff := cmp.Diff(TextContent(d.GetElement("list")), "firstsecondthird"); diff != "" {
+		t.Errorf("incorrect text content; -got +want: %s", diff)
+	}
+}
+
+func TestParseHTML(t *testing.T) {
+	t.Parallel()
+
+	d := New(dt.NewDocForTesting(`
+		<table><tbody id="rows"></tbody></table>
+	`))
+	row := d.ParseHTML(`
+		<tr class="row">
+			<td class="name"></td>
+			<td><button type="button" class="load">Load</button></td>
+		</tr>
+	`)
+	if diff := cmp.Diff(row.Get("tagName").String(), "TR"); diff != "" {
+		t.Errorf("incorrect tag; -got +want: %s", diff)
+	}
+	if !row.Get("parentNode").Truthy() || row.Get("isConnected").Bool() {
+		t.Errorf("parsed element attached to document")
+	}
+
+	// Fill in the template's slots, and add it to the document.
+	AppendChild(QuerySelector(row, ".name"), d.NewText("<b>key</b>"), nil)
+	AppendChild(d.GetElement("rows"), row, nil)
+	if diff := cmp.Diff(TextContent(d.GetElement("rows")), "<b>key</b>Load"); diff != "" {
+		t.Errorf("incorrect text content; -got +want: %s", diff)
+	}
+	if diff := cmp.Diff(len(d.GetElementsByTag("b")), 0); diff != "" {
+		t.Errorf("text filled in as markup; -got +want: %s", diff)
+	}
+	if diff := cmp.Diff(TextContent(QuerySelector(d.GetElement("rows"), "button.load")), "Load"); diff != "" {
+		t.Errorf("incorrect button text; -got +want: %s", diff)
+	}
+	if !QuerySelector(row, ".missing").IsNull() {
+		t.Errorf("missing element found")
+	}
+}
+
+func TestQuerySelectorAll(t *testing.T) {
+	t.Parallel()
+
+	d := New(dt.NewDocForTesting(`
+		<div id="list"><p class="item">first</p><p>other</p><p class="item">second</p></div>
+	`))
+	var got []string
+	for _, elem := range QuerySelectorAll(d.GetElement("list"), ".item") {
+		got = append(got, TextContent(elem))
+	}
+	if diff := cmp.Diff(got, []string{"first", "second"}); diff != "" {
+		t.Errorf("incorrect elements; -got +want: %s", diff)
+	}
+	if diff := cmp.Diff(len(QuerySelectorAll(d.GetElement("list"), ".missing")), 0); diff != "" {
+		t.Errorf("incorrect number of missing elements; -got +want: %s", diff)
+	}
+}
+
+func TestCloneNode(t *testing.T) {
+	t.Parallel()
+
+	d := New(dt.NewDocForTesting(`
+		<div id="list"></div>
+	`))
+	tmpl := d.ParseHTML(`<div><span class="slot"></span></div>`)
+	for _, text := range []string{"first", "second"} {
+		AppendChild(d.GetElement("list"), CloneNode(tmpl), func(elem js.Value) {
+			AppendChild(QuerySelector(elem, ".slot"), d.NewText(text), nil)
+		})
+	}
+	if diff := cmp.Diff(TextContent(d.GetElement("list")), "firstsecond"); diff != "" {
+		t.Errorf("incorrect text content; -got +want: %s", diff)
+	}
+	// The template itself is unchanged.
+	if diff := cmp.Diff(TextContent(tmpl), ""); diff != "" {
+		t.Errorf("template modified; -got +want: %s", diff)
+	}
+}
+
 func TestNewText(t *testing.T) {
 	t.Parallel()
 
@@ -104,6 +196,49 @@ func TestClick(t *testing.T) {
 	}
 }
 
+func TestOn(t *testing.T) {
+	t.Parallel()
+
+	d := New(dt.NewDocForTesting(`
+		<div id="parent">
+			<input id="child" type="text"/>
+		</div>
+	`))
+	parent := d.GetElement("parent")
+	child := d.GetElement("child")
+
+	type received struct {
+		Type   string
+		Target string
+	}
+	events := make(chan received, 10)
+	for _, eventType := range []string{"custom", "keydown"} {
+		cleanup := On(parent, eventType, func(ctx jsutil.AsyncContext, evt Event) {
+			events <- received{Type: evt.Type(), Target: ID(evt.Target())}
+		})
+		defer cleanup()
+	}
+
+	// Events stopped by the child never reach the parent.
+	cleanup := OnKeyDown(child, func(evt Event) { evt.StopPropagation() })
+	defer cleanup()
+	DoKeyDown(child, "Enter")
+
+	evt := child.Get("ownerDocument").Get("defaultView").Get("Event").New("custom", map[string]interface{}{
+		"bubbles": true,
+	})
+	child.Call("dispatchEvent", evt)
+
+	select {
+	case got := <-events:
+		if diff := cmp.Diff(got, received{Type: "custom", Target: "child"}); diff != "" {
+			t.Errorf("incorrect event; -got +want: %s", diff)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("callback not invoked")
+	}
+}
+
 func TestDOMContentLoaded(t *testing.T) {
 	t.Parallel()
 
@@ -123,6 +258,87 @@ func TestDOMContentLoaded(t *testing.T) {
 	}
 }
 
+func TestPageHide(t *testing.T) {
+	t.Parallel()
+
+	d := New(dt.NewDocForTesting(`
+		<p>Some Text</p>
+	`))
+
+	hidden := make(chan struct{}, 1)
+	cleanup := d.OnPageHide(func(ctx jsutil.AsyncContext) { hidden <- struct{}{} })
+	defer cleanup()
+
+	d.DoPageHide()
+	select {
+	case <-hidden:
+		return
+	case <-time.After(5 * time.Second):
+		t.Errorf("OnPageHide not invoked")
+	}
+}
+
+func TestVisibilityChange(t *testing.T) {
+	t.Parallel()
+
+	d := New(dt.NewDocForTesting(`
+		<p>Some Text</p>
+	`))
+
+	changes := make(chan bool, 1)
+	cleanup := d.OnVisibilityChange(func(ctx jsutil.AsyncContext, hidden bool) { changes <- hidden })
+	defer cleanup()
+
+	for _, hidden := range []bool{true, false} {
+		d.DoVisibilityChange(hidden)
+		select {
+		case got := <-changes:
+			if got != hidden {
+				t.Errorf("OnVisibilityChange reported hidden=%v; want %v", got, hidden)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("OnVisibilityChange not invoked")
+		}
+		if d.Hidden() != hidden {
+			t.Errorf("Hidden() returned %v; want %v", d.Hidden(), hidden)
+		}
+	}
+}
+
+func TestWriteClipboard(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		d := New(dt.NewDocForTesting(`
+			<p>Some Text</p>
+		`))
+
+		// No clipboard is available by default.
+		if err := d.WriteClipboard(ctx, "text"); !errors.Is(err, ErrClipboardUnavailable) {
+			t.Errorf("WriteClipboard() returned %v; want %v", err, ErrClipboardUnavailable)
+		}
+
+		var written string
+		writeText := js.FuncOf(func(this js.Value, args []js.Value) any {
+			written = args[0].String()
+			return js.Global().Get("Promise").Call("resolve")
+		})
+		defer writeText.Release()
+		clipboard := jsutil.NewObject()
+		clipboard.Set("writeText", writeText)
+		js.Global().Get("Object").Call("defineProperty", d.doc.Get("defaultView").Get("navigator"), "clipboard", map[string]any{
+			"value": clipboard,
+		})
+
+		if err := d.WriteClipboard(ctx, "SHA256:abc"); err != nil {
+			t.Errorf("WriteClipboard() failed: %v", err)
+		}
+		if diff := cmp.Diff(written, "SHA256:abc"); diff != "" {
+			t.Errorf("incorrect clipboard contents; -got +want: %s", diff)
+		}
+	})
+}
+
 func TestValue(t *testing.T) {
 	t.Parallel()
 
@@ -140,6 +356,427 @@ func TestValue(t *testing.T) {
 	}
 }
 
+func TestType(t *testing.T) {
+	t.Parallel()
+
+	d := New(dt.NewDocForTesting(`
+		<input id="ipt" type="password">
+	`))
+
+	if diff := cmp.Diff(Type(d.GetElement("ipt")), "password"); diff != "" {
+		t.Errorf("incorrect type; -got +want: %s", diff)
+	}
+
+	SetType(d.GetElement("ipt"), "text")
+	if diff := cmp.Diff(Type(d.GetElement("ipt")), "text"); diff != "" {
+		t.Errorf("incorrect type; -got +want: %s", diff)
+	}
+}
+
+func TestChecked(t *testing.T) {
+	t.Parallel()
+
+	d := New(dt.NewDocForTesting(`
+		<input id="chk" type="checkbox">
+	`))
+
+	if diff := cmp.Diff(Checked(d.GetElement("chk")), false); diff != "" {
+		t.Errorf("incorrect checked state; -got +want: %s", diff)
+	}
+
+	SetChecked(d.GetElement("chk"), true)
+	if diff := cmp.Diff(Checked(d.GetElement("chk")), true); diff != "" {
+		t.Errorf("incorrect checked state; -got +want: %s", diff)
+	}
+}
+
+func TestChange(t *testing.T) {
+	t.Parallel()
+
+	d := New(dt.NewDocForTesting(`
+		<input id="chk" type="checkbox">
+	`))
+
+	changed := make(chan struct{})
+	cleanup := OnChange(d.GetElement("chk"), func(ctx jsutil.AsyncContext, evt Event) { close(changed) })
+	defer cleanup()
+
+	DoClick(d.GetElement("chk"))
+	select {
+	case <-changed:
+		return
+	case <-time.After(5 * time.Second):
+		t.Errorf("changed callback not invoked")
+	}
+}
+
+func TestDragAndDrop(t *testing.T) {
+	t.Parallel()
+
+	d := New(dt.NewDocForTesting(`
+		<div id="src" draggable="true">Source</div>
+		<div id="dst">Destination</div>
+	`))
+
+	started := make(chan struct{})
+	over := make(chan struct{}, 1)
+	dropped := make(chan struct{})
+	cleanupStart := OnDragStart(d.GetElement("src"), func(ctx jsutil.AsyncContext, evt Event) { close(started) })
+	defer cleanupStart()
+	cleanupOver := OnDragOver(d.GetElement("dst"), func(ctx jsutil.AsyncContext, evt Event) {
+		select {
+		case over <- struct{}{}:
+		default:
+		}
+	})
+	defer cleanupOver()
+	cleanupDrop := OnDrop(d.GetElement("dst"), func(ctx jsutil.AsyncContext, evt Event) { close(dropped) })
+	defer cleanupDrop()
+
+	DoDragAndDrop(d.GetElement("src"), d.GetElement("dst"))
+	for _, c := range []struct {
+		name string
+		ch   <-chan struct{}
+	}{
+		{name: "drag start", ch: started},
+		{name: "drag over", ch: over},
+		{name: "drop", ch: dropped},
+	} {
+		select {
+		case <-c.ch:
+		case <-time.After(5 * time.Second):
+			t.Errorf("%s callback not invoked", c.name)
+		}
+	}
+}
+
+func TestInput(t *testing.T) {
+	t.Parallel()
+
+	d := New(dt.NewDocForTesting(`
+		<textarea id="input"></textarea>
+	`))
+	input := d.GetElement("input")
+
+	got := make(chan string, 1)
+	cleanup := OnInput(input, func(ctx jsutil.AsyncContext, evt Event) { got <- Value(input) })
+	defer cleanup()
+
+	SetValue(input, "new value")
+	DoInput(input)
+	select {
+	case v := <-got:
+		if diff := cmp.Diff(v, "new value"); diff != "" {
+			t.Errorf("incorrect value; -got +want: %s", diff)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("input callback not invoked")
+	}
+}
+
+func TestDoChange(t *testing.T) {
+	t.Parallel()
+
+	d := New(dt.NewDocForTesting(`
+		<select id="select">
+			<option value="first">First</option>
+			<option value="second">Second</option>
+		</select>
+	`))
+	sel := d.GetElement("select")
+
+	got := make(chan string, 1)
+	cleanup := OnChange(sel, func(ctx jsutil.AsyncContext, evt Event) { got <- Value(sel) })
+	defer cleanup()
+
+	SetValue(sel, "second")
+	DoChange(sel)
+	select {
+	case v := <-got:
+		if diff := cmp.Diff(v, "second"); diff != "" {
+			t.Errorf("incorrect value; -got +want: %s", diff)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("change callback not invoked")
+	}
+}
+
+func TestKeyDown(t *testing.T) {
+	t.Parallel()
+
+	d := New(dt.NewDocForTesting(`
+		<input id="input" type="text"/>
+	`))
+	input := d.GetElement("input")
+
+	var keys []string
+	var prevented []bool
+	cleanup := OnKeyDown(input, func(evt Event) {
+		keys = append(keys, evt.Key())
+		if evt.Key() == "Enter" {
+			evt.PreventDefault()
+		}
+		prevented = append(prevented, evt.Get("defaultPrevented").Bool())
+	})
+
+	// The callback is invoked synchronously.
+	DoKeyDown(input, "Enter")
+	DoKeyDown(input, "Escape")
+	if diff := cmp.Diff(keys, []string{"Enter", "Escape"}); diff != "" {
+		t.Errorf("incorrect keys; -got +want: %s", diff)
+	}
+	if diff := cmp.Diff(prevented, []bool{true, false}); diff != "" {
+		t.Errorf("incorrect default prevented; -got +want: %s", diff)
+	}
+
+	// The callback is no longer invoked once cleaned up.
+	cleanup()
+	DoKeyDown(input, "Enter")
+	if diff := cmp.Diff(keys, []string{"Enter", "Escape"}); diff != "" {
+		t.Errorf("incorrect keys after cleanup; -got +want: %s", diff)
+	}
+}
+
+func TestVisibility(t *testing.T) {
+	t.Parallel()
+
+	d := New(dt.NewDocForTesting(`
+		<div id="shown"></div>
+		<div id="hidden" hidden></div>
+	`))
+	shown := d.GetElement("shown")
+	hidden := d.GetElement("hidden")
+
+	if !IsVisible(shown) {
+		t.Errorf("element without hidden attribute not visible")
+	}
+	if IsVisible(hidden) {
+		t.Errorf("element with hidden attribute visible")
+	}
+
+	Hide(shown)
+	if IsVisible(shown) {
+		t.Errorf("element visible after Hide")
+	}
+	if !shown.Call("hasAttribute", "hidden").Bool() {
+		t.Errorf("hidden attribute not set after Hide")
+	}
+
+	Show(hidden)
+	if !IsVisible(hidden) {
+		t.Errorf("element not visible after Show")
+	}
+	if hidden.Call("hasAttribute", "hidden").Bool() {
+		t.Errorf("hidden attribute not cleared after Show")
+	}
+
+	SetVisible(shown, true)
+	SetVisible(hidden, false)
+	if !IsVisible(shown) || IsVisible(hidden) {
+		t.Errorf("incorrect visibility after SetVisible; got shown=%t hidden=%t, want shown=true hidden=false", IsVisible(shown), IsVisible(hidden))
+	}
+}
+
+func TestFocus(t *testing.T) {
+	t.Parallel()
+
+	d := New(dt.NewDocForTesting(`
+		<input id="first" type="text"/>
+		<input id="second" type="text"/>
+	`))
+	first := d.GetElement("first")
+	second := d.GetElement("second")
+
+	Focus(first)
+	if got := d.ActiveElement(); !got.Equal(first) {
+		t.Errorf("incorrect active element after focusing first; got %s, want first", ID(got))
+	}
+	Focus(second)
+	if got := d.ActiveElement(); !got.Equal(second) {
+		t.Errorf("incorrect active element after focusing second; got %s, want second", ID(got))
+	}
+
+	// Objects that cannot be focused are ignored.
+	Focus(js.Null())
+	Focus(js.Undefined())
+	if got := d.ActiveElement(); !got.Equal(second) {
+		t.Errorf("incorrect active element after focusing null; got %s, want second", ID(got))
+	}
+}
+
+func TestTrapFocus(t *testing.T) {
+	t.Parallel()
+
+	d := New(dt.NewDocForTesting(`
+		<div id="container">
+			<input id="first" type="text"/>
+			<button id="disabled" disabled>Disabled</button>
+			<button id="last">Last</button>
+		</div>
+	`))
+	container := d.GetElement("container")
+	first := d.GetElement("first")
+	last := d.GetElement("last")
+
+	cleanup := TrapFocus(container)
+
+	// Tab on the last element wraps to the first.
+	Focus(last)
+	DoKeyDown(last, "Tab")
+	if got := d.ActiveElement(); !got.Equal(first) {
+		t.Errorf("incorrect active element after Tab; got %s, want first", ID(got))
+	}
+
+	// Shift+Tab on the first element wraps to the last.
+	DoShiftKeyDown(first, "Tab")
+	if got := d.ActiveElement(); !got.Equal(last) {
+		t.Errorf("incorrect active element after Shift+Tab; got %s, want last", ID(got))
+	}
+
+	// Other keys are ignored.
+	DoKeyDown(last, "Enter")
+	if got := d.ActiveElement(); !got.Equal(last) {
+		t.Errorf("incorrect active element after Enter; got %s, want last", ID(got))
+	}
+
+	// Focus is no longer trapped once cleaned up.
+	cleanup()
+	DoKeyDown(last, "Tab")
+	if got := d.ActiveElement(); !got.Equal(last) {
+		t.Errorf("incorrect active element after cleanup; got %s, want last", ID(got))
+	}
+}
+
+func TestDownloadBlob(t *testing.T) {
+	t.Parallel()
+
+	doc := dt.NewDocForTesting(`<div></div>`)
+	d := New(doc)
+
+	// Capture the link that is clicked, and prevent navigation.
+	var href, download string
+	onClick := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		evt := jsutil.SingleArg(args)
+		href = evt.Get("target").Get("href").String()
+		download = evt.Get("target").Get("download").String()
+		evt.Call("preventDefault")
+		return nil
+	})
+	defer onClick.Release()
+	doc.Call("addEventListener", "click", onClick)
+	defer doc.Call("removeEventListener", "click", onClick)
+
+	d.DownloadBlob("data.json", "application/json", []byte(`{"foo":"bar"}`))
+	if diff := cmp.Diff(href, "data:application/json;base64,eyJmb28iOiJiYXIifQ=="); diff != "" {
+		t.Errorf("incorrect href; -got +want: %s", diff)
+	}
+	if diff := cmp.Diff(download, "data.json"); diff != "" {
+		t.Errorf("incorrect download filename; -got +want: %s", diff)
+	}
+	if diff := cmp.Diff(len(d.GetElementsByTag("a")), 0); diff != "" {
+		t.Errorf("incorrect number of links remaining; -got +want: %s", diff)
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	t.Parallel()
+
+	file := js.Global().Get("Blob").New([]interface{}{`{"foo":"bar"}`})
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		data, err := ReadFile(ctx, file)
+		if err != nil {
+			t.Errorf("failed to read file: %v", err)
+		}
+		if diff := cmp.Diff(string(data), `{"foo":"bar"}`); diff != "" {
+			t.Errorf("incorrect data; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestReadFileFromDrop(t *testing.T) {
+	t.Parallel()
+
+	newFile := func(contents, name string) js.Value {
+		return js.Global().Get("File").New([]interface{}{contents}, name)
+	}
+
+	testcases := []struct {
+		description string
+		files       []js.Value
+		maxBytes    int
+		wantName    string
+		wantData    string
+		wantErr     error
+	}{
+		{
+			description: "single file",
+			files:       []js.Value{newFile("some-data", "some.pem")},
+			maxBytes:    100,
+			wantName:    "some.pem",
+			wantData:    "some-data",
+		},
+		{
+			description: "multiple files",
+			files:       []js.Value{newFile("first", "first.pem"), newFile("second", "second.pem")},
+			maxBytes:    100,
+			wantName:    "first.pem",
+			wantData:    "first",
+		},
+		{
+			description: "file too large",
+			files:       []js.Value{newFile("some-data", "some.pem")},
+			maxBytes:    4,
+			wantName:    "some.pem",
+			wantErr:     ErrFileTooLarge,
+		},
+		{
+			description: "no files",
+			maxBytes:    100,
+			wantErr:     ErrNoDroppedFile,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			d := New(dt.NewDocForTesting(`
+				<div id="target"></div>
+			`))
+			target := d.GetElement("target")
+
+			type result struct {
+				name string
+				data string
+				err  error
+			}
+			results := make(chan result, 1)
+			cleanup := OnDrop(target, func(ctx jsutil.AsyncContext, evt Event) {
+				name, data, err := ReadFileFromDrop(ctx, evt, tc.maxBytes)
+				results <- result{name: name, data: string(data), err: err}
+			})
+			defer cleanup()
+
+			DoDropFiles(target, tc.files...)
+			select {
+			case got := <-results:
+				if diff := cmp.Diff(got.name, tc.wantName); diff != "" {
+					t.Errorf("incorrect name; -got +want: %s", diff)
+				}
+				if diff := cmp.Diff(got.data, tc.wantData); diff != "" {
+					t.Errorf("incorrect data; -got +want: %s", diff)
+				}
+				if diff := cmp.Diff(got.err, tc.wantErr, cmpopts.EquateErrors()); diff != "" {
+					t.Errorf("incorrect error; -got +want: %s", diff)
+				}
+			case <-time.After(5 * time.Second):
+				t.Errorf("drop callback not invoked")
+			}
+		})
+	}
+}
+
 func joinTextContent(objs []js.Value) string {
 	var result string
 	for _, o := range objs {
@@ -162,3 +799,72 @@ func TestGetElementsByTag(t *testing.T) {
 		t.Errorf("incorrect text content; -got +want: %s", diff)
 	}
 }
+
+func TestAttribute(t *testing.T) {
+	t.Parallel()
+
+	d := New(dt.NewDocForTesting(`
+		<button id="btn">Click</button>
+	`))
+	btn := d.GetElement("btn")
+
+	if diff := cmp.Diff(GetAttribute(btn, "title"), ""); diff != "" {
+		t.Errorf("incorrect unset attribute; -got +want: %s", diff)
+	}
+
+	SetAttribute(btn, "title", "Some title")
+	SetAttribute(btn, "type", "button")
+	if diff := cmp.Diff(GetAttribute(btn, "title"), "Some title"); diff != "" {
+		t.Errorf("incorrect title; -got +want: %s", diff)
+	}
+	if diff := cmp.Diff(btn.Get("type").String(), "button"); diff != "" {
+		t.Errorf("incorrect type; -got +want: %s", diff)
+	}
+}
+
+func TestClass(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		element     func() js.Value
+	}{
+		{
+			description: "classList",
+			element: func() js.Value {
+				d := New(dt.NewDocForTesting(`
+					<div id="div" class="first"></div>
+				`))
+				return d.GetElement("div")
+			},
+		},
+		{
+			description: "className only",
+			element: func() js.Value {
+				o := jsutil.NewObject()
+				o.Set("className", "first")
+				return o
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			o := tc.element()
+			AddClass(o, "second")
+			AddClass(o, "second")
+			if diff := cmp.Diff(o.Get("className").String(), "first second"); diff != "" {
+				t.Errorf("incorrect classes after add; -got +want: %s", diff)
+			}
+
+			RemoveClass(o, "first")
+			RemoveClass(o, "missing")
+			if diff := cmp.Diff(o.Get("className").String(), "second"); diff != "" {
+				t.Errorf("incorrect classes after remove; -got +want: %s", diff)
+			}
+		})
+	}
+}
@@ -17,6 +17,7 @@
 package jsutil
 
 import (
+	"sync"
 	"syscall/js"
 	"time"
 )
@@ -85,6 +86,61 @@ func SetTimeout(timeout time.Duration, callback func()) {
 	js.Global().Call("setTimeout", cb, timeout.Milliseconds())
 }
 
+// Debouncer coalesces calls made within a short window into a single
+// invocation of a function. The function is always invoked after the last
+// call, so it observes the final state.
+type Debouncer struct {
+	wait time.Duration
+	f    func(ctx AsyncContext)
+
+	// mu guards fields below.
+	mu sync.Mutex
+	// pending indicates that an invocation has been scheduled but has
+	// not yet started.
+	pending bool
+	// stopped indicates that no further invocations should be made.
+	stopped bool
+}
+
+// NewDebouncer returns a Debouncer that invokes f, within an async context,
+// once wait has elapsed since the first of a burst of calls.
+func NewDebouncer(wait time.Duration, f func(ctx AsyncContext)) *Debouncer {
+	return &Debouncer{wait: wait, f: f}
+}
+
+// Call schedules an invocation of the function. Calls made before the
+// scheduled invocation starts are coalesced into it; calls made while it is
+// running schedule another.
+func (d *Debouncer) Call() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.pending || d.stopped {
+		return
+	}
+	d.pending = true
+	SetTimeout(d.wait, func() {
+		d.mu.Lock()
+		d.pending = false
+		stopped := d.stopped
+		d.mu.Unlock()
+		if stopped {
+			return
+		}
+		Async(func(ctx AsyncContext) (js.Value, error) {
+			d.f(ctx)
+			return js.Undefined(), nil
+		})
+	})
+}
+
+// Stop prevents any further invocations of the function, including one that
+// is already scheduled.
+func (d *Debouncer) Stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stopped = true
+}
+
 // ExpandArgs unpacks function arguments to target values.
 func ExpandArgs(args []js.Value, target ...*js.Value) {
 	// Assign args to target.
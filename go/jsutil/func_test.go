@@ -15,6 +15,7 @@
 package jsutil
 
 import (
+	"sync"
 	"syscall/js"
 	"testing"
 	"time"
@@ -114,6 +115,62 @@ func TestSetTimeout(t *testing.T) {
 	}
 }
 
+func TestDebouncer(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var calls int
+	invoked := make(chan struct{}, 10)
+	d := NewDebouncer(50*time.Millisecond, func(_ AsyncContext) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		invoked <- struct{}{}
+	})
+	defer d.Stop()
+
+	// A burst of calls results in a single invocation.
+	for i := 0; i < 100; i++ {
+		d.Call()
+	}
+	select {
+	case <-invoked: // nothing to do.
+	case <-time.After(5 * time.Second):
+		t.Fatalf("function not invoked")
+	}
+	time.Sleep(200 * time.Millisecond)
+	mu.Lock()
+	if calls != 1 {
+		t.Errorf("incorrect number of invocations; got %d, want 1", calls)
+	}
+	mu.Unlock()
+
+	// A later call results in another invocation.
+	d.Call()
+	select {
+	case <-invoked: // nothing to do.
+	case <-time.After(5 * time.Second):
+		t.Errorf("trailing call not invoked")
+	}
+}
+
+func TestDebouncerStop(t *testing.T) {
+	t.Parallel()
+
+	invoked := make(chan struct{}, 1)
+	d := NewDebouncer(10*time.Millisecond, func(_ AsyncContext) {
+		invoked <- struct{}{}
+	})
+	d.Call()
+	d.Stop()
+
+	select {
+	case <-invoked:
+		t.Errorf("function invoked after being stopped")
+	case <-time.After(200 * time.Millisecond): // nothing to do.
+	}
+}
+
 func TestExpandArgs(t *testing.T) {
 	t.Parallel()
 
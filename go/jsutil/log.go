@@ -18,24 +18,80 @@ package jsutil
 
 import (
 	"fmt"
+	"sync/atomic"
 	"syscall/js"
 	"time"
 )
 
-// console is the default 'console' object for the browser.
-var console = js.Global().Get("console")
+// LogLevel is the minimum severity of messages logged to the Javascript
+// Console.
+type LogLevel int32
+
+const (
+	// LogLevelDebug logs all messages, including those intended only for
+	// troubleshooting.
+	LogLevelDebug LogLevel = iota
+	// LogLevelInfo logs general information, warnings and errors.
+	LogLevelInfo
+	// LogLevelWarn logs warnings and errors.
+	LogLevelWarn
+	// LogLevelError logs only errors.
+	LogLevelError
+)
+
+// DefaultLogLevel is the level at which messages are logged unless changed
+// using SetLogLevel. Debug messages are omitted to avoid cluttering the
+// console.
+const DefaultLogLevel = LogLevelInfo
+
+var (
+	// console is the default 'console' object for the browser.
+	console = js.Global().Get("console")
+	// logLevel is the current LogLevel.
+	logLevel atomic.Int32
+)
+
+func init() {
+	SetLogLevel(DefaultLogLevel)
+}
+
+// SetLogLevel sets the minimum severity of messages that are logged.
+func SetLogLevel(level LogLevel) {
+	logLevel.Store(int32(level))
+}
+
+// GetLogLevel returns the minimum severity of messages that are logged.
+func GetLogLevel() LogLevel {
+	return LogLevel(logLevel.Load())
+}
+
+// logAt logs a message using the named console method if the current level
+// permits messages of the supplied level. Each message is prefixed with a
+// timestamp.
+func logAt(level LogLevel, method string, format string, objs ...interface{}) {
+	if level < GetLogLevel() {
+		return
+	}
+	console.Call(method, time.Now().Format(time.StampMilli), fmt.Sprintf(format, objs...))
+}
 
 // Log logs general information to the Javascript Console.
 func Log(format string, objs ...interface{}) {
-	console.Call("log", time.Now().Format(time.StampMilli), fmt.Sprintf(format, objs...))
+	logAt(LogLevelInfo, "log", format, objs...)
+}
+
+// LogWarn logs a warning to the Javascript Console.
+func LogWarn(format string, objs ...interface{}) {
+	logAt(LogLevelWarn, "warn", format, objs...)
 }
 
 // LogError logs an error to the Javascript Console.
 func LogError(format string, objs ...interface{}) {
-	console.Call("error", time.Now().Format(time.StampMilli), fmt.Sprintf(format, objs...))
+	logAt(LogLevelError, "error", format, objs...)
 }
 
-// LogDebug logs a debug message to the Javascript Console.
+// LogDebug logs a debug message to the Javascript Console. It is a no-op
+// unless the level is LogLevelDebug.
 func LogDebug(format string, objs ...interface{}) {
-	console.Call("debug", time.Now().Format(time.StampMilli), fmt.Sprintf(format, objs...))
+	logAt(LogLevelDebug, "debug", format, objs...)
 }
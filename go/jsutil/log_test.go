@@ -0,0 +1,90 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jsutil
+
+import (
+	"syscall/js"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakeConsole replaces the console for the duration of a test, and returns
+// a function that reports the methods invoked since.
+func fakeConsole(t *testing.T) func() []string {
+	t.Helper()
+
+	var calls []string
+	obj := NewObject()
+	var funcs []js.Func
+	for _, method := range []string{"debug", "log", "warn", "error"} {
+		method := method
+		f := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			calls = append(calls, method)
+			return nil
+		})
+		funcs = append(funcs, f)
+		obj.Set(method, f)
+	}
+
+	orig := console
+	console = obj
+	t.Cleanup(func() {
+		console = orig
+		for _, f := range funcs {
+			f.Release()
+		}
+	})
+	return func() []string { return calls }
+}
+
+func TestLogLevel(t *testing.T) {
+	// Not parallel; replaces package-level state.
+	defer SetLogLevel(GetLogLevel())
+
+	testcases := []struct {
+		level LogLevel
+		want  []string
+	}{
+		{
+			level: LogLevelDebug,
+			want:  []string{"debug", "log", "warn", "error"},
+		},
+		{
+			level: LogLevelInfo,
+			want:  []string{"log", "warn", "error"},
+		},
+		{
+			level: LogLevelWarn,
+			want:  []string{"warn", "error"},
+		},
+		{
+			level: LogLevelError,
+			want:  []string{"error"},
+		},
+	}
+
+	for _, tc := range testcases {
+		calls := fakeConsole(t)
+		SetLogLevel(tc.level)
+		LogDebug("debug %d", 1)
+		Log("info %d", 2)
+		LogWarn("warn %d", 3)
+		LogError("error %d", 4)
+		if diff := cmp.Diff(calls(), tc.want); diff != "" {
+			t.Errorf("incorrect messages logged at level %d; -got +want: %s", tc.level, diff)
+		}
+	}
+}
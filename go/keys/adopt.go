@@ -0,0 +1,109 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	// errPublicOnly is returned when loading a key adopted from the
+	// agent. The agent does not expose private keys, so only the public
+	// key is configured.
+	errPublicOnly = errors.New("only the public key is configured; add the private key to load it")
+)
+
+// publicOnly returns the public key if only the public key is configured
+// (i.e., the key was adopted from the agent), or nil otherwise.
+func (s *storedKey) publicOnly() ssh.PublicKey {
+	if s.PEMPrivateKey != "" || s.AuthorizedKey == "" {
+		return nil
+	}
+	pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(s.AuthorizedKey))
+	if err != nil {
+		return nil
+	}
+	return pub
+}
+
+// AdoptLoaded implements Manager.AdoptLoaded.
+func (m *DefaultManager) AdoptLoaded(ctx jsutil.AsyncContext, key *LoadedKey, name string) error {
+	pub, err := ssh.ParsePublicKey(key.Blob())
+	if err != nil {
+		return fmt.Errorf("%w: %w", errParseFailed, err)
+	}
+	supplied := name
+	if strings.TrimSpace(name) == "" {
+		name = key.Comment
+	}
+	name, err = normalizeName(name)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		name = placeholderName
+	}
+
+	id, err := newKeyID()
+	if err != nil {
+		return err
+	}
+	sk := &storedKey{
+		ID:            id,
+		Name:          name,
+		AuthorizedKey: strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub))),
+		Encryption:    string(encryptionNone),
+		SchemaVersion: storedKeySchemaVersion,
+	}
+	if err := m.checkDuplicate(ctx, sk); err != nil {
+		return err
+	}
+	if err := m.checkName(ctx, supplied); err != nil {
+		return err
+	}
+	return m.storedKeys.WriteKey(ctx, sk.ID, sk)
+}
+
+// loadedPublicOnly returns the loaded key corresponding to the public-only
+// key with the specified ID, or nil if there is none. Public-only keys are
+// loaded by something other than this extension, so the loaded key is
+// identified by its public key rather than by the ID in its comment.
+func (m *DefaultManager) loadedPublicOnly(ctx jsutil.AsyncContext, id ID, loaded []*LoadedKey) (*LoadedKey, error) {
+	sk, err := m.readStoredKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if sk == nil {
+		return nil, nil
+	}
+	pub := sk.publicOnly()
+	if pub == nil {
+		return nil, nil
+	}
+	blob := pub.Marshal()
+	for _, l := range loaded {
+		if string(l.Blob()) == string(blob) {
+			return l, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,259 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// addExternal loads a key into the agent directly (i.e., not through the
+// manager), and returns the corresponding loaded key.
+func addExternal(ctx jsutil.AsyncContext, t *testing.T, agt agent.Agent, mgr *DefaultManager, pemPrivateKey, comment string) *LoadedKey {
+	t.Helper()
+
+	priv, err := ssh.ParseRawPrivateKey([]byte(pemPrivateKey))
+	if err != nil {
+		t.Fatalf("failed to parse private key: %v", err)
+	}
+	if err := agt.Add(agent.AddedKey{PrivateKey: priv, Comment: comment}); err != nil {
+		t.Fatalf("failed to load key into agent: %v", err)
+	}
+	loaded, err := mgr.Loaded(ctx)
+	if err != nil {
+		t.Fatalf("failed to enumerate loaded keys: %v", err)
+	}
+	for _, l := range loaded {
+		if l.Comment == comment {
+			return l
+		}
+	}
+	t.Fatalf("key %s not loaded", comment)
+	return nil
+}
+
+func TestAdoptLoaded(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		name        string
+		wantName    string
+	}{
+		{
+			description: "named key",
+			name:        "adopted-key",
+			wantName:    "adopted-key",
+		},
+		{
+			description: "name from comment",
+			wantName:    "external-comment",
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				agt := agent.NewKeyring()
+				syncStorage := storage.NewRaw(st.NewMemArea())
+				sessionStorage := storage.NewRaw(st.NewMemArea())
+				mgr, err := newTestManager(ctx, agt, syncStorage, sessionStorage, nil)
+				if err != nil {
+					t.Fatalf("failed to initialize manager: %v", err)
+				}
+				external := addExternal(ctx, t, agt, mgr, testdata.WithoutPassphrase.Private, "external-comment")
+
+				if err := mgr.AdoptLoaded(ctx, external, tc.name); err != nil {
+					t.Fatalf("failed to adopt key: %v", err)
+				}
+
+				// The key is configured with only its public key.
+				configured, err := mgr.Configured(ctx)
+				if err != nil {
+					t.Fatalf("failed to enumerate configured keys: %v", err)
+				}
+				if len(configured) != 1 {
+					t.Fatalf("incorrect number of configured keys: got %d, want 1", len(configured))
+				}
+				c := configured[0]
+				if diff := cmp.Diff(c.Name, tc.wantName); diff != "" {
+					t.Errorf("incorrect name; -got +want: %s", diff)
+				}
+				if !c.PublicOnly {
+					t.Errorf("adopted key not public-only")
+				}
+				if diff := cmp.Diff(c.Unsupported, errPublicOnly.Error()); diff != "" {
+					t.Errorf("incorrect unsupported reason; -got +want: %s", diff)
+				}
+				pub, err := ssh.ParsePublicKey(external.Blob())
+				if err != nil {
+					t.Fatalf("failed to parse public key: %v", err)
+				}
+				if diff := cmp.Diff(c.Fingerprint, ssh.FingerprintSHA256(pub)); diff != "" {
+					t.Errorf("incorrect fingerprint; -got +want: %s", diff)
+				}
+
+				// The loaded key is identified as the adopted key.
+				loaded, err := mgr.Loaded(ctx)
+				if err != nil {
+					t.Fatalf("failed to enumerate loaded keys: %v", err)
+				}
+				merged := MergeKeys(configured, loaded)
+				if len(merged) != 1 {
+					t.Fatalf("incorrect number of merged keys: got %d, want 1", len(merged))
+				}
+				if diff := cmp.Diff(merged[0].ID, ID(c.ID)); diff != "" {
+					t.Errorf("incorrect merged ID; -got +want: %s", diff)
+				}
+				if !merged[0].Loaded {
+					t.Errorf("adopted key not loaded")
+				}
+
+				// Stored keys with only a public key are not corrupt.
+				n, err := mgr.CorruptKeys(ctx)
+				if err != nil {
+					t.Fatalf("failed to count corrupt keys: %v", err)
+				}
+				if n != 0 {
+					t.Errorf("adopted key treated as corrupt")
+				}
+			})
+		})
+	}
+}
+
+func TestAdoptLoadedDuplicate(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		agt := agent.NewKeyring()
+		syncStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		mgr, err := newTestManager(ctx, agt, syncStorage, sessionStorage, []*initialKey{
+			{
+				Name:          "configured-key",
+				PEMPrivateKey: testdata.WithoutPassphrase.Private,
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+		external := addExternal(ctx, t, agt, mgr, testdata.WithoutPassphrase.Private, "external-comment")
+
+		err = mgr.AdoptLoaded(ctx, external, "adopted-key")
+		if diff := cmp.Diff(err, ErrDuplicateKey, cmpopts.EquateErrors()); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestAdoptLoadedNameInUse(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		agt := agent.NewKeyring()
+		syncStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		mgr, err := newTestManager(ctx, agt, syncStorage, sessionStorage, []*initialKey{
+			{
+				Name:          "configured-key",
+				PEMPrivateKey: testdata.WithPassphrase.Private,
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+		external := addExternal(ctx, t, agt, mgr, testdata.WithoutPassphrase.Private, "configured-key")
+
+		err = mgr.AdoptLoaded(ctx, external, "configured-key")
+		if diff := cmp.Diff(err, ErrNameInUse, cmpopts.EquateErrors()); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+		if !errors.Is(err, ErrInvalidName) {
+			t.Errorf("error %v is not ErrInvalidName", err)
+		}
+
+		// The key's comment is used when no name is supplied, even if
+		// already in use.
+		if err := mgr.AdoptLoaded(ctx, external, ""); err != nil {
+			t.Errorf("failed to adopt key named by comment: %v", err)
+		}
+	})
+}
+
+func TestPublicOnlyKey(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		agt := agent.NewKeyring()
+		syncStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		mgr, err := newTestManager(ctx, agt, syncStorage, sessionStorage, nil)
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+		external := addExternal(ctx, t, agt, mgr, testdata.WithoutPassphrase.Private, "external-comment")
+		if err := mgr.AdoptLoaded(ctx, external, "adopted-key"); err != nil {
+			t.Fatalf("failed to adopt key: %v", err)
+		}
+		id, err := findKey(ctx, mgr, InvalidID, "adopted-key")
+		if err != nil {
+			t.Fatalf("failed to find key: %v", err)
+		}
+
+		// A public-only key can be unloaded, but not loaded again.
+		if err := mgr.Unload(ctx, id); err != nil {
+			t.Errorf("failed to unload adopted key: %v", err)
+		}
+		loaded, err := mgr.Loaded(ctx)
+		if err != nil {
+			t.Fatalf("failed to enumerate loaded keys: %v", err)
+		}
+		if len(loaded) != 0 {
+			t.Errorf("adopted key still loaded")
+		}
+		err = mgr.Load(ctx, id, "", LoadOptions{})
+		if diff := cmp.Diff(err, errPublicOnly, cmpopts.EquateErrors()); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+
+		// Public-only keys are omitted from backups, since there is no
+		// private key to restore.
+		data, err := mgr.Export(ctx)
+		if err != nil {
+			t.Fatalf("failed to export: %v", err)
+		}
+		b, err := parseBackup(data)
+		if err != nil {
+			t.Fatalf("failed to parse backup: %v", err)
+		}
+		if len(b.Keys) != 0 {
+			t.Errorf("public-only key included in backup")
+		}
+	})
+}
@@ -0,0 +1,316 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"syscall/js"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	"github.com/norunners/vert"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+var (
+	// agentLockPrefixes are the prefixes for the agent lock. It is kept
+	// in session storage alongside loaded keys, so that keys restored
+	// when the background worker restarts remain locked.
+	agentLockPrefixes = []string{"agentLock"}
+)
+
+const (
+	// agentLockKey is the key at which the hash of the agent lock
+	// passphrase is stored.
+	agentLockKey = "hash"
+)
+
+var (
+	// errAgentLocked is returned for agent requests that are refused
+	// because the agent is locked.
+	errAgentLocked = errors.New("agent is locked")
+	// errAgentAlreadyLocked is returned when locking an agent that is
+	// already locked.
+	errAgentAlreadyLocked = errors.New("agent is already locked")
+	// errAgentNotLocked is returned when unlocking an agent that is not
+	// locked.
+	errAgentNotLocked = errors.New("agent is not locked")
+	// errIncorrectLockPassphrase is returned when the passphrase supplied
+	// to unlock the agent does not match the one with which it was
+	// locked.
+	errIncorrectLockPassphrase = errors.New("incorrect agent lock passphrase")
+	// errInvalidLockPassphrase is returned when locking the agent with
+	// an unacceptable passphrase.
+	errInvalidLockPassphrase = errors.New("invalid agent lock passphrase")
+)
+
+// IsAgentLocked determines if an error returned by a Manager indicates that
+// the request was refused because the agent is locked.
+func IsAgentLocked(err error) bool {
+	return errors.Is(err, errAgentLocked)
+}
+
+// LockChangedFunc is invoked when an agent is locked or unlocked.
+type LockChangedFunc func()
+
+// LockableAgent wraps an agent and implements the agent-wide lock (i.e.,
+// SSH_AGENTC_LOCK and SSH_AGENTC_UNLOCK, as requested by 'ssh-add -x').
+// While locked, the agent lists no keys and refuses all other requests until
+// it is unlocked with the passphrase with which it was locked.
+//
+// Unlike the lock implemented by agent.NewKeyring, the lock state may be
+// queried and restored, so that it survives the background worker being
+// restarted.
+type LockableAgent struct {
+	agent.ExtendedAgent
+
+	onChange LockChangedFunc
+
+	// mu guards fields below.
+	mu sync.Mutex
+	// hash is the hash of the passphrase with which the agent was
+	// locked, computed as for the master passphrase. Nil if the agent
+	// is not locked.
+	hash *storedMasterPassphrase
+}
+
+// NewLockableAgent returns a LockableAgent wrapping the supplied agent.
+// onChange, if non-nil, is invoked when the agent is locked or unlocked.
+func NewLockableAgent(agt agent.ExtendedAgent, onChange LockChangedFunc) *LockableAgent {
+	return &LockableAgent{
+		ExtendedAgent: agt,
+		onChange:      onChange,
+	}
+}
+
+// Locked indicates whether the agent is locked.
+func (a *LockableAgent) Locked() bool {
+	return a.lockHash() != nil
+}
+
+// lockHash returns the hash of the passphrase with which the agent was
+// locked, or nil if it is not locked.
+func (a *LockableAgent) lockHash() *storedMasterPassphrase {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.hash
+}
+
+// restoreLock locks the agent using a previously-computed passphrase hash,
+// or unlocks it if hash is nil. onChange is not invoked.
+func (a *LockableAgent) restoreLock(hash *storedMasterPassphrase) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.hash = hash
+}
+
+// changed reports that the agent was locked or unlocked.
+func (a *LockableAgent) changed() {
+	if a.onChange != nil {
+		a.onChange()
+	}
+}
+
+// Lock implements agent.Agent.Lock.
+func (a *LockableAgent) Lock(passphrase []byte) error {
+	hash, err := newStoredMasterPassphrase(string(passphrase))
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	if a.hash != nil {
+		a.mu.Unlock()
+		return errAgentAlreadyLocked
+	}
+	a.hash = hash
+	a.mu.Unlock()
+
+	a.changed()
+	return nil
+}
+
+// Unlock implements agent.Agent.Unlock.
+func (a *LockableAgent) Unlock(passphrase []byte) error {
+	a.mu.Lock()
+	hash := a.hash
+	a.mu.Unlock()
+	if hash == nil {
+		return errAgentNotLocked
+	}
+
+	ok, err := hash.matches(string(passphrase))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errIncorrectLockPassphrase
+	}
+
+	a.mu.Lock()
+	a.hash = nil
+	a.mu.Unlock()
+
+	a.changed()
+	return nil
+}
+
+// List implements agent.Agent.List. A locked agent lists no keys.
+func (a *LockableAgent) List() ([]*agent.Key, error) {
+	if a.Locked() {
+		return []*agent.Key{}, nil
+	}
+	return a.ExtendedAgent.List()
+}
+
+// Sign implements agent.Agent.Sign.
+func (a *LockableAgent) Sign(key ssh.PublicKey, data []byte) (*ssh.Signature, error) {
+	if a.Locked() {
+		return nil, errAgentLocked
+	}
+	return a.ExtendedAgent.Sign(key, data)
+}
+
+// SignWithFlags implements agent.ExtendedAgent.SignWithFlags.
+func (a *LockableAgent) SignWithFlags(key ssh.PublicKey, data []byte, flags agent.SignatureFlags) (*ssh.Signature, error) {
+	if a.Locked() {
+		return nil, errAgentLocked
+	}
+	return a.ExtendedAgent.SignWithFlags(key, data, flags)
+}
+
+// Add implements agent.Agent.Add.
+func (a *LockableAgent) Add(key agent.AddedKey) error {
+	if a.Locked() {
+		return errAgentLocked
+	}
+	return a.ExtendedAgent.Add(key)
+}
+
+// Remove implements agent.Agent.Remove.
+func (a *LockableAgent) Remove(key ssh.PublicKey) error {
+	if a.Locked() {
+		return errAgentLocked
+	}
+	return a.ExtendedAgent.Remove(key)
+}
+
+// RemoveAll implements agent.Agent.RemoveAll.
+func (a *LockableAgent) RemoveAll() error {
+	if a.Locked() {
+		return errAgentLocked
+	}
+	return a.ExtendedAgent.RemoveAll()
+}
+
+// Signers implements agent.Agent.Signers.
+func (a *LockableAgent) Signers() ([]ssh.Signer, error) {
+	if a.Locked() {
+		return nil, errAgentLocked
+	}
+	return a.ExtendedAgent.Signers()
+}
+
+// Extension implements agent.ExtendedAgent.Extension. A locked agent declines
+// all extensions, which is reported to the client as SSH_AGENT_FAILURE.
+func (a *LockableAgent) Extension(extensionType string, contents []byte) ([]byte, error) {
+	if a.Locked() {
+		return nil, agent.ErrExtensionUnsupported
+	}
+	return a.ExtendedAgent.Extension(extensionType, contents)
+}
+
+// LockAgent implements Manager.LockAgent.
+func (m *DefaultManager) LockAgent(ctx jsutil.AsyncContext, passphrase string) error {
+	if passphrase == "" {
+		return fmt.Errorf("%w: passphrase must not be empty", errInvalidLockPassphrase)
+	}
+	if err := m.agent.Lock([]byte(passphrase)); err != nil {
+		return err
+	}
+	return m.SaveAgentLock(ctx)
+}
+
+// UnlockAgent implements Manager.UnlockAgent.
+func (m *DefaultManager) UnlockAgent(ctx jsutil.AsyncContext, passphrase string) error {
+	if err := m.agent.Unlock([]byte(passphrase)); err != nil {
+		return err
+	}
+	return m.SaveAgentLock(ctx)
+}
+
+// AgentLocked implements Manager.AgentLocked.
+func (m *DefaultManager) AgentLocked(_ jsutil.AsyncContext) (bool, error) {
+	la, ok := m.agent.(*LockableAgent)
+	if !ok {
+		// Other agents do not report whether they are locked.
+		return false, nil
+	}
+	return la.Locked(), nil
+}
+
+// SaveAgentLock records the agent's lock in session storage, so that it can
+// be restored by LoadFromSession if the background worker restarts. It must
+// be invoked whenever the agent is locked or unlocked other than through the
+// manager (e.g., by a client of the agent).
+func (m *DefaultManager) SaveAgentLock(ctx jsutil.AsyncContext) error {
+	la, ok := m.agent.(*LockableAgent)
+	if !ok {
+		return nil
+	}
+
+	hash := la.lockHash()
+	if hash == nil {
+		if err := m.agentLock.Delete(ctx, []string{agentLockKey}); err != nil {
+			return fmt.Errorf("failed to remove agent lock: %w", err)
+		}
+		return nil
+	}
+	data := map[string]js.Value{
+		agentLockKey: vert.ValueOf(hash).JSValue(),
+	}
+	if err := m.agentLock.Set(ctx, data); err != nil {
+		return fmt.Errorf("failed to write agent lock: %w", err)
+	}
+	return nil
+}
+
+// restoreAgentLock locks the agent if it was locked when its lock was last
+// saved.
+func (m *DefaultManager) restoreAgentLock(ctx jsutil.AsyncContext) error {
+	la, ok := m.agent.(*LockableAgent)
+	if !ok {
+		return nil
+	}
+
+	data, err := m.agentLock.Get(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read agent lock: %w", err)
+	}
+	val, present := data[agentLockKey]
+	if !present {
+		return nil
+	}
+	var hash storedMasterPassphrase
+	if err := vert.ValueOf(val).AssignTo(&hash); err != nil {
+		return fmt.Errorf("failed to parse agent lock: %w", err)
+	}
+	la.restoreLock(&hash)
+	return nil
+}
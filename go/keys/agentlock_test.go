@@ -0,0 +1,216 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// signRequest sends an SSH_AGENTC_SIGN_REQUEST to an agent served over
+// conn, and returns the agent's response message.
+func signRequest(conn net.Conn, key ssh.PublicKey, data []byte) ([]byte, error) {
+	return agentRequest(conn, ssh.Marshal(struct {
+		Type  byte
+		Blob  []byte
+		Data  []byte
+		Flags uint32
+	}{13, key.Marshal(), data, 0}))
+}
+
+func TestLockableAgent(t *testing.T) {
+	t.Parallel()
+
+	priv, err := ssh.ParseRawPrivateKey([]byte(testdata.WithoutPassphrase.Private))
+	if err != nil {
+		t.Fatalf("failed to parse private key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+
+	keyring := agent.NewKeyring().(agent.ExtendedAgent)
+	if err := keyring.Add(agent.AddedKey{PrivateKey: priv}); err != nil {
+		t.Fatalf("failed to add key: %v", err)
+	}
+	var mu sync.Mutex
+	changes := 0
+	agt := NewLockableAgent(keyring, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		changes++
+	})
+	changed := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return changes
+	}
+
+	conn, server := net.Pipe()
+	defer conn.Close()
+	go agent.ServeAgent(agt, server)
+	client := agent.NewClient(conn)
+
+	if err := client.Lock([]byte("lock-passphrase")); err != nil {
+		t.Fatalf("failed to lock agent: %v", err)
+	}
+	if !agt.Locked() {
+		t.Errorf("agent not locked")
+	}
+	if diff := cmp.Diff(changed(), 1); diff != "" {
+		t.Errorf("incorrect number of changes; -got +want: %s", diff)
+	}
+
+	// A locked agent lists no keys, and refuses to sign data with
+	// SSH_AGENT_FAILURE.
+	keys, err := client.List()
+	if err != nil {
+		t.Errorf("failed to list keys: %v", err)
+	}
+	if len(keys) != 0 {
+		t.Errorf("locked agent listed %d keys; want none", len(keys))
+	}
+	rsp, err := signRequest(conn, signer.PublicKey(), []byte("some-data"))
+	if err != nil {
+		t.Fatalf("sign request failed: %v", err)
+	}
+	if diff := cmp.Diff(rsp, []byte{5}); diff != "" { // SSH_AGENT_FAILURE
+		t.Errorf("incorrect response to sign request; -got +want: %s", diff)
+	}
+
+	// The agent may not be locked twice, and is only unlocked by the
+	// passphrase with which it was locked.
+	if err := client.Lock([]byte("other-passphrase")); err == nil {
+		t.Errorf("locked agent locked again")
+	}
+	if err := client.Unlock([]byte("wrong-passphrase")); err == nil {
+		t.Errorf("agent unlocked with incorrect passphrase")
+	}
+	if !agt.Locked() {
+		t.Errorf("agent unlocked after failed attempts")
+	}
+
+	if err := client.Unlock([]byte("lock-passphrase")); err != nil {
+		t.Fatalf("failed to unlock agent: %v", err)
+	}
+	if diff := cmp.Diff(changed(), 2); diff != "" {
+		t.Errorf("incorrect number of changes; -got +want: %s", diff)
+	}
+	keys, err = client.List()
+	if err != nil {
+		t.Errorf("failed to list keys: %v", err)
+	}
+	if len(keys) != 1 {
+		t.Errorf("unlocked agent listed %d keys; want 1", len(keys))
+	}
+	if _, err := client.Sign(signer.PublicKey(), []byte("some-data")); err != nil {
+		t.Errorf("failed to sign after unlocking: %v", err)
+	}
+	if err := client.Unlock([]byte("lock-passphrase")); err == nil {
+		t.Errorf("unlocked agent unlocked again")
+	}
+}
+
+func TestManagerLockAgent(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		syncStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		agt := NewLockableAgent(agent.NewKeyring().(agent.ExtendedAgent), nil)
+		mgr, err := newTestManager(ctx, agt, syncStorage, sessionStorage, []*initialKey{
+			{
+				Name:          "good-key",
+				PEMPrivateKey: testdata.WithoutPassphrase.Private,
+				Load:          true,
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+
+		if err := mgr.LockAgent(ctx, ""); err == nil {
+			t.Errorf("agent locked with empty passphrase")
+		}
+		if err := mgr.LockAgent(ctx, "lock-passphrase"); err != nil {
+			t.Fatalf("failed to lock agent: %v", err)
+		}
+		if locked, err := mgr.AgentLocked(ctx); err != nil || !locked {
+			t.Errorf("agent not locked: locked=%t, err=%v", locked, err)
+		}
+
+		// Keys are hidden, and may not be loaded, while locked.
+		loaded, err := mgr.Loaded(ctx)
+		if err != nil {
+			t.Fatalf("failed to get loaded keys: %v", err)
+		}
+		if len(loaded) != 0 {
+			t.Errorf("locked agent reported %d loaded keys; want none", len(loaded))
+		}
+		id, err := findKey(ctx, mgr, InvalidID, "good-key")
+		if err != nil {
+			t.Fatalf("failed to find key: %v", err)
+		}
+		if err := mgr.Load(ctx, id, "", LoadOptions{}); !IsAgentLocked(err) {
+			t.Errorf("incorrect error loading key into locked agent: got %v, want agent locked", err)
+		}
+
+		// The lock survives the background worker restarting.
+		restarted := NewLockableAgent(agent.NewKeyring().(agent.ExtendedAgent), nil)
+		mgr = NewManager(restarted, syncStorage, storage.NewRaw(st.NewMemArea()), sessionStorage)
+		if err := mgr.LoadFromSession(ctx); err != nil {
+			t.Fatalf("failed to load from session: %v", err)
+		}
+		if !restarted.Locked() {
+			t.Errorf("agent not locked after restart")
+		}
+
+		if err := mgr.UnlockAgent(ctx, "wrong-passphrase"); !errors.Is(err, errIncorrectLockPassphrase) {
+			t.Errorf("incorrect error unlocking with wrong passphrase: got %v, want %v", err, errIncorrectLockPassphrase)
+		}
+		if err := mgr.UnlockAgent(ctx, "lock-passphrase"); err != nil {
+			t.Fatalf("failed to unlock agent: %v", err)
+		}
+		loaded, err = mgr.Loaded(ctx)
+		if err != nil {
+			t.Fatalf("failed to get loaded keys: %v", err)
+		}
+		if diff := cmp.Diff(loadedKeyIDs(loaded), []ID{id}); diff != "" {
+			t.Errorf("incorrect loaded keys after unlocking; -got +want: %s", diff)
+		}
+
+		// Once unlocked, the agent is no longer locked after a restart.
+		restarted = NewLockableAgent(agent.NewKeyring().(agent.ExtendedAgent), nil)
+		mgr = NewManager(restarted, syncStorage, storage.NewRaw(st.NewMemArea()), sessionStorage)
+		if err := mgr.LoadFromSession(ctx); err != nil {
+			t.Fatalf("failed to load from session: %v", err)
+		}
+		if restarted.Locked() {
+			t.Errorf("agent locked after restart")
+		}
+	})
+}
@@ -0,0 +1,153 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// Agent protocol message numbers. See [PROTOCOL.agent] section 5.1.
+const (
+	agentFailure                    = 5
+	agentAddSmartcardKey            = 20
+	agentRemoveSmartcardKey         = 21
+	agentAddSmartcardKeyConstrained = 26
+)
+
+const (
+	// maxAgentRequestBytes is the size of the largest request read from
+	// the client. Larger requests are passed to agent.ServeAgent, which
+	// rejects them.
+	maxAgentRequestBytes = 16 << 20
+)
+
+// agentRequestHandler processes a request from the client, excluding its
+// length prefix. It returns the response to send to the client, or nil if
+// the request is to be served by the agent.
+type agentRequestHandler func(req []byte) []byte
+
+// serveByAgent passes the request to the agent.
+func serveByAgent(req []byte) []byte {
+	return nil
+}
+
+// unsupportedRequest declines the request with SSH_AGENT_FAILURE, allowing
+// the client to continue using the connection. agent.ServeAgent would also
+// decline the request, but reports each as an error in the log.
+func unsupportedRequest(req []byte) []byte {
+	jsutil.LogDebug("ServeAgent: declining unsupported request type %d", req[0])
+	return []byte{agentFailure}
+}
+
+var (
+	// agentRequestHandlers are the handlers for requests that are not
+	// served by the agent. Requests of any other type (including the
+	// obsolete SSH1 requests, which agent.ServeAgent answers as though no
+	// SSH1 keys are held) are served by serveByAgent.
+	agentRequestHandlers = map[byte]agentRequestHandler{
+		// Keys held by a smartcard (i.e., a PKCS#11 provider) are
+		// accessed through a library on the client's machine, which
+		// the browser cannot load.
+		agentAddSmartcardKey:            unsupportedRequest,
+		agentRemoveSmartcardKey:         unsupportedRequest,
+		agentAddSmartcardKeyConstrained: unsupportedRequest,
+	}
+)
+
+// requestDispatcher wraps the connection to a client, and dispatches each
+// request to its handler in agentRequestHandlers. Requests to be served by
+// the agent are read by agent.ServeAgent; responses to other requests are
+// written to the client directly. Requests are dispatched here rather than
+// by wrapping the agent, since agent.ServeAgent answers requests of a type it
+// does not recognize without consulting the agent.
+//
+// agent.ServeAgent writes its response to a request before reading the next,
+// so responses are sent in the order the requests were received.
+type requestDispatcher struct {
+	rw      io.ReadWriter
+	pending []byte
+}
+
+// Read implements io.Reader.Read.
+func (d *requestDispatcher) Read(p []byte) (int, error) {
+	for len(d.pending) == 0 {
+		req, err := d.readRequest()
+		if err != nil {
+			return 0, err
+		}
+		if req != nil {
+			d.pending = req
+		}
+	}
+	n := copy(p, d.pending)
+	d.pending = d.pending[n:]
+	return n, nil
+}
+
+// readRequest reads the next request from the client. It returns the request
+// (including its length prefix) if it is to be served by the agent, or nil if
+// it was handled.
+func (d *requestDispatcher) readRequest() ([]byte, error) {
+	var length [4]byte
+	if _, err := io.ReadFull(d.rw, length[:]); err != nil {
+		return nil, err
+	}
+	l := binary.BigEndian.Uint32(length[:])
+	if l == 0 || l > maxAgentRequestBytes {
+		// Leave agent.ServeAgent to reject the request.
+		return length[:], nil
+	}
+
+	msg := make([]byte, 4+l)
+	copy(msg, length[:])
+	if _, err := io.ReadFull(d.rw, msg[4:]); err != nil {
+		return nil, err
+	}
+	req := msg[4:]
+
+	handler, ok := agentRequestHandlers[req[0]]
+	if !ok {
+		handler = serveByAgent
+	}
+	rsp := handler(req)
+	if rsp == nil {
+		return msg, nil
+	}
+	binary.BigEndian.PutUint32(length[:], uint32(len(rsp)))
+	if _, err := d.rw.Write(append(length[:], rsp...)); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// Write implements io.Writer.Write.
+func (d *requestDispatcher) Write(p []byte) (int, error) {
+	return d.rw.Write(p)
+}
+
+// ServeAgent serves the agent protocol to the client connected to c until
+// the connection is closed, in the same manner as agent.ServeAgent. Requests
+// to add or remove smartcard keys, which the agent cannot support, are
+// declined with SSH_AGENT_FAILURE without being reported as errors, so that
+// the client can continue using the connection.
+func ServeAgent(agt agent.Agent, c io.ReadWriter) error {
+	return agent.ServeAgent(agt, &requestDispatcher{rw: c})
+}
@@ -0,0 +1,221 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"bytes"
+	"log"
+	"net"
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestServeAgentUnsupportedRequests(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		req         []byte
+	}{
+		{
+			description: "add smartcard key",
+			req: ssh.Marshal(struct {
+				Type     byte
+				ReaderID string
+				PIN      string
+			}{agentAddSmartcardKey, "/usr/lib/opensc-pkcs11.so", "1234"}),
+		},
+		{
+			description: "remove smartcard key",
+			req: ssh.Marshal(struct {
+				Type     byte
+				ReaderID string
+				PIN      string
+			}{agentRemoveSmartcardKey, "/usr/lib/opensc-pkcs11.so", "1234"}),
+		},
+		{
+			description: "add smartcard key with constraints",
+			req: ssh.Marshal(struct {
+				Type        byte
+				ReaderID    string
+				PIN         string
+				Constraints []byte `ssh:"rest"`
+			}{agentAddSmartcardKeyConstrained, "/usr/lib/opensc-pkcs11.so", "1234", []byte{1, 0, 0, 0, 60}}),
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			priv, err := ssh.ParseRawPrivateKey([]byte(testdata.WithoutPassphrase.Private))
+			if err != nil {
+				t.Fatalf("failed to parse private key: %v", err)
+			}
+			keyring := agent.NewKeyring()
+			if err := keyring.Add(agent.AddedKey{PrivateKey: priv}); err != nil {
+				t.Fatalf("failed to add key: %v", err)
+			}
+
+			conn, server := net.Pipe()
+			defer conn.Close()
+			go ServeAgent(keyring, server)
+			client := agent.NewClient(conn)
+
+			rsp, err := agentRequest(conn, tc.req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if diff := cmp.Diff(rsp, []byte{agentFailure}); diff != "" {
+				t.Errorf("incorrect response; -got +want: %s", diff)
+			}
+
+			// The connection remains usable.
+			keys, err := client.List()
+			if err != nil {
+				t.Fatalf("failed to list keys after declined request: %v", err)
+			}
+			if diff := cmp.Diff(len(keys), 1); diff != "" {
+				t.Errorf("incorrect number of keys; -got +want: %s", diff)
+			}
+		})
+	}
+}
+
+func TestServeAgentSupportedRequests(t *testing.T) {
+	t.Parallel()
+
+	priv, err := ssh.ParseRawPrivateKey([]byte(testdata.WithoutPassphrase.Private))
+	if err != nil {
+		t.Fatalf("failed to parse private key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+
+	conn, server := net.Pipe()
+	defer conn.Close()
+	go ServeAgent(agent.NewKeyring(), server)
+	client := agent.NewClient(conn)
+
+	// Requests are served by the agent.
+	if err := client.Add(agent.AddedKey{PrivateKey: priv}); err != nil {
+		t.Fatalf("failed to add key: %v", err)
+	}
+	keys, err := client.List()
+	if err != nil {
+		t.Fatalf("failed to list keys: %v", err)
+	}
+	if diff := cmp.Diff(len(keys), 1); diff != "" {
+		t.Errorf("incorrect number of keys; -got +want: %s", diff)
+	}
+	if _, err := client.Sign(signer.PublicKey(), []byte("some-data")); err != nil {
+		t.Errorf("failed to sign: %v", err)
+	}
+	if err := client.RemoveAll(); err != nil {
+		t.Fatalf("failed to remove keys: %v", err)
+	}
+	keys, err = client.List()
+	if err != nil {
+		t.Fatalf("failed to list keys: %v", err)
+	}
+	if diff := cmp.Diff(len(keys), 0); diff != "" {
+		t.Errorf("incorrect number of keys after removal; -got +want: %s", diff)
+	}
+}
+
+func TestServeAgentRequestsServedByAgent(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		req         []byte
+		want        []byte
+	}{
+		{
+			description: "list ssh1 keys",
+			req:         []byte{1},
+			want:        []byte{2, 0, 0, 0, 0},
+		},
+		{
+			description: "remove all ssh1 keys",
+			req:         []byte{9},
+			want:        []byte{6},
+		},
+		{
+			description: "unknown request",
+			req:         []byte{99, 1, 2, 3},
+			want:        []byte{agentFailure},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			conn, server := net.Pipe()
+			defer conn.Close()
+			go ServeAgent(agent.NewKeyring(), server)
+
+			rsp, err := agentRequest(conn, tc.req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if diff := cmp.Diff(rsp, tc.want); diff != "" {
+				t.Errorf("incorrect response; -got +want: %s", diff)
+			}
+		})
+	}
+}
+
+// TestServeAgentUnsupportedRequestsNotLogged is not run in parallel, since it
+// replaces the output of the standard logger.
+func TestServeAgentUnsupportedRequestsNotLogged(t *testing.T) {
+	var logged bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logged)
+
+	conn, server := net.Pipe()
+	defer conn.Close()
+	go ServeAgent(agent.NewKeyring(), server)
+
+	// Smartcard requests are declined without being reported.
+	req := ssh.Marshal(struct {
+		Type     byte
+		ReaderID string
+		PIN      string
+	}{agentAddSmartcardKey, "/usr/lib/opensc-pkcs11.so", "1234"})
+	if _, err := agentRequest(conn, req); err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if diff := cmp.Diff(logged.String(), ""); diff != "" {
+		t.Errorf("unexpected log output; -got +want: %s", diff)
+	}
+
+	// Unknown requests are reported by the agent.
+	if _, err := agentRequest(conn, []byte{99}); err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if logged.Len() == 0 {
+		t.Errorf("unknown request not reported")
+	}
+}
@@ -0,0 +1,230 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"errors"
+	"fmt"
+	"syscall/js"
+	"time"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	"github.com/norunners/vert"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// AuditEntry records a request to sign data using a key, or another
+// sensitive operation on a key (see Action).
+type AuditEntry struct {
+	// Action identifies the operation performed (e.g.,
+	// AuditActionExportPrivate), or is empty for a request to sign data.
+	Action string `js:"action"`
+	// Time is the time (in seconds since the Unix epoch) at which the
+	// request was made.
+	Time int64 `js:"time"`
+	// Fingerprint is the SHA256 fingerprint of the key.
+	Fingerprint string `js:"fingerprint"`
+	// Name is the name of the configured key, or empty if the key was not
+	// loaded from a configured key.
+	Name string `js:"name"`
+	// Origin identifies the client that requested the signature (e.g.,
+	// 'chrome-untrusted://terminal'), or empty if not known.
+	Origin string `js:"origin"`
+	// Err, if non-empty, explains why the request failed (e.g., the user
+	// declined to confirm use of the key).
+	Err string `js:"err"`
+}
+
+const (
+	// AuditActionExportPrivate is the AuditEntry.Action recorded when the
+	// decrypted private key is exported.
+	AuditActionExportPrivate = "export-private"
+)
+
+// auditLog is the object stored in local storage holding the audit entries,
+// oldest first.
+type auditLog struct {
+	Entries []*AuditEntry `js:"entries"`
+}
+
+var (
+	// auditPrefixes are the prefixes for the audit log. Entries are
+	// recorded on every signature, which would quickly exhaust the write
+	// quota for synced storage, so it is kept in local storage.
+	auditPrefixes = []string{"audit"}
+)
+
+const (
+	// auditLogKey is the key at which the audit log is stored.
+	auditLogKey = "log"
+	// maxAuditEntries is the maximum number of entries retained in the
+	// audit log. The oldest entries are evicted once it is reached.
+	maxAuditEntries = 200
+)
+
+// readAuditLog returns the stored audit log, along with the version of the
+// stored item (see storage.Version).
+func (m *DefaultManager) readAuditLog(ctx jsutil.AsyncContext) (*auditLog, string, error) {
+	data, err := m.audit.Get(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	var log auditLog
+	val, present := data[auditLogKey]
+	if !present {
+		return &log, "", nil
+	}
+	if err := vert.ValueOf(val).AssignTo(&log); err != nil {
+		return nil, "", fmt.Errorf("failed to parse audit log: %w", err)
+	}
+	return &log, storage.Version(val), nil
+}
+
+// AppendAuditLog implements Manager.AppendAuditLog.
+func (m *DefaultManager) AppendAuditLog(ctx jsutil.AsyncContext, entry *AuditEntry) error {
+	var err error
+	for i := 0; i < maxUpdateAttempts; i++ {
+		var log *auditLog
+		var version string
+		log, version, err = m.readAuditLog(ctx)
+		if err != nil {
+			return err
+		}
+
+		log.Entries = append(log.Entries, entry)
+		if n := len(log.Entries); n > maxAuditEntries {
+			log.Entries = log.Entries[n-maxAuditEntries:]
+		}
+		data := map[string]js.Value{
+			auditLogKey: vert.ValueOf(log).JSValue(),
+		}
+		err = storage.SetIfUnchanged(ctx, m.audit, data, map[string]string{auditLogKey: version})
+		if !errors.Is(err, storage.ErrConflict) {
+			break
+		}
+		jsutil.LogDebug("DefaultManager.AppendAuditLog: retrying after conflict: %v", err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+// AuditLog implements Manager.AuditLog.
+func (m *DefaultManager) AuditLog(ctx jsutil.AsyncContext) ([]*AuditEntry, error) {
+	log, _, err := m.readAuditLog(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Entries are stored oldest first.
+	result := make([]*AuditEntry, 0, len(log.Entries))
+	for i := len(log.Entries) - 1; i >= 0; i-- {
+		result = append(result, log.Entries[i])
+	}
+	return result, nil
+}
+
+// ClearAuditLog implements Manager.ClearAuditLog.
+func (m *DefaultManager) ClearAuditLog(ctx jsutil.AsyncContext) error {
+	if err := m.audit.Delete(ctx, []string{auditLogKey}); err != nil {
+		return fmt.Errorf("failed to clear audit log: %w", err)
+	}
+	return nil
+}
+
+// SignRequestFunc is invoked after the agent is asked to sign data. err is
+// the error returned by the agent, if any.
+//
+// SignRequestFunc is invoked on the goroutine serving the agent request, and
+// must not block.
+type SignRequestFunc func(key *LoadedKey, origin string, err error)
+
+// AuditAgent wraps an agent serving a single client, and reports each
+// request to sign data along with the client's origin. Requests are refused
+// for keys the client is not allowed to use.
+type AuditAgent struct {
+	agent.ExtendedAgent
+
+	origin string
+	check  OriginCheckFunc
+	onSign SignRequestFunc
+}
+
+// NewAuditAgent returns an AuditAgent wrapping the supplied agent. origin
+// identifies the client served by the agent. If check is non-nil, it is
+// consulted before each request to sign data.
+func NewAuditAgent(agt agent.ExtendedAgent, origin string, check OriginCheckFunc, onSign SignRequestFunc) *AuditAgent {
+	return &AuditAgent{
+		ExtendedAgent: agt,
+		origin:        origin,
+		check:         check,
+		onSign:        onSign,
+	}
+}
+
+// Sign implements agent.Agent.Sign.
+func (a *AuditAgent) Sign(key ssh.PublicKey, data []byte) (*ssh.Signature, error) {
+	var sig *ssh.Signature
+	err := a.checkOrigin(key)
+	if err == nil {
+		sig, err = a.ExtendedAgent.Sign(key, data)
+	}
+	a.onSign(lookupLoaded(a.ExtendedAgent, key), a.origin, err)
+	return sig, err
+}
+
+// SignWithFlags implements agent.ExtendedAgent.SignWithFlags.
+func (a *AuditAgent) SignWithFlags(key ssh.PublicKey, data []byte, flags agent.SignatureFlags) (*ssh.Signature, error) {
+	var sig *ssh.Signature
+	err := a.checkOrigin(key)
+	if err == nil {
+		sig, err = a.ExtendedAgent.SignWithFlags(key, data, flags)
+	}
+	a.onSign(lookupLoaded(a.ExtendedAgent, key), a.origin, err)
+	return sig, err
+}
+
+// RecordSignRequest appends an entry for a request to sign data to the audit
+// log. It is intended to be invoked from a SignRequestFunc.
+func (s *Server) RecordSignRequest(ctx jsutil.AsyncContext, key *LoadedKey, origin string, signErr error) {
+	entry := &AuditEntry{
+		Time:   time.Now().Unix(),
+		Origin: origin,
+		Err:    makeErrStr(signErr),
+	}
+	if pub, err := ssh.ParsePublicKey(key.Blob()); err == nil {
+		entry.Fingerprint = ssh.FingerprintSHA256(pub)
+	}
+	if id := key.ID(); id != InvalidID {
+		if configured, err := s.mgr.Configured(ctx); err == nil {
+			for _, k := range configured {
+				if ID(k.ID) == id {
+					entry.Name = k.Name
+					break
+				}
+			}
+		}
+	}
+
+	if err := s.mgr.AppendAuditLog(ctx, entry); err != nil {
+		jsutil.LogError("Server.RecordSignRequest: failed to record sign request: %v", err)
+	}
+}
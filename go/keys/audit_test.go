@@ -0,0 +1,238 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestAuditLogEviction(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		appended    int
+		wantTimes   []int64
+	}{
+		{
+			description: "empty log",
+			appended:    0,
+		},
+		{
+			description: "retain all entries below limit",
+			appended:    3,
+			wantTimes:   []int64{3, 2, 1},
+		},
+		{
+			description: "retain all entries at limit",
+			appended:    maxAuditEntries,
+			wantTimes:   auditTimes(maxAuditEntries, 1),
+		},
+		{
+			description: "evict oldest entries beyond limit",
+			appended:    maxAuditEntries + 5,
+			wantTimes:   auditTimes(maxAuditEntries+5, 6),
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				syncStorage := storage.NewRaw(st.NewMemArea())
+				sessionStorage := storage.NewRaw(st.NewMemArea())
+				mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, nil)
+				if err != nil {
+					t.Fatalf("failed to initialize manager: %v", err)
+				}
+
+				for i := 1; i <= tc.appended; i++ {
+					if err := mgr.AppendAuditLog(ctx, &AuditEntry{Time: int64(i)}); err != nil {
+						t.Fatalf("failed to append entry: %v", err)
+					}
+				}
+
+				entries, err := mgr.AuditLog(ctx)
+				if err != nil {
+					t.Fatalf("failed to read audit log: %v", err)
+				}
+				var gotTimes []int64
+				for _, e := range entries {
+					gotTimes = append(gotTimes, e.Time)
+				}
+				if diff := cmp.Diff(gotTimes, tc.wantTimes); diff != "" {
+					t.Errorf("incorrect entries; -got +want: %s", diff)
+				}
+			})
+		})
+	}
+}
+
+// auditTimes returns the times from newest down to oldest, inclusive.
+func auditTimes(newest, oldest int64) []int64 {
+	var times []int64
+	for t := newest; t >= oldest; t-- {
+		times = append(times, t)
+	}
+	return times
+}
+
+func TestClearAuditLog(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		syncStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, nil)
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+
+		if err := mgr.AppendAuditLog(ctx, &AuditEntry{Time: 1}); err != nil {
+			t.Fatalf("failed to append entry: %v", err)
+		}
+		if err := mgr.ClearAuditLog(ctx); err != nil {
+			t.Errorf("failed to clear audit log: %v", err)
+		}
+
+		entries, err := mgr.AuditLog(ctx)
+		if err != nil {
+			t.Fatalf("failed to read audit log: %v", err)
+		}
+		if diff := cmp.Diff(entries, []*AuditEntry{}); diff != "" {
+			t.Errorf("incorrect entries; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestAuditAgent(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		type request struct {
+			Comment string
+			Origin  string
+			Failed  bool
+		}
+		var requests []request
+		agt := NewAuditAgent(agent.NewKeyring().(agent.ExtendedAgent), "some-origin", nil, func(key *LoadedKey, origin string, err error) {
+			requests = append(requests, request{Comment: key.Comment, Origin: origin, Failed: err != nil})
+		})
+
+		syncStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		mgr, err := newTestManager(ctx, agt, syncStorage, sessionStorage, []*initialKey{
+			{
+				Name:          "some-key",
+				PEMPrivateKey: testdata.WithoutPassphrase.Private,
+				Load:          true,
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+
+		loaded, err := agt.List()
+		if err != nil {
+			t.Fatalf("failed to list keys: %v", err)
+		}
+		if _, err := agt.Sign(loaded[0], []byte("some-data")); err != nil {
+			t.Errorf("failed to sign: %v", err)
+		}
+		if _, err := agt.SignWithFlags(loaded[0], []byte("some-data"), 0); err != nil {
+			t.Errorf("failed to sign: %v", err)
+		}
+
+		// Failed requests are also reported.
+		if err := mgr.UnloadAll(ctx); err != nil {
+			t.Fatalf("failed to unload keys: %v", err)
+		}
+		if _, err := agt.Sign(loaded[0], []byte("some-data")); err == nil {
+			t.Errorf("signing with unloaded key unexpectedly succeeded")
+		}
+
+		want := []request{
+			{Comment: loaded[0].Comment, Origin: "some-origin"},
+			{Comment: loaded[0].Comment, Origin: "some-origin"},
+			{Origin: "some-origin", Failed: true},
+		}
+		if diff := cmp.Diff(requests, want); diff != "" {
+			t.Errorf("incorrect requests; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestRecordSignRequest(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		syncStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, []*initialKey{
+			{
+				Name:          "some-key",
+				PEMPrivateKey: testdata.WithoutPassphrase.Private,
+				Load:          true,
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+		srv := NewServer(mgr)
+
+		loaded, err := mgr.Loaded(ctx)
+		if err != nil {
+			t.Fatalf("failed to get loaded keys: %v", err)
+		}
+		pub, err := ssh.ParsePublicKey(loaded[0].Blob())
+		if err != nil {
+			t.Fatalf("failed to parse public key: %v", err)
+		}
+
+		srv.RecordSignRequest(ctx, loaded[0], "some-origin", nil)
+		srv.RecordSignRequest(ctx, loaded[0], "other-origin", errors.New("denied"))
+
+		entries, err := mgr.AuditLog(ctx)
+		if err != nil {
+			t.Fatalf("failed to read audit log: %v", err)
+		}
+		for _, e := range entries {
+			if e.Time == 0 {
+				t.Errorf("entry missing time: %+v", e)
+			}
+			e.Time = 0
+		}
+		fingerprint := ssh.FingerprintSHA256(pub)
+		want := []*AuditEntry{
+			{Fingerprint: fingerprint, Name: "some-key", Origin: "other-origin", Err: "denied"},
+			{Fingerprint: fingerprint, Name: "some-key", Origin: "some-origin"},
+		}
+		if diff := cmp.Diff(entries, want); diff != "" {
+			t.Errorf("incorrect entries; -got +want: %s", diff)
+		}
+	})
+}
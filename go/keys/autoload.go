@@ -0,0 +1,119 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"fmt"
+	"slices"
+	"syscall/js"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	"github.com/google/chrome-ssh-agent/go/storage"
+)
+
+var (
+	// autoLoadPrefixes are the prefixes for the marker recording that
+	// keys were automatically loaded for the current session. Session
+	// storage is cleared when the browser exits, so keys are loaded once
+	// per browser start rather than every time the background worker
+	// starts.
+	autoLoadPrefixes = []string{"autoLoad"}
+)
+
+const (
+	// autoLoadDoneKey is the key under which the marker is stored.
+	autoLoadDoneKey = "done"
+)
+
+// SetAutoLoad implements Manager.SetAutoLoad.
+func (m *DefaultManager) SetAutoLoad(ctx jsutil.AsyncContext, id ID, autoLoad bool) error {
+	found := false
+	err := m.updateStoredKeys(ctx, func(sk *storedKey) bool {
+		if ID(sk.ID) != id {
+			return false
+		}
+		found = true
+		if sk.AutoLoad == autoLoad {
+			return false
+		}
+		sk.AutoLoad = autoLoad
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update key: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("%w: failed to find key with ID %s", errKeyNotFound, id)
+	}
+	return nil
+}
+
+// LoadAutoLoad loads all configured keys marked for automatic loading into
+// the agent. It is intended to be invoked when the background worker
+// starts, and has no effect if keys were already automatically loaded for
+// the current browser session. Encrypted keys are skipped, since no
+// passphrase is available. Failure to load an individual key is logged, and
+// does not prevent loading the remaining keys.
+func (m *DefaultManager) LoadAutoLoad(ctx jsutil.AsyncContext) error {
+	marker := storage.NewView(autoLoadPrefixes, m.sessionStorage)
+	data, err := marker.Get(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read auto-load state: %w", err)
+	}
+	if _, ok := data[autoLoadDoneKey]; ok {
+		jsutil.LogDebug("DefaultManager.LoadAutoLoad: keys already loaded for session")
+		return nil
+	}
+	if err := marker.Set(ctx, map[string]js.Value{autoLoadDoneKey: js.ValueOf(true)}); err != nil {
+		return fmt.Errorf("failed to write auto-load state: %w", err)
+	}
+
+	stored, err := m.storedKeys.ReadAll(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read keys: %w", err)
+	}
+	loaded, err := m.Loaded(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to enumerate loaded keys: %w", err)
+	}
+	loadedIDs := map[ID]bool{}
+	for _, l := range loaded {
+		loadedIDs[l.ID()] = true
+	}
+
+	// Keys with a higher priority are loaded first.
+	slices.SortStableFunc(stored, byPriority)
+	for _, k := range stored {
+		id := ID(k.ID)
+		switch {
+		case !k.AutoLoad, loadedIDs[id]:
+			continue
+		case k.Disabled:
+			jsutil.Log("Skipping automatic load of disabled key ID %s", id)
+			continue
+		}
+		if enc, _ := k.encryptionState(); enc != encryptionNone {
+			jsutil.Log("Skipping automatic load of key ID %s: a passphrase is required", id)
+			continue
+		}
+		jsutil.LogDebug("DefaultManager.LoadAutoLoad: loading key ID %s", id)
+		if err := m.Load(ctx, id, "", LoadOptions{}); err != nil {
+			jsutil.LogError("failed to automatically load key ID %s: %v; skipping", id, err)
+		}
+	}
+	return nil
+}
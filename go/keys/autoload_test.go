@@ -0,0 +1,161 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestSetAutoLoad(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		mgr, err := newTestManager(ctx, agent.NewKeyring(), storage.NewRaw(st.NewMemArea()), storage.NewRaw(st.NewMemArea()), []*initialKey{
+			{
+				Name:          "some-key",
+				PEMPrivateKey: testdata.WithoutPassphrase.Private,
+			},
+			{
+				Name:          "other-key",
+				PEMPrivateKey: testdata.ED25519WithoutPassphrase.Private,
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+		id, err := findKey(ctx, mgr, InvalidID, "some-key")
+		if err != nil {
+			t.Fatalf("failed to find key: %v", err)
+		}
+
+		if err := mgr.SetAutoLoad(ctx, id, true); err != nil {
+			t.Errorf("failed to set auto-load: %v", err)
+		}
+		err = mgr.SetAutoLoad(ctx, ID("bogus-id"), true)
+		if diff := cmp.Diff(err, errKeyNotFound, cmpopts.EquateErrors()); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+
+		configured, err := mgr.Configured(ctx)
+		if err != nil {
+			t.Fatalf("failed to get configured keys: %v", err)
+		}
+		got := map[string]bool{}
+		for _, k := range configured {
+			got[k.Name] = k.AutoLoad
+		}
+		want := map[string]bool{
+			"some-key":  true,
+			"other-key": false,
+		}
+		if diff := cmp.Diff(got, want); diff != "" {
+			t.Errorf("incorrect auto-load keys; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestLoadAutoLoad(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		syncStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, []*initialKey{
+			{
+				Name:          "bad-key",
+				PEMPrivateKey: testdata.WithoutPassphrase.Private[:len(testdata.WithoutPassphrase.Private)/2],
+				AddOptions:    AddOptions{AutoLoad: true},
+			},
+			{
+				Name:          "auto-key",
+				PEMPrivateKey: testdata.WithoutPassphrase.Private,
+				AddOptions:    AddOptions{AutoLoad: true},
+			},
+			{
+				Name:          "encrypted-key",
+				PEMPrivateKey: testdata.WithPassphrase.Private,
+				AddOptions:    AddOptions{AutoLoad: true},
+			},
+			{
+				Name:          "disabled-key",
+				PEMPrivateKey: testdata.ED25519WithoutPassphrase.Private,
+				AddOptions:    AddOptions{AutoLoad: true, Disabled: true},
+			},
+			{
+				Name:          "manual-key",
+				PEMPrivateKey: testdata.ECDSAWithoutPassphrase.Private,
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+		autoID, err := findKey(ctx, mgr, InvalidID, "auto-key")
+		if err != nil {
+			t.Fatalf("failed to find key: %v", err)
+		}
+
+		// Only the unencrypted, enabled key is loaded; the key that fails
+		// to load does not prevent it.
+		if err := mgr.LoadAutoLoad(ctx); err != nil {
+			t.Fatalf("failed to auto-load keys: %v", err)
+		}
+		loaded, err := mgr.Loaded(ctx)
+		if err != nil {
+			t.Fatalf("failed to get loaded keys: %v", err)
+		}
+		if diff := cmp.Diff(loadedKeyIDs(loaded), []ID{autoID}); diff != "" {
+			t.Errorf("incorrect loaded keys; -got +want: %s", diff)
+		}
+
+		// Keys are loaded only once per session; a key unloaded by the
+		// user stays unloaded when the background worker restarts.
+		if err := mgr.Unload(ctx, autoID); err != nil {
+			t.Fatalf("failed to unload key: %v", err)
+		}
+		mgr = NewManager(agent.NewKeyring(), syncStorage, storage.NewRaw(st.NewMemArea()), sessionStorage)
+		if err := mgr.LoadAutoLoad(ctx); err != nil {
+			t.Fatalf("failed to auto-load keys: %v", err)
+		}
+		loaded, err = mgr.Loaded(ctx)
+		if err != nil {
+			t.Fatalf("failed to get loaded keys: %v", err)
+		}
+		if diff := cmp.Diff(loadedKeyIDs(loaded), []ID{}, cmpopts.EquateEmpty()); diff != "" {
+			t.Errorf("incorrect loaded keys after restart; -got +want: %s", diff)
+		}
+
+		// A new session loads the key again.
+		mgr = NewManager(agent.NewKeyring(), syncStorage, storage.NewRaw(st.NewMemArea()), storage.NewRaw(st.NewMemArea()))
+		if err := mgr.LoadAutoLoad(ctx); err != nil {
+			t.Fatalf("failed to auto-load keys: %v", err)
+		}
+		loaded, err = mgr.Loaded(ctx)
+		if err != nil {
+			t.Fatalf("failed to get loaded keys: %v", err)
+		}
+		if diff := cmp.Diff(loadedKeyIDs(loaded), []ID{autoID}); diff != "" {
+			t.Errorf("incorrect loaded keys for new session; -got +want: %s", diff)
+		}
+	})
+}
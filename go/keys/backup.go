@@ -0,0 +1,202 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+)
+
+const (
+	// backupVersion is the version of the backup format produced by
+	// Export. It must be incremented for any incompatible change to the
+	// format.
+	backupVersion = 1
+)
+
+// backup is the document produced by Export.
+type backup struct {
+	// Version is the version of the backup format.
+	Version int `json:"version"`
+	// Keys are the configured keys.
+	Keys []*backupKey `json:"keys"`
+}
+
+// backupKey is a configured key included in a backup.
+type backupKey struct {
+	Name          string `json:"name"`
+	PEMPrivateKey string `json:"pemPrivateKey"`
+	// Encrypted indicates if the private key is encrypted. Unencrypted
+	// private keys are readable by anyone with access to the backup.
+	Encrypted        bool `json:"encrypted"`
+	ConfirmBeforeUse bool `json:"confirmBeforeUse"`
+	// Certificate is omitted for keys without a certificate.
+	Certificate string `json:"certificate,omitempty"`
+	// Disabled is omitted for keys that are enabled.
+	Disabled bool `json:"disabled,omitempty"`
+	// AutoLoad is omitted for keys that are not loaded automatically.
+	AutoLoad bool `json:"autoLoad,omitempty"`
+	// AllowedOrigins is omitted for keys that any client may use.
+	AllowedOrigins []string `json:"allowedOrigins,omitempty"`
+	// Tags is omitted for keys that are not grouped.
+	Tags []string `json:"tags,omitempty"`
+	// Note is omitted for keys without a note.
+	Note string `json:"note,omitempty"`
+}
+
+// Export implements Manager.Export.
+func (m *DefaultManager) Export(ctx jsutil.AsyncContext) ([]byte, error) {
+	stored, err := m.storedKeys.ReadAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keys: %w", err)
+	}
+
+	b := backup{
+		Version: backupVersion,
+		Keys:    []*backupKey{},
+	}
+	for _, k := range stored {
+		if k.publicOnly() != nil {
+			// There is no private key to back up; the key can be
+			// adopted again once loaded.
+			continue
+		}
+		b.Keys = append(b.Keys, &backupKey{
+			Name:             k.Name,
+			PEMPrivateKey:    k.PEMPrivateKey,
+			Encrypted:        k.Encrypted(),
+			ConfirmBeforeUse: k.ConfirmBeforeUse,
+			Certificate:      k.Certificate,
+			Disabled:         k.Disabled,
+			AutoLoad:         k.AutoLoad,
+			AllowedOrigins:   k.AllowedOrigins,
+			Tags:             k.Tags,
+			Note:             k.Note,
+		})
+	}
+	// Sort to ensure consistent output.
+	sort.SliceStable(b.Keys, func(i, j int) bool { return b.Keys[i].Name < b.Keys[j].Name })
+
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize keys: %w", err)
+	}
+	return data, nil
+}
+
+// ImportResult summarizes the keys processed by Import.
+type ImportResult struct {
+	// Imported is the number of keys that were configured.
+	Imported int `js:"imported"`
+	// Skipped is the number of keys that were already configured.
+	Skipped int `js:"skipped"`
+}
+
+var (
+	errInvalidBackup      = errors.New("invalid backup")
+	errIncompatibleBackup = errors.New("incompatible backup")
+)
+
+// parseBackup parses and validates a document produced by Export.
+func parseBackup(data []byte) (*backup, error) {
+	var b backup
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse: %w", errInvalidBackup, err)
+	}
+	if b.Version != backupVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d; expected version %d", errIncompatibleBackup, b.Version, backupVersion)
+	}
+	for i, k := range b.Keys {
+		if k == nil {
+			return nil, fmt.Errorf("%w: key %d is empty", errInvalidBackup, i)
+		}
+		if k.Name == "" {
+			return nil, fmt.Errorf("%w: key %d has no name", errInvalidBackup, i)
+		}
+		if k.PEMPrivateKey == "" {
+			return nil, fmt.Errorf("%w: key %s has no private key", errInvalidBackup, k.Name)
+		}
+	}
+	return &b, nil
+}
+
+// Import implements Manager.Import.
+func (m *DefaultManager) Import(ctx jsutil.AsyncContext, data []byte) (*ImportResult, error) {
+	// Validate the full backup before configuring any keys, so that a
+	// malformed backup is not partially imported.
+	b, err := parseBackup(data)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := m.storedKeys.ReadAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keys: %w", err)
+	}
+	// Public keys cannot be derived for some encrypted keys, so also
+	// treat identical private keys as duplicates.
+	existingPEM := make(map[string]bool)
+	for _, k := range existing {
+		existingPEM[k.PEMPrivateKey] = true
+	}
+
+	result := &ImportResult{}
+	var newKeys []*NewKey
+	for _, k := range b.Keys {
+		if existingPEM[k.PEMPrivateKey] {
+			result.Skipped++
+			continue
+		}
+		existingPEM[k.PEMPrivateKey] = true
+		newKeys = append(newKeys, &NewKey{
+			Name:          k.Name,
+			PEMPrivateKey: k.PEMPrivateKey,
+			Options: AddOptions{
+				ConfirmBeforeUse: k.ConfirmBeforeUse,
+				Certificate:      k.Certificate,
+				Disabled:         k.Disabled,
+				AutoLoad:         k.AutoLoad,
+				AllowedOrigins:   k.AllowedOrigins,
+				Tags:             k.Tags,
+				Note:             k.Note,
+			},
+		})
+	}
+
+	// Write all keys at once, so that a failure does not leave the backup
+	// partially imported.
+	errs, err := m.AddMany(ctx, newKeys)
+	if err != nil {
+		return result, fmt.Errorf("failed to import keys: %w", err)
+	}
+	var failed error
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			result.Imported++
+		case errors.Is(err, ErrDuplicateKey):
+			result.Skipped++
+		case failed == nil:
+			failed = fmt.Errorf("failed to import key %s: %w", newKeys[i].Name, err)
+		}
+	}
+	return result, failed
+}
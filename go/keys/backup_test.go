@@ -0,0 +1,348 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestExport(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		initial     []*initialKey
+		want        *backup
+	}{
+		{
+			description: "no keys",
+			want: &backup{
+				Version: backupVersion,
+				Keys:    []*backupKey{},
+			},
+		},
+		{
+			description: "multiple keys",
+			initial: []*initialKey{
+				{
+					Name:          "unencrypted-key",
+					PEMPrivateKey: testdata.WithoutPassphrase.Private,
+				},
+				{
+					Name:          "encrypted-key",
+					PEMPrivateKey: testdata.WithPassphrase.Private,
+					AddOptions:    AddOptions{ConfirmBeforeUse: true},
+				},
+			},
+			want: &backup{
+				Version: backupVersion,
+				Keys: []*backupKey{
+					{
+						Name:             "encrypted-key",
+						PEMPrivateKey:    testdata.WithPassphrase.Private,
+						Encrypted:        true,
+						ConfirmBeforeUse: true,
+					},
+					{
+						Name:          "unencrypted-key",
+						PEMPrivateKey: testdata.WithoutPassphrase.Private,
+					},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				syncStorage := storage.NewRaw(st.NewMemArea())
+				sessionStorage := storage.NewRaw(st.NewMemArea())
+				mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, tc.initial)
+				if err != nil {
+					t.Fatalf("failed to initialize manager: %v", err)
+				}
+
+				data, err := mgr.Export(ctx)
+				if err != nil {
+					t.Fatalf("failed to export: %v", err)
+				}
+
+				var got backup
+				if err := json.Unmarshal(data, &got); err != nil {
+					t.Fatalf("failed to parse exported data: %v", err)
+				}
+				if diff := cmp.Diff(&got, tc.want); diff != "" {
+					t.Errorf("incorrect exported data; -got +want: %s", diff)
+				}
+			})
+		})
+	}
+}
+
+func mustMarshalBackup(b *backup) []byte {
+	data, err := json.Marshal(b)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// configuredWithoutIDs returns the configured keys, sorted by name, with IDs
+// cleared since they are randomly generated.
+func configuredWithoutIDs(keys []*ConfiguredKey) []*ConfiguredKey {
+	var result []*ConfiguredKey
+	for _, k := range keys {
+		nk := *k
+		nk.ID = ""
+		result = append(result, &nk)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+func TestImport(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description    string
+		initial        []*initialKey
+		data           []byte
+		wantResult     *ImportResult
+		wantConfigured []*ConfiguredKey
+		wantErr        error
+	}{
+		{
+			description: "import keys",
+			data: mustMarshalBackup(&backup{
+				Version: backupVersion,
+				Keys: []*backupKey{
+					{
+						Name:             "encrypted-key",
+						PEMPrivateKey:    testdata.WithPassphrase.Private,
+						Encrypted:        true,
+						ConfirmBeforeUse: true,
+					},
+					{
+						Name:          "unencrypted-key",
+						PEMPrivateKey: testdata.WithoutPassphrase.Private,
+					},
+				},
+			}),
+			wantResult: &ImportResult{Imported: 2},
+			wantConfigured: []*ConfiguredKey{
+				{
+					Name:                  "encrypted-key",
+					Encrypted:             true,
+					ConfirmBeforeUse:      true,
+					RSASignatureAlgorithm: ClientRSASignatureAlgorithm,
+				},
+				{
+					Name:                  "unencrypted-key",
+					RSASignatureAlgorithm: ClientRSASignatureAlgorithm,
+					Algorithm:             "RSA",
+					Bits:                  2048,
+					Fingerprint:           fingerprint(t, testdata.WithoutPassphrase.Blob),
+					FingerprintMD5:        "MD5:48:49:9b:42:21:7c:a0:e8:af:2f:39:8a:00:17:f6:87",
+				},
+			},
+		},
+		{
+			description: "skip duplicate keys",
+			initial: []*initialKey{
+				{
+					Name:          "existing-key",
+					PEMPrivateKey: testdata.WithoutPassphrase.Private,
+				},
+				{
+					Name:          "existing-encrypted-key",
+					PEMPrivateKey: testdata.WithPassphrase.Private,
+				},
+			},
+			data: mustMarshalBackup(&backup{
+				Version: backupVersion,
+				Keys: []*backupKey{
+					{
+						Name:          "renamed-key",
+						PEMPrivateKey: testdata.WithoutPassphrase.Private,
+					},
+					{
+						Name:          "renamed-encrypted-key",
+						PEMPrivateKey: testdata.WithPassphrase.Private,
+						Encrypted:     true,
+					},
+					{
+						Name:          "new-key",
+						PEMPrivateKey: testdata.ED25519WithoutPassphrase.Private,
+					},
+				},
+			}),
+			wantResult: &ImportResult{Imported: 1, Skipped: 2},
+			wantConfigured: []*ConfiguredKey{
+				{
+					Name:                  "existing-encrypted-key",
+					Encrypted:             true,
+					RSASignatureAlgorithm: ClientRSASignatureAlgorithm,
+				},
+				{
+					Name:                  "existing-key",
+					RSASignatureAlgorithm: ClientRSASignatureAlgorithm,
+					Algorithm:             "RSA",
+					Bits:                  2048,
+					Fingerprint:           fingerprint(t, testdata.WithoutPassphrase.Blob),
+					FingerprintMD5:        "MD5:48:49:9b:42:21:7c:a0:e8:af:2f:39:8a:00:17:f6:87",
+				},
+				{
+					Name:           "new-key",
+					Comment:        "richard_alimi_gmail_com@workstation",
+					Algorithm:      "Ed25519",
+					Bits:           256,
+					Fingerprint:    fingerprint(t, testdata.ED25519WithoutPassphrase.Blob),
+					FingerprintMD5: "MD5:58:d4:80:81:6c:ab:e2:63:a1:e6:0f:ff:0d:91:e8:29",
+				},
+			},
+		},
+		{
+			description: "reject malformed backup",
+			data:        []byte("not-json"),
+			wantErr:     errInvalidBackup,
+		},
+		{
+			description: "reject incompatible version",
+			data: mustMarshalBackup(&backup{
+				Version: backupVersion + 1,
+			}),
+			wantErr: errIncompatibleBackup,
+		},
+		{
+			description: "reject invalid key without importing others",
+			data: mustMarshalBackup(&backup{
+				Version: backupVersion,
+				Keys: []*backupKey{
+					{
+						Name:          "valid-key",
+						PEMPrivateKey: testdata.WithoutPassphrase.Private,
+					},
+					{
+						Name: "key-without-private-key",
+					},
+				},
+			}),
+			wantErr: errInvalidBackup,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				syncStorage := storage.NewRaw(st.NewMemArea())
+				sessionStorage := storage.NewRaw(st.NewMemArea())
+				mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, tc.initial)
+				if err != nil {
+					t.Fatalf("failed to initialize manager: %v", err)
+				}
+
+				result, err := mgr.Import(ctx, tc.data)
+				if diff := cmp.Diff(err, tc.wantErr, cmpopts.EquateErrors()); diff != "" {
+					t.Errorf("incorrect error; -got +want: %s", diff)
+				}
+				if diff := cmp.Diff(result, tc.wantResult); diff != "" {
+					t.Errorf("incorrect result; -got +want: %s", diff)
+				}
+
+				configured, err := mgr.Configured(ctx)
+				if err != nil {
+					t.Fatalf("failed to get configured keys: %v", err)
+				}
+				if diff := cmp.Diff(configuredWithoutIDs(configured), tc.wantConfigured); diff != "" {
+					t.Errorf("incorrect configured keys; -got +want: %s", diff)
+				}
+			})
+		})
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		src, err := newTestManager(ctx, agent.NewKeyring(), storage.NewRaw(st.NewMemArea()), storage.NewRaw(st.NewMemArea()), []*initialKey{
+			{
+				Name:          "encrypted-key",
+				PEMPrivateKey: testdata.OpenSSHFormat.Private,
+				AddOptions:    AddOptions{ConfirmBeforeUse: true},
+			},
+			{
+				Name:          "auto-load-key",
+				PEMPrivateKey: testdata.WithoutPassphrase.Private,
+				AddOptions:    AddOptions{AutoLoad: true, Note: "prod bastion, rotate quarterly"},
+			},
+			{
+				Name:          "unencrypted-key",
+				PEMPrivateKey: testdata.ED25519WithoutPassphrase.Private,
+				AddOptions:    AddOptions{Disabled: true},
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+		dst := NewManager(agent.NewKeyring(), storage.NewRaw(st.NewMemArea()), storage.NewRaw(st.NewMemArea()), storage.NewRaw(st.NewMemArea()))
+
+		data, err := src.Export(ctx)
+		if err != nil {
+			t.Fatalf("failed to export: %v", err)
+		}
+		if _, err := dst.Import(ctx, data); err != nil {
+			t.Fatalf("failed to import: %v", err)
+		}
+
+		want, err := src.Configured(ctx)
+		if err != nil {
+			t.Fatalf("failed to get configured keys: %v", err)
+		}
+		got, err := dst.Configured(ctx)
+		if err != nil {
+			t.Fatalf("failed to get configured keys: %v", err)
+		}
+		if diff := cmp.Diff(configuredWithoutIDs(got), configuredWithoutIDs(want)); diff != "" {
+			t.Errorf("incorrect configured keys; -got +want: %s", diff)
+		}
+
+		// Loading an imported key uses the original private key.
+		id, err := findKey(ctx, dst, InvalidID, "encrypted-key")
+		if err != nil {
+			t.Fatalf("failed to find key: %v", err)
+		}
+		if err := dst.Load(ctx, id, testdata.OpenSSHFormat.Passphrase, LoadOptions{}); err != nil {
+			t.Errorf("failed to load imported key: %v", err)
+		}
+	})
+}
@@ -0,0 +1,56 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"strconv"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+)
+
+// BadgeFunc is invoked to display a short summary of the agent's state
+// (e.g., on the extension's toolbar icon).
+type BadgeFunc func(text string)
+
+// SetBadge sets the function used to display the number of keys loaded in
+// the agent. It is invoked whenever the loaded keys may have changed.
+func (s *Server) SetBadge(badge BadgeFunc) {
+	s.badge = badge
+}
+
+// UpdateBadge displays the number of keys currently loaded in the agent. No
+// badge is displayed if no keys are loaded.
+func (s *Server) UpdateBadge(ctx jsutil.AsyncContext) {
+	if s.badge == nil {
+		return
+	}
+
+	loaded, err := s.mgr.Loaded(ctx)
+	if err != nil {
+		jsutil.LogError("Server.UpdateBadge: failed to enumerate loaded keys: %v", err)
+		return
+	}
+	s.badge(badgeText(len(loaded)))
+}
+
+// badgeText returns the badge text for the number of loaded keys.
+func badgeText(loaded int) string {
+	if loaded == 0 {
+		return ""
+	}
+	return strconv.Itoa(loaded)
+}
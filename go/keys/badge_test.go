@@ -0,0 +1,89 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	mfakes "github.com/google/chrome-ssh-agent/go/message/fakes"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestBadge(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		syncStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, []*initialKey{
+			{
+				Name:          "good-key",
+				PEMPrivateKey: testdata.WithoutPassphrase.Private,
+			},
+			{
+				Name:          "other-key",
+				PEMPrivateKey: testdata.ED25519WithoutPassphrase.Private,
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+		goodID, err := findKey(ctx, mgr, InvalidID, "good-key")
+		if err != nil {
+			t.Fatalf("failed to find key: %v", err)
+		}
+		otherID, err := findKey(ctx, mgr, InvalidID, "other-key")
+		if err != nil {
+			t.Fatalf("failed to find key: %v", err)
+		}
+
+		hub := mfakes.NewHub()
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		var got []string
+		srv.SetBadge(func(text string) {
+			got = append(got, text)
+		})
+		hub.AddReceiver(srv)
+
+		srv.UpdateBadge(ctx)
+		if err := cli.Load(ctx, goodID, "", LoadOptions{}); err != nil {
+			t.Errorf("failed to load key: %v", err)
+		}
+		if err := cli.Load(ctx, otherID, "", LoadOptions{}); err != nil {
+			t.Errorf("failed to load key: %v", err)
+		}
+		if err := cli.Unload(ctx, goodID); err != nil {
+			t.Errorf("failed to unload key: %v", err)
+		}
+		if err := cli.Remove(ctx, goodID); err != nil {
+			t.Errorf("failed to remove key: %v", err)
+		}
+		if err := cli.UnloadAll(ctx); err != nil {
+			t.Errorf("failed to unload all keys: %v", err)
+		}
+
+		want := []string{"", "1", "2", "1", "1", ""}
+		if diff := cmp.Diff(got, want); diff != "" {
+			t.Errorf("incorrect badge text; -got +want: %s", diff)
+		}
+	})
+}
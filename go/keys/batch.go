@@ -0,0 +1,99 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"fmt"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+)
+
+// NewKey describes a key to be configured by AddMany.
+type NewKey struct {
+	// Name is a human-readable name describing the key. If empty, the
+	// comment embedded in the private key is used instead.
+	Name string `js:"name"`
+	// PEMPrivateKey is the PEM-encoded private key. A private key in
+	// PuTTY's .ppk format is also accepted.
+	PEMPrivateKey string `js:"pemPrivateKey"`
+	// Options specifies any settings to apply to the key.
+	Options AddOptions `js:"options"`
+}
+
+// AddMany implements Manager.AddMany.
+func (m *DefaultManager) AddMany(ctx jsutil.AsyncContext, keys []*NewKey) ([]error, error) {
+	existing, err := m.storedKeys.ReadAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keys: %w", err)
+	}
+
+	errs := make([]error, len(keys))
+	var added []*storedKey
+	for i, k := range keys {
+		sk, err := newStoredKey(k.Name, k.PEMPrivateKey, k.Options)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		// Keys earlier in the batch are also considered when detecting
+		// duplicates.
+		if !k.Options.AllowDuplicate {
+			if err := duplicateOf(existing, sk); err != nil {
+				errs[i] = err
+				continue
+			}
+		}
+		existing = append(existing, sk)
+		added = append(added, sk)
+	}
+
+	if err := m.storedKeys.WriteAll(ctx, added); err != nil {
+		return nil, fmt.Errorf("failed to write keys: %w", err)
+	}
+	return errs, nil
+}
+
+// RemoveMany implements Manager.RemoveMany.
+func (m *DefaultManager) RemoveMany(ctx jsutil.AsyncContext, ids []ID) ([]error, error) {
+	existing, err := m.storedKeys.ReadAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keys: %w", err)
+	}
+	configured := make(map[ID]bool)
+	for _, k := range existing {
+		configured[ID(k.ID)] = true
+	}
+
+	errs := make([]error, len(ids))
+	remove := make(map[ID]bool)
+	for i, id := range ids {
+		if !configured[id] {
+			errs[i] = fmt.Errorf("%w: failed to find key with ID %s", errKeyNotFound, id)
+			continue
+		}
+		remove[id] = true
+		m.passphrases.forget(id)
+	}
+	if len(remove) == 0 {
+		return errs, nil
+	}
+
+	if err := m.storedKeys.Delete(ctx, func(sk *storedKey) bool { return remove[ID(sk.ID)] }); err != nil {
+		return nil, fmt.Errorf("failed to remove keys: %w", err)
+	}
+	return errs, nil
+}
@@ -0,0 +1,248 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestAddMany(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description    string
+		initial        []*initialKey
+		keys           []*NewKey
+		wantErrs       []error
+		wantConfigured []string
+	}{
+		{
+			description: "add no keys",
+			keys:        nil,
+			wantErrs:    []error{},
+		},
+		{
+			description: "add multiple keys",
+			keys: []*NewKey{
+				{Name: "new-key-1", PEMPrivateKey: testdata.WithoutPassphrase.Private},
+				{Name: "new-key-2", PEMPrivateKey: testdata.ED25519WithoutPassphrase.Private},
+			},
+			wantErrs:       []error{nil, nil},
+			wantConfigured: []string{"new-key-1", "new-key-2"},
+		},
+		{
+			description: "reject duplicate of configured key",
+			initial: []*initialKey{
+				{
+					Name:          "new-key-1",
+					PEMPrivateKey: testdata.WithoutPassphrase.Private,
+				},
+			},
+			keys: []*NewKey{
+				{Name: "new-key-2", PEMPrivateKey: testdata.WithoutPassphrase.Private},
+				{Name: "new-key-3", PEMPrivateKey: testdata.ED25519WithoutPassphrase.Private},
+			},
+			wantErrs:       []error{ErrDuplicateKey, nil},
+			wantConfigured: []string{"new-key-1", "new-key-3"},
+		},
+		{
+			description: "reject duplicate within batch",
+			keys: []*NewKey{
+				{Name: "new-key-1", PEMPrivateKey: testdata.WithoutPassphrase.Private},
+				{Name: "new-key-2", PEMPrivateKey: testdata.WithoutPassphrase.Private},
+			},
+			wantErrs:       []error{nil, ErrDuplicateKey},
+			wantConfigured: []string{"new-key-1"},
+		},
+		{
+			description: "allow duplicate with override",
+			keys: []*NewKey{
+				{Name: "new-key-1", PEMPrivateKey: testdata.WithoutPassphrase.Private},
+				{
+					Name:          "new-key-2",
+					PEMPrivateKey: testdata.WithoutPassphrase.Private,
+					Options:       AddOptions{AllowDuplicate: true},
+				},
+			},
+			wantErrs:       []error{nil, nil},
+			wantConfigured: []string{"new-key-1", "new-key-2"},
+		},
+		{
+			description: "reject invalid key",
+			keys: []*NewKey{
+				{
+					Name:          "new-key-1",
+					PEMPrivateKey: strings.Replace(testdata.PPKv3WithoutPassphrase.Private, "test-comment", "other-comment", 1),
+				},
+				{Name: "new-key-2", PEMPrivateKey: testdata.ED25519WithoutPassphrase.Private},
+			},
+			wantErrs:       []error{errParseFailed, nil},
+			wantConfigured: []string{"new-key-2"},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				syncStorage := storage.NewRaw(st.NewMemArea())
+				sessionStorage := storage.NewRaw(st.NewMemArea())
+				mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, tc.initial)
+				if err != nil {
+					t.Fatalf("failed to initialize manager: %v", err)
+				}
+
+				errs, err := mgr.AddMany(ctx, tc.keys)
+				if err != nil {
+					t.Errorf("failed to add keys: %v", err)
+				}
+				if diff := cmp.Diff(errs, tc.wantErrs, cmpopts.EquateErrors()); diff != "" {
+					t.Errorf("incorrect per-key errors; -got +want: %s", diff)
+				}
+
+				names, err := configuredNames(ctx, mgr)
+				if err != nil {
+					t.Errorf("failed to get configured keys: %v", err)
+				}
+				if diff := cmp.Diff(names, tc.wantConfigured); diff != "" {
+					t.Errorf("incorrect configured keys; -got +want: %s", diff)
+				}
+			})
+		})
+	}
+}
+
+func TestAddManyWriteFailure(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		syncStorage := &fullArea{Area: storage.NewRaw(st.NewMemArea())}
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		mgr := NewManager(agent.NewKeyring(), syncStorage, storage.NewRaw(st.NewMemArea()), sessionStorage)
+
+		// A failed write fails the whole batch; no keys are configured.
+		_, err := mgr.AddMany(ctx, []*NewKey{
+			{Name: "new-key-1", PEMPrivateKey: testdata.WithoutPassphrase.Private},
+			{Name: "new-key-2", PEMPrivateKey: testdata.ED25519WithoutPassphrase.Private},
+		})
+		if diff := cmp.Diff(err, storage.ErrQuotaExceeded, cmpopts.EquateErrors()); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+
+		names, err := configuredNames(ctx, mgr)
+		if err != nil {
+			t.Errorf("failed to get configured keys: %v", err)
+		}
+		if diff := cmp.Diff(names, []string(nil)); diff != "" {
+			t.Errorf("incorrect configured keys; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestRemoveMany(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description    string
+		initial        []*initialKey
+		byName         []string
+		byID           []ID
+		wantErrs       []error
+		wantConfigured []string
+	}{
+		{
+			description: "remove multiple keys",
+			initial: []*initialKey{
+				{Name: "key-1", PEMPrivateKey: testdata.WithoutPassphrase.Private},
+				{Name: "key-2", PEMPrivateKey: testdata.ED25519WithoutPassphrase.Private},
+				{Name: "key-3", PEMPrivateKey: testdata.ECDSAWithoutPassphrase.Private},
+			},
+			byName:         []string{"key-1", "key-3"},
+			wantErrs:       []error{nil, nil},
+			wantConfigured: []string{"key-2"},
+		},
+		{
+			description: "report missing keys",
+			initial: []*initialKey{
+				{Name: "key-1", PEMPrivateKey: testdata.WithoutPassphrase.Private},
+				{Name: "key-2", PEMPrivateKey: testdata.ED25519WithoutPassphrase.Private},
+			},
+			byName:         []string{"key-1"},
+			byID:           []ID{ID("bogus-id")},
+			wantErrs:       []error{nil, errKeyNotFound},
+			wantConfigured: []string{"key-2"},
+		},
+		{
+			description:    "only missing keys",
+			byID:           []ID{ID("bogus-id-1"), ID("bogus-id-2")},
+			wantErrs:       []error{errKeyNotFound, errKeyNotFound},
+			wantConfigured: nil,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				syncStorage := storage.NewRaw(st.NewMemArea())
+				sessionStorage := storage.NewRaw(st.NewMemArea())
+				mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, tc.initial)
+				if err != nil {
+					t.Fatalf("failed to initialize manager: %v", err)
+				}
+
+				var ids []ID
+				for _, name := range tc.byName {
+					id, err := findKey(ctx, mgr, InvalidID, name)
+					if err != nil {
+						t.Fatalf("failed to find key: %v", err)
+					}
+					ids = append(ids, id)
+				}
+				ids = append(ids, tc.byID...)
+
+				errs, err := mgr.RemoveMany(ctx, ids)
+				if err != nil {
+					t.Errorf("failed to remove keys: %v", err)
+				}
+				if diff := cmp.Diff(errs, tc.wantErrs, cmpopts.EquateErrors()); diff != "" {
+					t.Errorf("incorrect per-key errors; -got +want: %s", diff)
+				}
+
+				names, err := configuredNames(ctx, mgr)
+				if err != nil {
+					t.Errorf("failed to get configured keys: %v", err)
+				}
+				if diff := cmp.Diff(names, tc.wantConfigured); diff != "" {
+					t.Errorf("incorrect configured keys; -got +want: %s", diff)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,132 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+)
+
+var (
+	errMalformedBlock = errors.New("malformed PEM block")
+)
+
+const (
+	// pemBeginPrefix starts the header line of a PEM block.
+	pemBeginPrefix = "-----BEGIN "
+	// pemEndPrefix starts the footer line of a PEM block.
+	pemEndPrefix = "-----END "
+	// pemBoundarySuffix ends both the header and footer lines of a PEM
+	// block.
+	pemBoundarySuffix = "-----"
+)
+
+// SplitBundle splits text containing several concatenated PEM blocks into
+// the individual blocks. Any text outside of the blocks is discarded.
+//
+// A block that is missing its footer extends up to the start of the next
+// block, so that it is reported as malformed when added rather than
+// swallowing the blocks that follow it.
+func SplitBundle(text string) []string {
+	var blocks []string
+	rest := text
+	for {
+		start := strings.Index(rest, pemBeginPrefix)
+		if start < 0 {
+			return blocks
+		}
+		rest = rest[start:]
+
+		// Find the end of the block: either the end of its footer line,
+		// or the start of the next block if the footer is missing.
+		end := len(rest)
+		if next := strings.Index(rest[len(pemBeginPrefix):], pemBeginPrefix); next >= 0 {
+			end = next + len(pemBeginPrefix)
+		}
+		if footer := strings.Index(rest[:end], pemEndPrefix); footer >= 0 {
+			if tail := strings.Index(rest[footer+len(pemEndPrefix):end], pemBoundarySuffix); tail >= 0 {
+				end = footer + len(pemEndPrefix) + tail + len(pemBoundarySuffix)
+			}
+		}
+
+		blocks = append(blocks, strings.TrimSpace(rest[:end]))
+		rest = rest[end:]
+	}
+}
+
+// IsBundle indicates if text contains more than one PEM block, and should
+// therefore be added as multiple keys.
+func IsBundle(text string) bool {
+	return len(SplitBundle(text)) > 1
+}
+
+// bundleKey returns the key to configure for the i'th PEM block in a bundle.
+// The key is named from the comment embedded in its private key; keys
+// without an embedded comment are named by adding an index suffix to base.
+func bundleKey(base string, i int, block string, opts AddOptions) (*NewKey, error) {
+	if b, rest := pem.Decode([]byte(block)); b == nil || len(strings.TrimSpace(string(rest))) > 0 {
+		return nil, errMalformedBlock
+	}
+
+	k := &NewKey{
+		PEMPrivateKey: block,
+		Options:       opts,
+	}
+	sk := &storedKey{PEMPrivateKey: block}
+	if sk.Comment() == "" {
+		k.Name = fmt.Sprintf("%s %d", base, i+1)
+	}
+	return k, nil
+}
+
+// AddBundle implements Manager.AddBundle.
+func (m *DefaultManager) AddBundle(ctx jsutil.AsyncContext, name string, bundle string, opts AddOptions) ([]error, error) {
+	base := strings.TrimSpace(name)
+	if base == "" {
+		base = placeholderName
+	}
+	// A certificate can correspond to at most one key; certificates must
+	// be added for individual keys.
+	opts.Certificate = ""
+
+	blocks := SplitBundle(bundle)
+	errs := make([]error, len(blocks))
+	var keys []*NewKey
+	var indexes []int
+	for i, block := range blocks {
+		k, err := bundleKey(base, i, block, opts)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		keys = append(keys, k)
+		indexes = append(indexes, i)
+	}
+
+	addErrs, err := m.AddMany(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	for j, err := range addErrs {
+		errs[indexes[j]] = err
+	}
+	return errs, nil
+}
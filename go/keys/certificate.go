@@ -0,0 +1,75 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	errInvalidCertificate = errors.New("invalid certificate")
+)
+
+// parseCertificate parses an OpenSSH certificate in the format used by
+// authorized_keys files (e.g., the contents of id_ed25519-cert.pub). A nil
+// certificate is returned if the supplied string is empty.
+func parseCertificate(certificate string) (*ssh.Certificate, error) {
+	if strings.TrimSpace(certificate) == "" {
+		return nil, nil
+	}
+
+	pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(certificate))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidCertificate, err)
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is a public key, not a certificate", errInvalidCertificate, pub.Type())
+	}
+	return cert, nil
+}
+
+// validateCertificate returns an error if the key's certificate is malformed,
+// or does not certify the key. Whether the certificate certifies the key can
+// only be determined if the public key can be derived without a passphrase.
+func (s *storedKey) validateCertificate() error {
+	cert, err := parseCertificate(s.Certificate)
+	if err != nil || cert == nil {
+		return err
+	}
+
+	if pub := s.PublicKey(); pub != nil && !bytes.Equal(cert.Key.Marshal(), pub.Marshal()) {
+		return fmt.Errorf("%w: certificate is for a different key", errInvalidCertificate)
+	}
+	return nil
+}
+
+// Certificate returns the certificate loaded into the agent, or nil if a
+// plain public key was loaded.
+func (k *LoadedKey) Certificate() *ssh.Certificate {
+	pub, err := ssh.ParsePublicKey(k.Blob())
+	if err != nil {
+		return nil
+	}
+	cert, _ := pub.(*ssh.Certificate)
+	return cert
+}
@@ -0,0 +1,201 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestAddCertificate(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description   string
+		pemPrivateKey string
+		certificate   string
+		wantErr       error
+	}{
+		{
+			description:   "certificate for key",
+			pemPrivateKey: testdata.ED25519WithCertificate.Private,
+			certificate:   testdata.ED25519WithCertificate.Certificate,
+		},
+		{
+			description:   "no certificate",
+			pemPrivateKey: testdata.ED25519WithCertificate.Private,
+		},
+		{
+			description:   "reject certificate for different key",
+			pemPrivateKey: testdata.WithoutPassphrase.Private,
+			certificate:   testdata.ED25519WithCertificate.Certificate,
+			wantErr:       errInvalidCertificate,
+		},
+		{
+			description:   "reject public key",
+			pemPrivateKey: testdata.ED25519WithCertificate.Private,
+			certificate:   "ssh-ed25519 " + testdata.ED25519WithoutPassphrase.Blob,
+			wantErr:       errInvalidCertificate,
+		},
+		{
+			description:   "reject malformed certificate",
+			pemPrivateKey: testdata.ED25519WithCertificate.Private,
+			certificate:   "bogus-certificate",
+			wantErr:       errInvalidCertificate,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				syncStorage := storage.NewRaw(st.NewMemArea())
+				sessionStorage := storage.NewRaw(st.NewMemArea())
+				mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, nil)
+				if err != nil {
+					t.Fatalf("failed to initialize manager: %v", err)
+				}
+
+				err = mgr.Add(ctx, "new-key", tc.pemPrivateKey, AddOptions{Certificate: tc.certificate})
+				if diff := cmp.Diff(err, tc.wantErr, cmpopts.EquateErrors()); diff != "" {
+					t.Errorf("incorrect error; -got +want: %s", diff)
+				}
+			})
+		})
+	}
+}
+
+func TestLoadCertificate(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		// Storage persists across multiple manager instances.
+		syncStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		initial := []*initialKey{
+			{
+				Name:          "cert-key",
+				PEMPrivateKey: testdata.ED25519WithCertificate.Private,
+				AddOptions:    AddOptions{Certificate: testdata.ED25519WithCertificate.Certificate},
+				Load:          true,
+			},
+		}
+
+		agt := NewConfirmAgent(agent.NewKeyring().(agent.ExtendedAgent))
+		mgr, err := newTestManager(ctx, agt, syncStorage, sessionStorage, initial)
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+
+		// The certificate is loaded in place of the plain public key.
+		loaded, err := mgr.Loaded(ctx)
+		if err != nil {
+			t.Fatalf("failed to enumerate loaded keys: %v", err)
+		}
+		if diff := cmp.Diff(loadedKeyBlobs(loaded), []string{testdata.ED25519WithCertificate.Blob}); diff != "" {
+			t.Errorf("incorrect loaded keys; -got +want: %s", diff)
+		}
+		cert := loaded[0].Certificate()
+		if cert == nil {
+			t.Fatalf("loaded key is not a certificate")
+		}
+		if diff := cmp.Diff(cert.ValidPrincipals, []string{"alice", "bob"}); diff != "" {
+			t.Errorf("incorrect principals; -got +want: %s", diff)
+		}
+
+		// The certificate can be used for signing.
+		keys, err := agt.List()
+		if err != nil {
+			t.Fatalf("failed to list keys: %v", err)
+		}
+		if _, err := agt.Sign(keys[0], []byte("some-data")); err != nil {
+			t.Errorf("failed to sign: %v", err)
+		}
+
+		// The certificate is restored along with the key from the session.
+		mgr2, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, nil)
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+		if err := mgr2.LoadFromSession(ctx); err != nil {
+			t.Fatalf("failed to load keys from session: %v", err)
+		}
+		loaded, err = mgr2.Loaded(ctx)
+		if err != nil {
+			t.Fatalf("failed to enumerate loaded keys: %v", err)
+		}
+		if diff := cmp.Diff(loadedKeyBlobs(loaded), []string{testdata.ED25519WithCertificate.Blob}); diff != "" {
+			t.Errorf("incorrect restored keys; -got +want: %s", diff)
+		}
+
+		// The certificate is unloaded along with the key.
+		if err := mgr.Unload(ctx, loaded[0].ID()); err != nil {
+			t.Errorf("failed to unload key: %v", err)
+		}
+		loaded, err = mgr.Loaded(ctx)
+		if err != nil {
+			t.Fatalf("failed to enumerate loaded keys: %v", err)
+		}
+		if len(loaded) != 0 {
+			t.Errorf("incorrect loaded keys after unload; got %d, want 0", len(loaded))
+		}
+	})
+}
+
+func TestLoadedKeyCertificate(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		blob        string
+		wantCert    bool
+	}{
+		{
+			description: "certificate",
+			blob:        testdata.ED25519WithCertificate.Blob,
+			wantCert:    true,
+		},
+		{
+			description: "public key",
+			blob:        testdata.ED25519WithoutPassphrase.Blob,
+		},
+		{
+			description: "invalid blob",
+			blob:        "bogus",
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			k := &LoadedKey{InternalBlob: tc.blob}
+			if diff := cmp.Diff(k.Certificate() != nil, tc.wantCert); diff != "" {
+				t.Errorf("incorrect certificate detection; -got +want: %s", diff)
+			}
+		})
+	}
+}
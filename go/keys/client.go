@@ -17,9 +17,13 @@
 package keys
 
 import (
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"syscall/js"
+	"time"
 
 	"github.com/google/chrome-ssh-agent/go/jsutil"
 	"github.com/google/chrome-ssh-agent/go/message"
@@ -29,7 +33,16 @@ import (
 // Server exposes a Manager instance via a messaging API so that a shared
 // instance can be invoked from a different page.
 type Server struct {
-	mgr Manager
+	mgr    Manager
+	notify NotifyFunc
+	badge  BadgeFunc
+	onLock LockFunc
+
+	// lockMu guards fields below.
+	lockMu sync.Mutex
+	// unlocked indicates that the master passphrase has been verified
+	// since the Server was started or last locked.
+	unlocked bool
 }
 
 // NewServer returns a new Server that manages keys using the
@@ -57,6 +70,92 @@ const (
 	msgTypeUnload
 	msgTypeUnloadRsp
 	msgTypeErrorRsp
+	msgTypePreferences
+	msgTypePreferencesRsp
+	msgTypeSetPreferences
+	msgTypeSetPreferencesRsp
+	msgTypeExport
+	msgTypeExportRsp
+	msgTypeImport
+	msgTypeImportRsp
+	msgTypeSetPositions
+	msgTypeSetPositionsRsp
+	msgTypeUnloadAll
+	msgTypeUnloadAllRsp
+	msgTypeSetRSASignatureAlgorithm
+	msgTypeSetRSASignatureAlgorithmRsp
+	msgTypeKeyStorage
+	msgTypeKeyStorageRsp
+	msgTypeSetKeyStorage
+	msgTypeSetKeyStorageRsp
+	msgTypeStorageUsage
+	msgTypeStorageUsageRsp
+	msgTypeAddMany
+	msgTypeAddManyRsp
+	msgTypeRemoveMany
+	msgTypeRemoveManyRsp
+	msgTypePing
+	msgTypePingRsp
+	msgTypeMarkUsed
+	msgTypeMarkUsedRsp
+	msgTypeReencrypt
+	msgTypeReencryptRsp
+	msgTypeSetDisabled
+	msgTypeSetDisabledRsp
+	msgTypeSetAutoLoad
+	msgTypeSetAutoLoadRsp
+	msgTypeForgetPassphrases
+	msgTypeForgetPassphrasesRsp
+	msgTypeAppendAuditLog
+	msgTypeAppendAuditLogRsp
+	msgTypeAuditLog
+	msgTypeAuditLogRsp
+	msgTypeClearAuditLog
+	msgTypeClearAuditLogRsp
+	msgTypeAddBundle
+	msgTypeAddBundleRsp
+	msgTypeTestSign
+	msgTypeTestSignRsp
+	msgTypeNativeHostStatus
+	msgTypeNativeHostStatusRsp
+	msgTypeSetAllowedOrigins
+	msgTypeSetAllowedOriginsRsp
+	msgTypeCorruptKeys
+	msgTypeCorruptKeysRsp
+	msgTypeRemoveCorruptKeys
+	msgTypeRemoveCorruptKeysRsp
+	msgTypeMasterPassphraseSet
+	msgTypeMasterPassphraseSetRsp
+	msgTypeVerifyMasterPassphrase
+	msgTypeVerifyMasterPassphraseRsp
+	msgTypeSetMasterPassphrase
+	msgTypeSetMasterPassphraseRsp
+	msgTypeRestore
+	msgTypeRestoreRsp
+	msgTypeLock
+	msgTypeLockRsp
+	msgTypeSetTags
+	msgTypeSetTagsRsp
+	msgTypeExportPublicKeys
+	msgTypeExportPublicKeysRsp
+	msgTypeAdoptLoaded
+	msgTypeAdoptLoadedRsp
+	msgTypeExtensions
+	msgTypeExtensionsRsp
+	msgTypeLockAgent
+	msgTypeLockAgentRsp
+	msgTypeUnlockAgent
+	msgTypeUnlockAgentRsp
+	msgTypeAgentLocked
+	msgTypeAgentLockedRsp
+	msgTypeSetPriority
+	msgTypeSetPriorityRsp
+	msgTypeExportPrivate
+	msgTypeExportPrivateRsp
+	msgTypeSetNote
+	msgTypeSetNoteRsp
+	msgTypeDiscardRemoved
+	msgTypeDiscardRemovedRsp
 )
 
 // msgHeader are the common fields included in every message.
@@ -85,9 +184,10 @@ type rspLoaded struct {
 }
 
 type msgAdd struct {
-	Type          int    `js:"type"`
-	Name          string `js:"name"`
-	PEMPrivateKey string `js:"pemPrivateKey"`
+	Type          int        `js:"type"`
+	Name          string     `js:"name"`
+	PEMPrivateKey string     `js:"pemPrivateKey"`
+	Options       AddOptions `js:"options"`
 }
 
 type rspAdd struct {
@@ -105,10 +205,31 @@ type rspRemove struct {
 	Err  string `js:"err"`
 }
 
+type msgRestore struct {
+	Type int    `js:"type"`
+	ID   string `js:"id"`
+}
+
+type rspRestore struct {
+	Type int    `js:"type"`
+	Err  string `js:"err"`
+}
+
+type msgDiscardRemoved struct {
+	Type int    `js:"type"`
+	ID   string `js:"id"`
+}
+
+type rspDiscardRemoved struct {
+	Type int    `js:"type"`
+	Err  string `js:"err"`
+}
+
 type msgLoad struct {
-	Type       int    `js:"type"`
-	ID         string `js:"id"`
-	Passphrase string `js:"passphrase"`
+	Type       int         `js:"type"`
+	ID         string      `js:"id"`
+	Passphrase string      `js:"passphrase"`
+	Options    LoadOptions `js:"options"`
 }
 
 type rspLoad struct {
@@ -126,241 +247,2334 @@ type rspUnload struct {
 	Err  string `js:"err"`
 }
 
-type rspError struct {
+type msgUnloadAll struct {
+	Type int `js:"type"`
+}
+
+type rspUnloadAll struct {
 	Type int    `js:"type"`
 	Err  string `js:"err"`
 }
 
-// makeErr converts a string to an error. Empty string returns nil (i.e., no
-// error).
-func makeErr(s string) error {
-	if s == "" {
-		return nil
-	}
-	return errors.New(s)
+type msgPreferences struct {
+	Type int `js:"type"`
 }
 
-// makeErrStr converts an error to a string. A nil error is converted to the
-// empty string.
-func makeErrStr(err error) string {
-	if err == nil {
-		return ""
-	}
-	return err.Error()
+type rspPreferences struct {
+	Type  int          `js:"type"`
+	Prefs *Preferences `js:"prefs"`
+	Err   string       `js:"err"`
 }
 
-// makeErrorResponse produces a generic error response that can be sent to the
-// client. This is used in case a more specific error is not possible.
-func (s *Server) makeErrorResponse(err error) js.Value {
-	jsutil.LogError("Server.makeErrorResponse: %v", err)
-	rsp := rspError{
-		Type: msgTypeErrorRsp,
-		Err:  makeErrStr(err),
-	}
-	return vert.ValueOf(rsp).JSValue()
+type msgSetPreferences struct {
+	Type  int          `js:"type"`
+	Prefs *Preferences `js:"prefs"`
 }
 
-// OnMessage is the callback invoked when a message is received. It determines
-// the type of request received, invokes the appropriate method on the
-// underlying manager instance, and then returns the response to be sent to the
-// client.
-func (s *Server) OnMessage(ctx jsutil.AsyncContext, headerObj js.Value, _ js.Value) js.Value {
-	var header msgHeader
-	if err := vert.ValueOf(headerObj).AssignTo(&header); err != nil {
-		return s.makeErrorResponse(fmt.Errorf("failed to parse message header: %w", err))
-	}
+type rspSetPreferences struct {
+	Type int    `js:"type"`
+	Err  string `js:"err"`
+}
 
-	jsutil.LogDebug("Server.OnMessage(type = %d)", header.Type)
-	switch header.Type {
-	case msgTypeConfigured:
-		jsutil.LogDebug("Server.OnMessage(Configured req)")
-		keys, err := s.mgr.Configured(ctx)
-		jsutil.LogDebug("Server.OnMessage(Configured rsp): %d keys, err=%v", len(keys), err)
-		rsp := rspConfigured{
-			Type: msgTypeConfiguredRsp,
-			Keys: keys,
-			Err:  makeErrStr(err),
-		}
-		return vert.ValueOf(rsp).JSValue()
-	case msgTypeLoaded:
-		jsutil.LogDebug("Server.OnMessage(Loaded req)")
-		keys, err := s.mgr.Loaded(ctx)
-		jsutil.LogDebug("Server.OnMessage(Loaded rsp): %d keys, err=%v", len(keys), err)
-		rsp := rspLoaded{
-			Type: msgTypeLoadedRsp,
-			Keys: keys,
-			Err:  makeErrStr(err),
-		}
-		return vert.ValueOf(rsp).JSValue()
-	case msgTypeAdd:
-		var m msgAdd
-		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
-			return s.makeErrorResponse(fmt.Errorf("failed to parse Add message: %w", err))
-		}
-		jsutil.LogDebug("Server.OnMessage(Add req): name=%s", m.Name)
-		err := s.mgr.Add(ctx, m.Name, m.PEMPrivateKey)
-		rsp := rspAdd{
-			Type: msgTypeAddRsp,
-			Err:  makeErrStr(err),
-		}
-		jsutil.LogDebug("Server.OnMessage(Add rsp): err=%v", err)
-		return vert.ValueOf(rsp).JSValue()
-	case msgTypeRemove:
-		var m msgRemove
-		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
-			return s.makeErrorResponse(fmt.Errorf("failed to parse Remove message: %w", err))
-		}
-		jsutil.LogDebug("Server.OnMessage(Remove req): id=%s", m.ID)
-		err := s.mgr.Remove(ctx, ID(m.ID))
-		rsp := rspRemove{
-			Type: msgTypeRemoveRsp,
-			Err:  makeErrStr(err),
-		}
-		jsutil.LogDebug("Server.OnMessage(Remove rsp): err=%v", err)
-		return vert.ValueOf(rsp).JSValue()
-	case msgTypeLoad:
-		var m msgLoad
-		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
-			return s.makeErrorResponse(fmt.Errorf("failed to parse Load message: %w", err))
-		}
-		jsutil.LogDebug("Server.OnMessage(Load req): id=%s", m.ID)
-		err := s.mgr.Load(ctx, ID(m.ID), m.Passphrase)
-		rsp := rspLoad{
-			Type: msgTypeLoadRsp,
-			Err:  makeErrStr(err),
-		}
-		jsutil.LogDebug("Server.OnMessage(Load rsp): err=%v", err)
-		return vert.ValueOf(rsp).JSValue()
-	case msgTypeUnload:
-		var m msgUnload
-		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
-			return s.makeErrorResponse(fmt.Errorf("failed to parse Unload message: %w", err))
-		}
-		jsutil.LogDebug("Server.OnMessage(Unload req): id=%s", m.ID)
-		err := s.mgr.Unload(ctx, ID(m.ID))
-		rsp := rspUnload{
-			Type: msgTypeUnloadRsp,
-			Err:  makeErrStr(err),
-		}
-		jsutil.LogDebug("Server.OnMessage(Unload rsp): err=%v", err)
-		return vert.ValueOf(rsp).JSValue()
-	default:
-		return s.makeErrorResponse(fmt.Errorf("received invalid message type: %d", header.Type))
-	}
+type msgExport struct {
+	Type int `js:"type"`
 }
 
-// client implements the Manager interface and forwards calls to a Server.
-type client struct {
-	msg message.Sender
+type rspExport struct {
+	Type int    `js:"type"`
+	Data string `js:"data"`
+	Err  string `js:"err"`
 }
 
-// NewClient returns a Manager implementation that forwards calls to a Server.
-func NewClient(msg message.Sender) Manager {
-	return &client{msg: msg}
+type msgExportPublicKeys struct {
+	Type int `js:"type"`
 }
 
-// Configured implements Manager.Configured.
-func (c *client) Configured(ctx jsutil.AsyncContext) ([]*ConfiguredKey, error) {
-	var msg msgConfigured
-	msg.Type = msgTypeConfigured
-	jsutil.LogDebug("Client.Configured(req)")
-	rspObj, err := c.msg.Send(ctx, vert.ValueOf(msg).JSValue())
-	jsutil.LogDebug("Client.Configured(rsp)")
-	if err != nil {
-		return nil, fmt.Errorf("failed to send message: %w", err)
-	}
-	var rsp rspConfigured
-	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
-	}
-	return rsp.Keys, makeErr(rsp.Err)
+type rspExportPublicKeys struct {
+	Type int    `js:"type"`
+	Text string `js:"text"`
+	Err  string `js:"err"`
 }
 
-// Loaded implements Manager.Loaded.
-func (c *client) Loaded(ctx jsutil.AsyncContext) ([]*LoadedKey, error) {
-	var msg msgLoaded
-	msg.Type = msgTypeLoaded
-	jsutil.LogDebug("Client.Loaded(req)")
-	rspObj, err := c.msg.Send(ctx, vert.ValueOf(msg).JSValue())
-	jsutil.LogDebug("Client.Loaded(rsp)")
-	if err != nil {
-		return nil, fmt.Errorf("failed to send message: %w", err)
-	}
-	var rsp rspLoaded
-	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
-	}
-	return rsp.Keys, makeErr(rsp.Err)
+type msgAdoptLoaded struct {
+	Type int        `js:"type"`
+	Key  *LoadedKey `js:"key"`
+	Name string     `js:"name"`
 }
 
-// Add implements Manager.Add.
-func (c *client) Add(ctx jsutil.AsyncContext, name string, pemPrivateKey string) error {
-	var msg msgAdd
-	msg.Type = msgTypeAdd
-	msg.Name = name
-	msg.PEMPrivateKey = pemPrivateKey
-	jsutil.LogDebug("Client.Add(req): name=%s", msg.Name)
-	rspObj, err := c.msg.Send(ctx, vert.ValueOf(msg).JSValue())
-	jsutil.LogDebug("Client.Add(rsp)")
-	if err != nil {
-		return fmt.Errorf("failed to send message: %w", err)
-	}
-	var rsp rspAdd
-	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
-		return fmt.Errorf("failed to parse response: %w", err)
-	}
-	return makeErr(rsp.Err)
+type rspAdoptLoaded struct {
+	Type int    `js:"type"`
+	Err  string `js:"err"`
 }
 
-// Remove implements Manager.Remove.
-func (c *client) Remove(ctx jsutil.AsyncContext, id ID) error {
-	var msg msgRemove
-	msg.Type = msgTypeRemove
-	msg.ID = string(id)
-	jsutil.LogDebug("Client.Remove(req): id=%s", msg.ID)
-	rspObj, err := c.msg.Send(ctx, vert.ValueOf(msg).JSValue())
-	jsutil.LogDebug("Client.Remove(rsp)")
-	if err != nil {
-		return fmt.Errorf("failed to send message: %w", err)
-	}
-	var rsp rspRemove
-	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
-		return fmt.Errorf("failed to parse response: %w", err)
-	}
-	return makeErr(rsp.Err)
+type msgExtensions struct {
+	Type int `js:"type"`
 }
 
-// Load implements Manager.Load.
-func (c *client) Load(ctx jsutil.AsyncContext, id ID, passphrase string) error {
-	var msg msgLoad
-	msg.Type = msgTypeLoad
-	msg.ID = string(id)
-	msg.Passphrase = passphrase
-	jsutil.LogDebug("Client.Load(req): id=%s", msg.ID)
-	rspObj, err := c.msg.Send(ctx, vert.ValueOf(msg).JSValue())
-	jsutil.LogDebug("Client.Load(rsp)")
-	if err != nil {
-		return fmt.Errorf("failed to send message: %w", err)
-	}
-	var rsp rspLoad
-	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
-		return fmt.Errorf("failed to parse response: %w", err)
-	}
-	return makeErr(rsp.Err)
+type rspExtensions struct {
+	Type  int      `js:"type"`
+	Names []string `js:"names"`
+	Err   string   `js:"err"`
 }
 
-// Unload implements Manager.Unload.
-func (c *client) Unload(ctx jsutil.AsyncContext, id ID) error {
-	var msg msgUnload
-	msg.Type = msgTypeUnload
-	msg.ID = string(id)
-	jsutil.LogDebug("Client.Unload(req): id=%s", msg.ID)
-	rspObj, err := c.msg.Send(ctx, vert.ValueOf(msg).JSValue())
-	jsutil.LogDebug("Client.Unload(rsp)")
-	if err != nil {
-		return fmt.Errorf("failed to send message: %w", err)
-	}
-	var rsp rspUnload
+type msgLockAgent struct {
+	Type       int    `js:"type"`
+	Passphrase string `js:"passphrase"`
+}
+
+type rspLockAgent struct {
+	Type int    `js:"type"`
+	Err  string `js:"err"`
+}
+
+type msgUnlockAgent struct {
+	Type       int    `js:"type"`
+	Passphrase string `js:"passphrase"`
+}
+
+type rspUnlockAgent struct {
+	Type int    `js:"type"`
+	Err  string `js:"err"`
+}
+
+type msgAgentLocked struct {
+	Type int `js:"type"`
+}
+
+type rspAgentLocked struct {
+	Type   int    `js:"type"`
+	Locked bool   `js:"locked"`
+	Err    string `js:"err"`
+}
+
+type msgSetPriority struct {
+	Type     int    `js:"type"`
+	ID       string `js:"id"`
+	Priority int    `js:"priority"`
+}
+
+type rspSetPriority struct {
+	Type int    `js:"type"`
+	Err  string `js:"err"`
+}
+
+type msgExportPrivate struct {
+	Type          int    `js:"type"`
+	ID            string `js:"id"`
+	Passphrase    string `js:"passphrase"`
+	NewPassphrase string `js:"newPassphrase"`
+}
+
+type rspExportPrivate struct {
+	Type          int    `js:"type"`
+	PEMPrivateKey string `js:"pemPrivateKey"`
+	Err           string `js:"err"`
+}
+
+type msgSetNote struct {
+	Type int    `js:"type"`
+	ID   string `js:"id"`
+	Note string `js:"note"`
+}
+
+type rspSetNote struct {
+	Type int    `js:"type"`
+	Err  string `js:"err"`
+}
+
+type msgImport struct {
+	Type int    `js:"type"`
+	Data string `js:"data"`
+}
+
+type rspImport struct {
+	Type   int           `js:"type"`
+	Result *ImportResult `js:"result"`
+	Err    string        `js:"err"`
+}
+
+type msgSetPositions struct {
+	Type int      `js:"type"`
+	IDs  []string `js:"ids"`
+}
+
+type rspSetPositions struct {
+	Type int    `js:"type"`
+	Err  string `js:"err"`
+}
+
+type msgSetRSASignatureAlgorithm struct {
+	Type      int    `js:"type"`
+	ID        string `js:"id"`
+	Algorithm string `js:"algorithm"`
+}
+
+type rspSetRSASignatureAlgorithm struct {
+	Type int    `js:"type"`
+	Err  string `js:"err"`
+}
+
+type msgKeyStorage struct {
+	Type int `js:"type"`
+}
+
+type rspKeyStorage struct {
+	Type     int    `js:"type"`
+	Location string `js:"location"`
+	Err      string `js:"err"`
+}
+
+type msgSetKeyStorage struct {
+	Type     int    `js:"type"`
+	Location string `js:"location"`
+}
+
+type rspSetKeyStorage struct {
+	Type int    `js:"type"`
+	Err  string `js:"err"`
+}
+
+type msgStorageUsage struct {
+	Type int `js:"type"`
+}
+
+type rspStorageUsage struct {
+	Type  int           `js:"type"`
+	Usage *StorageUsage `js:"usage"`
+	Err   string        `js:"err"`
+}
+
+type msgPing struct {
+	Type int `js:"type"`
+}
+
+type rspPing struct {
+	Type int    `js:"type"`
+	Err  string `js:"err"`
+}
+
+type msgAddMany struct {
+	Type int       `js:"type"`
+	Keys []*NewKey `js:"keys"`
+}
+
+type rspAddMany struct {
+	Type int      `js:"type"`
+	Errs []string `js:"errs"`
+	Err  string   `js:"err"`
+}
+
+type msgRemoveMany struct {
+	Type int      `js:"type"`
+	IDs  []string `js:"ids"`
+}
+
+type rspRemoveMany struct {
+	Type int      `js:"type"`
+	Errs []string `js:"errs"`
+	Err  string   `js:"err"`
+}
+
+type msgMarkUsed struct {
+	Type int    `js:"type"`
+	ID   string `js:"id"`
+}
+
+type rspMarkUsed struct {
+	Type int    `js:"type"`
+	Err  string `js:"err"`
+}
+
+type msgReencrypt struct {
+	Type          int    `js:"type"`
+	ID            string `js:"id"`
+	OldPassphrase string `js:"oldPassphrase"`
+	NewPassphrase string `js:"newPassphrase"`
+}
+
+type rspReencrypt struct {
+	Type int    `js:"type"`
+	Err  string `js:"err"`
+}
+
+type msgSetDisabled struct {
+	Type     int    `js:"type"`
+	ID       string `js:"id"`
+	Disabled bool   `js:"disabled"`
+}
+
+type rspSetDisabled struct {
+	Type int    `js:"type"`
+	Err  string `js:"err"`
+}
+
+type msgSetAutoLoad struct {
+	Type     int    `js:"type"`
+	ID       string `js:"id"`
+	AutoLoad bool   `js:"autoLoad"`
+}
+
+type rspSetAutoLoad struct {
+	Type int    `js:"type"`
+	Err  string `js:"err"`
+}
+
+type msgForgetPassphrases struct {
+	Type int `js:"type"`
+}
+
+type rspForgetPassphrases struct {
+	Type int    `js:"type"`
+	Err  string `js:"err"`
+}
+
+type msgAppendAuditLog struct {
+	Type  int         `js:"type"`
+	Entry *AuditEntry `js:"entry"`
+}
+
+type rspAppendAuditLog struct {
+	Type int    `js:"type"`
+	Err  string `js:"err"`
+}
+
+type msgAuditLog struct {
+	Type int `js:"type"`
+}
+
+type rspAuditLog struct {
+	Type    int           `js:"type"`
+	Entries []*AuditEntry `js:"entries"`
+	Err     string        `js:"err"`
+}
+
+type msgClearAuditLog struct {
+	Type int `js:"type"`
+}
+
+type rspClearAuditLog struct {
+	Type int    `js:"type"`
+	Err  string `js:"err"`
+}
+
+type msgAddBundle struct {
+	Type    int        `js:"type"`
+	Name    string     `js:"name"`
+	Bundle  string     `js:"bundle"`
+	Options AddOptions `js:"options"`
+}
+
+type rspAddBundle struct {
+	Type int      `js:"type"`
+	Errs []string `js:"errs"`
+	Err  string   `js:"err"`
+}
+
+type msgTestSign struct {
+	Type int        `js:"type"`
+	Key  *LoadedKey `js:"key"`
+}
+
+type rspTestSign struct {
+	Type int    `js:"type"`
+	Err  string `js:"err"`
+}
+
+type msgNativeHostStatus struct {
+	Type int `js:"type"`
+}
+
+type rspNativeHostStatus struct {
+	Type   int               `js:"type"`
+	Status *NativeHostStatus `js:"status"`
+	Err    string            `js:"err"`
+}
+
+type msgSetAllowedOrigins struct {
+	Type    int      `js:"type"`
+	ID      string   `js:"id"`
+	Origins []string `js:"origins"`
+}
+
+type rspSetAllowedOrigins struct {
+	Type int    `js:"type"`
+	Err  string `js:"err"`
+}
+
+type msgSetTags struct {
+	Type int      `js:"type"`
+	ID   string   `js:"id"`
+	Tags []string `js:"tags"`
+}
+
+type rspSetTags struct {
+	Type int    `js:"type"`
+	Err  string `js:"err"`
+}
+
+type msgCorruptKeys struct {
+	Type int `js:"type"`
+}
+
+type rspCorruptKeys struct {
+	Type  int    `js:"type"`
+	Count int    `js:"count"`
+	Err   string `js:"err"`
+}
+
+type msgRemoveCorruptKeys struct {
+	Type int `js:"type"`
+}
+
+type rspRemoveCorruptKeys struct {
+	Type    int    `js:"type"`
+	Removed int    `js:"removed"`
+	Err     string `js:"err"`
+}
+
+type msgMasterPassphraseSet struct {
+	Type int `js:"type"`
+}
+
+type rspMasterPassphraseSet struct {
+	Type int    `js:"type"`
+	Set  bool   `js:"set"`
+	Err  string `js:"err"`
+}
+
+type msgVerifyMasterPassphrase struct {
+	Type       int    `js:"type"`
+	Passphrase string `js:"passphrase"`
+}
+
+type rspVerifyMasterPassphrase struct {
+	Type int    `js:"type"`
+	Err  string `js:"err"`
+}
+
+type msgSetMasterPassphrase struct {
+	Type       int    `js:"type"`
+	Current    string `js:"current"`
+	Passphrase string `js:"passphrase"`
+}
+
+type rspSetMasterPassphrase struct {
+	Type int    `js:"type"`
+	Err  string `js:"err"`
+}
+
+type msgLock struct {
+	Type int `js:"type"`
+}
+
+type rspLock struct {
+	Type int    `js:"type"`
+	Err  string `js:"err"`
+}
+
+type rspError struct {
+	Type int    `js:"type"`
+	Err  string `js:"err"`
+}
+
+// makeErr converts a string to an error. Empty string returns nil (i.e., no
+// error).
+func makeErr(s string) error {
+	if s == "" {
+		return nil
+	}
+	if s == errLocked.Error() {
+		// Preserve the error, so that callers may detect it.
+		return errLocked
+	}
+	for _, d := range detectableErrs {
+		if strings.Contains(s, d.fragment) {
+			// Preserve the error's message, while allowing callers
+			// to detect it.
+			return &serverErr{msg: s, err: d.err}
+		}
+	}
+	return errors.New(s)
+}
+
+// detectableErrs are errors that callers may detect once returned by the
+// server, identified by a fragment of the error's message.
+var detectableErrs = []struct {
+	fragment string
+	err      error
+}{
+	{errAgentLocked.Error(), errAgentLocked},
+	{ErrUnsupportedCipher.Error(), ErrUnsupportedCipher},
+	{x509.IncorrectPasswordError.Error(), ErrBadPassphrase},
+	{"passphrase is incorrect", ErrBadPassphrase},
+	{ErrDuplicateKey.Error(), ErrDuplicateKey},
+	{ErrNameInUse.Error(), ErrNameInUse},
+	{ErrInvalidName.Error(), ErrInvalidName},
+	{errUnrecognizedKey.Error(), ErrUnsupportedFormat},
+}
+
+// serverErr is an error returned by the server which wraps an error that
+// callers may detect.
+type serverErr struct {
+	msg string
+	err error
+}
+
+// Error implements error.Error.
+func (e *serverErr) Error() string {
+	return e.msg
+}
+
+// Unwrap returns the wrapped error.
+func (e *serverErr) Unwrap() error {
+	return e.err
+}
+
+// makeErrStr converts an error to a string. A nil error is converted to the
+// empty string.
+func makeErrStr(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// makeErrs converts per-item error strings to errors, as with makeErr.
+func makeErrs(strs []string) []error {
+	if strs == nil {
+		return nil
+	}
+	errs := make([]error, len(strs))
+	for i, s := range strs {
+		errs[i] = makeErr(s)
+	}
+	return errs
+}
+
+// makeErrStrs converts per-item errors to strings, as with makeErrStr.
+func makeErrStrs(errs []error) []string {
+	if errs == nil {
+		return nil
+	}
+	strs := make([]string, len(errs))
+	for i, err := range errs {
+		strs[i] = makeErrStr(err)
+	}
+	return strs
+}
+
+// makeErrorResponse produces a generic error response that can be sent to the
+// client. This is used in case a more specific error is not possible.
+func (s *Server) makeErrorResponse(err error) js.Value {
+	jsutil.LogError("Server.makeErrorResponse: %v", err)
+	rsp := rspError{
+		Type: msgTypeErrorRsp,
+		Err:  makeErrStr(err),
+	}
+	return vert.ValueOf(rsp).JSValue()
+}
+
+// OnMessage is the callback invoked when a message is received. It determines
+// the type of request received, invokes the appropriate method on the
+// underlying manager instance, and then returns the response to be sent to the
+// client.
+func (s *Server) OnMessage(ctx jsutil.AsyncContext, headerObj js.Value, _ js.Value) js.Value {
+	var header msgHeader
+	if err := vert.ValueOf(headerObj).AssignTo(&header); err != nil {
+		return s.makeErrorResponse(fmt.Errorf("failed to parse message header: %w", err))
+	}
+
+	jsutil.LogDebug("Server.OnMessage(type = %d)", header.Type)
+	if !lockExempt[header.Type] && s.Locked(ctx) {
+		return s.makeErrorResponse(errLocked)
+	}
+	switch header.Type {
+	case msgTypeConfigured:
+		jsutil.LogDebug("Server.OnMessage(Configured req)")
+		keys, err := s.mgr.Configured(ctx)
+		jsutil.LogDebug("Server.OnMessage(Configured rsp): %d keys, err=%v", len(keys), err)
+		rsp := rspConfigured{
+			Type: msgTypeConfiguredRsp,
+			Keys: keys,
+			Err:  makeErrStr(err),
+		}
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeLoaded:
+		jsutil.LogDebug("Server.OnMessage(Loaded req)")
+		keys, err := s.mgr.Loaded(ctx)
+		jsutil.LogDebug("Server.OnMessage(Loaded rsp): %d keys, err=%v", len(keys), err)
+		rsp := rspLoaded{
+			Type: msgTypeLoadedRsp,
+			Keys: keys,
+			Err:  makeErrStr(err),
+		}
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeAdd:
+		var m msgAdd
+		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
+			return s.makeErrorResponse(fmt.Errorf("failed to parse Add message: %w", err))
+		}
+		jsutil.LogDebug("Server.OnMessage(Add req): name=%s", m.Name)
+		err := s.mgr.Add(ctx, m.Name, m.PEMPrivateKey, m.Options)
+		rsp := rspAdd{
+			Type: msgTypeAddRsp,
+			Err:  makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(Add rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeRemove:
+		var m msgRemove
+		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
+			return s.makeErrorResponse(fmt.Errorf("failed to parse Remove message: %w", err))
+		}
+		jsutil.LogDebug("Server.OnMessage(Remove req): id=%s", m.ID)
+		err := s.mgr.Remove(ctx, ID(m.ID))
+		s.UpdateBadge(ctx)
+		rsp := rspRemove{
+			Type: msgTypeRemoveRsp,
+			Err:  makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(Remove rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeRestore:
+		var m msgRestore
+		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
+			return s.makeErrorResponse(fmt.Errorf("failed to parse Restore message: %w", err))
+		}
+		jsutil.LogDebug("Server.OnMessage(Restore req): id=%s", m.ID)
+		err := s.mgr.Restore(ctx, ID(m.ID))
+		s.UpdateBadge(ctx)
+		rsp := rspRestore{
+			Type: msgTypeRestoreRsp,
+			Err:  makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(Restore rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeDiscardRemoved:
+		var m msgDiscardRemoved
+		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
+			return s.makeErrorResponse(fmt.Errorf("failed to parse DiscardRemoved message: %w", err))
+		}
+		jsutil.LogDebug("Server.OnMessage(DiscardRemoved req): id=%s", m.ID)
+		err := s.mgr.DiscardRemoved(ctx, ID(m.ID))
+		rsp := rspDiscardRemoved{
+			Type: msgTypeDiscardRemovedRsp,
+			Err:  makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(DiscardRemoved rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeLoad:
+		var m msgLoad
+		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
+			return s.makeErrorResponse(fmt.Errorf("failed to parse Load message: %w", err))
+		}
+		jsutil.LogDebug("Server.OnMessage(Load req): id=%s", m.ID)
+		err := s.mgr.Load(ctx, ID(m.ID), m.Passphrase, m.Options)
+		if err == nil && s.notifyEnabled(ctx) {
+			s.notify("SSH key loaded", s.describeKey(ctx, ID(m.ID)))
+		}
+		s.UpdateBadge(ctx)
+		rsp := rspLoad{
+			Type: msgTypeLoadRsp,
+			Err:  makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(Load rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeUnload:
+		var m msgUnload
+		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
+			return s.makeErrorResponse(fmt.Errorf("failed to parse Unload message: %w", err))
+		}
+		jsutil.LogDebug("Server.OnMessage(Unload req): id=%s", m.ID)
+		// Describe the key before unloading it; its public key is no
+		// longer available afterwards.
+		notify := s.notifyEnabled(ctx)
+		var desc string
+		if notify {
+			desc = s.describeKey(ctx, ID(m.ID))
+		}
+		err := s.mgr.Unload(ctx, ID(m.ID))
+		if err == nil && notify {
+			s.notify("SSH key unloaded", desc)
+		}
+		s.UpdateBadge(ctx)
+		rsp := rspUnload{
+			Type: msgTypeUnloadRsp,
+			Err:  makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(Unload rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeUnloadAll:
+		jsutil.LogDebug("Server.OnMessage(UnloadAll req)")
+		err := s.mgr.UnloadAll(ctx)
+		if err == nil && s.notifyEnabled(ctx) {
+			s.notify("SSH keys unloaded", "All keys were unloaded from the agent.")
+		}
+		s.UpdateBadge(ctx)
+		rsp := rspUnloadAll{
+			Type: msgTypeUnloadAllRsp,
+			Err:  makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(UnloadAll rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypePreferences:
+		jsutil.LogDebug("Server.OnMessage(Preferences req)")
+		prefs, err := s.mgr.Preferences(ctx)
+		jsutil.LogDebug("Server.OnMessage(Preferences rsp): err=%v", err)
+		rsp := rspPreferences{
+			Type:  msgTypePreferencesRsp,
+			Prefs: prefs,
+			Err:   makeErrStr(err),
+		}
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeSetPreferences:
+		var m msgSetPreferences
+		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
+			return s.makeErrorResponse(fmt.Errorf("failed to parse SetPreferences message: %w", err))
+		}
+		jsutil.LogDebug("Server.OnMessage(SetPreferences req)")
+		err := s.mgr.SetPreferences(ctx, m.Prefs)
+		rsp := rspSetPreferences{
+			Type: msgTypeSetPreferencesRsp,
+			Err:  makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(SetPreferences rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeExport:
+		var m msgExport
+		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
+			return s.makeErrorResponse(fmt.Errorf("failed to parse Export message: %w", err))
+		}
+		jsutil.LogDebug("Server.OnMessage(Export req)")
+		data, err := s.mgr.Export(ctx)
+		rsp := rspExport{
+			Type: msgTypeExportRsp,
+			Data: string(data),
+			Err:  makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(Export rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeExportPublicKeys:
+		var m msgExportPublicKeys
+		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
+			return s.makeErrorResponse(fmt.Errorf("failed to parse ExportPublicKeys message: %w", err))
+		}
+		jsutil.LogDebug("Server.OnMessage(ExportPublicKeys req)")
+		text, err := s.mgr.ExportPublicKeys(ctx)
+		rsp := rspExportPublicKeys{
+			Type: msgTypeExportPublicKeysRsp,
+			Text: text,
+			Err:  makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(ExportPublicKeys rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeAdoptLoaded:
+		var m msgAdoptLoaded
+		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
+			return s.makeErrorResponse(fmt.Errorf("failed to parse AdoptLoaded message: %w", err))
+		}
+		jsutil.LogDebug("Server.OnMessage(AdoptLoaded req): name=%s", m.Name)
+		err := s.mgr.AdoptLoaded(ctx, m.Key, m.Name)
+		rsp := rspAdoptLoaded{
+			Type: msgTypeAdoptLoadedRsp,
+			Err:  makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(AdoptLoaded rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeExtensions:
+		var m msgExtensions
+		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
+			return s.makeErrorResponse(fmt.Errorf("failed to parse Extensions message: %w", err))
+		}
+		jsutil.LogDebug("Server.OnMessage(Extensions req)")
+		names, err := s.mgr.Extensions(ctx)
+		rsp := rspExtensions{
+			Type:  msgTypeExtensionsRsp,
+			Names: names,
+			Err:   makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(Extensions rsp): names=%v, err=%v", names, err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeLockAgent:
+		var m msgLockAgent
+		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
+			return s.makeErrorResponse(fmt.Errorf("failed to parse LockAgent message: %w", err))
+		}
+		jsutil.LogDebug("Server.OnMessage(LockAgent req)")
+		err := s.mgr.LockAgent(ctx, m.Passphrase)
+		rsp := rspLockAgent{
+			Type: msgTypeLockAgentRsp,
+			Err:  makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(LockAgent rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeUnlockAgent:
+		var m msgUnlockAgent
+		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
+			return s.makeErrorResponse(fmt.Errorf("failed to parse UnlockAgent message: %w", err))
+		}
+		jsutil.LogDebug("Server.OnMessage(UnlockAgent req)")
+		err := s.mgr.UnlockAgent(ctx, m.Passphrase)
+		rsp := rspUnlockAgent{
+			Type: msgTypeUnlockAgentRsp,
+			Err:  makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(UnlockAgent rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeAgentLocked:
+		var m msgAgentLocked
+		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
+			return s.makeErrorResponse(fmt.Errorf("failed to parse AgentLocked message: %w", err))
+		}
+		jsutil.LogDebug("Server.OnMessage(AgentLocked req)")
+		locked, err := s.mgr.AgentLocked(ctx)
+		rsp := rspAgentLocked{
+			Type:   msgTypeAgentLockedRsp,
+			Locked: locked,
+			Err:    makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(AgentLocked rsp): locked=%t, err=%v", locked, err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeSetPriority:
+		var m msgSetPriority
+		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
+			return s.makeErrorResponse(fmt.Errorf("failed to parse SetPriority message: %w", err))
+		}
+		jsutil.LogDebug("Server.OnMessage(SetPriority req): id=%s, priority=%d", m.ID, m.Priority)
+		err := s.mgr.SetPriority(ctx, ID(m.ID), m.Priority)
+		rsp := rspSetPriority{
+			Type: msgTypeSetPriorityRsp,
+			Err:  makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(SetPriority rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeExportPrivate:
+		var m msgExportPrivate
+		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
+			return s.makeErrorResponse(fmt.Errorf("failed to parse ExportPrivate message: %w", err))
+		}
+		jsutil.LogDebug("Server.OnMessage(ExportPrivate req): id=%s", m.ID)
+		pemPrivateKey, err := s.mgr.ExportPrivate(ctx, ID(m.ID), m.Passphrase, m.NewPassphrase)
+		rsp := rspExportPrivate{
+			Type:          msgTypeExportPrivateRsp,
+			PEMPrivateKey: pemPrivateKey,
+			Err:           makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(ExportPrivate rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeSetNote:
+		var m msgSetNote
+		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
+			return s.makeErrorResponse(fmt.Errorf("failed to parse SetNote message: %w", err))
+		}
+		jsutil.LogDebug("Server.OnMessage(SetNote req): id=%s", m.ID)
+		err := s.mgr.SetNote(ctx, ID(m.ID), m.Note)
+		rsp := rspSetNote{
+			Type: msgTypeSetNoteRsp,
+			Err:  makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(SetNote rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeImport:
+		var m msgImport
+		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
+			return s.makeErrorResponse(fmt.Errorf("failed to parse Import message: %w", err))
+		}
+		jsutil.LogDebug("Server.OnMessage(Import req)")
+		result, err := s.mgr.Import(ctx, []byte(m.Data))
+		rsp := rspImport{
+			Type:   msgTypeImportRsp,
+			Result: result,
+			Err:    makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(Import rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeSetPositions:
+		var m msgSetPositions
+		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
+			return s.makeErrorResponse(fmt.Errorf("failed to parse SetPositions message: %w", err))
+		}
+		jsutil.LogDebug("Server.OnMessage(SetPositions req): ids=%v", m.IDs)
+		var ids []ID
+		for _, id := range m.IDs {
+			ids = append(ids, ID(id))
+		}
+		err := s.mgr.SetPositions(ctx, ids)
+		rsp := rspSetPositions{
+			Type: msgTypeSetPositionsRsp,
+			Err:  makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(SetPositions rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeSetRSASignatureAlgorithm:
+		var m msgSetRSASignatureAlgorithm
+		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
+			return s.makeErrorResponse(fmt.Errorf("failed to parse SetRSASignatureAlgorithm message: %w", err))
+		}
+		jsutil.LogDebug("Server.OnMessage(SetRSASignatureAlgorithm req): id=%s, algorithm=%s", m.ID, m.Algorithm)
+		err := s.mgr.SetRSASignatureAlgorithm(ctx, ID(m.ID), m.Algorithm)
+		rsp := rspSetRSASignatureAlgorithm{
+			Type: msgTypeSetRSASignatureAlgorithmRsp,
+			Err:  makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(SetRSASignatureAlgorithm rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeKeyStorage:
+		jsutil.LogDebug("Server.OnMessage(KeyStorage req)")
+		location, err := s.mgr.KeyStorage(ctx)
+		jsutil.LogDebug("Server.OnMessage(KeyStorage rsp): location=%s, err=%v", location, err)
+		rsp := rspKeyStorage{
+			Type:     msgTypeKeyStorageRsp,
+			Location: location,
+			Err:      makeErrStr(err),
+		}
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeSetKeyStorage:
+		var m msgSetKeyStorage
+		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
+			return s.makeErrorResponse(fmt.Errorf("failed to parse SetKeyStorage message: %w", err))
+		}
+		jsutil.LogDebug("Server.OnMessage(SetKeyStorage req): location=%s", m.Location)
+		err := s.mgr.SetKeyStorage(ctx, m.Location)
+		rsp := rspSetKeyStorage{
+			Type: msgTypeSetKeyStorageRsp,
+			Err:  makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(SetKeyStorage rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeAddMany:
+		var m msgAddMany
+		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
+			return s.makeErrorResponse(fmt.Errorf("failed to parse AddMany message: %w", err))
+		}
+		jsutil.LogDebug("Server.OnMessage(AddMany req): keys=%d", len(m.Keys))
+		errs, err := s.mgr.AddMany(ctx, m.Keys)
+		rsp := rspAddMany{
+			Type: msgTypeAddManyRsp,
+			Errs: makeErrStrs(errs),
+			Err:  makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(AddMany rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeRemoveMany:
+		var m msgRemoveMany
+		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
+			return s.makeErrorResponse(fmt.Errorf("failed to parse RemoveMany message: %w", err))
+		}
+		jsutil.LogDebug("Server.OnMessage(RemoveMany req): ids=%v", m.IDs)
+		var ids []ID
+		for _, id := range m.IDs {
+			ids = append(ids, ID(id))
+		}
+		errs, err := s.mgr.RemoveMany(ctx, ids)
+		s.UpdateBadge(ctx)
+		rsp := rspRemoveMany{
+			Type: msgTypeRemoveManyRsp,
+			Errs: makeErrStrs(errs),
+			Err:  makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(RemoveMany rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeStorageUsage:
+		jsutil.LogDebug("Server.OnMessage(StorageUsage req)")
+		usage, err := s.mgr.StorageUsage(ctx)
+		jsutil.LogDebug("Server.OnMessage(StorageUsage rsp): err=%v", err)
+		rsp := rspStorageUsage{
+			Type:  msgTypeStorageUsageRsp,
+			Usage: usage,
+			Err:   makeErrStr(err),
+		}
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypePing:
+		jsutil.LogDebug("Server.OnMessage(Ping req)")
+		err := s.mgr.Ping(ctx)
+		jsutil.LogDebug("Server.OnMessage(Ping rsp): err=%v", err)
+		rsp := rspPing{
+			Type: msgTypePingRsp,
+			Err:  makeErrStr(err),
+		}
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeMarkUsed:
+		var m msgMarkUsed
+		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
+			return s.makeErrorResponse(fmt.Errorf("failed to parse MarkUsed message: %w", err))
+		}
+		jsutil.LogDebug("Server.OnMessage(MarkUsed req): id=%s", m.ID)
+		err := s.mgr.MarkUsed(ctx, ID(m.ID))
+		rsp := rspMarkUsed{
+			Type: msgTypeMarkUsedRsp,
+			Err:  makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(MarkUsed rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeReencrypt:
+		var m msgReencrypt
+		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
+			return s.makeErrorResponse(fmt.Errorf("failed to parse Reencrypt message: %w", err))
+		}
+		jsutil.LogDebug("Server.OnMessage(Reencrypt req): id=%s", m.ID)
+		err := s.mgr.Reencrypt(ctx, ID(m.ID), m.OldPassphrase, m.NewPassphrase)
+		rsp := rspReencrypt{
+			Type: msgTypeReencryptRsp,
+			Err:  makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(Reencrypt rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeSetDisabled:
+		var m msgSetDisabled
+		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
+			return s.makeErrorResponse(fmt.Errorf("failed to parse SetDisabled message: %w", err))
+		}
+		jsutil.LogDebug("Server.OnMessage(SetDisabled req): id=%s, disabled=%t", m.ID, m.Disabled)
+		err := s.mgr.SetDisabled(ctx, ID(m.ID), m.Disabled)
+		rsp := rspSetDisabled{
+			Type: msgTypeSetDisabledRsp,
+			Err:  makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(SetDisabled rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeSetAutoLoad:
+		var m msgSetAutoLoad
+		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
+			return s.makeErrorResponse(fmt.Errorf("failed to parse SetAutoLoad message: %w", err))
+		}
+		jsutil.LogDebug("Server.OnMessage(SetAutoLoad req): id=%s, autoLoad=%t", m.ID, m.AutoLoad)
+		err := s.mgr.SetAutoLoad(ctx, ID(m.ID), m.AutoLoad)
+		rsp := rspSetAutoLoad{
+			Type: msgTypeSetAutoLoadRsp,
+			Err:  makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(SetAutoLoad rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeForgetPassphrases:
+		jsutil.LogDebug("Server.OnMessage(ForgetPassphrases req)")
+		err := s.mgr.ForgetPassphrases(ctx)
+		rsp := rspForgetPassphrases{
+			Type: msgTypeForgetPassphrasesRsp,
+			Err:  makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(ForgetPassphrases rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeAppendAuditLog:
+		var m msgAppendAuditLog
+		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
+			return s.makeErrorResponse(fmt.Errorf("failed to parse AppendAuditLog message: %w", err))
+		}
+		jsutil.LogDebug("Server.OnMessage(AppendAuditLog req)")
+		err := s.mgr.AppendAuditLog(ctx, m.Entry)
+		rsp := rspAppendAuditLog{
+			Type: msgTypeAppendAuditLogRsp,
+			Err:  makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(AppendAuditLog rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeAuditLog:
+		jsutil.LogDebug("Server.OnMessage(AuditLog req)")
+		entries, err := s.mgr.AuditLog(ctx)
+		jsutil.LogDebug("Server.OnMessage(AuditLog rsp): %d entries, err=%v", len(entries), err)
+		rsp := rspAuditLog{
+			Type:    msgTypeAuditLogRsp,
+			Entries: entries,
+			Err:     makeErrStr(err),
+		}
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeClearAuditLog:
+		jsutil.LogDebug("Server.OnMessage(ClearAuditLog req)")
+		err := s.mgr.ClearAuditLog(ctx)
+		rsp := rspClearAuditLog{
+			Type: msgTypeClearAuditLogRsp,
+			Err:  makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(ClearAuditLog rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeAddBundle:
+		var m msgAddBundle
+		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
+			return s.makeErrorResponse(fmt.Errorf("failed to parse AddBundle message: %w", err))
+		}
+		jsutil.LogDebug("Server.OnMessage(AddBundle req): name=%s", m.Name)
+		errs, err := s.mgr.AddBundle(ctx, m.Name, m.Bundle, m.Options)
+		rsp := rspAddBundle{
+			Type: msgTypeAddBundleRsp,
+			Errs: makeErrStrs(errs),
+			Err:  makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(AddBundle rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeTestSign:
+		var m msgTestSign
+		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
+			return s.makeErrorResponse(fmt.Errorf("failed to parse TestSign message: %w", err))
+		}
+		jsutil.LogDebug("Server.OnMessage(TestSign req): comment=%s", m.Key.Comment)
+		err := s.mgr.TestSign(ctx, m.Key)
+		rsp := rspTestSign{
+			Type: msgTypeTestSignRsp,
+			Err:  makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(TestSign rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeNativeHostStatus:
+		jsutil.LogDebug("Server.OnMessage(NativeHostStatus req)")
+		status, err := s.mgr.NativeHostStatus(ctx)
+		jsutil.LogDebug("Server.OnMessage(NativeHostStatus rsp): err=%v", err)
+		rsp := rspNativeHostStatus{
+			Type:   msgTypeNativeHostStatusRsp,
+			Status: status,
+			Err:    makeErrStr(err),
+		}
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeSetAllowedOrigins:
+		var m msgSetAllowedOrigins
+		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
+			return s.makeErrorResponse(fmt.Errorf("failed to parse SetAllowedOrigins message: %w", err))
+		}
+		jsutil.LogDebug("Server.OnMessage(SetAllowedOrigins req): id=%s, origins=%v", m.ID, m.Origins)
+		err := s.mgr.SetAllowedOrigins(ctx, ID(m.ID), m.Origins)
+		rsp := rspSetAllowedOrigins{
+			Type: msgTypeSetAllowedOriginsRsp,
+			Err:  makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(SetAllowedOrigins rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeSetTags:
+		var m msgSetTags
+		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
+			return s.makeErrorResponse(fmt.Errorf("failed to parse SetTags message: %w", err))
+		}
+		jsutil.LogDebug("Server.OnMessage(SetTags req): id=%s, tags=%v", m.ID, m.Tags)
+		err := s.mgr.SetTags(ctx, ID(m.ID), m.Tags)
+		rsp := rspSetTags{
+			Type: msgTypeSetTagsRsp,
+			Err:  makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(SetTags rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeCorruptKeys:
+		jsutil.LogDebug("Server.OnMessage(CorruptKeys req)")
+		count, err := s.mgr.CorruptKeys(ctx)
+		jsutil.LogDebug("Server.OnMessage(CorruptKeys rsp): count=%d, err=%v", count, err)
+		rsp := rspCorruptKeys{
+			Type:  msgTypeCorruptKeysRsp,
+			Count: count,
+			Err:   makeErrStr(err),
+		}
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeRemoveCorruptKeys:
+		jsutil.LogDebug("Server.OnMessage(RemoveCorruptKeys req)")
+		removed, err := s.mgr.RemoveCorruptKeys(ctx)
+		jsutil.LogDebug("Server.OnMessage(RemoveCorruptKeys rsp): removed=%d, err=%v", removed, err)
+		rsp := rspRemoveCorruptKeys{
+			Type:    msgTypeRemoveCorruptKeysRsp,
+			Removed: removed,
+			Err:     makeErrStr(err),
+		}
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeMasterPassphraseSet:
+		jsutil.LogDebug("Server.OnMessage(MasterPassphraseSet req)")
+		set, err := s.mgr.MasterPassphraseSet(ctx)
+		jsutil.LogDebug("Server.OnMessage(MasterPassphraseSet rsp): set=%t, err=%v", set, err)
+		rsp := rspMasterPassphraseSet{
+			Type: msgTypeMasterPassphraseSetRsp,
+			Set:  set,
+			Err:  makeErrStr(err),
+		}
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeVerifyMasterPassphrase:
+		var m msgVerifyMasterPassphrase
+		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
+			return s.makeErrorResponse(fmt.Errorf("failed to parse VerifyMasterPassphrase message: %w", err))
+		}
+		jsutil.LogDebug("Server.OnMessage(VerifyMasterPassphrase req)")
+		err := s.mgr.VerifyMasterPassphrase(ctx, m.Passphrase)
+		if err == nil {
+			s.setUnlocked(true)
+		}
+		rsp := rspVerifyMasterPassphrase{
+			Type: msgTypeVerifyMasterPassphraseRsp,
+			Err:  makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(VerifyMasterPassphrase rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeSetMasterPassphrase:
+		var m msgSetMasterPassphrase
+		if err := vert.ValueOf(headerObj).AssignTo(&m); err != nil {
+			return s.makeErrorResponse(fmt.Errorf("failed to parse SetMasterPassphrase message: %w", err))
+		}
+		jsutil.LogDebug("Server.OnMessage(SetMasterPassphrase req)")
+		err := s.mgr.SetMasterPassphrase(ctx, m.Current, m.Passphrase)
+		if err == nil {
+			// The user who set the master passphrase need not
+			// supply it again.
+			s.setUnlocked(true)
+		}
+		rsp := rspSetMasterPassphrase{
+			Type: msgTypeSetMasterPassphraseRsp,
+			Err:  makeErrStr(err),
+		}
+		jsutil.LogDebug("Server.OnMessage(SetMasterPassphrase rsp): err=%v", err)
+		return vert.ValueOf(rsp).JSValue()
+	case msgTypeLock:
+		jsutil.LogDebug("Server.OnMessage(Lock req)")
+		err := s.mgr.Lock(ctx)
+		if err == nil {
+			s.setUnlocked(false)
+		}
+		jsutil.LogDebug("Server.OnMessage(Lock rsp): err=%v", err)
+		rsp := rspLock{
+			Type: msgTypeLockRsp,
+			Err:  makeErrStr(err),
+		}
+		return vert.ValueOf(rsp).JSValue()
+	default:
+		return s.makeErrorResponse(fmt.Errorf("received invalid message type: %d", header.Type))
+	}
+}
+
+const (
+	// defaultRequestTimeout is the time after which a request that has not
+	// received a response is abandoned. It is generous, since some requests
+	// (e.g., loading a key protected by an expensive key derivation
+	// function) legitimately take a while.
+	defaultRequestTimeout = 30 * time.Second
+)
+
+// client implements the Manager interface and forwards calls to a Server.
+type client struct {
+	msg     message.Sender
+	timeout time.Duration
+
+	// parent is the client from which this client was derived by
+	// WithCancel, if any. Requests sent by this client are also tracked by
+	// its parent, so that they are cancelled along with the parent's.
+	parent *client
+
+	// mu guards fields below.
+	mu sync.Mutex
+	// nextRequest is the ID assigned to the next request sent. Only the
+	// client without a parent assigns IDs.
+	nextRequest uint64
+	// pending are the requests awaiting a response, indexed by request
+	// ID.
+	pending map[uint64]*request
+	// closed indicates that the client was closed by CloseClient, and
+	// must not send further requests.
+	closed bool
+}
+
+// NewClient returns a Manager implementation that forwards calls to a Server.
+func NewClient(msg message.Sender) Manager {
+	return NewClientWithTimeout(msg, defaultRequestTimeout)
+}
+
+// NewClientWithTimeout returns a Manager implementation that forwards calls to
+// a Server. If a request receives no response within timeout, an error is
+// returned for which IsUnreachable is true. Requests that only read state are
+// first retried once.
+func NewClientWithTimeout(msg message.Sender, timeout time.Duration) Manager {
+	return &client{msg: msg, timeout: timeout}
+}
+
+// Configured implements Manager.Configured.
+func (c *client) Configured(ctx jsutil.AsyncContext) ([]*ConfiguredKey, error) {
+	var msg msgConfigured
+	msg.Type = msgTypeConfigured
+	jsutil.LogDebug("Client.Configured(req)")
+	rspObj, err := c.sendIdempotent(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.Configured(rsp)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspConfigured
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return rsp.Keys, makeErr(rsp.Err)
+}
+
+// Loaded implements Manager.Loaded.
+func (c *client) Loaded(ctx jsutil.AsyncContext) ([]*LoadedKey, error) {
+	var msg msgLoaded
+	msg.Type = msgTypeLoaded
+	jsutil.LogDebug("Client.Loaded(req)")
+	rspObj, err := c.sendIdempotent(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.Loaded(rsp)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspLoaded
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return rsp.Keys, makeErr(rsp.Err)
+}
+
+// Add implements Manager.Add.
+func (c *client) Add(ctx jsutil.AsyncContext, name string, pemPrivateKey string, opts AddOptions) error {
+	var msg msgAdd
+	msg.Type = msgTypeAdd
+	msg.Name = name
+	msg.PEMPrivateKey = pemPrivateKey
+	msg.Options = opts
+	jsutil.LogDebug("Client.Add(req): name=%s", msg.Name)
+	rspObj, err := c.send(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.Add(rsp)")
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspAdd
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return makeErr(rsp.Err)
+}
+
+// Remove implements Manager.Remove.
+func (c *client) Remove(ctx jsutil.AsyncContext, id ID) error {
+	var msg msgRemove
+	msg.Type = msgTypeRemove
+	msg.ID = string(id)
+	jsutil.LogDebug("Client.Remove(req): id=%s", msg.ID)
+	rspObj, err := c.send(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.Remove(rsp)")
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspRemove
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return makeErr(rsp.Err)
+}
+
+// Restore implements Manager.Restore.
+func (c *client) Restore(ctx jsutil.AsyncContext, id ID) error {
+	var msg msgRestore
+	msg.Type = msgTypeRestore
+	msg.ID = string(id)
+	jsutil.LogDebug("Client.Restore(req): id=%s", msg.ID)
+	rspObj, err := c.send(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.Restore(rsp)")
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspRestore
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return makeErr(rsp.Err)
+}
+
+// DiscardRemoved implements Manager.DiscardRemoved.
+func (c *client) DiscardRemoved(ctx jsutil.AsyncContext, id ID) error {
+	var msg msgDiscardRemoved
+	msg.Type = msgTypeDiscardRemoved
+	msg.ID = string(id)
+	jsutil.LogDebug("Client.DiscardRemoved(req): id=%s", msg.ID)
+	rspObj, err := c.send(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.DiscardRemoved(rsp)")
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspDiscardRemoved
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return makeErr(rsp.Err)
+}
+
+// Load implements Manager.Load.
+func (c *client) Load(ctx jsutil.AsyncContext, id ID, passphrase string, opts LoadOptions) error {
+	var msg msgLoad
+	msg.Type = msgTypeLoad
+	msg.ID = string(id)
+	msg.Passphrase = passphrase
+	msg.Options = opts
+	jsutil.LogDebug("Client.Load(req): id=%s", msg.ID)
+	rspObj, err := c.send(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.Load(rsp)")
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspLoad
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return makeErr(rsp.Err)
+}
+
+// Unload implements Manager.Unload.
+func (c *client) Unload(ctx jsutil.AsyncContext, id ID) error {
+	var msg msgUnload
+	msg.Type = msgTypeUnload
+	msg.ID = string(id)
+	jsutil.LogDebug("Client.Unload(req): id=%s", msg.ID)
+	rspObj, err := c.send(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.Unload(rsp)")
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspUnload
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return makeErr(rsp.Err)
+}
+
+// UnloadAll implements Manager.UnloadAll.
+func (c *client) UnloadAll(ctx jsutil.AsyncContext) error {
+	var msg msgUnloadAll
+	msg.Type = msgTypeUnloadAll
+	jsutil.LogDebug("Client.UnloadAll(req)")
+	rspObj, err := c.send(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.UnloadAll(rsp)")
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspUnloadAll
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return makeErr(rsp.Err)
+}
+
+// Preferences implements Manager.Preferences.
+func (c *client) Preferences(ctx jsutil.AsyncContext) (*Preferences, error) {
+	var msg msgPreferences
+	msg.Type = msgTypePreferences
+	jsutil.LogDebug("Client.Preferences(req)")
+	rspObj, err := c.sendIdempotent(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.Preferences(rsp)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspPreferences
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return rsp.Prefs, makeErr(rsp.Err)
+}
+
+// SetPreferences implements Manager.SetPreferences.
+func (c *client) SetPreferences(ctx jsutil.AsyncContext, prefs *Preferences) error {
+	var msg msgSetPreferences
+	msg.Type = msgTypeSetPreferences
+	msg.Prefs = prefs
+	jsutil.LogDebug("Client.SetPreferences(req)")
+	rspObj, err := c.send(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.SetPreferences(rsp)")
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspSetPreferences
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return makeErr(rsp.Err)
+}
+
+// Export implements Manager.Export.
+func (c *client) Export(ctx jsutil.AsyncContext) ([]byte, error) {
+	var msg msgExport
+	msg.Type = msgTypeExport
+	jsutil.LogDebug("Client.Export(req)")
+	rspObj, err := c.sendIdempotent(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.Export(rsp)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspExport
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return []byte(rsp.Data), makeErr(rsp.Err)
+}
+
+// ExportPublicKeys implements Manager.ExportPublicKeys.
+func (c *client) ExportPublicKeys(ctx jsutil.AsyncContext) (string, error) {
+	var msg msgExportPublicKeys
+	msg.Type = msgTypeExportPublicKeys
+	jsutil.LogDebug("Client.ExportPublicKeys(req)")
+	rspObj, err := c.sendIdempotent(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.ExportPublicKeys(rsp)")
+	if err != nil {
+		return "", fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspExportPublicKeys
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	return rsp.Text, makeErr(rsp.Err)
+}
+
+// AdoptLoaded implements Manager.AdoptLoaded.
+func (c *client) AdoptLoaded(ctx jsutil.AsyncContext, key *LoadedKey, name string) error {
+	var msg msgAdoptLoaded
+	msg.Type = msgTypeAdoptLoaded
+	msg.Key = key
+	msg.Name = name
+	jsutil.LogDebug("Client.AdoptLoaded(req): name=%s", msg.Name)
+	rspObj, err := c.send(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.AdoptLoaded(rsp)")
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspAdoptLoaded
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return makeErr(rsp.Err)
+}
+
+// Extensions implements Manager.Extensions.
+func (c *client) Extensions(ctx jsutil.AsyncContext) ([]string, error) {
+	var msg msgExtensions
+	msg.Type = msgTypeExtensions
+	jsutil.LogDebug("Client.Extensions(req)")
+	rspObj, err := c.sendIdempotent(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.Extensions(rsp)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspExtensions
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return rsp.Names, makeErr(rsp.Err)
+}
+
+// LockAgent implements Manager.LockAgent.
+func (c *client) LockAgent(ctx jsutil.AsyncContext, passphrase string) error {
+	var msg msgLockAgent
+	msg.Type = msgTypeLockAgent
+	msg.Passphrase = passphrase
+	jsutil.LogDebug("Client.LockAgent(req)")
+	rspObj, err := c.send(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.LockAgent(rsp)")
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspLockAgent
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return makeErr(rsp.Err)
+}
+
+// UnlockAgent implements Manager.UnlockAgent.
+func (c *client) UnlockAgent(ctx jsutil.AsyncContext, passphrase string) error {
+	var msg msgUnlockAgent
+	msg.Type = msgTypeUnlockAgent
+	msg.Passphrase = passphrase
+	jsutil.LogDebug("Client.UnlockAgent(req)")
+	rspObj, err := c.send(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.UnlockAgent(rsp)")
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspUnlockAgent
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return makeErr(rsp.Err)
+}
+
+// AgentLocked implements Manager.AgentLocked.
+func (c *client) AgentLocked(ctx jsutil.AsyncContext) (bool, error) {
+	var msg msgAgentLocked
+	msg.Type = msgTypeAgentLocked
+	jsutil.LogDebug("Client.AgentLocked(req)")
+	rspObj, err := c.sendIdempotent(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.AgentLocked(rsp)")
+	if err != nil {
+		return false, fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspAgentLocked
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return false, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return rsp.Locked, makeErr(rsp.Err)
+}
+
+// SetPriority implements Manager.SetPriority.
+func (c *client) SetPriority(ctx jsutil.AsyncContext, id ID, priority int) error {
+	var msg msgSetPriority
+	msg.Type = msgTypeSetPriority
+	msg.ID = string(id)
+	msg.Priority = priority
+	jsutil.LogDebug("Client.SetPriority(req): id=%s, priority=%d", msg.ID, msg.Priority)
+	rspObj, err := c.send(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.SetPriority(rsp)")
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspSetPriority
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return makeErr(rsp.Err)
+}
+
+// ExportPrivate implements Manager.ExportPrivate.
+func (c *client) ExportPrivate(ctx jsutil.AsyncContext, id ID, passphrase, newPassphrase string) (string, error) {
+	var msg msgExportPrivate
+	msg.Type = msgTypeExportPrivate
+	msg.ID = string(id)
+	msg.Passphrase = passphrase
+	msg.NewPassphrase = newPassphrase
+	jsutil.LogDebug("Client.ExportPrivate(req): id=%s", msg.ID)
+	rspObj, err := c.send(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.ExportPrivate(rsp)")
+	if err != nil {
+		return "", fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspExportPrivate
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	return rsp.PEMPrivateKey, makeErr(rsp.Err)
+}
+
+// SetNote implements Manager.SetNote.
+func (c *client) SetNote(ctx jsutil.AsyncContext, id ID, note string) error {
+	var msg msgSetNote
+	msg.Type = msgTypeSetNote
+	msg.ID = string(id)
+	msg.Note = note
+	jsutil.LogDebug("Client.SetNote(req): id=%s", msg.ID)
+	rspObj, err := c.send(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.SetNote(rsp)")
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspSetNote
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return makeErr(rsp.Err)
+}
+
+// Import implements Manager.Import.
+func (c *client) Import(ctx jsutil.AsyncContext, data []byte) (*ImportResult, error) {
+	var msg msgImport
+	msg.Type = msgTypeImport
+	msg.Data = string(data)
+	jsutil.LogDebug("Client.Import(req)")
+	rspObj, err := c.send(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.Import(rsp)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspImport
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return rsp.Result, makeErr(rsp.Err)
+}
+
+// SetPositions implements Manager.SetPositions.
+func (c *client) SetPositions(ctx jsutil.AsyncContext, ids []ID) error {
+	var msg msgSetPositions
+	msg.Type = msgTypeSetPositions
+	for _, id := range ids {
+		msg.IDs = append(msg.IDs, string(id))
+	}
+	jsutil.LogDebug("Client.SetPositions(req): ids=%v", msg.IDs)
+	rspObj, err := c.send(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.SetPositions(rsp)")
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspSetPositions
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return makeErr(rsp.Err)
+}
+
+// SetRSASignatureAlgorithm implements Manager.SetRSASignatureAlgorithm.
+func (c *client) SetRSASignatureAlgorithm(ctx jsutil.AsyncContext, id ID, alg string) error {
+	var msg msgSetRSASignatureAlgorithm
+	msg.Type = msgTypeSetRSASignatureAlgorithm
+	msg.ID = string(id)
+	msg.Algorithm = alg
+	jsutil.LogDebug("Client.SetRSASignatureAlgorithm(req): id=%s, algorithm=%s", msg.ID, msg.Algorithm)
+	rspObj, err := c.send(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.SetRSASignatureAlgorithm(rsp)")
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspSetRSASignatureAlgorithm
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return makeErr(rsp.Err)
+}
+
+// KeyStorage implements Manager.KeyStorage.
+func (c *client) KeyStorage(ctx jsutil.AsyncContext) (string, error) {
+	var msg msgKeyStorage
+	msg.Type = msgTypeKeyStorage
+	jsutil.LogDebug("Client.KeyStorage(req)")
+	rspObj, err := c.sendIdempotent(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.KeyStorage(rsp)")
+	if err != nil {
+		return "", fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspKeyStorage
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	return rsp.Location, makeErr(rsp.Err)
+}
+
+// SetKeyStorage implements Manager.SetKeyStorage.
+func (c *client) SetKeyStorage(ctx jsutil.AsyncContext, location string) error {
+	var msg msgSetKeyStorage
+	msg.Type = msgTypeSetKeyStorage
+	msg.Location = location
+	jsutil.LogDebug("Client.SetKeyStorage(req): location=%s", msg.Location)
+	rspObj, err := c.send(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.SetKeyStorage(rsp)")
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspSetKeyStorage
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return makeErr(rsp.Err)
+}
+
+// StorageUsage implements Manager.StorageUsage.
+func (c *client) StorageUsage(ctx jsutil.AsyncContext) (*StorageUsage, error) {
+	var msg msgStorageUsage
+	msg.Type = msgTypeStorageUsage
+	jsutil.LogDebug("Client.StorageUsage(req)")
+	rspObj, err := c.sendIdempotent(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.StorageUsage(rsp)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspStorageUsage
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return rsp.Usage, makeErr(rsp.Err)
+}
+
+// AddMany implements Manager.AddMany.
+func (c *client) AddMany(ctx jsutil.AsyncContext, keys []*NewKey) ([]error, error) {
+	var msg msgAddMany
+	msg.Type = msgTypeAddMany
+	msg.Keys = keys
+	jsutil.LogDebug("Client.AddMany(req): keys=%d", len(msg.Keys))
+	rspObj, err := c.send(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.AddMany(rsp)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspAddMany
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return makeErrs(rsp.Errs), makeErr(rsp.Err)
+}
+
+// RemoveMany implements Manager.RemoveMany.
+func (c *client) RemoveMany(ctx jsutil.AsyncContext, ids []ID) ([]error, error) {
+	var msg msgRemoveMany
+	msg.Type = msgTypeRemoveMany
+	for _, id := range ids {
+		msg.IDs = append(msg.IDs, string(id))
+	}
+	jsutil.LogDebug("Client.RemoveMany(req): ids=%v", msg.IDs)
+	rspObj, err := c.send(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.RemoveMany(rsp)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspRemoveMany
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return makeErrs(rsp.Errs), makeErr(rsp.Err)
+}
+
+const (
+	// pingTimeout is the time after which a server that has not responded
+	// to a ping is considered unreachable. This allows time for the browser
+	// to start a suspended background worker.
+	pingTimeout = 5 * time.Second
+)
+
+var (
+	errUnreachable = errors.New("agent unreachable")
+	errCancelled   = errors.New("request cancelled")
+)
+
+// IsUnreachable determines if an error returned by a Manager indicates that
+// the server could not be reached, as opposed to the request failing.
+func IsUnreachable(err error) bool {
+	return errors.Is(err, errUnreachable)
+}
+
+// IsCancelled determines if an error returned by a Manager indicates that
+// the request was cancelled by CancelRequests before a response was
+// received. The request may still complete on the server.
+func IsCancelled(err error) bool {
+	return errors.Is(err, errCancelled)
+}
+
+// CancelRequests abandons all requests that are awaiting a response from the
+// server, if the manager is a client returned by NewClient or WithCancel. The
+// abandoned requests return an error for which IsCancelled is true; any
+// response subsequently received for them is discarded. This allows the
+// caller to recover if the server never responds.
+func CancelRequests(mgr Manager) {
+	c, ok := mgr.(*client)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, req := range c.pending {
+		jsutil.LogDebug("Client.CancelRequests: cancelling request %d", id)
+		req.cancel()
+		delete(c.pending, id)
+	}
+}
+
+// WithCancel returns a Manager that sends requests through mgr, along with a
+// function that abandons those of its requests awaiting a response, as by
+// CancelRequests. Other requests sent through mgr are unaffected, while
+// cancelling or closing mgr also cancels requests sent through the returned
+// Manager. If mgr is not a client returned by NewClient, it is returned
+// unchanged, and the function has no effect.
+func WithCancel(mgr Manager) (Manager, func()) {
+	c, ok := mgr.(*client)
+	if !ok {
+		return mgr, func() {}
+	}
+
+	scoped := &client{msg: c.msg, timeout: c.timeout, parent: c}
+	return scoped, func() { CancelRequests(scoped) }
+}
+
+// CloseClient releases a client returned by NewClient that is no longer
+// needed (e.g., because it is being replaced after the server stopped
+// responding). Requests awaiting a response are cancelled as by
+// CancelRequests, and subsequent requests fail with an error for which
+// IsCancelled is true, rather than being sent.
+func CloseClient(mgr Manager) {
+	c, ok := mgr.(*client)
+	if !ok {
+		return
+	}
+
+	CancelRequests(c)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+}
+
+// request is a request awaiting a response.
+type request struct {
+	once sync.Once
+	// cancelled is closed if the request is cancelled.
+	cancelled chan struct{}
+}
+
+// cancel abandons the request. A request is tracked by each of the clients
+// through which it was sent, so it may be cancelled more than once.
+func (r *request) cancel() {
+	r.once.Do(func() { close(r.cancelled) })
+}
+
+// isClosed indicates whether the client, or any client from which it was
+// derived, was closed by CloseClient.
+func (c *client) isClosed() bool {
+	for t := c; t != nil; t = t.parent {
+		t.mu.Lock()
+		closed := t.closed
+		t.mu.Unlock()
+		if closed {
+			return true
+		}
+	}
+	return false
+}
+
+// track records a request awaiting a response with the client and any
+// clients from which it was derived, returning the request's ID and the
+// request.
+func (c *client) track() (uint64, *request) {
+	root := c
+	for root.parent != nil {
+		root = root.parent
+	}
+	root.mu.Lock()
+	root.nextRequest++
+	id := root.nextRequest
+	root.mu.Unlock()
+
+	req := &request{cancelled: make(chan struct{})}
+	for t := c; t != nil; t = t.parent {
+		t.mu.Lock()
+		if t.pending == nil {
+			t.pending = make(map[uint64]*request)
+		}
+		t.pending[id] = req
+		t.mu.Unlock()
+	}
+	return id, req
+}
+
+// untrack records that a request is no longer awaiting a response.
+func (c *client) untrack(id uint64) {
+	for t := c; t != nil; t = t.parent {
+		t.mu.Lock()
+		delete(t.pending, id)
+		t.mu.Unlock()
+	}
+}
+
+// sendWithTimeout sends a message to the server, returning the response. An
+// error wrapping errUnreachable is returned if the message cannot be sent or
+// no response is received within the timeout, and an error wrapping
+// errCancelled if the request is cancelled first.
+func (c *client) sendWithTimeout(ctx jsutil.AsyncContext, msg js.Value, timeout time.Duration) (js.Value, error) {
+	if c.isClosed() {
+		return js.Undefined(), fmt.Errorf("%w: client closed", errCancelled)
+	}
+
+	id, req := c.track()
+	defer c.untrack(id)
+
+	type result struct {
+		rspObj js.Value
+		err    error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		rspObj, err := c.msg.Send(ctx, msg)
+		ch <- result{rspObj: rspObj, err: err}
+	}()
+
+	expired := time.After(timeout)
+	select {
+	case res := <-ch:
+		if res.err != nil {
+			return js.Undefined(), fmt.Errorf("%w: %w", errUnreachable, res.err)
+		}
+		return res.rspObj, nil
+	case <-req.cancelled:
+		// Wait for a late response no longer than the request would
+		// otherwise have waited.
+		go func() {
+			select {
+			case res := <-ch:
+				if res.err == nil {
+					jsutil.LogDebug("Client: discarding late response to cancelled request %d", id)
+				}
+			case <-expired:
+			}
+		}()
+		return js.Undefined(), fmt.Errorf("%w: request %d", errCancelled, id)
+	case <-expired:
+		return js.Undefined(), fmt.Errorf("%w: no response within %s", errUnreachable, timeout)
+	}
+}
+
+// send sends a message to the server, returning the response. The message is
+// sent only once: if no response is received, the request may still be in
+// progress (e.g., loading a key protected by an expensive key derivation
+// function), and repeating it may not be safe.
+func (c *client) send(ctx jsutil.AsyncContext, msg js.Value) (js.Value, error) {
+	return c.sendWithTimeout(ctx, msg, c.timeout)
+}
+
+// sendIdempotent sends a message that may safely be delivered more than once
+// (e.g., a request that only reads state), returning the response. If the
+// server is unreachable (e.g., the message was dropped while the background
+// worker was suspended), the worker is woken and the message is sent once
+// more.
+func (c *client) sendIdempotent(ctx jsutil.AsyncContext, msg js.Value) (js.Value, error) {
+	rspObj, err := c.send(ctx, msg)
+	if !IsUnreachable(err) {
+		return rspObj, err
+	}
+	jsutil.LogDebug("Client.sendIdempotent: %v; retrying", err)
+	c.wake(ctx)
+	return c.send(ctx, msg)
+}
+
+// wake pings the server, prompting the browser to start the background
+// worker if it is suspended. The outcome is ignored; the caller is expected
+// to retry its own request.
+func (c *client) wake(ctx jsutil.AsyncContext) {
+	var msg msgPing
+	msg.Type = msgTypePing
+	jsutil.LogDebug("Client.wake(req)")
+	_, err := c.sendWithTimeout(ctx, vert.ValueOf(msg).JSValue(), min(c.timeout, pingTimeout))
+	jsutil.LogDebug("Client.wake(rsp): err=%v", err)
+}
+
+// Ping implements Manager.Ping.
+func (c *client) Ping(ctx jsutil.AsyncContext) error {
+	var msg msgPing
+	msg.Type = msgTypePing
+	jsutil.LogDebug("Client.Ping(req)")
+	rspObj, err := c.sendWithTimeout(ctx, vert.ValueOf(msg).JSValue(), pingTimeout)
+	jsutil.LogDebug("Client.Ping(rsp)")
+	if err != nil {
+		return err
+	}
+	var rsp rspPing
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return makeErr(rsp.Err)
+}
+
+// MarkUsed implements Manager.MarkUsed.
+func (c *client) MarkUsed(ctx jsutil.AsyncContext, id ID) error {
+	var msg msgMarkUsed
+	msg.Type = msgTypeMarkUsed
+	msg.ID = string(id)
+	jsutil.LogDebug("Client.MarkUsed(req): id=%s", msg.ID)
+	rspObj, err := c.send(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.MarkUsed(rsp)")
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspMarkUsed
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return makeErr(rsp.Err)
+}
+
+// Reencrypt implements Manager.Reencrypt.
+func (c *client) Reencrypt(ctx jsutil.AsyncContext, id ID, oldPassphrase, newPassphrase string) error {
+	var msg msgReencrypt
+	msg.Type = msgTypeReencrypt
+	msg.ID = string(id)
+	msg.OldPassphrase = oldPassphrase
+	msg.NewPassphrase = newPassphrase
+	jsutil.LogDebug("Client.Reencrypt(req): id=%s", msg.ID)
+	rspObj, err := c.send(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.Reencrypt(rsp)")
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspReencrypt
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return makeErr(rsp.Err)
+}
+
+// SetDisabled implements Manager.SetDisabled.
+func (c *client) SetDisabled(ctx jsutil.AsyncContext, id ID, disabled bool) error {
+	var msg msgSetDisabled
+	msg.Type = msgTypeSetDisabled
+	msg.ID = string(id)
+	msg.Disabled = disabled
+	jsutil.LogDebug("Client.SetDisabled(req): id=%s, disabled=%t", msg.ID, msg.Disabled)
+	rspObj, err := c.send(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.SetDisabled(rsp)")
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspSetDisabled
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return makeErr(rsp.Err)
+}
+
+// SetAutoLoad implements Manager.SetAutoLoad.
+func (c *client) SetAutoLoad(ctx jsutil.AsyncContext, id ID, autoLoad bool) error {
+	var msg msgSetAutoLoad
+	msg.Type = msgTypeSetAutoLoad
+	msg.ID = string(id)
+	msg.AutoLoad = autoLoad
+	jsutil.LogDebug("Client.SetAutoLoad(req): id=%s, autoLoad=%t", msg.ID, msg.AutoLoad)
+	rspObj, err := c.send(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.SetAutoLoad(rsp)")
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspSetAutoLoad
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return makeErr(rsp.Err)
+}
+
+// ForgetPassphrases implements Manager.ForgetPassphrases.
+func (c *client) ForgetPassphrases(ctx jsutil.AsyncContext) error {
+	var msg msgForgetPassphrases
+	msg.Type = msgTypeForgetPassphrases
+	jsutil.LogDebug("Client.ForgetPassphrases(req)")
+	rspObj, err := c.send(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.ForgetPassphrases(rsp)")
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspForgetPassphrases
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return makeErr(rsp.Err)
+}
+
+// AppendAuditLog implements Manager.AppendAuditLog.
+func (c *client) AppendAuditLog(ctx jsutil.AsyncContext, entry *AuditEntry) error {
+	var msg msgAppendAuditLog
+	msg.Type = msgTypeAppendAuditLog
+	msg.Entry = entry
+	jsutil.LogDebug("Client.AppendAuditLog(req)")
+	rspObj, err := c.send(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.AppendAuditLog(rsp)")
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspAppendAuditLog
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return makeErr(rsp.Err)
+}
+
+// AuditLog implements Manager.AuditLog.
+func (c *client) AuditLog(ctx jsutil.AsyncContext) ([]*AuditEntry, error) {
+	var msg msgAuditLog
+	msg.Type = msgTypeAuditLog
+	jsutil.LogDebug("Client.AuditLog(req)")
+	rspObj, err := c.sendIdempotent(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.AuditLog(rsp)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspAuditLog
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return rsp.Entries, makeErr(rsp.Err)
+}
+
+// ClearAuditLog implements Manager.ClearAuditLog.
+func (c *client) ClearAuditLog(ctx jsutil.AsyncContext) error {
+	var msg msgClearAuditLog
+	msg.Type = msgTypeClearAuditLog
+	jsutil.LogDebug("Client.ClearAuditLog(req)")
+	rspObj, err := c.send(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.ClearAuditLog(rsp)")
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspClearAuditLog
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return makeErr(rsp.Err)
+}
+
+// AddBundle implements Manager.AddBundle.
+func (c *client) AddBundle(ctx jsutil.AsyncContext, name string, bundle string, opts AddOptions) ([]error, error) {
+	var msg msgAddBundle
+	msg.Type = msgTypeAddBundle
+	msg.Name = name
+	msg.Bundle = bundle
+	msg.Options = opts
+	jsutil.LogDebug("Client.AddBundle(req): name=%s", msg.Name)
+	rspObj, err := c.send(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.AddBundle(rsp)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspAddBundle
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return makeErrs(rsp.Errs), makeErr(rsp.Err)
+}
+
+// TestSign implements Manager.TestSign.
+func (c *client) TestSign(ctx jsutil.AsyncContext, key *LoadedKey) error {
+	var msg msgTestSign
+	msg.Type = msgTypeTestSign
+	msg.Key = key
+	jsutil.LogDebug("Client.TestSign(req): comment=%s", msg.Key.Comment)
+	rspObj, err := c.sendIdempotent(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.TestSign(rsp)")
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspTestSign
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return makeErr(rsp.Err)
+}
+
+// NativeHostStatus implements Manager.NativeHostStatus.
+func (c *client) NativeHostStatus(ctx jsutil.AsyncContext) (*NativeHostStatus, error) {
+	var msg msgNativeHostStatus
+	msg.Type = msgTypeNativeHostStatus
+	jsutil.LogDebug("Client.NativeHostStatus(req)")
+	rspObj, err := c.sendIdempotent(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.NativeHostStatus(rsp)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspNativeHostStatus
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return rsp.Status, makeErr(rsp.Err)
+}
+
+// SetAllowedOrigins implements Manager.SetAllowedOrigins.
+func (c *client) SetAllowedOrigins(ctx jsutil.AsyncContext, id ID, origins []string) error {
+	var msg msgSetAllowedOrigins
+	msg.Type = msgTypeSetAllowedOrigins
+	msg.ID = string(id)
+	msg.Origins = origins
+	jsutil.LogDebug("Client.SetAllowedOrigins(req): id=%s, origins=%v", msg.ID, msg.Origins)
+	rspObj, err := c.send(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.SetAllowedOrigins(rsp)")
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspSetAllowedOrigins
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return makeErr(rsp.Err)
+}
+
+// SetTags implements Manager.SetTags.
+func (c *client) SetTags(ctx jsutil.AsyncContext, id ID, tags []string) error {
+	var msg msgSetTags
+	msg.Type = msgTypeSetTags
+	msg.ID = string(id)
+	msg.Tags = tags
+	jsutil.LogDebug("Client.SetTags(req): id=%s, tags=%v", msg.ID, msg.Tags)
+	rspObj, err := c.send(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.SetTags(rsp)")
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspSetTags
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return makeErr(rsp.Err)
+}
+
+// CorruptKeys implements Manager.CorruptKeys.
+func (c *client) CorruptKeys(ctx jsutil.AsyncContext) (int, error) {
+	var msg msgCorruptKeys
+	msg.Type = msgTypeCorruptKeys
+	jsutil.LogDebug("Client.CorruptKeys(req)")
+	rspObj, err := c.sendIdempotent(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.CorruptKeys(rsp)")
+	if err != nil {
+		return 0, fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspCorruptKeys
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return 0, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return rsp.Count, makeErr(rsp.Err)
+}
+
+// RemoveCorruptKeys implements Manager.RemoveCorruptKeys.
+func (c *client) RemoveCorruptKeys(ctx jsutil.AsyncContext) (int, error) {
+	var msg msgRemoveCorruptKeys
+	msg.Type = msgTypeRemoveCorruptKeys
+	jsutil.LogDebug("Client.RemoveCorruptKeys(req)")
+	rspObj, err := c.send(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.RemoveCorruptKeys(rsp)")
+	if err != nil {
+		return 0, fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspRemoveCorruptKeys
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return 0, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return rsp.Removed, makeErr(rsp.Err)
+}
+
+// MasterPassphraseSet implements Manager.MasterPassphraseSet.
+func (c *client) MasterPassphraseSet(ctx jsutil.AsyncContext) (bool, error) {
+	var msg msgMasterPassphraseSet
+	msg.Type = msgTypeMasterPassphraseSet
+	jsutil.LogDebug("Client.MasterPassphraseSet(req)")
+	rspObj, err := c.sendIdempotent(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.MasterPassphraseSet(rsp)")
+	if err != nil {
+		return false, fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspMasterPassphraseSet
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return false, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return rsp.Set, makeErr(rsp.Err)
+}
+
+// VerifyMasterPassphrase implements Manager.VerifyMasterPassphrase.
+func (c *client) VerifyMasterPassphrase(ctx jsutil.AsyncContext, passphrase string) error {
+	var msg msgVerifyMasterPassphrase
+	msg.Type = msgTypeVerifyMasterPassphrase
+	msg.Passphrase = passphrase
+	jsutil.LogDebug("Client.VerifyMasterPassphrase(req)")
+	rspObj, err := c.sendIdempotent(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.VerifyMasterPassphrase(rsp)")
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspVerifyMasterPassphrase
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return makeErr(rsp.Err)
+}
+
+// SetMasterPassphrase implements Manager.SetMasterPassphrase.
+func (c *client) SetMasterPassphrase(ctx jsutil.AsyncContext, current, passphrase string) error {
+	var msg msgSetMasterPassphrase
+	msg.Type = msgTypeSetMasterPassphrase
+	msg.Current = current
+	msg.Passphrase = passphrase
+	jsutil.LogDebug("Client.SetMasterPassphrase(req)")
+	rspObj, err := c.send(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.SetMasterPassphrase(rsp)")
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspSetMasterPassphrase
+	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return makeErr(rsp.Err)
+}
+
+// Lock implements Manager.Lock.
+func (c *client) Lock(ctx jsutil.AsyncContext) error {
+	var msg msgLock
+	msg.Type = msgTypeLock
+	jsutil.LogDebug("Client.Lock(req)")
+	rspObj, err := c.sendIdempotent(ctx, vert.ValueOf(msg).JSValue())
+	jsutil.LogDebug("Client.Lock(rsp)")
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	var rsp rspLock
 	if err := vert.ValueOf(rspObj).AssignTo(&rsp); err != nil {
 		return fmt.Errorf("failed to parse response: %w", err)
 	}
@@ -15,23 +15,59 @@
 package keys
 
 import (
+	"crypto/x509"
 	"errors"
+	"fmt"
+	"syscall/js"
 	"testing"
+	"time"
 
 	"github.com/google/chrome-ssh-agent/go/jsutil"
 	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/ppk"
+	"github.com/google/chrome-ssh-agent/go/message"
 	mfakes "github.com/google/chrome-ssh-agent/go/message/fakes"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 )
 
 type dummyManager struct {
 	ID             ID
+	IDs            []ID
 	Name           string
 	PEMPrivateKey  string
+	AddOptions     AddOptions
 	Passphrase     string
+	NewPassphrase  string
+	LoadOptions    LoadOptions
 	ConfiguredKeys []*ConfiguredKey
 	LoadedKeys     []*LoadedKey
 	Key            *LoadedKey
+	Prefs          *Preferences
+	Data           []byte
+	ImportResult   *ImportResult
+	UnloadedAll    bool
+	Disabled       bool
+	AutoLoad       bool
+	Origins        []string
+	Tags           []string
+	Priority       int
+	Note           string
+	ExtensionNames []string
+	AgentIsLocked  bool
+	Count          int
+	Forgot         bool
+	AuditEntries   []*AuditEntry
+	AuditCleared   bool
+	NativeHost     *NativeHostStatus
+	Algorithm      string
+	Location       string
+	Usage          *StorageUsage
+	NewKeys        []*NewKey
+	MasterSet      bool
+	MasterErr      error
+	Locked         bool
+	Errs           []error
 	Err            error
 }
 
@@ -39,9 +75,10 @@ func (m *dummyManager) Configured(_ jsutil.AsyncContext) ([]*ConfiguredKey, erro
 	return m.ConfiguredKeys, m.Err
 }
 
-func (m *dummyManager) Add(_ jsutil.AsyncContext, name string, pemPrivateKey string) error {
+func (m *dummyManager) Add(_ jsutil.AsyncContext, name string, pemPrivateKey string, opts AddOptions) error {
 	m.Name = name
 	m.PEMPrivateKey = pemPrivateKey
+	m.AddOptions = opts
 	return m.Err
 }
 
@@ -50,13 +87,24 @@ func (m *dummyManager) Remove(_ jsutil.AsyncContext, id ID) error {
 	return m.Err
 }
 
+func (m *dummyManager) Restore(_ jsutil.AsyncContext, id ID) error {
+	m.ID = id
+	return m.Err
+}
+
+func (m *dummyManager) DiscardRemoved(_ jsutil.AsyncContext, id ID) error {
+	m.ID = id
+	return m.Err
+}
+
 func (m *dummyManager) Loaded(_ jsutil.AsyncContext) ([]*LoadedKey, error) {
 	return m.LoadedKeys, m.Err
 }
 
-func (m *dummyManager) Load(_ jsutil.AsyncContext, id ID, passphrase string) error {
+func (m *dummyManager) Load(_ jsutil.AsyncContext, id ID, passphrase string, opts LoadOptions) error {
 	m.ID = id
 	m.Passphrase = passphrase
+	m.LoadOptions = opts
 	return m.Err
 }
 
@@ -65,6 +113,213 @@ func (m *dummyManager) Unload(_ jsutil.AsyncContext, id ID) error {
 	return m.Err
 }
 
+func (m *dummyManager) UnloadAll(_ jsutil.AsyncContext) error {
+	m.UnloadedAll = true
+	return m.Err
+}
+
+func (m *dummyManager) Preferences(_ jsutil.AsyncContext) (*Preferences, error) {
+	return m.Prefs, m.Err
+}
+
+func (m *dummyManager) SetPreferences(_ jsutil.AsyncContext, prefs *Preferences) error {
+	m.Prefs = prefs
+	return m.Err
+}
+
+func (m *dummyManager) SetPositions(_ jsutil.AsyncContext, ids []ID) error {
+	m.IDs = ids
+	return m.Err
+}
+
+func (m *dummyManager) SetRSASignatureAlgorithm(_ jsutil.AsyncContext, id ID, alg string) error {
+	m.ID = id
+	m.Algorithm = alg
+	return m.Err
+}
+
+func (m *dummyManager) KeyStorage(_ jsutil.AsyncContext) (string, error) {
+	return m.Location, m.Err
+}
+
+func (m *dummyManager) SetKeyStorage(_ jsutil.AsyncContext, location string) error {
+	m.Location = location
+	return m.Err
+}
+
+func (m *dummyManager) StorageUsage(_ jsutil.AsyncContext) (*StorageUsage, error) {
+	return m.Usage, m.Err
+}
+
+func (m *dummyManager) AddMany(_ jsutil.AsyncContext, keys []*NewKey) ([]error, error) {
+	m.NewKeys = keys
+	return m.Errs, m.Err
+}
+
+func (m *dummyManager) AddBundle(_ jsutil.AsyncContext, name string, bundle string, opts AddOptions) ([]error, error) {
+	m.Name = name
+	m.PEMPrivateKey = bundle
+	m.AddOptions = opts
+	return m.Errs, m.Err
+}
+
+func (m *dummyManager) RemoveMany(_ jsutil.AsyncContext, ids []ID) ([]error, error) {
+	m.IDs = ids
+	return m.Errs, m.Err
+}
+
+func (m *dummyManager) Ping(_ jsutil.AsyncContext) error {
+	return m.Err
+}
+
+func (m *dummyManager) MarkUsed(_ jsutil.AsyncContext, id ID) error {
+	m.ID = id
+	return m.Err
+}
+
+func (m *dummyManager) Reencrypt(_ jsutil.AsyncContext, id ID, oldPassphrase, newPassphrase string) error {
+	m.ID = id
+	m.Passphrase = oldPassphrase
+	m.NewPassphrase = newPassphrase
+	return m.Err
+}
+
+func (m *dummyManager) SetDisabled(_ jsutil.AsyncContext, id ID, disabled bool) error {
+	m.ID = id
+	m.Disabled = disabled
+	return m.Err
+}
+
+func (m *dummyManager) SetAutoLoad(_ jsutil.AsyncContext, id ID, autoLoad bool) error {
+	m.ID = id
+	m.AutoLoad = autoLoad
+	return m.Err
+}
+
+func (m *dummyManager) SetAllowedOrigins(_ jsutil.AsyncContext, id ID, origins []string) error {
+	m.ID = id
+	m.Origins = origins
+	return m.Err
+}
+
+func (m *dummyManager) SetTags(_ jsutil.AsyncContext, id ID, tags []string) error {
+	m.ID = id
+	m.Tags = tags
+	return m.Err
+}
+
+func (m *dummyManager) SetPriority(_ jsutil.AsyncContext, id ID, priority int) error {
+	m.ID = id
+	m.Priority = priority
+	return m.Err
+}
+
+func (m *dummyManager) SetNote(_ jsutil.AsyncContext, id ID, note string) error {
+	m.ID = id
+	m.Note = note
+	return m.Err
+}
+
+func (m *dummyManager) ExportPrivate(_ jsutil.AsyncContext, id ID, passphrase, newPassphrase string) (string, error) {
+	m.ID = id
+	m.Passphrase = passphrase
+	m.NewPassphrase = newPassphrase
+	return m.PEMPrivateKey, m.Err
+}
+
+func (m *dummyManager) CorruptKeys(_ jsutil.AsyncContext) (int, error) {
+	return m.Count, m.Err
+}
+
+func (m *dummyManager) RemoveCorruptKeys(_ jsutil.AsyncContext) (int, error) {
+	return m.Count, m.Err
+}
+
+func (m *dummyManager) MasterPassphraseSet(_ jsutil.AsyncContext) (bool, error) {
+	return m.MasterSet, m.MasterErr
+}
+
+func (m *dummyManager) VerifyMasterPassphrase(_ jsutil.AsyncContext, passphrase string) error {
+	m.Passphrase = passphrase
+	return m.Err
+}
+
+func (m *dummyManager) SetMasterPassphrase(_ jsutil.AsyncContext, current, passphrase string) error {
+	m.Passphrase = current
+	m.NewPassphrase = passphrase
+	return m.Err
+}
+
+func (m *dummyManager) Lock(_ jsutil.AsyncContext) error {
+	m.Locked = true
+	return m.Err
+}
+
+func (m *dummyManager) ForgetPassphrases(_ jsutil.AsyncContext) error {
+	m.Forgot = true
+	return m.Err
+}
+
+func (m *dummyManager) TestSign(_ jsutil.AsyncContext, key *LoadedKey) error {
+	m.Key = key
+	return m.Err
+}
+
+func (m *dummyManager) AdoptLoaded(_ jsutil.AsyncContext, key *LoadedKey, name string) error {
+	m.Key = key
+	m.Name = name
+	return m.Err
+}
+
+func (m *dummyManager) Extensions(_ jsutil.AsyncContext) ([]string, error) {
+	return m.ExtensionNames, m.Err
+}
+
+func (m *dummyManager) LockAgent(_ jsutil.AsyncContext, passphrase string) error {
+	m.Passphrase = passphrase
+	return m.Err
+}
+
+func (m *dummyManager) UnlockAgent(_ jsutil.AsyncContext, passphrase string) error {
+	m.Passphrase = passphrase
+	return m.Err
+}
+
+func (m *dummyManager) AgentLocked(_ jsutil.AsyncContext) (bool, error) {
+	return m.AgentIsLocked, m.Err
+}
+
+func (m *dummyManager) NativeHostStatus(_ jsutil.AsyncContext) (*NativeHostStatus, error) {
+	return m.NativeHost, m.Err
+}
+
+func (m *dummyManager) AppendAuditLog(_ jsutil.AsyncContext, entry *AuditEntry) error {
+	m.AuditEntries = append(m.AuditEntries, entry)
+	return m.Err
+}
+
+func (m *dummyManager) AuditLog(_ jsutil.AsyncContext) ([]*AuditEntry, error) {
+	return m.AuditEntries, m.Err
+}
+
+func (m *dummyManager) ClearAuditLog(_ jsutil.AsyncContext) error {
+	m.AuditCleared = true
+	return m.Err
+}
+
+func (m *dummyManager) Export(_ jsutil.AsyncContext) ([]byte, error) {
+	return m.Data, m.Err
+}
+
+func (m *dummyManager) ExportPublicKeys(_ jsutil.AsyncContext) (string, error) {
+	return string(m.Data), m.Err
+}
+
+func (m *dummyManager) Import(_ jsutil.AsyncContext, data []byte) (*ImportResult, error) {
+	m.Data = data
+	return m.ImportResult, m.Err
+}
+
 func TestClientServerConfigured(t *testing.T) {
 	t.Parallel()
 
@@ -112,17 +367,21 @@ func TestClientServerAdd(t *testing.T) {
 
 		wantName := "some-name"
 		wantPrivateKey := "private-key"
+		wantOptions := AddOptions{ConfirmBeforeUse: true}
 		wantErr := errors.New("failed")
 
 		mgr.Err = wantErr
 
-		err := cli.Add(ctx, wantName, wantPrivateKey)
+		err := cli.Add(ctx, wantName, wantPrivateKey, wantOptions)
 		if diff := cmp.Diff(mgr.Name, wantName); diff != "" {
 			t.Errorf("incorrect name; -got +want: %s", diff)
 		}
 		if diff := cmp.Diff(mgr.PEMPrivateKey, wantPrivateKey); diff != "" {
 			t.Errorf("incorrect private key; -got +want: %s", diff)
 		}
+		if diff := cmp.Diff(mgr.AddOptions, wantOptions); diff != "" {
+			t.Errorf("incorrect options; -got +want: %s", diff)
+		}
 		// Compare by error string; cmp.EquateErrors doesn't work since type
 		// information is lost on conversion to/from JSON in message hub.
 		if diff := cmp.Diff(err, wantErr, errStringCmp); diff != "" {
@@ -158,6 +417,60 @@ func TestClientServerRemove(t *testing.T) {
 	})
 }
 
+func TestClientServerRestore(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		wantID := ID("id-0")
+		wantErr := errors.New("failed")
+
+		mgr.Err = wantErr
+
+		err := cli.Restore(ctx, wantID)
+		if diff := cmp.Diff(mgr.ID, wantID); diff != "" {
+			t.Errorf("incorrect ID; -got +want: %s", diff)
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(err, wantErr, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerDiscardRemoved(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		wantID := ID("id-0")
+		wantErr := errors.New("failed")
+
+		mgr.Err = wantErr
+
+		err := cli.DiscardRemoved(ctx, wantID)
+		if diff := cmp.Diff(mgr.ID, wantID); diff != "" {
+			t.Errorf("incorrect ID; -got +want: %s", diff)
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(err, wantErr, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
 func TestClientServerLoaded(t *testing.T) {
 	t.Parallel()
 
@@ -172,6 +485,7 @@ func TestClientServerLoaded(t *testing.T) {
 		k0.Type = "type-0"
 		k0.SetBlob([]byte("blob-0"))
 		k0.Comment = "comment-0"
+		k0.Expiry = 1234
 		k1 := &LoadedKey{}
 		k1.Type = "type-1"
 		k1.SetBlob([]byte("blob-1"))
@@ -207,17 +521,21 @@ func TestClientServerLoad(t *testing.T) {
 
 		wantID := ID("id-0")
 		wantPassphrase := "secret"
+		wantOptions := LoadOptions{LifetimeSecs: 60}
 		wantErr := errors.New("failed")
 
 		mgr.Err = wantErr
 
-		err := cli.Load(ctx, wantID, wantPassphrase)
+		err := cli.Load(ctx, wantID, wantPassphrase, wantOptions)
 		if diff := cmp.Diff(mgr.ID, wantID); diff != "" {
 			t.Errorf("incorrect ID; -got +want: %s", diff)
 		}
 		if diff := cmp.Diff(mgr.Passphrase, wantPassphrase); diff != "" {
 			t.Errorf("incorrect passphrase; -got +want: %s", diff)
 		}
+		if diff := cmp.Diff(mgr.LoadOptions, wantOptions); diff != "" {
+			t.Errorf("incorrect load options; -got +want: %s", diff)
+		}
 		// Compare by error string; cmp.EquateErrors doesn't work since type
 		// information is lost on conversion to/from JSON in message hub.
 		if diff := cmp.Diff(err, wantErr, errStringCmp); diff != "" {
@@ -252,3 +570,1478 @@ func TestClientServerUnload(t *testing.T) {
 		}
 	})
 }
+
+func TestClientServerUnloadAll(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		wantErr := errors.New("failed")
+
+		mgr.Err = wantErr
+
+		err := cli.UnloadAll(ctx)
+		if !mgr.UnloadedAll {
+			t.Errorf("UnloadAll not invoked on manager")
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(err, wantErr, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerPreferences(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		wantPrefs := &Preferences{ConfirmUnload: true}
+		wantErr := errors.New("failed")
+
+		mgr.Prefs = wantPrefs
+		mgr.Err = wantErr
+
+		prefs, err := cli.Preferences(ctx)
+		if diff := cmp.Diff(prefs, wantPrefs); diff != "" {
+			t.Errorf("incorrect preferences; -got +want: %s", diff)
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(err, wantErr, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerSetPreferences(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		wantPrefs := &Preferences{ConfirmUnload: true}
+		wantErr := errors.New("failed")
+
+		mgr.Err = wantErr
+
+		err := cli.SetPreferences(ctx, wantPrefs)
+		if diff := cmp.Diff(mgr.Prefs, wantPrefs); diff != "" {
+			t.Errorf("incorrect preferences; -got +want: %s", diff)
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(err, wantErr, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerExport(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		wantData := []byte(`{"version": 1}`)
+		wantErr := errors.New("failed")
+
+		mgr.Data = wantData
+		mgr.Err = wantErr
+
+		data, err := cli.Export(ctx)
+		if diff := cmp.Diff(data, wantData); diff != "" {
+			t.Errorf("incorrect data; -got +want: %s", diff)
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(err, wantErr, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerExportPublicKeys(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		wantText := "ssh-ed25519 AAAA some-key\n"
+		wantErr := errors.New("failed")
+
+		mgr.Data = []byte(wantText)
+		mgr.Err = wantErr
+
+		text, err := cli.ExportPublicKeys(ctx)
+		if diff := cmp.Diff(text, wantText); diff != "" {
+			t.Errorf("incorrect text; -got +want: %s", diff)
+		}
+		if diff := cmp.Diff(err, wantErr, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerImport(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		wantData := []byte(`{"version": 1}`)
+		wantResult := &ImportResult{Imported: 2, Skipped: 1}
+		wantErr := errors.New("failed")
+
+		mgr.ImportResult = wantResult
+		mgr.Err = wantErr
+
+		result, err := cli.Import(ctx, wantData)
+		if diff := cmp.Diff(mgr.Data, wantData); diff != "" {
+			t.Errorf("incorrect data; -got +want: %s", diff)
+		}
+		if diff := cmp.Diff(result, wantResult); diff != "" {
+			t.Errorf("incorrect result; -got +want: %s", diff)
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(err, wantErr, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerSetPositions(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		wantIDs := []ID{ID("id-1"), ID("id-0")}
+		wantErr := errors.New("failed")
+
+		mgr.Err = wantErr
+
+		err := cli.SetPositions(ctx, wantIDs)
+		if diff := cmp.Diff(mgr.IDs, wantIDs); diff != "" {
+			t.Errorf("incorrect IDs; -got +want: %s", diff)
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(err, wantErr, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerSetRSASignatureAlgorithm(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		wantID := ID("some-id")
+		wantAlg := "rsa-sha2-256"
+		wantErr := errors.New("failed")
+
+		mgr.Err = wantErr
+
+		err := cli.SetRSASignatureAlgorithm(ctx, wantID, wantAlg)
+		if diff := cmp.Diff(mgr.ID, wantID); diff != "" {
+			t.Errorf("incorrect key; -got +want: %s", diff)
+		}
+		if diff := cmp.Diff(mgr.Algorithm, wantAlg); diff != "" {
+			t.Errorf("incorrect algorithm; -got +want: %s", diff)
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(err, wantErr, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerKeyStorage(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		wantLocation := KeyStorageLocal
+		wantErr := errors.New("failed")
+
+		mgr.Location = wantLocation
+		mgr.Err = wantErr
+
+		location, err := cli.KeyStorage(ctx)
+		if diff := cmp.Diff(location, wantLocation); diff != "" {
+			t.Errorf("incorrect location; -got +want: %s", diff)
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(err, wantErr, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerSetKeyStorage(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		wantLocation := KeyStorageLocal
+		wantErr := errors.New("failed")
+
+		mgr.Err = wantErr
+
+		err := cli.SetKeyStorage(ctx, wantLocation)
+		if diff := cmp.Diff(mgr.Location, wantLocation); diff != "" {
+			t.Errorf("incorrect location; -got +want: %s", diff)
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(err, wantErr, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerStorageUsage(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		wantUsage := &StorageUsage{
+			Location:          KeyStorageSync,
+			BytesInUse:        1024,
+			QuotaBytes:        102400,
+			QuotaBytesPerItem: 8192,
+		}
+		wantErr := errors.New("failed")
+
+		mgr.Usage = wantUsage
+		mgr.Err = wantErr
+
+		usage, err := cli.StorageUsage(ctx)
+		if diff := cmp.Diff(usage, wantUsage); diff != "" {
+			t.Errorf("incorrect usage; -got +want: %s", diff)
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(err, wantErr, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerAddMany(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		wantKeys := []*NewKey{
+			{
+				Name:          "some-name",
+				PEMPrivateKey: "private-key",
+				Options:       AddOptions{ConfirmBeforeUse: true},
+			},
+			{
+				Name:          "other-name",
+				PEMPrivateKey: "other-private-key",
+			},
+		}
+		wantErrs := []error{errors.New("invalid"), errors.New("duplicate")}
+		wantErr := errors.New("failed")
+
+		mgr.Errs = wantErrs
+		mgr.Err = wantErr
+
+		errs, err := cli.AddMany(ctx, wantKeys)
+		if diff := cmp.Diff(mgr.NewKeys, wantKeys); diff != "" {
+			t.Errorf("incorrect keys; -got +want: %s", diff)
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(errs, wantErrs, errStringCmp); diff != "" {
+			t.Errorf("incorrect per-key errors; -got +want: %s", diff)
+		}
+		if diff := cmp.Diff(err, wantErr, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerAddBundle(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{
+			Errs: []error{errors.New("duplicate"), errors.New("malformed")},
+			Err:  errors.New("failed"),
+		}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		errs, err := cli.AddBundle(ctx, "some-name", "some-bundle", AddOptions{ConfirmBeforeUse: true})
+		if diff := cmp.Diff(mgr.Name, "some-name"); diff != "" {
+			t.Errorf("incorrect name; -got +want: %s", diff)
+		}
+		if diff := cmp.Diff(mgr.PEMPrivateKey, "some-bundle"); diff != "" {
+			t.Errorf("incorrect bundle; -got +want: %s", diff)
+		}
+		if diff := cmp.Diff(mgr.AddOptions, AddOptions{ConfirmBeforeUse: true}); diff != "" {
+			t.Errorf("incorrect options; -got +want: %s", diff)
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(errs, mgr.Errs, errStringCmp); diff != "" {
+			t.Errorf("incorrect per-block errors; -got +want: %s", diff)
+		}
+		if diff := cmp.Diff(err, mgr.Err, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerRemoveMany(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		wantIDs := []ID{ID("id-1"), ID("id-2")}
+		wantErrs := []error{errors.New("not found"), errors.New("also not found")}
+		wantErr := errors.New("failed")
+
+		mgr.Errs = wantErrs
+		mgr.Err = wantErr
+
+		errs, err := cli.RemoveMany(ctx, wantIDs)
+		if diff := cmp.Diff(mgr.IDs, wantIDs); diff != "" {
+			t.Errorf("incorrect IDs; -got +want: %s", diff)
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(errs, wantErrs, errStringCmp); diff != "" {
+			t.Errorf("incorrect per-key errors; -got +want: %s", diff)
+		}
+		if diff := cmp.Diff(err, wantErr, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerPing(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		if err := cli.Ping(ctx); err != nil {
+			t.Errorf("Ping failed: %v", err)
+		}
+
+		wantErr := errors.New("failed")
+		mgr.Err = wantErr
+		err := cli.Ping(ctx)
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(err, wantErr, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerMarkUsed(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{
+			Err: errors.New("failed"),
+		}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		err := cli.MarkUsed(ctx, ID("some-id"))
+		if diff := cmp.Diff(mgr.ID, ID("some-id")); diff != "" {
+			t.Errorf("incorrect ID; -got +want: %s", diff)
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(err, mgr.Err, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerReencrypt(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{
+			Err: errors.New("failed"),
+		}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		err := cli.Reencrypt(ctx, ID("some-id"), "old-passphrase", "new-passphrase")
+		if diff := cmp.Diff(mgr.ID, ID("some-id")); diff != "" {
+			t.Errorf("incorrect ID; -got +want: %s", diff)
+		}
+		if diff := cmp.Diff(mgr.Passphrase, "old-passphrase"); diff != "" {
+			t.Errorf("incorrect old passphrase; -got +want: %s", diff)
+		}
+		if diff := cmp.Diff(mgr.NewPassphrase, "new-passphrase"); diff != "" {
+			t.Errorf("incorrect new passphrase; -got +want: %s", diff)
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(err, mgr.Err, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerSetDisabled(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{
+			Err: errors.New("failed"),
+		}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		err := cli.SetDisabled(ctx, ID("some-id"), true)
+		if diff := cmp.Diff(mgr.ID, ID("some-id")); diff != "" {
+			t.Errorf("incorrect ID; -got +want: %s", diff)
+		}
+		if diff := cmp.Diff(mgr.Disabled, true); diff != "" {
+			t.Errorf("incorrect disabled; -got +want: %s", diff)
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(err, mgr.Err, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerSetAllowedOrigins(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{
+			Err: errors.New("failed"),
+		}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		err := cli.SetAllowedOrigins(ctx, ID("some-id"), []string{"chrome-extension://some-extension"})
+		if diff := cmp.Diff(mgr.ID, ID("some-id")); diff != "" {
+			t.Errorf("incorrect ID; -got +want: %s", diff)
+		}
+		if diff := cmp.Diff(mgr.Origins, []string{"chrome-extension://some-extension"}); diff != "" {
+			t.Errorf("incorrect origins; -got +want: %s", diff)
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(err, mgr.Err, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerSetTags(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{
+			Err: errors.New("failed"),
+		}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		err := cli.SetTags(ctx, ID("some-id"), []string{"personal", "work"})
+		if diff := cmp.Diff(mgr.ID, ID("some-id")); diff != "" {
+			t.Errorf("incorrect ID; -got +want: %s", diff)
+		}
+		if diff := cmp.Diff(mgr.Tags, []string{"personal", "work"}); diff != "" {
+			t.Errorf("incorrect tags; -got +want: %s", diff)
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(err, mgr.Err, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerSetPriority(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{
+			Err: errors.New("failed"),
+		}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		err := cli.SetPriority(ctx, ID("some-id"), 10)
+		if diff := cmp.Diff(mgr.ID, ID("some-id")); diff != "" {
+			t.Errorf("incorrect ID; -got +want: %s", diff)
+		}
+		if diff := cmp.Diff(mgr.Priority, 10); diff != "" {
+			t.Errorf("incorrect priority; -got +want: %s", diff)
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(err, mgr.Err, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerSetNote(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{
+			Err: errors.New("failed"),
+		}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		err := cli.SetNote(ctx, ID("some-id"), "prod bastion, rotate quarterly")
+		if diff := cmp.Diff(mgr.ID, ID("some-id")); diff != "" {
+			t.Errorf("incorrect ID; -got +want: %s", diff)
+		}
+		if diff := cmp.Diff(mgr.Note, "prod bastion, rotate quarterly"); diff != "" {
+			t.Errorf("incorrect note; -got +want: %s", diff)
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(err, mgr.Err, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerExportPrivate(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{
+			PEMPrivateKey: "private-key",
+			Err:           errors.New("failed"),
+		}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		pemPrivateKey, err := cli.ExportPrivate(ctx, ID("some-id"), "passphrase", "new-passphrase")
+		if diff := cmp.Diff(mgr.ID, ID("some-id")); diff != "" {
+			t.Errorf("incorrect ID; -got +want: %s", diff)
+		}
+		if diff := cmp.Diff(mgr.Passphrase, "passphrase"); diff != "" {
+			t.Errorf("incorrect passphrase; -got +want: %s", diff)
+		}
+		if diff := cmp.Diff(mgr.NewPassphrase, "new-passphrase"); diff != "" {
+			t.Errorf("incorrect new passphrase; -got +want: %s", diff)
+		}
+		if diff := cmp.Diff(pemPrivateKey, "private-key"); diff != "" {
+			t.Errorf("incorrect private key; -got +want: %s", diff)
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(err, mgr.Err, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerCorruptKeys(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{
+			Count: 2,
+			Err:   errors.New("failed"),
+		}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		count, err := cli.CorruptKeys(ctx)
+		if diff := cmp.Diff(count, 2); diff != "" {
+			t.Errorf("incorrect count; -got +want: %s", diff)
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(err, mgr.Err, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+
+		removed, err := cli.RemoveCorruptKeys(ctx)
+		if diff := cmp.Diff(removed, 2); diff != "" {
+			t.Errorf("incorrect number removed; -got +want: %s", diff)
+		}
+		if diff := cmp.Diff(err, mgr.Err, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerMasterPassphrase(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{
+			MasterSet: true,
+			MasterErr: errors.New("failed to check"),
+			Err:       errors.New("failed"),
+		}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		set, err := cli.MasterPassphraseSet(ctx)
+		if !set {
+			t.Errorf("master passphrase not reported as set")
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(err, mgr.MasterErr, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+
+		err = cli.VerifyMasterPassphrase(ctx, "secret")
+		if diff := cmp.Diff(mgr.Passphrase, "secret"); diff != "" {
+			t.Errorf("incorrect passphrase; -got +want: %s", diff)
+		}
+		if diff := cmp.Diff(err, mgr.Err, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+
+		// Once the master passphrase is verified, it may be changed.
+		mgr.MasterErr = nil
+		mgr.Err = nil
+		if err := cli.VerifyMasterPassphrase(ctx, "secret"); err != nil {
+			t.Fatalf("failed to verify master passphrase: %v", err)
+		}
+		mgr.Err = errors.New("failed")
+		err = cli.SetMasterPassphrase(ctx, "old-secret", "new-secret")
+		if diff := cmp.Diff(mgr.Passphrase, "old-secret"); diff != "" {
+			t.Errorf("incorrect current passphrase; -got +want: %s", diff)
+		}
+		if diff := cmp.Diff(mgr.NewPassphrase, "new-secret"); diff != "" {
+			t.Errorf("incorrect new passphrase; -got +want: %s", diff)
+		}
+		if diff := cmp.Diff(err, mgr.Err, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerSetAutoLoad(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{
+			Err: errors.New("failed"),
+		}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		err := cli.SetAutoLoad(ctx, ID("some-id"), true)
+		if diff := cmp.Diff(mgr.ID, ID("some-id")); diff != "" {
+			t.Errorf("incorrect ID; -got +want: %s", diff)
+		}
+		if diff := cmp.Diff(mgr.AutoLoad, true); diff != "" {
+			t.Errorf("incorrect auto-load; -got +want: %s", diff)
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(err, mgr.Err, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerForgetPassphrases(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{
+			Err: errors.New("failed"),
+		}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		err := cli.ForgetPassphrases(ctx)
+		if !mgr.Forgot {
+			t.Errorf("passphrases not forgotten")
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(err, mgr.Err, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerTestSign(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{
+			Err: errors.New("failed"),
+		}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		key := &LoadedKey{Type: "some-type", Comment: "some-comment"}
+		key.SetBlob([]byte("some-blob"))
+		err := cli.TestSign(ctx, key)
+		if diff := cmp.Diff(mgr.Key, key); diff != "" {
+			t.Errorf("incorrect key; -got +want: %s", diff)
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(err, mgr.Err, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerAdoptLoaded(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{
+			Err: errors.New("failed"),
+		}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		key := &LoadedKey{Type: "some-type", Comment: "some-comment"}
+		key.SetBlob([]byte("some-blob"))
+		err := cli.AdoptLoaded(ctx, key, "some-name")
+		if diff := cmp.Diff(mgr.Key, key); diff != "" {
+			t.Errorf("incorrect key; -got +want: %s", diff)
+		}
+		if diff := cmp.Diff(mgr.Name, "some-name"); diff != "" {
+			t.Errorf("incorrect name; -got +want: %s", diff)
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(err, mgr.Err, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerExtensions(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{
+			ExtensionNames: []string{"query", "test@example.com"},
+			Err:            errors.New("failed"),
+		}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		names, err := cli.Extensions(ctx)
+		if diff := cmp.Diff(names, mgr.ExtensionNames); diff != "" {
+			t.Errorf("incorrect extensions; -got +want: %s", diff)
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(err, mgr.Err, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerLockAgent(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{
+			Err: errors.New("failed"),
+		}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		err := cli.LockAgent(ctx, "some-passphrase")
+		if diff := cmp.Diff(mgr.Passphrase, "some-passphrase"); diff != "" {
+			t.Errorf("incorrect passphrase; -got +want: %s", diff)
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(err, mgr.Err, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerUnlockAgent(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{
+			Err: errors.New("failed"),
+		}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		err := cli.UnlockAgent(ctx, "some-passphrase")
+		if diff := cmp.Diff(mgr.Passphrase, "some-passphrase"); diff != "" {
+			t.Errorf("incorrect passphrase; -got +want: %s", diff)
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(err, mgr.Err, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerAgentLocked(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{
+			AgentIsLocked: true,
+			Err:           errors.New("failed"),
+		}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		locked, err := cli.AgentLocked(ctx)
+		if !locked {
+			t.Errorf("agent reported unlocked")
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(err, mgr.Err, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerNativeHostStatus(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{
+			NativeHost: &NativeHostStatus{Err: "host not found", RetryAt: 1234},
+			Err:        errors.New("failed"),
+		}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		status, err := cli.NativeHostStatus(ctx)
+		if diff := cmp.Diff(status, mgr.NativeHost); diff != "" {
+			t.Errorf("incorrect status; -got +want: %s", diff)
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(err, mgr.Err, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientServerAuditLog(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{
+			Err: errors.New("failed"),
+		}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		entry := &AuditEntry{
+			Time:        1234,
+			Fingerprint: "SHA256:abc",
+			Name:        "some-key",
+			Origin:      "chrome-untrusted://terminal",
+		}
+		err := cli.AppendAuditLog(ctx, entry)
+		if diff := cmp.Diff(mgr.AuditEntries, []*AuditEntry{entry}); diff != "" {
+			t.Errorf("incorrect appended entries; -got +want: %s", diff)
+		}
+		// Compare by error string; cmp.EquateErrors doesn't work since type
+		// information is lost on conversion to/from JSON in message hub.
+		if diff := cmp.Diff(err, mgr.Err, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+
+		entries, err := cli.AuditLog(ctx)
+		if diff := cmp.Diff(entries, []*AuditEntry{entry}); diff != "" {
+			t.Errorf("incorrect entries; -got +want: %s", diff)
+		}
+		if diff := cmp.Diff(err, mgr.Err, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+
+		err = cli.ClearAuditLog(ctx)
+		if !mgr.AuditCleared {
+			t.Errorf("audit log not cleared")
+		}
+		if diff := cmp.Diff(err, mgr.Err, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientRetry(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		drop        int
+		delay       time.Duration
+		wantErr     error
+	}{
+		{
+			description: "delivered",
+		},
+		{
+			description: "delayed",
+			delay:       20 * time.Millisecond,
+		},
+		{
+			description: "dropped once",
+			drop:        1,
+		},
+		{
+			description: "dropped along with wake",
+			drop:        2,
+		},
+		{
+			description: "dropped repeatedly",
+			drop:        3,
+			wantErr:     errUnreachable,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				hub := mfakes.NewHub()
+				defer hub.Close()
+				mgr := &dummyManager{
+					ConfiguredKeys: []*ConfiguredKey{{ID: "id-1", Name: "key-1"}},
+				}
+				cli := NewClientWithTimeout(hub, 200*time.Millisecond)
+				srv := NewServer(mgr)
+				hub.AddReceiver(srv)
+				hub.DropNext(tc.drop)
+				hub.SetDelay(tc.delay)
+
+				configured, err := cli.Configured(ctx)
+				if diff := cmp.Diff(err, tc.wantErr, cmpopts.EquateErrors()); diff != "" {
+					t.Errorf("incorrect error; -got +want: %s", diff)
+				}
+				if diff := cmp.Diff(IsUnreachable(err), tc.wantErr != nil); diff != "" {
+					t.Errorf("incorrect unreachable; -got +want: %s", diff)
+				}
+				if tc.wantErr != nil {
+					return
+				}
+				if diff := cmp.Diff(configured, mgr.ConfiguredKeys); diff != "" {
+					t.Errorf("incorrect configured keys; -got +want: %s", diff)
+				}
+			})
+		})
+	}
+}
+
+// countingManager is a dummyManager that counts the keys added.
+type countingManager struct {
+	*dummyManager
+	adds int
+}
+
+func (m *countingManager) Add(ctx jsutil.AsyncContext, name string, pemPrivateKey string, opts AddOptions) error {
+	m.adds++
+	return m.dummyManager.Add(ctx, name, pemPrivateKey, opts)
+}
+
+func TestClientNoRetryNonIdempotent(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		defer hub.Close()
+		mgr := &countingManager{dummyManager: &dummyManager{}}
+		cli := NewClientWithTimeout(hub, 200*time.Millisecond)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+		hub.SetDelay(500 * time.Millisecond)
+
+		// A slow request is abandoned, but not repeated.
+		err := cli.Add(ctx, "some-name", "private-key", AddOptions{})
+		if diff := cmp.Diff(err, errUnreachable, cmpopts.EquateErrors()); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+		time.Sleep(time.Second)
+		if diff := cmp.Diff(mgr.adds, 1); diff != "" {
+			t.Errorf("incorrect number of adds; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestClientCancelRequests(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		defer hub.Close()
+		mgr := &countingManager{dummyManager: &dummyManager{}}
+		cli := NewClientWithTimeout(hub, 5*time.Second)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+		hub.HoldNext(1)
+
+		// The request is never answered; cancel it.
+		errs := make(chan error, 1)
+		go func() {
+			errs <- cli.Add(ctx, "some-name", "private-key", AddOptions{})
+		}()
+		time.Sleep(50 * time.Millisecond)
+		CancelRequests(cli)
+		select {
+		case err := <-errs:
+			if !IsCancelled(err) {
+				t.Errorf("incorrect error: got %v, want cancelled", err)
+			}
+			if IsUnreachable(err) {
+				t.Errorf("cancelled request reported as unreachable: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("cancelled request did not return")
+		}
+
+		// The late response is discarded, and later requests succeed.
+		hub.Release()
+		if err := cli.Add(ctx, "other-name", "private-key", AddOptions{}); err != nil {
+			t.Errorf("request after cancellation failed: %v", err)
+		}
+		if diff := cmp.Diff(mgr.adds, 2); diff != "" {
+			t.Errorf("incorrect number of adds; -got +want: %s", diff)
+		}
+
+		// Cancelling with no outstanding requests, or for a manager
+		// that is not a client, has no effect.
+		CancelRequests(cli)
+		CancelRequests(mgr)
+		if err := cli.Add(ctx, "another-name", "private-key", AddOptions{}); err != nil {
+			t.Errorf("request after cancellation failed: %v", err)
+		}
+	})
+}
+
+func TestClientWithCancel(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		defer hub.Close()
+		mgr := &countingManager{dummyManager: &dummyManager{}}
+		cli := NewClientWithTimeout(hub, 5*time.Second)
+		hub.AddReceiver(NewServer(mgr))
+		scoped, cancel := WithCancel(cli)
+		hub.HoldNext(2)
+
+		// Neither request is answered; cancel only the one sent through
+		// the scoped client.
+		scopedErrs := make(chan error, 1)
+		go func() {
+			scopedErrs <- scoped.Add(ctx, "some-name", "private-key", AddOptions{})
+		}()
+		otherErrs := make(chan error, 1)
+		go func() {
+			otherErrs <- cli.Add(ctx, "other-name", "private-key", AddOptions{})
+		}()
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+		select {
+		case err := <-scopedErrs:
+			if !IsCancelled(err) {
+				t.Errorf("incorrect error: got %v, want cancelled", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("cancelled request did not return")
+		}
+
+		// The other request completes once answered.
+		hub.Release()
+		select {
+		case err := <-otherErrs:
+			if err != nil {
+				t.Errorf("request not cancelled failed: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("request not cancelled did not return")
+		}
+		if diff := cmp.Diff(mgr.adds, 2); diff != "" {
+			t.Errorf("incorrect number of adds; -got +want: %s", diff)
+		}
+
+		// Cancelling the parent client also cancels requests sent
+		// through the scoped client.
+		hub.HoldNext(1)
+		go func() {
+			scopedErrs <- scoped.Add(ctx, "another-name", "private-key", AddOptions{})
+		}()
+		time.Sleep(50 * time.Millisecond)
+		CancelRequests(cli)
+		select {
+		case err := <-scopedErrs:
+			if !IsCancelled(err) {
+				t.Errorf("incorrect error: got %v, want cancelled", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("cancelled request did not return")
+		}
+		hub.Release()
+
+		// Once the parent is closed, the scoped client sends no
+		// further requests.
+		CloseClient(cli)
+		if err := scoped.Add(ctx, "last-name", "private-key", AddOptions{}); !IsCancelled(err) {
+			t.Errorf("incorrect error for request after close: got %v, want cancelled", err)
+		}
+
+		// A manager that is not a client is returned unchanged.
+		if got, cancel := WithCancel(mgr); got != Manager(mgr) {
+			t.Errorf("manager that is not a client was wrapped")
+		} else {
+			cancel()
+		}
+	})
+}
+
+func TestCloseClient(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		defer hub.Close()
+		mgr := &countingManager{dummyManager: &dummyManager{}}
+		cli := NewClientWithTimeout(hub, 5*time.Second)
+		hub.AddReceiver(NewServer(mgr))
+		hub.HoldNext(1)
+
+		// The request is never answered; closing the client cancels
+		// it.
+		errs := make(chan error, 1)
+		go func() {
+			errs <- cli.Add(ctx, "some-name", "private-key", AddOptions{})
+		}()
+		time.Sleep(50 * time.Millisecond)
+		CloseClient(cli)
+		select {
+		case err := <-errs:
+			if !IsCancelled(err) {
+				t.Errorf("incorrect error: got %v, want cancelled", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("pending request did not return")
+		}
+
+		// Later requests are not sent.
+		hub.Release()
+		if err := cli.Add(ctx, "other-name", "private-key", AddOptions{}); !IsCancelled(err) {
+			t.Errorf("incorrect error for request after close: got %v, want cancelled", err)
+		}
+		if diff := cmp.Diff(mgr.adds, 1); diff != "" {
+			t.Errorf("incorrect number of adds; -got +want: %s", diff)
+		}
+
+		// A replacement client is unaffected.
+		if err := NewClient(hub).Add(ctx, "another-name", "private-key", AddOptions{}); err != nil {
+			t.Errorf("request from new client failed: %v", err)
+		}
+
+		// Closing a manager that is not a client has no effect.
+		CloseClient(mgr)
+	})
+}
+
+func TestClientServerErrorNotUnreachable(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{
+			Err: errors.New("failed"),
+		}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		// Errors returned by the server are not mistaken for the server
+		// being unreachable.
+		err := cli.Remove(ctx, ID("some-id"))
+		if diff := cmp.Diff(err, mgr.Err, errStringCmp); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+		if IsUnreachable(err) {
+			t.Errorf("server error reported as unreachable: %v", err)
+		}
+	})
+}
+
+func TestClientServerUnsupportedCipher(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{
+			Err: fmt.Errorf("%w: %w", errParseFailed, ErrUnsupportedCipher),
+		}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+
+		// Keys using an unsupported cipher remain identifiable after the
+		// error is returned by the server.
+		err := cli.Load(ctx, ID("some-id"), "secret", LoadOptions{})
+		if diff := cmp.Diff(err.Error(), mgr.Err.Error()); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+		if !errors.Is(err, ErrUnsupportedCipher) {
+			t.Errorf("unsupported cipher not identified: %v", err)
+		}
+	})
+}
+
+func TestClientServerErrorCategories(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		err         error
+		want        error
+	}{
+		{
+			description: "bad passphrase",
+			err:         categorize(fmt.Errorf("failed to parse private key: %w", x509.IncorrectPasswordError), ErrBadPassphrase),
+			want:        ErrBadPassphrase,
+		},
+		{
+			description: "bad ppk passphrase",
+			err:         categorize(fmt.Errorf("failed to parse private key: %w: passphrase is incorrect or file is corrupt", ppk.ErrMACMismatch), ErrBadPassphrase),
+			want:        ErrBadPassphrase,
+		},
+		{
+			description: "duplicate key",
+			err:         fmt.Errorf("%w: key already configured as some-key", ErrDuplicateKey),
+			want:        ErrDuplicateKey,
+		},
+		{
+			description: "invalid name",
+			err:         ErrInvalidName,
+			want:        ErrInvalidName,
+		},
+		{
+			description: "name in use",
+			err:         fmt.Errorf("%w: some-key", ErrNameInUse),
+			want:        ErrNameInUse,
+		},
+		{
+			description: "unsupported format",
+			err:         errUnrecognizedKey,
+			want:        ErrUnsupportedFormat,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				hub := mfakes.NewHub()
+				mgr := &dummyManager{
+					Err: tc.err,
+				}
+				cli := NewClient(hub)
+				srv := NewServer(mgr)
+				hub.AddReceiver(srv)
+
+				// The error's category remains identifiable after
+				// the error is returned by the server.
+				err := cli.Load(ctx, ID("some-id"), "secret", LoadOptions{})
+				if diff := cmp.Diff(err.Error(), mgr.Err.Error()); diff != "" {
+					t.Errorf("incorrect error; -got +want: %s", diff)
+				}
+				if !errors.Is(err, tc.want) {
+					t.Errorf("error category not identified: got %v, want %v", err, tc.want)
+				}
+			})
+		})
+	}
+}
+
+// hangingSender is a message.Sender that never receives a response.
+type hangingSender struct {
+	done chan struct{}
+}
+
+func (s *hangingSender) Send(_ jsutil.AsyncContext, _ js.Value) (js.Value, error) {
+	<-s.done
+	return js.Undefined(), errors.New("closed")
+}
+
+func TestClientPingUnreachable(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		sender      func() (message.Sender, func())
+	}{
+		{
+			description: "no receiver",
+			sender: func() (message.Sender, func()) {
+				return mfakes.NewHub(), func() {}
+			},
+		},
+		{
+			description: "no response",
+			sender: func() (message.Sender, func()) {
+				s := &hangingSender{done: make(chan struct{})}
+				return s, func() { close(s.done) }
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				sender, release := tc.sender()
+				defer release()
+				cli := NewClient(sender)
+
+				err := cli.Ping(ctx)
+				if diff := cmp.Diff(err, errUnreachable, cmpopts.EquateErrors()); diff != "" {
+					t.Errorf("incorrect error; -got +want: %s", diff)
+				}
+			})
+		})
+	}
+}
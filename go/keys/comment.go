@@ -0,0 +1,158 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"bytes"
+	"encoding/pem"
+	"math/big"
+
+	"github.com/google/chrome-ssh-agent/go/keys/ppk"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// placeholderName is the name assigned to a key added without a name,
+	// if the key does not embed a comment.
+	placeholderName = "Unnamed key"
+)
+
+// Comment returns the comment embedded in the private key, or the empty
+// string if there is none. PuTTY .ppk files store the comment outside the
+// encrypted key material. OpenSSH keys store it alongside the private key,
+// so it is only available for unencrypted keys. Other formats do not
+// include a comment.
+func (s *storedKey) Comment() string {
+	if ppk.IsPPK([]byte(s.PEMPrivateKey)) {
+		k, err := ppk.Parse([]byte(s.PEMPrivateKey))
+		if err != nil {
+			return ""
+		}
+		return k.Comment
+	}
+
+	block, _ := pem.Decode([]byte(s.PEMPrivateKey))
+	if block == nil || block.Type != "OPENSSH PRIVATE KEY" {
+		return ""
+	}
+	return opensshComment(block.Bytes)
+}
+
+// opensshComment returns the comment from the decoded contents of an
+// unencrypted OpenSSH private key. See PROTOCOL.key in the OpenSSH sources.
+func opensshComment(data []byte) string {
+	if !bytes.HasPrefix(data, []byte(opensshMagic)) {
+		return ""
+	}
+
+	var envelope struct {
+		CipherName   string
+		KdfName      string
+		KdfOpts      string
+		NumKeys      uint32
+		PubKey       []byte
+		PrivKeyBlock []byte
+		Rest         []byte `ssh:"rest"`
+	}
+	if err := ssh.Unmarshal(data[len(opensshMagic):], &envelope); err != nil {
+		return ""
+	}
+	if envelope.CipherName != "none" {
+		return ""
+	}
+
+	var private struct {
+		Check1  uint32
+		Check2  uint32
+		Keytype string
+		Rest    []byte `ssh:"rest"`
+	}
+	if err := ssh.Unmarshal(envelope.PrivKeyBlock, &private); err != nil {
+		return ""
+	}
+
+	// The comment follows the key-specific fields, so they must be parsed
+	// to locate it.
+	var comment string
+	var err error
+	switch private.Keytype {
+	case ssh.KeyAlgoRSA:
+		var key struct {
+			N, E, D, Iqmp, P, Q *big.Int
+			Comment             string
+			Pad                 []byte `ssh:"rest"`
+		}
+		err = ssh.Unmarshal(private.Rest, &key)
+		comment = key.Comment
+	case ssh.KeyAlgoED25519:
+		var key struct {
+			Pub     []byte
+			Priv    []byte
+			Comment string
+			Pad     []byte `ssh:"rest"`
+		}
+		err = ssh.Unmarshal(private.Rest, &key)
+		comment = key.Comment
+	case ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521:
+		var key struct {
+			Curve   string
+			Pub     []byte
+			D       *big.Int
+			Comment string
+			Pad     []byte `ssh:"rest"`
+		}
+		err = ssh.Unmarshal(private.Rest, &key)
+		comment = key.Comment
+	case ssh.KeyAlgoDSA:
+		var key struct {
+			P, Q, G, Y, X *big.Int
+			Comment       string
+			Pad           []byte `ssh:"rest"`
+		}
+		err = ssh.Unmarshal(private.Rest, &key)
+		comment = key.Comment
+	case ssh.KeyAlgoSKED25519:
+		var key struct {
+			Pub         []byte
+			Application string
+			Flags       uint8
+			KeyHandle   []byte
+			Reserved    []byte
+			Comment     string
+			Pad         []byte `ssh:"rest"`
+		}
+		err = ssh.Unmarshal(private.Rest, &key)
+		comment = key.Comment
+	case ssh.KeyAlgoSKECDSA256:
+		var key struct {
+			Curve       string
+			Pub         []byte
+			Application string
+			Flags       uint8
+			KeyHandle   []byte
+			Reserved    []byte
+			Comment     string
+			Pad         []byte `ssh:"rest"`
+		}
+		err = ssh.Unmarshal(private.Rest, &key)
+		comment = key.Comment
+	}
+	if err != nil {
+		return ""
+	}
+	return comment
+}
@@ -0,0 +1,108 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestComment(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description   string
+		pemPrivateKey string
+		want          string
+	}{
+		{
+			description:   "unencrypted openssh rsa key",
+			pemPrivateKey: testdata.OpenSSHFormatWithoutPassphrase.Private,
+			want:          "richard_alimi_gmail_com@workstation",
+		},
+		{
+			description:   "unencrypted openssh ed25519 key",
+			pemPrivateKey: testdata.ED25519WithoutPassphrase.Private,
+			want:          "richard_alimi_gmail_com@workstation",
+		},
+		{
+			description:   "encrypted openssh key",
+			pemPrivateKey: testdata.ED25519WithPassphrase.Private,
+		},
+		{
+			description:   "unencrypted ppk key",
+			pemPrivateKey: testdata.PPKv2WithoutPassphrase.Private,
+			want:          "test-comment",
+		},
+		{
+			description:   "encrypted ppk key",
+			pemPrivateKey: testdata.PPKv3WithPassphrase.Private,
+			want:          "test-comment",
+		},
+		{
+			description:   "pem key",
+			pemPrivateKey: testdata.WithoutPassphrase.Private,
+		},
+		{
+			description:   "invalid key",
+			pemPrivateKey: "bogus-key-data",
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			sk := &storedKey{PEMPrivateKey: tc.pemPrivateKey}
+			if diff := cmp.Diff(sk.Comment(), tc.want); diff != "" {
+				t.Errorf("incorrect comment; -got +want: %s", diff)
+			}
+		})
+	}
+}
+
+func TestConfiguredComment(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		syncStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, []*initialKey{
+			{
+				Name:          "some-key",
+				PEMPrivateKey: testdata.PPKv2WithPassphrase.Private,
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+
+		configured, err := mgr.Configured(ctx)
+		if err != nil {
+			t.Fatalf("failed to get configured keys: %v", err)
+		}
+		if diff := cmp.Diff(configured[0].Comment, "test-comment"); diff != "" {
+			t.Errorf("incorrect comment; -got +want: %s", diff)
+		}
+	})
+}
@@ -0,0 +1,169 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// ConfirmFunc is invoked before a key that requires confirmation is used to
+// sign data. The signature is returned only if it returns true.
+//
+// ConfirmFunc is invoked on the goroutine serving the agent request, so it
+// may block while waiting for the user to respond.
+type ConfirmFunc func(key *LoadedKey) bool
+
+var (
+	errSignDenied = errors.New("signing denied by user")
+)
+
+// ConfirmAgent wraps an agent and enforces the ConfirmBeforeUse constraint
+// for keys added to it. The keyring provided by the agent package accepts,
+// but otherwise ignores, the constraint.
+type ConfirmAgent struct {
+	agent.ExtendedAgent
+
+	mu      sync.Mutex
+	confirm ConfirmFunc
+	// required contains the public key material for keys that require
+	// confirmation before use.
+	required map[string]bool
+}
+
+// NewConfirmAgent returns a ConfirmAgent wrapping the supplied agent. Keys
+// requiring confirmation may not be used for signing until a ConfirmFunc is
+// supplied via SetConfirm.
+func NewConfirmAgent(agt agent.ExtendedAgent) *ConfirmAgent {
+	return &ConfirmAgent{
+		ExtendedAgent: agt,
+		required:      map[string]bool{},
+	}
+}
+
+// SetConfirm sets the function used to prompt the user to confirm use of a
+// key.
+func (a *ConfirmAgent) SetConfirm(confirm ConfirmFunc) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.confirm = confirm
+}
+
+// Add implements agent.Agent.Add.
+func (a *ConfirmAgent) Add(key agent.AddedKey) error {
+	signer, err := ssh.NewSignerFromKey(key.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("failed to determine public key: %w", err)
+	}
+
+	if err := a.ExtendedAgent.Add(key); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// A key added with a certificate is listed and used for signing by
+	// its certificate.
+	blob := string(signer.PublicKey().Marshal())
+	if key.Certificate != nil {
+		blob = string(key.Certificate.Marshal())
+	}
+	if key.ConfirmBeforeUse {
+		a.required[blob] = true
+	} else {
+		delete(a.required, blob)
+	}
+	return nil
+}
+
+// Remove implements agent.Agent.Remove.
+func (a *ConfirmAgent) Remove(key ssh.PublicKey) error {
+	if err := a.ExtendedAgent.Remove(key); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.required, string(key.Marshal()))
+	return nil
+}
+
+// RemoveAll implements agent.Agent.RemoveAll.
+func (a *ConfirmAgent) RemoveAll() error {
+	if err := a.ExtendedAgent.RemoveAll(); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.required = map[string]bool{}
+	return nil
+}
+
+// Sign implements agent.Agent.Sign.
+func (a *ConfirmAgent) Sign(key ssh.PublicKey, data []byte) (*ssh.Signature, error) {
+	return a.SignWithFlags(key, data, 0)
+}
+
+// SignWithFlags implements agent.ExtendedAgent.SignWithFlags.
+func (a *ConfirmAgent) SignWithFlags(key ssh.PublicKey, data []byte, flags agent.SignatureFlags) (*ssh.Signature, error) {
+	if err := a.checkConfirmed(key); err != nil {
+		return nil, err
+	}
+	return a.ExtendedAgent.SignWithFlags(key, data, flags)
+}
+
+// checkConfirmed prompts the user to confirm use of the key if required. An
+// error is returned if the key may not be used.
+func (a *ConfirmAgent) checkConfirmed(key ssh.PublicKey) error {
+	a.mu.Lock()
+	required := a.required[string(key.Marshal())]
+	confirm := a.confirm
+	a.mu.Unlock()
+
+	if !required {
+		return nil
+	}
+	if confirm == nil {
+		return fmt.Errorf("%w: confirmation unavailable", errSignDenied)
+	}
+
+	if !confirm(lookupLoaded(a.ExtendedAgent, key)) {
+		return errSignDenied
+	}
+	return nil
+}
+
+// lookupLoaded returns the LoadedKey corresponding to the public key,
+// including the comment attached to it in the agent if available.
+func lookupLoaded(agt agent.Agent, key ssh.PublicKey) *LoadedKey {
+	lk := &LoadedKey{Type: key.Type()}
+	lk.SetBlob(key.Marshal())
+	if loaded, err := agt.List(); err == nil {
+		for _, l := range loaded {
+			if l.Type() == key.Type() && string(l.Marshal()) == string(key.Marshal()) {
+				lk.Comment = l.Comment
+				break
+			}
+		}
+	}
+	return lk
+}
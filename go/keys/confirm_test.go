@@ -0,0 +1,175 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestConfirmBeforeUse(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description      string
+		confirmBeforeUse bool
+		setConfirm       bool
+		confirm          bool
+		wantConfirmed    bool
+		wantErr          error
+	}{
+		{
+			description: "confirmation not required",
+			setConfirm:  true,
+		},
+		{
+			description:      "confirmation allowed",
+			confirmBeforeUse: true,
+			setConfirm:       true,
+			confirm:          true,
+			wantConfirmed:    true,
+		},
+		{
+			description:      "confirmation denied",
+			confirmBeforeUse: true,
+			setConfirm:       true,
+			wantConfirmed:    true,
+			wantErr:          errSignDenied,
+		},
+		{
+			description:      "confirmation unavailable",
+			confirmBeforeUse: true,
+			wantErr:          errSignDenied,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				agt := NewConfirmAgent(agent.NewKeyring().(agent.ExtendedAgent))
+				var confirmed []*LoadedKey
+				if tc.setConfirm {
+					agt.SetConfirm(func(key *LoadedKey) bool {
+						confirmed = append(confirmed, key)
+						return tc.confirm
+					})
+				}
+
+				syncStorage := storage.NewRaw(st.NewMemArea())
+				sessionStorage := storage.NewRaw(st.NewMemArea())
+				mgr, err := newTestManager(ctx, agt, syncStorage, sessionStorage, []*initialKey{
+					{
+						Name:          "some-key",
+						PEMPrivateKey: testdata.WithoutPassphrase.Private,
+						Load:          true,
+						AddOptions:    AddOptions{ConfirmBeforeUse: tc.confirmBeforeUse},
+					},
+				})
+				if err != nil {
+					t.Fatalf("failed to initialize manager: %v", err)
+				}
+
+				configured, err := mgr.Configured(ctx)
+				if err != nil {
+					t.Fatalf("failed to get configured keys: %v", err)
+				}
+				if diff := cmp.Diff(configured[0].ConfirmBeforeUse, tc.confirmBeforeUse); diff != "" {
+					t.Errorf("incorrect configured constraint; -got +want: %s", diff)
+				}
+
+				loaded, err := agt.List()
+				if err != nil {
+					t.Fatalf("failed to list keys: %v", err)
+				}
+				_, err = agt.Sign(loaded[0], []byte("some-data"))
+				if diff := cmp.Diff(err, tc.wantErr, cmpopts.EquateErrors()); diff != "" {
+					t.Errorf("incorrect error; -got +want: %s", diff)
+				}
+				if diff := cmp.Diff(len(confirmed) > 0, tc.wantConfirmed); diff != "" {
+					t.Errorf("incorrect confirmation; -got +want: %s", diff)
+				}
+				for _, k := range confirmed {
+					if diff := cmp.Diff(k.Comment, loaded[0].Comment); diff != "" {
+						t.Errorf("incorrect confirmed key; -got +want: %s", diff)
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestConfirmAfterRemove(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		agt := NewConfirmAgent(agent.NewKeyring().(agent.ExtendedAgent))
+		agt.SetConfirm(func(key *LoadedKey) bool { return false })
+
+		syncStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		mgr, err := newTestManager(ctx, agt, syncStorage, sessionStorage, []*initialKey{
+			{
+				Name:          "confirmed-key",
+				PEMPrivateKey: testdata.WithoutPassphrase.Private,
+				Load:          true,
+				AddOptions:    AddOptions{ConfirmBeforeUse: true},
+			},
+			{
+				Name:          "unconfirmed-key",
+				PEMPrivateKey: testdata.WithoutPassphrase.Private,
+				AddOptions:    AddOptions{AllowDuplicate: true},
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+
+		// Replace the loaded key with an identical key that does not
+		// require confirmation.
+		confirmedID, err := findKey(ctx, mgr, InvalidID, "confirmed-key")
+		if err != nil {
+			t.Fatalf("failed to find key: %v", err)
+		}
+		if err := mgr.Unload(ctx, confirmedID); err != nil {
+			t.Fatalf("failed to unload key: %v", err)
+		}
+		unconfirmedID, err := findKey(ctx, mgr, InvalidID, "unconfirmed-key")
+		if err != nil {
+			t.Fatalf("failed to find key: %v", err)
+		}
+		if err := mgr.Load(ctx, unconfirmedID, "", LoadOptions{}); err != nil {
+			t.Fatalf("failed to load key: %v", err)
+		}
+
+		loaded, err := agt.List()
+		if err != nil {
+			t.Fatalf("failed to list keys: %v", err)
+		}
+		if _, err := agt.Sign(loaded[0], []byte("some-data")); err != nil {
+			t.Errorf("failed to sign: %v", err)
+		}
+	})
+}
@@ -0,0 +1,63 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+)
+
+var (
+	errMissingID         = errors.New("stored key has no ID")
+	errMissingPrivateKey = errors.New("stored key has no private key")
+)
+
+// Validate implements storage.Validator. Stored keys lacking an ID or a
+// private key cannot be used, and are treated as corrupt. Keys adopted from
+// the agent have only a public key.
+func (s *storedKey) Validate() error {
+	if s.ID == "" {
+		return errMissingID
+	}
+	if s.PEMPrivateKey == "" && s.AuthorizedKey == "" {
+		return fmt.Errorf("%w: key ID %s", errMissingPrivateKey, s.ID)
+	}
+	return nil
+}
+
+// CorruptKeys implements Manager.CorruptKeys.
+func (m *DefaultManager) CorruptKeys(ctx jsutil.AsyncContext) (int, error) {
+	corrupt, err := m.storedKeys.Corrupt(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read keys: %w", err)
+	}
+	return len(corrupt), nil
+}
+
+// RemoveCorruptKeys implements Manager.RemoveCorruptKeys.
+func (m *DefaultManager) RemoveCorruptKeys(ctx jsutil.AsyncContext) (int, error) {
+	n, err := m.storedKeys.DeleteCorrupt(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove corrupt keys: %w", err)
+	}
+	if n > 0 {
+		jsutil.Log("Removed %d corrupt stored keys", n)
+	}
+	return n, nil
+}
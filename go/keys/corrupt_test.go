@@ -0,0 +1,99 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"syscall/js"
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestCorruptKeys(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		syncStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, []*initialKey{
+			{
+				Name:          "good-key",
+				PEMPrivateKey: testdata.WithoutPassphrase.Private,
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+
+		// Deliberately store malformed records alongside the good key.
+		malformed := map[string]js.Value{
+			"key.not-an-object": js.ValueOf(42),
+			"key.missing-pem":   js.ValueOf(map[string]any{"id": "missing-pem", "name": "bad-key"}),
+		}
+		if err := syncStorage.Set(ctx, malformed); err != nil {
+			t.Fatalf("failed to store malformed records: %v", err)
+		}
+
+		configuredNames := func() []string {
+			configured, err := mgr.Configured(ctx)
+			if err != nil {
+				t.Fatalf("failed to enumerate configured keys: %v", err)
+			}
+			var names []string
+			for _, k := range configured {
+				names = append(names, k.Name)
+			}
+			return names
+		}
+
+		// The corrupt records are skipped, without affecting the good
+		// key.
+		if diff := cmp.Diff(configuredNames(), []string{"good-key"}); diff != "" {
+			t.Errorf("incorrect configured keys; -got +want: %s", diff)
+		}
+		count, err := mgr.CorruptKeys(ctx)
+		if err != nil {
+			t.Fatalf("failed to count corrupt keys: %v", err)
+		}
+		if diff := cmp.Diff(count, 2); diff != "" {
+			t.Errorf("incorrect corrupt key count; -got +want: %s", diff)
+		}
+
+		removed, err := mgr.RemoveCorruptKeys(ctx)
+		if err != nil {
+			t.Fatalf("failed to remove corrupt keys: %v", err)
+		}
+		if diff := cmp.Diff(removed, 2); diff != "" {
+			t.Errorf("incorrect number of keys removed; -got +want: %s", diff)
+		}
+
+		count, err = mgr.CorruptKeys(ctx)
+		if err != nil {
+			t.Fatalf("failed to count corrupt keys: %v", err)
+		}
+		if diff := cmp.Diff(count, 0); diff != "" {
+			t.Errorf("incorrect corrupt key count after removal; -got +want: %s", diff)
+		}
+		if diff := cmp.Diff(configuredNames(), []string{"good-key"}); diff != "" {
+			t.Errorf("incorrect configured keys after removal; -got +want: %s", diff)
+		}
+	})
+}
@@ -0,0 +1,84 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+)
+
+var (
+	errKeyDisabled = errors.New("key is disabled")
+)
+
+// SetDisabled implements Manager.SetDisabled.
+func (m *DefaultManager) SetDisabled(ctx jsutil.AsyncContext, id ID, disabled bool) error {
+	found := false
+	err := m.updateStoredKeys(ctx, func(sk *storedKey) bool {
+		if ID(sk.ID) != id {
+			return false
+		}
+		found = true
+		if sk.Disabled == disabled {
+			return false
+		}
+		sk.Disabled = disabled
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update key: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("%w: failed to find key with ID %s", errKeyNotFound, id)
+	}
+	if !disabled {
+		return nil
+	}
+
+	// Ensure a disabled key is no longer available to clients of the
+	// agent.
+	loaded, err := m.Loaded(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to enumerate loaded keys: %w", err)
+	}
+	for _, l := range loaded {
+		if l.ID() == id {
+			if err := m.Unload(ctx, id); err != nil {
+				return fmt.Errorf("failed to unload disabled key: %w", err)
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// disabledKeys returns the IDs of configured keys that are disabled.
+func (m *DefaultManager) disabledKeys(ctx jsutil.AsyncContext) (map[ID]bool, error) {
+	stored, err := m.storedKeys.ReadAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := map[ID]bool{}
+	for _, k := range stored {
+		if k.Disabled {
+			result[ID(k.ID)] = true
+		}
+	}
+	return result, nil
+}
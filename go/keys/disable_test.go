@@ -0,0 +1,234 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestSetDisabled(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description  string
+		name         string
+		id           ID
+		disabled     bool
+		wantDisabled map[string]bool
+		wantLoaded   []string
+		wantErr      error
+	}{
+		{
+			description: "enabled by default",
+			wantDisabled: map[string]bool{
+				"loaded-key":   false,
+				"disabled-key": true,
+			},
+			wantLoaded: []string{"loaded-key"},
+		},
+		{
+			description: "disable loaded key",
+			name:        "loaded-key",
+			disabled:    true,
+			wantDisabled: map[string]bool{
+				"loaded-key":   true,
+				"disabled-key": true,
+			},
+		},
+		{
+			description: "enable disabled key",
+			name:        "disabled-key",
+			disabled:    false,
+			wantDisabled: map[string]bool{
+				"loaded-key":   false,
+				"disabled-key": false,
+			},
+			wantLoaded: []string{"loaded-key"},
+		},
+		{
+			description: "reject invalid ID",
+			id:          ID("bogus-id"),
+			disabled:    true,
+			wantDisabled: map[string]bool{
+				"loaded-key":   false,
+				"disabled-key": true,
+			},
+			wantLoaded: []string{"loaded-key"},
+			wantErr:    errKeyNotFound,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				syncStorage := storage.NewRaw(st.NewMemArea())
+				sessionStorage := storage.NewRaw(st.NewMemArea())
+				mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, []*initialKey{
+					{
+						Name:          "loaded-key",
+						PEMPrivateKey: testdata.WithoutPassphrase.Private,
+						Load:          true,
+					},
+					{
+						Name:          "disabled-key",
+						PEMPrivateKey: testdata.ED25519WithoutPassphrase.Private,
+						AddOptions:    AddOptions{Disabled: true},
+					},
+				})
+				if err != nil {
+					t.Fatalf("failed to initialize manager: %v", err)
+				}
+
+				if tc.name != "" || tc.id != InvalidID {
+					id := tc.id
+					if id == InvalidID {
+						id, err = findKey(ctx, mgr, InvalidID, tc.name)
+						if err != nil {
+							t.Fatalf("failed to find key: %v", err)
+						}
+					}
+					err = mgr.SetDisabled(ctx, id, tc.disabled)
+					if diff := cmp.Diff(err, tc.wantErr, cmpopts.EquateErrors()); diff != "" {
+						t.Errorf("incorrect error; -got +want: %s", diff)
+					}
+				}
+
+				configured, err := mgr.Configured(ctx)
+				if err != nil {
+					t.Fatalf("failed to get configured keys: %v", err)
+				}
+				got := map[string]bool{}
+				for _, k := range configured {
+					got[k.Name] = k.Disabled
+				}
+				if diff := cmp.Diff(got, tc.wantDisabled); diff != "" {
+					t.Errorf("incorrect disabled keys; -got +want: %s", diff)
+				}
+
+				var wantLoaded []ID
+				for _, name := range tc.wantLoaded {
+					id, err := findKey(ctx, mgr, InvalidID, name)
+					if err != nil {
+						t.Fatalf("failed to find key: %v", err)
+					}
+					wantLoaded = append(wantLoaded, id)
+				}
+				loaded, err := mgr.Loaded(ctx)
+				if err != nil {
+					t.Fatalf("failed to get loaded keys: %v", err)
+				}
+				if diff := cmp.Diff(loadedKeyIDs(loaded), wantLoaded, cmpopts.EquateEmpty()); diff != "" {
+					t.Errorf("incorrect loaded keys; -got +want: %s", diff)
+				}
+			})
+		})
+	}
+}
+
+func TestLoadDisabledKey(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		agt := agent.NewKeyring()
+		mgr, err := newTestManager(ctx, agt, storage.NewRaw(st.NewMemArea()), storage.NewRaw(st.NewMemArea()), []*initialKey{
+			{
+				Name:          "disabled-key",
+				PEMPrivateKey: testdata.WithoutPassphrase.Private,
+				AddOptions:    AddOptions{Disabled: true},
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+		id, err := findKey(ctx, mgr, InvalidID, "disabled-key")
+		if err != nil {
+			t.Fatalf("failed to find key: %v", err)
+		}
+
+		err = mgr.Load(ctx, id, "", LoadOptions{})
+		if diff := cmp.Diff(err, errKeyDisabled, cmpopts.EquateErrors()); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+
+		// The key never reaches the agent.
+		keys, err := agt.List()
+		if err != nil {
+			t.Fatalf("failed to list agent keys: %v", err)
+		}
+		if len(keys) != 0 {
+			t.Errorf("disabled key loaded into agent: %v", keys)
+		}
+	})
+}
+
+func TestDisabledKeyNotRestoredFromSession(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		syncStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, []*initialKey{
+			{
+				Name:          "some-key",
+				PEMPrivateKey: testdata.WithoutPassphrase.Private,
+				Load:          true,
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+
+		// Simulate the key being disabled elsewhere (e.g., on another
+		// device) while it remains in this session.
+		if err := mgr.storedKeys.Update(ctx, func(sk *storedKey) bool {
+			sk.Disabled = true
+			return true
+		}); err != nil {
+			t.Fatalf("failed to disable key: %v", err)
+		}
+
+		agt := agent.NewKeyring()
+		mgr = NewManager(agt, syncStorage, storage.NewRaw(st.NewMemArea()), sessionStorage)
+		if err := mgr.LoadFromSession(ctx); err != nil {
+			t.Fatalf("failed to load keys from session: %v", err)
+		}
+		keys, err := agt.List()
+		if err != nil {
+			t.Fatalf("failed to list agent keys: %v", err)
+		}
+		if len(keys) != 0 {
+			t.Errorf("disabled key restored into agent: %v", keys)
+		}
+		gotSessionKeys, err := sessionKeyIDs(ctx, mgr.sessionKeys)
+		if err != nil {
+			t.Errorf("failed to get session keys: %v", err)
+		}
+		if diff := cmp.Diff(gotSessionKeys, []ID{}, cmpopts.EquateEmpty()); diff != "" {
+			t.Errorf("incorrect session keys; -got +want: %s", diff)
+		}
+	})
+}
@@ -0,0 +1,215 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"bytes"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/chrome-ssh-agent/go/keys/ppk"
+	"golang.org/x/crypto/ssh"
+)
+
+// encryption describes how a configured private key is protected.
+type encryption string
+
+const (
+	// encryptionUnknown indicates that the protection was not recorded
+	// when the key was added. This is the case for keys stored by older
+	// releases.
+	encryptionUnknown encryption = ""
+	// encryptionNone indicates that the key is not encrypted.
+	encryptionNone encryption = "none"
+	// encryptionPassphrase indicates that the key is encrypted and
+	// requires a passphrase to load.
+	encryptionPassphrase encryption = "passphrase"
+	// encryptionUnsupported indicates that the key is encrypted using a
+	// cipher that cannot be decrypted.
+	encryptionUnsupported encryption = "unsupported"
+)
+
+var (
+	// unsupportedCipherMessages are fragments of the messages of errors
+	// returned by the private key parsers when the key uses a cipher or
+	// key derivation function they do not support. The parsers do not
+	// return distinct errors that may be detected otherwise.
+	unsupportedCipherMessages = []string{
+		"ssh: unknown cipher",
+		"ssh: unknown KDF",
+		"pkcs8: unsupported cipher",
+		"pkcs8: unsupported KDF",
+		"x509: unknown encryption mode",
+	}
+)
+
+// classifyDecryptError classifies an error returned when parsing a private
+// key. If the error indicates that the key uses an unsupported cipher, the
+// returned error wraps ErrUnsupportedCipher; otherwise, the error is returned
+// unchanged.
+func classifyDecryptError(err error) error {
+	if err == nil || errors.Is(err, ErrUnsupportedCipher) {
+		return err
+	}
+	if errors.Is(err, ppk.ErrUnsupported) {
+		return fmt.Errorf("%w: %w", ErrUnsupportedCipher, err)
+	}
+	for _, msg := range unsupportedCipherMessages {
+		if strings.Contains(err.Error(), msg) {
+			return fmt.Errorf("%w: %w", ErrUnsupportedCipher, err)
+		}
+	}
+	return err
+}
+
+const (
+	// opensshMagic is the prefix of the decoded contents of an OpenSSH
+	// private key. See PROTOCOL.key in the OpenSSH sources.
+	opensshMagic = "openssh-key-v1\x00"
+)
+
+var (
+	// opensshCiphers are the ciphers supported for OpenSSH private keys.
+	opensshCiphers = map[string]bool{
+		"none":       true,
+		"aes256-ctr": true,
+		"aes256-cbc": true,
+	}
+
+	// pemCiphers are the ciphers supported for legacy encrypted PEM
+	// private keys, as named in the DEK-Info header.
+	pemCiphers = map[string]bool{
+		"DES-CBC":      true,
+		"DES-EDE3-CBC": true,
+		"AES-128-CBC":  true,
+		"AES-192-CBC":  true,
+		"AES-256-CBC":  true,
+	}
+)
+
+// detectEncryption parses the private key to determine how it is protected.
+// If the key uses a cipher that cannot be decrypted, an error describing the
+// problem is also returned.
+func detectEncryption(pemPrivateKey string) (encryption, error) {
+	if ppk.IsPPK([]byte(pemPrivateKey)) {
+		return detectPPKEncryption(pemPrivateKey)
+	}
+
+	block, _ := pem.Decode([]byte(pemPrivateKey))
+	if block == nil {
+		// Defer complaining about improperly formatted keys until they
+		// are loaded.
+		return encryptionNone, nil
+	}
+
+	switch {
+	case block.Type == "ENCRYPTED PRIVATE KEY":
+		// Encrypted PKCS#8 keys declare their cipher within the
+		// encrypted structure; leave it to the parser to complain.
+		return encryptionPassphrase, nil
+	case block.Type == "OPENSSH PRIVATE KEY":
+		return detectOpenSSHEncryption(block.Bytes)
+	case strings.Contains(block.Headers["Proc-Type"], "ENCRYPTED"):
+		cipher, _, _ := strings.Cut(block.Headers["DEK-Info"], ",")
+		if !pemCiphers[cipher] {
+			return encryptionUnsupported, fmt.Errorf("%w: %q", ErrUnsupportedCipher, cipher)
+		}
+		return encryptionPassphrase, nil
+	default:
+		return encryptionNone, nil
+	}
+}
+
+// detectOpenSSHEncryption determines how an OpenSSH private key is protected
+// from the decoded contents of the key.
+func detectOpenSSHEncryption(data []byte) (encryption, error) {
+	if !bytes.HasPrefix(data, []byte(opensshMagic)) {
+		return encryptionNone, nil
+	}
+
+	var header struct {
+		CipherName string
+		KdfName    string
+		Rest       []byte `ssh:"rest"`
+	}
+	if err := ssh.Unmarshal(data[len(opensshMagic):], &header); err != nil {
+		return encryptionNone, nil
+	}
+
+	if header.CipherName == "none" {
+		return encryptionNone, nil
+	}
+	if !opensshCiphers[header.CipherName] {
+		return encryptionUnsupported, fmt.Errorf("%w: %q", ErrUnsupportedCipher, header.CipherName)
+	}
+	if header.KdfName != "bcrypt" {
+		return encryptionUnsupported, fmt.Errorf("%w: unsupported key derivation function %q", ErrUnsupportedCipher, header.KdfName)
+	}
+	return encryptionPassphrase, nil
+}
+
+// detectPPKEncryption determines how a PuTTY .ppk private key is protected.
+func detectPPKEncryption(data string) (encryption, error) {
+	k, err := ppk.Parse([]byte(data))
+	if errors.Is(err, ppk.ErrUnsupported) {
+		return encryptionUnsupported, fmt.Errorf("%w: %w", ErrUnsupportedCipher, err)
+	}
+	if err != nil || !k.Encrypted() {
+		return encryptionNone, nil
+	}
+	return encryptionPassphrase, nil
+}
+
+// unsupportedError returns an error explaining why a key using an unsupported
+// cipher cannot be loaded. The error wraps ErrUnsupportedCipher.
+func (s *storedKey) unsupportedError() error {
+	if _, err := detectEncryption(s.PEMPrivateKey); errors.Is(err, ErrUnsupportedCipher) {
+		return err
+	}
+	return ErrUnsupportedCipher
+}
+
+// encryptionState returns how the key is protected, along with a description
+// of why the key cannot be loaded if it uses an unsupported cipher. The
+// protection recorded when the key was added is used if available.
+func (s *storedKey) encryptionState() (encryption, string) {
+	enc := encryption(s.Encryption)
+	if enc == encryptionUnknown {
+		var err error
+		enc, err = detectEncryption(s.PEMPrivateKey)
+		if err != nil {
+			return enc, err.Error()
+		}
+		// Fall back to the prior heuristic, which may also detect
+		// encryption in keys we failed to parse above.
+		if s.Encrypted() && enc == encryptionNone {
+			enc = encryptionPassphrase
+		}
+		return enc, ""
+	}
+
+	if enc == encryptionUnsupported {
+		// The reason is not stored; recompute it.
+		if _, err := detectEncryption(s.PEMPrivateKey); err != nil {
+			return enc, err.Error()
+		}
+		return enc, ErrUnsupportedCipher.Error()
+	}
+	return enc, ""
+}
@@ -0,0 +1,289 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/ppk"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// unsupportedPEMCipher is a legacy encrypted PEM key whose DEK-Info header
+// names a cipher that cannot be decrypted.
+var unsupportedPEMCipher = strings.Replace(testdata.WithPassphrase.Private, "AES-256-CBC", "BF-CBC", 1)
+
+func TestDetectEncryption(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description   string
+		pemPrivateKey string
+		want          encryption
+		wantErr       error
+	}{
+		{
+			description:   "unencrypted key",
+			pemPrivateKey: testdata.WithoutPassphrase.Private,
+			want:          encryptionNone,
+		},
+		{
+			description:   "encrypted key",
+			pemPrivateKey: testdata.WithPassphrase.Private,
+			want:          encryptionPassphrase,
+		},
+		{
+			description:   "unencrypted openssh key",
+			pemPrivateKey: testdata.OpenSSHFormatWithoutPassphrase.Private,
+			want:          encryptionNone,
+		},
+		{
+			description:   "encrypted openssh key",
+			pemPrivateKey: testdata.OpenSSHFormat.Private,
+			want:          encryptionPassphrase,
+		},
+		{
+			description:   "unencrypted pkcs8 key",
+			pemPrivateKey: testdata.PKCS8FormatWithoutPassphrase.Private,
+			want:          encryptionNone,
+		},
+		{
+			description:   "encrypted pkcs8 key",
+			pemPrivateKey: testdata.PKCS8Format.Private,
+			want:          encryptionPassphrase,
+		},
+		{
+			description:   "unencrypted pkcs8 ecdsa key",
+			pemPrivateKey: testdata.PKCS8ECDSAWithoutPassphrase.Private,
+			want:          encryptionNone,
+		},
+		{
+			description:   "encrypted pkcs8 ecdsa key",
+			pemPrivateKey: testdata.PKCS8ECDSAWithPassphrase.Private,
+			want:          encryptionPassphrase,
+		},
+		{
+			description:   "openssh key with unsupported cipher",
+			pemPrivateKey: testdata.ED25519UnsupportedCipher.Private,
+			want:          encryptionUnsupported,
+			wantErr:       ErrUnsupportedCipher,
+		},
+		{
+			description:   "pem key with unsupported cipher",
+			pemPrivateKey: unsupportedPEMCipher,
+			want:          encryptionUnsupported,
+			wantErr:       ErrUnsupportedCipher,
+		},
+		{
+			description:   "unencrypted ppk key",
+			pemPrivateKey: testdata.PPKv2WithoutPassphrase.Private,
+			want:          encryptionNone,
+		},
+		{
+			description:   "encrypted ppk key",
+			pemPrivateKey: testdata.PPKv3WithPassphrase.Private,
+			want:          encryptionPassphrase,
+		},
+		{
+			description:   "ppk key with unsupported key derivation",
+			pemPrivateKey: strings.Replace(testdata.PPKv3WithPassphrase.Private, "Argon2id", "Argon2d", 1),
+			want:          encryptionUnsupported,
+			wantErr:       ErrUnsupportedCipher,
+		},
+		{
+			description:   "invalid key",
+			pemPrivateKey: "bogus-key-data",
+			want:          encryptionNone,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := detectEncryption(tc.pemPrivateKey)
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Errorf("incorrect encryption; -got +want: %s", diff)
+			}
+			if diff := cmp.Diff(err, tc.wantErr, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("incorrect error; -got +want: %s", diff)
+			}
+		})
+	}
+}
+
+func TestClassifyDecryptError(t *testing.T) {
+	t.Parallel()
+
+	otherErr := errors.New("ssh: no key found")
+
+	testcases := []struct {
+		description     string
+		err             error
+		wantUnsupported bool
+	}{
+		{
+			description: "no error",
+			err:         nil,
+		},
+		{
+			description: "unrelated error",
+			err:         otherErr,
+		},
+		{
+			description: "incorrect passphrase",
+			err:         x509.IncorrectPasswordError,
+		},
+		{
+			description:     "unknown openssh cipher",
+			err:             errors.New(`ssh: unknown cipher "chacha20-poly1305@openssh.com", only supports "aes256-ctr" or "aes256-cbc"`),
+			wantUnsupported: true,
+		},
+		{
+			description:     "unknown openssh kdf",
+			err:             errors.New(`ssh: unknown KDF "scrypt", only supports "none" or "bcrypt"`),
+			wantUnsupported: true,
+		},
+		{
+			description:     "unsupported pkcs8 cipher",
+			err:             errors.New("pkcs8: unsupported cipher (OID: 1.2.840.113549.3.7)"),
+			wantUnsupported: true,
+		},
+		{
+			description:     "unknown pem encryption mode",
+			err:             errors.New("x509: unknown encryption mode"),
+			wantUnsupported: true,
+		},
+		{
+			description:     "unsupported ppk encryption",
+			err:             fmt.Errorf("%w: key derivation Argon2d", ppk.ErrUnsupported),
+			wantUnsupported: true,
+		},
+		{
+			description:     "already classified",
+			err:             ErrUnsupportedCipher,
+			wantUnsupported: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			got := classifyDecryptError(tc.err)
+			if diff := cmp.Diff(errors.Is(got, ErrUnsupportedCipher), tc.wantUnsupported); diff != "" {
+				t.Errorf("incorrect classification; -got +want: %s", diff)
+			}
+			if !errors.Is(got, tc.err) {
+				t.Errorf("classified error %v does not wrap %v", got, tc.err)
+			}
+		})
+	}
+}
+
+func TestConfiguredEncryption(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description     string
+		pemPrivateKey   string
+		legacy          bool
+		wantEncrypted   bool
+		wantUnsupported bool
+	}{
+		{
+			description:   "unencrypted key",
+			pemPrivateKey: testdata.WithoutPassphrase.Private,
+		},
+		{
+			description:   "encrypted key",
+			pemPrivateKey: testdata.ED25519WithPassphrase.Private,
+			wantEncrypted: true,
+		},
+		{
+			description:     "unsupported cipher",
+			pemPrivateKey:   testdata.ED25519UnsupportedCipher.Private,
+			wantEncrypted:   true,
+			wantUnsupported: true,
+		},
+		{
+			description:   "encrypted key stored by older release",
+			pemPrivateKey: testdata.ED25519WithPassphrase.Private,
+			legacy:        true,
+			wantEncrypted: true,
+		},
+		{
+			description:     "unsupported cipher stored by older release",
+			pemPrivateKey:   unsupportedPEMCipher,
+			legacy:          true,
+			wantEncrypted:   true,
+			wantUnsupported: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				syncStorage := storage.NewRaw(st.NewMemArea())
+				sessionStorage := storage.NewRaw(st.NewMemArea())
+				mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, nil)
+				if err != nil {
+					t.Fatalf("failed to initialize manager: %v", err)
+				}
+
+				if tc.legacy {
+					// Older releases did not record encryption.
+					err = mgr.storedKeys.Write(ctx, &storedKey{
+						ID:            "legacy-id",
+						Name:          "some-key",
+						PEMPrivateKey: tc.pemPrivateKey,
+					})
+				} else {
+					err = mgr.Add(ctx, "some-key", tc.pemPrivateKey, AddOptions{})
+				}
+				if err != nil {
+					t.Fatalf("failed to add key: %v", err)
+				}
+
+				configured, err := mgr.Configured(ctx)
+				if err != nil {
+					t.Fatalf("failed to get configured keys: %v", err)
+				}
+				if diff := cmp.Diff(configured[0].Encrypted, tc.wantEncrypted); diff != "" {
+					t.Errorf("incorrect encrypted; -got +want: %s", diff)
+				}
+				if diff := cmp.Diff(configured[0].Unsupported != "", tc.wantUnsupported); diff != "" {
+					t.Errorf("incorrect unsupported; -got +want: %s", diff)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,67 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Categories of errors returned by a Manager, which callers may detect using
+// errors.Is (e.g., to display a message explaining how to resolve the
+// error). Errors remain detectable once returned by a Client.
+var (
+	// ErrBadPassphrase indicates that a key could not be decrypted using
+	// the supplied passphrase.
+	ErrBadPassphrase = errors.New("incorrect passphrase")
+	// ErrDuplicateKey indicates that a key is already configured.
+	ErrDuplicateKey = errors.New("duplicate key")
+	// ErrInvalidName indicates that the name supplied for a key is not
+	// valid text.
+	ErrInvalidName = errors.New("invalid key name")
+	// ErrNameInUse indicates that the name supplied for a key is already
+	// used by another configured key. It is also an ErrInvalidName.
+	ErrNameInUse = fmt.Errorf("%w: name already in use", ErrInvalidName)
+	// ErrUnsupportedFormat indicates that the private key supplied is not
+	// in a recognized format.
+	ErrUnsupportedFormat = errors.New("unsupported key format")
+	// ErrUnsupportedCipher indicates that a key could not be loaded
+	// because it is encrypted using a cipher (or key derivation function)
+	// that is not supported. Such keys can be loaded once re-encrypted
+	// using a supported cipher.
+	ErrUnsupportedCipher = errors.New("unsupported cipher")
+)
+
+// categorizedErr is an error that also belongs to one of the categories
+// above. Its message is unchanged.
+type categorizedErr struct {
+	err      error
+	category error
+}
+
+// categorize returns err, additionally detectable as category.
+func categorize(err, category error) error {
+	return &categorizedErr{err: err, category: category}
+}
+
+func (e *categorizedErr) Error() string {
+	return e.err.Error()
+}
+
+func (e *categorizedErr) Unwrap() []error {
+	return []error{e.err, e.category}
+}
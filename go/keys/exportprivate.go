@@ -0,0 +1,64 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	"golang.org/x/crypto/ssh"
+)
+
+// ExportPrivate implements Manager.ExportPrivate.
+func (m *DefaultManager) ExportPrivate(ctx jsutil.AsyncContext, id ID, passphrase, newPassphrase string) (string, error) {
+	sk, err := m.readStoredKey(ctx, id)
+	if err != nil {
+		return "", fmt.Errorf("failed to read key: %w", err)
+	}
+	if sk == nil {
+		return "", fmt.Errorf("%w: failed to find key with ID %s", errKeyNotFound, id)
+	}
+
+	var pemPrivateKey string
+	if sk.publicOnly() != nil {
+		err = errPublicOnly
+	} else {
+		pemPrivateKey, err = reencryptKey(sk, passphrase, newPassphrase)
+	}
+
+	// Every attempt is recorded, so that the user can later determine
+	// whether a private key may have been disclosed.
+	entry := &AuditEntry{
+		Time:   time.Now().Unix(),
+		Action: AuditActionExportPrivate,
+		Name:   sk.Name,
+		Err:    makeErrStr(err),
+	}
+	if pub := sk.PublicKey(); pub != nil {
+		entry.Fingerprint = ssh.FingerprintSHA256(pub)
+	}
+	if auditErr := m.AppendAuditLog(ctx, entry); auditErr != nil {
+		// Refuse to disclose the key if the export cannot be recorded.
+		return "", auditErr
+	}
+
+	if err != nil {
+		return "", err
+	}
+	return pemPrivateKey, nil
+}
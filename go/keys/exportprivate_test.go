@@ -0,0 +1,164 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestExportPrivate(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description   string
+		key           testdata.TestKey
+		byID          ID
+		passphrase    string
+		newPassphrase string
+		wantErr       error
+		wantAudit     bool
+	}{
+		{
+			description: "export unencrypted key",
+			key:         testdata.WithoutPassphrase,
+			wantAudit:   true,
+		},
+		{
+			description: "export decrypted key",
+			key:         testdata.ED25519WithPassphrase,
+			passphrase:  testdata.ED25519WithPassphrase.Passphrase,
+			wantAudit:   true,
+		},
+		{
+			description:   "export re-encrypted key",
+			key:           testdata.ECDSAWithPassphrase,
+			passphrase:    testdata.ECDSAWithPassphrase.Passphrase,
+			newPassphrase: "new-secret",
+			wantAudit:     true,
+		},
+		{
+			description: "incorrect passphrase",
+			key:         testdata.WithPassphrase,
+			passphrase:  "incorrect-passphrase",
+			wantErr:     x509.IncorrectPasswordError,
+			wantAudit:   true,
+		},
+		{
+			description: "invalid ID",
+			key:         testdata.WithoutPassphrase,
+			byID:        ID("bogus-id"),
+			wantErr:     errKeyNotFound,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				syncStorage := storage.NewRaw(st.NewMemArea())
+				sessionStorage := storage.NewRaw(st.NewMemArea())
+				initial := []*initialKey{
+					{Name: "key", PEMPrivateKey: tc.key.Private},
+				}
+				mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, initial)
+				if err != nil {
+					t.Fatalf("failed to initialize manager: %v", err)
+				}
+				id, err := findKey(ctx, mgr, InvalidID, "key")
+				if err != nil {
+					t.Fatalf("failed to find key: %v", err)
+				}
+				before, err := mgr.storedKeys.Read(ctx, func(sk *storedKey) bool { return ID(sk.ID) == id })
+				if err != nil {
+					t.Fatalf("failed to read key: %v", err)
+				}
+
+				exportID := id
+				if tc.byID != InvalidID {
+					exportID = tc.byID
+				}
+				pemPrivateKey, err := mgr.ExportPrivate(ctx, exportID, tc.passphrase, tc.newPassphrase)
+				if diff := cmp.Diff(err, tc.wantErr, cmpopts.EquateErrors()); diff != "" {
+					t.Errorf("incorrect error; -got +want: %s", diff)
+				}
+
+				entries, err := mgr.AuditLog(ctx)
+				if err != nil {
+					t.Fatalf("failed to read audit log: %v", err)
+				}
+				if !tc.wantAudit {
+					if diff := cmp.Diff(len(entries), 0); diff != "" {
+						t.Errorf("incorrect number of audit entries; -got +want: %s", diff)
+					}
+					return
+				}
+				if len(entries) != 1 {
+					t.Fatalf("incorrect number of audit entries: got %d, want 1", len(entries))
+				}
+				if diff := cmp.Diff(entries[0].Action, AuditActionExportPrivate); diff != "" {
+					t.Errorf("incorrect audit action; -got +want: %s", diff)
+				}
+				if diff := cmp.Diff(entries[0].Name, "key"); diff != "" {
+					t.Errorf("incorrect audit name; -got +want: %s", diff)
+				}
+				if diff := cmp.Diff(entries[0].Err != "", tc.wantErr != nil); diff != "" {
+					t.Errorf("incorrect audit error; -got +want: %s", diff)
+				}
+				if tc.wantErr != nil {
+					return
+				}
+
+				// The exported key is the configured key, encrypted
+				// with the new passphrase if one was supplied.
+				var signer ssh.Signer
+				if tc.newPassphrase == "" {
+					signer, err = ssh.ParsePrivateKey([]byte(pemPrivateKey))
+				} else {
+					signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(pemPrivateKey), []byte(tc.newPassphrase))
+				}
+				if err != nil {
+					t.Fatalf("failed to parse exported key: %v", err)
+				}
+				blob := base64.StdEncoding.EncodeToString(signer.PublicKey().Marshal())
+				if diff := cmp.Diff(blob, tc.key.Blob); diff != "" {
+					t.Errorf("incorrect exported key; -got +want: %s", diff)
+				}
+
+				// The stored key is unmodified.
+				after, err := mgr.storedKeys.Read(ctx, func(sk *storedKey) bool { return ID(sk.ID) == id })
+				if err != nil {
+					t.Fatalf("failed to read key: %v", err)
+				}
+				if diff := cmp.Diff(after.PEMPrivateKey, before.PEMPrivateKey); diff != "" {
+					t.Errorf("stored key modified; -got +want: %s", diff)
+				}
+			})
+		})
+	}
+}
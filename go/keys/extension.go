@@ -0,0 +1,138 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+const (
+	// queryExtension is the name of the agent extension that lists the
+	// extensions supported by the agent. See [PROTOCOL.agent] section 4.7.
+	queryExtension = "query"
+
+	// agentSuccess is the SSH_AGENT_SUCCESS message number.
+	agentSuccess = 6
+)
+
+// ExtensionHandler processes the contents of an agent extension request.
+//
+// The response must be a complete agent message, beginning with its message
+// number (typically SSH_AGENT_SUCCESS). An empty response is sent as
+// SSH_AGENT_SUCCESS. Returning agent.ErrExtensionUnsupported sends
+// SSH_AGENT_FAILURE; any other error sends SSH_AGENT_EXTENSION_FAILURE.
+type ExtensionHandler func(contents []byte) ([]byte, error)
+
+// ExtensionAgent wraps an agent and handles agent extension requests.
+// Requests for extensions that have not been registered are passed to the
+// wrapped agent, which declines those it does not support.
+type ExtensionAgent struct {
+	agent.ExtendedAgent
+
+	handlers map[string]ExtensionHandler
+}
+
+// NewExtensionAgent returns an ExtensionAgent wrapping the supplied agent.
+// The query extension is registered by default.
+func NewExtensionAgent(agt agent.ExtendedAgent) *ExtensionAgent {
+	a := &ExtensionAgent{
+		ExtendedAgent: agt,
+		handlers:      map[string]ExtensionHandler{},
+	}
+	a.Register(queryExtension, a.query)
+	return a
+}
+
+// Register installs the handler for the named extension, replacing any
+// existing handler. It must not be invoked while the agent is being served.
+func (a *ExtensionAgent) Register(name string, handler ExtensionHandler) {
+	a.handlers[name] = handler
+}
+
+// query implements the query extension. The response lists the names of the
+// registered extensions.
+func (a *ExtensionAgent) query(contents []byte) ([]byte, error) {
+	var names []string
+	for name := range a.handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rsp := []byte{agentSuccess}
+	for _, name := range names {
+		rsp = append(rsp, ssh.Marshal(struct{ Name string }{name})...)
+	}
+	return rsp, nil
+}
+
+// Extension implements agent.ExtendedAgent.Extension.
+func (a *ExtensionAgent) Extension(extensionType string, contents []byte) ([]byte, error) {
+	if handler, ok := a.handlers[extensionType]; ok {
+		return handler(contents)
+	}
+	return a.ExtendedAgent.Extension(extensionType, contents)
+}
+
+var errQueryFailed = errors.New("failed to query agent extensions")
+
+// parseQueryResponse parses the response to the query extension, returning
+// the names of the supported extensions.
+func parseQueryResponse(rsp []byte) ([]string, error) {
+	if len(rsp) == 0 || rsp[0] != agentSuccess {
+		return nil, fmt.Errorf("%w: unexpected response", errQueryFailed)
+	}
+
+	names := []string{}
+	rest := rsp[1:]
+	for len(rest) > 0 {
+		var name struct {
+			Name string
+			Rest []byte `ssh:"rest"`
+		}
+		if err := ssh.Unmarshal(rest, &name); err != nil {
+			return nil, fmt.Errorf("%w: %w", errQueryFailed, err)
+		}
+		names = append(names, name.Name)
+		rest = name.Rest
+	}
+	return names, nil
+}
+
+// Extensions implements Manager.Extensions.
+func (m *DefaultManager) Extensions(ctx jsutil.AsyncContext) ([]string, error) {
+	agt, ok := m.agent.(agent.ExtendedAgent)
+	if !ok {
+		return []string{}, nil
+	}
+
+	rsp, err := agt.Extension(queryExtension, nil)
+	if errors.Is(err, agent.ErrExtensionUnsupported) {
+		// The agent does not support any extensions, not even the
+		// query extension.
+		return []string{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("%w: %w", errQueryFailed, err)
+	}
+	return parseQueryResponse(rsp)
+}
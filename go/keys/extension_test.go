@@ -0,0 +1,250 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// extensionRequest sends an SSH_AGENTC_EXTENSION request for the named
+// extension to an agent served over conn, and returns the agent's response
+// message.
+func extensionRequest(conn net.Conn, name string, contents []byte) ([]byte, error) {
+	return agentRequest(conn, ssh.Marshal(struct {
+		Type     byte
+		Name     string
+		Contents []byte `ssh:"rest"`
+	}{27, name, contents}))
+}
+
+// agentRequest sends a request message to an agent served over conn, and
+// returns the agent's response message.
+func agentRequest(conn net.Conn, req []byte) ([]byte, error) {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(req)))
+	if _, err := conn.Write(append(length[:], req...)); err != nil {
+		return nil, err
+	}
+
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	rsp := make([]byte, binary.BigEndian.Uint32(length[:]))
+	if _, err := io.ReadFull(conn, rsp); err != nil {
+		return nil, err
+	}
+	return rsp, nil
+}
+
+// nameList encodes names as an SSH string list.
+func nameList(names ...string) []byte {
+	var result []byte
+	for _, name := range names {
+		result = append(result, ssh.Marshal(struct{ Name string }{name})...)
+	}
+	return result
+}
+
+func TestExtensionAgent(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		register    map[string]ExtensionHandler
+		extension   string
+		contents    []byte
+		want        []byte
+	}{
+		{
+			description: "query extensions",
+			extension:   "query",
+			want:        append([]byte{agentSuccess}, nameList("query")...),
+		},
+		{
+			description: "query includes registered extensions",
+			register: map[string]ExtensionHandler{
+				"test@example.com": func(contents []byte) ([]byte, error) { return nil, nil },
+			},
+			extension: "query",
+			want:      append([]byte{agentSuccess}, nameList("query", "test@example.com")...),
+		},
+		{
+			description: "decline unsupported extension",
+			extension:   "session-bind@openssh.com",
+			contents:    []byte("some-contents"),
+			want:        []byte{5}, // SSH_AGENT_FAILURE
+		},
+		{
+			description: "empty response is success",
+			register: map[string]ExtensionHandler{
+				"test@example.com": func(contents []byte) ([]byte, error) { return nil, nil },
+			},
+			extension: "test@example.com",
+			want:      []byte{agentSuccess},
+		},
+		{
+			description: "registered extension receives contents",
+			register: map[string]ExtensionHandler{
+				"test@example.com": func(contents []byte) ([]byte, error) {
+					return append([]byte{agentSuccess}, contents...), nil
+				},
+			},
+			extension: "test@example.com",
+			contents:  []byte("some-contents"),
+			want:      append([]byte{agentSuccess}, "some-contents"...),
+		},
+		{
+			description: "registered extension fails",
+			register: map[string]ExtensionHandler{
+				"test@example.com": func(contents []byte) ([]byte, error) {
+					return nil, errors.New("failed")
+				},
+			},
+			extension: "test@example.com",
+			want:      []byte{28}, // SSH_AGENT_EXTENSION_FAILURE
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			agt := NewExtensionAgent(agent.NewKeyring().(agent.ExtendedAgent))
+			for name, handler := range tc.register {
+				agt.Register(name, handler)
+			}
+
+			client, server := net.Pipe()
+			defer client.Close()
+			go agent.ServeAgent(agt, server)
+
+			got, err := extensionRequest(client, tc.extension, tc.contents)
+			if err != nil {
+				t.Fatalf("extension request failed: %v", err)
+			}
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Errorf("incorrect response; -got +want: %s", diff)
+			}
+
+			// The connection remains usable after the extension request.
+			if _, err := agent.NewClient(client).List(); err != nil {
+				t.Errorf("failed to list keys after extension request: %v", err)
+			}
+		})
+	}
+}
+
+func TestManagerExtensions(t *testing.T) {
+	t.Parallel()
+
+	extAgent := NewExtensionAgent(agent.NewKeyring().(agent.ExtendedAgent))
+	extAgent.Register("test@example.com", func(contents []byte) ([]byte, error) { return nil, nil })
+
+	testcases := []struct {
+		description string
+		agent       agent.Agent
+		want        []string
+	}{
+		{
+			description: "extension agent lists registered extensions",
+			agent:       extAgent,
+			want:        []string{"query", "test@example.com"},
+		},
+		{
+			description: "agent without extensions",
+			agent:       agent.NewKeyring(),
+			want:        []string{},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				mgr := NewManager(tc.agent, storage.NewRaw(st.NewMemArea()), storage.NewRaw(st.NewMemArea()), storage.NewRaw(st.NewMemArea()))
+				got, err := mgr.Extensions(ctx)
+				if err != nil {
+					t.Fatalf("Extensions failed: %v", err)
+				}
+				if diff := cmp.Diff(got, tc.want); diff != "" {
+					t.Errorf("incorrect extensions; -got +want: %s", diff)
+				}
+			})
+		})
+	}
+}
+
+func TestParseQueryResponse(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		rsp         []byte
+		want        []string
+		wantErr     error
+	}{
+		{
+			description: "extensions listed",
+			rsp:         append([]byte{agentSuccess}, nameList("query", "test@example.com")...),
+			want:        []string{"query", "test@example.com"},
+		},
+		{
+			description: "no extensions",
+			rsp:         []byte{agentSuccess},
+			want:        []string{},
+		},
+		{
+			description: "failure response",
+			rsp:         []byte{5}, // SSH_AGENT_FAILURE
+			wantErr:     errQueryFailed,
+		},
+		{
+			description: "truncated name",
+			rsp:         append([]byte{agentSuccess}, 0, 0, 0, 10, 'a'),
+			wantErr:     errQueryFailed,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseQueryResponse(tc.rsp)
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Errorf("incorrect extensions; -got +want: %s", diff)
+			}
+			if diff := cmp.Diff(err, tc.wantErr, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("incorrect error; -got +want: %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fakes provides fake implementations of interfaces used to manage
+// keys, for use in tests.
+package fakes
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// Op identifies an operation requested of an agent.
+type Op string
+
+const (
+	OpList      Op = "List"
+	OpSign      Op = "Sign"
+	OpAdd       Op = "Add"
+	OpRemove    Op = "Remove"
+	OpRemoveAll Op = "RemoveAll"
+	OpLock      Op = "Lock"
+	OpUnlock    Op = "Unlock"
+	OpSigners   Op = "Signers"
+	OpExtension Op = "Extension"
+)
+
+// Call records an operation requested of an agent, along with its arguments
+// and result. Fields that do not apply to the operation are left unset.
+type Call struct {
+	Op Op
+	// Added is the key supplied to Add.
+	Added *agent.AddedKey
+	// Key is the public key supplied to Sign or Remove.
+	Key ssh.PublicKey
+	// Data is the data supplied to Sign.
+	Data []byte
+	// Flags are the flags supplied to Sign.
+	Flags agent.SignatureFlags
+	// Passphrase is the passphrase supplied to Lock or Unlock.
+	Passphrase []byte
+	// ExtensionType and Contents are supplied to Extension.
+	ExtensionType string
+	Contents      []byte
+	// Err is the error returned by the operation.
+	Err error
+}
+
+// RecordingAgent is an agent that records every operation requested of it,
+// so that tests can check exactly what was asked of the agent. Operations are
+// performed by an underlying agent.
+type RecordingAgent struct {
+	agt agent.ExtendedAgent
+
+	// mu guards fields below.
+	mu    sync.Mutex
+	calls []*Call
+}
+
+// NewRecordingAgent returns a RecordingAgent that performs operations using
+// the supplied agent. If agt is nil, an in-memory keyring is used.
+func NewRecordingAgent(agt agent.ExtendedAgent) *RecordingAgent {
+	if agt == nil {
+		agt = agent.NewKeyring().(agent.ExtendedAgent)
+	}
+	return &RecordingAgent{agt: agt}
+}
+
+// record appends the call to those recorded.
+func (a *RecordingAgent) record(c *Call) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.calls = append(a.calls, c)
+}
+
+// List implements agent.Agent.List.
+func (a *RecordingAgent) List() ([]*agent.Key, error) {
+	keys, err := a.agt.List()
+	a.record(&Call{Op: OpList, Err: err})
+	return keys, err
+}
+
+// Sign implements agent.Agent.Sign.
+func (a *RecordingAgent) Sign(key ssh.PublicKey, data []byte) (*ssh.Signature, error) {
+	sig, err := a.agt.Sign(key, data)
+	a.record(&Call{Op: OpSign, Key: key, Data: bytes.Clone(data), Err: err})
+	return sig, err
+}
+
+// SignWithFlags implements agent.ExtendedAgent.SignWithFlags.
+func (a *RecordingAgent) SignWithFlags(key ssh.PublicKey, data []byte, flags agent.SignatureFlags) (*ssh.Signature, error) {
+	sig, err := a.agt.SignWithFlags(key, data, flags)
+	a.record(&Call{Op: OpSign, Key: key, Data: bytes.Clone(data), Flags: flags, Err: err})
+	return sig, err
+}
+
+// Add implements agent.Agent.Add.
+func (a *RecordingAgent) Add(key agent.AddedKey) error {
+	err := a.agt.Add(key)
+	added := key
+	added.ConstraintExtensions = append([]agent.ConstraintExtension(nil), key.ConstraintExtensions...)
+	a.record(&Call{Op: OpAdd, Added: &added, Err: err})
+	return err
+}
+
+// Remove implements agent.Agent.Remove.
+func (a *RecordingAgent) Remove(key ssh.PublicKey) error {
+	err := a.agt.Remove(key)
+	a.record(&Call{Op: OpRemove, Key: key, Err: err})
+	return err
+}
+
+// RemoveAll implements agent.Agent.RemoveAll.
+func (a *RecordingAgent) RemoveAll() error {
+	err := a.agt.RemoveAll()
+	a.record(&Call{Op: OpRemoveAll, Err: err})
+	return err
+}
+
+// Lock implements agent.Agent.Lock.
+func (a *RecordingAgent) Lock(passphrase []byte) error {
+	err := a.agt.Lock(passphrase)
+	a.record(&Call{Op: OpLock, Passphrase: bytes.Clone(passphrase), Err: err})
+	return err
+}
+
+// Unlock implements agent.Agent.Unlock.
+func (a *RecordingAgent) Unlock(passphrase []byte) error {
+	err := a.agt.Unlock(passphrase)
+	a.record(&Call{Op: OpUnlock, Passphrase: bytes.Clone(passphrase), Err: err})
+	return err
+}
+
+// Signers implements agent.Agent.Signers.
+func (a *RecordingAgent) Signers() ([]ssh.Signer, error) {
+	signers, err := a.agt.Signers()
+	a.record(&Call{Op: OpSigners, Err: err})
+	return signers, err
+}
+
+// Extension implements agent.ExtendedAgent.Extension.
+func (a *RecordingAgent) Extension(extensionType string, contents []byte) ([]byte, error) {
+	rsp, err := a.agt.Extension(extensionType, contents)
+	a.record(&Call{Op: OpExtension, ExtensionType: extensionType, Contents: bytes.Clone(contents), Err: err})
+	return rsp, err
+}
+
+// Calls returns the operations requested of the agent, in the order in which
+// they were requested.
+func (a *RecordingAgent) Calls() []*Call {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]*Call(nil), a.calls...)
+}
+
+// CallsTo returns the requests for the specified operation, in the order in
+// which they were made.
+func (a *RecordingAgent) CallsTo(op Op) []*Call {
+	var result []*Call
+	for _, c := range a.Calls() {
+		if c.Op == op {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// Reset discards the recorded operations. The keys held by the agent are
+// unaffected.
+func (a *RecordingAgent) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.calls = nil
+}
+
+// AddedKey returns the key most recently added to the agent with the
+// specified name, or nil if there is none. Keys added by the extension have
+// comments of the form '<name> (chrome-ssh-agent:<id>)', so a comment
+// beginning with the name also matches.
+func (a *RecordingAgent) AddedKey(name string) *agent.AddedKey {
+	calls := a.CallsTo(OpAdd)
+	for i := len(calls) - 1; i >= 0; i-- {
+		c := calls[i].Added.Comment
+		if c == name || strings.HasPrefix(c, name+" (") {
+			return calls[i].Added
+		}
+	}
+	return nil
+}
+
+// ExpectOps reports an error if the operations requested of the agent differ
+// from those expected.
+func (a *RecordingAgent) ExpectOps(t testing.TB, want ...Op) {
+	t.Helper()
+
+	var got []Op
+	for _, c := range a.Calls() {
+		got = append(got, c.Op)
+	}
+	if len(got) != len(want) {
+		t.Errorf("incorrect agent operations: got %v, want %v", got, want)
+		return
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("incorrect agent operations: got %v, want %v", got, want)
+			return
+		}
+	}
+}
+
+// ExpectAdded reports an error unless a key with the specified name was added
+// to the agent with the specified constraints.
+func (a *RecordingAgent) ExpectAdded(t testing.TB, name string, lifetimeSecs uint32, confirmBeforeUse bool) {
+	t.Helper()
+
+	k := a.AddedKey(name)
+	if k == nil {
+		t.Errorf("key %s not added to agent", name)
+		return
+	}
+	if k.LifetimeSecs != lifetimeSecs {
+		t.Errorf("key %s added with lifetime %d seconds; want %d", name, k.LifetimeSecs, lifetimeSecs)
+	}
+	if k.ConfirmBeforeUse != confirmBeforeUse {
+		t.Errorf("key %s added with confirm-before-use %v; want %v", name, k.ConfirmBeforeUse, confirmBeforeUse)
+	}
+}
+
+// ExpectNotCalled reports an error if the specified operation was requested
+// of the agent.
+func (a *RecordingAgent) ExpectNotCalled(t testing.TB, op Op) {
+	t.Helper()
+
+	if n := len(a.CallsTo(op)); n > 0 {
+		t.Errorf("agent operation %s requested %d times; want none", op, n)
+	}
+}
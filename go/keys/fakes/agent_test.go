@@ -0,0 +1,83 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakes
+
+import (
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestRecordingAgent(t *testing.T) {
+	t.Parallel()
+
+	priv, err := ssh.ParseRawPrivateKey([]byte(testdata.WithoutPassphrase.Private))
+	if err != nil {
+		t.Fatalf("failed to parse private key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+
+	agt := NewRecordingAgent(nil)
+	if err := agt.Add(agent.AddedKey{
+		PrivateKey:       priv,
+		Comment:          "some-key (chrome-ssh-agent:1)",
+		LifetimeSecs:     60,
+		ConfirmBeforeUse: true,
+	}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	keys, err := agt.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(keys) != 1 {
+		t.Fatalf("incorrect number of keys; got %d, want 1", len(keys))
+	}
+	if _, err := agt.Sign(signer.PublicKey(), []byte("data")); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if err := agt.Remove(signer.PublicKey()); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+
+	agt.ExpectOps(t, OpAdd, OpList, OpSign, OpRemove)
+	agt.ExpectAdded(t, "some-key", 60, true)
+	agt.ExpectNotCalled(t, OpRemoveAll)
+	if got := string(agt.CallsTo(OpSign)[0].Data); got != "data" {
+		t.Errorf("incorrect signed data; got %q, want %q", got, "data")
+	}
+	if agt.AddedKey("other-key") != nil {
+		t.Errorf("AddedKey returned key that was not added")
+	}
+
+	// Failed operations are recorded along with their error.
+	if err := agt.Remove(signer.PublicKey()); err == nil {
+		t.Errorf("Remove of missing key unexpectedly succeeded")
+	}
+	if calls := agt.CallsTo(OpRemove); calls[len(calls)-1].Err == nil {
+		t.Errorf("failed Remove recorded without error")
+	}
+
+	// Reset discards the record, but not the agent's keys.
+	agt.Reset()
+	if calls := agt.Calls(); len(calls) != 0 {
+		t.Errorf("calls remain after Reset: %v", calls)
+	}
+}
@@ -0,0 +1,264 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"fmt"
+	"sync"
+	"syscall/js"
+	"time"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+var (
+	// activityPrefixes are the prefixes for the time at which the agent
+	// was last used. It is kept in session storage so that the idle
+	// timeout survives restarts of the background worker.
+	activityPrefixes = []string{"agentActivity"}
+)
+
+const (
+	// lastActivityKey is the key at which the time the agent was last
+	// used is stored, in milliseconds since the Unix epoch.
+	lastActivityKey = "lastActivity"
+)
+
+// IdleFunc is invoked when the agent has not been used for the idle timeout.
+//
+// IdleFunc is invoked from CheckIdle, and must not block.
+type IdleFunc func()
+
+// ActiveFunc is invoked with the time at which the agent was last used, so
+// that it may be persisted and supplied to SetLastUsed after a restart.
+//
+// ActiveFunc is invoked on the goroutine serving the agent request, and must
+// not block.
+type ActiveFunc func(lastUsed time.Time)
+
+// ScheduleFunc arranges for CheckIdle to be invoked at the supplied time
+// (e.g., using an alarm that survives restarts of the background worker).
+// A schedule may replace one made earlier.
+//
+// ScheduleFunc must not block.
+type ScheduleFunc func(when time.Time)
+
+// IdleAgent wraps an agent and tracks the time at which it was last used
+// to list keys or sign data. Once the agent has not been used for the idle
+// timeout, the IdleFunc is invoked (e.g., to unload all keys).
+type IdleAgent struct {
+	agent.ExtendedAgent
+
+	mu       sync.Mutex
+	onIdle   IdleFunc
+	onActive ActiveFunc
+	schedule ScheduleFunc
+	// configured indicates that an idle timeout has been supplied.
+	configured bool
+	// timeout is the period of inactivity after which onIdle is invoked.
+	// Zero indicates that the idle timer is disabled.
+	timeout time.Duration
+	// lastUsed is the time at which the agent was last used, or the time
+	// at which the idle timeout was changed if more recent.
+	lastUsed time.Time
+	// pending is the time at which CheckIdle is scheduled to be invoked,
+	// or zero if it is not scheduled.
+	pending time.Time
+	// idle indicates that onIdle has been invoked since the agent was
+	// last used.
+	idle bool
+}
+
+// NewIdleAgent returns an IdleAgent wrapping the supplied agent. The idle
+// timer is disabled until a timeout is supplied via SetIdleTimeout.
+func NewIdleAgent(agt agent.ExtendedAgent, schedule ScheduleFunc, onActive ActiveFunc, onIdle IdleFunc) *IdleAgent {
+	return &IdleAgent{
+		ExtendedAgent: agt,
+		onIdle:        onIdle,
+		onActive:      onActive,
+		schedule:      schedule,
+	}
+}
+
+// SetLastUsed sets the time at which the agent was last used, as persisted
+// by an earlier instance of the agent. It must be invoked before the first
+// call to SetIdleTimeout.
+func (a *IdleAgent) SetLastUsed(lastUsed time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.lastUsed = lastUsed
+}
+
+// SetIdleTimeout sets the period of inactivity after which the IdleFunc is
+// invoked. The first timeout supplied is measured from the time set by
+// SetLastUsed, if any; the period is otherwise measured from now. A zero
+// timeout disables the idle timer.
+func (a *IdleAgent) SetIdleTimeout(timeout time.Duration) {
+	a.mu.Lock()
+	if a.configured && timeout == a.timeout {
+		a.mu.Unlock()
+		return
+	}
+	restart := a.configured || a.lastUsed.IsZero()
+	a.configured = true
+	a.timeout = timeout
+	a.idle = false
+	if restart {
+		a.lastUsed = time.Now()
+	}
+	lastUsed := a.lastUsed
+	a.scheduleLocked()
+	a.mu.Unlock()
+
+	if restart {
+		a.onActive(lastUsed)
+	}
+}
+
+// touch records that the agent was used.
+func (a *IdleAgent) touch() {
+	a.mu.Lock()
+	now := time.Now()
+	a.lastUsed = now
+	a.idle = false
+	a.scheduleLocked()
+	a.mu.Unlock()
+
+	a.onActive(now)
+}
+
+// scheduleLocked schedules CheckIdle for the time at which the agent will
+// have been idle for the timeout, unless it is already scheduled to be
+// invoked sooner. A check that occurs early reschedules itself. a.mu must
+// be held.
+func (a *IdleAgent) scheduleLocked() {
+	if a.timeout <= 0 || a.idle {
+		return
+	}
+	when := a.lastUsed.Add(a.timeout)
+	if !a.pending.IsZero() && !a.pending.After(when) {
+		return
+	}
+	a.pending = when
+	a.schedule(when)
+}
+
+// CheckIdle invokes the IdleFunc if the agent has been idle for the timeout,
+// or reschedules the check otherwise. It is invoked at the time requested
+// via the ScheduleFunc.
+func (a *IdleAgent) CheckIdle() {
+	a.mu.Lock()
+	a.pending = time.Time{}
+	if a.timeout <= 0 || a.idle {
+		a.mu.Unlock()
+		return
+	}
+	if time.Until(a.lastUsed.Add(a.timeout)) > 0 {
+		// The agent was used since the check was scheduled.
+		a.scheduleLocked()
+		a.mu.Unlock()
+		return
+	}
+	a.idle = true
+	timeout := a.timeout
+	a.mu.Unlock()
+
+	jsutil.LogDebug("IdleAgent: idle for %s", timeout)
+	a.onIdle()
+}
+
+// List implements agent.Agent.List.
+func (a *IdleAgent) List() ([]*agent.Key, error) {
+	a.touch()
+	return a.ExtendedAgent.List()
+}
+
+// Sign implements agent.Agent.Sign.
+func (a *IdleAgent) Sign(key ssh.PublicKey, data []byte) (*ssh.Signature, error) {
+	a.touch()
+	return a.ExtendedAgent.Sign(key, data)
+}
+
+// SignWithFlags implements agent.ExtendedAgent.SignWithFlags.
+func (a *IdleAgent) SignWithFlags(key ssh.PublicKey, data []byte, flags agent.SignatureFlags) (*ssh.Signature, error) {
+	a.touch()
+	return a.ExtendedAgent.SignWithFlags(key, data, flags)
+}
+
+// UnloadIdle unloads all keys from the agent after it has been idle, and
+// informs the user if enabled in their preferences. It is intended to be
+// invoked from an IdleFunc.
+func (s *Server) UnloadIdle(ctx jsutil.AsyncContext) {
+	loaded, err := s.mgr.Loaded(ctx)
+	if err != nil {
+		jsutil.LogError("Server.UnloadIdle: failed to enumerate loaded keys: %v", err)
+		return
+	}
+	if len(loaded) == 0 {
+		return
+	}
+
+	if err := s.mgr.UnloadAll(ctx); err != nil {
+		jsutil.LogError("Server.UnloadIdle: failed to unload keys: %v", err)
+	}
+	s.UpdateBadge(ctx)
+	if s.notifyEnabled(ctx) {
+		s.notify("SSH keys unloaded", "All keys were unloaded after the agent was idle.")
+	}
+}
+
+// MarkActive records the time at which the agent was last used, so that the
+// idle timeout is honored across restarts of the background worker.
+func (m *DefaultManager) MarkActive(ctx jsutil.AsyncContext, lastUsed time.Time) error {
+	data := map[string]js.Value{
+		lastActivityKey: js.ValueOf(float64(lastUsed.UnixMilli())),
+	}
+	if err := m.activity.Set(ctx, data); err != nil {
+		return fmt.Errorf("failed to write agent activity: %w", err)
+	}
+	return nil
+}
+
+// LastActive returns the time recorded by MarkActive, or the zero time if
+// none has been recorded in the current session.
+func (m *DefaultManager) LastActive(ctx jsutil.AsyncContext) (time.Time, error) {
+	data, err := m.activity.Get(ctx)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read agent activity: %w", err)
+	}
+	val, present := data[lastActivityKey]
+	if !present {
+		return time.Time{}, nil
+	}
+	if val.Type() != js.TypeNumber {
+		return time.Time{}, fmt.Errorf("invalid agent activity: has type %s", val.Type())
+	}
+	return time.UnixMilli(int64(val.Float())), nil
+}
+
+// idleSince returns the time from which the agent has been idle, for the
+// purpose of unloading the supplied session key. Loading a key counts as
+// activity, so a key loaded after the agent was last used is not unloaded
+// until the timeout has passed since it was loaded.
+func (k *sessionKey) idleSince(lastActive time.Time) time.Time {
+	if loaded := time.Unix(k.LoadedAt, 0); loaded.After(lastActive) {
+		return loaded
+	}
+	return lastActive
+}
@@ -0,0 +1,327 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// newTestIdleAgent returns an IdleAgent that checks for idleness using
+// timers.
+func newTestIdleAgent(onActive ActiveFunc, onIdle IdleFunc) *IdleAgent {
+	var agt *IdleAgent
+	agt = NewIdleAgent(agent.NewKeyring().(agent.ExtendedAgent), func(when time.Time) {
+		jsutil.SetTimeout(time.Until(when), agt.CheckIdle)
+	}, onActive, onIdle)
+	return agt
+}
+
+func TestIdleAgent(t *testing.T) {
+	t.Parallel()
+
+	const timeout = 200 * time.Millisecond
+
+	testcases := []struct {
+		description string
+		// setup configures the agent, returning the number of times the
+		// agent is expected to have been idle afterwards.
+		setup    func(agt *IdleAgent)
+		wantIdle int32
+	}{
+		{
+			description: "disabled by default",
+			setup:       func(agt *IdleAgent) {},
+			wantIdle:    0,
+		},
+		{
+			description: "idle after timeout",
+			setup: func(agt *IdleAgent) {
+				agt.SetIdleTimeout(timeout)
+			},
+			wantIdle: 1,
+		},
+		{
+			description: "idle once until used again",
+			setup: func(agt *IdleAgent) {
+				agt.SetIdleTimeout(timeout)
+				time.Sleep(3 * timeout)
+			},
+			wantIdle: 1,
+		},
+		{
+			description: "use resets timer",
+			setup: func(agt *IdleAgent) {
+				agt.SetIdleTimeout(timeout)
+				for i := 0; i < 6; i++ {
+					time.Sleep(timeout / 4)
+					agt.List()
+				}
+			},
+			wantIdle: 1,
+		},
+		{
+			description: "use after idle restarts timer",
+			setup: func(agt *IdleAgent) {
+				agt.SetIdleTimeout(timeout)
+				time.Sleep(2 * timeout)
+				agt.List()
+			},
+			wantIdle: 2,
+		},
+		{
+			description: "disable cancels timer",
+			setup: func(agt *IdleAgent) {
+				agt.SetIdleTimeout(timeout)
+				agt.SetIdleTimeout(0)
+				agt.List()
+			},
+			wantIdle: 0,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				var idle atomic.Int32
+				agt := newTestIdleAgent(func(time.Time) {}, func() {
+					idle.Add(1)
+				})
+
+				start := time.Now()
+				tc.setup(agt)
+				// The agent remains unused during the time taken by
+				// setup, so it is idle within twice the timeout.
+				time.Sleep(2 * timeout)
+
+				if got := idle.Load(); got != tc.wantIdle {
+					t.Errorf("incorrect idle count after %s; got %d, want %d", time.Since(start), got, tc.wantIdle)
+				}
+			})
+		})
+	}
+}
+
+func TestIdleAgentRestart(t *testing.T) {
+	t.Parallel()
+
+	const timeout = 200 * time.Millisecond
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		var lastUsed time.Time
+		first := newTestIdleAgent(func(t time.Time) { lastUsed = t }, func() {})
+		first.SetIdleTimeout(timeout)
+		first.List()
+		if lastUsed.IsZero() {
+			t.Fatalf("time of last use not reported")
+		}
+
+		// A restarted agent continues the timeout from the last use
+		// reported by the earlier agent, rather than from when the
+		// timeout is set.
+		time.Sleep(timeout / 2)
+		var idle atomic.Int32
+		second := newTestIdleAgent(func(time.Time) {}, func() {
+			idle.Add(1)
+		})
+		second.SetLastUsed(lastUsed)
+		second.SetIdleTimeout(timeout)
+		time.Sleep(3 * timeout / 4)
+		if got := idle.Load(); got != 1 {
+			t.Errorf("incorrect idle count after restart; got %d, want 1", got)
+		}
+	})
+}
+
+func TestLoadFromSessionIdle(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		idleMins    uint32
+		lastActive  time.Duration
+		wantLoaded  bool
+	}{
+		{
+			description: "recently active",
+			idleMins:    5,
+			lastActive:  time.Minute,
+			wantLoaded:  true,
+		},
+		{
+			description: "idle for timeout",
+			idleMins:    5,
+			lastActive:  10 * time.Minute,
+			wantLoaded:  false,
+		},
+		{
+			description: "idle timeout disabled",
+			lastActive:  10 * time.Minute,
+			wantLoaded:  true,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				// Storage persists across restarts of the
+				// background worker.
+				syncStorage := storage.NewRaw(st.NewMemArea())
+				sessionStorage := storage.NewRaw(st.NewMemArea())
+
+				mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, []*initialKey{
+					{
+						Name:          "good-key",
+						PEMPrivateKey: testdata.WithoutPassphrase.Private,
+						Load:          true,
+					},
+				})
+				if err != nil {
+					t.Fatalf("failed to initialize manager: %v", err)
+				}
+				if err := mgr.SetPreferences(ctx, &Preferences{IdleUnloadMins: tc.idleMins}); err != nil {
+					t.Fatalf("failed to set preferences: %v", err)
+				}
+
+				// Simulate a key that was loaded, and an agent
+				// that was last used, before the worker was
+				// suspended.
+				loadedAt := time.Now().Add(-tc.lastActive).Unix()
+				if err := mgr.sessionKeys.Update(ctx, func(sk *sessionKey) bool {
+					sk.LoadedAt = loadedAt
+					return true
+				}); err != nil {
+					t.Fatalf("failed to update session keys: %v", err)
+				}
+				if err := mgr.MarkActive(ctx, time.Now().Add(-tc.lastActive)); err != nil {
+					t.Fatalf("failed to record activity: %v", err)
+				}
+
+				// Restart with a new manager and agent.
+				restarted := NewManager(agent.NewKeyring(), syncStorage, storage.NewRaw(st.NewMemArea()), sessionStorage)
+				if err := restarted.LoadFromSession(ctx); err != nil {
+					t.Fatalf("failed to load keys from session: %v", err)
+				}
+				loaded, err := restarted.Loaded(ctx)
+				if err != nil {
+					t.Fatalf("failed to enumerate loaded keys: %v", err)
+				}
+				if got := len(loaded) > 0; got != tc.wantLoaded {
+					t.Errorf("incorrect loaded state after restart; got %t, want %t", got, tc.wantLoaded)
+				}
+			})
+		})
+	}
+}
+
+func TestUnloadIdle(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		initial     []*initialKey
+		prefs       *Preferences
+		want        []notification
+	}{
+		{
+			description: "unload loaded keys",
+			initial: []*initialKey{
+				{
+					Name:          "good-key",
+					PEMPrivateKey: testdata.WithoutPassphrase.Private,
+					Load:          true,
+				},
+			},
+			prefs: &Preferences{NotifyLoad: true},
+			want: []notification{
+				{Title: "SSH keys unloaded", Message: "All keys were unloaded after the agent was idle."},
+			},
+		},
+		{
+			description: "no notification without loaded keys",
+			initial: []*initialKey{
+				{
+					Name:          "good-key",
+					PEMPrivateKey: testdata.WithoutPassphrase.Private,
+				},
+			},
+			prefs: &Preferences{NotifyLoad: true},
+		},
+		{
+			description: "notification disabled by preferences",
+			initial: []*initialKey{
+				{
+					Name:          "good-key",
+					PEMPrivateKey: testdata.WithoutPassphrase.Private,
+					Load:          true,
+				},
+			},
+			prefs: &Preferences{},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				syncStorage := storage.NewRaw(st.NewMemArea())
+				sessionStorage := storage.NewRaw(st.NewMemArea())
+				mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, tc.initial)
+				if err != nil {
+					t.Fatalf("failed to initialize manager: %v", err)
+				}
+				if err := mgr.SetPreferences(ctx, tc.prefs); err != nil {
+					t.Fatalf("failed to set preferences: %v", err)
+				}
+
+				srv := NewServer(mgr)
+				var got []notification
+				srv.SetNotify(func(title, message string) {
+					got = append(got, notification{Title: title, Message: message})
+				})
+
+				srv.UnloadIdle(ctx)
+
+				loaded, err := mgr.Loaded(ctx)
+				if err != nil {
+					t.Errorf("failed to enumerate loaded keys: %v", err)
+				}
+				if len(loaded) != 0 {
+					t.Errorf("incorrect loaded keys; got %d, want 0", len(loaded))
+				}
+				if diff := cmp.Diff(got, tc.want); diff != "" {
+					t.Errorf("incorrect notifications; -got +want: %s", diff)
+				}
+			})
+		})
+	}
+}
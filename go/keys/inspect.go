@@ -0,0 +1,179 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// KeyInfo describes a private key without configuring it.
+type KeyInfo struct {
+	// Type is the type of key (e.g., 'ssh-rsa'). It is empty if the
+	// public key cannot be determined without a passphrase.
+	Type string
+	// Fingerprint is the SHA256 fingerprint of the public key. It is
+	// empty if the public key cannot be determined without a passphrase.
+	Fingerprint string
+	// Comment is the comment embedded in the private key, if any.
+	Comment string
+	// Bits is the size of the key in bits. It is zero if the public key
+	// cannot be determined without a passphrase.
+	Bits int
+	// Encrypted indicates if the private key is encrypted and requires a
+	// passphrase to load.
+	Encrypted bool
+}
+
+var (
+	errNoKey = errors.New("no private key supplied")
+)
+
+// Inspect parses the private key and describes it, without configuring it.
+// passphrase is used to decrypt the private key if the public key cannot
+// otherwise be determined; if it is empty, the description of an encrypted
+// key may be incomplete.
+func Inspect(privateKey, passphrase string) (*KeyInfo, error) {
+	if strings.TrimSpace(privateKey) == "" {
+		return nil, errNoKey
+	}
+	if err := validatePPK(privateKey); err != nil {
+		return nil, err
+	}
+
+	sk := &storedKey{PEMPrivateKey: privateKey}
+	enc, err := detectEncryption(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	info := &KeyInfo{
+		Comment:   sk.Comment(),
+		Encrypted: enc == encryptionPassphrase || sk.Encrypted(),
+	}
+
+	pub := sk.PublicKey()
+	if pub == nil {
+		if info.Encrypted && passphrase == "" {
+			return info, nil
+		}
+		// Decrypting the key reports why it could not be parsed.
+		decrypted, err := decryptKey(sk, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		priv, err := parseDecryptedKey(decrypted)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errParseFailed, err)
+		}
+		signer, err := ssh.NewSignerFromKey(priv)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errParseFailed, err)
+		}
+		pub = signer.PublicKey()
+	}
+
+	info.Type = pub.Type()
+	info.Fingerprint = ssh.FingerprintSHA256(pub)
+	info.Bits = keyBits(pub)
+	return info, nil
+}
+
+// keyBits returns the size of the public key in bits, or zero if unknown.
+// Certificates are sized by the key they certify.
+func keyBits(pub ssh.PublicKey) int {
+	if cert, ok := pub.(*ssh.Certificate); ok {
+		pub = cert.Key
+	}
+	cpk, ok := pub.(ssh.CryptoPublicKey)
+	if !ok {
+		// Security key types do not expose the underlying key.
+		if isSecurityKeyType(pub.Type()) {
+			return 256
+		}
+		return 0
+	}
+	switch k := cpk.CryptoPublicKey().(type) {
+	case *rsa.PublicKey:
+		return k.N.BitLen()
+	case *ecdsa.PublicKey:
+		return k.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return 8 * ed25519.PublicKeySize
+	case *dsa.PublicKey:
+		return k.P.BitLen()
+	default:
+		return 0
+	}
+}
+
+// keyAlgorithm returns a normalized description of the public key's
+// algorithm (e.g., 'RSA', 'ECDSA P-256', 'Ed25519'), or the empty string if
+// unknown. Certificates are described by the key they certify.
+func keyAlgorithm(pub ssh.PublicKey) string {
+	if cert, ok := pub.(*ssh.Certificate); ok {
+		pub = cert.Key
+	}
+	cpk, ok := pub.(ssh.CryptoPublicKey)
+	if !ok {
+		// Security key types do not expose the underlying key.
+		switch pub.Type() {
+		case ssh.KeyAlgoSKECDSA256:
+			return "ECDSA-SK P-256"
+		case ssh.KeyAlgoSKED25519:
+			return "Ed25519-SK"
+		}
+		return ""
+	}
+	switch k := cpk.CryptoPublicKey().(type) {
+	case *rsa.PublicKey:
+		return "RSA"
+	case *ecdsa.PublicKey:
+		return "ECDSA " + k.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "Ed25519"
+	case *dsa.PublicKey:
+		return "DSA"
+	default:
+		return ""
+	}
+}
+
+// DescribePublicKey returns a normalized description of the algorithm
+// (e.g., 'RSA', 'ECDSA P-256', 'Ed25519') and the size in bits of the public
+// key material in the supplied blob, as listed by the agent. The algorithm
+// is empty and the size zero if they cannot be determined.
+func DescribePublicKey(blob []byte) (algorithm string, bits int) {
+	pub, err := ssh.ParsePublicKey(blob)
+	if err != nil {
+		return "", 0
+	}
+	return keyAlgorithm(pub), keyBits(pub)
+}
+
+// fingerprintMD5 returns the legacy MD5 fingerprint of the public key, in
+// the form displayed by 'ssh-keygen -E md5' (e.g., 'MD5:a1:b2:...'). Some
+// older systems display only this form.
+func fingerprintMD5(pub ssh.PublicKey) string {
+	return "MD5:" + ssh.FingerprintLegacyMD5(pub)
+}
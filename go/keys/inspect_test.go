@@ -0,0 +1,248 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/crypto/ssh"
+)
+
+// fingerprint returns the SHA256 fingerprint of the base64-encoded public
+// key.
+func fingerprint(t *testing.T, blob string) string {
+	t.Helper()
+	b, err := base64.StdEncoding.DecodeString(blob)
+	if err != nil {
+		t.Fatalf("failed to decode blob: %v", err)
+	}
+	pub, err := ssh.ParsePublicKey(b)
+	if err != nil {
+		t.Fatalf("failed to parse public key: %v", err)
+	}
+	return ssh.FingerprintSHA256(pub)
+}
+
+func TestFingerprintMD5(t *testing.T) {
+	t.Parallel()
+
+	b, err := base64.StdEncoding.DecodeString(testdata.WithoutPassphrase.Blob)
+	if err != nil {
+		t.Fatalf("failed to decode blob: %v", err)
+	}
+	pub, err := ssh.ParsePublicKey(b)
+	if err != nil {
+		t.Fatalf("failed to parse public key: %v", err)
+	}
+	// As output by 'ssh-keygen -l -E md5'.
+	want := "MD5:48:49:9b:42:21:7c:a0:e8:af:2f:39:8a:00:17:f6:87"
+	if diff := cmp.Diff(fingerprintMD5(pub), want); diff != "" {
+		t.Errorf("incorrect fingerprint; -got +want: %s", diff)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		privateKey  string
+		passphrase  string
+		wantBlob    string
+		want        *KeyInfo
+		wantErr     error
+	}{
+		{
+			description: "unencrypted rsa key",
+			privateKey:  testdata.WithoutPassphrase.Private,
+			wantBlob:    testdata.WithoutPassphrase.Blob,
+			want: &KeyInfo{
+				Type: testdata.WithoutPassphrase.Type,
+				Bits: 2048,
+			},
+		},
+		{
+			description: "unencrypted ecdsa key",
+			privateKey:  testdata.ECDSAWithoutPassphrase.Private,
+			wantBlob:    testdata.ECDSAWithoutPassphrase.Blob,
+			want: &KeyInfo{
+				Type: testdata.ECDSAWithoutPassphrase.Type,
+				Bits: 521,
+			},
+		},
+		{
+			description: "unencrypted openssh key with comment",
+			privateKey:  testdata.ED25519WithoutPassphrase.Private,
+			wantBlob:    testdata.ED25519WithoutPassphrase.Blob,
+			want: &KeyInfo{
+				Type:    testdata.ED25519WithoutPassphrase.Type,
+				Comment: "richard_alimi_gmail_com@workstation",
+				Bits:    256,
+			},
+		},
+		{
+			description: "security key",
+			privateKey:  testdata.SecurityKeyED25519.Private,
+			wantBlob:    testdata.SecurityKeyED25519.Blob,
+			want: &KeyInfo{
+				Type:    testdata.SecurityKeyED25519.Type,
+				Comment: "security-key",
+				Bits:    256,
+			},
+		},
+		{
+			description: "encrypted openssh key without passphrase",
+			privateKey:  testdata.ED25519WithPassphrase.Private,
+			wantBlob:    testdata.ED25519WithPassphrase.Blob,
+			want: &KeyInfo{
+				Type:      testdata.ED25519WithPassphrase.Type,
+				Bits:      256,
+				Encrypted: true,
+			},
+		},
+		{
+			description: "encrypted pem key without passphrase",
+			privateKey:  testdata.WithPassphrase.Private,
+			want: &KeyInfo{
+				Encrypted: true,
+			},
+		},
+		{
+			description: "encrypted pem key with passphrase",
+			privateKey:  testdata.WithPassphrase.Private,
+			passphrase:  testdata.WithPassphrase.Passphrase,
+			wantBlob:    testdata.WithPassphrase.Blob,
+			want: &KeyInfo{
+				Type:      testdata.WithPassphrase.Type,
+				Bits:      2048,
+				Encrypted: true,
+			},
+		},
+		{
+			description: "encrypted ppk key",
+			privateKey:  testdata.PPKv3WithPassphrase.Private,
+			wantBlob:    testdata.PPKv3WithPassphrase.Blob,
+			want: &KeyInfo{
+				Type:      testdata.PPKv3WithPassphrase.Type,
+				Comment:   "test-comment",
+				Bits:      256,
+				Encrypted: true,
+			},
+		},
+		{
+			description: "truncated key",
+			privateKey:  testdata.WithoutPassphrase.Private[:len(testdata.WithoutPassphrase.Private)/2],
+			wantErr:     errParseFailed,
+		},
+		{
+			description: "modified ppk key",
+			privateKey:  strings.Replace(testdata.PPKv3WithoutPassphrase.Private, "test-comment", "other-comment", 1),
+			wantErr:     errParseFailed,
+		},
+		{
+			description: "unsupported cipher",
+			privateKey:  testdata.ED25519UnsupportedCipher.Private,
+			wantErr:     ErrUnsupportedCipher,
+		},
+		{
+			description: "empty",
+			privateKey:  " \n",
+			wantErr:     errNoKey,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			info, err := Inspect(tc.privateKey, tc.passphrase)
+			if diff := cmp.Diff(err, tc.wantErr, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("incorrect error; -got +want: %s", diff)
+			}
+			want := tc.want
+			if want != nil && tc.wantBlob != "" {
+				want.Fingerprint = fingerprint(t, tc.wantBlob)
+			}
+			if diff := cmp.Diff(info, want); diff != "" {
+				t.Errorf("incorrect info; -got +want: %s", diff)
+			}
+		})
+	}
+}
+
+func TestDescribePublicKey(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description   string
+		blob          string
+		wantAlgorithm string
+		wantBits      int
+	}{
+		{
+			description:   "rsa key",
+			blob:          testdata.WithoutPassphrase.Blob,
+			wantAlgorithm: "RSA",
+			wantBits:      2048,
+		},
+		{
+			description:   "ecdsa key",
+			blob:          testdata.ECDSAWithoutPassphrase.Blob,
+			wantAlgorithm: "ECDSA P-521",
+			wantBits:      521,
+		},
+		{
+			description:   "ed25519 key",
+			blob:          testdata.ED25519WithoutPassphrase.Blob,
+			wantAlgorithm: "Ed25519",
+			wantBits:      256,
+		},
+		{
+			description:   "certificate",
+			blob:          testdata.ED25519WithCertificate.Blob,
+			wantAlgorithm: "Ed25519",
+			wantBits:      256,
+		},
+		{
+			description: "invalid blob",
+			blob:        base64.StdEncoding.EncodeToString([]byte("bogus")),
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			blob, err := base64.StdEncoding.DecodeString(tc.blob)
+			if err != nil {
+				t.Fatalf("failed to decode blob: %v", err)
+			}
+			algorithm, bits := DescribePublicKey(blob)
+			if diff := cmp.Diff(algorithm, tc.wantAlgorithm); diff != "" {
+				t.Errorf("incorrect algorithm; -got +want: %s", diff)
+			}
+			if diff := cmp.Diff(bits, tc.wantBits); diff != "" {
+				t.Errorf("incorrect bits; -got +want: %s", diff)
+			}
+		})
+	}
+}
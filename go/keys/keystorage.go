@@ -0,0 +1,245 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"errors"
+	"fmt"
+	"syscall/js"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	"github.com/google/chrome-ssh-agent/go/storage"
+)
+
+const (
+	// KeyStorageSync indicates that configured keys are stored in storage
+	// that is synced between the user's devices. This is the default.
+	KeyStorageSync = "sync"
+	// KeyStorageLocal indicates that configured keys are stored on the
+	// local device only.
+	KeyStorageLocal = "local"
+
+	// keyStorageKey is the key at which the key storage setting is
+	// stored.
+	keyStorageKey = "default"
+)
+
+var (
+	// keyStoragePrefixes are the prefixes for the key storage setting.
+	// The setting applies to a single device, so it is kept in local
+	// storage.
+	keyStoragePrefixes = []string{"keyStorage"}
+
+	errInvalidKeyStorage = errors.New("invalid key storage")
+	errSyncQuota         = errors.New("synced storage is full; store keys on this device only to configure larger or additional keys")
+)
+
+// StorageUsage describes the space consumed in the storage area holding
+// configured keys.
+type StorageUsage struct {
+	// Location is the location of the storage area; either KeyStorageSync
+	// or KeyStorageLocal.
+	Location string `js:"location"`
+	// BytesInUse is the total space used in the storage area, including
+	// data other than configured keys.
+	BytesInUse int `js:"bytesInUse"`
+	// QuotaBytes is the maximum total space permitted in the storage
+	// area, or zero if it is not known.
+	QuotaBytes int `js:"quotaBytes"`
+	// QuotaBytesPerItem is the maximum space permitted for a single item
+	// in the storage area, or zero if items are not limited.
+	QuotaBytesPerItem int `js:"quotaBytesPerItem"`
+}
+
+// keyArea is the storage area holding configured keys. It delegates to
+// either synced or local storage, as selected by the key storage setting.
+//
+// keyArea implements the storage.Area interface.
+type keyArea struct {
+	sync    storage.Area
+	local   storage.Area
+	setting *storage.View
+}
+
+func newKeyArea(syncStorage, localStorage storage.Area) *keyArea {
+	return &keyArea{
+		sync:    syncStorage,
+		local:   localStorage,
+		setting: storage.NewView(keyStoragePrefixes, localStorage),
+	}
+}
+
+// location returns the current key storage setting.
+func (a *keyArea) location(ctx jsutil.AsyncContext) (string, error) {
+	data, err := a.setting.Get(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to read key storage setting: %w", err)
+	}
+	val, present := data[keyStorageKey]
+	if !present {
+		return KeyStorageSync, nil
+	}
+	if val.Type() != js.TypeString {
+		return "", fmt.Errorf("%w: setting has type %s", errInvalidKeyStorage, val.Type())
+	}
+	return val.String(), nil
+}
+
+// setLocation updates the key storage setting.
+func (a *keyArea) setLocation(ctx jsutil.AsyncContext, location string) error {
+	if err := a.setting.Set(ctx, map[string]js.Value{keyStorageKey: js.ValueOf(location)}); err != nil {
+		return fmt.Errorf("failed to write key storage setting: %w", err)
+	}
+	return nil
+}
+
+// area returns the storage area for the specified location.
+func (a *keyArea) area(location string) storage.Area {
+	if location == KeyStorageLocal {
+		return a.local
+	}
+	return a.sync
+}
+
+// current returns the currently-selected storage area, along with its
+// location.
+func (a *keyArea) current(ctx jsutil.AsyncContext) (string, storage.Area, error) {
+	location, err := a.location(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	return location, a.area(location), nil
+}
+
+// quotaError explains how to resolve a failure to write keys to the
+// specified location due to exceeding its quota.
+func quotaError(location string, err error) error {
+	if location != KeyStorageSync {
+		return err
+	}
+	if errors.Is(err, storage.ErrItemQuotaExceeded) || errors.Is(err, storage.ErrQuotaExceeded) {
+		return fmt.Errorf("%w: %w", errSyncQuota, err)
+	}
+	return err
+}
+
+// Set implements storage.Area.Set.
+func (a *keyArea) Set(ctx jsutil.AsyncContext, data map[string]js.Value) error {
+	location, area, err := a.current(ctx)
+	if err != nil {
+		return err
+	}
+	return quotaError(location, area.Set(ctx, data))
+}
+
+// Get implements storage.Area.Get.
+func (a *keyArea) Get(ctx jsutil.AsyncContext) (map[string]js.Value, error) {
+	_, area, err := a.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return area.Get(ctx)
+}
+
+// GetKeys implements storage.KeysArea.GetKeys.
+func (a *keyArea) GetKeys(ctx jsutil.AsyncContext, keys []string) (map[string]js.Value, error) {
+	_, area, err := a.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return storage.AreaGetKeys(ctx, area, keys)
+}
+
+// Delete implements storage.Area.Delete.
+func (a *keyArea) Delete(ctx jsutil.AsyncContext, keys []string) error {
+	_, area, err := a.current(ctx)
+	if err != nil {
+		return err
+	}
+	return area.Delete(ctx, keys)
+}
+
+// KeyStorage implements Manager.KeyStorage.
+func (m *DefaultManager) KeyStorage(ctx jsutil.AsyncContext) (string, error) {
+	return m.keyArea.location(ctx)
+}
+
+// StorageUsage implements Manager.StorageUsage.
+func (m *DefaultManager) StorageUsage(ctx jsutil.AsyncContext) (*StorageUsage, error) {
+	location, area, err := m.keyArea.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := storage.AreaUsage(ctx, area)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read storage usage: %w", err)
+	}
+	return &StorageUsage{
+		Location:          location,
+		BytesInUse:        usage.BytesInUse,
+		QuotaBytes:        usage.QuotaBytes,
+		QuotaBytesPerItem: usage.QuotaBytesPerItem,
+	}, nil
+}
+
+// SetKeyStorage implements Manager.SetKeyStorage.
+func (m *DefaultManager) SetKeyStorage(ctx jsutil.AsyncContext, location string) error {
+	if location != KeyStorageSync && location != KeyStorageLocal {
+		return fmt.Errorf("%w: %s", errInvalidKeyStorage, location)
+	}
+
+	current, err := m.keyArea.location(ctx)
+	if err != nil {
+		return err
+	}
+	if current == location {
+		return nil // Nothing to do.
+	}
+
+	// Copy configured keys to the new location. Keys are left in place
+	// at the old location so that other devices syncing keys are not
+	// affected. Keys already present at the new location (e.g., synced
+	// from another device) are not copied again.
+	src := storage.NewTyped[storedKey](m.keyArea.area(current), storedKeyPrefixes)
+	dst := storage.NewTyped[storedKey](m.keyArea.area(location), storedKeyPrefixes)
+	srcKeys, err := src.ReadAll(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read keys: %w", err)
+	}
+	dstKeys, err := dst.ReadAll(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read keys at new location: %w", err)
+	}
+	existingID := make(map[string]bool)
+	existingPEM := make(map[string]bool)
+	for _, k := range dstKeys {
+		existingID[k.ID] = true
+		if k.PEMPrivateKey != "" {
+			existingPEM[k.PEMPrivateKey] = true
+		}
+	}
+	for _, k := range srcKeys {
+		if existingID[k.ID] || existingPEM[k.PEMPrivateKey] {
+			continue
+		}
+		if err := dst.Write(ctx, k); err != nil {
+			return fmt.Errorf("failed to copy key %s: %w", k.Name, quotaError(location, err))
+		}
+	}
+
+	return m.keyArea.setLocation(ctx, location)
+}
@@ -0,0 +1,216 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"fmt"
+	"sort"
+	"syscall/js"
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func configuredNames(ctx jsutil.AsyncContext, mgr Manager) ([]string, error) {
+	configured, err := mgr.Configured(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, k := range configured {
+		names = append(names, k.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func TestKeyStorage(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		syncStorage := storage.NewRaw(st.NewMemArea())
+		localStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		mgr := NewManager(agent.NewKeyring(), syncStorage, localStorage, sessionStorage)
+		// otherDevice shares synced storage, but has its own local
+		// storage.
+		otherDevice := NewManager(agent.NewKeyring(), syncStorage, storage.NewRaw(st.NewMemArea()), storage.NewRaw(st.NewMemArea()))
+
+		checkLocation := func(want string) {
+			t.Helper()
+			location, err := mgr.KeyStorage(ctx)
+			if err != nil {
+				t.Fatalf("failed to read key storage: %v", err)
+			}
+			if diff := cmp.Diff(location, want); diff != "" {
+				t.Errorf("incorrect key storage; -got +want: %s", diff)
+			}
+		}
+		checkNames := func(m Manager, want []string) {
+			t.Helper()
+			names, err := configuredNames(ctx, m)
+			if err != nil {
+				t.Fatalf("failed to read configured keys: %v", err)
+			}
+			if diff := cmp.Diff(names, want); diff != "" {
+				t.Errorf("incorrect configured keys; -got +want: %s", diff)
+			}
+		}
+
+		// Keys are synced by default.
+		checkLocation(KeyStorageSync)
+		if err := mgr.Add(ctx, "synced-key", testdata.WithoutPassphrase.Private, AddOptions{}); err != nil {
+			t.Fatalf("failed to add key: %v", err)
+		}
+		checkNames(otherDevice, []string{"synced-key"})
+
+		// Switching to local storage copies existing keys; new keys are
+		// not synced.
+		if err := mgr.SetKeyStorage(ctx, KeyStorageLocal); err != nil {
+			t.Fatalf("failed to set key storage: %v", err)
+		}
+		checkLocation(KeyStorageLocal)
+		if err := mgr.Add(ctx, "local-key", testdata.ED25519WithoutPassphrase.Private, AddOptions{}); err != nil {
+			t.Fatalf("failed to add key: %v", err)
+		}
+		checkNames(mgr, []string{"local-key", "synced-key"})
+		checkNames(otherDevice, []string{"synced-key"})
+
+		// Switching back copies local keys, without duplicating keys
+		// that are already synced.
+		if err := mgr.SetKeyStorage(ctx, KeyStorageSync); err != nil {
+			t.Fatalf("failed to set key storage: %v", err)
+		}
+		checkLocation(KeyStorageSync)
+		checkNames(mgr, []string{"local-key", "synced-key"})
+		checkNames(otherDevice, []string{"local-key", "synced-key"})
+
+		// Invalid locations are rejected.
+		err := mgr.SetKeyStorage(ctx, "elsewhere")
+		if diff := cmp.Diff(err, errInvalidKeyStorage, cmpopts.EquateErrors()); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+		checkLocation(KeyStorageSync)
+	})
+}
+
+// fullArea is a storage area that fails all writes as if its quota is
+// exceeded.
+type fullArea struct {
+	storage.Area
+}
+
+func (a *fullArea) Set(_ jsutil.AsyncContext, _ map[string]js.Value) error {
+	return fmt.Errorf("failed to set data: %w", storage.ErrQuotaExceeded)
+}
+
+func TestKeyStorageQuota(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		syncStorage := &fullArea{Area: storage.NewRaw(st.NewMemArea())}
+		localStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		mgr := NewManager(agent.NewKeyring(), syncStorage, localStorage, sessionStorage)
+
+		// Exceeding the quota for synced storage suggests storing keys
+		// locally instead.
+		err := mgr.Add(ctx, "new-key", testdata.WithoutPassphrase.Private, AddOptions{})
+		if diff := cmp.Diff(err, errSyncQuota, cmpopts.EquateErrors()); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+
+		if err := mgr.SetKeyStorage(ctx, KeyStorageLocal); err != nil {
+			t.Fatalf("failed to set key storage: %v", err)
+		}
+		if err := mgr.Add(ctx, "new-key", testdata.WithoutPassphrase.Private, AddOptions{}); err != nil {
+			t.Errorf("failed to add key locally: %v", err)
+		}
+
+		// Copying keys back to synced storage reports the same error.
+		err = mgr.SetKeyStorage(ctx, KeyStorageSync)
+		if diff := cmp.Diff(err, errSyncQuota, cmpopts.EquateErrors()); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+		location, err := mgr.KeyStorage(ctx)
+		if err != nil {
+			t.Fatalf("failed to read key storage: %v", err)
+		}
+		if diff := cmp.Diff(location, KeyStorageLocal); diff != "" {
+			t.Errorf("incorrect key storage; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestStorageUsage(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		syncArea := st.NewMemArea()
+		syncArea.Set("QUOTA_BYTES", 102400)
+		syncArea.Set("QUOTA_BYTES_PER_ITEM", 8192)
+		syncStorage := storage.NewRaw(syncArea)
+		localStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		mgr := NewManager(agent.NewKeyring(), syncStorage, localStorage, sessionStorage)
+
+		before, err := mgr.StorageUsage(ctx)
+		if err != nil {
+			t.Fatalf("failed to read storage usage: %v", err)
+		}
+		want := &StorageUsage{
+			Location:          KeyStorageSync,
+			QuotaBytes:        102400,
+			QuotaBytesPerItem: 8192,
+		}
+		if diff := cmp.Diff(before, want); diff != "" {
+			t.Errorf("incorrect usage; -got +want: %s", diff)
+		}
+
+		// Usage reflects newly-added keys.
+		if err := mgr.Add(ctx, "new-key", testdata.WithoutPassphrase.Private, AddOptions{}); err != nil {
+			t.Fatalf("failed to add key: %v", err)
+		}
+		after, err := mgr.StorageUsage(ctx)
+		if err != nil {
+			t.Fatalf("failed to read storage usage: %v", err)
+		}
+		if after.BytesInUse <= len(testdata.WithoutPassphrase.Private) {
+			t.Errorf("usage does not reflect added key: %d bytes", after.BytesInUse)
+		}
+
+		// Usage is reported for the storage area currently holding keys.
+		if err := mgr.SetKeyStorage(ctx, KeyStorageLocal); err != nil {
+			t.Fatalf("failed to set key storage: %v", err)
+		}
+		local, err := mgr.StorageUsage(ctx)
+		if err != nil {
+			t.Fatalf("failed to read storage usage: %v", err)
+		}
+		if diff := cmp.Diff(local.Location, KeyStorageLocal); diff != "" {
+			t.Errorf("incorrect location; -got +want: %s", diff)
+		}
+		if diff := cmp.Diff(local.QuotaBytesPerItem, 0); diff != "" {
+			t.Errorf("incorrect per-item quota; -got +want: %s", diff)
+		}
+	})
+}
@@ -0,0 +1,106 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+)
+
+var (
+	errTooManyKeysLoaded = errors.New("maximum number of loaded keys reached")
+)
+
+// lastActive returns the time (in seconds since the Unix epoch) at which
+// each loaded configured key was last active: the later of when it was
+// loaded, and when it was last used to sign data.
+func (m *DefaultManager) lastActive(ctx jsutil.AsyncContext) (map[ID]int64, error) {
+	sessionKeys, err := m.sessionKeys.ReadAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session keys: %w", err)
+	}
+	used, err := m.lastUsed(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[ID]int64, len(sessionKeys))
+	for _, sk := range sessionKeys {
+		id := ID(sk.ID)
+		result[id] = sk.LoadedAt
+		if used[id] > result[id] {
+			result[id] = used[id]
+		}
+	}
+	return result, nil
+}
+
+// enforceLoadLimit ensures that loading the key with the specified ID does
+// not exceed the maximum number of loaded keys selected in the user's
+// preferences. If the limit would be exceeded, an error is returned, or, if
+// the user prefers, the least-recently-used keys are unloaded to make room.
+//
+// Only keys loaded from configured keys count towards the limit.
+func (m *DefaultManager) enforceLoadLimit(ctx jsutil.AsyncContext, id ID) error {
+	prefs, err := m.Preferences(ctx)
+	if err != nil {
+		return err
+	}
+	if prefs.MaxLoadedKeys == 0 {
+		return nil
+	}
+
+	loaded, err := m.Loaded(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to enumerate loaded keys: %w", err)
+	}
+	var others []ID
+	for _, l := range loaded {
+		// Reloading a key replaces it, so it doesn't count.
+		if lid := l.ID(); lid != InvalidID && lid != id {
+			others = append(others, lid)
+		}
+	}
+	excess := len(others) - int(prefs.MaxLoadedKeys) + 1
+	if excess <= 0 {
+		return nil
+	}
+	if !prefs.EvictLRU {
+		return fmt.Errorf("%w: %d keys may be loaded at once; unload a key first", errTooManyKeysLoaded, prefs.MaxLoadedKeys)
+	}
+
+	active, err := m.lastActive(ctx)
+	if err != nil {
+		return err
+	}
+	sort.Slice(others, func(i, j int) bool {
+		if active[others[i]] != active[others[j]] {
+			return active[others[i]] < active[others[j]]
+		}
+		return others[i] < others[j]
+	})
+	for _, evict := range others[:excess] {
+		jsutil.Log("Unloading least recently used key ID %s to respect limit of %d loaded keys", evict, prefs.MaxLoadedKeys)
+		if err := m.Unload(ctx, evict); err != nil {
+			return fmt.Errorf("failed to unload least recently used key: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"sort"
+	"syscall/js"
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestLoadLimit(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		prefs       *Preferences
+		loadedAt    map[string]int64
+		usedAt      map[string]int64
+		load        string
+		wantErr     error
+		wantLoaded  []string
+	}{
+		{
+			description: "no limit",
+			prefs:       &Preferences{},
+			load:        "key-3",
+			wantLoaded:  []string{"key-1", "key-2", "key-3"},
+		},
+		{
+			description: "below limit",
+			prefs:       &Preferences{MaxLoadedKeys: 3},
+			load:        "key-3",
+			wantLoaded:  []string{"key-1", "key-2", "key-3"},
+		},
+		{
+			description: "reject at limit",
+			prefs:       &Preferences{MaxLoadedKeys: 2},
+			load:        "key-3",
+			wantErr:     errTooManyKeysLoaded,
+			wantLoaded:  []string{"key-1", "key-2"},
+		},
+		{
+			description: "evict earliest loaded",
+			prefs:       &Preferences{MaxLoadedKeys: 2, EvictLRU: true},
+			loadedAt:    map[string]int64{"key-1": 100, "key-2": 200},
+			load:        "key-3",
+			wantLoaded:  []string{"key-2", "key-3"},
+		},
+		{
+			description: "evict least recently used",
+			prefs:       &Preferences{MaxLoadedKeys: 2, EvictLRU: true},
+			loadedAt:    map[string]int64{"key-1": 100, "key-2": 200},
+			usedAt:      map[string]int64{"key-1": 300},
+			load:        "key-3",
+			wantLoaded:  []string{"key-1", "key-3"},
+		},
+		{
+			description: "evict multiple after limit lowered",
+			prefs:       &Preferences{MaxLoadedKeys: 1, EvictLRU: true},
+			loadedAt:    map[string]int64{"key-1": 100, "key-2": 200},
+			load:        "key-3",
+			wantLoaded:  []string{"key-3"},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				syncStorage := storage.NewRaw(st.NewMemArea())
+				sessionStorage := storage.NewRaw(st.NewMemArea())
+				mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, []*initialKey{
+					{Name: "key-1", PEMPrivateKey: testdata.WithoutPassphrase.Private, Load: true},
+					{Name: "key-2", PEMPrivateKey: testdata.ED25519WithoutPassphrase.Private, Load: true},
+					{Name: "key-3", PEMPrivateKey: testdata.ECDSAWithoutPassphrase.Private},
+				})
+				if err != nil {
+					t.Fatalf("failed to initialize manager: %v", err)
+				}
+
+				names := map[ID]string{}
+				ids := map[string]ID{}
+				for _, name := range []string{"key-1", "key-2", "key-3"} {
+					id, err := findKey(ctx, mgr, InvalidID, name)
+					if err != nil {
+						t.Fatalf("failed to find key %s: %v", name, err)
+					}
+					names[id] = name
+					ids[name] = id
+				}
+
+				// Control the recorded activity of each key.
+				if err := mgr.sessionKeys.Update(ctx, func(sk *sessionKey) bool {
+					sk.LoadedAt = tc.loadedAt[names[ID(sk.ID)]]
+					return true
+				}); err != nil {
+					t.Fatalf("failed to update session keys: %v", err)
+				}
+				used := map[string]js.Value{}
+				for name, at := range tc.usedAt {
+					used[string(ids[name])] = js.ValueOf(float64(at))
+				}
+				if err := mgr.usage.Set(ctx, used); err != nil {
+					t.Fatalf("failed to set usage: %v", err)
+				}
+
+				if err := mgr.SetPreferences(ctx, tc.prefs); err != nil {
+					t.Fatalf("failed to set preferences: %v", err)
+				}
+
+				err = mgr.Load(ctx, ids[tc.load], "", LoadOptions{})
+				if diff := cmp.Diff(err, tc.wantErr, cmpopts.EquateErrors()); diff != "" {
+					t.Errorf("incorrect error; -got +want: %s", diff)
+				}
+
+				loaded, err := mgr.Loaded(ctx)
+				if err != nil {
+					t.Fatalf("failed to enumerate loaded keys: %v", err)
+				}
+				var got []string
+				for _, l := range loaded {
+					got = append(got, names[l.ID()])
+				}
+				sort.Strings(got)
+				if diff := cmp.Diff(got, tc.wantLoaded); diff != "" {
+					t.Errorf("incorrect loaded keys; -got +want: %s", diff)
+				}
+			})
+		})
+	}
+}
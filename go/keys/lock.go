@@ -0,0 +1,95 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"errors"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+)
+
+var (
+	// errLocked is returned for requests that are rejected because the
+	// master passphrase has not been verified.
+	errLocked = errors.New("keys are locked; enter the master passphrase to unlock")
+)
+
+// IsLocked indicates whether the error was caused by the request being
+// rejected until the master passphrase is verified.
+func IsLocked(err error) bool {
+	return errors.Is(err, errLocked)
+}
+
+// LockFunc is invoked when the Server is locked or unlocked.
+//
+// LockFunc must not block.
+type LockFunc func()
+
+// SetOnLock sets the function invoked when the Server is locked or unlocked
+// (e.g., to hide keys that may be loaded without the options page).
+func (s *Server) SetOnLock(onLock LockFunc) {
+	s.onLock = onLock
+}
+
+// lockExempt are the messages that are served while the Server is locked.
+// They permit the master passphrase to be verified, and reveal nothing
+// about the configured keys.
+var lockExempt = map[int]bool{
+	msgTypePing:                   true,
+	msgTypeLock:                   true,
+	msgTypeMasterPassphraseSet:    true,
+	msgTypeVerifyMasterPassphrase: true,
+}
+
+// Locked indicates whether requests to manage keys are rejected until the
+// master passphrase is verified. The Server is locked when it starts if a
+// master passphrase is set, and whenever Lock is requested. It remains
+// locked if it cannot be determined whether a master passphrase is set.
+func (s *Server) Locked(ctx jsutil.AsyncContext) bool {
+	s.lockMu.Lock()
+	unlocked := s.unlocked
+	s.lockMu.Unlock()
+	if unlocked {
+		return false
+	}
+
+	set, err := s.mgr.MasterPassphraseSet(ctx)
+	if err != nil {
+		jsutil.LogError("Server.Locked: failed to check master passphrase: %v", err)
+		return true
+	}
+	return set
+}
+
+// setUnlocked records whether the master passphrase has been verified, and
+// invokes the LockFunc if that has changed.
+func (s *Server) setUnlocked(unlocked bool) {
+	s.lockMu.Lock()
+	changed := s.unlocked != unlocked
+	s.unlocked = unlocked
+	s.lockMu.Unlock()
+
+	if changed && s.onLock != nil {
+		s.onLock()
+	}
+}
+
+// Lock implements Manager.Lock. Locking is enforced by the Server, so a
+// DefaultManager is never locked.
+func (m *DefaultManager) Lock(_ jsutil.AsyncContext) error {
+	return nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	mfakes "github.com/google/chrome-ssh-agent/go/message/fakes"
+)
+
+func TestServerLock(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		hub := mfakes.NewHub()
+		mgr := &dummyManager{MasterSet: true}
+		cli := NewClient(hub)
+		srv := NewServer(mgr)
+		hub.AddReceiver(srv)
+		var changes int
+		srv.SetOnLock(func() { changes++ })
+
+		// Requests to manage keys are rejected until the master
+		// passphrase is verified.
+		if _, err := cli.Configured(ctx); !IsLocked(err) {
+			t.Errorf("incorrect error before unlocking; got %v, want locked", err)
+		}
+		mgr.Err = errIncorrectMasterPassphrase
+		if err := cli.VerifyMasterPassphrase(ctx, "wrong"); err == nil {
+			t.Errorf("incorrect passphrase verified")
+		}
+		if _, err := cli.Configured(ctx); !IsLocked(err) {
+			t.Errorf("incorrect error after incorrect passphrase; got %v, want locked", err)
+		}
+		mgr.Err = nil
+		if err := cli.VerifyMasterPassphrase(ctx, "secret"); err != nil {
+			t.Fatalf("failed to verify passphrase: %v", err)
+		}
+		if _, err := cli.Configured(ctx); err != nil {
+			t.Errorf("failed to enumerate keys after unlocking: %v", err)
+		}
+		if srv.Locked(ctx) {
+			t.Errorf("locked after unlocking")
+		}
+
+		// Locking rejects requests again.
+		if err := cli.Lock(ctx); err != nil {
+			t.Fatalf("failed to lock: %v", err)
+		}
+		if !mgr.Locked {
+			t.Errorf("manager not locked")
+		}
+		if _, err := cli.Configured(ctx); !IsLocked(err) {
+			t.Errorf("incorrect error after locking; got %v, want locked", err)
+		}
+		if changes != 2 {
+			t.Errorf("incorrect lock changes; got %d, want 2", changes)
+		}
+
+		// Without a master passphrase, requests are permitted.
+		mgr.MasterSet = false
+		if _, err := cli.Configured(ctx); err != nil {
+			t.Errorf("failed to enumerate keys without master passphrase: %v", err)
+		}
+
+		// The server remains locked if it cannot be determined
+		// whether a master passphrase is set.
+		mgr.MasterErr = errors.New("failed")
+		if _, err := cli.Configured(ctx); !IsLocked(err) {
+			t.Errorf("incorrect error if master passphrase unknown; got %v, want locked", err)
+		}
+	})
+}
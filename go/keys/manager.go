@@ -29,8 +29,10 @@ import (
 	"math"
 	"math/big"
 	"strings"
+	"time"
 
 	"github.com/google/chrome-ssh-agent/go/jsutil"
+	"github.com/google/chrome-ssh-agent/go/keys/ppk"
 	"github.com/google/chrome-ssh-agent/go/storage"
 	"github.com/youmark/pkcs8"
 	"golang.org/x/crypto/ssh"
@@ -51,9 +53,74 @@ type ConfiguredKey struct {
 	ID string `js:"id"`
 	// Name is a name allocated to key.
 	Name string `js:"name"`
+	// Comment is the comment embedded in the private key, if available.
+	Comment string `js:"comment"`
 	// Encrypted indicates if the key is encrypted and requires a passphrase
 	// to load.
 	Encrypted bool `js:"encrypted"`
+	// Unsupported, if non-empty, explains why the key cannot be loaded
+	// (e.g., it is encrypted using an unsupported cipher).
+	Unsupported string `js:"unsupported"`
+	// SecurityKey indicates that the key is backed by a hardware security
+	// key (e.g., a FIDO authenticator), and therefore cannot be loaded.
+	SecurityKey bool `js:"securityKey"`
+	// PublicOnly indicates that only the public key is configured (i.e.,
+	// the key was adopted from the agent), and therefore it cannot be
+	// loaded.
+	PublicOnly bool `js:"publicOnly"`
+	// ConfirmBeforeUse indicates that the user must confirm each use of
+	// the key once it is loaded into the agent.
+	ConfirmBeforeUse bool `js:"confirmBeforeUse"`
+	// Position is the position at which the key is displayed relative to
+	// other keys, starting from 1. Zero indicates that the user has not
+	// positioned the key.
+	Position int `js:"position"`
+	// RSASignatureAlgorithm is the signature algorithm used in place of
+	// ssh-rsa signatures, or ClientRSASignatureAlgorithm if the client's
+	// request is honored. It is empty if the key is not known to be an
+	// RSA key.
+	RSASignatureAlgorithm string `js:"rsaSignatureAlgorithm"`
+	// LastUsed is the time (in seconds since the Unix epoch) at which the
+	// key was last used to sign data on this device. Zero indicates that
+	// the key has not been used.
+	LastUsed int64 `js:"lastUsed"`
+	// Disabled indicates that the key cannot be loaded into the agent
+	// until it is enabled again.
+	Disabled bool `js:"disabled"`
+	// AutoLoad indicates that the key is loaded into the agent
+	// automatically when the browser starts.
+	AutoLoad bool `js:"autoLoad"`
+	// PassphraseCached indicates that the passphrase for an encrypted key
+	// is cached, so the key can be loaded without supplying it.
+	PassphraseCached bool `js:"passphraseCached"`
+	// AllowedOrigins are the origins of the clients (e.g.,
+	// 'chrome-extension://<id>') permitted to use the key for signing.
+	// Empty indicates that any client may use the key.
+	AllowedOrigins []string `js:"allowedOrigins"`
+	// Tags are the user-defined labels by which the key is grouped (e.g.,
+	// 'work', 'personal'). Empty indicates that the key is not grouped.
+	Tags []string `js:"tags"`
+	// Priority determines the order in which keys are offered to servers;
+	// keys with a higher priority are offered first. Zero is the default.
+	Priority int `js:"priority"`
+	// Note is free text describing the key (e.g., what it is used for).
+	// It is displayed alongside the key, but never sent to the agent.
+	Note string `js:"note"`
+	// Algorithm is a normalized description of the key's algorithm (e.g.,
+	// 'RSA', 'ECDSA P-256', 'Ed25519'). It is empty if the public key
+	// cannot be determined without a passphrase.
+	Algorithm string `js:"algorithm"`
+	// Bits is the size of the key in bits. It is zero if the public key
+	// cannot be determined without a passphrase.
+	Bits int `js:"bits"`
+	// Fingerprint is the SHA256 fingerprint of the public key (e.g.,
+	// 'SHA256:...'). It is empty if the public key cannot be determined
+	// without a passphrase.
+	Fingerprint string `js:"fingerprint"`
+	// FingerprintMD5 is the legacy MD5 fingerprint of the public key
+	// (e.g., 'MD5:a1:b2:...'). It is empty if the public key cannot be
+	// determined without a passphrase.
+	FingerprintMD5 string `js:"fingerprintMD5"`
 }
 
 // LoadedKey is a key loaded into the agent.
@@ -65,6 +132,13 @@ type LoadedKey struct {
 	InternalBlob string `js:"blob"`
 	// Comment is a comment for the loaded key.
 	Comment string `js:"comment"`
+	// Expiry is the time (in seconds since the Unix epoch) at which the
+	// key will be automatically unloaded from the agent. Zero indicates
+	// that the key does not expire.
+	Expiry int64 `js:"expiry"`
+	// OneShot indicates that the key will be automatically unloaded from
+	// the agent after it is first used to sign data.
+	OneShot bool `js:"oneShot"`
 }
 
 // SetBlob sets the given public key material for the loaded key.
@@ -93,13 +167,68 @@ func (k *LoadedKey) Blob() []byte {
 // determined, then InvalidID is returned.
 //
 // The ID for a key loaded into the agent is stored in the Comment field as
-// a string in a particular format.
+// a string in a particular format; see agentComment.
 func (k *LoadedKey) ID() ID {
-	if !strings.HasPrefix(k.Comment, commentPrefix) {
-		return InvalidID
+	// Check for the name first, in case the name itself happens to begin
+	// with the prefix.
+	marker := " (" + commentPrefix
+	if i := strings.LastIndex(k.Comment, marker); i >= 0 && strings.HasSuffix(k.Comment, ")") {
+		return ID(k.Comment[i+len(marker) : len(k.Comment)-1])
+	}
+	if strings.HasPrefix(k.Comment, commentPrefix) {
+		return ID(strings.TrimPrefix(k.Comment, commentPrefix))
 	}
+	return InvalidID
+}
+
+// agentComment returns the comment for a configured key loaded into the agent.
+// The comment includes the key's name, so that it is meaningful to clients
+// listing the keys (e.g., 'ssh-add -l'), along with the key's ID.
+func agentComment(id ID, name string) string {
+	if name == "" {
+		return fmt.Sprintf("%s%s", commentPrefix, id)
+	}
+	return fmt.Sprintf("%s (%s%s)", name, commentPrefix, id)
+}
 
-	return ID(strings.TrimPrefix(k.Comment, commentPrefix))
+// AddOptions are optional settings applied to a key when it is configured.
+// The zero value applies default settings.
+type AddOptions struct {
+	// ConfirmBeforeUse indicates that the user must confirm each use of
+	// the key once it is loaded into the agent.
+	ConfirmBeforeUse bool `js:"confirmBeforeUse"`
+	// AllowDuplicate indicates that the key should be configured even if
+	// the same key is already configured under a different name.
+	AllowDuplicate bool `js:"allowDuplicate"`
+	// Certificate is an OpenSSH certificate for the key (e.g., the
+	// contents of id_ed25519-cert.pub), which is loaded into the agent
+	// along with the key. Empty if the key has no certificate.
+	Certificate string `js:"certificate"`
+	// Disabled indicates that the key should be configured, but not
+	// loaded into the agent until it is enabled.
+	Disabled bool `js:"disabled"`
+	// AutoLoad indicates that the key should be loaded into the agent
+	// automatically when the browser starts.
+	AutoLoad bool `js:"autoLoad"`
+	// AllowedOrigins are the origins of the clients permitted to use the
+	// key for signing. Empty indicates that any client may use the key.
+	AllowedOrigins []string `js:"allowedOrigins"`
+	// Tags are the labels by which the key is grouped for display.
+	Tags []string `js:"tags"`
+	// Note is free text describing the key.
+	Note string `js:"note"`
+}
+
+// LoadOptions are optional constraints applied to a key when it is loaded
+// into the agent. The zero value applies no constraints.
+type LoadOptions struct {
+	// LifetimeSecs is the number of seconds after which the key is
+	// automatically unloaded from the agent. Zero indicates that the key
+	// does not expire.
+	LifetimeSecs uint32 `js:"lifetimeSecs"`
+	// OneShot indicates that the key is automatically unloaded from the
+	// agent after it is first used to sign data.
+	OneShot bool `js:"oneShot"`
 }
 
 // Manager provides an API for managing configured keys and loading them into
@@ -109,8 +238,28 @@ type Manager interface {
 	Configured(ctx jsutil.AsyncContext) ([]*ConfiguredKey, error)
 
 	// Add configures a new key.  name is a human-readable name describing
-	// the key, and pemPrivateKey is the PEM-encoded private key.
-	Add(ctx jsutil.AsyncContext, name string, pemPrivateKey string) error
+	// the key, and pemPrivateKey is the PEM-encoded private key. A private
+	// key in PuTTY's .ppk format is also accepted. Surrounding whitespace
+	// is removed from name, and Windows line endings in pemPrivateKey are
+	// converted, before either is validated. If name is empty, the comment
+	// embedded in the private key is used instead; otherwise, a name
+	// already used by a configured key (compared as by SameName) is
+	// rejected with ErrNameInUse. opts specifies any settings to apply to
+	// the key.
+	Add(ctx jsutil.AsyncContext, name string, pemPrivateKey string, opts AddOptions) error
+
+	// AddMany configures multiple new keys, writing them to storage at
+	// once. An error is returned for each key (nil if it was configured
+	// successfully), in the order supplied. If the keys cannot be written,
+	// a single error is returned instead, and none are configured.
+	AddMany(ctx jsutil.AsyncContext, keys []*NewKey) ([]error, error)
+
+	// AddBundle configures a separate key for each PEM block in bundle,
+	// which contains several concatenated blocks (see SplitBundle). Keys
+	// are named from their embedded comment, or else by adding an index
+	// suffix to name. Malformed blocks are skipped. As with AddMany, the
+	// returned slice holds the error (if any) for each block.
+	AddBundle(ctx jsutil.AsyncContext, name string, bundle string, opts AddOptions) ([]error, error)
 
 	// Remove removes the key with the specified ID.
 	//
@@ -119,30 +268,236 @@ type Manager interface {
 	// if the requested key was removed, or ignored because it didn't
 	// exist.  This could be improved, but it doesn't seem worth it at
 	// the moment.
+	//
+	// The removed key is retained in session storage so that its
+	// removal may be undone using Restore, until it is discarded using
+	// DiscardRemoved or another key is removed.
 	Remove(ctx jsutil.AsyncContext, id ID) error
 
+	// Restore configures once more the key with the specified ID, which
+	// must be the key most recently removed using Remove.
+	Restore(ctx jsutil.AsyncContext, id ID) error
+
+	// DiscardRemoved discards the retained copy of the removed key with
+	// the specified ID, so that its removal may no longer be undone. It
+	// succeeds if no such copy is retained.
+	DiscardRemoved(ctx jsutil.AsyncContext, id ID) error
+
+	// RemoveMany removes the keys with the specified IDs from storage at
+	// once. An error is returned for each ID (nil if it was removed), in
+	// the order supplied. If the keys cannot be removed, a single error
+	// is returned instead, and none are removed.
+	RemoveMany(ctx jsutil.AsyncContext, ids []ID) ([]error, error)
+
 	// Loaded returns the full set of keys loaded into the agent.
 	Loaded(ctx jsutil.AsyncContext) ([]*LoadedKey, error)
 
 	// Load loads a new key into to the agent, using the passphrase to
-	// decrypt the private key.
-	//
-	// NOTE: Unencrypted private keys are not currently supported.
-	Load(ctx jsutil.AsyncContext, id ID, passphrase string) error
+	// decrypt the private key. opts specifies any constraints to apply
+	// to the loaded key.
+	Load(ctx jsutil.AsyncContext, id ID, passphrase string, opts LoadOptions) error
 
 	// Unload unloads a key from the agent.
 	Unload(ctx jsutil.AsyncContext, id ID) error
+
+	// UnloadAll unloads all keys from the agent in a single operation,
+	// including keys that were not loaded by this extension.
+	UnloadAll(ctx jsutil.AsyncContext) error
+
+	// Preferences returns the user's current preferences.
+	Preferences(ctx jsutil.AsyncContext) (*Preferences, error)
+
+	// SetPreferences replaces the user's preferences.
+	SetPreferences(ctx jsutil.AsyncContext, prefs *Preferences) error
+
+	// SetPositions sets the positions of configured keys to reflect the
+	// supplied order. Keys not included are no longer positioned.
+	SetPositions(ctx jsutil.AsyncContext, ids []ID) error
+
+	// SetRSASignatureAlgorithm sets the signature algorithm (e.g.,
+	// 'rsa-sha2-512') used for an RSA key when a client does not request
+	// a specific one. It takes effect the next time the key is loaded.
+	SetRSASignatureAlgorithm(ctx jsutil.AsyncContext, id ID, alg string) error
+
+	// KeyStorage returns the location in which configured keys are stored
+	// on this device; either KeyStorageSync or KeyStorageLocal.
+	KeyStorage(ctx jsutil.AsyncContext) (string, error)
+
+	// SetKeyStorage changes the location in which configured keys are
+	// stored on this device. Keys are copied to the new location; they are
+	// left unmodified at the old location.
+	SetKeyStorage(ctx jsutil.AsyncContext, location string) error
+
+	// StorageUsage returns the space consumed in the storage area holding
+	// configured keys, along with its quotas.
+	StorageUsage(ctx jsutil.AsyncContext) (*StorageUsage, error)
+
+	// Export returns a JSON document containing all configured keys,
+	// suitable for backup.
+	Export(ctx jsutil.AsyncContext) ([]byte, error)
+
+	// ExportPublicKeys returns the public keys of all configured keys in
+	// authorized_keys format, one per line with the key's name as its
+	// comment. Keys whose public key cannot be derived (i.e., encrypted
+	// keys that are not loaded) are listed in a trailing comment.
+	ExportPublicKeys(ctx jsutil.AsyncContext) (string, error)
+
+	// AdoptLoaded configures a key that is loaded in the agent, but not
+	// configured (e.g., it was loaded by another program). The agent does
+	// not expose private keys, so only the public key is configured; the
+	// key is identified as loaded while the agent holds it, but cannot be
+	// loaded by the extension. If name is empty, the key's comment is used;
+	// otherwise, a name already in use is rejected as by Add.
+	AdoptLoaded(ctx jsutil.AsyncContext, key *LoadedKey, name string) error
+
+	// Extensions returns the names of the agent extensions supported by
+	// the agent (i.e., those listed in response to the query extension),
+	// sorted by name. The list is empty if the agent supports none.
+	Extensions(ctx jsutil.AsyncContext) ([]string, error)
+
+	// LockAgent locks the agent with the supplied passphrase, as for
+	// 'ssh-add -x'. While locked, the agent lists no keys and refuses to
+	// sign data or change its keys. This is independent of the master
+	// passphrase, which protects configured keys.
+	LockAgent(ctx jsutil.AsyncContext, passphrase string) error
+
+	// UnlockAgent unlocks the agent, as for 'ssh-add -X'. The passphrase
+	// must match the one with which the agent was locked.
+	UnlockAgent(ctx jsutil.AsyncContext, passphrase string) error
+
+	// AgentLocked indicates whether the agent is locked.
+	AgentLocked(ctx jsutil.AsyncContext) (bool, error)
+
+	// Import configures the keys contained in a JSON document produced by
+	// Export. Keys that are already configured are skipped.
+	Import(ctx jsutil.AsyncContext, data []byte) (*ImportResult, error)
+
+	// Ping checks that the manager is reachable, returning an error if not
+	// (e.g., if the background worker cannot be contacted).
+	Ping(ctx jsutil.AsyncContext) error
+
+	// MarkUsed records that the key with the specified ID was just used
+	// to sign data. The time is reported as ConfiguredKey.LastUsed.
+	MarkUsed(ctx jsutil.AsyncContext, id ID) error
+
+	// Reencrypt replaces the passphrase protecting the private key for the
+	// key with the specified ID. oldPassphrase is used to decrypt the key,
+	// which is then stored in OpenSSH format encrypted with newPassphrase.
+	// If newPassphrase is empty, the key is stored unencrypted. The key is
+	// left unmodified if it cannot be decrypted.
+	Reencrypt(ctx jsutil.AsyncContext, id ID, oldPassphrase, newPassphrase string) error
+
+	// ExportPrivate returns the private key for the key with the specified
+	// ID in OpenSSH format, so that it can be backed up. passphrase is used
+	// to decrypt the key. The returned key is encrypted with newPassphrase,
+	// or is unencrypted if newPassphrase is empty. The stored key is not
+	// modified. Each attempt is recorded in the audit log.
+	ExportPrivate(ctx jsutil.AsyncContext, id ID, passphrase, newPassphrase string) (string, error)
+
+	// SetDisabled sets whether the key with the specified ID is disabled.
+	// A disabled key remains configured, but cannot be loaded into the
+	// agent. Disabling a loaded key also unloads it.
+	SetDisabled(ctx jsutil.AsyncContext, id ID, disabled bool) error
+
+	// SetAutoLoad sets whether the key with the specified ID is loaded
+	// into the agent automatically when the browser starts. Encrypted
+	// keys are not loaded automatically, since no passphrase is
+	// available.
+	SetAutoLoad(ctx jsutil.AsyncContext, id ID, autoLoad bool) error
+
+	// SetAllowedOrigins sets the origins of the clients permitted to use
+	// the key with the specified ID for signing. An empty list permits
+	// any client.
+	SetAllowedOrigins(ctx jsutil.AsyncContext, id ID, origins []string) error
+
+	// SetTags sets the labels by which the key with the specified ID is
+	// grouped for display. An empty list removes the key from all groups.
+	SetTags(ctx jsutil.AsyncContext, id ID, tags []string) error
+
+	// SetNote sets the free-text note describing the key with the
+	// specified ID. An empty note removes it.
+	SetNote(ctx jsutil.AsyncContext, id ID, note string) error
+
+	// SetPriority sets the priority of the key with the specified ID. Keys
+	// with a higher priority are offered to servers first.
+	SetPriority(ctx jsutil.AsyncContext, id ID, priority int) error
+
+	// ForgetPassphrases removes all passphrases cached when loading
+	// keys. See Preferences.PassphraseCacheMins.
+	ForgetPassphrases(ctx jsutil.AsyncContext) error
+
+	// TestSign checks that the agent can sign data using the specified
+	// loaded key, by signing a random nonce and verifying the signature
+	// against the key's public key. Keys requiring confirmation prompt
+	// the user as usual.
+	TestSign(ctx jsutil.AsyncContext, key *LoadedKey) error
+
+	// NativeHostStatus returns the status of the connection to the native
+	// messaging host. See Preferences.NativeHost.
+	NativeHostStatus(ctx jsutil.AsyncContext) (*NativeHostStatus, error)
+
+	// CorruptKeys returns the number of stored keys that could not be
+	// read, and are therefore omitted from the configured keys.
+	CorruptKeys(ctx jsutil.AsyncContext) (int, error)
+
+	// RemoveCorruptKeys removes all stored keys that could not be read,
+	// returning the number removed.
+	RemoveCorruptKeys(ctx jsutil.AsyncContext) (int, error)
+
+	// MasterPassphraseSet indicates whether a master passphrase is
+	// configured. If so, the options page is locked until the master
+	// passphrase is supplied.
+	MasterPassphraseSet(ctx jsutil.AsyncContext) (bool, error)
+
+	// VerifyMasterPassphrase checks the supplied master passphrase
+	// against the one that is configured. It succeeds if no master
+	// passphrase is configured.
+	VerifyMasterPassphrase(ctx jsutil.AsyncContext, passphrase string) error
+
+	// SetMasterPassphrase changes the master passphrase. The current
+	// master passphrase must be supplied if one is configured. An empty
+	// passphrase removes the master passphrase.
+	SetMasterPassphrase(ctx jsutil.AsyncContext, current, passphrase string) error
+
+	// Lock rejects further requests to manage keys until the master
+	// passphrase is verified again. It has no effect if no master
+	// passphrase is configured.
+	Lock(ctx jsutil.AsyncContext) error
+
+	// AppendAuditLog records a request to sign data in the audit log. The
+	// oldest entries are evicted once the log reaches its maximum size.
+	AppendAuditLog(ctx jsutil.AsyncContext, entry *AuditEntry) error
+
+	// AuditLog returns the entries in the audit log, newest first.
+	AuditLog(ctx jsutil.AsyncContext) ([]*AuditEntry, error)
+
+	// ClearAuditLog removes all entries from the audit log.
+	ClearAuditLog(ctx jsutil.AsyncContext) error
 }
 
 // NewManager returns a Manager implementation that can manage keys in the
 // supplied agent, and store configured keys in the supplied storage.
-func NewManager(agt agent.Agent, syncStorage, sessionStorage storage.Area) *DefaultManager {
+// Configured keys are stored in either syncStorage or localStorage, as
+// selected by SetKeyStorage.
+func NewManager(agt agent.Agent, syncStorage, localStorage, sessionStorage storage.Area) *DefaultManager {
+	keyArea := newKeyArea(syncStorage, localStorage)
 	return &DefaultManager{
 		agent:          agt,
 		syncStorage:    syncStorage,
+		localStorage:   localStorage,
 		sessionStorage: sessionStorage,
-		storedKeys:     storage.NewTyped[storedKey](syncStorage, storedKeyPrefixes),
+		keyArea:        keyArea,
+		storedKeys:     storage.NewTyped[storedKey](keyArea, storedKeyPrefixes),
 		sessionKeys:    storage.NewTyped[sessionKey](sessionStorage, sessionKeyPrefixes),
+		removedKeys:    storage.NewTyped[storedKey](sessionStorage, removedKeyPrefixes),
+		prefs:          storage.NewView(prefsPrefixes, syncStorage),
+		usage:          storage.NewView(usagePrefixes, localStorage),
+		passphrases:    newPassphraseCache(),
+		audit:          storage.NewView(auditPrefixes, localStorage),
+		nativeHost:     storage.NewView(nativeHostPrefixes, sessionStorage),
+		master:         storage.NewView(masterPassphrasePrefixes, syncStorage),
+		activity:       storage.NewView(activityPrefixes, sessionStorage),
+		agentLock:      storage.NewView(agentLockPrefixes, sessionStorage),
 	}
 }
 
@@ -150,17 +505,92 @@ func NewManager(agt agent.Agent, syncStorage, sessionStorage storage.Area) *Defa
 type DefaultManager struct {
 	agent          agent.Agent
 	syncStorage    storage.Area
+	localStorage   storage.Area
 	sessionStorage storage.Area
+	keyArea        *keyArea
 	storedKeys     *storage.Typed[storedKey]
 	sessionKeys    *storage.Typed[sessionKey]
+	removedKeys    *storage.Typed[storedKey]
+	prefs          *storage.View
+	usage          *storage.View
+	passphrases    *passphraseCache
+	audit          *storage.View
+	nativeHost     *storage.View
+	master         *storage.View
+	activity       *storage.View
+	agentLock      *storage.View
 }
 
 // storedKey is the raw object stored in persistent storage for a configured
-// key.
+// key. PEMPrivateKey holds the private key as supplied by the user; despite
+// the name, it may also be a PuTTY .ppk file.
 type storedKey struct {
 	ID            string `js:"id"`
 	Name          string `js:"name"`
 	PEMPrivateKey string `js:"pemPrivateKey"`
+	// Encryption records how the key is protected, as detected when the
+	// key was added. It is absent for keys stored by older releases, in
+	// which case it is detected when needed.
+	Encryption string `js:"encryption"`
+	// ConfirmBeforeUse is absent for keys stored by older releases, in
+	// which case it is false.
+	ConfirmBeforeUse bool `js:"confirmBeforeUse"`
+	// Position is absent for keys that have not been positioned, in
+	// which case it is zero.
+	Position int `js:"position"`
+	// RSASignatureAlgorithm is absent unless selected by the user, in
+	// which case the algorithm requested by the client is used.
+	RSASignatureAlgorithm string `js:"rsaSignatureAlgorithm"`
+	// SchemaVersion is the version of the schema with which the key was
+	// stored. It is absent for keys stored by older releases, in which
+	// case it is zero.
+	SchemaVersion int `js:"schemaVersion"`
+	// Certificate is absent for keys without a certificate.
+	Certificate string `js:"certificate"`
+	// Disabled is absent for keys stored by older releases, in which
+	// case it is false.
+	Disabled bool `js:"disabled"`
+	// AutoLoad is absent for keys stored by older releases, in which
+	// case it is false.
+	AutoLoad bool `js:"autoLoad"`
+	// AllowedOrigins is absent for keys stored by older releases, in
+	// which case any client may use the key.
+	AllowedOrigins []string `js:"allowedOrigins"`
+	// Tags is absent for keys stored by older releases, in which case
+	// the key is not grouped.
+	Tags []string `js:"tags"`
+	// Priority is absent for keys stored by older releases, in which case
+	// it is zero.
+	Priority int `js:"priority"`
+	// Note is absent for keys stored by older releases, in which case the
+	// key has no note.
+	Note string `js:"note"`
+	// AuthorizedKey is the public key in authorized_keys format. It is
+	// present only for keys adopted from the agent, for which
+	// PEMPrivateKey is empty.
+	AuthorizedKey string `js:"authorizedKey"`
+}
+
+const (
+	// storedKeySchemaVersion is the current version of the schema for
+	// stored keys. It must be incremented whenever keys stored by older
+	// releases require migration, and the corresponding step added to
+	// storedKey.Migrate.
+	storedKeySchemaVersion = 1
+)
+
+// Migrate implements storage.Migrator. Keys stored by older releases are
+// upgraded to the current schema version. Keys stored by newer releases are
+// left untouched.
+func (s *storedKey) Migrate() {
+	if s.SchemaVersion < 1 {
+		// Older releases did not record how the key is protected.
+		if s.Encryption == "" {
+			enc, _ := s.encryptionState()
+			s.Encryption = string(enc)
+		}
+		s.SchemaVersion = 1
+	}
 }
 
 // EncryptedPKCS8 determines if the private key is an encrypted PKCS#8 formatted
@@ -182,6 +612,11 @@ func (s *storedKey) EncryptedPKCS8() bool {
 // Encrypted determines if the private key is encrypted. The Proc-Type header
 // contains 'ENCRYPTED' if the key is encrypted. See RFC 1421 Section 4.6.1.1.
 func (s *storedKey) Encrypted() bool {
+	if ppk.IsPPK([]byte(s.PEMPrivateKey)) {
+		k, err := ppk.Parse([]byte(s.PEMPrivateKey))
+		return err == nil && k.Encrypted()
+	}
+
 	block, _ := pem.Decode([]byte(s.PEMPrivateKey))
 	if block == nil {
 		// Attempt to handle this gracefully and guess that it isn't
@@ -201,6 +636,12 @@ func (s *storedKey) Encrypted() bool {
 	// reimplement the underlying crypto libraries. Instead, just attempt to
 	// decrypt it without a passphrase.
 	if block.Type == "OPENSSH PRIVATE KEY" {
+		if s.securityKey() != nil {
+			// Security keys can never be parsed; rely on the
+			// declared cipher instead.
+			enc, _ := detectOpenSSHEncryption(block.Bytes)
+			return enc == encryptionPassphrase
+		}
 		_, err := ssh.ParseRawPrivateKey([]byte(s.PEMPrivateKey))
 		return err != nil
 	}
@@ -208,6 +649,44 @@ func (s *storedKey) Encrypted() bool {
 	return strings.Contains(block.Headers["Proc-Type"], "ENCRYPTED")
 }
 
+// PublicKey returns the public key corresponding to the private key. nil is
+// returned if the public key cannot be derived without a passphrase; this is
+// the case for encrypted keys, except those in OpenSSH format where the public
+// key is stored unencrypted.
+func (s *storedKey) PublicKey() ssh.PublicKey {
+	if pub := s.publicOnly(); pub != nil {
+		return pub
+	}
+	if ppk.IsPPK([]byte(s.PEMPrivateKey)) {
+		// The public key is never encrypted in .ppk files.
+		k, err := ppk.Parse([]byte(s.PEMPrivateKey))
+		if err != nil {
+			return nil
+		}
+		return k.PublicKey
+	}
+	if pub := s.securityKey(); pub != nil {
+		// Security keys cannot be parsed, but the public key is
+		// available in the envelope.
+		return pub
+	}
+
+	priv, err := ssh.ParseRawPrivateKey([]byte(s.PEMPrivateKey))
+	if err != nil {
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			return missing.PublicKey
+		}
+		return nil
+	}
+
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		return nil
+	}
+	return signer.PublicKey()
+}
+
 // sessionKey is the raw object stored in session storage for a key that has
 // been loaded into the agent.
 //
@@ -218,6 +697,40 @@ func (s *storedKey) Encrypted() bool {
 type sessionKey struct {
 	ID         string `js:"id"`
 	PrivateKey string `js:"privateKey"`
+	// Name is the name of the configured key at the time it was loaded.
+	Name string `js:"name"`
+	// Expiry is the time (in seconds since the Unix epoch) at which the
+	// key should be unloaded from the agent. Zero indicates that the key
+	// does not expire.
+	Expiry int64 `js:"expiry"`
+	// ConfirmBeforeUse indicates that the user must confirm each use of
+	// the key.
+	ConfirmBeforeUse bool `js:"confirmBeforeUse"`
+	// RSASignatureAlgorithm is the signature algorithm to use if the key
+	// is an RSA key.
+	RSASignatureAlgorithm string `js:"rsaSignatureAlgorithm"`
+	// Certificate is the certificate loaded along with the key, if any.
+	Certificate string `js:"certificate"`
+	// LoadedAt is the time (in seconds since the Unix epoch) at which the
+	// key was loaded.
+	LoadedAt int64 `js:"loadedAt"`
+	// OneShot indicates that the key should be unloaded from the agent
+	// after it is first used to sign data.
+	OneShot bool `js:"oneShot"`
+}
+
+// lifetimeSecs returns the remaining lifetime of the key in seconds, and
+// whether the key has expired.
+func (s *sessionKey) lifetimeSecs(now time.Time) (secs uint32, expired bool) {
+	if s.Expiry == 0 {
+		return 0, false // No expiry.
+	}
+
+	remaining := s.Expiry - now.Unix()
+	if remaining <= 0 {
+		return 0, true
+	}
+	return uint32(remaining), false
 }
 
 var (
@@ -259,64 +772,316 @@ var (
 )
 
 const (
-	// commentPrefix is the prefix for the comment included when a
-	// configured key is loaded into the agent. The full comment is of the
-	// form 'chrome-ssh-agent:<id>'.
+	// commentPrefix is the prefix for the ID included in the comment when
+	// a configured key is loaded into the agent. The full comment is of
+	// the form '<name> (chrome-ssh-agent:<id>)', or
+	// 'chrome-ssh-agent:<id>' if the name is not known.
 	commentPrefix = "chrome-ssh-agent:"
 )
 
+// Ping implements Manager.Ping. A DefaultManager is always reachable.
+func (m *DefaultManager) Ping(_ jsutil.AsyncContext) error {
+	return nil
+}
+
 // Configured implements Manager.Configured.
 func (m *DefaultManager) Configured(ctx jsutil.AsyncContext) ([]*ConfiguredKey, error) {
 	keys, err := m.storedKeys.ReadAll(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read keys: %w", err)
 	}
+	lastUsed, err := m.lastUsed(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	var result []*ConfiguredKey
 	for _, k := range keys {
+		enc, unsupported := k.encryptionState()
 		c := ConfiguredKey{
-			ID:        k.ID,
-			Name:      k.Name,
-			Encrypted: k.Encrypted(),
+			ID:               k.ID,
+			Name:             k.Name,
+			Comment:          k.Comment(),
+			Encrypted:        enc != encryptionNone,
+			Unsupported:      unsupported,
+			ConfirmBeforeUse: k.ConfirmBeforeUse,
+			Position:         k.Position,
+			LastUsed:         lastUsed[ID(k.ID)],
+			Disabled:         k.Disabled,
+			AutoLoad:         k.AutoLoad,
+			AllowedOrigins:   k.AllowedOrigins,
+			Tags:             k.Tags,
+			Priority:         k.Priority,
+			Note:             k.Note,
+		}
+		if k.securityKey() != nil {
+			c.SecurityKey = true
+			c.Unsupported = errSecurityKey.Error()
+		}
+		if k.publicOnly() != nil {
+			c.PublicOnly = true
+			c.Unsupported = errPublicOnly.Error()
+		}
+		if _, ok := m.passphrases.get(ID(k.ID)); ok && c.Encrypted {
+			c.PassphraseCached = true
+		}
+		if pub := k.PublicKey(); pub != nil {
+			c.Algorithm = keyAlgorithm(pub)
+			c.Bits = keyBits(pub)
+			c.Fingerprint = ssh.FingerprintSHA256(pub)
+			c.FingerprintMD5 = fingerprintMD5(pub)
+		}
+		if k.isRSA() {
+			c.RSASignatureAlgorithm = k.rsaSignatureAlgorithm()
 		}
 		result = append(result, &c)
 	}
 	return result, nil
 }
 
-var errInvalidName = errors.New("invalid name")
+// checkDuplicate returns an error if the key is already configured. Keys are
+// compared by the fingerprint of their public key; keys for which the public
+// key cannot be derived are never considered duplicates.
+func (m *DefaultManager) checkDuplicate(ctx jsutil.AsyncContext, key *storedKey) error {
+	if key.PublicKey() == nil {
+		return nil
+	}
 
-// Add implements Manager.Add.
-func (m *DefaultManager) Add(ctx jsutil.AsyncContext, name string, pemPrivateKey string) error {
-	if name == "" {
-		return fmt.Errorf("%w: name must not be empty", errInvalidName)
+	existing, err := m.storedKeys.ReadAll(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read keys: %w", err)
+	}
+	return duplicateOf(existing, key)
+}
+
+// duplicateOf returns an error if the public key of the supplied key matches
+// that of one of the existing keys.
+func duplicateOf(existing []*storedKey, key *storedKey) error {
+	pub := key.PublicKey()
+	if pub == nil {
+		return nil
 	}
+	fingerprint := ssh.FingerprintSHA256(pub)
 
+	for _, k := range existing {
+		if p := k.PublicKey(); p != nil && ssh.FingerprintSHA256(p) == fingerprint {
+			return fmt.Errorf("%w: key already configured as %s", ErrDuplicateKey, k.Name)
+		}
+	}
+	return nil
+}
+
+// checkName returns an error if the supplied name is already used by a
+// configured key, compared as by SameName. An empty name (for which the key's
+// comment is used instead) is never in use.
+func (m *DefaultManager) checkName(ctx jsutil.AsyncContext, name string) error {
+	if strings.TrimSpace(name) == "" {
+		return nil
+	}
+	sk, err := m.storedKeys.Read(ctx, func(sk *storedKey) bool { return SameName(sk.Name, name) })
+	if err != nil {
+		return fmt.Errorf("failed to read keys: %w", err)
+	}
+	if sk != nil {
+		return fmt.Errorf("%w: %s", ErrNameInUse, sk.Name)
+	}
+	return nil
+}
+
+// validatePPK returns an error if the private key is a PuTTY .ppk file that
+// is malformed. Unless encrypted, the file's MAC is also verified; the MAC
+// for encrypted files cannot be verified until the passphrase is supplied.
+// Files using unsupported features are accepted, and reported as such when
+// configured keys are enumerated.
+func validatePPK(pemPrivateKey string) error {
+	if !ppk.IsPPK([]byte(pemPrivateKey)) {
+		return nil
+	}
+
+	k, err := ppk.Parse([]byte(pemPrivateKey))
+	if errors.Is(err, ppk.ErrUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", errParseFailed, err)
+	}
+	if k.Encrypted() {
+		return nil
+	}
+	if _, err := k.RawPrivateKey(nil); err != nil && !errors.Is(err, ppk.ErrUnsupported) {
+		return fmt.Errorf("%w: %w", errParseFailed, err)
+	}
+	return nil
+}
+
+// newKeyID returns a newly-generated ID for a configured key.
+func newKeyID() (string, error) {
 	i, err := rand.Int(rand.Reader, big.NewInt(math.MaxInt64))
 	if err != nil {
-		return fmt.Errorf("failed to generate new ID: %w", err)
+		return "", fmt.Errorf("failed to generate new ID: %w", err)
+	}
+	return i.String(), nil
+}
+
+// newStoredKey validates a key to be configured, and returns the
+// corresponding storedKey with a newly-generated ID.
+func newStoredKey(name string, pemPrivateKey string, opts AddOptions) (*storedKey, error) {
+	pemPrivateKey = normalizeLineEndings(pemPrivateKey)
+	if err := validatePPK(pemPrivateKey); err != nil {
+		return nil, err
+	}
+	name, err := normalizeName(name)
+	if err != nil {
+		return nil, err
 	}
 
+	id, err := newKeyID()
+	if err != nil {
+		return nil, err
+	}
+
+	// Record how the key is protected so that it can be displayed before
+	// the key is loaded. Keys using an unsupported cipher are still
+	// accepted; they are marked as such, and fail to load.
+	enc, _ := detectEncryption(pemPrivateKey)
 	sk := &storedKey{
-		ID:            i.String(),
-		Name:          name,
-		PEMPrivateKey: pemPrivateKey,
+		ID:               id,
+		Name:             name,
+		PEMPrivateKey:    pemPrivateKey,
+		Encryption:       string(enc),
+		ConfirmBeforeUse: opts.ConfirmBeforeUse,
+		SchemaVersion:    storedKeySchemaVersion,
+		Certificate:      strings.TrimSpace(opts.Certificate),
+		Disabled:         opts.Disabled,
+		AutoLoad:         opts.AutoLoad,
+		AllowedOrigins:   normalizeOrigins(opts.AllowedOrigins),
+		Tags:             normalizeTags(opts.Tags),
+		Note:             normalizeNote(opts.Note),
+	}
+	if err := sk.validateCertificate(); err != nil {
+		return nil, err
+	}
+	if err := validateNote(sk.Note); err != nil {
+		return nil, err
+	}
+	if sk.Name == "" {
+		sk.Name = sk.Comment()
+		if sk.Name == "" {
+			sk.Name = placeholderName
+		}
+	}
+	return sk, nil
+}
+
+// Add implements Manager.Add.
+func (m *DefaultManager) Add(ctx jsutil.AsyncContext, name string, pemPrivateKey string, opts AddOptions) error {
+	sk, err := newStoredKey(name, pemPrivateKey, opts)
+	if err != nil {
+		return err
+	}
+	if !opts.AllowDuplicate {
+		if err := m.checkDuplicate(ctx, sk); err != nil {
+			return err
+		}
+	}
+	if err := m.checkName(ctx, name); err != nil {
+		return err
+	}
+	// Store the key under its ID so that it can be read without reading
+	// all configured keys; see readStoredKey.
+	return m.storedKeys.WriteKey(ctx, sk.ID, sk)
+}
+
+// readStoredKey returns the configured key with the specified ID, or nil if
+// it is not found. Keys are stored under their ID, and are read directly.
+// Keys stored before this was the case (or copied between storage areas) are
+// stored under an unrelated key, and require reading all configured keys.
+func (m *DefaultManager) readStoredKey(ctx jsutil.AsyncContext, id ID) (*storedKey, error) {
+	sk, err := m.storedKeys.ReadKey(ctx, string(id))
+	if err != nil {
+		return nil, err
+	}
+	if sk != nil && ID(sk.ID) == id {
+		return sk, nil
+	}
+	return m.storedKeys.Read(ctx, func(sk *storedKey) bool { return ID(sk.ID) == id })
+}
+
+// maxUpdateAttempts is the number of times an update to the configured keys
+// is attempted when it conflicts with a concurrent modification.
+const maxUpdateAttempts = 3
+
+// updateStoredKeys applies the supplied update function to each configured
+// key, as with storage.Typed.Update. If the keys are modified concurrently
+// (e.g., by another extension context), they are read again and the update is
+// reapplied; the update function must therefore tolerate being invoked more
+// than once for the same key.
+func (m *DefaultManager) updateStoredKeys(ctx jsutil.AsyncContext, update func(sk *storedKey) bool) error {
+	var err error
+	for i := 0; i < maxUpdateAttempts; i++ {
+		err = m.storedKeys.Update(ctx, update)
+		if !errors.Is(err, storage.ErrConflict) {
+			return err
+		}
+		jsutil.LogDebug("DefaultManager.updateStoredKeys: retrying after conflict: %v", err)
+	}
+	return err
+}
+
+// SetPositions implements Manager.SetPositions.
+func (m *DefaultManager) SetPositions(ctx jsutil.AsyncContext, ids []ID) error {
+	positions := make(map[ID]int)
+	for i, id := range ids {
+		positions[id] = i + 1
+	}
+
+	err := m.updateStoredKeys(ctx, func(sk *storedKey) bool {
+		pos := positions[ID(sk.ID)]
+		if sk.Position == pos {
+			return false
+		}
+		sk.Position = pos
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update key positions: %w", err)
 	}
-	return m.storedKeys.Write(ctx, sk)
+	return nil
 }
 
 // Remove implements Manager.Remove.
 func (m *DefaultManager) Remove(ctx jsutil.AsyncContext, id ID) error {
+	m.passphrases.forget(id)
+	sk, err := m.readStoredKey(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to read keys: %w", err)
+	}
+	if sk != nil {
+		if err := m.stashRemoved(ctx, sk); err != nil {
+			return err
+		}
+	}
 	return m.storedKeys.Delete(ctx, func(sk *storedKey) bool { return ID(sk.ID) == id })
 }
 
 // Loaded implements Manager.Loaded.
-func (m *DefaultManager) Loaded(_ jsutil.AsyncContext) ([]*LoadedKey, error) {
+func (m *DefaultManager) Loaded(ctx jsutil.AsyncContext) ([]*LoadedKey, error) {
 	loaded, err := m.agent.List()
 	if err != nil {
 		return nil, fmt.Errorf("failed to list loaded keys: %w", err)
 	}
 
+	// Session keys record any expiry and one-shot constraint for keys we
+	// loaded.
+	sessionKeys, err := m.sessionKeys.ReadAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session keys: %w", err)
+	}
+	byID := make(map[ID]*sessionKey)
+	for _, sk := range sessionKeys {
+		byID[ID(sk.ID)] = sk
+	}
+
 	var result []*LoadedKey
 	for _, l := range loaded {
 		k := LoadedKey{
@@ -324,6 +1089,10 @@ func (m *DefaultManager) Loaded(_ jsutil.AsyncContext) ([]*LoadedKey, error) {
 			Comment: l.Comment,
 		}
 		k.SetBlob(l.Marshal())
+		if sk := byID[k.ID()]; sk != nil {
+			k.Expiry = sk.Expiry
+			k.OneShot = sk.OneShot
+		}
 		result = append(result, &k)
 	}
 
@@ -343,6 +1112,7 @@ func (m *DefaultManager) CleanupOldData(ctx jsutil.AsyncContext) {
 
 	areas := []storage.Area{
 		m.syncStorage,
+		m.localStorage,
 		m.sessionStorage,
 	}
 	prefixesLists := [][]string{
@@ -367,13 +1137,53 @@ func (m *DefaultManager) LoadFromSession(ctx jsutil.AsyncContext) error {
 		return fmt.Errorf("failed to read session keys: %w", err)
 	}
 
-	// Attempt to load each into the agent.
+	// Keys may have been disabled (e.g., on another device) since they
+	// were loaded. If configured keys cannot be read, assume none are
+	// disabled rather than failing to restore the session.
+	disabled, err := m.disabledKeys(ctx)
+	if err != nil {
+		jsutil.LogError("failed to read disabled keys: %v", err)
+	}
+
+	// The agent may have been idle for longer than the idle timeout
+	// while we were suspended. If the time of last use cannot be read,
+	// assume the agent was not idle.
+	var idleTimeout time.Duration
+	if prefs, err := m.Preferences(ctx); err != nil {
+		jsutil.LogError("failed to read preferences: %v", err)
+	} else {
+		idleTimeout = prefs.IdleTimeout()
+	}
+	lastActive, err := m.LastActive(ctx)
+	if err != nil {
+		jsutil.LogError("failed to read agent activity: %v", err)
+	}
+
+	// Attempt to load each into the agent. Keys that expired while we
+	// were suspended, that were idle for the idle timeout, or that have
+	// since been disabled, are removed from the session instead.
 	jsutil.LogDebug("DefaultManager.LoadFromSession: Load session keys")
+	now := time.Now()
 	for _, k := range sessionKeys {
-		if err := m.addToAgent(ID(k.ID), decryptedKey(k.PrivateKey)); err != nil {
+		lifetimeSecs, expired := k.lifetimeSecs(now)
+		idle := idleTimeout > 0 && !lastActive.IsZero() && now.Sub(k.idleSince(lastActive)) >= idleTimeout
+		if expired || idle || disabled[ID(k.ID)] {
+			jsutil.LogDebug("DefaultManager.LoadFromSession: session key ID %s expired, idle or disabled; removing", k.ID)
+			if err := m.sessionKeys.Delete(ctx, func(sk *sessionKey) bool { return sk.ID == k.ID }); err != nil {
+				jsutil.LogError("failed to remove expired session key ID %s: %v", k.ID, err)
+			}
+			continue
+		}
+		if err := m.addToAgent(ID(k.ID), k.Name, decryptedKey(k.PrivateKey), k.Certificate, lifetimeSecs, k.ConfirmBeforeUse, k.RSASignatureAlgorithm); err != nil {
 			jsutil.LogError("failed to load session key ID %s into agent: %v; skipping", k.ID, err)
 		}
 	}
+
+	// Keys are restored before the lock, since a locked agent refuses
+	// to add them.
+	if err := m.restoreAgentLock(ctx); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -388,31 +1198,30 @@ func decryptKey(key *storedKey, passphrase string) (decryptedKey, error) {
 	var err error
 	var priv interface{}
 	switch {
-	case key.EncryptedPKCS8():
-		// Crypto libraries don't yet support encrypted PKCS#8 keys:
-		//   https://github.com/golang/go/issues/8860
-		var block *pem.Block
-		block, _ = pem.Decode([]byte(key.PEMPrivateKey))
-		if block == nil {
-			return "", fmt.Errorf("%w: failed to decode encrypted private key", errDecodeFailed)
-		}
+	case ppk.IsPPK([]byte(key.PEMPrivateKey)):
+		// .ppk files are stored as supplied, and converted when loaded.
 		if passphrase != "" {
-			priv, err = pkcs8.ParsePKCS8PrivateKey(block.Bytes, []byte(passphrase))
+			priv, err = ppk.ParseRawPrivateKeyWithPassphrase([]byte(key.PEMPrivateKey), []byte(passphrase))
 		} else {
-			priv, err = pkcs8.ParsePKCS8PrivateKey(block.Bytes, nil)
+			priv, err = ppk.ParseRawPrivateKey([]byte(key.PEMPrivateKey))
 		}
+	case key.EncryptedPKCS8():
+		priv, err = parseEncryptedPKCS8(key.PEMPrivateKey, passphrase)
 	case key.Encrypted():
 		priv, err = ssh.ParseRawPrivateKeyWithPassphrase([]byte(key.PEMPrivateKey), []byte(passphrase))
 	default:
 		priv, err = ssh.ParseRawPrivateKey([]byte(key.PEMPrivateKey))
 	}
-	// Forward incorrect password errors on directly.
-	if err != nil && errors.Is(err, x509.IncorrectPasswordError) {
-		return "", fmt.Errorf("failed to parse private key: %w", err)
+	// Forward incorrect password errors on directly. A .ppk file whose
+	// integrity check fails when decrypted most likely indicates an
+	// incorrect passphrase too.
+	if err != nil && (errors.Is(err, x509.IncorrectPasswordError) || (passphrase != "" && errors.Is(err, ppk.ErrMACMismatch))) {
+		return "", categorize(fmt.Errorf("failed to parse private key: %w", err), ErrBadPassphrase)
 	}
-	// Wrap all other non-specific errors.
+	// Wrap all other non-specific errors, identifying those caused by an
+	// unsupported cipher.
 	if err != nil {
-		return "", fmt.Errorf("%w: %w", errParseFailed, err)
+		return "", fmt.Errorf("%w: %w", errParseFailed, classifyDecryptError(err))
 	}
 
 	// Workaround for https://github.com/google/chrome-ssh-agent/issues/28.
@@ -435,20 +1244,61 @@ func decryptKey(key *storedKey, passphrase string) (decryptedKey, error) {
 	})), nil
 }
 
+const (
+	// pkcs8IncorrectPassword is the message of the error returned by the
+	// pkcs8 package if the private key cannot be decrypted. The package
+	// does not export a corresponding error value.
+	pkcs8IncorrectPassword = "pkcs8: incorrect password"
+)
+
+// parseEncryptedPKCS8 decrypts and parses a private key in encrypted PKCS#8
+// format. As with other encrypted keys, x509.IncorrectPasswordError is
+// returned if the passphrase is incorrect.
+func parseEncryptedPKCS8(pemPrivateKey string, passphrase string) (interface{}, error) {
+	// Crypto libraries don't yet support encrypted PKCS#8 keys:
+	//   https://github.com/golang/go/issues/8860
+	block, _ := pem.Decode([]byte(pemPrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("%w: failed to decode encrypted private key", errDecodeFailed)
+	}
+	if passphrase == "" {
+		// The pkcs8 package assumes the key is unencrypted if no
+		// passphrase is supplied.
+		return nil, x509.IncorrectPasswordError
+	}
+
+	priv, err := pkcs8.ParsePKCS8PrivateKey(block.Bytes, []byte(passphrase))
+	if err != nil && err.Error() == pkcs8IncorrectPassword {
+		return nil, x509.IncorrectPasswordError
+	}
+	return priv, err
+}
+
 func parseDecryptedKey(pemPrivateKey decryptedKey) (interface{}, error) {
 	return ssh.ParseRawPrivateKey([]byte(pemPrivateKey))
 }
 
-func (m *DefaultManager) addToAgent(id ID, key decryptedKey) error {
+func (m *DefaultManager) addToAgent(id ID, name string, key decryptedKey, certificate string, lifetimeSecs uint32, confirmBeforeUse bool, rsaSignatureAlgorithm string) error {
 	priv, err := parseDecryptedKey(key)
 	if err != nil {
 		return err
 	}
+	cert, err := parseCertificate(certificate)
+	if err != nil {
+		return err
+	}
 
-	err = m.agent.Add(agent.AddedKey{
-		PrivateKey: priv,
-		Comment:    fmt.Sprintf("%s%s", commentPrefix, id),
-	})
+	added := agent.AddedKey{
+		PrivateKey:       priv,
+		Certificate:      cert,
+		Comment:          agentComment(id, name),
+		LifetimeSecs:     lifetimeSecs,
+		ConfirmBeforeUse: confirmBeforeUse,
+	}
+	if rsaSignatureAlgorithm != "" {
+		added.ConstraintExtensions = append(added.ConstraintExtensions, rsaSignatureAlgorithmConstraint(rsaSignatureAlgorithm))
+	}
+	err = m.agent.Add(added)
 	if err != nil {
 		return fmt.Errorf("failed to add key to agent: %w", err)
 	}
@@ -456,8 +1306,8 @@ func (m *DefaultManager) addToAgent(id ID, key decryptedKey) error {
 }
 
 // Load implements Manager.Load.
-func (m *DefaultManager) Load(ctx jsutil.AsyncContext, id ID, passphrase string) error {
-	key, err := m.storedKeys.Read(ctx, func(key *storedKey) bool { return ID(key.ID) == id })
+func (m *DefaultManager) Load(ctx jsutil.AsyncContext, id ID, passphrase string, opts LoadOptions) error {
+	key, err := m.readStoredKey(ctx, id)
 	if err != nil {
 		return fmt.Errorf("failed to read key: %w", err)
 	}
@@ -466,18 +1316,67 @@ func (m *DefaultManager) Load(ctx jsutil.AsyncContext, id ID, passphrase string)
 		return fmt.Errorf("%w: failed to find key with ID %s", errKeyNotFound, id)
 	}
 
+	if key.Disabled {
+		return fmt.Errorf("%w: key ID %s must be enabled before loading", errKeyDisabled, id)
+	}
+
+	if key.securityKey() != nil {
+		return fmt.Errorf("%w: key ID %s", errSecurityKey, id)
+	}
+
+	if key.publicOnly() != nil {
+		return fmt.Errorf("%w: key ID %s", errPublicOnly, id)
+	}
+
+	enc, _ := key.encryptionState()
+	if enc == encryptionUnsupported {
+		return fmt.Errorf("%w: %w", errParseFailed, key.unsupportedError())
+	}
+
+	// Use the cached passphrase if the caller did not supply one.
+	if passphrase == "" && enc != encryptionNone {
+		if cached, ok := m.passphrases.get(id); ok {
+			jsutil.LogDebug("DefaultManager.Load: using cached passphrase for key ID %s", id)
+			passphrase = cached
+		}
+	}
+
 	decrypted, err := decryptKey(key, passphrase)
 	if err != nil {
 		return fmt.Errorf("failed to decrypt key: %w", err)
 	}
+	if enc != encryptionNone {
+		m.passphrases.put(id, passphrase)
+	}
 
-	if err := m.addToAgent(id, decrypted); err != nil {
+	// Enforce the limit only once the key is known to load, so that a
+	// mistyped passphrase doesn't unload other keys.
+	if err := m.enforceLoadLimit(ctx, id); err != nil {
+		return err
+	}
+
+	// The preferred algorithm is passed to the agent only if the user
+	// selected one; otherwise, the client's request is honored.
+	var rsaAlg string
+	if decrypted.isRSA() {
+		rsaAlg = key.RSASignatureAlgorithm
+	}
+	if err := m.addToAgent(id, key.Name, decrypted, key.Certificate, opts.LifetimeSecs, key.ConfirmBeforeUse, rsaAlg); err != nil {
 		return err
 	}
 
 	sk := &sessionKey{
-		ID:         string(id),
-		PrivateKey: string(decrypted),
+		ID:                    string(id),
+		Name:                  key.Name,
+		PrivateKey:            string(decrypted),
+		ConfirmBeforeUse:      key.ConfirmBeforeUse,
+		RSASignatureAlgorithm: rsaAlg,
+		Certificate:           key.Certificate,
+		LoadedAt:              time.Now().Unix(),
+		OneShot:               opts.OneShot,
+	}
+	if opts.LifetimeSecs > 0 {
+		sk.Expiry = time.Now().Add(time.Duration(opts.LifetimeSecs) * time.Second).Unix()
 	}
 	if err := m.sessionKeys.Write(ctx, sk); err != nil {
 		return fmt.Errorf("failed to store loaded key to session: %w", err)
@@ -508,6 +1407,11 @@ func (m *DefaultManager) Unload(ctx jsutil.AsyncContext, id ID) error {
 			break
 		}
 	}
+	if lk == nil {
+		if lk, err = m.loadedPublicOnly(ctx, id, loaded); err != nil {
+			return fmt.Errorf("%w: failed to read key: %w", errAgentUnloadFailed, err)
+		}
+	}
 	if lk == nil {
 		return fmt.Errorf("%w: invalid id: %s", errAgentUnloadFailed, id)
 	}
@@ -526,3 +1430,18 @@ func (m *DefaultManager) Unload(ctx jsutil.AsyncContext, id ID) error {
 
 	return nil
 }
+
+// UnloadAll implements Manager.UnloadAll.
+func (m *DefaultManager) UnloadAll(ctx jsutil.AsyncContext) error {
+	m.passphrases.clear()
+
+	if err := m.agent.RemoveAll(); err != nil {
+		return fmt.Errorf("%w: %w", errAgentUnloadFailed, err)
+	}
+
+	if err := m.sessionKeys.Delete(ctx, func(sk *sessionKey) bool { return true }); err != nil {
+		return fmt.Errorf("%w: %w", errStorageUnloadFailed, err)
+	}
+
+	return nil
+}
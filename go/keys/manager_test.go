@@ -16,10 +16,16 @@ package keys
 
 import (
 	"crypto/x509"
+	"fmt"
+	"strings"
+	"syscall/js"
 	"testing"
+	"time"
 
 	"github.com/google/chrome-ssh-agent/go/jsutil"
 	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/fakes"
+	"github.com/google/chrome-ssh-agent/go/keys/ppk"
 	"github.com/google/chrome-ssh-agent/go/keys/testdata"
 	"github.com/google/chrome-ssh-agent/go/storage"
 	st "github.com/google/chrome-ssh-agent/go/storage/testing"
@@ -34,12 +40,13 @@ type initialKey struct {
 	PEMPrivateKey string
 	Load          bool
 	Passphrase    string
+	AddOptions    AddOptions
 }
 
 func newTestManager(ctx jsutil.AsyncContext, agent agent.Agent, syncStorage, sessionStorage storage.Area, keys []*initialKey) (*DefaultManager, error) {
-	mgr := NewManager(agent, syncStorage, sessionStorage)
+	mgr := NewManager(agent, syncStorage, storage.NewRaw(st.NewMemArea()), sessionStorage)
 	for _, k := range keys {
-		if err := mgr.Add(ctx, k.Name, k.PEMPrivateKey); err != nil {
+		if err := mgr.Add(ctx, k.Name, k.PEMPrivateKey, k.AddOptions); err != nil {
 			return nil, err
 		}
 
@@ -48,7 +55,7 @@ func newTestManager(ctx jsutil.AsyncContext, agent agent.Agent, syncStorage, ses
 			if err != nil {
 				return nil, err
 			}
-			if err := mgr.Load(ctx, id, k.Passphrase); err != nil {
+			if err := mgr.Load(ctx, id, k.Passphrase, LoadOptions{}); err != nil {
 				return nil, err
 			}
 		}
@@ -65,6 +72,7 @@ func TestAdd(t *testing.T) {
 		initial        []*initialKey
 		name           string
 		pemPrivateKey  string
+		opts           AddOptions
 		wantConfigured []string
 		wantErr        error
 	}{
@@ -87,22 +95,177 @@ func TestAdd(t *testing.T) {
 			wantConfigured: []string{"new-key-1", "new-key-2"},
 		},
 		{
-			description: "add multiple keys with same name",
+			description: "reject name already in use",
 			initial: []*initialKey{
 				{
 					Name:          "new-key",
 					PEMPrivateKey: testdata.WithPassphrase.Private,
 				},
 			},
+			name:           " new-key ",
+			pemPrivateKey:  testdata.WithoutPassphrase.Private,
+			wantConfigured: []string{"new-key"},
+			wantErr:        ErrNameInUse,
+		},
+		{
+			description: "allow name differing in case",
+			initial: []*initialKey{
+				{
+					Name:          "new-key",
+					PEMPrivateKey: testdata.WithPassphrase.Private,
+				},
+			},
+			name:           "New-Key",
+			pemPrivateKey:  testdata.WithoutPassphrase.Private,
+			wantConfigured: []string{"New-Key", "new-key"},
+		},
+		{
+			description: "allow comment matching name in use",
+			initial: []*initialKey{
+				{
+					Name:          "richard_alimi_gmail_com@workstation",
+					PEMPrivateKey: testdata.WithPassphrase.Private,
+				},
+			},
+			pemPrivateKey:  testdata.ED25519WithoutPassphrase.Private,
+			wantConfigured: []string{"richard_alimi_gmail_com@workstation", "richard_alimi_gmail_com@workstation"},
+		},
+		{
+			description:    "add ppk key",
+			name:           "new-key",
+			pemPrivateKey:  testdata.PPKv3WithoutPassphrase.Private,
+			wantConfigured: []string{"new-key"},
+		},
+		{
+			description:   "reject ppk key with mac mismatch",
+			name:          "new-key",
+			pemPrivateKey: strings.Replace(testdata.PPKv3WithoutPassphrase.Private, "test-comment", "other-comment", 1),
+			wantErr:       errParseFailed,
+		},
+		{
+			description: "reject duplicate ppk key",
+			initial: []*initialKey{
+				{
+					Name:          "new-key-1",
+					PEMPrivateKey: testdata.ED25519WithoutPassphrase.Private,
+				},
+			},
+			name:           "new-key-2",
+			pemPrivateKey:  testdata.PPKv3WithPassphrase.Private,
+			wantConfigured: []string{"new-key-1"},
+			wantErr:        ErrDuplicateKey,
+		},
+		{
+			description:    "add key named with emoji",
+			name:           "🔑 prod",
+			pemPrivateKey:  testdata.WithPassphrase.Private,
+			wantConfigured: []string{"🔑 prod"},
+		},
+		{
+			description:    "normalize name with combining characters",
+			name:           "cafe\u0301",
+			pemPrivateKey:  testdata.WithPassphrase.Private,
+			wantConfigured: []string{"caf\u00e9"},
+		},
+		{
+			description:    "trim name",
+			name:           "  new-key\n",
+			pemPrivateKey:  testdata.WithPassphrase.Private,
+			wantConfigured: []string{"new-key"},
+		},
+		{
+			description:    "add key with windows line endings",
+			name:           "new-key",
+			pemPrivateKey:  strings.ReplaceAll(testdata.WithoutPassphrase.Private, "\n", "\r\n"),
+			wantConfigured: []string{"new-key"},
+		},
+		{
+			description:    "add ppk key with windows line endings",
 			name:           "new-key",
+			pemPrivateKey:  strings.ReplaceAll(testdata.PPKv3WithoutPassphrase.Private, "\n", "\r\n"),
+			wantConfigured: []string{"new-key"},
+		},
+		{
+			description: "reject duplicate key with windows line endings",
+			initial: []*initialKey{
+				{
+					Name:          "new-key-1",
+					PEMPrivateKey: testdata.ED25519WithoutPassphrase.Private,
+				},
+			},
+			name:           "new-key-2",
+			pemPrivateKey:  strings.ReplaceAll(testdata.ED25519WithoutPassphrase.Private, "\n", "\r\n"),
+			wantConfigured: []string{"new-key-1"},
+			wantErr:        ErrDuplicateKey,
+		},
+		{
+			description:    "default name to embedded comment",
+			name:           " ",
+			pemPrivateKey:  testdata.ED25519WithoutPassphrase.Private,
+			wantConfigured: []string{"richard_alimi_gmail_com@workstation"},
+		},
+		{
+			description:    "default name to ppk comment",
+			name:           "",
+			pemPrivateKey:  testdata.PPKv3WithPassphrase.Private,
+			wantConfigured: []string{"test-comment"},
+		},
+		{
+			description:    "default name to placeholder",
+			name:           "",
 			pemPrivateKey:  testdata.WithPassphrase.Private,
-			wantConfigured: []string{"new-key", "new-key"},
+			wantConfigured: []string{placeholderName},
+		},
+		{
+			description: "reject duplicate key",
+			initial: []*initialKey{
+				{
+					Name:          "new-key-1",
+					PEMPrivateKey: testdata.WithoutPassphrase.Private,
+				},
+			},
+			name:           "new-key-2",
+			pemPrivateKey:  testdata.WithoutPassphrase.Private,
+			wantConfigured: []string{"new-key-1"},
+			wantErr:        ErrDuplicateKey,
+		},
+		{
+			description: "reject duplicate encrypted key with public key",
+			initial: []*initialKey{
+				{
+					Name:          "new-key-1",
+					PEMPrivateKey: testdata.OpenSSHFormat.Private,
+				},
+			},
+			name:           "new-key-2",
+			pemPrivateKey:  testdata.OpenSSHFormat.Private,
+			wantConfigured: []string{"new-key-1"},
+			wantErr:        ErrDuplicateKey,
 		},
 		{
-			description:   "reject invalid name",
-			name:          "",
-			pemPrivateKey: testdata.WithPassphrase.Private,
-			wantErr:       errInvalidName,
+			description: "allow different keys",
+			initial: []*initialKey{
+				{
+					Name:          "new-key-1",
+					PEMPrivateKey: testdata.WithoutPassphrase.Private,
+				},
+			},
+			name:           "new-key-2",
+			pemPrivateKey:  testdata.ED25519WithoutPassphrase.Private,
+			wantConfigured: []string{"new-key-1", "new-key-2"},
+		},
+		{
+			description: "allow duplicate key with override",
+			initial: []*initialKey{
+				{
+					Name:          "new-key-1",
+					PEMPrivateKey: testdata.WithoutPassphrase.Private,
+				},
+			},
+			name:           "new-key-2",
+			pemPrivateKey:  testdata.WithoutPassphrase.Private,
+			opts:           AddOptions{AllowDuplicate: true},
+			wantConfigured: []string{"new-key-1", "new-key-2"},
 		},
 	}
 
@@ -120,7 +283,7 @@ func TestAdd(t *testing.T) {
 				}
 
 				// Add the key.
-				err = mgr.Add(ctx, tc.name, tc.pemPrivateKey)
+				err = mgr.Add(ctx, tc.name, tc.pemPrivateKey, tc.opts)
 				if diff := cmp.Diff(err, tc.wantErr, cmpopts.EquateErrors()); diff != "" {
 					t.Errorf("incorrect error; -got +want: %s", diff)
 				}
@@ -139,6 +302,196 @@ func TestAdd(t *testing.T) {
 	}
 }
 
+func TestAddWindowsLineEndings(t *testing.T) {
+	t.Parallel()
+
+	for _, key := range []testdata.TestKey{testdata.WithoutPassphrase, testdata.PPKv3WithoutPassphrase} {
+		jut.DoSync(func(ctx jsutil.AsyncContext) {
+			syncStorage := storage.NewRaw(st.NewMemArea())
+			sessionStorage := storage.NewRaw(st.NewMemArea())
+			initial := []*initialKey{
+				{
+					Name:          "key",
+					PEMPrivateKey: strings.ReplaceAll(key.Private, "\n", "\r\n"),
+					Load:          true,
+				},
+			}
+			mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, initial)
+			if err != nil {
+				t.Fatalf("failed to initialize manager: %v", err)
+			}
+
+			// The key is stored with Unix line endings, and can be
+			// loaded.
+			stored, err := mgr.storedKeys.ReadAll(ctx)
+			if err != nil {
+				t.Fatalf("failed to read keys: %v", err)
+			}
+			if strings.Contains(stored[0].PEMPrivateKey, "\r") {
+				t.Errorf("key stored with windows line endings")
+			}
+			loaded, err := mgr.Loaded(ctx)
+			if err != nil {
+				t.Fatalf("failed to enumerate loaded keys: %v", err)
+			}
+			if diff := cmp.Diff(loadedKeyBlobs(loaded), []string{key.Blob}); diff != "" {
+				t.Errorf("incorrect loaded keys; -got +want: %s", diff)
+			}
+		})
+	}
+}
+
+func TestSetPositions(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description   string
+		initial       []*initialKey
+		order         []string
+		wantPositions map[string]int
+	}{
+		{
+			description: "position all keys",
+			initial: []*initialKey{
+				{
+					Name:          "key-a",
+					PEMPrivateKey: testdata.WithPassphrase.Private,
+				},
+				{
+					Name:          "key-b",
+					PEMPrivateKey: testdata.WithoutPassphrase.Private,
+				},
+			},
+			order: []string{"key-b", "key-a"},
+			wantPositions: map[string]int{
+				"key-a": 2,
+				"key-b": 1,
+			},
+		},
+		{
+			description: "omitted keys are not positioned",
+			initial: []*initialKey{
+				{
+					Name:          "key-a",
+					PEMPrivateKey: testdata.WithPassphrase.Private,
+				},
+				{
+					Name:          "key-b",
+					PEMPrivateKey: testdata.WithoutPassphrase.Private,
+				},
+			},
+			order: []string{"key-b"},
+			wantPositions: map[string]int{
+				"key-a": 0,
+				"key-b": 1,
+			},
+		},
+		{
+			description: "no keys positioned",
+			initial: []*initialKey{
+				{
+					Name:          "key-a",
+					PEMPrivateKey: testdata.WithPassphrase.Private,
+				},
+			},
+			wantPositions: map[string]int{
+				"key-a": 0,
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				syncStorage := storage.NewRaw(st.NewMemArea())
+				sessionStorage := storage.NewRaw(st.NewMemArea())
+				mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, tc.initial)
+				if err != nil {
+					t.Fatalf("failed to initialize manager: %v", err)
+				}
+
+				var ids []ID
+				for _, name := range tc.order {
+					id, err := findKey(ctx, mgr, InvalidID, name)
+					if err != nil {
+						t.Fatalf("failed to find key: %v", err)
+					}
+					ids = append(ids, id)
+				}
+
+				if err := mgr.SetPositions(ctx, ids); err != nil {
+					t.Errorf("failed to set positions: %v", err)
+				}
+
+				configured, err := mgr.Configured(ctx)
+				if err != nil {
+					t.Errorf("failed to get configured keys: %v", err)
+				}
+				positions := map[string]int{}
+				for _, k := range configured {
+					positions[k.Name] = k.Position
+				}
+				if diff := cmp.Diff(positions, tc.wantPositions); diff != "" {
+					t.Errorf("incorrect positions; -got +want: %s", diff)
+				}
+			})
+		})
+	}
+}
+
+func TestUpdateStoredKeysConflict(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		syncStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, []*initialKey{
+			{Name: "some-key", PEMPrivateKey: testdata.WithoutPassphrase.Private},
+		})
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+		id, err := findKey(ctx, mgr, InvalidID, "some-key")
+		if err != nil {
+			t.Fatalf("failed to find key: %v", err)
+		}
+
+		// Rename the key concurrently with the first attempt to update
+		// it.
+		attempts := 0
+		err = mgr.updateStoredKeys(ctx, func(sk *storedKey) bool {
+			attempts++
+			if attempts == 1 {
+				concurrent := *sk
+				concurrent.Name = "renamed"
+				if err := mgr.storedKeys.WriteKey(ctx, sk.ID, &concurrent); err != nil {
+					t.Fatalf("failed to write key: %v", err)
+				}
+			}
+			sk.Position = 5
+			return true
+		})
+		if err != nil {
+			t.Fatalf("update failed: %v", err)
+		}
+		if attempts != 2 {
+			t.Errorf("incorrect number of attempts; got %d, want 2", attempts)
+		}
+
+		// Both the concurrent modification and the update are retained.
+		sk, err := mgr.readStoredKey(ctx, id)
+		if err != nil {
+			t.Fatalf("failed to read key: %v", err)
+		}
+		if sk.Name != "renamed" || sk.Position != 5 {
+			t.Errorf("incorrect key; got name=%q position=%d, want name=%q position=5", sk.Name, sk.Position, "renamed")
+		}
+	})
+}
+
 func TestRemove(t *testing.T) {
 	t.Parallel()
 
@@ -268,6 +621,262 @@ func TestConfigured(t *testing.T) {
 	}
 }
 
+func TestConfiguredKeyDetails(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		syncStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, []*initialKey{
+			{Name: "rsa-key", PEMPrivateKey: testdata.WithoutPassphrase.Private},
+			{Name: "ecdsa-key", PEMPrivateKey: testdata.ECDSAWithoutPassphrase.Private},
+			// The public key of an encrypted PEM key is unknown
+			// until it is decrypted.
+			{Name: "encrypted-key", PEMPrivateKey: testdata.WithPassphrase.Private},
+		})
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+
+		configured, err := mgr.Configured(ctx)
+		if err != nil {
+			t.Fatalf("failed to enumerate configured keys: %v", err)
+		}
+		type details struct {
+			Name      string
+			Algorithm string
+			Bits      int
+		}
+		var got []details
+		for _, k := range configured {
+			got = append(got, details{Name: k.Name, Algorithm: k.Algorithm, Bits: k.Bits})
+		}
+		want := []details{
+			{Name: "ecdsa-key", Algorithm: "ECDSA P-521", Bits: 521},
+			{Name: "encrypted-key"},
+			{Name: "rsa-key", Algorithm: "RSA", Bits: 2048},
+		}
+		less := func(a, b details) bool { return a.Name < b.Name }
+		if diff := cmp.Diff(got, want, cmpopts.SortSlices(less)); diff != "" {
+			t.Errorf("incorrect key details; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestMigrateStoredKey(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		record      map[string]any
+		want        *storedKey
+	}{
+		{
+			description: "upgrade v0 unencrypted key",
+			record: map[string]any{
+				"id":            "some-id",
+				"name":          "some-key",
+				"pemPrivateKey": testdata.WithoutPassphrase.Private,
+			},
+			want: &storedKey{
+				ID:            "some-id",
+				Name:          "some-key",
+				PEMPrivateKey: testdata.WithoutPassphrase.Private,
+				Encryption:    string(encryptionNone),
+				SchemaVersion: storedKeySchemaVersion,
+			},
+		},
+		{
+			description: "upgrade v0 encrypted key",
+			record: map[string]any{
+				"id":               "some-id",
+				"name":             "some-key",
+				"pemPrivateKey":    testdata.WithPassphrase.Private,
+				"confirmBeforeUse": true,
+			},
+			want: &storedKey{
+				ID:               "some-id",
+				Name:             "some-key",
+				PEMPrivateKey:    testdata.WithPassphrase.Private,
+				Encryption:       string(encryptionPassphrase),
+				ConfirmBeforeUse: true,
+				SchemaVersion:    storedKeySchemaVersion,
+			},
+		},
+		{
+			description: "upgrade v0 key with unsupported cipher",
+			record: map[string]any{
+				"id":            "some-id",
+				"name":          "some-key",
+				"pemPrivateKey": testdata.ED25519UnsupportedCipher.Private,
+			},
+			want: &storedKey{
+				ID:            "some-id",
+				Name:          "some-key",
+				PEMPrivateKey: testdata.ED25519UnsupportedCipher.Private,
+				Encryption:    string(encryptionUnsupported),
+				SchemaVersion: storedKeySchemaVersion,
+			},
+		},
+		{
+			description: "preserve current key",
+			record: map[string]any{
+				"id":            "some-id",
+				"name":          "some-key",
+				"pemPrivateKey": testdata.WithPassphrase.Private,
+				"encryption":    string(encryptionNone),
+				"schemaVersion": storedKeySchemaVersion,
+			},
+			want: &storedKey{
+				ID:            "some-id",
+				Name:          "some-key",
+				PEMPrivateKey: testdata.WithPassphrase.Private,
+				Encryption:    string(encryptionNone),
+				SchemaVersion: storedKeySchemaVersion,
+			},
+		},
+		{
+			description: "preserve key from newer release",
+			record: map[string]any{
+				"id":            "some-id",
+				"name":          "some-key",
+				"pemPrivateKey": testdata.WithPassphrase.Private,
+				"schemaVersion": storedKeySchemaVersion + 1,
+			},
+			want: &storedKey{
+				ID:            "some-id",
+				Name:          "some-key",
+				PEMPrivateKey: testdata.WithPassphrase.Private,
+				SchemaVersion: storedKeySchemaVersion + 1,
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				syncStorage := storage.NewRaw(st.NewMemArea())
+				sessionStorage := storage.NewRaw(st.NewMemArea())
+				mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, nil)
+				if err != nil {
+					t.Fatalf("failed to initialize manager: %v", err)
+				}
+
+				// Store the record as written by an older (or newer)
+				// release.
+				err = syncStorage.Set(ctx, map[string]js.Value{
+					storedKeyPrefixes[0] + ".1": js.ValueOf(tc.record),
+				})
+				if err != nil {
+					t.Fatalf("failed to store key: %v", err)
+				}
+
+				got, err := mgr.storedKeys.ReadAll(ctx)
+				if err != nil {
+					t.Fatalf("failed to read keys: %v", err)
+				}
+				if diff := cmp.Diff(got, []*storedKey{tc.want}); diff != "" {
+					t.Errorf("incorrect keys; -got +want: %s", diff)
+				}
+			})
+		})
+	}
+}
+
+func TestAddSchemaVersion(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		syncStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, nil)
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+
+		if err := mgr.Add(ctx, "some-key", testdata.WithoutPassphrase.Private, AddOptions{}); err != nil {
+			t.Fatalf("failed to add key: %v", err)
+		}
+
+		// Inspect the stored record directly, bypassing migration.
+		data, err := syncStorage.Get(ctx)
+		if err != nil {
+			t.Fatalf("failed to read storage: %v", err)
+		}
+		var versions []int
+		for _, v := range data {
+			versions = append(versions, v.Get("schemaVersion").Int())
+		}
+		if diff := cmp.Diff(versions, []int{storedKeySchemaVersion}); diff != "" {
+			t.Errorf("incorrect schema versions; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestReadStoredKey(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		syncStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, []*initialKey{
+			{Name: "added", PEMPrivateKey: testdata.WithoutPassphrase.Private},
+		})
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+		addedID, err := findKey(ctx, mgr, InvalidID, "added")
+		if err != nil {
+			t.Fatalf("failed to find key: %v", err)
+		}
+
+		// Added keys are stored under their ID.
+		data, err := syncStorage.GetKeys(ctx, []string{storedKeyPrefixes[0] + "." + string(addedID)})
+		if err != nil {
+			t.Fatalf("failed to read storage: %v", err)
+		}
+		if len(data) != 1 {
+			t.Errorf("key not stored under its ID; got %d items", len(data))
+		}
+
+		// Keys stored under an unrelated key (e.g., by an older version)
+		// are also found.
+		legacy, err := newStoredKey("legacy", testdata.ED25519WithoutPassphrase.Private, AddOptions{})
+		if err != nil {
+			t.Fatalf("failed to create key: %v", err)
+		}
+		if err := mgr.storedKeys.Write(ctx, legacy); err != nil {
+			t.Fatalf("failed to write key: %v", err)
+		}
+
+		testcases := []struct {
+			id       ID
+			wantName string
+		}{
+			{id: addedID, wantName: "added"},
+			{id: ID(legacy.ID), wantName: "legacy"},
+			{id: ID("bogus-id")},
+		}
+		for _, tc := range testcases {
+			sk, err := mgr.readStoredKey(ctx, tc.id)
+			if err != nil {
+				t.Errorf("failed to read key %s: %v", tc.id, err)
+				continue
+			}
+			var gotName string
+			if sk != nil {
+				gotName = sk.Name
+			}
+			if diff := cmp.Diff(gotName, tc.wantName); diff != "" {
+				t.Errorf("incorrect key for ID %s; -got +want: %s", tc.id, diff)
+			}
+		}
+	})
+}
+
 func TestLoadAndLoaded(t *testing.T) {
 	t.Parallel()
 
@@ -379,6 +988,46 @@ func TestLoadAndLoaded(t *testing.T) {
 				testdata.PKCS8FormatWithoutPassphrase.Blob,
 			},
 		},
+		{
+			description: "load pkcs8 format rsa key without passphrase",
+			initial: []*initialKey{
+				{
+					Name:          "good-key",
+					PEMPrivateKey: testdata.PKCS8RSAWithoutPassphrase.Private,
+				},
+			},
+			byName: "good-key",
+			wantLoaded: []string{
+				testdata.PKCS8RSAWithoutPassphrase.Blob,
+			},
+		},
+		{
+			description: "load pkcs8 format ecdsa key",
+			initial: []*initialKey{
+				{
+					Name:          "good-key",
+					PEMPrivateKey: testdata.PKCS8ECDSAWithPassphrase.Private,
+				},
+			},
+			byName:     "good-key",
+			passphrase: testdata.PKCS8ECDSAWithPassphrase.Passphrase,
+			wantLoaded: []string{
+				testdata.PKCS8ECDSAWithPassphrase.Blob,
+			},
+		},
+		{
+			description: "load pkcs8 format ecdsa key without passphrase",
+			initial: []*initialKey{
+				{
+					Name:          "good-key",
+					PEMPrivateKey: testdata.PKCS8ECDSAWithoutPassphrase.Private,
+				},
+			},
+			byName: "good-key",
+			wantLoaded: []string{
+				testdata.PKCS8ECDSAWithoutPassphrase.Blob,
+			},
+		},
 		{
 			description: "load ecdsa key",
 			initial: []*initialKey{
@@ -445,6 +1094,81 @@ func TestLoadAndLoaded(t *testing.T) {
 			passphrase: "some passphrase",
 			wantErr:    errParseFailed,
 		},
+		{
+			description: "load ppk key",
+			initial: []*initialKey{
+				{
+					Name:          "good-key",
+					PEMPrivateKey: testdata.PPKv2WithoutPassphrase.Private,
+				},
+			},
+			byName: "good-key",
+			wantLoaded: []string{
+				testdata.PPKv2WithoutPassphrase.Blob,
+			},
+		},
+		{
+			description: "load encrypted ppk key",
+			initial: []*initialKey{
+				{
+					Name:          "good-key",
+					PEMPrivateKey: testdata.PPKv3WithPassphrase.Private,
+				},
+			},
+			byName:     "good-key",
+			passphrase: testdata.PPKv3WithPassphrase.Passphrase,
+			wantLoaded: []string{
+				testdata.PPKv3WithPassphrase.Blob,
+			},
+		},
+		{
+			description: "fail on invalid ppk passphrase",
+			initial: []*initialKey{
+				{
+					Name:          "good-key",
+					PEMPrivateKey: testdata.PPKv2WithPassphrase.Private,
+				},
+			},
+			byName:     "good-key",
+			passphrase: "incorrect passphrase",
+			wantErr:    ppk.ErrMACMismatch,
+		},
+		{
+			description: "identify invalid ppk passphrase",
+			initial: []*initialKey{
+				{
+					Name:          "good-key",
+					PEMPrivateKey: testdata.PPKv2WithPassphrase.Private,
+				},
+			},
+			byName:     "good-key",
+			passphrase: "incorrect passphrase",
+			wantErr:    ErrBadPassphrase,
+		},
+		{
+			description: "fail on unsupported cipher",
+			initial: []*initialKey{
+				{
+					Name:          "good-key",
+					PEMPrivateKey: testdata.ED25519UnsupportedCipher.Private,
+				},
+			},
+			byName:     "good-key",
+			passphrase: testdata.ED25519UnsupportedCipher.Passphrase,
+			wantErr:    errParseFailed,
+		},
+		{
+			description: "identify unsupported cipher",
+			initial: []*initialKey{
+				{
+					Name:          "good-key",
+					PEMPrivateKey: testdata.ED25519UnsupportedCipher.Private,
+				},
+			},
+			byName:     "good-key",
+			passphrase: testdata.ED25519UnsupportedCipher.Passphrase,
+			wantErr:    ErrUnsupportedCipher,
+		},
 		{
 			description: "fail on invalid password",
 			initial: []*initialKey{
@@ -457,6 +1181,41 @@ func TestLoadAndLoaded(t *testing.T) {
 			passphrase: "incorrect passphrase",
 			wantErr:    x509.IncorrectPasswordError,
 		},
+		{
+			description: "identify invalid password",
+			initial: []*initialKey{
+				{
+					Name:          "good-key",
+					PEMPrivateKey: testdata.WithPassphrase.Private,
+				},
+			},
+			byName:     "good-key",
+			passphrase: "incorrect passphrase",
+			wantErr:    ErrBadPassphrase,
+		},
+		{
+			description: "fail on invalid password for pkcs8 key",
+			initial: []*initialKey{
+				{
+					Name:          "good-key",
+					PEMPrivateKey: testdata.PKCS8ECDSAWithPassphrase.Private,
+				},
+			},
+			byName:     "good-key",
+			passphrase: "incorrect passphrase",
+			wantErr:    x509.IncorrectPasswordError,
+		},
+		{
+			description: "fail on missing password for pkcs8 key",
+			initial: []*initialKey{
+				{
+					Name:          "good-key",
+					PEMPrivateKey: testdata.PKCS8ECDSAWithPassphrase.Private,
+				},
+			},
+			byName:  "good-key",
+			wantErr: x509.IncorrectPasswordError,
+		},
 		{
 			description: "fail on invalid ID",
 			initial: []*initialKey{
@@ -491,7 +1250,7 @@ func TestLoadAndLoaded(t *testing.T) {
 				}
 
 				// Load the key
-				err = mgr.Load(ctx, id, tc.passphrase)
+				err = mgr.Load(ctx, id, tc.passphrase, LoadOptions{})
 				if diff := cmp.Diff(err, tc.wantErr, cmpopts.EquateErrors()); diff != "" {
 					t.Errorf("incorrect error; -got +want: %s", diff)
 				}
@@ -612,6 +1371,95 @@ func TestUnload(t *testing.T) {
 	}
 }
 
+func TestUnloadAll(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		syncStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		agt := agent.NewKeyring()
+		mgr, err := newTestManager(ctx, agt, syncStorage, sessionStorage, []*initialKey{
+			{
+				Name:          "good-key",
+				PEMPrivateKey: testdata.WithPassphrase.Private,
+				Load:          true,
+				Passphrase:    testdata.WithPassphrase.Passphrase,
+			},
+			{
+				Name:          "other-key",
+				PEMPrivateKey: testdata.ED25519WithoutPassphrase.Private,
+				Load:          true,
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+
+		// Also load a key into the agent directly (i.e., not through the
+		// manager); it is unloaded too.
+		priv, err := ssh.ParseRawPrivateKey([]byte(testdata.WithoutPassphrase.Private))
+		if err != nil {
+			t.Fatalf("failed to parse private key: %v", err)
+		}
+		if err := agt.Add(agent.AddedKey{PrivateKey: priv}); err != nil {
+			t.Fatalf("failed to load key into agent: %v", err)
+		}
+
+		if err := mgr.UnloadAll(ctx); err != nil {
+			t.Errorf("failed to unload all keys: %v", err)
+		}
+
+		loaded, err := mgr.Loaded(ctx)
+		if err != nil {
+			t.Errorf("failed to get loaded keys: %v", err)
+		}
+		if diff := cmp.Diff(loadedKeyBlobs(loaded), []string(nil)); diff != "" {
+			t.Errorf("incorrect loaded keys; -got +want: %s", diff)
+		}
+
+		gotSessionKeys, err := sessionKeyIDs(ctx, mgr.sessionKeys)
+		if err != nil {
+			t.Errorf("failed to get session keys: %v", err)
+		}
+		if diff := cmp.Diff(gotSessionKeys, []ID(nil), idSlice); diff != "" {
+			t.Errorf("incorrect session keys; -got +want: %s", diff)
+		}
+
+		// Configured keys remain configured.
+		configured, err := mgr.Configured(ctx)
+		if err != nil {
+			t.Errorf("failed to get configured keys: %v", err)
+		}
+		if diff := cmp.Diff(len(configured), 2); diff != "" {
+			t.Errorf("incorrect number of configured keys; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestLoadedKeyID(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		comment string
+		want    ID
+	}{
+		{comment: agentComment(ID("123"), "some-key"), want: ID("123")},
+		{comment: agentComment(ID("123"), ""), want: ID("123")},
+		{comment: agentComment(ID("123"), "key (with parens)"), want: ID("123")},
+		{comment: agentComment(ID("123"), "chrome-ssh-agent:456"), want: ID("123")},
+		{comment: "chrome-ssh-agent:123", want: ID("123")},
+		{comment: "some comment", want: InvalidID},
+		{comment: "", want: InvalidID},
+	}
+
+	for _, tc := range testcases {
+		k := &LoadedKey{Comment: tc.comment}
+		if diff := cmp.Diff(k.ID(), tc.want); diff != "" {
+			t.Errorf("incorrect ID for comment %q; -got +want: %s", tc.comment, diff)
+		}
+	}
+}
+
 func TestGetID(t *testing.T) {
 	t.Parallel()
 
@@ -638,7 +1486,7 @@ func TestGetID(t *testing.T) {
 		}
 
 		// Load the key.
-		if err = mgr.Load(ctx, wantID, testdata.WithPassphrase.Passphrase); err != nil {
+		if err = mgr.Load(ctx, wantID, testdata.WithPassphrase.Passphrase, LoadOptions{}); err != nil {
 			t.Errorf("failed to load key: %v", err)
 		}
 
@@ -651,6 +1499,19 @@ func TestGetID(t *testing.T) {
 			t.Errorf("incorrect loaded key IDs; -got +want: %s", diff)
 		}
 
+		// Clients listing keys from the agent see the configured name.
+		agentKeys, err := agt.List()
+		if err != nil {
+			t.Errorf("failed to list keys in agent: %v", err)
+		}
+		var comments []string
+		for _, k := range agentKeys {
+			comments = append(comments, k.Comment)
+		}
+		if diff := cmp.Diff(comments, []string{fmt.Sprintf("good-key (chrome-ssh-agent:%s)", wantID)}); diff != "" {
+			t.Errorf("incorrect agent comments; -got +want: %s", diff)
+		}
+
 		// Now, also load a key into the agent directly (i.e., not through the
 		// manager). We will ensure that we get InvalidID back when we try
 		// to extract the ID from it.
@@ -704,7 +1565,7 @@ func TestLoadFromSession(t *testing.T) {
 			}
 
 			// Load the key.
-			if err = mgr.Load(ctx, wantID, testdata.WithPassphrase.Passphrase); err != nil {
+			if err = mgr.Load(ctx, wantID, testdata.WithPassphrase.Passphrase, LoadOptions{}); err != nil {
 				t.Errorf("failed to load key: %v", err)
 			}
 
@@ -719,15 +1580,11 @@ func TestLoadFromSession(t *testing.T) {
 		}()
 
 		// Second manager instance loads keys from storage. We expect the
-		// loaded key to be loaded into the agent.
+		// loaded key to be loaded into the agent. The key is already
+		// configured in the shared storage.
 		func() {
 			agt := agent.NewKeyring()
-			mgr, err := newTestManager(ctx, agt, syncStorage, sessionStorage, []*initialKey{
-				{
-					Name:          "good-key",
-					PEMPrivateKey: testdata.WithPassphrase.Private,
-				},
-			})
+			mgr, err := newTestManager(ctx, agt, syncStorage, sessionStorage, nil)
 			if err != nil {
 				t.Fatalf("failed to initialize manager: %v", err)
 			}
@@ -748,3 +1605,127 @@ func TestLoadFromSession(t *testing.T) {
 		}()
 	})
 }
+
+func TestLoadWithLifetime(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		syncStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, []*initialKey{
+			{
+				Name:          "long-lived-key",
+				PEMPrivateKey: testdata.WithoutPassphrase.Private,
+			},
+			{
+				Name:          "short-lived-key",
+				PEMPrivateKey: testdata.ECDSAWithoutPassphrase.Private,
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+
+		longID, err := findKey(ctx, mgr, InvalidID, "long-lived-key")
+		if err != nil {
+			t.Fatalf("failed to find key: %v", err)
+		}
+		shortID, err := findKey(ctx, mgr, InvalidID, "short-lived-key")
+		if err != nil {
+			t.Fatalf("failed to find key: %v", err)
+		}
+
+		// Load both keys with different lifetimes.
+		start := time.Now()
+		if err := mgr.Load(ctx, longID, "", LoadOptions{LifetimeSecs: 3600}); err != nil {
+			t.Fatalf("failed to load key: %v", err)
+		}
+		if err := mgr.Load(ctx, shortID, "", LoadOptions{LifetimeSecs: 1}); err != nil {
+			t.Fatalf("failed to load key: %v", err)
+		}
+
+		// Both keys are loaded; the expiry reflects the lifetime.
+		loaded, err := mgr.Loaded(ctx)
+		if err != nil {
+			t.Fatalf("failed to get loaded keys: %v", err)
+		}
+		if diff := cmp.Diff(loadedKeyIDs(loaded), []ID{longID, shortID}, idSlice); diff != "" {
+			t.Errorf("incorrect loaded keys; -got +want: %s", diff)
+		}
+		for _, l := range loaded {
+			if l.ID() != longID {
+				continue
+			}
+			want := start.Add(3600 * time.Second).Unix()
+			if l.Expiry < want || l.Expiry > want+5 {
+				t.Errorf("incorrect expiry; got %d, want approximately %d", l.Expiry, want)
+			}
+		}
+
+		// Once the lifetime has passed, only the long-lived key remains.
+		time.Sleep(1500 * time.Millisecond)
+		loaded, err = mgr.Loaded(ctx)
+		if err != nil {
+			t.Fatalf("failed to get loaded keys: %v", err)
+		}
+		if diff := cmp.Diff(loadedKeyIDs(loaded), []ID{longID}); diff != "" {
+			t.Errorf("incorrect loaded keys; -got +want: %s", diff)
+		}
+
+		// Expired keys are not restored from the session.
+		agt := agent.NewKeyring()
+		mgr = NewManager(agt, syncStorage, storage.NewRaw(st.NewMemArea()), sessionStorage)
+		if err := mgr.LoadFromSession(ctx); err != nil {
+			t.Fatalf("failed to load keys from session: %v", err)
+		}
+		loaded, err = mgr.Loaded(ctx)
+		if err != nil {
+			t.Fatalf("failed to get loaded keys: %v", err)
+		}
+		if diff := cmp.Diff(loadedKeyIDs(loaded), []ID{longID}); diff != "" {
+			t.Errorf("incorrect loaded keys after restore; -got +want: %s", diff)
+		}
+		gotSessionKeys, err := sessionKeyIDs(ctx, mgr.sessionKeys)
+		if err != nil {
+			t.Errorf("failed to get session keys: %v", err)
+		}
+		if diff := cmp.Diff(gotSessionKeys, []ID{longID}); diff != "" {
+			t.Errorf("incorrect session keys; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestLoadConstraints(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		agt := fakes.NewRecordingAgent(nil)
+		mgr, err := newTestManager(ctx, agt, storage.NewRaw(st.NewMemArea()), storage.NewRaw(st.NewMemArea()), []*initialKey{
+			{
+				Name:          "confirmed-key",
+				PEMPrivateKey: testdata.WithoutPassphrase.Private,
+				AddOptions:    AddOptions{ConfirmBeforeUse: true},
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+		id, err := findKey(ctx, mgr, InvalidID, "confirmed-key")
+		if err != nil {
+			t.Fatalf("failed to find key: %v", err)
+		}
+
+		agt.Reset()
+		if err := mgr.Load(ctx, id, "", LoadOptions{LifetimeSecs: 600}); err != nil {
+			t.Fatalf("failed to load key: %v", err)
+		}
+
+		// The key is added once, with the lifetime and confirmation
+		// constraints passed through to the agent.
+		if n := len(agt.CallsTo(fakes.OpAdd)); n != 1 {
+			t.Errorf("key added %d times; want 1", n)
+		}
+		agt.ExpectAdded(t, "confirmed-key", 600, true)
+		agt.ExpectNotCalled(t, fakes.OpRemove)
+	})
+}
@@ -0,0 +1,178 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"syscall/js"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	"github.com/norunners/vert"
+	"golang.org/x/crypto/argon2"
+)
+
+var (
+	// masterPassphrasePrefixes are the prefixes for the master passphrase.
+	// It is kept in sync storage alongside the preferences, so that the
+	// lock applies on every browser where the keys are available.
+	masterPassphrasePrefixes = []string{"masterPassphrase"}
+)
+
+const (
+	// masterPassphraseKey is the key at which the master passphrase hash
+	// is stored.
+	masterPassphraseKey = "hash"
+
+	// Argon2id parameters used to hash new master passphrases. The
+	// parameters are stored alongside the hash, so they may be changed
+	// without invalidating existing passphrases.
+	masterPassphraseTime    = 1
+	masterPassphraseMemory  = 64 * 1024
+	masterPassphraseThreads = 4
+	masterPassphraseKeyLen  = 32
+	masterPassphraseSaltLen = 16
+)
+
+var (
+	// errIncorrectMasterPassphrase is returned when the supplied master
+	// passphrase does not match the one that is configured.
+	errIncorrectMasterPassphrase = errors.New("incorrect master passphrase")
+)
+
+// storedMasterPassphrase is the raw object stored in persistent storage for
+// the master passphrase. Only a salted hash is stored; the passphrase itself
+// is never persisted.
+type storedMasterPassphrase struct {
+	Salt    string `js:"salt"`
+	Hash    string `js:"hash"`
+	Time    int    `js:"time"`
+	Memory  int    `js:"memory"`
+	Threads int    `js:"threads"`
+}
+
+// hashMasterPassphrase returns the Argon2id hash of the passphrase using the
+// salt and parameters in s.
+func hashMasterPassphrase(s *storedMasterPassphrase, salt []byte, passphrase string) []byte {
+	return argon2.IDKey([]byte(passphrase), salt, uint32(s.Time), uint32(s.Memory), uint8(s.Threads), masterPassphraseKeyLen)
+}
+
+// newStoredMasterPassphrase hashes the passphrase with a fresh random salt.
+func newStoredMasterPassphrase(passphrase string) (*storedMasterPassphrase, error) {
+	salt := make([]byte, masterPassphraseSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	s := &storedMasterPassphrase{
+		Salt:    base64.StdEncoding.EncodeToString(salt),
+		Time:    masterPassphraseTime,
+		Memory:  masterPassphraseMemory,
+		Threads: masterPassphraseThreads,
+	}
+	s.Hash = base64.StdEncoding.EncodeToString(hashMasterPassphrase(s, salt, passphrase))
+	return s, nil
+}
+
+// matches indicates whether the passphrase matches the stored hash.
+func (s *storedMasterPassphrase) matches(passphrase string) (bool, error) {
+	salt, err := base64.StdEncoding.DecodeString(s.Salt)
+	if err != nil {
+		return false, fmt.Errorf("failed to decode salt: %w", err)
+	}
+	want, err := base64.StdEncoding.DecodeString(s.Hash)
+	if err != nil {
+		return false, fmt.Errorf("failed to decode hash: %w", err)
+	}
+	got := hashMasterPassphrase(s, salt, passphrase)
+	return subtle.ConstantTimeCompare(got, want) == 1, nil
+}
+
+// readMasterPassphrase returns the stored master passphrase, or nil if none
+// is configured.
+func (m *DefaultManager) readMasterPassphrase(ctx jsutil.AsyncContext) (*storedMasterPassphrase, error) {
+	data, err := m.master.Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read master passphrase: %w", err)
+	}
+
+	val, present := data[masterPassphraseKey]
+	if !present {
+		return nil, nil
+	}
+	var s storedMasterPassphrase
+	if err := vert.ValueOf(val).AssignTo(&s); err != nil {
+		return nil, fmt.Errorf("failed to parse master passphrase: %w", err)
+	}
+	return &s, nil
+}
+
+// MasterPassphraseSet implements Manager.MasterPassphraseSet.
+func (m *DefaultManager) MasterPassphraseSet(ctx jsutil.AsyncContext) (bool, error) {
+	s, err := m.readMasterPassphrase(ctx)
+	if err != nil {
+		return false, err
+	}
+	return s != nil, nil
+}
+
+// VerifyMasterPassphrase implements Manager.VerifyMasterPassphrase.
+func (m *DefaultManager) VerifyMasterPassphrase(ctx jsutil.AsyncContext, passphrase string) error {
+	s, err := m.readMasterPassphrase(ctx)
+	if err != nil {
+		return err
+	}
+	if s == nil {
+		return nil
+	}
+	ok, err := s.matches(passphrase)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errIncorrectMasterPassphrase
+	}
+	return nil
+}
+
+// SetMasterPassphrase implements Manager.SetMasterPassphrase.
+func (m *DefaultManager) SetMasterPassphrase(ctx jsutil.AsyncContext, current, passphrase string) error {
+	if err := m.VerifyMasterPassphrase(ctx, current); err != nil {
+		return err
+	}
+
+	if passphrase == "" {
+		if err := m.master.Delete(ctx, []string{masterPassphraseKey}); err != nil {
+			return fmt.Errorf("failed to remove master passphrase: %w", err)
+		}
+		return nil
+	}
+
+	s, err := newStoredMasterPassphrase(passphrase)
+	if err != nil {
+		return err
+	}
+	data := map[string]js.Value{
+		masterPassphraseKey: vert.ValueOf(s).JSValue(),
+	}
+	if err := m.master.Set(ctx, data); err != nil {
+		return fmt.Errorf("failed to write master passphrase: %w", err)
+	}
+	return nil
+}
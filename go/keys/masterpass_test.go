@@ -0,0 +1,93 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestMasterPassphrase(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		syncStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, nil)
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+
+		// Initially, no master passphrase is set, and any passphrase
+		// is accepted.
+		if set, err := mgr.MasterPassphraseSet(ctx); err != nil || set {
+			t.Errorf("MasterPassphraseSet() = %t, %v; want false, nil", set, err)
+		}
+		if err := mgr.VerifyMasterPassphrase(ctx, "anything"); err != nil {
+			t.Errorf("VerifyMasterPassphrase() failed with no master passphrase: %v", err)
+		}
+
+		// Set a master passphrase.
+		if err := mgr.SetMasterPassphrase(ctx, "", "secret"); err != nil {
+			t.Fatalf("failed to set master passphrase: %v", err)
+		}
+		if set, err := mgr.MasterPassphraseSet(ctx); err != nil || !set {
+			t.Errorf("MasterPassphraseSet() = %t, %v; want true, nil", set, err)
+		}
+		if err := mgr.VerifyMasterPassphrase(ctx, "secret"); err != nil {
+			t.Errorf("VerifyMasterPassphrase() failed with correct passphrase: %v", err)
+		}
+		if err := mgr.VerifyMasterPassphrase(ctx, "wrong"); !errors.Is(err, errIncorrectMasterPassphrase) {
+			t.Errorf("VerifyMasterPassphrase() with incorrect passphrase returned %v; want %v", err, errIncorrectMasterPassphrase)
+		}
+
+		// The passphrase itself is never stored.
+		data, err := syncStorage.Get(ctx)
+		if err != nil {
+			t.Fatalf("failed to read storage: %v", err)
+		}
+		for k, v := range data {
+			if s := jsutil.ToJSON(v); strings.Contains(s, "secret") {
+				t.Errorf("passphrase found in storage at %s: %s", k, s)
+			}
+		}
+
+		// Changing the master passphrase requires the current one.
+		if err := mgr.SetMasterPassphrase(ctx, "wrong", "other"); !errors.Is(err, errIncorrectMasterPassphrase) {
+			t.Errorf("SetMasterPassphrase() with incorrect passphrase returned %v; want %v", err, errIncorrectMasterPassphrase)
+		}
+		if err := mgr.SetMasterPassphrase(ctx, "secret", "other"); err != nil {
+			t.Fatalf("failed to change master passphrase: %v", err)
+		}
+		if err := mgr.VerifyMasterPassphrase(ctx, "other"); err != nil {
+			t.Errorf("VerifyMasterPassphrase() failed with changed passphrase: %v", err)
+		}
+
+		// Removing the master passphrase.
+		if err := mgr.SetMasterPassphrase(ctx, "other", ""); err != nil {
+			t.Fatalf("failed to remove master passphrase: %v", err)
+		}
+		if set, err := mgr.MasterPassphraseSet(ctx); err != nil || set {
+			t.Errorf("MasterPassphraseSet() = %t, %v; want false, nil", set, err)
+		}
+	})
+}
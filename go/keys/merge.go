@@ -0,0 +1,318 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	"golang.org/x/crypto/ssh"
+)
+
+// Key describes a key known to the manager: a configured key, a key loaded
+// in the agent, or both.
+type Key struct {
+	// ID is the unique ID corresponding to the key.
+	ID ID
+	// Loaded indicates if the key is currently loaded.
+	Loaded bool
+	// Encrypted indicates if the private key is encrypted and requires a
+	// passphrase to load. This field is only valid if the key is not
+	// loaded.
+	Encrypted bool
+	// Unsupported, if non-empty, explains why the key cannot be loaded.
+	// This field is only valid if the key is not loaded.
+	Unsupported string
+	// Error, if non-empty, explains why the key's public key could not be
+	// parsed (e.g., it is corrupt). Such keys cannot be loaded or used.
+	Error string
+	// SecurityKey indicates that the key is backed by a hardware security
+	// key (e.g., a FIDO authenticator).
+	SecurityKey bool
+	// PublicOnly indicates that only the public key is configured (i.e.,
+	// the key was adopted from the agent).
+	PublicOnly bool
+	// Name is the human-readable name assigned to the key.
+	Name string
+	// Type is the type of key (e.g., 'ssh-rsa').
+	Type string
+	// Algorithm is a normalized description of the key's algorithm (e.g.,
+	// 'RSA', 'ECDSA P-256', 'Ed25519'). It is empty if unknown (e.g., the
+	// key is encrypted and not loaded).
+	Algorithm string
+	// BitSize is the size of the key in bits, or zero if unknown.
+	BitSize int
+	// Fingerprint is the SHA256 fingerprint of the public key (e.g.,
+	// 'SHA256:...'). It is empty if unknown.
+	Fingerprint string
+	// FingerprintMD5 is the legacy MD5 fingerprint of the public key
+	// (e.g., 'MD5:a1:b2:...'). It is empty if unknown.
+	FingerprintMD5 string
+	// Blob is the public key material for the key.
+	Blob string
+	// Comment is the comment embedded in the private key for configured
+	// keys, or the comment attached to the key in the agent for keys that
+	// are not configured.
+	Comment string
+	// AgentComment is the comment attached to the key in the agent. This
+	// field is only valid if the key is loaded.
+	AgentComment string
+	// ConfirmBeforeUse indicates that the user must confirm each use of
+	// the key.
+	ConfirmBeforeUse bool
+	// Position is the position assigned to the key by the user, or zero if
+	// the key has not been positioned.
+	Position int
+	// Disabled indicates that the key cannot be loaded until it is enabled.
+	Disabled bool
+	// AutoLoad indicates that the key is loaded automatically when the
+	// browser starts.
+	AutoLoad bool
+	// AllowedOrigins are the origins of the clients permitted to use the
+	// key. Empty indicates that any client may use the key.
+	AllowedOrigins []string
+	// Tags are the labels by which the key is grouped for display.
+	Tags []string
+	// Priority determines the order in which keys are offered to servers;
+	// keys with a higher priority are offered first.
+	Priority int
+	// Note is free text describing the key.
+	Note string
+	// PassphraseCached indicates that the passphrase for an encrypted key
+	// is cached, so the key can be loaded without prompting. This field is
+	// only valid if the key is not loaded.
+	PassphraseCached bool
+	// RSASignatureAlgorithm is the signature algorithm used for the key
+	// when a client does not request one. It is empty for keys that are
+	// not RSA keys.
+	RSASignatureAlgorithm string
+	// Expiry is the time at which the key will be automatically unloaded.
+	// The zero value indicates that the key does not expire. This field is
+	// only valid if the key is loaded.
+	Expiry time.Time
+	// OneShot indicates that the key will be automatically unloaded after
+	// it is first used to sign data. This field is only valid if the key
+	// is loaded.
+	OneShot bool
+	// LastUsed is the time at which the key was last used to sign data.
+	// The zero value indicates that the key has not been used. This field
+	// is only valid if the key has a valid ID.
+	LastUsed time.Time
+	// Certificate indicates that a certificate is loaded for the key. This
+	// field is only valid if the key is loaded.
+	Certificate bool
+	// Principals are the principals for which the certificate is valid.
+	// An empty list indicates that the certificate is valid for any
+	// principal.
+	Principals []string
+	// ValidAfter is the time before which the certificate is not valid.
+	// The zero value indicates that the certificate has no lower bound.
+	ValidAfter time.Time
+	// ValidBefore is the time at which the certificate expires. The zero
+	// value indicates that the certificate does not expire.
+	ValidBefore time.Time
+}
+
+// lastUsedTime returns the time at which the configured key was last used,
+// or the zero value if it has not been used.
+func lastUsedTime(k *ConfiguredKey) time.Time {
+	if k.LastUsed == 0 {
+		return time.Time{}
+	}
+	return time.Unix(k.LastUsed, 0)
+}
+
+// certTime converts a certificate validity bound to a time, or the zero
+// value if the bound is unset.
+func certTime(t uint64) time.Time {
+	if t == 0 || t == ssh.CertTimeInfinity {
+		return time.Time{}
+	}
+	return time.Unix(int64(t), 0)
+}
+
+// MergeKeys merges configured and loaded keys to create a consolidated list,
+// such as that displayed in the options page. Each configured key appears
+// once, whether or not it is loaded. Keys loaded in the agent that do not
+// correspond to a configured key appear with InvalidID.
+func MergeKeys(configured []*ConfiguredKey, loaded []*LoadedKey) []*Key {
+	// Build map of configured keys for faster lookup
+	configuredMap := make(map[ID]*ConfiguredKey)
+	// Keys adopted from the agent are loaded by something other than this
+	// extension, so they are matched by fingerprint rather than by the ID
+	// in the comment.
+	publicOnly := make(map[string]*ConfiguredKey)
+	for _, k := range configured {
+		configuredMap[ID(k.ID)] = k
+		if k.PublicOnly && k.Fingerprint != "" {
+			publicOnly[k.Fingerprint] = k
+		}
+	}
+
+	var result []*Key
+
+	// Add all loaded keys. Keep track of the IDs that were detected as
+	// being loaded.
+	loadedIds := make(map[ID]bool)
+	// The same key may be loaded more than once (e.g., loaded from a
+	// configured key, and also added to the agent directly). Track where
+	// each key appears so that copies can be collapsed into one.
+	byBlob := make(map[string]int)
+	for _, l := range loaded {
+		// Gather basic fields we get for any loaded key.
+		k := &Key{
+			Loaded:       true,
+			Type:         l.Type,
+			Blob:         base64.StdEncoding.EncodeToString(l.Blob()),
+			Comment:      l.Comment,
+			AgentComment: l.Comment,
+			SecurityKey:  isSecurityKeyType(l.Type),
+		}
+		k.Algorithm, k.BitSize = DescribePublicKey(l.Blob())
+		if pub, err := ssh.ParsePublicKey(l.Blob()); err == nil {
+			k.Fingerprint = ssh.FingerprintSHA256(pub)
+			k.FingerprintMD5 = fingerprintMD5(pub)
+		} else {
+			k.Error = fmt.Sprintf("failed to parse public key: %v", err)
+		}
+		if l.Expiry != 0 {
+			k.Expiry = time.Unix(l.Expiry, 0)
+		}
+		k.OneShot = l.OneShot
+		if cert := l.Certificate(); cert != nil {
+			k.Certificate = true
+			k.Principals = cert.ValidPrincipals
+			k.ValidAfter = certTime(cert.ValidAfter)
+			k.ValidBefore = certTime(cert.ValidBefore)
+		}
+		// Attempt to figure out if this is a key we loaded. If so, fill
+		// in some additional information.  It is possible that a key with
+		// a non-existent ID is loaded (e.g., it was removed while loaded);
+		// in this case we claim we do not have an ID.
+		id := l.ID()
+		if ak := publicOnly[k.Fingerprint]; id == InvalidID && ak != nil {
+			id = ID(ak.ID)
+		}
+		if id != InvalidID {
+			if ak := configuredMap[id]; ak != nil {
+				loadedIds[id] = true
+				k.ID = id
+				k.Name = ak.Name
+				k.Comment = ak.Comment
+				k.ConfirmBeforeUse = ak.ConfirmBeforeUse
+				k.Position = ak.Position
+				k.Disabled = ak.Disabled
+				k.AutoLoad = ak.AutoLoad
+				k.AllowedOrigins = ak.AllowedOrigins
+				k.Tags = ak.Tags
+				k.Priority = ak.Priority
+				k.Note = ak.Note
+				k.PublicOnly = ak.PublicOnly
+				k.RSASignatureAlgorithm = ak.RSASignatureAlgorithm
+				k.LastUsed = lastUsedTime(ak)
+			}
+		}
+		// Collapse copies of the same key, preferring the copy
+		// corresponding to a configured key. Copies corresponding to
+		// different configured keys remain distinct so that each
+		// configured key appears.
+		if i, ok := byBlob[k.Blob]; ok && (result[i].ID == InvalidID || k.ID == InvalidID) {
+			if result[i].ID == InvalidID {
+				result[i] = k
+			}
+			continue
+		}
+		byBlob[k.Blob] = len(result)
+		result = append(result, k)
+	}
+
+	// Add all configured keys that are not loaded.
+	for _, a := range configured {
+		// Skip any that we already covered above.
+		if loadedIds[ID(a.ID)] {
+			continue
+		}
+
+		result = append(result, &Key{
+			ID:                    ID(a.ID),
+			Loaded:                false,
+			Encrypted:             a.Encrypted,
+			Unsupported:           a.Unsupported,
+			SecurityKey:           a.SecurityKey,
+			PublicOnly:            a.PublicOnly,
+			Name:                  a.Name,
+			Comment:               a.Comment,
+			ConfirmBeforeUse:      a.ConfirmBeforeUse,
+			Position:              a.Position,
+			Disabled:              a.Disabled,
+			AutoLoad:              a.AutoLoad,
+			AllowedOrigins:        a.AllowedOrigins,
+			Tags:                  a.Tags,
+			Priority:              a.Priority,
+			Note:                  a.Note,
+			Algorithm:             a.Algorithm,
+			BitSize:               a.Bits,
+			Fingerprint:           a.Fingerprint,
+			FingerprintMD5:        a.FingerprintMD5,
+			PassphraseCached:      a.PassphraseCached,
+			RSASignatureAlgorithm: a.RSASignatureAlgorithm,
+			LastUsed:              lastUsedTime(a),
+		})
+	}
+
+	// Sort to ensure consistent ordering. Keys positioned by the user are
+	// listed first.
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Position != b.Position {
+			if a.Position == 0 || b.Position == 0 {
+				return b.Position == 0
+			}
+			return a.Position < b.Position
+		}
+		if c := CompareNames(a.Name, b.Name); c != 0 {
+			return c < 0
+		}
+		if a.Blob < b.Blob {
+			return true
+		}
+		if a.Blob > b.Blob {
+			return false
+		}
+		return a.ID < b.ID
+	})
+
+	return result
+}
+
+// Snapshot returns the keys known to the manager, merging the configured keys
+// with those loaded in the agent. It allows the state of the manager to be
+// inspected without the options page (e.g., in tests).
+func Snapshot(ctx jsutil.AsyncContext, mgr Manager) ([]*Key, error) {
+	configured, err := mgr.Configured(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get configured keys: %w", err)
+	}
+	loaded, err := mgr.Loaded(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get loaded keys: %w", err)
+	}
+	return MergeKeys(configured, loaded), nil
+}
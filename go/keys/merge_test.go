@@ -0,0 +1,95 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestSnapshot(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		agt := agent.NewKeyring()
+		syncStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		mgr, err := newTestManager(ctx, agt, syncStorage, sessionStorage, []*initialKey{
+			{
+				Name:          "loaded-key",
+				PEMPrivateKey: testdata.WithoutPassphrase.Private,
+				Load:          true,
+			},
+			{
+				Name:          "unloaded-key",
+				PEMPrivateKey: testdata.WithPassphrase.Private,
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+
+		// Load keys into the agent directly (i.e., not through the
+		// manager). The copy of the configured key is collapsed into it.
+		for _, pem := range []string{testdata.WithoutPassphrase.Private, testdata.ED25519WithoutPassphrase.Private} {
+			priv, err := ssh.ParseRawPrivateKey([]byte(pem))
+			if err != nil {
+				t.Fatalf("failed to parse private key: %v", err)
+			}
+			if err := agt.Add(agent.AddedKey{PrivateKey: priv}); err != nil {
+				t.Fatalf("failed to load key into agent: %v", err)
+			}
+		}
+
+		snapshot, err := Snapshot(ctx, mgr)
+		if err != nil {
+			t.Fatalf("failed to get snapshot: %v", err)
+		}
+
+		type summary struct {
+			Name       string
+			Configured bool
+			Loaded     bool
+			Encrypted  bool
+			Type       string
+		}
+		var got []summary
+		for _, k := range snapshot {
+			got = append(got, summary{
+				Name:       k.Name,
+				Configured: k.ID != InvalidID,
+				Loaded:     k.Loaded,
+				Encrypted:  k.Encrypted,
+				Type:       k.Type,
+			})
+		}
+		want := []summary{
+			{Loaded: true, Type: testdata.ED25519WithoutPassphrase.Type},
+			{Name: "loaded-key", Configured: true, Loaded: true, Type: testdata.WithoutPassphrase.Type},
+			{Name: "unloaded-key", Configured: true, Encrypted: true},
+		}
+		if diff := cmp.Diff(got, want); diff != "" {
+			t.Errorf("incorrect snapshot; -got +want: %s", diff)
+		}
+	})
+}
@@ -0,0 +1,80 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"strings"
+	"sync"
+	"syscall/js"
+	"unicode/utf8"
+)
+
+// normalizeName returns the name in Unicode Normalization Form C, so that
+// names that are displayed identically (e.g., using a precomposed character,
+// or a base character followed by a combining mark) are also stored
+// identically. Leading and trailing whitespace (e.g., a newline copied along
+// with the name) is removed after normalization, so a name consisting only
+// of whitespace is returned as empty.
+//
+// Normalization is delegated to the JavaScript runtime, which implements it
+// for the Unicode version supported by the browser.
+func normalizeName(name string) (string, error) {
+	if !utf8.ValidString(name) {
+		return "", ErrInvalidName
+	}
+	// Methods cannot be invoked directly on a primitive string value.
+	normalize := js.Global().Get("String").Get("prototype").Get("normalize")
+	return strings.TrimSpace(normalize.Call("call", name, "NFC").String()), nil
+}
+
+// SameName reports whether a and b are the same key name once normalized as
+// they are when a key is configured. Empty names never match, since the
+// key's comment is then used as its name instead.
+func SameName(a, b string) bool {
+	na, err := normalizeName(a)
+	if err != nil || na == "" {
+		return false
+	}
+	nb, err := normalizeName(b)
+	return err == nil && na == nb
+}
+
+var (
+	collatorOnce sync.Once
+	collator     js.Value
+)
+
+// CompareNames compares two key names for display, returning a negative
+// number if a sorts before b, a positive number if a sorts after b, and zero
+// if they are identical. Names are compared using Unicode-aware collation
+// rather than byte order, so that (for example) accented characters sort
+// alongside their base characters. Names that collate equally are ordered
+// by their bytes so that the order is stable.
+func CompareNames(a, b string) int {
+	if a == b {
+		return 0
+	}
+	collatorOnce.Do(func() {
+		collator = js.Global().Get("Intl").Get("Collator").New(js.Undefined(), map[string]any{
+			"numeric": true,
+		})
+	})
+	if c := collator.Call("compare", a, b).Int(); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,182 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestNormalizeName(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		name        string
+		want        string
+		wantErr     error
+	}{
+		{
+			description: "ascii",
+			name:        "new-key",
+			want:        "new-key",
+		},
+		{
+			description: "emoji",
+			name:        "🔑 prod",
+			want:        "🔑 prod",
+		},
+		{
+			description: "combining character",
+			name:        "cafe\u0301",
+			want:        "caf\u00e9",
+		},
+		{
+			description: "already normalized",
+			name:        "caf\u00e9",
+			want:        "caf\u00e9",
+		},
+		{
+			description: "emoji with modifier",
+			name:        "\U0001f44d\U0001f3fd deploy",
+			want:        "\U0001f44d\U0001f3fd deploy",
+		},
+		{
+			description: "surrounding whitespace",
+			name:        " \tnew-key\r\n",
+			want:        "new-key",
+		},
+		{
+			description: "whitespace only",
+			name:        "   ",
+			want:        "",
+		},
+		{
+			description: "trailing combining character",
+			name:        "cafe\u0301 ",
+			want:        "caf\u00e9",
+		},
+		{
+			description: "invalid utf-8",
+			name:        "bad\xff",
+			wantErr:     ErrInvalidName,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := normalizeName(tc.name)
+			if diff := cmp.Diff(err, tc.wantErr, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("incorrect error; -got +want: %s", diff)
+			}
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Errorf("incorrect name; -got +want: %s", diff)
+			}
+		})
+	}
+}
+
+func TestSameName(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		a, b        string
+		want        bool
+	}{
+		{
+			description: "identical",
+			a:           "new-key",
+			b:           "new-key",
+			want:        true,
+		},
+		{
+			description: "different",
+			a:           "new-key-1",
+			b:           "new-key-2",
+			want:        false,
+		},
+		{
+			description: "surrounding whitespace",
+			a:           " new-key\n",
+			b:           "new-key",
+			want:        true,
+		},
+		{
+			description: "combining character",
+			a:           "cafe\u0301",
+			b:           "caf\u00e9",
+			want:        true,
+		},
+		{
+			description: "case differs",
+			a:           "New-Key",
+			b:           "new-key",
+			want:        false,
+		},
+		{
+			description: "empty names",
+			a:           " ",
+			b:           "",
+			want:        false,
+		},
+		{
+			description: "invalid utf-8",
+			a:           "bad\xff",
+			b:           "bad\xff",
+			want:        false,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			if got := SameName(tc.a, tc.b); got != tc.want {
+				t.Errorf("incorrect result for %q and %q: got %v, want %v", tc.a, tc.b, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCompareNames(t *testing.T) {
+	t.Parallel()
+
+	names := []string{"zeta", "Émile", "eve", "🔑 prod", "key10", "key2", "cafe\u0301", "caf\u00e9"}
+	want := []string{"🔑 prod", "cafe\u0301", "caf\u00e9", "Émile", "eve", "key2", "key10", "zeta"}
+
+	// Sorting must be independent of the initial order.
+	for i := 0; i < 2; i++ {
+		got := append([]string(nil), names...)
+		sort.Slice(got, func(i, j int) bool { return CompareNames(got[i], got[j]) < 0 })
+		if diff := cmp.Diff(got, want); diff != "" {
+			t.Errorf("incorrect order; -got +want: %s", diff)
+		}
+		sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	}
+
+	if c := CompareNames("🔑 prod", "🔑 prod"); c != 0 {
+		t.Errorf("identical names compared as %d, want 0", c)
+	}
+}
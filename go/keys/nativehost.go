@@ -0,0 +1,87 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"fmt"
+	"syscall/js"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	"github.com/norunners/vert"
+)
+
+const (
+	// NativeHostName is the name of the native messaging host through
+	// which the agent is exposed to other applications (e.g., a terminal
+	// via SSH_AUTH_SOCK). See Preferences.NativeHost.
+	NativeHostName = "com.google.chrome_ssh_agent"
+)
+
+// NativeHostStatus describes the connection to the native messaging host.
+type NativeHostStatus struct {
+	// Connected indicates that the agent is connected to the host.
+	Connected bool `js:"connected"`
+	// Err describes why the host was last disconnected. It is empty if
+	// the host is connected, or if no connection was attempted.
+	Err string `js:"err"`
+	// RetryAt is the time (in seconds since the Unix epoch) at which a
+	// new connection will be attempted. Zero indicates that no attempt
+	// is scheduled.
+	RetryAt int64 `js:"retryAt"`
+}
+
+var (
+	// nativeHostPrefixes are the prefixes for the native messaging host
+	// status. It is kept in session storage, since it describes the
+	// connection made by the current background worker.
+	nativeHostPrefixes = []string{"nativeHost"}
+)
+
+const (
+	// nativeHostKey is the key at which the native messaging host status
+	// is stored.
+	nativeHostKey = "status"
+)
+
+// NativeHostStatus implements Manager.NativeHostStatus.
+func (m *DefaultManager) NativeHostStatus(ctx jsutil.AsyncContext) (*NativeHostStatus, error) {
+	data, err := m.nativeHost.Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read native host status: %w", err)
+	}
+
+	var status NativeHostStatus
+	if val, present := data[nativeHostKey]; present {
+		if err := vert.ValueOf(val).AssignTo(&status); err != nil {
+			return nil, fmt.Errorf("failed to parse native host status: %w", err)
+		}
+	}
+	return &status, nil
+}
+
+// SetNativeHostStatus records the status of the connection to the native
+// messaging host. It is intended to be invoked only by the background
+// worker, which maintains the connection.
+func (m *DefaultManager) SetNativeHostStatus(ctx jsutil.AsyncContext, status *NativeHostStatus) error {
+	data := map[string]js.Value{
+		nativeHostKey: vert.ValueOf(status).JSValue(),
+	}
+	if err := m.nativeHost.Set(ctx, data); err != nil {
+		return fmt.Errorf("failed to write native host status: %w", err)
+	}
+	return nil
+}
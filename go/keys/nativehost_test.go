@@ -0,0 +1,60 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestNativeHostStatus(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		syncStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, nil)
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+
+		// No connection has been attempted.
+		status, err := mgr.NativeHostStatus(ctx)
+		if err != nil {
+			t.Fatalf("failed to get status: %v", err)
+		}
+		if diff := cmp.Diff(status, &NativeHostStatus{}); diff != "" {
+			t.Errorf("incorrect initial status; -got +want: %s", diff)
+		}
+
+		want := &NativeHostStatus{Err: "host not found", RetryAt: 1234}
+		if err := mgr.SetNativeHostStatus(ctx, want); err != nil {
+			t.Fatalf("failed to set status: %v", err)
+		}
+		status, err = mgr.NativeHostStatus(ctx)
+		if err != nil {
+			t.Fatalf("failed to get status: %v", err)
+		}
+		if diff := cmp.Diff(status, want); diff != "" {
+			t.Errorf("incorrect status; -got +want: %s", diff)
+		}
+	})
+}
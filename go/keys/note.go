@@ -0,0 +1,78 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+)
+
+const (
+	// maxNoteLength is the maximum length of a note, in characters. Notes
+	// are synced along with the key, so they are limited to stay well
+	// within the quota for each synced item.
+	maxNoteLength = 1000
+)
+
+var (
+	errNoteTooLong = errors.New("note is too long")
+)
+
+// normalizeNote returns the note with surrounding whitespace removed, and
+// Windows line endings converted to Unix line endings.
+func normalizeNote(note string) string {
+	return strings.TrimSpace(normalizeLineEndings(note))
+}
+
+// validateNote checks that a normalized note may be stored.
+func validateNote(note string) error {
+	if n := utf8.RuneCountInString(note); n > maxNoteLength {
+		return fmt.Errorf("%w: %d characters; at most %d are allowed", errNoteTooLong, n, maxNoteLength)
+	}
+	return nil
+}
+
+// SetNote implements Manager.SetNote.
+func (m *DefaultManager) SetNote(ctx jsutil.AsyncContext, id ID, note string) error {
+	note = normalizeNote(note)
+	if err := validateNote(note); err != nil {
+		return err
+	}
+	found := false
+	err := m.updateStoredKeys(ctx, func(sk *storedKey) bool {
+		if ID(sk.ID) != id {
+			return false
+		}
+		found = true
+		if sk.Note == note {
+			return false
+		}
+		sk.Note = note
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update key: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("%w: failed to find key with ID %s", errKeyNotFound, id)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestSetNote(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		byName      string
+		byID        ID
+		note        string
+		want        string
+		wantErr     error
+	}{
+		{
+			description: "set note",
+			byName:      "good-key",
+			note:        "prod bastion, rotate quarterly",
+			want:        "prod bastion, rotate quarterly",
+		},
+		{
+			description: "multiple lines",
+			byName:      "good-key",
+			note:        "  prod bastion\r\nrotate quarterly\n",
+			want:        "prod bastion\nrotate quarterly",
+		},
+		{
+			description: "clear note",
+			byName:      "good-key",
+			note:        " ",
+		},
+		{
+			description: "note too long",
+			byName:      "good-key",
+			note:        strings.Repeat("🔑", maxNoteLength+1),
+			want:        "original note",
+			wantErr:     errNoteTooLong,
+		},
+		{
+			description: "invalid key",
+			byID:        ID("bogus-id"),
+			note:        "prod bastion",
+			wantErr:     errKeyNotFound,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				syncStorage := storage.NewRaw(st.NewMemArea())
+				sessionStorage := storage.NewRaw(st.NewMemArea())
+				mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, []*initialKey{
+					{
+						Name:          "good-key",
+						PEMPrivateKey: testdata.WithoutPassphrase.Private,
+						AddOptions:    AddOptions{Note: "original note"},
+					},
+				})
+				if err != nil {
+					t.Fatalf("failed to initialize manager: %v", err)
+				}
+
+				id, err := findKey(ctx, mgr, tc.byID, tc.byName)
+				if err != nil {
+					t.Fatalf("failed to find key: %v", err)
+				}
+
+				err = mgr.SetNote(ctx, id, tc.note)
+				if diff := cmp.Diff(err, tc.wantErr, cmpopts.EquateErrors()); diff != "" {
+					t.Errorf("incorrect error; -got +want: %s", diff)
+				}
+				if tc.byID != InvalidID {
+					return
+				}
+
+				// The note is read back from storage by a new
+				// manager.
+				other := NewManager(agent.NewKeyring(), syncStorage, storage.NewRaw(st.NewMemArea()), sessionStorage)
+				configured, err := other.Configured(ctx)
+				if err != nil {
+					t.Fatalf("failed to enumerate configured keys: %v", err)
+				}
+				if diff := cmp.Diff(configured[0].Note, tc.want); diff != "" {
+					t.Errorf("incorrect note; -got +want: %s", diff)
+				}
+			})
+		})
+	}
+}
+
+func TestNoteNotSentToAgent(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		keyring := agent.NewKeyring()
+		_, err := newTestManager(ctx, keyring, storage.NewRaw(st.NewMemArea()), storage.NewRaw(st.NewMemArea()), []*initialKey{
+			{
+				Name:          "good-key",
+				PEMPrivateKey: testdata.WithoutPassphrase.Private,
+				AddOptions:    AddOptions{Note: "prod bastion"},
+				Load:          true,
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+
+		listed, err := keyring.List()
+		if err != nil {
+			t.Fatalf("failed to list keys: %v", err)
+		}
+		for _, k := range listed {
+			if strings.Contains(k.Comment, "prod bastion") {
+				t.Errorf("note sent to agent in comment %q", k.Comment)
+			}
+		}
+	})
+}
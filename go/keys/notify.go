@@ -0,0 +1,81 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"fmt"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	"golang.org/x/crypto/ssh"
+)
+
+// NotifyFunc is invoked to inform the user that the set of loaded keys has
+// changed.
+type NotifyFunc func(title, message string)
+
+// SetNotify sets the function used to inform the user when a key is loaded
+// or unloaded. Notifications are only displayed if enabled in the user's
+// preferences.
+func (s *Server) SetNotify(notify NotifyFunc) {
+	s.notify = notify
+}
+
+// notifyEnabled returns true if the user should be informed of changes to
+// the loaded keys.
+func (s *Server) notifyEnabled(ctx jsutil.AsyncContext) bool {
+	if s.notify == nil {
+		return false
+	}
+
+	prefs, err := s.mgr.Preferences(ctx)
+	if err != nil {
+		jsutil.LogError("Server.notifyEnabled: failed to read preferences: %v", err)
+		return false
+	}
+	return prefs.NotifyLoad
+}
+
+// describeKey returns a description of the loaded key for display to the
+// user, including its name and fingerprint. Details that cannot be
+// determined are omitted.
+func (s *Server) describeKey(ctx jsutil.AsyncContext, id ID) string {
+	name := string(id)
+	if configured, err := s.mgr.Configured(ctx); err == nil {
+		for _, k := range configured {
+			if ID(k.ID) == id {
+				name = k.Name
+				break
+			}
+		}
+	}
+
+	loaded, err := s.mgr.Loaded(ctx)
+	if err != nil {
+		return name
+	}
+	for _, l := range loaded {
+		if l.ID() != id {
+			continue
+		}
+		pub, err := ssh.ParsePublicKey(l.Blob())
+		if err != nil {
+			break
+		}
+		return fmt.Sprintf("%s (%s)", name, ssh.FingerprintSHA256(pub))
+	}
+	return name
+}
@@ -0,0 +1,150 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	mfakes "github.com/google/chrome-ssh-agent/go/message/fakes"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+type notification struct {
+	Title   string
+	Message string
+}
+
+func TestNotify(t *testing.T) {
+	t.Parallel()
+
+	blob, err := base64.StdEncoding.DecodeString(testdata.WithoutPassphrase.Blob)
+	if err != nil {
+		t.Fatalf("failed to decode blob: %v", err)
+	}
+	pub, err := ssh.ParsePublicKey(blob)
+	if err != nil {
+		t.Fatalf("failed to parse public key: %v", err)
+	}
+	desc := fmt.Sprintf("good-key (%s)", ssh.FingerprintSHA256(pub))
+
+	testcases := []struct {
+		description string
+		prefs       *Preferences
+		sequence    func(ctx jsutil.AsyncContext, mgr Manager, id ID) error
+		want        []notification
+	}{
+		{
+			description: "load and unload",
+			prefs:       &Preferences{NotifyLoad: true},
+			sequence: func(ctx jsutil.AsyncContext, mgr Manager, id ID) error {
+				if err := mgr.Load(ctx, id, "", LoadOptions{}); err != nil {
+					return err
+				}
+				return mgr.Unload(ctx, id)
+			},
+			want: []notification{
+				{Title: "SSH key loaded", Message: desc},
+				{Title: "SSH key unloaded", Message: desc},
+			},
+		},
+		{
+			description: "unload all",
+			prefs:       &Preferences{NotifyLoad: true},
+			sequence: func(ctx jsutil.AsyncContext, mgr Manager, id ID) error {
+				if err := mgr.Load(ctx, id, "", LoadOptions{}); err != nil {
+					return err
+				}
+				return mgr.UnloadAll(ctx)
+			},
+			want: []notification{
+				{Title: "SSH key loaded", Message: desc},
+				{Title: "SSH keys unloaded", Message: "All keys were unloaded from the agent."},
+			},
+		},
+		{
+			description: "no notification on failure",
+			prefs:       &Preferences{NotifyLoad: true},
+			sequence: func(ctx jsutil.AsyncContext, mgr Manager, id ID) error {
+				if err := mgr.Load(ctx, ID("bogus-id"), "", LoadOptions{}); err == nil {
+					return fmt.Errorf("loading invalid key unexpectedly succeeded")
+				}
+				return nil
+			},
+		},
+		{
+			description: "disabled by preferences",
+			prefs:       &Preferences{},
+			sequence: func(ctx jsutil.AsyncContext, mgr Manager, id ID) error {
+				if err := mgr.Load(ctx, id, "", LoadOptions{}); err != nil {
+					return err
+				}
+				return mgr.Unload(ctx, id)
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				syncStorage := storage.NewRaw(st.NewMemArea())
+				sessionStorage := storage.NewRaw(st.NewMemArea())
+				mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, []*initialKey{
+					{
+						Name:          "good-key",
+						PEMPrivateKey: testdata.WithoutPassphrase.Private,
+					},
+				})
+				if err != nil {
+					t.Fatalf("failed to initialize manager: %v", err)
+				}
+				if err := mgr.SetPreferences(ctx, tc.prefs); err != nil {
+					t.Fatalf("failed to set preferences: %v", err)
+				}
+				id, err := findKey(ctx, mgr, InvalidID, "good-key")
+				if err != nil {
+					t.Fatalf("failed to find key: %v", err)
+				}
+
+				hub := mfakes.NewHub()
+				cli := NewClient(hub)
+				srv := NewServer(mgr)
+				var got []notification
+				srv.SetNotify(func(title, message string) {
+					got = append(got, notification{Title: title, Message: message})
+				})
+				hub.AddReceiver(srv)
+
+				if err := tc.sequence(ctx, cli, id); err != nil {
+					t.Errorf("sequence failed: %v", err)
+				}
+				if diff := cmp.Diff(got, tc.want); diff != "" {
+					t.Errorf("incorrect notifications; -got +want: %s", diff)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,59 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+)
+
+// unloadOneShot unloads the key with the specified ID if it was loaded to be
+// used only once, and informs the user if enabled in their preferences. It is
+// invoked after the key has been used to sign data, once the signature has
+// been returned to the client.
+func (s *Server) unloadOneShot(ctx jsutil.AsyncContext, id ID) {
+	loaded, err := s.mgr.Loaded(ctx)
+	if err != nil {
+		jsutil.LogError("Server.unloadOneShot: failed to enumerate loaded keys: %v", err)
+		return
+	}
+	var oneShot bool
+	for _, l := range loaded {
+		if l.ID() == id {
+			oneShot = l.OneShot
+			break
+		}
+	}
+	if !oneShot {
+		return
+	}
+
+	// Describe the key before unloading it; its public key is no longer
+	// available afterwards.
+	notify := s.notifyEnabled(ctx)
+	var desc string
+	if notify {
+		desc = s.describeKey(ctx, id)
+	}
+	if err := s.mgr.Unload(ctx, id); err != nil {
+		jsutil.LogError("Server.unloadOneShot: failed to unload key %s: %v", id, err)
+		return
+	}
+	s.UpdateBadge(ctx)
+	if notify {
+		s.notify("SSH key unloaded", desc+" was unloaded after its first use.")
+	}
+}
@@ -0,0 +1,111 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestOneShot(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		var used []*LoadedKey
+		agt := NewUsageAgent(agent.NewKeyring().(agent.ExtendedAgent), func(key *LoadedKey) {
+			used = append(used, key)
+		})
+
+		syncStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		mgr, err := newTestManager(ctx, agt, syncStorage, sessionStorage, []*initialKey{
+			{
+				Name:          "one-shot-key",
+				PEMPrivateKey: testdata.WithoutPassphrase.Private,
+			},
+			{
+				Name:          "other-key",
+				PEMPrivateKey: testdata.ECDSAWithoutPassphrase.Private,
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+		srv := NewServer(mgr)
+
+		oneShot, err := findKey(ctx, mgr, InvalidID, "one-shot-key")
+		if err != nil {
+			t.Fatalf("failed to find key: %v", err)
+		}
+		other, err := findKey(ctx, mgr, InvalidID, "other-key")
+		if err != nil {
+			t.Fatalf("failed to find key: %v", err)
+		}
+		if err := mgr.Load(ctx, oneShot, "", LoadOptions{OneShot: true}); err != nil {
+			t.Fatalf("failed to load key: %v", err)
+		}
+		if err := mgr.Load(ctx, other, "", LoadOptions{}); err != nil {
+			t.Fatalf("failed to load key: %v", err)
+		}
+
+		loaded, err := mgr.Loaded(ctx)
+		if err != nil {
+			t.Fatalf("failed to get loaded keys: %v", err)
+		}
+		oneShots := make(map[ID]bool)
+		for _, l := range loaded {
+			oneShots[l.ID()] = l.OneShot
+		}
+		if diff := cmp.Diff(oneShots, map[ID]bool{oneShot: true, other: false}); diff != "" {
+			t.Errorf("incorrect one-shot keys; -got +want: %s", diff)
+		}
+
+		// Sign once with each key.
+		pubs, err := agt.List()
+		if err != nil {
+			t.Fatalf("failed to list keys: %v", err)
+		}
+		for _, pub := range pubs {
+			if _, err := agt.Sign(pub, []byte("some-data")); err != nil {
+				t.Errorf("failed to sign: %v", err)
+			}
+		}
+		for _, key := range used {
+			srv.KeyUsed(ctx, key)
+		}
+
+		// Only the one-shot key is unloaded.
+		loaded, err = mgr.Loaded(ctx)
+		if err != nil {
+			t.Fatalf("failed to get loaded keys: %v", err)
+		}
+		var gotLoaded []ID
+		for _, l := range loaded {
+			gotLoaded = append(gotLoaded, l.ID())
+		}
+		if diff := cmp.Diff(gotLoaded, []ID{other}); diff != "" {
+			t.Errorf("incorrect loaded keys; -got +want: %s", diff)
+		}
+	})
+}
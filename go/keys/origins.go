@@ -0,0 +1,122 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	errOriginNotAllowed = errors.New("client not allowed to use key")
+)
+
+// normalizeOrigins returns the origins with surrounding whitespace and any
+// trailing slash removed. Empty and duplicate origins are dropped.
+func normalizeOrigins(origins []string) []string {
+	var result []string
+	for _, o := range origins {
+		o = strings.TrimSuffix(strings.TrimSpace(o), "/")
+		if o == "" || slices.Contains(result, o) {
+			continue
+		}
+		result = append(result, o)
+	}
+	return result
+}
+
+// originAllowed indicates if a client with the specified origin may use a
+// key restricted to the allowed origins. An empty allowlist permits any
+// client.
+func originAllowed(allowed []string, origin string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	return slices.Contains(allowed, strings.TrimSuffix(origin, "/"))
+}
+
+// SetAllowedOrigins implements Manager.SetAllowedOrigins.
+func (m *DefaultManager) SetAllowedOrigins(ctx jsutil.AsyncContext, id ID, origins []string) error {
+	origins = normalizeOrigins(origins)
+	found := false
+	err := m.updateStoredKeys(ctx, func(sk *storedKey) bool {
+		if ID(sk.ID) != id {
+			return false
+		}
+		found = true
+		if slices.Equal(sk.AllowedOrigins, origins) {
+			return false
+		}
+		sk.AllowedOrigins = origins
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update key: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("%w: failed to find key with ID %s", errKeyNotFound, id)
+	}
+	return nil
+}
+
+// CheckOrigin returns an error if the client with the specified origin is
+// not allowed to use the loaded key for signing. Keys that are not
+// configured (e.g., those added directly to the agent) are not restricted.
+// It is intended to be invoked from an OriginCheckFunc.
+func (s *Server) CheckOrigin(ctx jsutil.AsyncContext, key *LoadedKey, origin string) error {
+	id := key.ID()
+	if id == InvalidID {
+		return nil
+	}
+
+	configured, err := s.mgr.Configured(ctx)
+	if err != nil {
+		// Refuse rather than risk signing for a disallowed client.
+		return fmt.Errorf("%w: failed to read keys: %v", errOriginNotAllowed, err)
+	}
+	for _, k := range configured {
+		if ID(k.ID) != id {
+			continue
+		}
+		if !originAllowed(k.AllowedOrigins, origin) {
+			return fmt.Errorf("%w: '%s' may not use key '%s'", errOriginNotAllowed, origin, k.Name)
+		}
+		return nil
+	}
+	return nil
+}
+
+// OriginCheckFunc is invoked before the agent signs data for a client, and
+// returns an error if the client may not use the key.
+//
+// OriginCheckFunc is invoked on the goroutine serving the agent request, so
+// it may block.
+type OriginCheckFunc func(key *LoadedKey, origin string) error
+
+// checkOrigin returns an error if the client served by the agent may not
+// use the key.
+func (a *AuditAgent) checkOrigin(key ssh.PublicKey) error {
+	if a.check == nil {
+		return nil
+	}
+	return a.check(lookupLoaded(a.ExtendedAgent, key), a.origin)
+}
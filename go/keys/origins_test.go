@@ -0,0 +1,217 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestSetAllowedOrigins(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		byName      string
+		byID        ID
+		origins     []string
+		want        []string
+		wantErr     error
+	}{
+		{
+			description: "restrict key",
+			byName:      "good-key",
+			origins:     []string{"chrome-extension://some-extension"},
+			want:        []string{"chrome-extension://some-extension"},
+		},
+		{
+			description: "normalize origins",
+			byName:      "good-key",
+			origins:     []string{" https://example.com/ ", "", "https://example.com"},
+			want:        []string{"https://example.com"},
+		},
+		{
+			description: "clear restriction",
+			byName:      "good-key",
+		},
+		{
+			description: "invalid key",
+			byID:        ID("bogus-id"),
+			origins:     []string{"https://example.com"},
+			wantErr:     errKeyNotFound,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				syncStorage := storage.NewRaw(st.NewMemArea())
+				sessionStorage := storage.NewRaw(st.NewMemArea())
+				mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, []*initialKey{
+					{
+						Name:          "good-key",
+						PEMPrivateKey: testdata.WithoutPassphrase.Private,
+						AddOptions:    AddOptions{AllowedOrigins: []string{"https://other.example.com"}},
+					},
+				})
+				if err != nil {
+					t.Fatalf("failed to initialize manager: %v", err)
+				}
+
+				id, err := findKey(ctx, mgr, tc.byID, tc.byName)
+				if err != nil {
+					t.Fatalf("failed to find key: %v", err)
+				}
+
+				err = mgr.SetAllowedOrigins(ctx, id, tc.origins)
+				if diff := cmp.Diff(err, tc.wantErr, cmpopts.EquateErrors()); diff != "" {
+					t.Errorf("incorrect error; -got +want: %s", diff)
+				}
+				if tc.wantErr != nil {
+					return
+				}
+
+				configured, err := mgr.Configured(ctx)
+				if err != nil {
+					t.Fatalf("failed to enumerate configured keys: %v", err)
+				}
+				if diff := cmp.Diff(configured[0].AllowedOrigins, tc.want, cmpopts.EquateEmpty()); diff != "" {
+					t.Errorf("incorrect allowed origins; -got +want: %s", diff)
+				}
+			})
+		})
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		allowed     []string
+		origin      string
+		wantErr     error
+	}{
+		{
+			description: "any client allowed",
+			origin:      "chrome-extension://some-extension",
+		},
+		{
+			description: "allowed client",
+			allowed:     []string{"chrome-extension://other-extension", "chrome-extension://some-extension"},
+			origin:      "chrome-extension://some-extension",
+		},
+		{
+			description: "allowed client with trailing slash",
+			allowed:     []string{"https://example.com"},
+			origin:      "https://example.com/",
+		},
+		{
+			description: "disallowed client",
+			allowed:     []string{"chrome-extension://other-extension"},
+			origin:      "chrome-extension://some-extension",
+			wantErr:     errOriginNotAllowed,
+		},
+		{
+			description: "unidentified client",
+			allowed:     []string{"chrome-extension://other-extension"},
+			origin:      "",
+			wantErr:     errOriginNotAllowed,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				var srv *Server
+				var signErrs []error
+				agt := NewAuditAgent(agent.NewKeyring().(agent.ExtendedAgent), tc.origin,
+					func(key *LoadedKey, origin string) error {
+						return srv.CheckOrigin(ctx, key, origin)
+					},
+					func(key *LoadedKey, origin string, err error) {
+						signErrs = append(signErrs, err)
+					})
+
+				syncStorage := storage.NewRaw(st.NewMemArea())
+				sessionStorage := storage.NewRaw(st.NewMemArea())
+				mgr, err := newTestManager(ctx, agt, syncStorage, sessionStorage, []*initialKey{
+					{
+						Name:          "some-key",
+						PEMPrivateKey: testdata.WithoutPassphrase.Private,
+						Load:          true,
+						AddOptions:    AddOptions{AllowedOrigins: tc.allowed},
+					},
+				})
+				if err != nil {
+					t.Fatalf("failed to initialize manager: %v", err)
+				}
+				srv = NewServer(mgr)
+
+				loaded, err := agt.List()
+				if err != nil {
+					t.Fatalf("failed to list keys: %v", err)
+				}
+				_, err = agt.Sign(loaded[0], []byte("some-data"))
+				if diff := cmp.Diff(err, tc.wantErr, cmpopts.EquateErrors()); diff != "" {
+					t.Errorf("incorrect error from Sign; -got +want: %s", diff)
+				}
+				_, err = agt.SignWithFlags(loaded[0], []byte("some-data"), 0)
+				if diff := cmp.Diff(err, tc.wantErr, cmpopts.EquateErrors()); diff != "" {
+					t.Errorf("incorrect error from SignWithFlags; -got +want: %s", diff)
+				}
+
+				// Refused requests are still reported.
+				if diff := cmp.Diff(len(signErrs), 2); diff != "" {
+					t.Errorf("incorrect number of reported requests; -got +want: %s", diff)
+				}
+			})
+		})
+	}
+}
+
+func TestCheckOriginExternalKey(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		syncStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, nil)
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+
+		// Keys added directly to the agent have no configured ID, and
+		// are not restricted.
+		key := &LoadedKey{Type: "ssh-rsa", Comment: "external"}
+		if err := NewServer(mgr).CheckOrigin(ctx, key, "https://example.com"); err != nil {
+			t.Errorf("CheckOrigin failed for external key: %v", err)
+		}
+	})
+}
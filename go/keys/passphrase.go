@@ -0,0 +1,102 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxPassphraseStrength is the highest score returned by PassphraseStrength.
+const MaxPassphraseStrength = 4
+
+// passphraseStrengthLabels describe each score returned by
+// PassphraseStrength.
+var passphraseStrengthLabels = []string{
+	"Very weak",
+	"Weak",
+	"Fair",
+	"Good",
+	"Strong",
+}
+
+// PassphraseStrength estimates the strength of a passphrase based on its
+// length and the variety of characters it contains. The score ranges from
+// zero to MaxPassphraseStrength; feedback describes the score and, if it
+// can be improved, how to do so.
+//
+// The estimate is advisory only; it does not account for dictionary words
+// or passphrases reused elsewhere.
+func PassphraseStrength(pw string) (score int, feedback string) {
+	if pw == "" {
+		return 0, "Enter a passphrase"
+	}
+
+	length := utf8.RuneCountInString(pw)
+	switch {
+	case length >= 16:
+		score = 3
+	case length >= 12:
+		score = 2
+	case length >= 8:
+		score = 1
+	}
+
+	var lower, upper, digit, other bool
+	distinct := make(map[rune]bool)
+	for _, r := range pw {
+		distinct[r] = true
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+	classes := 0
+	for _, present := range []bool{lower, upper, digit, other} {
+		if present {
+			classes++
+		}
+	}
+	if classes >= 3 {
+		score++
+	}
+	// A passphrase consisting of a single repeated character is no
+	// stronger than a very short one, regardless of length.
+	if len(distinct) == 1 {
+		score = 0
+	}
+	score = min(score, MaxPassphraseStrength)
+
+	feedback = passphraseStrengthLabels[score]
+	switch {
+	case len(distinct) == 1:
+		feedback += ": avoid repeating a single character"
+	case length < 12:
+		feedback += ": use at least 12 characters"
+	case classes < 3:
+		feedback += ": mix upper and lower case letters, digits and symbols"
+	case length < 16:
+		feedback += ": longer passphrases are stronger"
+	}
+	return score, feedback
+}
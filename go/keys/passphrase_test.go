@@ -0,0 +1,108 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPassphraseStrength(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description  string
+		passphrase   string
+		wantScore    int
+		wantFeedback string
+	}{
+		{
+			description:  "empty",
+			passphrase:   "",
+			wantScore:    0,
+			wantFeedback: "Enter a passphrase",
+		},
+		{
+			description:  "short",
+			passphrase:   "abc",
+			wantScore:    0,
+			wantFeedback: "Very weak: use at least 12 characters",
+		},
+		{
+			description:  "short with variety",
+			passphrase:   "aB3$",
+			wantScore:    1,
+			wantFeedback: "Weak: use at least 12 characters",
+		},
+		{
+			description:  "minimum length",
+			passphrase:   "abcdefgh",
+			wantScore:    1,
+			wantFeedback: "Weak: use at least 12 characters",
+		},
+		{
+			description:  "long without variety",
+			passphrase:   "abcdefghijkl",
+			wantScore:    2,
+			wantFeedback: "Fair: mix upper and lower case letters, digits and symbols",
+		},
+		{
+			description:  "long with variety",
+			passphrase:   "Abcdefghij1!",
+			wantScore:    3,
+			wantFeedback: "Good: longer passphrases are stronger",
+		},
+		{
+			description:  "very long without variety",
+			passphrase:   "correct horse battery staple",
+			wantScore:    3,
+			wantFeedback: "Good: mix upper and lower case letters, digits and symbols",
+		},
+		{
+			description:  "very long with variety",
+			passphrase:   "Correct horse battery staple 42",
+			wantScore:    4,
+			wantFeedback: "Strong",
+		},
+		{
+			description:  "repeated character",
+			passphrase:   "aaaaaaaaaaaaaaaaaaaa",
+			wantScore:    0,
+			wantFeedback: "Very weak: avoid repeating a single character",
+		},
+		{
+			description:  "counts characters rather than bytes",
+			passphrase:   "ééééééé1",
+			wantScore:    1,
+			wantFeedback: "Weak: use at least 12 characters",
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			score, feedback := PassphraseStrength(tc.passphrase)
+			if diff := cmp.Diff(score, tc.wantScore); diff != "" {
+				t.Errorf("incorrect score; -got +want: %s", diff)
+			}
+			if diff := cmp.Diff(feedback, tc.wantFeedback); diff != "" {
+				t.Errorf("incorrect feedback; -got +want: %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+)
+
+// passphraseCache remembers the passphrases used to successfully decrypt
+// keys, so that keys can be loaded again without prompting the user.
+// Passphrases are held only in memory, and are never persisted.
+//
+// Caching passphrases weakens security: anyone able to use the browser
+// profile may load a key while its passphrase is cached. It is therefore
+// disabled until a TTL is supplied via setTTL.
+type passphraseCache struct {
+	mu sync.Mutex
+	// ttl is the period for which a passphrase is cached. Zero indicates
+	// that passphrases are not cached.
+	ttl     time.Duration
+	entries map[ID]*cachedPassphrase
+}
+
+// cachedPassphrase is a passphrase held in the cache.
+type cachedPassphrase struct {
+	passphrase string
+	expiry     time.Time
+}
+
+func newPassphraseCache() *passphraseCache {
+	return &passphraseCache{
+		entries: map[ID]*cachedPassphrase{},
+	}
+}
+
+// setTTL sets the period for which passphrases are cached. Passphrases
+// already cached keep their original expiry, unless caching is disabled by
+// a zero TTL, in which case they are forgotten.
+func (c *passphraseCache) setTTL(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl = ttl
+	if ttl <= 0 {
+		c.entries = map[ID]*cachedPassphrase{}
+	}
+}
+
+// get returns the cached passphrase for the key, if any.
+func (c *passphraseCache) get(id ID) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.entries[id]
+	if e == nil || !time.Now().Before(e.expiry) {
+		return "", false
+	}
+	return e.passphrase, true
+}
+
+// put caches the passphrase for the key, if caching is enabled. The
+// passphrase is forgotten once the TTL expires.
+func (c *passphraseCache) put(id ID, passphrase string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ttl <= 0 {
+		return
+	}
+	e := &cachedPassphrase{
+		passphrase: passphrase,
+		expiry:     time.Now().Add(c.ttl),
+	}
+	c.entries[id] = e
+	jsutil.SetTimeout(c.ttl, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		// The entry may have since been replaced.
+		if c.entries[id] == e {
+			jsutil.LogDebug("passphraseCache: passphrase for key ID %s expired", id)
+			delete(c.entries, id)
+		}
+	})
+}
+
+// forget removes any cached passphrase for the key.
+func (c *passphraseCache) forget(id ID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, id)
+}
+
+// clear removes all cached passphrases.
+func (c *passphraseCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[ID]*cachedPassphrase{}
+}
+
+// SetPassphraseCacheTTL sets the period for which passphrases used to load
+// keys are cached in memory, allowing keys to be loaded again without a
+// passphrase. Zero disables caching and forgets any cached passphrases.
+func (m *DefaultManager) SetPassphraseCacheTTL(ttl time.Duration) {
+	m.passphrases.setTTL(ttl)
+}
+
+// ForgetPassphrases implements Manager.ForgetPassphrases.
+func (m *DefaultManager) ForgetPassphrases(ctx jsutil.AsyncContext) error {
+	m.passphrases.clear()
+	return nil
+}
@@ -0,0 +1,133 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestPassphraseCache(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		ttl         time.Duration
+		// afterLoad is invoked after the key is loaded with its
+		// passphrase, then unloaded.
+		afterLoad    func(ctx jsutil.AsyncContext, mgr *DefaultManager, id ID) error
+		wantCached   bool
+		wantReloaded bool
+	}{
+		{
+			description: "disabled by default",
+		},
+		{
+			description:  "cached within TTL",
+			ttl:          time.Minute,
+			wantCached:   true,
+			wantReloaded: true,
+		},
+		{
+			description: "forgotten after TTL",
+			ttl:         100 * time.Millisecond,
+			afterLoad: func(ctx jsutil.AsyncContext, mgr *DefaultManager, id ID) error {
+				time.Sleep(200 * time.Millisecond)
+				return nil
+			},
+		},
+		{
+			description: "forgotten explicitly",
+			ttl:         time.Minute,
+			afterLoad: func(ctx jsutil.AsyncContext, mgr *DefaultManager, id ID) error {
+				return mgr.ForgetPassphrases(ctx)
+			},
+		},
+		{
+			description: "forgotten when all keys unloaded",
+			ttl:         time.Minute,
+			afterLoad: func(ctx jsutil.AsyncContext, mgr *DefaultManager, id ID) error {
+				return mgr.UnloadAll(ctx)
+			},
+		},
+		{
+			description: "forgotten when caching disabled",
+			ttl:         time.Minute,
+			afterLoad: func(ctx jsutil.AsyncContext, mgr *DefaultManager, id ID) error {
+				mgr.SetPassphraseCacheTTL(0)
+				return nil
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				mgr, err := newTestManager(ctx, agent.NewKeyring(), storage.NewRaw(st.NewMemArea()), storage.NewRaw(st.NewMemArea()), []*initialKey{
+					{
+						Name:          "encrypted-key",
+						PEMPrivateKey: testdata.WithPassphrase.Private,
+					},
+				})
+				if err != nil {
+					t.Fatalf("failed to initialize manager: %v", err)
+				}
+				mgr.SetPassphraseCacheTTL(tc.ttl)
+				id, err := findKey(ctx, mgr, InvalidID, "encrypted-key")
+				if err != nil {
+					t.Fatalf("failed to find key: %v", err)
+				}
+
+				if err := mgr.Load(ctx, id, testdata.WithPassphrase.Passphrase, LoadOptions{}); err != nil {
+					t.Fatalf("failed to load key: %v", err)
+				}
+				if err := mgr.Unload(ctx, id); err != nil {
+					t.Fatalf("failed to unload key: %v", err)
+				}
+				if tc.afterLoad != nil {
+					if err := tc.afterLoad(ctx, mgr, id); err != nil {
+						t.Fatalf("afterLoad failed: %v", err)
+					}
+				}
+
+				configured, err := mgr.Configured(ctx)
+				if err != nil {
+					t.Fatalf("failed to get configured keys: %v", err)
+				}
+				if diff := cmp.Diff(configured[0].PassphraseCached, tc.wantCached); diff != "" {
+					t.Errorf("incorrect passphrase cached; -got +want: %s", diff)
+				}
+
+				// Loading without a passphrase succeeds only if it is
+				// cached.
+				err = mgr.Load(ctx, id, "", LoadOptions{})
+				if diff := cmp.Diff(err == nil, tc.wantReloaded); diff != "" {
+					t.Errorf("incorrect reload result (err=%v); -got +want: %s", err, diff)
+				}
+			})
+		})
+	}
+}
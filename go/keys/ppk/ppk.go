@@ -0,0 +1,435 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ppk parses private keys stored in PuTTY's .ppk format.
+//
+// Versions 2 and 3 of the format are supported. The format is described at
+// https://the.earth.li/~sgtatham/putty/latest/htmldoc/AppendixC.html.
+package ppk
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	// ErrInvalidFormat indicates that the file is not a valid PuTTY
+	// private key file.
+	ErrInvalidFormat = errors.New("ppk: invalid format")
+	// ErrUnsupported indicates that the file uses a version, algorithm,
+	// cipher or key derivation function that is not supported.
+	ErrUnsupported = errors.New("ppk: unsupported")
+	// ErrMACMismatch indicates that the integrity check failed. For
+	// encrypted files, this typically means the passphrase is incorrect.
+	ErrMACMismatch = errors.New("ppk: MAC mismatch")
+)
+
+const (
+	// headerPrefix is the prefix of the first line of a PuTTY private key
+	// file. The prefix is followed by the format version.
+	headerPrefix = "PuTTY-User-Key-File-"
+
+	// macKeyPrefix is prepended to the passphrase to derive the MAC key
+	// for version 2 files.
+	macKeyPrefix = "putty-private-key-file-mac-key"
+
+	encryptionNone   = "none"
+	encryptionAES256 = "aes256-cbc"
+)
+
+// IsPPK returns true if the data appears to be a PuTTY private key file.
+func IsPPK(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte(headerPrefix))
+}
+
+// kdfParams are the parameters used to derive keys from the passphrase in
+// version 3 files.
+type kdfParams struct {
+	Algorithm   string
+	Memory      uint32
+	Passes      uint32
+	Parallelism uint8
+	Salt        []byte
+}
+
+// Key is a parsed PuTTY private key file.
+type Key struct {
+	// Version is the version of the file format.
+	Version int
+	// Algorithm is the key algorithm (e.g., 'ssh-rsa').
+	Algorithm string
+	// Encryption is the cipher used to encrypt the private key material,
+	// or 'none'.
+	Encryption string
+	// Comment is the comment stored alongside the key.
+	Comment string
+	// PublicKey is the public key, which is never encrypted.
+	PublicKey ssh.PublicKey
+
+	publicBlob  []byte
+	privateBlob []byte
+	kdf         kdfParams
+	mac         []byte
+}
+
+// Encrypted returns true if the private key material is encrypted and a
+// passphrase is required to decrypt it.
+func (k *Key) Encrypted() bool {
+	return k.Encryption != encryptionNone
+}
+
+// lineReader reads the fields of a PuTTY private key file.
+type lineReader struct {
+	lines []string
+}
+
+// field reads the next line, which must be a field with the specified name,
+// and returns its value.
+func (r *lineReader) field(name string) (string, error) {
+	if len(r.lines) == 0 {
+		return "", fmt.Errorf("%w: missing %s", ErrInvalidFormat, name)
+	}
+	n, v, ok := strings.Cut(r.lines[0], ": ")
+	if !ok || n != name {
+		return "", fmt.Errorf("%w: expected %s", ErrInvalidFormat, name)
+	}
+	r.lines = r.lines[1:]
+	return v, nil
+}
+
+// uintField reads the next line as a field with an unsigned integer value.
+func (r *lineReader) uintField(name string, bitSize int) (uint64, error) {
+	v, err := r.field(name)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseUint(v, 10, bitSize)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid %s: %w", ErrInvalidFormat, name, err)
+	}
+	return n, nil
+}
+
+// blob reads a field specifying a number of lines, followed by that many
+// lines of base64-encoded data.
+func (r *lineReader) blob(name string) ([]byte, error) {
+	n, err := r.uintField(name, 16)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(r.lines)) < n {
+		return nil, fmt.Errorf("%w: truncated %s", ErrInvalidFormat, name)
+	}
+	b, err := base64.StdEncoding.DecodeString(strings.Join(r.lines[:n], ""))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid %s: %w", ErrInvalidFormat, name, err)
+	}
+	r.lines = r.lines[n:]
+	return b, nil
+}
+
+// Parse parses a PuTTY private key file. The private key material is not
+// decrypted; see RawPrivateKey.
+func Parse(data []byte) (*Key, error) {
+	text := strings.ReplaceAll(strings.TrimSpace(string(data)), "\r\n", "\n")
+	r := &lineReader{lines: strings.Split(text, "\n")}
+
+	var k Key
+	if len(r.lines) == 0 {
+		return nil, fmt.Errorf("%w: empty file", ErrInvalidFormat)
+	}
+	header, _, _ := strings.Cut(r.lines[0], ":")
+	if !strings.HasPrefix(header, headerPrefix) {
+		return nil, fmt.Errorf("%w: missing header", ErrInvalidFormat)
+	}
+	switch v := strings.TrimPrefix(header, headerPrefix); v {
+	case "2":
+		k.Version = 2
+	case "3":
+		k.Version = 3
+	default:
+		return nil, fmt.Errorf("%w: version %s", ErrUnsupported, v)
+	}
+
+	var err error
+	if k.Algorithm, err = r.field(header); err != nil {
+		return nil, err
+	}
+	if k.Encryption, err = r.field("Encryption"); err != nil {
+		return nil, err
+	}
+	if k.Encryption != encryptionNone && k.Encryption != encryptionAES256 {
+		return nil, fmt.Errorf("%w: encryption %s", ErrUnsupported, k.Encryption)
+	}
+	if k.Comment, err = r.field("Comment"); err != nil {
+		return nil, err
+	}
+	if k.publicBlob, err = r.blob("Public-Lines"); err != nil {
+		return nil, err
+	}
+	if k.PublicKey, err = ssh.ParsePublicKey(k.publicBlob); err != nil {
+		return nil, fmt.Errorf("%w: invalid public key: %w", ErrInvalidFormat, err)
+	}
+	if k.PublicKey.Type() != k.Algorithm {
+		return nil, fmt.Errorf("%w: public key type %s does not match algorithm %s", ErrInvalidFormat, k.PublicKey.Type(), k.Algorithm)
+	}
+	if k.Version == 3 && k.Encrypted() {
+		if err := r.kdf(&k.kdf); err != nil {
+			return nil, err
+		}
+	}
+	if k.privateBlob, err = r.blob("Private-Lines"); err != nil {
+		return nil, err
+	}
+	mac, err := r.field("Private-MAC")
+	if err != nil {
+		return nil, err
+	}
+	if k.mac, err = hex.DecodeString(mac); err != nil {
+		return nil, fmt.Errorf("%w: invalid Private-MAC: %w", ErrInvalidFormat, err)
+	}
+	return &k, nil
+}
+
+// kdf reads the key derivation parameters used in version 3 files.
+func (r *lineReader) kdf(p *kdfParams) error {
+	var err error
+	if p.Algorithm, err = r.field("Key-Derivation"); err != nil {
+		return err
+	}
+	if p.Algorithm != "Argon2id" && p.Algorithm != "Argon2i" {
+		// Argon2d is not supported by the underlying library.
+		return fmt.Errorf("%w: key derivation %s", ErrUnsupported, p.Algorithm)
+	}
+	memory, err := r.uintField("Argon2-Memory", 32)
+	if err != nil {
+		return err
+	}
+	passes, err := r.uintField("Argon2-Passes", 32)
+	if err != nil {
+		return err
+	}
+	parallelism, err := r.uintField("Argon2-Parallelism", 8)
+	if err != nil {
+		return err
+	}
+	salt, err := r.field("Argon2-Salt")
+	if err != nil {
+		return err
+	}
+	if p.Salt, err = hex.DecodeString(salt); err != nil {
+		return fmt.Errorf("%w: invalid Argon2-Salt: %w", ErrInvalidFormat, err)
+	}
+	p.Memory, p.Passes, p.Parallelism = uint32(memory), uint32(passes), uint8(parallelism)
+	return nil
+}
+
+// deriveKeys derives the cipher key, IV and MAC key from the passphrase.
+func (k *Key) deriveKeys(passphrase []byte) (cipherKey, iv, macKey []byte) {
+	if k.Version == 2 {
+		macSum := sha1.Sum(append([]byte(macKeyPrefix), passphrase...))
+		if !k.Encrypted() {
+			return nil, nil, macSum[:]
+		}
+		a := sha1.Sum(append([]byte{0, 0, 0, 0}, passphrase...))
+		b := sha1.Sum(append([]byte{0, 0, 0, 1}, passphrase...))
+		return append(a[:], b[:]...)[:32], make([]byte, aes.BlockSize), macSum[:]
+	}
+
+	if !k.Encrypted() {
+		return nil, nil, nil
+	}
+	derive := argon2.IDKey
+	if k.kdf.Algorithm == "Argon2i" {
+		derive = argon2.Key
+	}
+	out := derive(passphrase, k.kdf.Salt, k.kdf.Passes, k.kdf.Memory, k.kdf.Parallelism, 32+aes.BlockSize+32)
+	return out[:32], out[32 : 32+aes.BlockSize], out[32+aes.BlockSize:]
+}
+
+// RawPrivateKey decrypts and verifies the private key material, returning a
+// private key of the same type as ssh.ParseRawPrivateKey. The passphrase is
+// ignored if the private key is not encrypted.
+func (k *Key) RawPrivateKey(passphrase []byte) (interface{}, error) {
+	cipherKey, iv, macKey := k.deriveKeys(passphrase)
+
+	private := k.privateBlob
+	if k.Encrypted() {
+		if len(private)%aes.BlockSize != 0 {
+			return nil, fmt.Errorf("%w: private key length is not a multiple of the block size", ErrInvalidFormat)
+		}
+		c, err := aes.NewCipher(cipherKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize cipher: %w", err)
+		}
+		private = make([]byte, len(k.privateBlob))
+		cipher.NewCBCDecrypter(c, iv).CryptBlocks(private, k.privateBlob)
+	}
+
+	var newHash func() hash.Hash = sha256.New
+	if k.Version == 2 {
+		newHash = sha1.New
+	}
+	mac := hmac.New(newHash, macKey)
+	mac.Write(ssh.Marshal(struct {
+		Algorithm  string
+		Encryption string
+		Comment    string
+		Public     []byte
+		Private    []byte
+	}{k.Algorithm, k.Encryption, k.Comment, k.publicBlob, private}))
+	if !hmac.Equal(mac.Sum(nil), k.mac) {
+		if k.Encrypted() {
+			return nil, fmt.Errorf("%w: passphrase is incorrect or file is corrupt", ErrMACMismatch)
+		}
+		return nil, fmt.Errorf("%w: file is corrupt", ErrMACMismatch)
+	}
+
+	return k.parsePrivate(private)
+}
+
+// parsePrivate parses the decrypted private key material. Any trailing
+// padding is ignored.
+func (k *Key) parsePrivate(private []byte) (interface{}, error) {
+	switch k.Algorithm {
+	case ssh.KeyAlgoRSA:
+		var pub struct {
+			Name string
+			E    *big.Int
+			N    *big.Int
+		}
+		var priv struct {
+			D    *big.Int
+			P    *big.Int
+			Q    *big.Int
+			Iqmp *big.Int
+			Rest []byte `ssh:"rest"`
+		}
+		if err := unmarshal(k.publicBlob, &pub, private, &priv); err != nil {
+			return nil, err
+		}
+		if !pub.E.IsInt64() {
+			return nil, fmt.Errorf("%w: invalid RSA exponent", ErrInvalidFormat)
+		}
+		key := &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{N: pub.N, E: int(pub.E.Int64())},
+			D:         priv.D,
+			Primes:    []*big.Int{priv.P, priv.Q},
+		}
+		if err := key.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: invalid RSA key: %w", ErrInvalidFormat, err)
+		}
+		key.Precompute()
+		return key, nil
+
+	case ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521:
+		var priv struct {
+			D    *big.Int
+			Rest []byte `ssh:"rest"`
+		}
+		if err := ssh.Unmarshal(private, &priv); err != nil {
+			return nil, fmt.Errorf("%w: invalid private key: %w", ErrInvalidFormat, err)
+		}
+		// The curve and public point are determined by the public key.
+		ecPub, ok := k.PublicKey.(ssh.CryptoPublicKey).CryptoPublicKey().(*ecdsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid ECDSA public key", ErrInvalidFormat)
+		}
+		key := &ecdsa.PrivateKey{PublicKey: *ecPub, D: priv.D}
+		x, y := key.Curve.ScalarBaseMult(priv.D.Bytes())
+		if x.Cmp(ecPub.X) != 0 || y.Cmp(ecPub.Y) != 0 {
+			return nil, fmt.Errorf("%w: ECDSA private key does not match public key", ErrInvalidFormat)
+		}
+		return key, nil
+
+	case ssh.KeyAlgoED25519:
+		var pub struct {
+			Name string
+			Key  []byte
+		}
+		var priv struct {
+			Seed []byte
+			Rest []byte `ssh:"rest"`
+		}
+		if err := unmarshal(k.publicBlob, &pub, private, &priv); err != nil {
+			return nil, err
+		}
+		if len(priv.Seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("%w: invalid Ed25519 private key length", ErrInvalidFormat)
+		}
+		key := ed25519.NewKeyFromSeed(priv.Seed)
+		if !bytes.Equal(key.Public().(ed25519.PublicKey), pub.Key) {
+			return nil, fmt.Errorf("%w: Ed25519 private key does not match public key", ErrInvalidFormat)
+		}
+		return key, nil
+
+	default:
+		return nil, fmt.Errorf("%w: algorithm %s", ErrUnsupported, k.Algorithm)
+	}
+}
+
+// unmarshal decodes the public and private key material.
+func unmarshal(publicBlob []byte, pub interface{}, privateBlob []byte, priv interface{}) error {
+	if err := ssh.Unmarshal(publicBlob, pub); err != nil {
+		return fmt.Errorf("%w: invalid public key: %w", ErrInvalidFormat, err)
+	}
+	if err := ssh.Unmarshal(privateBlob, priv); err != nil {
+		return fmt.Errorf("%w: invalid private key: %w", ErrInvalidFormat, err)
+	}
+	return nil
+}
+
+// ParseRawPrivateKey returns a private key from a PuTTY private key file. If
+// the private key is encrypted, it returns a *ssh.PassphraseMissingError,
+// mirroring ssh.ParseRawPrivateKey.
+func ParseRawPrivateKey(data []byte) (interface{}, error) {
+	k, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if k.Encrypted() {
+		return nil, &ssh.PassphraseMissingError{PublicKey: k.PublicKey}
+	}
+	return k.RawPrivateKey(nil)
+}
+
+// ParseRawPrivateKeyWithPassphrase returns a private key decrypted with
+// passphrase from a PuTTY private key file.
+func ParseRawPrivateKeyWithPassphrase(data, passphrase []byte) (interface{}, error) {
+	k, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return k.RawPrivateKey(passphrase)
+}
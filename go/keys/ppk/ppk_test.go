@@ -0,0 +1,277 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ppk
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestIsPPK(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		data        string
+		want        bool
+	}{
+		{
+			description: "version 2",
+			data:        testdata.PPKv2WithoutPassphrase.Private,
+			want:        true,
+		},
+		{
+			description: "version 3",
+			data:        testdata.PPKv3WithPassphrase.Private,
+			want:        true,
+		},
+		{
+			description: "pem key",
+			data:        testdata.WithoutPassphrase.Private,
+		},
+		{
+			description: "openssh key",
+			data:        testdata.OpenSSHFormat.Private,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			if diff := cmp.Diff(IsPPK([]byte(tc.data)), tc.want); diff != "" {
+				t.Errorf("incorrect result; -got +want: %s", diff)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description   string
+		data          string
+		wantVersion   int
+		wantEncrypted bool
+		wantBlob      string
+		wantErr       error
+	}{
+		{
+			description: "version 2 without passphrase",
+			data:        testdata.PPKv2WithoutPassphrase.Private,
+			wantVersion: 2,
+			wantBlob:    testdata.PPKv2WithoutPassphrase.Blob,
+		},
+		{
+			description:   "version 2 with passphrase",
+			data:          testdata.PPKv2WithPassphrase.Private,
+			wantVersion:   2,
+			wantEncrypted: true,
+			wantBlob:      testdata.PPKv2WithPassphrase.Blob,
+		},
+		{
+			description: "version 3 without passphrase",
+			data:        testdata.PPKv3WithoutPassphrase.Private,
+			wantVersion: 3,
+			wantBlob:    testdata.PPKv3WithoutPassphrase.Blob,
+		},
+		{
+			description:   "version 3 with passphrase",
+			data:          testdata.PPKv3WithPassphrase.Private,
+			wantVersion:   3,
+			wantEncrypted: true,
+			wantBlob:      testdata.PPKv3WithPassphrase.Blob,
+		},
+		{
+			description: "windows line endings",
+			data:        strings.ReplaceAll(testdata.PPKv3WithoutPassphrase.Private, "\n", "\r\n"),
+			wantVersion: 3,
+			wantBlob:    testdata.PPKv3WithoutPassphrase.Blob,
+		},
+		{
+			description: "unsupported version",
+			data:        strings.Replace(testdata.PPKv2WithoutPassphrase.Private, "File-2", "File-1", 1),
+			wantErr:     ErrUnsupported,
+		},
+		{
+			description: "unsupported encryption",
+			data:        strings.Replace(testdata.PPKv2WithPassphrase.Private, "aes256-cbc", "3des-cbc", 1),
+			wantErr:     ErrUnsupported,
+		},
+		{
+			description: "unsupported key derivation",
+			data:        strings.Replace(testdata.PPKv3WithPassphrase.Private, "Argon2id", "Argon2d", 1),
+			wantErr:     ErrUnsupported,
+		},
+		{
+			description: "missing field",
+			data:        strings.Replace(testdata.PPKv3WithoutPassphrase.Private, "Comment: test-comment\n", "", 1),
+			wantErr:     ErrInvalidFormat,
+		},
+		{
+			description: "truncated",
+			data:        strings.Split(testdata.PPKv3WithoutPassphrase.Private, "Private-Lines")[0],
+			wantErr:     ErrInvalidFormat,
+		},
+		{
+			description: "not a ppk file",
+			data:        testdata.WithoutPassphrase.Private,
+			wantErr:     ErrInvalidFormat,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			k, err := Parse([]byte(tc.data))
+			if diff := cmp.Diff(err, tc.wantErr, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("incorrect error; -got +want: %s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(k.Version, tc.wantVersion); diff != "" {
+				t.Errorf("incorrect version; -got +want: %s", diff)
+			}
+			if diff := cmp.Diff(k.Encrypted(), tc.wantEncrypted); diff != "" {
+				t.Errorf("incorrect encrypted; -got +want: %s", diff)
+			}
+			if diff := cmp.Diff(k.Comment, "test-comment"); diff != "" {
+				t.Errorf("incorrect comment; -got +want: %s", diff)
+			}
+			blob := base64.StdEncoding.EncodeToString(k.PublicKey.Marshal())
+			if diff := cmp.Diff(blob, tc.wantBlob); diff != "" {
+				t.Errorf("incorrect public key; -got +want: %s", diff)
+			}
+		})
+	}
+}
+
+func TestParseRawPrivateKey(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description       string
+		data              string
+		passphrase        string
+		wantBlob          string
+		wantMissingPhrase bool
+		wantErr           error
+	}{
+		{
+			description: "version 2 rsa key",
+			data:        testdata.PPKv2WithoutPassphrase.Private,
+			wantBlob:    testdata.PPKv2WithoutPassphrase.Blob,
+		},
+		{
+			description: "version 2 encrypted rsa key",
+			data:        testdata.PPKv2WithPassphrase.Private,
+			passphrase:  testdata.PPKv2WithPassphrase.Passphrase,
+			wantBlob:    testdata.PPKv2WithPassphrase.Blob,
+		},
+		{
+			description: "version 3 ed25519 key",
+			data:        testdata.PPKv3WithoutPassphrase.Private,
+			wantBlob:    testdata.PPKv3WithoutPassphrase.Blob,
+		},
+		{
+			description: "version 3 encrypted ed25519 key",
+			data:        testdata.PPKv3WithPassphrase.Private,
+			passphrase:  testdata.PPKv3WithPassphrase.Passphrase,
+			wantBlob:    testdata.PPKv3WithPassphrase.Blob,
+		},
+		{
+			description: "version 3 ecdsa key",
+			data:        testdata.PPKv3ECDSAWithoutPassphrase.Private,
+			wantBlob:    testdata.PPKv3ECDSAWithoutPassphrase.Blob,
+		},
+		{
+			description:       "encrypted key without passphrase",
+			data:              testdata.PPKv3WithPassphrase.Private,
+			wantMissingPhrase: true,
+		},
+		{
+			description: "version 2 incorrect passphrase",
+			data:        testdata.PPKv2WithPassphrase.Private,
+			passphrase:  "incorrect passphrase",
+			wantErr:     ErrMACMismatch,
+		},
+		{
+			description: "version 3 incorrect passphrase",
+			data:        testdata.PPKv3WithPassphrase.Private,
+			passphrase:  "incorrect passphrase",
+			wantErr:     ErrMACMismatch,
+		},
+		{
+			description: "modified comment",
+			data:        strings.Replace(testdata.PPKv3WithoutPassphrase.Private, "test-comment", "other-comment", 1),
+			wantErr:     ErrMACMismatch,
+		},
+		{
+			description: "modified mac",
+			data:        strings.Replace(testdata.PPKv2WithoutPassphrase.Private, "Private-MAC: 0", "Private-MAC: 1", 1),
+			wantErr:     ErrMACMismatch,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var priv interface{}
+			var err error
+			if tc.passphrase != "" {
+				priv, err = ParseRawPrivateKeyWithPassphrase([]byte(tc.data), []byte(tc.passphrase))
+			} else {
+				priv, err = ParseRawPrivateKey([]byte(tc.data))
+			}
+
+			var missing *ssh.PassphraseMissingError
+			if tc.wantMissingPhrase {
+				if !errors.As(err, &missing) {
+					t.Errorf("incorrect error; got %v, want PassphraseMissingError", err)
+				}
+				return
+			}
+			if diff := cmp.Diff(err, tc.wantErr, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("incorrect error; -got +want: %s", diff)
+			}
+			if err != nil {
+				return
+			}
+
+			signer, err := ssh.NewSignerFromKey(priv)
+			if err != nil {
+				t.Fatalf("failed to create signer: %v", err)
+			}
+			blob := base64.StdEncoding.EncodeToString(signer.PublicKey().Marshal())
+			if diff := cmp.Diff(blob, tc.wantBlob); diff != "" {
+				t.Errorf("incorrect public key; -got +want: %s", diff)
+			}
+		})
+	}
+}
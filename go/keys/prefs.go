@@ -0,0 +1,195 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"fmt"
+	"syscall/js"
+	"time"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	"github.com/norunners/vert"
+)
+
+// Preferences are user-configurable settings that control the behavior of
+// the extension.
+//
+// Preferences are stored as a single object. Fields missing from the stored
+// object (e.g., those added in a newer release) assume their zero value, so
+// the zero value of each field must correspond to the default behavior.
+type Preferences struct {
+	// ConfirmUnload indicates that the user must confirm before a key is
+	// unloaded from the agent.
+	ConfirmUnload bool `js:"confirmUnload"`
+	// ConfirmLoad indicates that the user must confirm the details of a
+	// key before it is loaded from the options page.
+	ConfirmLoad bool `js:"confirmLoad"`
+	// NotifyLoad indicates that a notification is displayed whenever a key
+	// is loaded into or unloaded from the agent.
+	NotifyLoad bool `js:"notifyLoad"`
+	// IdleUnloadMins is the number of minutes without the agent being
+	// used to list keys or sign data, after which all keys are unloaded.
+	// Zero indicates that keys are not unloaded when idle.
+	IdleUnloadMins uint32 `js:"idleUnloadMins"`
+	// ShowKeyMaterial indicates that the public key material for each key
+	// is displayed in the options page.
+	ShowKeyMaterial bool `js:"showKeyMaterial"`
+	// ShowMD5Fingerprint indicates that the legacy MD5 fingerprint of
+	// each key is displayed in the options page alongside the SHA256
+	// fingerprint.
+	ShowMD5Fingerprint bool `js:"showMD5Fingerprint"`
+	// CompactView indicates that configured keys are displayed in dense,
+	// single-line rows.
+	CompactView bool `js:"compactView"`
+	// KeysPerPage is the maximum number of keys displayed at once in the
+	// options page. Zero indicates that all keys are displayed.
+	KeysPerPage uint32 `js:"keysPerPage"`
+	// DebugLogging indicates that debug messages are logged to the
+	// console, to aid troubleshooting.
+	DebugLogging bool `js:"debugLogging"`
+	// DebugConsole indicates that the options page offers a console for
+	// invoking the manager directly, to aid troubleshooting.
+	DebugConsole bool `js:"debugConsole"`
+	// PassphraseCacheMins is the number of minutes for which the
+	// passphrase used to load an encrypted key is cached in memory, during
+	// which the key may be loaded again without the passphrase. This
+	// weakens security, since anyone with access to the browser may load
+	// the key while the passphrase is cached. Zero indicates that
+	// passphrases are not cached.
+	PassphraseCacheMins uint32 `js:"passphraseCacheMins"`
+	// NativeHost indicates that the agent is exposed to other
+	// applications (e.g., a terminal) through the native messaging host
+	// named NativeHostName, which must be installed separately.
+	NativeHost bool `js:"nativeHost"`
+	// MaxLoadedKeys is the maximum number of configured keys that may be
+	// loaded into the agent at once. Zero indicates no limit.
+	MaxLoadedKeys uint32 `js:"maxLoadedKeys"`
+	// EvictLRU indicates that, when loading a key would exceed
+	// MaxLoadedKeys, the least recently used key is unloaded to make room
+	// instead of the load failing.
+	EvictLRU bool `js:"evictLRU"`
+	// MinKeyBits is the size below which RSA and DSA keys are flagged as
+	// weak. Zero indicates that DefaultMinKeyBits applies.
+	MinKeyBits uint32 `js:"minKeyBits"`
+	// SortColumn is the column by which configured keys are sorted in
+	// the options page; either SortByName or SortByType. Empty indicates
+	// the order arranged by the user.
+	SortColumn string `js:"sortColumn"`
+	// SortDescending indicates that keys are sorted by SortColumn in
+	// descending order.
+	SortDescending bool `js:"sortDescending"`
+	// CollapsedTags are the tags whose groups of keys are collapsed in
+	// the options page. The empty tag denotes keys without any tags.
+	CollapsedTags []string `js:"collapsedTags"`
+	// RefreshSecs is the interval, in seconds, at which the options page
+	// refreshes the state of the agent while it is displayed. Zero
+	// indicates that DefaultRefreshSecs applies.
+	RefreshSecs uint32 `js:"refreshSecs"`
+}
+
+const (
+	// SortByName sorts keys by name.
+	SortByName = "name"
+	// SortByType sorts keys by type.
+	SortByType = "type"
+)
+
+const (
+	// DefaultMinKeyBits is the size below which RSA and DSA keys are
+	// flagged as weak, unless the user selects otherwise.
+	DefaultMinKeyBits = 2048
+	// DefaultRefreshSecs is the interval, in seconds, at which the
+	// options page refreshes the state of the agent, unless the user
+	// selects otherwise.
+	DefaultRefreshSecs = 10
+)
+
+// WeakKeyBits returns the size below which RSA and DSA keys are flagged as
+// weak.
+func (p *Preferences) WeakKeyBits() int {
+	if p.MinKeyBits == 0 {
+		return DefaultMinKeyBits
+	}
+	return int(p.MinKeyBits)
+}
+
+// RefreshInterval returns the interval at which the options page refreshes
+// the state of the agent while it is displayed.
+func (p *Preferences) RefreshInterval() time.Duration {
+	if p.RefreshSecs == 0 {
+		return DefaultRefreshSecs * time.Second
+	}
+	return time.Duration(p.RefreshSecs) * time.Second
+}
+
+// IdleTimeout returns the period of inactivity after which all keys are
+// unloaded. Zero indicates that keys are not unloaded when idle.
+func (p *Preferences) IdleTimeout() time.Duration {
+	return time.Duration(p.IdleUnloadMins) * time.Minute
+}
+
+// PassphraseCacheTTL returns the period for which passphrases are cached.
+// Zero indicates that passphrases are not cached.
+func (p *Preferences) PassphraseCacheTTL() time.Duration {
+	return time.Duration(p.PassphraseCacheMins) * time.Minute
+}
+
+// LogLevel returns the level at which messages are logged to the console.
+func (p *Preferences) LogLevel() jsutil.LogLevel {
+	if p.DebugLogging {
+		return jsutil.LogLevelDebug
+	}
+	return jsutil.DefaultLogLevel
+}
+
+var (
+	// prefsPrefixes are the prefixes for preferences stored in persistent
+	// storage.
+	prefsPrefixes = []string{"prefs"}
+)
+
+const (
+	// prefsKey is the key at which preferences are stored.
+	prefsKey = "default"
+)
+
+// Preferences implements Manager.Preferences.
+func (m *DefaultManager) Preferences(ctx jsutil.AsyncContext) (*Preferences, error) {
+	data, err := m.prefs.Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read preferences: %w", err)
+	}
+
+	var prefs Preferences
+	if val, present := data[prefsKey]; present {
+		if err := vert.ValueOf(val).AssignTo(&prefs); err != nil {
+			return nil, fmt.Errorf("failed to parse preferences: %w", err)
+		}
+	}
+	return &prefs, nil
+}
+
+// SetPreferences implements Manager.SetPreferences.
+func (m *DefaultManager) SetPreferences(ctx jsutil.AsyncContext, prefs *Preferences) error {
+	data := map[string]js.Value{
+		prefsKey: vert.ValueOf(prefs).JSValue(),
+	}
+	if err := m.prefs.Set(ctx, data); err != nil {
+		return fmt.Errorf("failed to write preferences: %w", err)
+	}
+	return nil
+}
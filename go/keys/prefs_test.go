@@ -0,0 +1,126 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestPreferences(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		// Storage persists across multiple manager instances.
+		syncStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+
+		mgr := NewManager(agent.NewKeyring(), syncStorage, storage.NewRaw(st.NewMemArea()), sessionStorage)
+
+		// Defaults are returned if nothing has been stored.
+		prefs, err := mgr.Preferences(ctx)
+		if err != nil {
+			t.Fatalf("failed to read default preferences: %v", err)
+		}
+		if diff := cmp.Diff(prefs, &Preferences{}); diff != "" {
+			t.Errorf("incorrect default preferences; -got +want: %s", diff)
+		}
+
+		// Updated preferences are visible to a subsequent instance.
+		want := &Preferences{ConfirmUnload: true}
+		if err := mgr.SetPreferences(ctx, want); err != nil {
+			t.Fatalf("failed to set preferences: %v", err)
+		}
+		mgr = NewManager(agent.NewKeyring(), syncStorage, storage.NewRaw(st.NewMemArea()), sessionStorage)
+		prefs, err = mgr.Preferences(ctx)
+		if err != nil {
+			t.Fatalf("failed to read preferences: %v", err)
+		}
+		if diff := cmp.Diff(prefs, want); diff != "" {
+			t.Errorf("incorrect preferences; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestPreferencesLogLevel(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		prefs       *Preferences
+		want        jsutil.LogLevel
+	}{
+		{
+			description: "default",
+			prefs:       &Preferences{},
+			want:        jsutil.DefaultLogLevel,
+		},
+		{
+			description: "debug logging",
+			prefs:       &Preferences{DebugLogging: true},
+			want:        jsutil.LogLevelDebug,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			if diff := cmp.Diff(tc.prefs.LogLevel(), tc.want); diff != "" {
+				t.Errorf("incorrect log level; -got +want: %s", diff)
+			}
+		})
+	}
+}
+
+func TestPreferencesRefreshInterval(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		prefs       *Preferences
+		want        time.Duration
+	}{
+		{
+			description: "default",
+			prefs:       &Preferences{},
+			want:        DefaultRefreshSecs * time.Second,
+		},
+		{
+			description: "selected interval",
+			prefs:       &Preferences{RefreshSecs: 30},
+			want:        30 * time.Second,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			if diff := cmp.Diff(tc.prefs.RefreshInterval(), tc.want); diff != "" {
+				t.Errorf("incorrect refresh interval; -got +want: %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// SetPriority implements Manager.SetPriority.
+func (m *DefaultManager) SetPriority(ctx jsutil.AsyncContext, id ID, priority int) error {
+	found := false
+	err := m.updateStoredKeys(ctx, func(sk *storedKey) bool {
+		if ID(sk.ID) != id {
+			return false
+		}
+		found = true
+		if sk.Priority == priority {
+			return false
+		}
+		sk.Priority = priority
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update key: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("%w: failed to find key with ID %s", errKeyNotFound, id)
+	}
+	return nil
+}
+
+// byPriority orders stored keys so that those with a higher priority come
+// first. Keys with the same priority retain their relative order when used
+// with a stable sort.
+func byPriority(a, b *storedKey) int {
+	return cmp.Compare(b.Priority, a.Priority)
+}
+
+// OrderIdentities returns the keys loaded in the agent, ordered so that keys
+// with a higher priority are offered to servers first. Keys with the same
+// priority, including keys that are not configured, retain the order in
+// which the agent listed them. It is intended to be invoked from an
+// IdentityOrderFunc.
+func (s *Server) OrderIdentities(ctx jsutil.AsyncContext, loaded []*agent.Key) []*agent.Key {
+	configured, err := s.mgr.Configured(ctx)
+	if err != nil {
+		// The keys remain usable, if not in the preferred order.
+		jsutil.LogError("Server.OrderIdentities: failed to read keys: %v", err)
+		return loaded
+	}
+
+	byID := make(map[ID]int)
+	// Keys adopted from the agent are loaded by something other than this
+	// extension, so they are matched by fingerprint rather than by the ID
+	// in the comment.
+	byFingerprint := make(map[string]int)
+	for _, k := range configured {
+		byID[ID(k.ID)] = k.Priority
+		if k.PublicOnly && k.Fingerprint != "" {
+			byFingerprint[k.Fingerprint] = k.Priority
+		}
+	}
+	priority := func(k *agent.Key) int {
+		if p, ok := byID[(&LoadedKey{Comment: k.Comment}).ID()]; ok {
+			return p
+		}
+		return byFingerprint[ssh.FingerprintSHA256(k)]
+	}
+
+	result := slices.Clone(loaded)
+	slices.SortStableFunc(result, func(a, b *agent.Key) int {
+		return cmp.Compare(priority(b), priority(a))
+	})
+	return result
+}
+
+// IdentityOrderFunc is invoked when a client lists the keys loaded in the
+// agent, and returns the keys in the order in which they are offered.
+//
+// IdentityOrderFunc is invoked on the goroutine serving the agent request, so
+// it may block.
+type IdentityOrderFunc func(keys []*agent.Key) []*agent.Key
+
+// PriorityAgent wraps an agent and lists keys in order of priority, so that
+// servers try preferred keys first. Servers typically limit the number of
+// keys a client may offer, so offering the preferred key early avoids
+// authentication failures when many keys are loaded.
+type PriorityAgent struct {
+	agent.ExtendedAgent
+
+	order IdentityOrderFunc
+}
+
+// NewPriorityAgent returns a PriorityAgent wrapping the supplied agent.
+func NewPriorityAgent(agt agent.ExtendedAgent, order IdentityOrderFunc) *PriorityAgent {
+	return &PriorityAgent{
+		ExtendedAgent: agt,
+		order:         order,
+	}
+}
+
+// List implements agent.Agent.List.
+func (a *PriorityAgent) List() ([]*agent.Key, error) {
+	keys, err := a.ExtendedAgent.List()
+	if err != nil {
+		return nil, err
+	}
+	return a.order(keys), nil
+}
@@ -0,0 +1,205 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestSetPriority(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		byName      string
+		byID        ID
+		priority    int
+		wantErr     error
+	}{
+		{
+			description: "raise priority",
+			byName:      "good-key",
+			priority:    10,
+		},
+		{
+			description: "lower priority",
+			byName:      "good-key",
+			priority:    -5,
+		},
+		{
+			description: "default priority",
+			byName:      "good-key",
+		},
+		{
+			description: "invalid key",
+			byID:        ID("bogus-id"),
+			priority:    10,
+			wantErr:     errKeyNotFound,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				syncStorage := storage.NewRaw(st.NewMemArea())
+				sessionStorage := storage.NewRaw(st.NewMemArea())
+				mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, []*initialKey{
+					{
+						Name:          "good-key",
+						PEMPrivateKey: testdata.WithoutPassphrase.Private,
+					},
+				})
+				if err != nil {
+					t.Fatalf("failed to initialize manager: %v", err)
+				}
+
+				id, err := findKey(ctx, mgr, tc.byID, tc.byName)
+				if err != nil {
+					t.Fatalf("failed to find key: %v", err)
+				}
+
+				err = mgr.SetPriority(ctx, id, tc.priority)
+				if diff := cmp.Diff(err, tc.wantErr, cmpopts.EquateErrors()); diff != "" {
+					t.Errorf("incorrect error; -got +want: %s", diff)
+				}
+				if tc.wantErr != nil {
+					return
+				}
+
+				configured, err := mgr.Configured(ctx)
+				if err != nil {
+					t.Fatalf("failed to enumerate configured keys: %v", err)
+				}
+				if diff := cmp.Diff(configured[0].Priority, tc.priority); diff != "" {
+					t.Errorf("incorrect priority; -got +want: %s", diff)
+				}
+			})
+		})
+	}
+}
+
+func TestPriorityAgent(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		priorities  map[string]int
+		want        []string
+	}{
+		{
+			description: "agent order by default",
+			want: []string{
+				testdata.WithoutPassphrase.Blob,
+				testdata.ECDSAWithoutPassphrase.Blob,
+				testdata.ED25519WithoutPassphrase.Blob,
+			},
+		},
+		{
+			description: "higher priority first",
+			priorities: map[string]int{
+				"rsa-key":     -1,
+				"ed25519-key": 10,
+			},
+			want: []string{
+				testdata.ED25519WithoutPassphrase.Blob,
+				testdata.ECDSAWithoutPassphrase.Blob,
+				testdata.WithoutPassphrase.Blob,
+			},
+		},
+		{
+			description: "equal priority retains agent order",
+			priorities: map[string]int{
+				"rsa-key":   5,
+				"ecdsa-key": 5,
+			},
+			want: []string{
+				testdata.WithoutPassphrase.Blob,
+				testdata.ECDSAWithoutPassphrase.Blob,
+				testdata.ED25519WithoutPassphrase.Blob,
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				keyring := agent.NewKeyring().(agent.ExtendedAgent)
+				syncStorage := storage.NewRaw(st.NewMemArea())
+				sessionStorage := storage.NewRaw(st.NewMemArea())
+				mgr, err := newTestManager(ctx, keyring, syncStorage, sessionStorage, []*initialKey{
+					{
+						Name:          "rsa-key",
+						PEMPrivateKey: testdata.WithoutPassphrase.Private,
+						Load:          true,
+					},
+					{
+						Name:          "ecdsa-key",
+						PEMPrivateKey: testdata.ECDSAWithoutPassphrase.Private,
+						Load:          true,
+					},
+					{
+						Name:          "ed25519-key",
+						PEMPrivateKey: testdata.ED25519WithoutPassphrase.Private,
+						Load:          true,
+					},
+				})
+				if err != nil {
+					t.Fatalf("failed to initialize manager: %v", err)
+				}
+				for name, priority := range tc.priorities {
+					id, err := findKey(ctx, mgr, InvalidID, name)
+					if err != nil {
+						t.Fatalf("failed to find key: %v", err)
+					}
+					if err := mgr.SetPriority(ctx, id, priority); err != nil {
+						t.Fatalf("failed to set priority: %v", err)
+					}
+				}
+
+				srv := NewServer(mgr)
+				agt := NewPriorityAgent(keyring, func(keys []*agent.Key) []*agent.Key {
+					return srv.OrderIdentities(ctx, keys)
+				})
+				listed, err := agt.List()
+				if err != nil {
+					t.Fatalf("failed to list keys: %v", err)
+				}
+				var got []string
+				for _, k := range listed {
+					got = append(got, base64.StdEncoding.EncodeToString(k.Marshal()))
+				}
+				if diff := cmp.Diff(got, tc.want); diff != "" {
+					t.Errorf("incorrect identity order; -got +want: %s", diff)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,87 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	"golang.org/x/crypto/ssh"
+)
+
+// ExportPublicKeys implements Manager.ExportPublicKeys.
+func (m *DefaultManager) ExportPublicKeys(ctx jsutil.AsyncContext) (string, error) {
+	stored, err := m.storedKeys.ReadAll(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to read keys: %w", err)
+	}
+	loaded, err := m.Loaded(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get loaded keys: %w", err)
+	}
+
+	// The public key of an encrypted key can still be exported if the
+	// key is loaded into the agent.
+	loadedPub := make(map[ID]ssh.PublicKey)
+	for _, l := range loaded {
+		if pub, err := ssh.ParsePublicKey(l.Blob()); err == nil {
+			loadedPub[l.ID()] = pub
+		}
+	}
+
+	// Sort to ensure consistent output.
+	sort.SliceStable(stored, func(i, j int) bool { return stored[i].Name < stored[j].Name })
+
+	var buf bytes.Buffer
+	var missing []string
+	for _, k := range stored {
+		pub := k.PublicKey()
+		if pub == nil {
+			pub = loadedPub[ID(k.ID)]
+		}
+		if pub == nil {
+			missing = append(missing, k.Name)
+			continue
+		}
+		buf.WriteString(authorizedKey(pub, k.Name))
+	}
+
+	if len(missing) > 0 {
+		buf.WriteString("# The following keys are encrypted and not loaded; load them to\n")
+		buf.WriteString("# export their public keys:\n")
+		for _, name := range missing {
+			fmt.Fprintf(&buf, "#   %s\n", name)
+		}
+	}
+	return buf.String(), nil
+}
+
+// authorizedKey returns a line in authorized_keys format for the public key,
+// with the name as its comment.
+func authorizedKey(pub ssh.PublicKey, name string) string {
+	line := strings.TrimSuffix(string(ssh.MarshalAuthorizedKey(pub)), "\n")
+	// A comment spans the remainder of the line, so it must not contain a
+	// line break.
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" {
+		return line + "\n"
+	}
+	return fmt.Sprintf("%s %s\n", line, name)
+}
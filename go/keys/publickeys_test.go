@@ -0,0 +1,124 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestExportPublicKeys(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		syncStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, []*initialKey{
+			{
+				Name:          "plain-key",
+				PEMPrivateKey: testdata.WithoutPassphrase.Private,
+			},
+			{
+				Name:          "loaded-key",
+				PEMPrivateKey: testdata.WithPassphrase.Private,
+				Load:          true,
+				Passphrase:    testdata.WithPassphrase.Passphrase,
+			},
+			{
+				Name:          "locked-key",
+				PEMPrivateKey: testdata.PKCS8Format.Private,
+			},
+			{
+				Name:          "openssh-key",
+				PEMPrivateKey: testdata.ED25519WithPassphrase.Private,
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+
+		got, err := mgr.ExportPublicKeys(ctx)
+		if err != nil {
+			t.Fatalf("failed to export public keys: %v", err)
+		}
+		// Encrypted keys are exported if they are loaded, or if the
+		// public key is stored unencrypted.
+		want := fmt.Sprintf("%s %s loaded-key\n", testdata.WithPassphrase.Type, testdata.WithPassphrase.Blob) +
+			fmt.Sprintf("%s %s openssh-key\n", testdata.ED25519WithPassphrase.Type, testdata.ED25519WithPassphrase.Blob) +
+			fmt.Sprintf("%s %s plain-key\n", testdata.WithoutPassphrase.Type, testdata.WithoutPassphrase.Blob) +
+			"# The following keys are encrypted and not loaded; load them to\n" +
+			"# export their public keys:\n" +
+			"#   locked-key\n"
+		if diff := cmp.Diff(got, want); diff != "" {
+			t.Errorf("incorrect public keys; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestAuthorizedKeyName(t *testing.T) {
+	t.Parallel()
+
+	blob, err := base64.StdEncoding.DecodeString(testdata.WithoutPassphrase.Blob)
+	if err != nil {
+		t.Fatalf("failed to decode blob: %v", err)
+	}
+	pub, err := ssh.ParsePublicKey(blob)
+	if err != nil {
+		t.Fatalf("failed to parse public key: %v", err)
+	}
+	prefix := fmt.Sprintf("%s %s", testdata.WithoutPassphrase.Type, testdata.WithoutPassphrase.Blob)
+
+	testcases := []struct {
+		description string
+		name        string
+		want        string
+	}{
+		{
+			description: "simple name",
+			name:        "my-key",
+			want:        prefix + " my-key\n",
+		},
+		{
+			description: "line break in name",
+			name:        "my\nkey ",
+			want:        prefix + " my key\n",
+		},
+		{
+			description: "empty name",
+			want:        prefix + "\n",
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			if diff := cmp.Diff(authorizedKey(pub, tc.name), tc.want); diff != "" {
+				t.Errorf("incorrect line; -got +want: %s", diff)
+			}
+		})
+	}
+}
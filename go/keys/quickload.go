@@ -0,0 +1,72 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"fmt"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+)
+
+// LoadWithoutPrompt loads the key with the specified ID into the agent if it
+// can be loaded without prompting the user; that is, if it is not encrypted
+// or its passphrase is cached. The user is informed if enabled in their
+// preferences. It is intended for loading keys from outside the options page
+// (e.g., from the context menu).
+//
+// ok is false if a passphrase is required, in which case the key is not
+// loaded. Loading a key that is already loaded has no effect.
+func (s *Server) LoadWithoutPrompt(ctx jsutil.AsyncContext, id ID) (ok bool, err error) {
+	configured, err := s.mgr.Configured(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to read keys: %w", err)
+	}
+	var key *ConfiguredKey
+	for _, k := range configured {
+		if ID(k.ID) == id {
+			key = k
+			break
+		}
+	}
+	if key == nil {
+		return false, fmt.Errorf("%w: failed to find key with ID %s", errKeyNotFound, id)
+	}
+
+	loaded, err := s.mgr.Loaded(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to enumerate loaded keys: %w", err)
+	}
+	for _, l := range loaded {
+		if l.ID() == id {
+			return true, nil
+		}
+	}
+
+	if key.Encrypted && !key.PassphraseCached {
+		return false, nil
+	}
+
+	err = s.mgr.Load(ctx, id, "", LoadOptions{})
+	if err == nil && s.notifyEnabled(ctx) {
+		s.notify("SSH key loaded", s.describeKey(ctx, id))
+	}
+	s.UpdateBadge(ctx)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestLoadWithoutPrompt(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description      string
+		name             string
+		id               ID
+		cacheTTL         time.Duration
+		cachePassphrase  bool
+		wantOk           bool
+		wantLoaded       bool
+		wantNotification bool
+		wantErr          error
+	}{
+		{
+			description:      "unencrypted key",
+			name:             "unencrypted-key",
+			wantOk:           true,
+			wantLoaded:       true,
+			wantNotification: true,
+		},
+		{
+			description: "encrypted key requires passphrase",
+			name:        "encrypted-key",
+		},
+		{
+			description:      "encrypted key with cached passphrase",
+			name:             "encrypted-key",
+			cacheTTL:         time.Minute,
+			cachePassphrase:  true,
+			wantOk:           true,
+			wantLoaded:       true,
+			wantNotification: true,
+		},
+		{
+			description: "disabled key",
+			name:        "disabled-key",
+			wantErr:     errKeyDisabled,
+		},
+		{
+			description: "invalid ID",
+			id:          ID("bogus-id"),
+			wantErr:     errKeyNotFound,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				mgr, err := newTestManager(ctx, agent.NewKeyring(), storage.NewRaw(st.NewMemArea()), storage.NewRaw(st.NewMemArea()), []*initialKey{
+					{
+						Name:          "unencrypted-key",
+						PEMPrivateKey: testdata.WithoutPassphrase.Private,
+					},
+					{
+						Name:          "encrypted-key",
+						PEMPrivateKey: testdata.ED25519WithPassphrase.Private,
+					},
+					{
+						Name:          "disabled-key",
+						PEMPrivateKey: testdata.ECDSAWithoutPassphrase.Private,
+						AddOptions:    AddOptions{Disabled: true},
+					},
+				})
+				if err != nil {
+					t.Fatalf("failed to initialize manager: %v", err)
+				}
+				if err := mgr.SetPreferences(ctx, &Preferences{NotifyLoad: true}); err != nil {
+					t.Fatalf("failed to set preferences: %v", err)
+				}
+				mgr.SetPassphraseCacheTTL(tc.cacheTTL)
+
+				id := tc.id
+				if id == InvalidID {
+					id, err = findKey(ctx, mgr, InvalidID, tc.name)
+					if err != nil {
+						t.Fatalf("failed to find key: %v", err)
+					}
+				}
+				if tc.cachePassphrase {
+					if err := mgr.Load(ctx, id, testdata.ED25519WithPassphrase.Passphrase, LoadOptions{}); err != nil {
+						t.Fatalf("failed to load key: %v", err)
+					}
+					if err := mgr.Unload(ctx, id); err != nil {
+						t.Fatalf("failed to unload key: %v", err)
+					}
+				}
+
+				srv := NewServer(mgr)
+				var notified bool
+				srv.SetNotify(func(title, message string) { notified = true })
+
+				ok, err := srv.LoadWithoutPrompt(ctx, id)
+				if diff := cmp.Diff(err, tc.wantErr, cmpopts.EquateErrors()); diff != "" {
+					t.Errorf("incorrect error; -got +want: %s", diff)
+				}
+				if diff := cmp.Diff(ok, tc.wantOk); diff != "" {
+					t.Errorf("incorrect result; -got +want: %s", diff)
+				}
+				if diff := cmp.Diff(notified, tc.wantNotification); diff != "" {
+					t.Errorf("incorrect notification; -got +want: %s", diff)
+				}
+
+				loaded, err := mgr.Loaded(ctx)
+				if err != nil {
+					t.Fatalf("failed to get loaded keys: %v", err)
+				}
+				if diff := cmp.Diff(len(loaded) == 1, tc.wantLoaded); diff != "" {
+					t.Errorf("incorrect loaded; -got +want: %s", diff)
+				}
+			})
+		})
+	}
+}
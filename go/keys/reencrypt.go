@@ -0,0 +1,87 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"encoding/pem"
+	"fmt"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	"golang.org/x/crypto/ssh"
+)
+
+// reencryptKey decrypts the stored private key using oldPassphrase, and
+// returns it encrypted in OpenSSH format using newPassphrase. The returned
+// key is unencrypted if newPassphrase is empty. The comment embedded in the
+// private key is retained if it can be read without a passphrase.
+func reencryptKey(key *storedKey, oldPassphrase, newPassphrase string) (string, error) {
+	if enc, unsupported := key.encryptionState(); enc == encryptionUnsupported {
+		return "", fmt.Errorf("%w: %s", errParseFailed, unsupported)
+	}
+
+	decrypted, err := decryptKey(key, oldPassphrase)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt key: %w", err)
+	}
+	priv, err := parseDecryptedKey(decrypted)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", errParseFailed, err)
+	}
+
+	var block *pem.Block
+	if newPassphrase == "" {
+		block, err = ssh.MarshalPrivateKey(priv, key.Comment())
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(priv, key.Comment(), []byte(newPassphrase))
+	}
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", errMarshalFailed, err)
+	}
+	return string(pem.EncodeToMemory(block)), nil
+}
+
+// Reencrypt implements Manager.Reencrypt.
+func (m *DefaultManager) Reencrypt(ctx jsutil.AsyncContext, id ID, oldPassphrase, newPassphrase string) error {
+	found := false
+	var updateErr error
+	err := m.updateStoredKeys(ctx, func(sk *storedKey) bool {
+		if ID(sk.ID) != id {
+			return false
+		}
+		found = true
+		pemPrivateKey, err := reencryptKey(sk, oldPassphrase, newPassphrase)
+		if err != nil {
+			updateErr = err
+			return false
+		}
+		enc, _ := detectEncryption(pemPrivateKey)
+		sk.PEMPrivateKey = pemPrivateKey
+		sk.Encryption = string(enc)
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update key: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("%w: failed to find key with ID %s", errKeyNotFound, id)
+	}
+	if updateErr == nil {
+		// The cached passphrase, if any, no longer decrypts the key.
+		m.passphrases.forget(id)
+	}
+	return updateErr
+}
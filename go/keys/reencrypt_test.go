@@ -0,0 +1,190 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"crypto/x509"
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestReencrypt(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description   string
+		key           testdata.TestKey
+		byID          ID
+		oldPassphrase string
+		newPassphrase string
+		wantErr       error
+		wantEncrypted bool
+	}{
+		{
+			description:   "add passphrase",
+			key:           testdata.WithoutPassphrase,
+			newPassphrase: "new-secret",
+			wantEncrypted: true,
+		},
+		{
+			description:   "change passphrase",
+			key:           testdata.ED25519WithPassphrase,
+			oldPassphrase: testdata.ED25519WithPassphrase.Passphrase,
+			newPassphrase: "new-secret",
+			wantEncrypted: true,
+		},
+		{
+			description:   "remove passphrase",
+			key:           testdata.ECDSAWithPassphrase,
+			oldPassphrase: testdata.ECDSAWithPassphrase.Passphrase,
+		},
+		{
+			description:   "change passphrase for PKCS#8 key",
+			key:           testdata.PKCS8Format,
+			oldPassphrase: testdata.PKCS8Format.Passphrase,
+			newPassphrase: "new-secret",
+			wantEncrypted: true,
+		},
+		{
+			description:   "change passphrase for PPK key",
+			key:           testdata.PPKv3WithPassphrase,
+			oldPassphrase: testdata.PPKv3WithPassphrase.Passphrase,
+			newPassphrase: "new-secret",
+			wantEncrypted: true,
+		},
+		{
+			description:   "incorrect old passphrase",
+			key:           testdata.WithPassphrase,
+			oldPassphrase: "incorrect-passphrase",
+			newPassphrase: "new-secret",
+			wantErr:       x509.IncorrectPasswordError,
+			wantEncrypted: true,
+		},
+		{
+			description:   "unsupported cipher",
+			key:           testdata.ED25519UnsupportedCipher,
+			oldPassphrase: testdata.ED25519UnsupportedCipher.Passphrase,
+			newPassphrase: "new-secret",
+			wantErr:       errParseFailed,
+			wantEncrypted: true,
+		},
+		{
+			description:   "invalid ID",
+			key:           testdata.WithoutPassphrase,
+			byID:          ID("bogus-id"),
+			newPassphrase: "new-secret",
+			wantErr:       errKeyNotFound,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				syncStorage := storage.NewRaw(st.NewMemArea())
+				sessionStorage := storage.NewRaw(st.NewMemArea())
+				initial := []*initialKey{
+					{Name: "key", PEMPrivateKey: tc.key.Private},
+				}
+				mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, initial)
+				if err != nil {
+					t.Fatalf("failed to initialize manager: %v", err)
+				}
+				id, err := findKey(ctx, mgr, InvalidID, "key")
+				if err != nil {
+					t.Fatalf("failed to find key: %v", err)
+				}
+				before, err := mgr.storedKeys.Read(ctx, func(sk *storedKey) bool { return ID(sk.ID) == id })
+				if err != nil {
+					t.Fatalf("failed to read key: %v", err)
+				}
+
+				reencryptID := id
+				if tc.byID != InvalidID {
+					reencryptID = tc.byID
+				}
+				err = mgr.Reencrypt(ctx, reencryptID, tc.oldPassphrase, tc.newPassphrase)
+				if diff := cmp.Diff(err, tc.wantErr, cmpopts.EquateErrors()); diff != "" {
+					t.Errorf("incorrect error; -got +want: %s", diff)
+				}
+
+				after, err := mgr.storedKeys.Read(ctx, func(sk *storedKey) bool { return ID(sk.ID) == id })
+				if err != nil {
+					t.Fatalf("failed to read key: %v", err)
+				}
+				if tc.wantErr != nil || tc.byID != InvalidID {
+					// The key is left unmodified.
+					if diff := cmp.Diff(after.PEMPrivateKey, before.PEMPrivateKey); diff != "" {
+						t.Errorf("key modified; -got +want: %s", diff)
+					}
+					return
+				}
+				if diff := cmp.Diff(after.Encrypted(), tc.wantEncrypted); diff != "" {
+					t.Errorf("incorrect encryption; -got +want: %s", diff)
+				}
+
+				// The key can be loaded with the new passphrase.
+				if err := mgr.Load(ctx, id, tc.newPassphrase, LoadOptions{}); err != nil {
+					t.Fatalf("failed to load key: %v", err)
+				}
+				loaded, err := mgr.Loaded(ctx)
+				if err != nil {
+					t.Fatalf("failed to enumerate loaded keys: %v", err)
+				}
+				if diff := cmp.Diff(loadedKeyBlobs(loaded), []string{tc.key.Blob}); diff != "" {
+					t.Errorf("incorrect loaded keys; -got +want: %s", diff)
+				}
+			})
+		})
+	}
+}
+
+func TestReencryptOldPassphraseRejected(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		syncStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		initial := []*initialKey{
+			{Name: "key", PEMPrivateKey: testdata.WithPassphrase.Private},
+		}
+		mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, initial)
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+		id, err := findKey(ctx, mgr, InvalidID, "key")
+		if err != nil {
+			t.Fatalf("failed to find key: %v", err)
+		}
+
+		if err := mgr.Reencrypt(ctx, id, testdata.WithPassphrase.Passphrase, "new-secret"); err != nil {
+			t.Fatalf("failed to re-encrypt key: %v", err)
+		}
+		err = mgr.Load(ctx, id, testdata.WithPassphrase.Passphrase, LoadOptions{})
+		if diff := cmp.Diff(err, x509.IncorrectPasswordError, cmpopts.EquateErrors()); diff != "" {
+			t.Errorf("incorrect error loading with old passphrase; -got +want: %s", diff)
+		}
+	})
+}
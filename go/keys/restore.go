@@ -0,0 +1,64 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"fmt"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+)
+
+var (
+	// removedKeyPrefixes are the prefixes for the most recently removed
+	// configured key, retained so that its removal may be undone. It is
+	// kept in session storage, so that it is discarded when the browser
+	// exits if not discarded sooner (see DiscardRemoved).
+	removedKeyPrefixes = []string{"removedKey"}
+)
+
+// stashRemoved retains a configured key that is about to be removed, so that
+// it may later be restored. Any key retained earlier is discarded.
+func (m *DefaultManager) stashRemoved(ctx jsutil.AsyncContext, sk *storedKey) error {
+	if err := m.removedKeys.Delete(ctx, func(*storedKey) bool { return true }); err != nil {
+		return fmt.Errorf("failed to discard previously removed key: %w", err)
+	}
+	return m.removedKeys.WriteKey(ctx, sk.ID, sk)
+}
+
+// Restore implements Manager.Restore.
+func (m *DefaultManager) Restore(ctx jsutil.AsyncContext, id ID) error {
+	sk, err := m.removedKeys.ReadKey(ctx, string(id))
+	if err != nil {
+		return fmt.Errorf("failed to read removed key: %w", err)
+	}
+	if sk == nil {
+		return fmt.Errorf("%w: failed to find removed key with ID %s", errKeyNotFound, id)
+	}
+
+	if err := m.storedKeys.WriteKey(ctx, sk.ID, sk); err != nil {
+		return fmt.Errorf("failed to write key: %w", err)
+	}
+	return m.DiscardRemoved(ctx, id)
+}
+
+// DiscardRemoved implements Manager.DiscardRemoved.
+func (m *DefaultManager) DiscardRemoved(ctx jsutil.AsyncContext, id ID) error {
+	if err := m.removedKeys.Delete(ctx, func(rk *storedKey) bool { return ID(rk.ID) == id }); err != nil {
+		return fmt.Errorf("failed to discard removed key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestRestore(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		syncStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, []*initialKey{
+			{
+				Name:          "first-key",
+				PEMPrivateKey: testdata.WithoutPassphrase.Private,
+				AddOptions:    AddOptions{ConfirmBeforeUse: true},
+			},
+			{
+				Name:          "second-key",
+				PEMPrivateKey: testdata.ED25519WithoutPassphrase.Private,
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+		first, err := findKey(ctx, mgr, InvalidID, "first-key")
+		if err != nil {
+			t.Fatalf("failed to find key: %v", err)
+		}
+		second, err := findKey(ctx, mgr, InvalidID, "second-key")
+		if err != nil {
+			t.Fatalf("failed to find key: %v", err)
+		}
+
+		configured := func() map[ID]bool {
+			keys, err := mgr.Configured(ctx)
+			if err != nil {
+				t.Fatalf("failed to enumerate configured keys: %v", err)
+			}
+			result := map[ID]bool{}
+			for _, k := range keys {
+				result[ID(k.ID)] = k.ConfirmBeforeUse
+			}
+			return result
+		}
+
+		// Only the most recently removed key may be restored.
+		if err := mgr.Remove(ctx, second); err != nil {
+			t.Fatalf("failed to remove key: %v", err)
+		}
+		if err := mgr.Remove(ctx, first); err != nil {
+			t.Fatalf("failed to remove key: %v", err)
+		}
+		if diff := cmp.Diff(configured(), map[ID]bool{}); diff != "" {
+			t.Errorf("incorrect configured keys; -got +want: %s", diff)
+		}
+		if err := mgr.Restore(ctx, second); !errors.Is(err, errKeyNotFound) {
+			t.Errorf("Restore() of earlier key returned %v; want %v", err, errKeyNotFound)
+		}
+
+		// The key is restored with its settings intact.
+		if err := mgr.Restore(ctx, first); err != nil {
+			t.Fatalf("failed to restore key: %v", err)
+		}
+		if diff := cmp.Diff(configured(), map[ID]bool{first: true}); diff != "" {
+			t.Errorf("incorrect configured keys; -got +want: %s", diff)
+		}
+
+		// A key may be restored only once.
+		if err := mgr.Restore(ctx, first); !errors.Is(err, errKeyNotFound) {
+			t.Errorf("repeated Restore() returned %v; want %v", err, errKeyNotFound)
+		}
+
+		// A discarded key may not be restored.
+		if err := mgr.Remove(ctx, first); err != nil {
+			t.Fatalf("failed to remove key: %v", err)
+		}
+		if err := mgr.DiscardRemoved(ctx, first); err != nil {
+			t.Fatalf("failed to discard removed key: %v", err)
+		}
+		if err := mgr.Restore(ctx, first); !errors.Is(err, errKeyNotFound) {
+			t.Errorf("Restore() of discarded key returned %v; want %v", err, errKeyNotFound)
+		}
+		if diff := cmp.Diff(configured(), map[ID]bool{}); diff != "" {
+			t.Errorf("incorrect configured keys; -got +want: %s", diff)
+		}
+
+		// Discarding a key that is not retained succeeds.
+		if err := mgr.DiscardRemoved(ctx, first); err != nil {
+			t.Errorf("repeated DiscardRemoved() failed: %v", err)
+		}
+	})
+}
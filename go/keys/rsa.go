@@ -0,0 +1,251 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"crypto/rsa"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+const (
+	// ClientRSASignatureAlgorithm indicates that an RSA key signs using
+	// the algorithm requested by the client. This is used unless the user
+	// selects a specific algorithm, so that clients requesting legacy
+	// ssh-rsa signatures (e.g., for older servers) receive them.
+	ClientRSASignatureAlgorithm = "client"
+
+	// rsaSignatureAlgorithmExtension is the name of the constraint
+	// extension used to pass the preferred signature algorithm for an
+	// RSA key to the agent. See PROTOCOL.agent in the OpenSSH sources for
+	// the naming of extensions.
+	rsaSignatureAlgorithmExtension = "rsa-signature-algorithm@chrome-ssh-agent"
+)
+
+var (
+	// RSASignatureAlgorithms are the signature algorithms that may be
+	// selected for RSA keys. Other than ClientRSASignatureAlgorithm, they
+	// are listed from most to least preferred.
+	RSASignatureAlgorithms = []string{
+		ClientRSASignatureAlgorithm,
+		ssh.KeyAlgoRSASHA512,
+		ssh.KeyAlgoRSASHA256,
+		ssh.KeyAlgoRSA,
+	}
+
+	// rsaSignatureFlags are the flags requesting each signature algorithm
+	// when signing using an RSA key.
+	rsaSignatureFlags = map[string]agent.SignatureFlags{
+		ssh.KeyAlgoRSASHA512: agent.SignatureFlagRsaSha512,
+		ssh.KeyAlgoRSASHA256: agent.SignatureFlagRsaSha256,
+		ssh.KeyAlgoRSA:       0,
+	}
+
+	errInvalidAlgorithm = errors.New("invalid signature algorithm")
+	errNotRSA           = errors.New("not an RSA key")
+)
+
+// isRSA determines if the private key is an RSA key. This may not be
+// determined for some encrypted keys until they are loaded.
+func (s *storedKey) isRSA() bool {
+	if pub := s.PublicKey(); pub != nil {
+		return pub.Type() == ssh.KeyAlgoRSA
+	}
+	// Legacy PEM keys declare their type, even if encrypted.
+	block, _ := pem.Decode([]byte(s.PEMPrivateKey))
+	return block != nil && block.Type == "RSA PRIVATE KEY"
+}
+
+// isRSA determines if the decrypted private key is an RSA key.
+func (k decryptedKey) isRSA() bool {
+	priv, err := parseDecryptedKey(k)
+	if err != nil {
+		return false
+	}
+	_, ok := priv.(*rsa.PrivateKey)
+	return ok
+}
+
+// rsaSignatureAlgorithm returns the signature algorithm to use if the key is
+// an RSA key.
+func (s *storedKey) rsaSignatureAlgorithm() string {
+	if s.RSASignatureAlgorithm == "" {
+		return ClientRSASignatureAlgorithm
+	}
+	return s.RSASignatureAlgorithm
+}
+
+// rsaSignatureAlgorithmConstraint returns the constraint extension passing
+// the preferred signature algorithm to the agent. Agents that do not
+// recognize the extension ignore it.
+func rsaSignatureAlgorithmConstraint(alg string) agent.ConstraintExtension {
+	return agent.ConstraintExtension{
+		ExtensionName:    rsaSignatureAlgorithmExtension,
+		ExtensionDetails: []byte(alg),
+	}
+}
+
+// SetRSASignatureAlgorithm implements Manager.SetRSASignatureAlgorithm.
+func (m *DefaultManager) SetRSASignatureAlgorithm(ctx jsutil.AsyncContext, id ID, alg string) error {
+	if _, ok := rsaSignatureFlags[alg]; !ok && alg != ClientRSASignatureAlgorithm {
+		return fmt.Errorf("%w: %q", errInvalidAlgorithm, alg)
+	}
+	// The client's choice is the default, so it is stored as the absence
+	// of a selection.
+	stored := alg
+	if alg == ClientRSASignatureAlgorithm {
+		stored = ""
+	}
+
+	found := false
+	var updateErr error
+	err := m.updateStoredKeys(ctx, func(sk *storedKey) bool {
+		if ID(sk.ID) != id {
+			return false
+		}
+		found = true
+		if !sk.isRSA() {
+			updateErr = fmt.Errorf("%w: key ID %s", errNotRSA, id)
+			return false
+		}
+		sk.RSASignatureAlgorithm = stored
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update key: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("%w: failed to find key with ID %s", errKeyNotFound, id)
+	}
+	return updateErr
+}
+
+// RSASignatureAgent wraps an agent and applies the preferred signature
+// algorithm for RSA keys, supplied when the key is added using a constraint
+// extension. The algorithm is used only if the client does not request a
+// SHA-2 signature. Keys added without the constraint sign using the algorithm
+// requested by the client, including ssh-rsa (i.e., no flags).
+type RSASignatureAgent struct {
+	agent.ExtendedAgent
+
+	mu sync.Mutex
+	// flags contains the flags to apply when signing using an RSA key if
+	// the client does not request a specific signature algorithm, indexed
+	// by public key material.
+	flags map[string]agent.SignatureFlags
+}
+
+// NewRSASignatureAgent returns an RSASignatureAgent wrapping the supplied
+// agent.
+func NewRSASignatureAgent(agt agent.ExtendedAgent) *RSASignatureAgent {
+	return &RSASignatureAgent{
+		ExtendedAgent: agt,
+		flags:         map[string]agent.SignatureFlags{},
+	}
+}
+
+// Add implements agent.Agent.Add.
+func (a *RSASignatureAgent) Add(key agent.AddedKey) error {
+	signer, err := ssh.NewSignerFromKey(key.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("failed to determine public key: %w", err)
+	}
+
+	// Handle our own constraint extension, and pass the remainder on.
+	var flags *agent.SignatureFlags
+	var extensions []agent.ConstraintExtension
+	for _, ext := range key.ConstraintExtensions {
+		if ext.ExtensionName != rsaSignatureAlgorithmExtension {
+			extensions = append(extensions, ext)
+			continue
+		}
+		f, ok := rsaSignatureFlags[string(ext.ExtensionDetails)]
+		if !ok {
+			return fmt.Errorf("%w: %q", errInvalidAlgorithm, ext.ExtensionDetails)
+		}
+		flags = &f
+	}
+	key.ConstraintExtensions = extensions
+
+	if err := a.ExtendedAgent.Add(key); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// A key added with a certificate is listed and used for signing by
+	// its certificate.
+	blob := string(signer.PublicKey().Marshal())
+	if key.Certificate != nil {
+		blob = string(key.Certificate.Marshal())
+	}
+	if flags != nil {
+		a.flags[blob] = *flags
+	} else {
+		delete(a.flags, blob)
+	}
+	return nil
+}
+
+// Remove implements agent.Agent.Remove.
+func (a *RSASignatureAgent) Remove(key ssh.PublicKey) error {
+	if err := a.ExtendedAgent.Remove(key); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.flags, string(key.Marshal()))
+	return nil
+}
+
+// RemoveAll implements agent.Agent.RemoveAll.
+func (a *RSASignatureAgent) RemoveAll() error {
+	if err := a.ExtendedAgent.RemoveAll(); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.flags = map[string]agent.SignatureFlags{}
+	return nil
+}
+
+// Sign implements agent.Agent.Sign.
+func (a *RSASignatureAgent) Sign(key ssh.PublicKey, data []byte) (*ssh.Signature, error) {
+	return a.SignWithFlags(key, data, 0)
+}
+
+// SignWithFlags implements agent.ExtendedAgent.SignWithFlags.
+func (a *RSASignatureAgent) SignWithFlags(key ssh.PublicKey, data []byte, flags agent.SignatureFlags) (*ssh.Signature, error) {
+	if flags == 0 && (key.Type() == ssh.KeyAlgoRSA || key.Type() == ssh.CertAlgoRSAv01) {
+		// The client requested an ssh-rsa signature; use the
+		// preferred algorithm instead if the user selected one.
+		a.mu.Lock()
+		if f, ok := a.flags[string(key.Marshal())]; ok {
+			flags = f
+		}
+		a.mu.Unlock()
+	}
+	return a.ExtendedAgent.SignWithFlags(key, data, flags)
+}
@@ -0,0 +1,282 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestSetRSASignatureAlgorithm(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		name        string
+		id          ID
+		alg         string
+		wantAlg     map[string]string
+		wantErr     error
+	}{
+		{
+			description: "default algorithm",
+			wantAlg: map[string]string{
+				"rsa-key":       ClientRSASignatureAlgorithm,
+				"encrypted-rsa": ClientRSASignatureAlgorithm,
+				"ed25519-key":   "",
+			},
+		},
+		{
+			description: "select algorithm",
+			name:        "rsa-key",
+			alg:         ssh.KeyAlgoRSASHA256,
+			wantAlg: map[string]string{
+				"rsa-key":       ssh.KeyAlgoRSASHA256,
+				"encrypted-rsa": ClientRSASignatureAlgorithm,
+				"ed25519-key":   "",
+			},
+		},
+		{
+			description: "select algorithm for encrypted key",
+			name:        "encrypted-rsa",
+			alg:         ssh.KeyAlgoRSA,
+			wantAlg: map[string]string{
+				"rsa-key":       ClientRSASignatureAlgorithm,
+				"encrypted-rsa": ssh.KeyAlgoRSA,
+				"ed25519-key":   "",
+			},
+		},
+		{
+			description: "select client's algorithm",
+			name:        "rsa-key",
+			alg:         ClientRSASignatureAlgorithm,
+			wantAlg: map[string]string{
+				"rsa-key":       ClientRSASignatureAlgorithm,
+				"encrypted-rsa": ClientRSASignatureAlgorithm,
+				"ed25519-key":   "",
+			},
+		},
+		{
+			description: "reject invalid algorithm",
+			name:        "rsa-key",
+			alg:         "rsa-sha2-1024",
+			wantAlg: map[string]string{
+				"rsa-key":       ClientRSASignatureAlgorithm,
+				"encrypted-rsa": ClientRSASignatureAlgorithm,
+				"ed25519-key":   "",
+			},
+			wantErr: errInvalidAlgorithm,
+		},
+		{
+			description: "reject non-RSA key",
+			name:        "ed25519-key",
+			alg:         ssh.KeyAlgoRSASHA256,
+			wantAlg: map[string]string{
+				"rsa-key":       ClientRSASignatureAlgorithm,
+				"encrypted-rsa": ClientRSASignatureAlgorithm,
+				"ed25519-key":   "",
+			},
+			wantErr: errNotRSA,
+		},
+		{
+			description: "reject invalid ID",
+			id:          ID("bogus-id"),
+			alg:         ssh.KeyAlgoRSASHA256,
+			wantAlg: map[string]string{
+				"rsa-key":       ClientRSASignatureAlgorithm,
+				"encrypted-rsa": ClientRSASignatureAlgorithm,
+				"ed25519-key":   "",
+			},
+			wantErr: errKeyNotFound,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				syncStorage := storage.NewRaw(st.NewMemArea())
+				sessionStorage := storage.NewRaw(st.NewMemArea())
+				mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, []*initialKey{
+					{
+						Name:          "rsa-key",
+						PEMPrivateKey: testdata.WithoutPassphrase.Private,
+					},
+					{
+						Name:          "encrypted-rsa",
+						PEMPrivateKey: testdata.WithPassphrase.Private,
+					},
+					{
+						Name:          "ed25519-key",
+						PEMPrivateKey: testdata.ED25519WithoutPassphrase.Private,
+					},
+				})
+				if err != nil {
+					t.Fatalf("failed to initialize manager: %v", err)
+				}
+
+				if tc.name != "" || tc.id != InvalidID {
+					id := tc.id
+					if id == InvalidID {
+						id, err = findKey(ctx, mgr, InvalidID, tc.name)
+						if err != nil {
+							t.Fatalf("failed to find key: %v", err)
+						}
+					}
+					err = mgr.SetRSASignatureAlgorithm(ctx, id, tc.alg)
+					if diff := cmp.Diff(err, tc.wantErr, cmpopts.EquateErrors()); diff != "" {
+						t.Errorf("incorrect error; -got +want: %s", diff)
+					}
+				}
+
+				configured, err := mgr.Configured(ctx)
+				if err != nil {
+					t.Fatalf("failed to get configured keys: %v", err)
+				}
+				got := map[string]string{}
+				for _, k := range configured {
+					got[k.Name] = k.RSASignatureAlgorithm
+				}
+				if diff := cmp.Diff(got, tc.wantAlg); diff != "" {
+					t.Errorf("incorrect algorithms; -got +want: %s", diff)
+				}
+			})
+		})
+	}
+}
+
+func TestRSASignatureAlgorithm(t *testing.T) {
+	t.Parallel()
+
+	blob, err := base64.StdEncoding.DecodeString(testdata.WithoutPassphrase.Blob)
+	if err != nil {
+		t.Fatalf("failed to decode blob: %v", err)
+	}
+	pub, err := ssh.ParsePublicKey(blob)
+	if err != nil {
+		t.Fatalf("failed to parse public key: %v", err)
+	}
+	data := []byte("some data to sign")
+
+	testcases := []struct {
+		description string
+		alg         string
+		flags       agent.SignatureFlags
+		wantFormat  string
+	}{
+		{
+			description: "default honors requested ssh-rsa",
+			wantFormat:  ssh.KeyAlgoRSA,
+		},
+		{
+			description: "default honors requested sha2",
+			flags:       agent.SignatureFlagRsaSha512,
+			wantFormat:  ssh.KeyAlgoRSASHA512,
+		},
+		{
+			description: "client's algorithm selected",
+			alg:         ClientRSASignatureAlgorithm,
+			wantFormat:  ssh.KeyAlgoRSA,
+		},
+		{
+			description: "selected algorithm replaces ssh-rsa",
+			alg:         ssh.KeyAlgoRSASHA512,
+			wantFormat:  ssh.KeyAlgoRSASHA512,
+		},
+		{
+			description: "selected algorithm",
+			alg:         ssh.KeyAlgoRSASHA256,
+			wantFormat:  ssh.KeyAlgoRSASHA256,
+		},
+		{
+			description: "legacy algorithm",
+			alg:         ssh.KeyAlgoRSA,
+			wantFormat:  ssh.KeyAlgoRSA,
+		},
+		{
+			description: "requested algorithm takes precedence",
+			alg:         ssh.KeyAlgoRSA,
+			flags:       agent.SignatureFlagRsaSha256,
+			wantFormat:  ssh.KeyAlgoRSASHA256,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				syncStorage := storage.NewRaw(st.NewMemArea())
+				sessionStorage := storage.NewRaw(st.NewMemArea())
+				agt := NewRSASignatureAgent(agent.NewKeyring().(agent.ExtendedAgent))
+				mgr, err := newTestManager(ctx, agt, syncStorage, sessionStorage, []*initialKey{
+					{
+						Name:          "rsa-key",
+						PEMPrivateKey: testdata.WithoutPassphrase.Private,
+					},
+				})
+				if err != nil {
+					t.Fatalf("failed to initialize manager: %v", err)
+				}
+				id, err := findKey(ctx, mgr, InvalidID, "rsa-key")
+				if err != nil {
+					t.Fatalf("failed to find key: %v", err)
+				}
+				if tc.alg != "" {
+					if err := mgr.SetRSASignatureAlgorithm(ctx, id, tc.alg); err != nil {
+						t.Fatalf("failed to set algorithm: %v", err)
+					}
+				}
+				if err := mgr.Load(ctx, id, "", LoadOptions{}); err != nil {
+					t.Fatalf("failed to load key: %v", err)
+				}
+
+				// The algorithm also applies to keys restored from
+				// the session.
+				restored := NewRSASignatureAgent(agent.NewKeyring().(agent.ExtendedAgent))
+				if err := NewManager(restored, syncStorage, storage.NewRaw(st.NewMemArea()), sessionStorage).LoadFromSession(ctx); err != nil {
+					t.Fatalf("failed to load keys from session: %v", err)
+				}
+
+				for _, a := range []*RSASignatureAgent{agt, restored} {
+					sig, err := a.SignWithFlags(pub, data, tc.flags)
+					if err != nil {
+						t.Errorf("failed to sign: %v", err)
+						continue
+					}
+					if diff := cmp.Diff(sig.Format, tc.wantFormat); diff != "" {
+						t.Errorf("incorrect signature format; -got +want: %s", diff)
+					}
+					if err := pub.Verify(data, sig); err != nil {
+						t.Errorf("failed to verify signature: %v", err)
+					}
+				}
+			})
+		})
+	}
+}
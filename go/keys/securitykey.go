@@ -0,0 +1,89 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"bytes"
+	"encoding/pem"
+	"errors"
+
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	// errSecurityKey is returned when loading a key backed by a hardware
+	// security key (e.g., a FIDO authenticator such as a YubiKey). The
+	// private key file holds only a handle to the key on the device, and
+	// signing requires the device to be accessed for the 'ssh:'
+	// application, which browsers do not permit via WebAuthn.
+	errSecurityKey = errors.New("security key (FIDO) keys require the hardware authenticator, which cannot be used from the browser")
+)
+
+// isSecurityKeyType indicates whether keys of the specified type are backed
+// by a hardware security key.
+func isSecurityKeyType(keyType string) bool {
+	switch keyType {
+	case ssh.KeyAlgoSKED25519, ssh.KeyAlgoSKECDSA256:
+		return true
+	default:
+		return false
+	}
+}
+
+// opensshPublicKey returns the public key from the decoded contents of an
+// OpenSSH private key. The public key is never encrypted, so it is available
+// even for key types that cannot be parsed. See PROTOCOL.key in the OpenSSH
+// sources.
+func opensshPublicKey(data []byte) ssh.PublicKey {
+	if !bytes.HasPrefix(data, []byte(opensshMagic)) {
+		return nil
+	}
+
+	var envelope struct {
+		CipherName string
+		KdfName    string
+		KdfOpts    string
+		NumKeys    uint32
+		PubKey     []byte
+		Rest       []byte `ssh:"rest"`
+	}
+	if err := ssh.Unmarshal(data[len(opensshMagic):], &envelope); err != nil {
+		return nil
+	}
+	if envelope.NumKeys != 1 {
+		return nil
+	}
+	pub, err := ssh.ParsePublicKey(envelope.PubKey)
+	if err != nil {
+		return nil
+	}
+	return pub
+}
+
+// securityKey returns the public key if the private key is backed by a
+// hardware security key, or nil otherwise.
+func (s *storedKey) securityKey() ssh.PublicKey {
+	block, _ := pem.Decode([]byte(s.PEMPrivateKey))
+	if block == nil || block.Type != "OPENSSH PRIVATE KEY" {
+		return nil
+	}
+	pub := opensshPublicKey(block.Bytes)
+	if pub == nil || !isSecurityKeyType(pub.Type()) {
+		return nil
+	}
+	return pub
+}
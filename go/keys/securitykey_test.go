@@ -0,0 +1,92 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestSecurityKey(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		syncStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, []*initialKey{
+			{
+				Name:          "sk-key",
+				PEMPrivateKey: testdata.SecurityKeyED25519.Private,
+			},
+			{
+				Name:          "regular-key",
+				PEMPrivateKey: testdata.WithoutPassphrase.Private,
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+
+		configured, err := mgr.Configured(ctx)
+		if err != nil {
+			t.Fatalf("failed to enumerate configured keys: %v", err)
+		}
+		for _, k := range configured {
+			wantSecurityKey := k.Name == "sk-key"
+			if k.SecurityKey != wantSecurityKey {
+				t.Errorf("key %s: SecurityKey = %t; want %t", k.Name, k.SecurityKey, wantSecurityKey)
+			}
+			if (k.Unsupported != "") != wantSecurityKey {
+				t.Errorf("key %s: incorrect Unsupported %q", k.Name, k.Unsupported)
+			}
+			if !wantSecurityKey {
+				continue
+			}
+			if diff := cmp.Diff(k.Comment, "security-key"); diff != "" {
+				t.Errorf("incorrect comment; -got +want: %s", diff)
+			}
+			if diff := cmp.Diff(k.Algorithm, "Ed25519-SK"); diff != "" {
+				t.Errorf("incorrect algorithm; -got +want: %s", diff)
+			}
+			if diff := cmp.Diff(k.Bits, 256); diff != "" {
+				t.Errorf("incorrect bits; -got +want: %s", diff)
+			}
+		}
+
+		// The key cannot be loaded, since the hardware authenticator is
+		// required to sign.
+		id, err := findKey(ctx, mgr, InvalidID, "sk-key")
+		if err != nil {
+			t.Fatalf("failed to find key: %v", err)
+		}
+		if err := mgr.Load(ctx, id, "", LoadOptions{}); !errors.Is(err, errSecurityKey) {
+			t.Errorf("Load() returned %v; want %v", err, errSecurityKey)
+		}
+
+		// Duplicates are detected using the public key.
+		err = mgr.Add(ctx, "sk-key-copy", testdata.SecurityKeyED25519.Private, AddOptions{})
+		if !errors.Is(err, ErrDuplicateKey) {
+			t.Errorf("Add() of duplicate returned %v; want %v", err, ErrDuplicateKey)
+		}
+	})
+}
@@ -0,0 +1,64 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+)
+
+// normalizeTags returns the tags with surrounding whitespace removed, sorted
+// for display. Empty and duplicate tags are dropped.
+func normalizeTags(tags []string) []string {
+	var result []string
+	for _, t := range tags {
+		t = strings.TrimSpace(t)
+		if t == "" || slices.Contains(result, t) {
+			continue
+		}
+		result = append(result, t)
+	}
+	slices.SortStableFunc(result, CompareNames)
+	return result
+}
+
+// SetTags implements Manager.SetTags.
+func (m *DefaultManager) SetTags(ctx jsutil.AsyncContext, id ID, tags []string) error {
+	tags = normalizeTags(tags)
+	found := false
+	err := m.updateStoredKeys(ctx, func(sk *storedKey) bool {
+		if ID(sk.ID) != id {
+			return false
+		}
+		found = true
+		if slices.Equal(sk.Tags, tags) {
+			return false
+		}
+		sk.Tags = tags
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update key: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("%w: failed to find key with ID %s", errKeyNotFound, id)
+	}
+	return nil
+}
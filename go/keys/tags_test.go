@@ -0,0 +1,116 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	t.Parallel()
+
+	got := normalizeTags([]string{" work ", "", "personal", "work", "Work"})
+	if diff := cmp.Diff(got, []string{"personal", "work", "Work"}); diff != "" {
+		t.Errorf("incorrect tags; -got +want: %s", diff)
+	}
+}
+
+func TestSetTags(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		byName      string
+		byID        ID
+		tags        []string
+		want        []string
+		wantErr     error
+	}{
+		{
+			description: "tag key",
+			byName:      "good-key",
+			tags:        []string{"work"},
+			want:        []string{"work"},
+		},
+		{
+			description: "multiple tags",
+			byName:      "good-key",
+			tags:        []string{"work", " personal ", "", "work"},
+			want:        []string{"personal", "work"},
+		},
+		{
+			description: "clear tags",
+			byName:      "good-key",
+		},
+		{
+			description: "invalid key",
+			byID:        ID("bogus-id"),
+			tags:        []string{"work"},
+			wantErr:     errKeyNotFound,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				syncStorage := storage.NewRaw(st.NewMemArea())
+				sessionStorage := storage.NewRaw(st.NewMemArea())
+				mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, []*initialKey{
+					{
+						Name:          "good-key",
+						PEMPrivateKey: testdata.WithoutPassphrase.Private,
+						AddOptions:    AddOptions{Tags: []string{"other"}},
+					},
+				})
+				if err != nil {
+					t.Fatalf("failed to initialize manager: %v", err)
+				}
+
+				id, err := findKey(ctx, mgr, tc.byID, tc.byName)
+				if err != nil {
+					t.Fatalf("failed to find key: %v", err)
+				}
+
+				err = mgr.SetTags(ctx, id, tc.tags)
+				if diff := cmp.Diff(err, tc.wantErr, cmpopts.EquateErrors()); diff != "" {
+					t.Errorf("incorrect error; -got +want: %s", diff)
+				}
+				if tc.wantErr != nil {
+					return
+				}
+
+				configured, err := mgr.Configured(ctx)
+				if err != nil {
+					t.Fatalf("failed to enumerate configured keys: %v", err)
+				}
+				if diff := cmp.Diff(configured[0].Tags, tc.want, cmpopts.EquateEmpty()); diff != "" {
+					t.Errorf("incorrect tags; -got +want: %s", diff)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,58 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	errSignatureInvalid = errors.New("signature does not match public key")
+)
+
+const (
+	// testSignNonceBytes is the size of the random data signed by
+	// TestSign.
+	testSignNonceBytes = 32
+)
+
+// TestSign implements Manager.TestSign.
+func (m *DefaultManager) TestSign(ctx jsutil.AsyncContext, key *LoadedKey) error {
+	pub, err := ssh.ParsePublicKey(key.Blob())
+	if err != nil {
+		return fmt.Errorf("failed to parse public key: %w", err)
+	}
+
+	nonce := make([]byte, testSignNonceBytes)
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sig, err := m.agent.Sign(pub, nonce)
+	if err != nil {
+		return fmt.Errorf("failed to sign: %w", err)
+	}
+	if err := pub.Verify(nonce, sig); err != nil {
+		return fmt.Errorf("%w: %v", errSignatureInvalid, err)
+	}
+	return nil
+}
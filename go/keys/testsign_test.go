@@ -0,0 +1,119 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// corruptAgent wraps an agent, and corrupts any signature it returns.
+type corruptAgent struct {
+	agent.ExtendedAgent
+}
+
+func (a *corruptAgent) Sign(key ssh.PublicKey, data []byte) (*ssh.Signature, error) {
+	sig, err := a.ExtendedAgent.Sign(key, data)
+	if err != nil {
+		return nil, err
+	}
+	sig.Blob[0] ^= 0xff
+	return sig, nil
+}
+
+func TestTestSign(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description      string
+		agent            func() agent.ExtendedAgent
+		confirmBeforeUse bool
+		unload           bool
+		wantErr          error
+	}{
+		{
+			description: "valid signature",
+			agent:       func() agent.ExtendedAgent { return agent.NewKeyring().(agent.ExtendedAgent) },
+		},
+		{
+			description: "invalid signature",
+			agent: func() agent.ExtendedAgent {
+				return &corruptAgent{agent.NewKeyring().(agent.ExtendedAgent)}
+			},
+			wantErr: errSignatureInvalid,
+		},
+		{
+			description: "confirmation denied",
+			agent: func() agent.ExtendedAgent {
+				return NewConfirmAgent(agent.NewKeyring().(agent.ExtendedAgent))
+			},
+			confirmBeforeUse: true,
+			wantErr:          errSignDenied,
+		},
+		{
+			description: "key not loaded",
+			agent:       func() agent.ExtendedAgent { return agent.NewKeyring().(agent.ExtendedAgent) },
+			unload:      true,
+			wantErr:     cmpopts.AnyError,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				syncStorage := storage.NewRaw(st.NewMemArea())
+				sessionStorage := storage.NewRaw(st.NewMemArea())
+				mgr, err := newTestManager(ctx, tc.agent(), syncStorage, sessionStorage, []*initialKey{
+					{
+						Name:          "some-key",
+						PEMPrivateKey: testdata.WithoutPassphrase.Private,
+						Load:          true,
+						AddOptions:    AddOptions{ConfirmBeforeUse: tc.confirmBeforeUse},
+					},
+				})
+				if err != nil {
+					t.Fatalf("failed to initialize manager: %v", err)
+				}
+
+				loaded, err := mgr.Loaded(ctx)
+				if err != nil {
+					t.Fatalf("failed to get loaded keys: %v", err)
+				}
+				if tc.unload {
+					if err := mgr.UnloadAll(ctx); err != nil {
+						t.Fatalf("failed to unload keys: %v", err)
+					}
+				}
+
+				err = mgr.TestSign(ctx, loaded[0])
+				if diff := cmp.Diff(err, tc.wantErr, cmpopts.EquateErrors()); diff != "" {
+					t.Errorf("incorrect error; -got +want: %s", diff)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,131 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"fmt"
+	"syscall/js"
+	"time"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+var (
+	// usagePrefixes are the prefixes for the times at which configured
+	// keys were last used, indexed by ID. Usage is recorded on every
+	// signature, which would quickly exhaust the write quota for synced
+	// storage, so it is kept in local storage.
+	usagePrefixes = []string{"keyUsage"}
+)
+
+// lastUsed returns the times (in seconds since the Unix epoch) at which
+// configured keys were last used, indexed by ID.
+func (m *DefaultManager) lastUsed(ctx jsutil.AsyncContext) (map[ID]int64, error) {
+	data, err := m.usage.Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key usage: %w", err)
+	}
+
+	result := make(map[ID]int64)
+	for id, val := range data {
+		if val.Type() != js.TypeNumber {
+			jsutil.LogError("ignoring invalid usage for key %s: has type %s", id, val.Type())
+			continue
+		}
+		result[ID(id)] = int64(val.Float())
+	}
+	return result, nil
+}
+
+// MarkUsed implements Manager.MarkUsed.
+func (m *DefaultManager) MarkUsed(ctx jsutil.AsyncContext, id ID) error {
+	sk, err := m.readStoredKey(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to read keys: %w", err)
+	}
+	if sk == nil {
+		return fmt.Errorf("%w: failed to find key with ID %s", errKeyNotFound, id)
+	}
+
+	data := map[string]js.Value{
+		string(id): js.ValueOf(float64(time.Now().Unix())),
+	}
+	if err := m.usage.Set(ctx, data); err != nil {
+		return fmt.Errorf("failed to write key usage: %w", err)
+	}
+	return nil
+}
+
+// UsedFunc is invoked after a key is used to sign data.
+//
+// UsedFunc is invoked on the goroutine serving the agent request, and must
+// not block.
+type UsedFunc func(key *LoadedKey)
+
+// UsageAgent wraps an agent and reports each key used to sign data.
+type UsageAgent struct {
+	agent.ExtendedAgent
+
+	onUsed UsedFunc
+}
+
+// NewUsageAgent returns a UsageAgent wrapping the supplied agent.
+func NewUsageAgent(agt agent.ExtendedAgent, onUsed UsedFunc) *UsageAgent {
+	return &UsageAgent{
+		ExtendedAgent: agt,
+		onUsed:        onUsed,
+	}
+}
+
+// used reports that the key was used to sign data.
+func (a *UsageAgent) used(key ssh.PublicKey) {
+	a.onUsed(lookupLoaded(a.ExtendedAgent, key))
+}
+
+// Sign implements agent.Agent.Sign.
+func (a *UsageAgent) Sign(key ssh.PublicKey, data []byte) (*ssh.Signature, error) {
+	sig, err := a.ExtendedAgent.Sign(key, data)
+	if err == nil {
+		a.used(key)
+	}
+	return sig, err
+}
+
+// SignWithFlags implements agent.ExtendedAgent.SignWithFlags.
+func (a *UsageAgent) SignWithFlags(key ssh.PublicKey, data []byte, flags agent.SignatureFlags) (*ssh.Signature, error) {
+	sig, err := a.ExtendedAgent.SignWithFlags(key, data, flags)
+	if err == nil {
+		a.used(key)
+	}
+	return sig, err
+}
+
+// KeyUsed records that the key was used to sign data, and unloads it if it
+// was loaded to be used only once. Keys that were not loaded from a
+// configured key are ignored. It is intended to be invoked from a UsedFunc.
+func (s *Server) KeyUsed(ctx jsutil.AsyncContext, key *LoadedKey) {
+	id := key.ID()
+	if id == InvalidID {
+		return
+	}
+	if err := s.mgr.MarkUsed(ctx, id); err != nil {
+		jsutil.LogError("Server.KeyUsed: failed to record use of key %s: %v", id, err)
+	}
+	s.unloadOneShot(ctx, id)
+}
@@ -0,0 +1,209 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/chrome-ssh-agent/go/storage"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestUsageAgent(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		var used []*LoadedKey
+		agt := NewUsageAgent(agent.NewKeyring().(agent.ExtendedAgent), func(key *LoadedKey) {
+			used = append(used, key)
+		})
+
+		syncStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		_, err := newTestManager(ctx, agt, syncStorage, sessionStorage, []*initialKey{
+			{
+				Name:          "some-key",
+				PEMPrivateKey: testdata.WithoutPassphrase.Private,
+				Load:          true,
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+
+		loaded, err := agt.List()
+		if err != nil {
+			t.Fatalf("failed to list keys: %v", err)
+		}
+		if _, err := agt.Sign(loaded[0], []byte("some-data")); err != nil {
+			t.Errorf("failed to sign: %v", err)
+		}
+		if _, err := agt.SignWithFlags(loaded[0], []byte("some-data"), 0); err != nil {
+			t.Errorf("failed to sign: %v", err)
+		}
+
+		// Signing with a key that is not loaded is not reported.
+		if err := agt.RemoveAll(); err != nil {
+			t.Fatalf("failed to unload keys: %v", err)
+		}
+		if _, err := agt.Sign(loaded[0], []byte("some-data")); err == nil {
+			t.Errorf("signing with unloaded key unexpectedly succeeded")
+		}
+
+		var got []string
+		for _, k := range used {
+			got = append(got, k.Comment)
+		}
+		want := []string{loaded[0].Comment, loaded[0].Comment}
+		if diff := cmp.Diff(got, want); diff != "" {
+			t.Errorf("incorrect used keys; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestMarkUsed(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		initial     []*initialKey
+		byName      string
+		byID        ID
+		wantUsed    []string
+		wantErr     error
+	}{
+		{
+			description: "mark key used",
+			initial: []*initialKey{
+				{
+					Name:          "used-key",
+					PEMPrivateKey: testdata.WithoutPassphrase.Private,
+				},
+				{
+					Name:          "unused-key",
+					PEMPrivateKey: testdata.ECDSAWithoutPassphrase.Private,
+				},
+			},
+			byName:   "used-key",
+			wantUsed: []string{"used-key"},
+		},
+		{
+			description: "fail on invalid ID",
+			initial: []*initialKey{
+				{
+					Name:          "some-key",
+					PEMPrivateKey: testdata.WithoutPassphrase.Private,
+				},
+			},
+			byID:    ID("bogus-id"),
+			wantErr: errKeyNotFound,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				syncStorage := storage.NewRaw(st.NewMemArea())
+				sessionStorage := storage.NewRaw(st.NewMemArea())
+				mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, tc.initial)
+				if err != nil {
+					t.Fatalf("failed to initialize manager: %v", err)
+				}
+
+				id, err := findKey(ctx, mgr, tc.byID, tc.byName)
+				if err != nil {
+					t.Fatalf("failed to find key: %v", err)
+				}
+
+				before := time.Now().Unix()
+				err = mgr.MarkUsed(ctx, id)
+				if diff := cmp.Diff(err, tc.wantErr, cmpopts.EquateErrors()); diff != "" {
+					t.Errorf("incorrect error; -got +want: %s", diff)
+				}
+
+				configured, err := mgr.Configured(ctx)
+				if err != nil {
+					t.Fatalf("failed to get configured keys: %v", err)
+				}
+				var gotUsed []string
+				for _, k := range configured {
+					if k.LastUsed == 0 {
+						continue
+					}
+					gotUsed = append(gotUsed, k.Name)
+					if k.LastUsed < before || k.LastUsed > time.Now().Unix() {
+						t.Errorf("incorrect last used time for %s: got %d, want at least %d", k.Name, k.LastUsed, before)
+					}
+				}
+				if diff := cmp.Diff(gotUsed, tc.wantUsed); diff != "" {
+					t.Errorf("incorrect used keys; -got +want: %s", diff)
+				}
+			})
+		})
+	}
+}
+
+func TestKeyUsed(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		syncStorage := storage.NewRaw(st.NewMemArea())
+		sessionStorage := storage.NewRaw(st.NewMemArea())
+		mgr, err := newTestManager(ctx, agent.NewKeyring(), syncStorage, sessionStorage, []*initialKey{
+			{
+				Name:          "some-key",
+				PEMPrivateKey: testdata.WithoutPassphrase.Private,
+				Load:          true,
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to initialize manager: %v", err)
+		}
+		srv := NewServer(mgr)
+
+		// Keys not loaded from a configured key are ignored.
+		srv.KeyUsed(ctx, &LoadedKey{Comment: "external-key"})
+		configured, err := mgr.Configured(ctx)
+		if err != nil {
+			t.Fatalf("failed to get configured keys: %v", err)
+		}
+		if diff := cmp.Diff(configured[0].LastUsed, int64(0)); diff != "" {
+			t.Errorf("incorrect last used time; -got +want: %s", diff)
+		}
+
+		loaded, err := mgr.Loaded(ctx)
+		if err != nil {
+			t.Fatalf("failed to get loaded keys: %v", err)
+		}
+		srv.KeyUsed(ctx, loaded[0])
+		configured, err = mgr.Configured(ctx)
+		if err != nil {
+			t.Fatalf("failed to get configured keys: %v", err)
+		}
+		if configured[0].LastUsed == 0 {
+			t.Errorf("last used time not recorded")
+		}
+	})
+}
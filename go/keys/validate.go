@@ -0,0 +1,63 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/google/chrome-ssh-agent/go/keys/ppk"
+)
+
+var (
+	errNoName          = errors.New("a name is required for a key without an embedded comment")
+	errUnrecognizedKey = categorize(errors.New("private key must be a PEM block or PuTTY .ppk file"), ErrUnsupportedFormat)
+)
+
+// normalizeLineEndings converts Windows (CRLF) line endings in a pasted key
+// to Unix (LF) line endings, which some key formats (e.g., PuTTY .ppk files)
+// require to be parsed.
+func normalizeLineEndings(privateKey string) string {
+	return strings.ReplaceAll(privateKey, "\r\n", "\n")
+}
+
+// ValidateNew checks that a key about to be configured appears valid,
+// without fully parsing it. The private key must have a recognizable PEM or
+// PuTTY .ppk header. The name may be empty only if the private key embeds a
+// comment, which is used as the name instead. The name is also optional for
+// a bundle of several keys, since names are then generated (see BundleKeys).
+//
+// ValidateNew is intended to provide early feedback to the user; keys that
+// pass may still fail to load.
+func ValidateNew(name, privateKey string) error {
+	privateKey = normalizeLineEndings(privateKey)
+	trimmed := strings.TrimSpace(privateKey)
+	if trimmed == "" {
+		return errNoKey
+	}
+	if !strings.HasPrefix(trimmed, "-----BEGIN ") && !ppk.IsPPK([]byte(trimmed)) {
+		return errUnrecognizedKey
+	}
+
+	if strings.TrimSpace(name) == "" && !IsBundle(privateKey) {
+		sk := &storedKey{PEMPrivateKey: privateKey}
+		if sk.Comment() == "" {
+			return errNoName
+		}
+	}
+	return nil
+}
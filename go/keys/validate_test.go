@@ -0,0 +1,108 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestValidateNew(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		name        string
+		privateKey  string
+		wantErr     error
+	}{
+		{
+			description: "pem key",
+			name:        "some-key",
+			privateKey:  testdata.WithoutPassphrase.Private,
+		},
+		{
+			description: "encrypted pem key",
+			name:        "some-key",
+			privateKey:  testdata.WithPassphrase.Private,
+		},
+		{
+			description: "openssh key",
+			name:        "some-key",
+			privateKey:  testdata.OpenSSHFormat.Private,
+		},
+		{
+			description: "ppk key",
+			name:        "some-key",
+			privateKey:  testdata.PPKv3WithPassphrase.Private,
+		},
+		{
+			description: "surrounding whitespace",
+			name:        "some-key",
+			privateKey:  "\n  " + testdata.WithoutPassphrase.Private + "\n",
+		},
+		{
+			description: "no name with openssh comment",
+			privateKey:  testdata.ED25519WithoutPassphrase.Private,
+		},
+		{
+			description: "no name with ppk comment",
+			name:        "  ",
+			privateKey:  testdata.PPKv3WithPassphrase.Private,
+		},
+		{
+			description: "no name with ppk comment and windows line endings",
+			privateKey:  strings.ReplaceAll(testdata.PPKv3WithPassphrase.Private, "\n", "\r\n"),
+		},
+		{
+			description: "no name without comment",
+			privateKey:  testdata.WithoutPassphrase.Private,
+			wantErr:     errNoName,
+		},
+		{
+			description: "no name with encrypted openssh key",
+			privateKey:  testdata.ED25519WithPassphrase.Private,
+			wantErr:     errNoName,
+		},
+		{
+			description: "no key",
+			name:        "some-key",
+			privateKey:  " \n",
+			wantErr:     errNoKey,
+		},
+		{
+			description: "unrecognized key",
+			name:        "some-key",
+			privateKey:  "bogus-key-data",
+			wantErr:     errUnrecognizedKey,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			err := ValidateNew(tc.name, tc.privateKey)
+			if diff := cmp.Diff(err, tc.wantErr, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("incorrect error; -got +want: %s", diff)
+			}
+		})
+	}
+}
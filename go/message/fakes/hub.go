@@ -18,7 +18,9 @@ package fakes
 
 import (
 	"errors"
+	"sync"
 	"syscall/js"
+	"time"
 
 	"github.com/google/chrome-ssh-agent/go/jsutil"
 )
@@ -32,11 +34,66 @@ type Receiver interface {
 // Hub is a fake implementation of Chrome's messaging APIs.
 type Hub struct {
 	receivers []Receiver
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	// mu guards fields below.
+	mu sync.Mutex
+	// drop is the number of subsequent messages to drop.
+	drop int
+	// delay is the time by which delivery of each message is delayed.
+	delay time.Duration
+	// hold is the number of subsequent messages whose response is held.
+	hold int
+	// released is closed to release held responses.
+	released chan struct{}
 }
 
 // NewHub returns a fake implementation of Chrome's messaging APIs.
 func NewHub() *Hub {
-	return &Hub{}
+	return &Hub{
+		closed:   make(chan struct{}),
+		released: make(chan struct{}),
+	}
+}
+
+// DropNext causes the next n messages to be dropped, simulating messages
+// lost while a background worker is suspended. Dropped messages are never
+// delivered, and Send does not return for them until the hub is closed.
+func (m *Hub) DropNext(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.drop = n
+}
+
+// SetDelay delays delivery of each subsequent message by d, simulating a
+// slow receiver.
+func (m *Hub) SetDelay(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.delay = d
+}
+
+// HoldNext causes the responses to the next n messages to be held,
+// simulating a request that is never answered. Held messages are delivered,
+// but Send does not return for them until Release is invoked.
+func (m *Hub) HoldNext(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hold = n
+}
+
+// Release returns the responses to all messages currently held.
+func (m *Hub) Release() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	close(m.released)
+	m.released = make(chan struct{})
+}
+
+// Close releases any Send calls blocked on dropped messages.
+func (m *Hub) Close() {
+	m.closeOnce.Do(func() { close(m.closed) })
 }
 
 // AddReceiver adds a receiver to which messages should be delivered.
@@ -46,9 +103,31 @@ func (m *Hub) AddReceiver(r Receiver) {
 
 // Send implements Sender.Send().
 func (m *Hub) Send(ctx jsutil.AsyncContext, msg js.Value) (js.Value, error) {
+	m.mu.Lock()
+	dropped := m.drop > 0
+	if dropped {
+		m.drop--
+	}
+	delay := m.delay
+	var released chan struct{}
+	if m.hold > 0 {
+		m.hold--
+		released = m.released
+	}
+	m.mu.Unlock()
+
+	if dropped {
+		<-m.closed
+		return js.Undefined(), errors.New("hub closed")
+	}
+	time.Sleep(delay)
+
 	for _, r := range m.receivers {
 		rsp := r.OnMessage(ctx, msg, js.Null())
 		if !rsp.IsUndefined() {
+			if released != nil {
+				<-released
+			}
 			return rsp, nil
 		}
 	}
@@ -17,6 +17,7 @@ package fakes
 import (
 	"syscall/js"
 	"testing"
+	"time"
 
 	"github.com/google/chrome-ssh-agent/go/jsutil"
 	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
@@ -90,3 +91,105 @@ func TestMessagePassing(t *testing.T) {
 		t.Errorf("incorrect response for map; -got +want: %s", diff)
 	}
 }
+
+func TestDropNext(t *testing.T) {
+	t.Parallel()
+
+	hub := NewHub()
+	hub.AddReceiver(&intReceiver{})
+	hub.DropNext(1)
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		// The first message is dropped; no response is received until
+		// the hub is closed.
+		dropped := make(chan error, 1)
+		go func() {
+			_, err := hub.Send(ctx, js.ValueOf(42))
+			dropped <- err
+		}()
+		select {
+		case err := <-dropped:
+			t.Errorf("dropped message returned early: %v", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		// Subsequent messages are delivered.
+		rsp, err := hub.Send(ctx, js.ValueOf(42))
+		if err != nil {
+			t.Errorf("SendMessage failed: %v", err)
+		} else if diff := cmp.Diff(rsp.String(), "int"); diff != "" {
+			t.Errorf("incorrect response; -got +want: %s", diff)
+		}
+
+		hub.Close()
+		if err := <-dropped; err == nil {
+			t.Errorf("dropped message unexpectedly succeeded")
+		}
+	})
+}
+
+func TestSetDelay(t *testing.T) {
+	t.Parallel()
+
+	hub := NewHub()
+	defer hub.Close()
+	hub.AddReceiver(&intReceiver{})
+	hub.SetDelay(50 * time.Millisecond)
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		start := time.Now()
+		rsp, err := hub.Send(ctx, js.ValueOf(42))
+		if err != nil {
+			t.Fatalf("SendMessage failed: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+			t.Errorf("message delivered too soon; got %s, want at least 50ms", elapsed)
+		}
+		if diff := cmp.Diff(rsp.String(), "int"); diff != "" {
+			t.Errorf("incorrect response; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestHoldNext(t *testing.T) {
+	t.Parallel()
+
+	hub := NewHub()
+	defer hub.Close()
+	hub.AddReceiver(&intReceiver{})
+	hub.HoldNext(1)
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		// The response to the first message is held until released.
+		type result struct {
+			rsp js.Value
+			err error
+		}
+		held := make(chan result, 1)
+		go func() {
+			rsp, err := hub.Send(ctx, js.ValueOf(42))
+			held <- result{rsp: rsp, err: err}
+		}()
+		select {
+		case res := <-held:
+			t.Errorf("held message returned early: %v", res.err)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		// Subsequent messages are answered.
+		rsp, err := hub.Send(ctx, js.ValueOf(42))
+		if err != nil {
+			t.Errorf("SendMessage failed: %v", err)
+		} else if diff := cmp.Diff(rsp.String(), "int"); diff != "" {
+			t.Errorf("incorrect response; -got +want: %s", diff)
+		}
+
+		hub.Release()
+		res := <-held
+		if res.err != nil {
+			t.Errorf("SendMessage failed: %v", res.err)
+		} else if diff := cmp.Diff(res.rsp.String(), "int"); diff != "" {
+			t.Errorf("incorrect response; -got +want: %s", diff)
+		}
+	})
+}
@@ -20,11 +20,13 @@ import (
 	"syscall/js"
 
 	"github.com/google/chrome-ssh-agent/go/app"
+	"github.com/google/chrome-ssh-agent/go/chrome"
 	"github.com/google/chrome-ssh-agent/go/dom"
 	"github.com/google/chrome-ssh-agent/go/jsutil"
 	"github.com/google/chrome-ssh-agent/go/keys"
 	"github.com/google/chrome-ssh-agent/go/message"
 	"github.com/google/chrome-ssh-agent/go/optionsui"
+	"github.com/google/chrome-ssh-agent/go/storage"
 	"github.com/google/chrome-ssh-agent/go/testing"
 )
 
@@ -33,8 +35,13 @@ type options struct {
 	doc     *dom.Doc
 }
 
+// newManager returns a client for the manager in the background worker.
+func newManager() keys.Manager {
+	return keys.NewClient(message.NewLocalSender())
+}
+
 func newOptions() *options {
-	mgr := keys.NewClient(message.NewLocalSender())
+	mgr := newManager()
 	doc := dom.New(js.Null())
 
 	return &options{
@@ -48,12 +55,23 @@ func (a *options) Name() string {
 }
 
 func (a *options) Init(ctx jsutil.AsyncContext, cleanup *jsutil.CleanupFuncs) error {
-	ui := optionsui.New(a.manager, a.doc)
+	ui := optionsui.New(a.manager, a.doc, storage.DefaultOnChanged())
 	cleanup.Add(ui.Release)
+	ui.SetMessages(chrome.GetMessage)
+	// Offer to reconnect to the background worker, or restart the
+	// extension, if the agent stops responding.
+	ui.SetNewManager(newManager)
+	ui.SetReloadExtension(chrome.ReloadExtension)
 
 	qs := dom.NewURLSearchParams(dom.DefaultQueryString())
 	if qs.Has("test") {
-		testing.WriteResults(a.doc, ui.EndToEndTest(ctx))
+		report := ui.EndToEndReport(ctx)
+		testing.WriteResults(a.doc, report.Errors())
+		if text, err := report.JSON(); err != nil {
+			jsutil.LogError("failed to write test report: %v", err)
+		} else {
+			testing.WriteReport(a.doc, text)
+		}
 	}
 
 	return nil
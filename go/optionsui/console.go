@@ -0,0 +1,176 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package optionsui
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/chrome-ssh-agent/go/dom"
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	"github.com/google/chrome-ssh-agent/go/keys"
+	"github.com/norunners/vert"
+)
+
+// consoleCommand is a command accepted by the debug console, which invokes
+// a method on the manager.
+type consoleCommand struct {
+	// args describes the arguments to the command, for display in help.
+	args string
+	// help describes the command.
+	help string
+	// run invokes the command with the supplied arguments, returning the
+	// result to be displayed.
+	run func(ctx jsutil.AsyncContext, mgr keys.Manager, args []string) (any, error)
+}
+
+var (
+	errConsoleEmpty   = errors.New("no command entered; enter 'help' to list commands")
+	errConsoleUnknown = errors.New("unknown command; enter 'help' to list commands")
+	errConsoleArgs    = errors.New("incorrect arguments")
+)
+
+// noArgs wraps a command that takes no arguments.
+func noArgs(run func(ctx jsutil.AsyncContext, mgr keys.Manager) (any, error)) func(jsutil.AsyncContext, keys.Manager, []string) (any, error) {
+	return func(ctx jsutil.AsyncContext, mgr keys.Manager, args []string) (any, error) {
+		if len(args) != 0 {
+			return nil, errConsoleArgs
+		}
+		return run(ctx, mgr)
+	}
+}
+
+// idArg wraps a command that takes a key ID as its only argument.
+func idArg(run func(ctx jsutil.AsyncContext, mgr keys.Manager, id keys.ID) (any, error)) func(jsutil.AsyncContext, keys.Manager, []string) (any, error) {
+	return func(ctx jsutil.AsyncContext, mgr keys.Manager, args []string) (any, error) {
+		if len(args) != 1 {
+			return nil, errConsoleArgs
+		}
+		return run(ctx, mgr, keys.ID(args[0]))
+	}
+}
+
+// consoleCommands are the commands accepted by the debug console, indexed by
+// name. Keys are only loaded if they can be loaded without a passphrase, so
+// that passphrases are never typed where they are displayed.
+var consoleCommands = map[string]*consoleCommand{
+	"configured": {
+		help: "List configured keys",
+		run: noArgs(func(ctx jsutil.AsyncContext, mgr keys.Manager) (any, error) {
+			return mgr.Configured(ctx)
+		}),
+	},
+	"loaded": {
+		help: "List keys loaded in the agent",
+		run: noArgs(func(ctx jsutil.AsyncContext, mgr keys.Manager) (any, error) {
+			return mgr.Loaded(ctx)
+		}),
+	},
+	"load": {
+		args: "<id>",
+		help: "Load a key that does not require a passphrase",
+		run: idArg(func(ctx jsutil.AsyncContext, mgr keys.Manager, id keys.ID) (any, error) {
+			return nil, mgr.Load(ctx, id, "", keys.LoadOptions{})
+		}),
+	},
+	"unload": {
+		args: "<id>",
+		help: "Unload a key",
+		run: idArg(func(ctx jsutil.AsyncContext, mgr keys.Manager, id keys.ID) (any, error) {
+			return nil, mgr.Unload(ctx, id)
+		}),
+	},
+	"preferences": {
+		help: "Show preferences",
+		run: noArgs(func(ctx jsutil.AsyncContext, mgr keys.Manager) (any, error) {
+			return mgr.Preferences(ctx)
+		}),
+	},
+	"storage": {
+		help: "Show storage usage",
+		run: noArgs(func(ctx jsutil.AsyncContext, mgr keys.Manager) (any, error) {
+			return mgr.StorageUsage(ctx)
+		}),
+	},
+	"ping": {
+		help: "Check that the agent is reachable",
+		run: noArgs(func(ctx jsutil.AsyncContext, mgr keys.Manager) (any, error) {
+			return nil, mgr.Ping(ctx)
+		}),
+	},
+}
+
+// consoleHelp returns the text listing the commands accepted by the debug
+// console.
+func consoleHelp() string {
+	var names []string
+	for name := range consoleCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var lines []string
+	for _, name := range names {
+		cmd := consoleCommands[name]
+		usage := strings.TrimSpace(name + " " + cmd.args)
+		lines = append(lines, fmt.Sprintf("%-14s %s", usage, cmd.help))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// runConsoleCommand parses and runs the command entered in the debug console,
+// returning the result rendered as JSON.
+func runConsoleCommand(ctx jsutil.AsyncContext, mgr keys.Manager, line string) (string, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return "", errConsoleEmpty
+	}
+	name, args := fields[0], fields[1:]
+	if name == "help" {
+		return consoleHelp(), nil
+	}
+	cmd, ok := consoleCommands[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", errConsoleUnknown, name)
+	}
+
+	result, err := cmd.run(ctx, mgr, args)
+	if errors.Is(err, errConsoleArgs) {
+		return "", fmt.Errorf("%w; usage: %s", err, strings.TrimSpace(name+" "+cmd.args))
+	}
+	if err != nil {
+		return "", err
+	}
+	if result == nil {
+		return "ok", nil
+	}
+	return jsutil.ToJSON(vert.ValueOf(result).JSValue()), nil
+}
+
+// runConsole runs the command entered in the debug console, and displays the
+// result.
+func (u *UI) runConsole(ctx jsutil.AsyncContext, _ dom.Event) {
+	line := dom.Value(u.consoleInput)
+	dom.RemoveChildren(u.consoleOutput)
+	text, err := runConsoleCommand(ctx, u.mgr, line)
+	if err != nil {
+		text = fmt.Sprintf("Error: %v", err)
+	}
+	dom.AppendChild(u.consoleOutput, u.dom.NewText(fmt.Sprintf("> %s\n%s", line, text)), nil)
+}
@@ -0,0 +1,108 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package optionsui
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/dom"
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+)
+
+func TestRunConsoleCommand(t *testing.T) {
+	t.Parallel()
+
+	h := newHarness()
+	defer h.Release()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		h.waitLoaded(ctx)
+
+		if err := h.Client.Add(ctx, "some-key", testdata.WithoutPassphrase.Private, keys.AddOptions{}); err != nil {
+			t.Fatalf("failed to add key: %v", err)
+		}
+		h.UI.updateKeys(ctx)
+		h.waitKeyConfigured(ctx, "some-key")
+		id := findKey(h.UI.displayedKeys(), "some-key")
+
+		testcases := []struct {
+			line     string
+			contains string
+			wantErr  error
+		}{
+			{line: "", wantErr: errConsoleEmpty},
+			{line: "bogus", wantErr: errConsoleUnknown},
+			{line: "configured extra", wantErr: errConsoleArgs},
+			{line: "load", wantErr: errConsoleArgs},
+			{line: "help", contains: "load <id>"},
+			{line: "configured", contains: `"name":"some-key"`},
+			{line: "  load   " + string(id), contains: "ok"},
+			{line: "loaded", contains: string(id)},
+			{line: "ping", contains: "ok"},
+		}
+		for _, tc := range testcases {
+			got, err := runConsoleCommand(ctx, h.Client, tc.line)
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("%q: incorrect error; got %v, want %v", tc.line, err, tc.wantErr)
+			}
+			if !strings.Contains(got, tc.contains) {
+				t.Errorf("%q: incorrect result; got %q, want containing %q", tc.line, got, tc.contains)
+			}
+		}
+	})
+}
+
+func TestDebugConsole(t *testing.T) {
+	t.Parallel()
+
+	h := newHarness()
+	defer h.Release()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		h.waitLoaded(ctx)
+
+		pane := h.dom.GetElement("consolePane")
+		input := h.dom.GetElement("consoleCommand")
+		output := h.dom.GetElement("consoleOutput")
+
+		// The console is only offered once enabled in preferences.
+		if dom.IsVisible(pane) {
+			t.Errorf("debug console displayed by default")
+		}
+		dom.DoClick(h.dom.GetElement("debugConsole"))
+		mustPoll(ctx, func() bool {
+			prefs, err := h.Client.Preferences(ctx)
+			return err == nil && prefs.DebugConsole
+		})
+		mustPoll(ctx, func() bool { return dom.IsVisible(pane) })
+
+		dom.SetValue(input, "preferences")
+		dom.DoKeyDown(input, "Enter")
+		mustPoll(ctx, func() bool {
+			return strings.Contains(dom.TextContent(output), `"debugConsole":true`)
+		})
+
+		dom.SetValue(input, "bogus")
+		dom.DoClick(h.dom.GetElement("consoleRun"))
+		mustPoll(ctx, func() bool {
+			return strings.Contains(dom.TextContent(output), errConsoleUnknown.Error())
+		})
+	})
+}
@@ -0,0 +1,172 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package optionsui
+
+import (
+	"fmt"
+	"syscall/js"
+
+	"github.com/google/chrome-ssh-agent/go/dom"
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	"github.com/google/chrome-ssh-agent/go/keys"
+)
+
+// promptExportPrivate displays a dialog warning the user of the risk of
+// exporting the private key, and requiring that they type the key's name to
+// confirm. The passphrase is requested if the key is encrypted. The user may
+// also supply a new passphrase with which to encrypt the exported key.
+func (u *UI) promptExportPrivate(ctx jsutil.AsyncContext, k *displayedKey) (ok bool, passphrase, newPassphrase string) {
+	dialogElem := u.dom.GetElement("exportPrivateDialog")
+	dialog := dom.NewDialog(dialogElem)
+	form := u.dom.GetElement("exportPrivateForm")
+	name := u.dom.GetElement("exportPrivateName")
+	confirm := u.dom.GetElement("exportPrivateConfirm")
+	passphraseRow := u.dom.GetElement("exportPrivatePassphraseRow")
+	passphraseField := u.dom.GetElement("exportPrivatePassphrase")
+	newField := u.dom.GetElement("exportPrivateNew")
+	passphraseReveal := u.dom.GetElement("exportPrivatePassphraseReveal")
+	newReveal := u.dom.GetElement("exportPrivateNewReveal")
+	okButton := u.dom.GetElement("exportPrivateOk")
+	cancel := u.dom.GetElement("exportPrivateCancel")
+	dom.AppendChild(name, u.dom.NewText(k.Name), nil)
+	dom.SetVisible(passphraseRow, k.Encrypted)
+	okButton.Set("disabled", true)
+
+	// The key may only be exported once its name has been typed exactly.
+	confirmed := func() bool { return dom.Value(confirm) == k.Name }
+
+	sig := newSignal()
+	var cleanup jsutil.CleanupFuncs
+	cleanup.Add(dom.OnSubmit(form, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		if !confirmed() {
+			return
+		}
+		ok = true
+		passphrase = dom.Value(passphraseField)
+		newPassphrase = dom.Value(newField)
+		dialog.Close()
+		sig.Notify()
+	}))
+	cleanup.Add(dom.OnClick(cancel, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		dialog.Close()
+		sig.Notify()
+	}))
+	cleanup.Add(dom.OnInput(confirm, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		okButton.Set("disabled", !confirmed())
+	}))
+	for _, field := range []js.Value{confirm, passphraseField, newField} {
+		cleanup.Add(onDialogKeys(field, okButton, cancel))
+	}
+	cleanup.Add(revealToggle(passphraseField, passphraseReveal))
+	cleanup.Add(revealToggle(newField, newReveal))
+	cleanup.Add(dialog.OnClose(func(ctx jsutil.AsyncContext, evt dom.Event) {
+		dom.RemoveChildren(name)
+		dom.SetValue(confirm, "")
+		dom.SetValue(passphraseField, "")
+		dom.SetValue(newField, "")
+		okButton.Set("disabled", true)
+		cleanup.Do()
+	}))
+
+	cleanup.Add(u.showModal(dialog, dialogElem, confirm))
+	sig.Wait(ctx)
+	return
+}
+
+// showPrivateKey displays the exported private key for a key. The key is
+// masked until the user chooses to show it, and may be copied to the
+// clipboard. It is discarded when the dialog is closed.
+func (u *UI) showPrivateKey(ctx jsutil.AsyncContext, k *displayedKey, pemPrivateKey string) {
+	dialogElem := u.dom.GetElement("privateKeyDialog")
+	dialog := dom.NewDialog(dialogElem)
+	form := u.dom.GetElement("privateKeyForm")
+	name := u.dom.GetElement("privateKeyName")
+	text := u.dom.GetElement("privateKeyText")
+	reveal := u.dom.GetElement("privateKeyReveal")
+	copyButton := u.dom.GetElement("privateKeyCopy")
+	closeButton := u.dom.GetElement("privateKeyClose")
+	dom.AppendChild(name, u.dom.NewText(k.Name), nil)
+	dom.SetValue(text, pemPrivateKey)
+
+	setMasked := func(masked bool) {
+		label := "Hide"
+		if masked {
+			dom.AddClass(text, "masked")
+			label = "Show"
+		} else {
+			dom.RemoveClass(text, "masked")
+		}
+		dom.SetAttribute(reveal, "aria-pressed", fmt.Sprint(!masked))
+		dom.RemoveChildren(reveal)
+		dom.AppendChild(reveal, u.dom.NewText(label), nil)
+	}
+	masked := true
+	setMasked(masked)
+
+	sig := newSignal()
+	var cleanup jsutil.CleanupFuncs
+	cleanup.Add(dom.OnSubmit(form, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		dialog.Close()
+		sig.Notify()
+	}))
+	cleanup.Add(dom.OnClick(reveal, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		masked = !masked
+		setMasked(masked)
+	}))
+	cleanup.Add(dom.OnClick(copyButton, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		if err := u.writeClipboard(ctx, pemPrivateKey); err != nil {
+			u.setError(fmt.Errorf("failed to copy private key: %w", err))
+			return
+		}
+		u.setError(nil)
+		u.showCopied(copyButton)
+	}))
+	cleanup.Add(dialog.OnClose(func(ctx jsutil.AsyncContext, evt dom.Event) {
+		dom.RemoveChildren(name)
+		dom.SetValue(text, "")
+		setMasked(true)
+		cleanup.Do()
+	}))
+
+	cleanup.Add(u.showModal(dialog, dialogElem, closeButton))
+	sig.Wait(ctx)
+}
+
+// exportPrivate displays the private key for the key with the specified ID,
+// so that it can be backed up. A dialog first warns the user of the risk and
+// prompts for the passphrase.
+func (u *UI) exportPrivate(ctx jsutil.AsyncContext, id keys.ID) {
+	k := u.keyByID(id)
+	if k == nil {
+		u.setError(fmt.Errorf("failed to export private key for key ID %s: not found", id))
+		return
+	}
+	ok, passphrase, newPassphrase := u.promptExportPrivate(ctx, k)
+	if !ok {
+		return
+	}
+
+	pemPrivateKey, err := u.mgr.ExportPrivate(ctx, id, passphrase, newPassphrase)
+	// The export is recorded in the activity log, even if it failed.
+	u.updateActivity(ctx)
+	if err != nil {
+		u.setError(u.failed(msgFailedExportPrivate, err))
+		return
+	}
+	u.setError(nil)
+	u.showPrivateKey(ctx, k, pemPrivateKey)
+}
@@ -0,0 +1,160 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package optionsui
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Keys identifying user-facing strings. Keys are limited to ASCII letters,
+// digits and underscores, so that they may also be defined in the
+// extension's message catalogs (i.e., _locales/<locale>/messages.json).
+const (
+	msgAdopt            = "buttonAdopt"
+	msgAllowedSites     = "buttonAllowedSites"
+	msgAutoLoad         = "labelAutoLoad"
+	msgChangePassphrase = "buttonChangePassphrase"
+	msgClientAlgorithm  = "labelClientAlgorithm"
+	msgCopyFingerprint  = "buttonCopyFingerprint"
+	msgDisable          = "buttonDisable"
+	msgEnable           = "buttonEnable"
+	msgExportPrivate    = "buttonExportPrivate"
+	msgLoad             = "buttonLoad"
+	msgNone             = "labelNone"
+	msgNote             = "buttonNote"
+	msgPriority         = "buttonPriority"
+	msgRemove           = "buttonRemove"
+	msgShowQR           = "buttonShowQR"
+	msgSSHConfig        = "buttonSSHConfig"
+	msgTags             = "buttonTags"
+	msgUnload           = "buttonUnload"
+	msgVerify           = "buttonVerify"
+
+	msgFailedAdd           = "errorFailedAdd"
+	msgFailedExport        = "errorFailedExport"
+	msgFailedExportPrivate = "errorFailedExportPrivate"
+	msgFailedImport        = "errorFailedImport"
+	msgFailedLoad          = "errorFailedLoad"
+	msgFailedPassphrase    = "errorFailedPassphrase"
+	msgFailedRemove        = "errorFailedRemove"
+	msgFailedUnload        = "errorFailedUnload"
+
+	msgHintBadPassphrase     = "hintBadPassphrase"
+	msgHintDuplicateKey      = "hintDuplicateKey"
+	msgHintInvalidName       = "hintInvalidName"
+	msgHintNameInUse         = "hintNameInUse"
+	msgHintUnsupportedCipher = "hintUnsupportedCipher"
+	msgHintUnsupportedFormat = "hintUnsupportedFormat"
+)
+
+// defaultMessages are the user-facing strings in English, used when no
+// translation is available.
+var defaultMessages = map[string]string{
+	msgAdopt:            "Adopt",
+	msgAllowedSites:     "Allowed Sites",
+	msgAutoLoad:         "Auto-load",
+	msgChangePassphrase: "Change Passphrase",
+	msgClientAlgorithm:  "As requested by client",
+	msgCopyFingerprint:  "Copy fingerprint",
+	msgDisable:          "Disable",
+	msgEnable:           "Enable",
+	msgExportPrivate:    "Export Private Key",
+	msgLoad:             "Load",
+	msgNone:             "None",
+	msgNote:             "Note",
+	msgPriority:         "Priority",
+	msgRemove:           "Remove",
+	msgShowQR:           "Show QR",
+	msgSSHConfig:        "SSH Config Snippet",
+	msgTags:             "Tags",
+	msgUnload:           "Unload",
+	msgVerify:           "Verify",
+
+	msgFailedAdd:           "failed to add key",
+	msgFailedExport:        "failed to export keys",
+	msgFailedExportPrivate: "failed to export private key",
+	msgFailedImport:        "failed to import keys",
+	msgFailedLoad:          "failed to load key",
+	msgFailedPassphrase:    "failed to change passphrase",
+	msgFailedRemove:        "failed to remove key",
+	msgFailedUnload:        "failed to unload key",
+
+	msgHintBadPassphrase:     "The passphrase is incorrect; check it and try again",
+	msgHintDuplicateKey:      "This key is already configured; to add it again, allow adding a key that is already configured",
+	msgHintInvalidName:       "The key name is not valid; choose a different name",
+	msgHintNameInUse:         "This name is already in use; choose a different name",
+	msgHintUnsupportedCipher: "This key uses an unsupported cipher; re-export it with ssh-keygen -p",
+	msgHintUnsupportedFormat: "The key is not in a supported format; supply a PEM-encoded private key or a PuTTY .ppk file",
+}
+
+// MessageLookup returns the translation of the user-facing string identified
+// by key. ok is false if no translation is available.
+type MessageLookup func(key string) (msg string, ok bool)
+
+// catalog translates user-facing strings, falling back to English.
+type catalog struct {
+	// mu guards fields below.
+	mu sync.Mutex
+	// lookup returns translations. Nil if only English is available.
+	lookup MessageLookup
+}
+
+// set replaces the function used to look up translations.
+func (c *catalog) set(lookup MessageLookup) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lookup = lookup
+}
+
+// t returns the user-facing string identified by key, translated if
+// possible. If no translation is available, the English string is returned;
+// unknown keys are returned as-is.
+func (c *catalog) t(key string) string {
+	c.mu.Lock()
+	lookup := c.lookup
+	c.mu.Unlock()
+
+	if lookup != nil {
+		if msg, ok := lookup(key); ok && msg != "" {
+			return msg
+		}
+	}
+	if msg, ok := defaultMessages[key]; ok {
+		return msg
+	}
+	return key
+}
+
+// SetMessages translates user-facing strings using the supplied lookup (e.g.,
+// chrome.GetMessage). Strings it does not translate are displayed in English.
+// Strings already displayed are translated once they are next refreshed.
+func (u *UI) SetMessages(lookup MessageLookup) {
+	u.messages.set(lookup)
+}
+
+// t returns the user-facing string identified by key, translated if
+// possible.
+func (u *UI) t(key string) string {
+	return u.messages.t(key)
+}
+
+// failed returns an error reporting that an operation failed, prefixed by the
+// translated description of the operation identified by key.
+func (u *UI) failed(key string, err error) error {
+	return fmt.Errorf("%s: %w", u.t(key), err)
+}
@@ -0,0 +1,145 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package optionsui
+
+import (
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/dom"
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakeMessages returns a MessageLookup that translates using the supplied
+// catalog.
+func fakeMessages(catalog map[string]string) MessageLookup {
+	return func(key string) (string, bool) {
+		msg, ok := catalog[key]
+		return msg, ok
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		lookup      MessageLookup
+		key         string
+		want        string
+	}{
+		{
+			description: "default to English",
+			key:         msgLoad,
+			want:        "Load",
+		},
+		{
+			description: "translated",
+			lookup:      fakeMessages(map[string]string{msgLoad: "Charger"}),
+			key:         msgLoad,
+			want:        "Charger",
+		},
+		{
+			description: "fall back to English if not translated",
+			lookup:      fakeMessages(map[string]string{msgLoad: "Charger"}),
+			key:         msgUnload,
+			want:        "Unload",
+		},
+		{
+			description: "fall back to English if translation empty",
+			lookup:      fakeMessages(map[string]string{msgLoad: ""}),
+			key:         msgLoad,
+			want:        "Load",
+		},
+		{
+			description: "unknown key",
+			key:         "bogusKey",
+			want:        "bogusKey",
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var c catalog
+			c.set(tc.lookup)
+			if diff := cmp.Diff(c.t(tc.key), tc.want); diff != "" {
+				t.Errorf("incorrect message; -got +want: %s", diff)
+			}
+		})
+	}
+}
+
+func TestDefaultMessagesComplete(t *testing.T) {
+	t.Parallel()
+
+	// Every key must have an English default.
+	for _, key := range []string{
+		msgAdopt, msgAllowedSites, msgAutoLoad, msgChangePassphrase,
+		msgClientAlgorithm, msgCopyFingerprint, msgDisable, msgEnable,
+		msgExportPrivate, msgLoad, msgNone, msgNote, msgPriority, msgRemove,
+		msgShowQR, msgSSHConfig, msgTags, msgUnload, msgVerify,
+		msgFailedAdd, msgFailedExport, msgFailedExportPrivate,
+		msgFailedImport, msgFailedLoad, msgFailedPassphrase,
+		msgFailedRemove, msgFailedUnload,
+		msgHintBadPassphrase, msgHintDuplicateKey, msgHintInvalidName,
+		msgHintNameInUse, msgHintUnsupportedCipher, msgHintUnsupportedFormat,
+	} {
+		if defaultMessages[key] == "" {
+			t.Errorf("no default message for key %s", key)
+		}
+	}
+}
+
+func TestTranslatedUI(t *testing.T) {
+	t.Parallel()
+
+	h := newHarness()
+	defer h.Release()
+	h.UI.SetMessages(fakeMessages(map[string]string{
+		msgLoad:      "Charger",
+		msgFailedAdd: "échec de l'ajout de la clé",
+	}))
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		h.waitLoaded(ctx)
+		if err := h.Client.Add(ctx, "new-key", testdata.WithPassphrase.Private, keys.AddOptions{}); err != nil {
+			t.Fatalf("failed to add key: %v", err)
+		}
+		h.UI.updateKeys(ctx)
+		id := findKey(h.UI.displayedKeys(), "new-key")
+
+		// Translated button labels are displayed, and others remain
+		// in English.
+		if diff := cmp.Diff(dom.TextContent(h.dom.GetElement(buttonID(LoadButton, id))), "Charger"); diff != "" {
+			t.Errorf("incorrect load button label; -got +want: %s", diff)
+		}
+		if diff := cmp.Diff(dom.TextContent(h.dom.GetElement(buttonID(RemoveButton, id))), "Remove"); diff != "" {
+			t.Errorf("incorrect remove button label; -got +want: %s", diff)
+		}
+
+		// Error prefixes are translated.
+		if diff := cmp.Diff(h.UI.failed(msgFailedAdd, errNotTextFile).Error(), "échec de l'ajout de la clé: "+errNotTextFile.Error()); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
@@ -0,0 +1,128 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package optionsui
+
+import (
+	"slices"
+	"strconv"
+	"syscall/js"
+	"time"
+
+	"github.com/google/chrome-ssh-agent/go/dom"
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+)
+
+const (
+	// countdownInterval is the interval at which the remaining lifetime of
+	// loaded keys is updated.
+	countdownInterval = time.Second
+	// expiryWarning is the remaining lifetime below which a key is
+	// highlighted as about to expire.
+	expiryWarning = time.Minute
+	// expiryAttr is the attribute recording when the key whose remaining
+	// lifetime is displayed by an element expires, in milliseconds since
+	// the Unix epoch.
+	expiryAttr = "data-expiry"
+)
+
+// expiring indicates that the key will soon be unloaded, since its lifetime
+// is nearly over.
+func (d *displayedKey) expiring(now time.Time) bool {
+	return !d.Expiry.IsZero() && d.Expiry.Sub(now) < expiryWarning
+}
+
+// countdown counts down the remaining lifetime of the displayed keys once per
+// countdownInterval, unless already doing so. Only the elements displaying
+// the remaining lifetime are updated; the keys are refreshed from the agent
+// once a key expires, so that it is displayed as unloaded. Counting down
+// stops once no displayed key has a limited lifetime (e.g., once such keys
+// expire, or are unloaded or removed).
+func (u *UI) countdown() {
+	if !slices.ContainsFunc(u.keys, func(k *displayedKey) bool { return !k.Expiry.IsZero() }) {
+		return
+	}
+
+	u.refreshMu.Lock()
+	if u.countingDown {
+		u.refreshMu.Unlock()
+		return
+	}
+	u.countingDown = true
+	u.refreshMu.Unlock()
+
+	stop := func() {
+		u.refreshMu.Lock()
+		u.countingDown = false
+		u.refreshMu.Unlock()
+	}
+
+	jsutil.Async(func(ctx jsutil.AsyncContext) (js.Value, error) {
+		for {
+			time.Sleep(countdownInterval)
+			u.reconnectMu.Lock()
+			released := u.released
+			u.reconnectMu.Unlock()
+			if released {
+				stop()
+				return js.Undefined(), nil
+			}
+			if u.dom.Hidden() {
+				continue
+			}
+
+			remaining, expired := u.updateLifetimes(time.Now())
+			if expired {
+				// The agent removes expired keys; display the
+				// agent's current state.
+				u.updateKeys(ctx)
+				continue
+			}
+			if remaining == 0 {
+				stop()
+				return js.Undefined(), nil
+			}
+		}
+	})
+}
+
+// updateLifetimes updates the elements displaying the remaining lifetime of
+// keys. It returns the number of keys yet to expire, and whether any key has
+// expired.
+func (u *UI) updateLifetimes(now time.Time) (remaining int, expired bool) {
+	for _, elem := range dom.QuerySelectorAll(u.keysData, "["+expiryAttr+"]") {
+		ms, err := strconv.ParseInt(dom.GetAttribute(elem, expiryAttr), 10, 64)
+		if err != nil {
+			continue
+		}
+		k := &displayedKey{Expiry: time.UnixMilli(ms)}
+
+		dom.RemoveChildren(elem)
+		dom.AppendChild(elem, u.dom.NewText(k.lifetimeText(now)), nil)
+		if k.expiring(now) {
+			dom.AddClass(elem, "keyExpiring")
+		} else {
+			dom.RemoveClass(elem, "keyExpiring")
+		}
+
+		if k.Expiry.After(now) {
+			remaining++
+		} else {
+			expired = true
+		}
+	}
+	return
+}
@@ -0,0 +1,119 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package optionsui
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/chrome-ssh-agent/go/dom"
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestExpiring(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	testcases := []struct {
+		description string
+		expiry      time.Time
+		want        bool
+	}{
+		{
+			description: "does not expire",
+		},
+		{
+			description: "expires later",
+			expiry:      now.Add(time.Hour),
+		},
+		{
+			description: "expires soon",
+			expiry:      now.Add(30 * time.Second),
+			want:        true,
+		},
+		{
+			description: "expired",
+			expiry:      now.Add(-time.Second),
+			want:        true,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			k := &displayedKey{Expiry: tc.expiry}
+			if diff := cmp.Diff(k.expiring(now), tc.want); diff != "" {
+				t.Errorf("incorrect expiring; -got +want: %s", diff)
+			}
+		})
+	}
+}
+
+func TestLifetimeCountdown(t *testing.T) {
+	t.Parallel()
+
+	h := newHarness()
+	defer h.Release()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		h.waitLoaded(ctx)
+
+		if err := h.Client.Add(ctx, "new-key", testdata.WithoutPassphrase.Private, keys.AddOptions{}); err != nil {
+			t.Fatalf("failed to add key: %v", err)
+		}
+		h.UI.updateKeys(ctx)
+		id := findKey(h.UI.displayedKeys(), "new-key")
+		if err := h.Client.Load(ctx, id, "", keys.LoadOptions{LifetimeSecs: 3}); err != nil {
+			t.Fatalf("failed to load key: %v", err)
+		}
+		h.UI.updateKeys(ctx)
+
+		// The remaining lifetime is highlighted, since the key is about
+		// to expire.
+		lifetime := dom.QuerySelector(h.dom.GetElement(rowID(id)), ".keyLifetime")
+		if lifetime.IsNull() {
+			t.Fatalf("remaining lifetime not displayed")
+		}
+		if !strings.Contains(dom.GetAttribute(lifetime, "class"), "keyExpiring") {
+			t.Errorf("expiring key not highlighted; got class %q", dom.GetAttribute(lifetime, "class"))
+		}
+
+		// The remaining lifetime counts down in place, without
+		// replacing the key's row.
+		initial := dom.TextContent(lifetime)
+		mustPoll(ctx, func() bool { return dom.TextContent(lifetime) != initial })
+		if !strings.HasPrefix(dom.TextContent(lifetime), "Expires in") && dom.TextContent(lifetime) != "Expired" {
+			t.Errorf("incorrect remaining lifetime: %q", dom.TextContent(lifetime))
+		}
+
+		// Once the key expires, it is displayed as unloaded, and
+		// counting down stops.
+		h.waitKeyUnloaded(ctx, "new-key")
+		mustPoll(ctx, func() bool {
+			h.UI.refreshMu.Lock()
+			defer h.UI.refreshMu.Unlock()
+			return !h.UI.countingDown
+		})
+	})
+}
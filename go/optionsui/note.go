@@ -0,0 +1,84 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package optionsui
+
+import (
+	"fmt"
+
+	"github.com/google/chrome-ssh-agent/go/dom"
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	"github.com/google/chrome-ssh-agent/go/keys"
+)
+
+// promptNote displays a dialog prompting the user for a note describing a
+// key.
+func (u *UI) promptNote(ctx jsutil.AsyncContext, id keys.ID) (ok bool, note string) {
+	k := u.keyByID(id)
+	if k == nil {
+		u.setError(fmt.Errorf("failed to edit note for key ID %s: not found", id))
+		return
+	}
+
+	dialogElem := u.dom.GetElement("noteDialog")
+	dialog := dom.NewDialog(dialogElem)
+	form := u.dom.GetElement("noteForm")
+	name := u.dom.GetElement("noteName")
+	field := u.dom.GetElement("noteText")
+	okButton := u.dom.GetElement("noteOk")
+	cancel := u.dom.GetElement("noteCancel")
+	dom.AppendChild(name, u.dom.NewText(k.Name), nil)
+	dom.SetValue(field, k.Note)
+
+	sig := newSignal()
+	var cleanup jsutil.CleanupFuncs
+	cleanup.Add(dom.OnSubmit(form, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		ok = true
+		note = dom.Value(field)
+		dialog.Close()
+		sig.Notify()
+	}))
+	cleanup.Add(dom.OnClick(cancel, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		dialog.Close()
+		sig.Notify()
+	}))
+	cleanup.Add(onDialogKeys(field, okButton, cancel))
+	cleanup.Add(dialog.OnClose(func(ctx jsutil.AsyncContext, evt dom.Event) {
+		dom.RemoveChildren(name)
+		dom.SetValue(field, "")
+		cleanup.Do()
+	}))
+
+	cleanup.Add(u.showModal(dialog, dialogElem, field))
+	sig.Wait(ctx)
+	return
+}
+
+// editNote changes the note describing the key with the specified ID. A
+// dialog prompts the user for the note.
+func (u *UI) editNote(ctx jsutil.AsyncContext, id keys.ID) {
+	ok, note := u.promptNote(ctx, id)
+	if !ok {
+		return
+	}
+
+	if err := u.mgr.SetNote(ctx, id, note); err != nil {
+		u.setError(fmt.Errorf("failed to set note: %w", err))
+		return
+	}
+	u.setError(nil)
+	u.updateKeys(ctx)
+}
@@ -0,0 +1,80 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package optionsui
+
+import (
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/dom"
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEditNote(t *testing.T) {
+	t.Parallel()
+
+	h := newHarness()
+	defer h.Release()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		h.waitLoaded(ctx)
+
+		if err := h.Client.Add(ctx, "some-key", testdata.WithoutPassphrase.Private, keys.AddOptions{}); err != nil {
+			t.Fatalf("failed to add key: %v", err)
+		}
+		h.UI.updateKeys(ctx)
+		id := findKey(h.UI.displayedKeys(), "some-key")
+		if note := h.dom.GetElement(noteID(id)); !note.IsNull() {
+			t.Errorf("note displayed for key without a note")
+		}
+
+		// The note is edited using a dialog.
+		dialog := h.dom.GetElement("noteDialog")
+		dom.DoClick(h.dom.GetElement(buttonID(NoteButton, id)))
+		h.waitDialogOpen(ctx, dialog)
+		dom.SetValue(h.dom.GetElement("noteText"), " prod bastion\nrotate quarterly ")
+		dom.DoClick(h.dom.GetElement("noteOk"))
+		h.waitDialogClosed(ctx, dialog)
+		mustPoll(ctx, func() bool {
+			k := h.UI.keyByID(id)
+			return k != nil && k.Note != ""
+		})
+		if diff := cmp.Diff(h.UI.keyByID(id).Note, "prod bastion\nrotate quarterly"); diff != "" {
+			t.Errorf("incorrect note; -got +want: %s", diff)
+		}
+
+		// The note is displayed with the key.
+		mustPoll(ctx, func() bool { return !h.dom.GetElement(noteID(id)).IsNull() })
+		if diff := cmp.Diff(dom.TextContent(h.dom.GetElement(noteID(id))), "prod bastion\nrotate quarterly"); diff != "" {
+			t.Errorf("incorrect displayed note; -got +want: %s", diff)
+		}
+
+		// The existing note is edited, and may be removed.
+		dom.DoClick(h.dom.GetElement(buttonID(NoteButton, id)))
+		h.waitDialogOpen(ctx, dialog)
+		if diff := cmp.Diff(dom.Value(h.dom.GetElement("noteText")), "prod bastion\nrotate quarterly"); diff != "" {
+			t.Errorf("incorrect initial note; -got +want: %s", diff)
+		}
+		dom.SetValue(h.dom.GetElement("noteText"), "")
+		dom.DoClick(h.dom.GetElement("noteOk"))
+		h.waitDialogClosed(ctx, dialog)
+		mustPoll(ctx, func() bool { return h.dom.GetElement(noteID(id)).IsNull() })
+	})
+}
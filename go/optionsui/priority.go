@@ -0,0 +1,124 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package optionsui
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/chrome-ssh-agent/go/dom"
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	"github.com/google/chrome-ssh-agent/go/keys"
+)
+
+var (
+	// errInvalidPriority indicates that the priority supplied by the user
+	// is not a valid number.
+	errInvalidPriority = errors.New("invalid priority")
+)
+
+// parsePriority parses the priority supplied by the user. An empty value
+// indicates the default priority.
+func parsePriority(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	p, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%w: '%s' is not a whole number", errInvalidPriority, s)
+	}
+	return p, nil
+}
+
+// byPriority returns the keys ordered so that those with a higher priority
+// come first. Keys with the same priority retain their relative order.
+func byPriority(disp []*displayedKey) []*displayedKey {
+	result := slices.Clone(disp)
+	slices.SortStableFunc(result, func(a, b *displayedKey) int {
+		return cmp.Compare(b.Priority, a.Priority)
+	})
+	return result
+}
+
+// promptPriority displays a dialog prompting the user for the priority of a
+// key.
+func (u *UI) promptPriority(ctx jsutil.AsyncContext, id keys.ID) (ok bool, priority string) {
+	k := u.keyByID(id)
+	if k == nil {
+		u.setError(fmt.Errorf("failed to edit priority for key ID %s: not found", id))
+		return
+	}
+
+	dialogElem := u.dom.GetElement("priorityDialog")
+	dialog := dom.NewDialog(dialogElem)
+	form := u.dom.GetElement("priorityForm")
+	name := u.dom.GetElement("priorityName")
+	field := u.dom.GetElement("priorityValue")
+	okButton := u.dom.GetElement("priorityOk")
+	cancel := u.dom.GetElement("priorityCancel")
+	dom.AppendChild(name, u.dom.NewText(k.Name), nil)
+	dom.SetValue(field, strconv.Itoa(k.Priority))
+
+	sig := newSignal()
+	var cleanup jsutil.CleanupFuncs
+	cleanup.Add(dom.OnSubmit(form, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		ok = true
+		priority = dom.Value(field)
+		dialog.Close()
+		sig.Notify()
+	}))
+	cleanup.Add(dom.OnClick(cancel, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		dialog.Close()
+		sig.Notify()
+	}))
+	cleanup.Add(onDialogKeys(field, okButton, cancel))
+	cleanup.Add(dialog.OnClose(func(ctx jsutil.AsyncContext, evt dom.Event) {
+		dom.RemoveChildren(name)
+		dom.SetValue(field, "")
+		cleanup.Do()
+	}))
+
+	cleanup.Add(u.showModal(dialog, dialogElem, field))
+	sig.Wait(ctx)
+	return
+}
+
+// editPriority changes the priority of the key with the specified ID. A
+// dialog prompts the user for the priority.
+func (u *UI) editPriority(ctx jsutil.AsyncContext, id keys.ID) {
+	ok, s := u.promptPriority(ctx, id)
+	if !ok {
+		return
+	}
+	priority, err := parsePriority(s)
+	if err != nil {
+		u.setError(err)
+		return
+	}
+
+	if err := u.mgr.SetPriority(ctx, id, priority); err != nil {
+		u.setError(fmt.Errorf("failed to set priority: %w", err))
+		return
+	}
+	u.setError(nil)
+	u.updateKeys(ctx)
+}
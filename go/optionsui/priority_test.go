@@ -0,0 +1,140 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package optionsui
+
+import (
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/dom"
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestParsePriority(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		s           string
+		want        int
+		wantErr     error
+	}{
+		{
+			description: "empty",
+			s:           "",
+			want:        0,
+		},
+		{
+			description: "positive",
+			s:           " 10 ",
+			want:        10,
+		},
+		{
+			description: "negative",
+			s:           "-3",
+			want:        -3,
+		},
+		{
+			description: "not a number",
+			s:           "high",
+			wantErr:     errInvalidPriority,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parsePriority(tc.s)
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Errorf("incorrect priority; -got +want: %s", diff)
+			}
+			if diff := cmp.Diff(err, tc.wantErr, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("incorrect error; -got +want: %s", diff)
+			}
+		})
+	}
+}
+
+func TestByPriority(t *testing.T) {
+	t.Parallel()
+
+	disp := []*displayedKey{
+		{Name: "low", Priority: -1},
+		{Name: "first-default"},
+		{Name: "high", Priority: 10},
+		{Name: "second-default"},
+	}
+	var got []string
+	for _, k := range byPriority(disp) {
+		got = append(got, k.Name)
+	}
+	if diff := cmp.Diff(got, []string{"high", "first-default", "second-default", "low"}); diff != "" {
+		t.Errorf("incorrect order; -got +want: %s", diff)
+	}
+}
+
+func TestEditPriority(t *testing.T) {
+	t.Parallel()
+
+	h := newHarness()
+	defer h.Release()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		h.waitLoaded(ctx)
+
+		if err := h.Client.Add(ctx, "some-key", testdata.WithoutPassphrase.Private, keys.AddOptions{}); err != nil {
+			t.Fatalf("failed to add key: %v", err)
+		}
+		h.UI.updateKeys(ctx)
+		id := findKey(h.UI.displayedKeys(), "some-key")
+
+		// The priority is edited using a dialog.
+		dialog := h.dom.GetElement("priorityDialog")
+		dom.DoClick(h.dom.GetElement(buttonID(PriorityButton, id)))
+		h.waitDialogOpen(ctx, dialog)
+		if diff := cmp.Diff(dom.Value(h.dom.GetElement("priorityValue")), "0"); diff != "" {
+			t.Errorf("incorrect initial priority; -got +want: %s", diff)
+		}
+		dom.SetValue(h.dom.GetElement("priorityValue"), "10")
+		dom.DoClick(h.dom.GetElement("priorityOk"))
+		h.waitDialogClosed(ctx, dialog)
+		mustPoll(ctx, func() bool {
+			k := h.UI.keyByID(id)
+			return k != nil && k.Priority == 10
+		})
+
+		// Invalid priorities are rejected.
+		dom.DoClick(h.dom.GetElement(buttonID(PriorityButton, id)))
+		h.waitDialogOpen(ctx, dialog)
+		dom.SetValue(h.dom.GetElement("priorityValue"), "high")
+		dom.DoClick(h.dom.GetElement("priorityOk"))
+		h.waitDialogClosed(ctx, dialog)
+		mustPoll(ctx, func() bool { return dom.TextContent(h.UI.errorText) != "" })
+		if diff := cmp.Diff(dom.TextContent(h.UI.errorText), "invalid priority: 'high' is not a whole number"); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+		if diff := cmp.Diff(h.UI.keyByID(id).Priority, 10); diff != "" {
+			t.Errorf("incorrect priority after invalid input; -got +want: %s", diff)
+		}
+	})
+}
@@ -0,0 +1,101 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package optionsui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/chrome-ssh-agent/go/dom"
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	"github.com/google/chrome-ssh-agent/go/keys"
+	"github.com/google/chrome-ssh-agent/go/qrcode"
+)
+
+const (
+	// qrScale is the size in pixels of each module of a displayed QR code.
+	qrScale = 4
+	// qrMargin is the width in modules of the light border surrounding a
+	// displayed QR code, which scanners require to locate it.
+	qrMargin = 4
+)
+
+// authorizedKeyLine returns the public key of the supplied key as a line in
+// authorized_keys format, with the key's name as its comment. It is empty if
+// the public key is unknown (e.g., the key is encrypted and not loaded).
+func authorizedKeyLine(k *displayedKey) string {
+	if k.Blob == "" {
+		return ""
+	}
+	line := fmt.Sprintf("%s %s", k.Type, k.Blob)
+	// A comment spans the remainder of the line, so it must not contain a
+	// line break.
+	if name := strings.Join(strings.Fields(k.Name), " "); name != "" {
+		line += " " + name
+	}
+	return line
+}
+
+// showQR displays the public key of the key with the specified ID as a QR
+// code, so that it can be scanned by another device. Only the public key is
+// encoded.
+func (u *UI) showQR(ctx jsutil.AsyncContext, id keys.ID) {
+	k := u.keyByID(id)
+	if k == nil {
+		u.setError(fmt.Errorf("failed to show QR code for key ID %s: not found", id))
+		return
+	}
+	line := authorizedKeyLine(k)
+	if line == "" {
+		u.setError(fmt.Errorf("failed to show QR code for %s: public key unknown; load the key first", k.Name))
+		return
+	}
+	code, err := qrcode.Encode([]byte(line), qrcode.Medium)
+	if err != nil {
+		u.setError(fmt.Errorf("failed to show QR code for %s: %w", k.Name, err))
+		return
+	}
+	u.setError(nil)
+
+	dialogElem := u.dom.GetElement("qrDialog")
+	dialog := dom.NewDialog(dialogElem)
+	form := u.dom.GetElement("qrForm")
+	name := u.dom.GetElement("qrName")
+	canvas := u.dom.GetElement("qrCanvas")
+	closeButton := u.dom.GetElement("qrClose")
+	dom.AppendChild(name, u.dom.NewText(k.Name), nil)
+	dom.DrawGrid(canvas, code.Size, qrMargin, qrScale, code.Dark)
+	dom.SetAttribute(canvas, "title", line)
+
+	sig := newSignal()
+	var cleanup jsutil.CleanupFuncs
+	cleanup.Add(dom.OnSubmit(form, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		dialog.Close()
+		sig.Notify()
+	}))
+	cleanup.Add(dialog.OnClose(func(ctx jsutil.AsyncContext, evt dom.Event) {
+		dom.RemoveChildren(name)
+		// Resizing the canvas clears it.
+		canvas.Set("width", 0)
+		canvas.Set("height", 0)
+		dom.SetAttribute(canvas, "title", "")
+		cleanup.Do()
+	}))
+
+	cleanup.Add(u.showModal(dialog, dialogElem, closeButton))
+	sig.Wait(ctx)
+}
@@ -0,0 +1,126 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package optionsui
+
+import (
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/dom"
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/chrome-ssh-agent/go/qrcode"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAuthorizedKeyLine(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		key         *displayedKey
+		want        string
+	}{
+		{
+			description: "key with name",
+			key:         &displayedKey{Name: "work-key", Type: "ssh-ed25519", Blob: "AAAAC3NzaC1lZDI1NTE5"},
+			want:        "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5 work-key",
+		},
+		{
+			description: "name with line break",
+			key:         &displayedKey{Name: "work\nkey ", Type: "ssh-ed25519", Blob: "AAAAC3NzaC1lZDI1NTE5"},
+			want:        "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5 work key",
+		},
+		{
+			description: "key without name",
+			key:         &displayedKey{Type: "ssh-ed25519", Blob: "AAAAC3NzaC1lZDI1NTE5"},
+			want:        "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5",
+		},
+		{
+			description: "public key unknown",
+			key:         &displayedKey{Name: "work-key"},
+			want:        "",
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			if diff := cmp.Diff(authorizedKeyLine(tc.key), tc.want); diff != "" {
+				t.Errorf("incorrect line; -got +want: %s", diff)
+			}
+		})
+	}
+}
+
+func TestShowQR(t *testing.T) {
+	t.Parallel()
+
+	h := newHarness()
+	defer h.Release()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		h.waitLoaded(ctx)
+
+		if err := h.Client.Add(ctx, "some-key", testdata.WithoutPassphrase.Private, keys.AddOptions{}); err != nil {
+			t.Fatalf("failed to add key: %v", err)
+		}
+		h.UI.updateKeys(ctx)
+		id := findKey(h.UI.displayedKeys(), "some-key")
+
+		// The public key is unknown until the key is loaded.
+		button := h.dom.GetElement(buttonID(QRButton, id))
+		if !button.Get("disabled").Bool() {
+			t.Errorf("QR button enabled before key loaded")
+		}
+
+		if err := h.Client.Load(ctx, id, "", keys.LoadOptions{}); err != nil {
+			t.Fatalf("failed to load key: %v", err)
+		}
+		h.UI.updateKeys(ctx)
+		button = h.dom.GetElement(buttonID(QRButton, id))
+		mustPoll(ctx, func() bool { return !button.Get("disabled").Bool() })
+
+		line := authorizedKeyLine(h.UI.keyByID(id))
+		code, err := qrcode.Encode([]byte(line), qrcode.Medium)
+		if err != nil {
+			t.Fatalf("failed to encode: %v", err)
+		}
+
+		// The dialog displays the QR code, sized to fit the code and
+		// its border.
+		dialog := h.dom.GetElement("qrDialog")
+		canvas := h.dom.GetElement("qrCanvas")
+		dom.DoClick(button)
+		h.waitDialogOpen(ctx, dialog)
+		if diff := cmp.Diff(dom.TextContent(h.dom.GetElement("qrName")), "some-key"); diff != "" {
+			t.Errorf("incorrect name; -got +want: %s", diff)
+		}
+		if diff := cmp.Diff(canvas.Get("width").Int(), (code.Size+2*qrMargin)*qrScale); diff != "" {
+			t.Errorf("incorrect canvas width; -got +want: %s", diff)
+		}
+		if diff := cmp.Diff(dom.GetAttribute(canvas, "title"), line); diff != "" {
+			t.Errorf("incorrect canvas title; -got +want: %s", diff)
+		}
+
+		// The canvas is cleared once the dialog is closed.
+		dom.DoClick(h.dom.GetElement("qrClose"))
+		h.waitDialogClosed(ctx, dialog)
+		mustPoll(ctx, func() bool { return canvas.Get("width").Int() == 0 })
+	})
+}
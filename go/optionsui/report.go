@@ -0,0 +1,133 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package optionsui
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// TestStep describes a single step of the end-to-end test.
+type TestStep struct {
+	// Name describes the step.
+	Name string `json:"name"`
+	// Passed indicates that no failures were observed during the step.
+	Passed bool `json:"passed"`
+	// DurationMillis is the time taken by the step, in milliseconds.
+	DurationMillis int64 `json:"durationMillis"`
+	// Errors describes the failures observed during the step.
+	Errors []string `json:"errors,omitempty"`
+
+	start time.Time
+}
+
+// TestReport describes the outcome of the end-to-end test. It may be
+// serialized as JSON for consumption by automated test runners.
+type TestReport struct {
+	// Passed indicates that no failures were observed during the test.
+	Passed bool `json:"passed"`
+	// Start is the time at which the test started.
+	Start time.Time `json:"start"`
+	// DurationMillis is the time taken by the entire test, in
+	// milliseconds.
+	DurationMillis int64 `json:"durationMillis"`
+	// Steps are the steps of the test, in the order they were run.
+	Steps []*TestStep `json:"steps"`
+
+	errs []error
+}
+
+// Errors returns the failures observed during the test, in the order they
+// were observed.
+func (r *TestReport) Errors() []error {
+	return r.errs
+}
+
+// JSON returns the report serialized as JSON.
+func (r *TestReport) JSON() (string, error) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize test report: %w", err)
+	}
+	return string(b), nil
+}
+
+// testRecorder records the steps of the end-to-end test as they are run,
+// along with the failures observed during each.
+type testRecorder struct {
+	report *TestReport
+	logf   func(format string, args ...interface{})
+}
+
+// newTestRecorder returns a testRecorder that also reports each step via
+// logf as it begins.
+func newTestRecorder(logf func(format string, args ...interface{})) *testRecorder {
+	return &testRecorder{
+		report: &TestReport{Start: time.Now()},
+		logf:   logf,
+	}
+}
+
+// current returns the step currently being run, or nil if none have begun.
+func (r *testRecorder) current() *TestStep {
+	if len(r.report.Steps) == 0 {
+		return nil
+	}
+	return r.report.Steps[len(r.report.Steps)-1]
+}
+
+// endStep records the duration of the step currently being run, if any.
+func (r *testRecorder) endStep(now time.Time) {
+	if s := r.current(); s != nil {
+		s.DurationMillis = now.Sub(s.start).Milliseconds()
+		s.Passed = len(s.Errors) == 0
+	}
+}
+
+// step ends the step currently being run, and begins a new one.
+func (r *testRecorder) step(format string, args ...interface{}) {
+	now := time.Now()
+	r.endStep(now)
+	r.report.Steps = append(r.report.Steps, &TestStep{
+		Name:  fmt.Sprintf(format, args...),
+		start: now,
+	})
+	r.logf(format, args...)
+}
+
+// fail records a failure observed during the step currently being run.
+func (r *testRecorder) fail(err error) {
+	r.report.errs = append(r.report.errs, err)
+	if s := r.current(); s != nil {
+		s.Errors = append(s.Errors, err.Error())
+	}
+}
+
+// finish ends the test, and returns the completed report.
+func (r *testRecorder) finish() *TestReport {
+	now := time.Now()
+	r.endStep(now)
+	r.report.DurationMillis = now.Sub(r.report.Start).Milliseconds()
+	r.report.Passed = len(r.report.errs) == 0
+
+	r.logf("Finished test")
+	for _, err := range r.report.errs {
+		r.logf("  Reported Error: %v", err)
+	}
+	return r.report
+}
@@ -0,0 +1,90 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package optionsui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestTestRecorder(t *testing.T) {
+	t.Parallel()
+
+	var logged []string
+	r := newTestRecorder(func(format string, args ...interface{}) {
+		logged = append(logged, fmt.Sprintf(format, args...))
+	})
+	r.step("Starting test")
+	r.step("Load key %d", 1)
+	time.Sleep(20 * time.Millisecond)
+	r.fail(errors.New("failed to load"))
+	r.fail(errors.New("incorrect type"))
+	r.step("Unload key")
+	report := r.finish()
+
+	wantLogged := []string{
+		"Starting test",
+		"Load key 1",
+		"Unload key",
+		"Finished test",
+		"  Reported Error: failed to load",
+		"  Reported Error: incorrect type",
+	}
+	if diff := cmp.Diff(logged, wantLogged); diff != "" {
+		t.Errorf("incorrect logged steps; -got +want: %s", diff)
+	}
+
+	wantSteps := []*TestStep{
+		{Name: "Starting test", Passed: true},
+		{Name: "Load key 1", Errors: []string{"failed to load", "incorrect type"}},
+		{Name: "Unload key", Passed: true},
+	}
+	if diff := cmp.Diff(report.Steps, wantSteps, cmpopts.IgnoreUnexported(TestStep{}), cmpopts.IgnoreFields(TestStep{}, "DurationMillis")); diff != "" {
+		t.Errorf("incorrect steps; -got +want: %s", diff)
+	}
+	if report.Passed {
+		t.Errorf("report passed despite failures")
+	}
+	if got := report.Steps[1].DurationMillis; got < 20 {
+		t.Errorf("incorrect step duration: got %dms, want at least 20ms", got)
+	}
+	if report.DurationMillis < report.Steps[1].DurationMillis {
+		t.Errorf("overall duration %dms shorter than step duration %dms", report.DurationMillis, report.Steps[1].DurationMillis)
+	}
+	if diff := cmp.Diff(len(report.Errors()), 2); diff != "" {
+		t.Errorf("incorrect number of errors; -got +want: %s", diff)
+	}
+
+	// The report may be parsed by automation.
+	text, err := report.JSON()
+	if err != nil {
+		t.Fatalf("failed to serialize report: %v", err)
+	}
+	var parsed TestReport
+	if err := json.Unmarshal([]byte(text), &parsed); err != nil {
+		t.Fatalf("failed to parse report: %v", err)
+	}
+	if diff := cmp.Diff(&parsed, report, cmpopts.IgnoreUnexported(TestReport{}, TestStep{})); diff != "" {
+		t.Errorf("incorrect parsed report; -got +want: %s", diff)
+	}
+}
@@ -0,0 +1,230 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package optionsui
+
+import (
+	"errors"
+	"fmt"
+	"syscall/js"
+
+	"github.com/google/chrome-ssh-agent/go/dom"
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	"github.com/google/chrome-ssh-agent/go/keys"
+)
+
+const (
+	// selectClass is the class of the checkboxes that select keys.
+	selectClass = "keySelect"
+	// selectKeyAttr is the attribute of a checkbox holding the ID of the
+	// key it selects.
+	selectKeyAttr = "data-key-id"
+)
+
+// selectCheckboxID returns the value of the 'id' attribute to be assigned to
+// the checkbox that selects the key with the specified ID.
+func selectCheckboxID(id keys.ID) string {
+	return fmt.Sprintf("select-%s", id)
+}
+
+// selectedText returns the label of the button removing the selected keys.
+func selectedText(n int) string {
+	if n == 0 {
+		return "Remove Selected"
+	}
+	return fmt.Sprintf("Remove Selected (%d)", n)
+}
+
+// keysText returns a count of keys, suitable for display.
+func keysText(n int) string {
+	if n == 1 {
+		return "1 key"
+	}
+	return fmt.Sprintf("%d keys", n)
+}
+
+// selectKey handles the user checking or unchecking the checkbox for the key
+// with the specified ID. If shift is true, every key displayed between the
+// key whose checkbox was most recently clicked and this key is changed to
+// match, so that a range of keys may be selected at once.
+func (u *UI) selectKey(id keys.ID, checked, shift bool) {
+	ids := []keys.ID{id}
+	if shift && u.lastSelected != keys.InvalidID {
+		ids = u.selectRange(u.lastSelected, id)
+	}
+	if u.selected == nil {
+		u.selected = map[keys.ID]bool{}
+	}
+	for _, i := range ids {
+		if checked {
+			u.selected[i] = true
+		} else {
+			delete(u.selected, i)
+		}
+	}
+	u.lastSelected = id
+	u.updateSelection()
+}
+
+// selectRange returns the IDs of the keys displayed between the keys with
+// the specified IDs, inclusive, in the order in which they are displayed. If
+// from is no longer displayed, only to is returned.
+func (u *UI) selectRange(from, to keys.ID) []keys.ID {
+	var result []keys.ID
+	seen := map[keys.ID]bool{}
+	inRange := false
+	for _, box := range dom.QuerySelectorAll(u.keysData, "."+selectClass) {
+		id := keys.ID(dom.GetAttribute(box, selectKeyAttr))
+		if seen[id] {
+			// The key is displayed in several groups.
+			continue
+		}
+		seen[id] = true
+		edge := id == from || id == to
+		if edge || inRange {
+			result = append(result, id)
+		}
+		if edge {
+			if inRange || from == to {
+				return result
+			}
+			inRange = true
+		}
+	}
+	return []keys.ID{to}
+}
+
+// selectedIDs returns the IDs of the selected keys, in the order in which
+// they are displayed.
+func (u *UI) selectedIDs() []keys.ID {
+	var result []keys.ID
+	for _, k := range u.keys {
+		if k.ID != keys.InvalidID && u.selected[k.ID] {
+			result = append(result, k.ID)
+		}
+	}
+	return result
+}
+
+// updateSelection refreshes the checkboxes and the button removing the
+// selected keys to reflect the current selection. Keys that are no longer
+// displayed are dropped from the selection.
+func (u *UI) updateSelection() {
+	ids := u.selectedIDs()
+	u.selected = map[keys.ID]bool{}
+	for _, id := range ids {
+		u.selected[id] = true
+	}
+	if u.keyByID(u.lastSelected) == nil {
+		u.lastSelected = keys.InvalidID
+	}
+
+	for _, box := range dom.QuerySelectorAll(u.keysData, "."+selectClass) {
+		dom.SetChecked(box, u.selected[keys.ID(dom.GetAttribute(box, selectKeyAttr))])
+	}
+	u.removeSelectedButton.Set("disabled", len(ids) == 0)
+	dom.RemoveChildren(u.removeSelectedButton)
+	dom.AppendChild(u.removeSelectedButton, u.dom.NewText(selectedText(len(ids))), nil)
+}
+
+// promptRemoveMany displays a dialog prompting the user to confirm that the
+// keys with the specified IDs should be removed. The name of each key is
+// listed.
+func (u *UI) promptRemoveMany(ctx jsutil.AsyncContext, ids []keys.ID) (yes bool) {
+	dialog := dom.NewDialog(u.dom.GetElement("removeManyDialog"))
+	form := u.dom.GetElement("removeManyForm")
+	count := u.dom.GetElement("removeManyCount")
+	list := u.dom.GetElement("removeManyList")
+	no := u.dom.GetElement("removeManyNo")
+	dom.AppendChild(count, u.dom.NewText(keysText(len(ids))), nil)
+	for _, id := range ids {
+		if k := u.keyByID(id); k != nil {
+			dom.AppendChild(list, u.dom.NewElement("li"), func(item js.Value) {
+				dom.AppendChild(item, u.dom.NewText(k.Name), nil)
+			})
+		}
+	}
+
+	sig := newSignal()
+	var cleanup jsutil.CleanupFuncs
+	cleanup.Add(dom.OnSubmit(form, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		yes = true
+		dialog.Close()
+		sig.Notify()
+	}))
+	cleanup.Add(dom.OnClick(no, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		dialog.Close()
+		sig.Notify()
+	}))
+	cleanup.Add(dialog.OnClose(func(ctx jsutil.AsyncContext, evt dom.Event) {
+		dom.RemoveChildren(count)
+		dom.RemoveChildren(list)
+		cleanup.Do()
+	}))
+
+	dialog.ShowModal()
+	sig.Wait(ctx)
+	return
+}
+
+// removeSelected removes the selected keys at once. A dialog prompts the user
+// to confirm that the keys should be removed. Failure to remove an individual
+// key does not prevent removing the remaining keys; all failures are
+// displayed together once finished.
+func (u *UI) removeSelected(ctx jsutil.AsyncContext, _ dom.Event) {
+	ids := u.selectedIDs()
+	if len(ids) == 0 {
+		return
+	}
+	if yes := u.promptRemoveMany(ctx, ids); !yes {
+		return
+	}
+
+	names := map[keys.ID]string{}
+	for _, id := range ids {
+		names[id] = string(id)
+		if k := u.keyByID(id); k != nil {
+			names[id] = k.Name
+		}
+	}
+	// Only the removal of a single key may be undone.
+	u.dismissUndo(0)
+	removeErrs, err := u.mgr.RemoveMany(ctx, ids)
+	u.selected = nil
+	u.lastSelected = keys.InvalidID
+
+	var errs []error
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to remove keys: %w", err))
+	}
+	removed := 0
+	for i, e := range removeErrs {
+		if e != nil {
+			errs = append(errs, fmt.Errorf("failed to remove key %s: %w", names[ids[i]], e))
+			continue
+		}
+		removed++
+	}
+
+	u.updateKeys(ctx)
+	// Set error after updating keys; updating keys clears any error.
+	if len(errs) > 0 {
+		u.setError(errors.Join(errs...))
+	}
+	if removed > 0 {
+		u.setStatus(fmt.Sprintf("Removed %s.", keysText(removed)))
+	}
+}
@@ -0,0 +1,212 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package optionsui
+
+import (
+	"strings"
+	"syscall/js"
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/dom"
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/go-cmp/cmp"
+)
+
+// addKeys configures keys with the specified names, and refreshes the
+// displayed keys.
+func addKeys(ctx jsutil.AsyncContext, t *testing.T, h *testHarness, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		if err := h.Client.Add(ctx, name, testdata.WithoutPassphrase.Private, keys.AddOptions{AllowDuplicate: true}); err != nil {
+			t.Fatalf("failed to add key %s: %v", name, err)
+		}
+	}
+	h.UI.updateKeys(ctx)
+}
+
+// displayedNames returns the names of the displayed keys.
+func displayedNames(h *testHarness) []string {
+	var result []string
+	for _, k := range h.UI.displayedKeys() {
+		result = append(result, k.Name)
+	}
+	return result
+}
+
+// shiftClick simulates clicking on an element while holding the Shift key.
+func shiftClick(o js.Value) {
+	view := o.Get("ownerDocument").Get("defaultView")
+	evt := view.Get("MouseEvent").New("click", map[string]any{"bubbles": true, "shiftKey": true})
+	o.Call("dispatchEvent", evt)
+}
+
+func TestRemoveSelected(t *testing.T) {
+	t.Parallel()
+
+	h := newHarness()
+	defer h.Release()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		h.waitLoaded(ctx)
+		addKeys(ctx, t, h, "key-a", "key-b", "key-c")
+
+		button := h.dom.GetElement("removeSelected")
+		if !button.Get("disabled").Bool() {
+			t.Errorf("remove selected enabled without a selection")
+		}
+
+		a := findKey(h.UI.displayedKeys(), "key-a")
+		c := findKey(h.UI.displayedKeys(), "key-c")
+		dom.DoClick(h.dom.GetElement(selectCheckboxID(a)))
+		dom.DoClick(h.dom.GetElement(selectCheckboxID(c)))
+		if diff := cmp.Diff(dom.TextContent(button), "Remove Selected (2)"); diff != "" {
+			t.Errorf("incorrect button text; -got +want: %s", diff)
+		}
+		if button.Get("disabled").Bool() {
+			t.Errorf("remove selected disabled with a selection")
+		}
+
+		// A single confirmation lists every selected key.
+		dialog := h.dom.GetElement("removeManyDialog")
+		dom.DoClick(button)
+		h.waitDialogOpen(ctx, dialog)
+		if diff := cmp.Diff(dom.TextContent(h.dom.GetElement("removeManyCount")), "2 keys"); diff != "" {
+			t.Errorf("incorrect count; -got +want: %s", diff)
+		}
+		if diff := cmp.Diff(dom.TextContent(h.dom.GetElement("removeManyList")), "key-akey-c"); diff != "" {
+			t.Errorf("incorrect names; -got +want: %s", diff)
+		}
+		dom.DoClick(h.dom.GetElement("removeManyYes"))
+		h.waitDialogClosed(ctx, dialog)
+		mustPoll(ctx, func() bool { return cmp.Equal(displayedNames(h), []string{"key-b"}) })
+
+		// The selection is cleared once the keys are removed.
+		if diff := cmp.Diff(dom.TextContent(button), "Remove Selected"); diff != "" {
+			t.Errorf("incorrect button text; -got +want: %s", diff)
+		}
+		if !button.Get("disabled").Bool() {
+			t.Errorf("remove selected enabled after removal")
+		}
+		if diff := cmp.Diff(dom.TextContent(h.UI.statusText), "Removed 2 keys."); diff != "" {
+			t.Errorf("incorrect status; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestRemoveSelectedCancelled(t *testing.T) {
+	t.Parallel()
+
+	h := newHarness()
+	defer h.Release()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		h.waitLoaded(ctx)
+		addKeys(ctx, t, h, "key-a", "key-b")
+
+		a := findKey(h.UI.displayedKeys(), "key-a")
+		dom.DoClick(h.dom.GetElement(selectCheckboxID(a)))
+
+		dialog := h.dom.GetElement("removeManyDialog")
+		dom.DoClick(h.dom.GetElement("removeSelected"))
+		h.waitDialogOpen(ctx, dialog)
+		dom.DoClick(h.dom.GetElement("removeManyNo"))
+		h.waitDialogClosed(ctx, dialog)
+
+		// Nothing is removed, and the selection remains.
+		if diff := cmp.Diff(displayedNames(h), []string{"key-a", "key-b"}); diff != "" {
+			t.Errorf("incorrect keys; -got +want: %s", diff)
+		}
+		if !dom.Checked(h.dom.GetElement(selectCheckboxID(a))) {
+			t.Errorf("selection cleared after cancelling")
+		}
+	})
+}
+
+func TestRemoveSelectedFailure(t *testing.T) {
+	t.Parallel()
+
+	h := newHarness()
+	defer h.Release()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		h.waitLoaded(ctx)
+		addKeys(ctx, t, h, "key-a", "key-b")
+
+		a := findKey(h.UI.displayedKeys(), "key-a")
+		b := findKey(h.UI.displayedKeys(), "key-b")
+		dom.DoClick(h.dom.GetElement(selectCheckboxID(a)))
+		dom.DoClick(h.dom.GetElement(selectCheckboxID(b)))
+
+		dialog := h.dom.GetElement("removeManyDialog")
+		dom.DoClick(h.dom.GetElement("removeSelected"))
+		h.waitDialogOpen(ctx, dialog)
+		// The key is removed elsewhere before the user confirms.
+		if err := h.Client.Remove(ctx, a); err != nil {
+			t.Fatalf("failed to remove key: %v", err)
+		}
+		dom.DoClick(h.dom.GetElement("removeManyYes"))
+		h.waitDialogClosed(ctx, dialog)
+
+		// The remaining key is removed, and the failure is reported.
+		mustPoll(ctx, func() bool { return len(displayedNames(h)) == 0 })
+		mustPoll(ctx, func() bool { return strings.Contains(dom.TextContent(h.UI.errorText), "failed to remove key key-a") })
+		if diff := cmp.Diff(dom.TextContent(h.UI.statusText), "Removed 1 key."); diff != "" {
+			t.Errorf("incorrect status; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestSelectRange(t *testing.T) {
+	t.Parallel()
+
+	h := newHarness()
+	defer h.Release()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		h.waitLoaded(ctx)
+		addKeys(ctx, t, h, "key-a", "key-b", "key-c", "key-d")
+
+		selected := func() []string {
+			var result []string
+			for _, id := range h.UI.selectedIDs() {
+				result = append(result, h.UI.keyByID(id).Name)
+			}
+			return result
+		}
+
+		// Holding Shift selects every key between the two clicked.
+		dom.DoClick(h.dom.GetElement(selectCheckboxID(findKey(h.UI.displayedKeys(), "key-b"))))
+		shiftClick(h.dom.GetElement(selectCheckboxID(findKey(h.UI.displayedKeys(), "key-d"))))
+		if diff := cmp.Diff(selected(), []string{"key-b", "key-c", "key-d"}); diff != "" {
+			t.Errorf("incorrect selection; -got +want: %s", diff)
+		}
+		for _, name := range []string{"key-b", "key-c", "key-d"} {
+			if !dom.Checked(h.dom.GetElement(selectCheckboxID(findKey(h.UI.displayedKeys(), name)))) {
+				t.Errorf("checkbox for %s not checked", name)
+			}
+		}
+
+		// Likewise, a range may be deselected.
+		shiftClick(h.dom.GetElement(selectCheckboxID(findKey(h.UI.displayedKeys(), "key-c"))))
+		if diff := cmp.Diff(selected(), []string{"key-b"}); diff != "" {
+			t.Errorf("incorrect selection; -got +want: %s", diff)
+		}
+	})
+}
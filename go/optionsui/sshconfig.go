@@ -0,0 +1,80 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package optionsui
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"syscall/js"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+)
+
+const (
+	// sshConfigHost is the placeholder for the host in the generated
+	// ~/.ssh/config snippet, which the user replaces with their server.
+	sshConfigHost = "host.example.com"
+)
+
+var (
+	// unsafeFileChars matches characters that are replaced when a key's
+	// name is used as a file name.
+	unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// sshConfigFile returns the path of the file in which the public key for the
+// supplied key is to be saved, relative to the user's home directory.
+func sshConfigFile(k *displayedKey) string {
+	name := strings.Trim(unsafeFileChars.ReplaceAllString(k.Name, "_"), "_.")
+	if name == "" {
+		name = "chrome-ssh-agent"
+	}
+	return fmt.Sprintf("~/.ssh/%s.pub", name)
+}
+
+// sshConfigSnippet returns a block for ~/.ssh/config that uses the supplied
+// key to connect to a host.
+//
+// The private key is held by the agent rather than on disk, so the block
+// points IdentityAgent at the agent's socket. IdentityFile names a file
+// holding the public key, which, combined with IdentitiesOnly, restricts ssh
+// to offering only this key from the agent.
+func sshConfigSnippet(k *displayedKey) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Key '%s' in SSH Agent for Google Chrome.\n", k.Name)
+	if k.Fingerprint != "" {
+		fmt.Fprintf(&b, "# Fingerprint: %s\n", k.Fingerprint)
+	}
+	fmt.Fprintf(&b, "# Save the public key to %s, and replace %s with the server's name.\n", sshConfigFile(k), sshConfigHost)
+	fmt.Fprintf(&b, "Host %s\n", sshConfigHost)
+	fmt.Fprintf(&b, "    IdentityAgent SSH_AUTH_SOCK\n")
+	fmt.Fprintf(&b, "    IdentityFile %s\n", sshConfigFile(k))
+	fmt.Fprintf(&b, "    IdentitiesOnly yes\n")
+	return b.String()
+}
+
+// copySSHConfig copies a ~/.ssh/config snippet using the supplied key to the
+// clipboard. On success, a confirmation is briefly displayed after btn.
+func (u *UI) copySSHConfig(ctx jsutil.AsyncContext, k *displayedKey, btn js.Value) {
+	if err := u.writeClipboard(ctx, sshConfigSnippet(k)); err != nil {
+		u.setError(fmt.Errorf("failed to copy SSH config snippet: %w", err))
+		return
+	}
+	u.setError(nil)
+	u.showCopied(btn)
+}
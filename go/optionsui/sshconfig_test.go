@@ -0,0 +1,101 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package optionsui
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSSHConfigSnippet(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		key         *displayedKey
+		want        string
+	}{
+		{
+			description: "key with fingerprint",
+			key: &displayedKey{
+				Name:        "work-key",
+				Fingerprint: "SHA256:1B2M2Y8AsgTpgAmY7PhCfg",
+			},
+			want: `# Key 'work-key' in SSH Agent for Google Chrome.
+# Fingerprint: SHA256:1B2M2Y8AsgTpgAmY7PhCfg
+# Save the public key to ~/.ssh/work-key.pub, and replace host.example.com with the server's name.
+Host host.example.com
+    IdentityAgent SSH_AUTH_SOCK
+    IdentityFile ~/.ssh/work-key.pub
+    IdentitiesOnly yes
+`,
+		},
+		{
+			description: "key without fingerprint",
+			key: &displayedKey{
+				Name: "encrypted-key",
+			},
+			want: `# Key 'encrypted-key' in SSH Agent for Google Chrome.
+# Save the public key to ~/.ssh/encrypted-key.pub, and replace host.example.com with the server's name.
+Host host.example.com
+    IdentityAgent SSH_AUTH_SOCK
+    IdentityFile ~/.ssh/encrypted-key.pub
+    IdentitiesOnly yes
+`,
+		},
+		{
+			description: "name unsafe for file",
+			key: &displayedKey{
+				Name:        "../my prod key/",
+				Fingerprint: "SHA256:1B2M2Y8AsgTpgAmY7PhCfg",
+			},
+			want: `# Key '../my prod key/' in SSH Agent for Google Chrome.
+# Fingerprint: SHA256:1B2M2Y8AsgTpgAmY7PhCfg
+# Save the public key to ~/.ssh/my_prod_key.pub, and replace host.example.com with the server's name.
+Host host.example.com
+    IdentityAgent SSH_AUTH_SOCK
+    IdentityFile ~/.ssh/my_prod_key.pub
+    IdentitiesOnly yes
+`,
+		},
+		{
+			description: "name without safe characters",
+			key: &displayedKey{
+				Name: "клю́ч",
+			},
+			want: `# Key 'клю́ч' in SSH Agent for Google Chrome.
+# Save the public key to ~/.ssh/chrome-ssh-agent.pub, and replace host.example.com with the server's name.
+Host host.example.com
+    IdentityAgent SSH_AUTH_SOCK
+    IdentityFile ~/.ssh/chrome-ssh-agent.pub
+    IdentitiesOnly yes
+`,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			if diff := cmp.Diff(sshConfigSnippet(tc.key), tc.want); diff != "" {
+				t.Errorf("incorrect snippet; -got +want: %s", diff)
+			}
+		})
+	}
+}
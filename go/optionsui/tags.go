@@ -0,0 +1,294 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package optionsui
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall/js"
+	"time"
+
+	"github.com/google/chrome-ssh-agent/go/dom"
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	"github.com/google/chrome-ssh-agent/go/keys"
+)
+
+const (
+	// ungroupedTag denotes the group of keys without any tags.
+	ungroupedTag = ""
+	// ungroupedName is the name displayed for the group of keys without
+	// any tags.
+	ungroupedName = "Ungrouped"
+
+	// groupHeaderHTML is the template for the header displayed above each
+	// group of keys. The header spans every column of the keys table.
+	groupHeaderHTML = `
+		<tr class="keyGroup">
+			<th colspan="7">
+				<button type="button" class="keyGroupToggle">
+					<span class="keyGroupArrow"></span>
+					<span class="keyGroupName"></span>
+					<span class="keyGroupCount"></span>
+				</button>
+			</th>
+		</tr>`
+)
+
+// keyGroup is a set of keys displayed together under a common tag.
+type keyGroup struct {
+	// Tag is the tag shared by the keys, or ungroupedTag for keys without
+	// any tags.
+	Tag string
+	// Keys are the keys in the group, in the order in which they are
+	// displayed.
+	Keys []*displayedKey
+}
+
+// keyTags returns the tags of the groups in which the key is displayed.
+func keyTags(k *displayedKey) []string {
+	if len(k.Tags) == 0 {
+		return []string{ungroupedTag}
+	}
+	return k.Tags
+}
+
+// hasTags indicates if any of the keys have tags, in which case keys are
+// displayed in groups.
+func hasTags(disp []*displayedKey) bool {
+	return slices.ContainsFunc(disp, func(k *displayedKey) bool { return len(k.Tags) > 0 })
+}
+
+// groupKeys returns the groups in which the keys are displayed. Keys with
+// several tags appear in the group for each, and keys without any tags appear
+// in a final group for ungroupedTag. Keys retain their relative order within
+// each group.
+func groupKeys(disp []*displayedKey) []*keyGroup {
+	byTag := map[string]*keyGroup{}
+	var result []*keyGroup
+	for _, k := range disp {
+		for _, tag := range keyTags(k) {
+			g := byTag[tag]
+			if g == nil {
+				g = &keyGroup{Tag: tag}
+				byTag[tag] = g
+				result = append(result, g)
+			}
+			g.Keys = append(g.Keys, k)
+		}
+	}
+	slices.SortStableFunc(result, func(a, b *keyGroup) int {
+		switch {
+		case a.Tag == b.Tag:
+			return 0
+		case a.Tag == ungroupedTag:
+			return 1
+		case b.Tag == ungroupedTag:
+			return -1
+		}
+		return keys.CompareNames(a.Tag, b.Tag)
+	})
+	return result
+}
+
+// groupName returns the name displayed for the group with the specified tag.
+func groupName(tag string) string {
+	if tag == ungroupedTag {
+		return ungroupedName
+	}
+	return tag
+}
+
+// groupToggleID returns the value of the 'id' attribute to be assigned to the
+// button that collapses or expands the group with the specified tag.
+func groupToggleID(tag string) string {
+	return fmt.Sprintf("group-%s", tag)
+}
+
+// groupCollapsed indicates if the group with the specified tag is collapsed.
+func (u *UI) groupCollapsed(tag string) bool {
+	return slices.Contains(u.collapsedTags, tag)
+}
+
+// hiddenInGroups indicates if the key is not displayed because every group
+// in which it appears is collapsed.
+func (u *UI) hiddenInGroups(k *displayedKey) bool {
+	if !hasTags(u.keys) {
+		return false
+	}
+	for _, tag := range keyTags(k) {
+		if !u.groupCollapsed(tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// appendKeyRows appends rows displaying the keys to the table of keys. If
+// grouped is true, keys are displayed under a header for each of their tags,
+// omitting those in collapsed groups.
+func (u *UI) appendKeyRows(disp []*displayedKey, grouped bool, now time.Time) {
+	if !grouped {
+		for _, k := range disp {
+			u.appendKeyRow(k, now, true)
+		}
+		return
+	}
+
+	tmpl := u.dom.ParseHTML(groupHeaderHTML)
+	rendered := map[*displayedKey]bool{}
+	for _, g := range groupKeys(disp) {
+		collapsed := u.groupCollapsed(g.Tag)
+		u.appendGroupHeader(dom.CloneNode(tmpl), g, collapsed)
+		if collapsed {
+			continue
+		}
+		for _, k := range g.Keys {
+			u.appendKeyRow(k, now, !rendered[k])
+			rendered[k] = true
+		}
+	}
+}
+
+// appendGroupHeader appends the header for a group of keys to the table of
+// keys. header is a copy of the header template, whose slots are filled in.
+func (u *UI) appendGroupHeader(header js.Value, g *keyGroup, collapsed bool) {
+	tag := g.Tag
+	dom.AppendChild(u.keysData, header, func(row js.Value) {
+		btn := dom.QuerySelector(row, ".keyGroupToggle")
+		dom.SetAttribute(btn, "id", groupToggleID(tag))
+		dom.SetAttribute(btn, "aria-expanded", strconv.FormatBool(!collapsed))
+		arrow := "\u25BC"
+		if collapsed {
+			arrow = "\u25B6"
+		}
+		dom.AppendChild(dom.QuerySelector(row, ".keyGroupArrow"), u.dom.NewText(arrow), nil)
+		dom.AppendChild(dom.QuerySelector(row, ".keyGroupName"), u.dom.NewText(groupName(tag)), nil)
+		dom.AppendChild(dom.QuerySelector(row, ".keyGroupCount"), u.dom.NewText(fmt.Sprintf("(%d)", len(g.Keys))), nil)
+		u.keysCleanup.Add(dom.OnClick(btn, func(ctx jsutil.AsyncContext, evt dom.Event) {
+			u.toggleGroup(ctx, tag)
+		}))
+	})
+}
+
+// toggleGroup collapses or expands the group with the specified tag, and
+// saves its state in the user's preferences.
+func (u *UI) toggleGroup(ctx jsutil.AsyncContext, tag string) {
+	prefs, err := u.mgr.Preferences(ctx)
+	if err != nil {
+		u.setError(fmt.Errorf("failed to get preferences: %w", err))
+		return
+	}
+
+	var collapsed []string
+	for _, t := range prefs.CollapsedTags {
+		if t != tag {
+			collapsed = append(collapsed, t)
+		}
+	}
+	if len(collapsed) == len(prefs.CollapsedTags) {
+		collapsed = append(collapsed, tag)
+	}
+	prefs.CollapsedTags = collapsed
+	if err := u.mgr.SetPreferences(ctx, prefs); err != nil {
+		u.setError(fmt.Errorf("failed to save preferences: %w", err))
+		return
+	}
+	u.setError(nil)
+	u.setCollapsedTags(ctx, prefs.CollapsedTags)
+}
+
+// setCollapsedTags selects the groups of keys that are collapsed, refreshing
+// the displayed keys if they changed.
+func (u *UI) setCollapsedTags(ctx jsutil.AsyncContext, tags []string) {
+	if slices.Equal(tags, u.collapsedTags) {
+		return
+	}
+	u.collapsedTags = tags
+	u.updateKeys(ctx)
+}
+
+// parseTags parses the comma-separated list of tags supplied by the user.
+func parseTags(s string) []string {
+	var result []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// promptTags displays a dialog prompting the user for the tags by which a key
+// is grouped, separated by commas.
+func (u *UI) promptTags(ctx jsutil.AsyncContext, id keys.ID) (ok bool, tags []string) {
+	k := u.keyByID(id)
+	if k == nil {
+		u.setError(fmt.Errorf("failed to edit tags for key ID %s: not found", id))
+		return
+	}
+
+	dialogElem := u.dom.GetElement("tagsDialog")
+	dialog := dom.NewDialog(dialogElem)
+	form := u.dom.GetElement("tagsForm")
+	name := u.dom.GetElement("tagsName")
+	field := u.dom.GetElement("tagsList")
+	okButton := u.dom.GetElement("tagsOk")
+	cancel := u.dom.GetElement("tagsCancel")
+	dom.AppendChild(name, u.dom.NewText(k.Name), nil)
+	dom.SetValue(field, strings.Join(k.Tags, ", "))
+
+	sig := newSignal()
+	var cleanup jsutil.CleanupFuncs
+	cleanup.Add(dom.OnSubmit(form, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		ok = true
+		tags = parseTags(dom.Value(field))
+		dialog.Close()
+		sig.Notify()
+	}))
+	cleanup.Add(dom.OnClick(cancel, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		dialog.Close()
+		sig.Notify()
+	}))
+	cleanup.Add(onDialogKeys(field, okButton, cancel))
+	cleanup.Add(dialog.OnClose(func(ctx jsutil.AsyncContext, evt dom.Event) {
+		dom.RemoveChildren(name)
+		dom.SetValue(field, "")
+		cleanup.Do()
+	}))
+
+	cleanup.Add(u.showModal(dialog, dialogElem, field))
+	sig.Wait(ctx)
+	return
+}
+
+// editTags changes the tags by which the key with the specified ID is grouped.
+// A dialog prompts the user for the tags.
+func (u *UI) editTags(ctx jsutil.AsyncContext, id keys.ID) {
+	ok, tags := u.promptTags(ctx, id)
+	if !ok {
+		return
+	}
+
+	if err := u.mgr.SetTags(ctx, id, tags); err != nil {
+		u.setError(fmt.Errorf("failed to set tags: %w", err))
+		return
+	}
+	u.setError(nil)
+	u.updateKeys(ctx)
+}
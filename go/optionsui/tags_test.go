@@ -0,0 +1,207 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package optionsui
+
+import (
+	"fmt"
+	"sort"
+	"syscall/js"
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/dom"
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	"github.com/google/chrome-ssh-agent/go/keys"
+	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestGroupKeys(t *testing.T) {
+	t.Parallel()
+
+	work := &displayedKey{Name: "work-key", Tags: []string{"work"}}
+	shared := &displayedKey{Name: "shared-key", Tags: []string{"personal", "work"}}
+	plain := &displayedKey{Name: "plain-key"}
+
+	type group struct {
+		Tag  string
+		Keys []string
+	}
+	var got []group
+	for _, g := range groupKeys([]*displayedKey{work, plain, shared}) {
+		var names []string
+		for _, k := range g.Keys {
+			names = append(names, k.Name)
+		}
+		got = append(got, group{Tag: g.Tag, Keys: names})
+	}
+	want := []group{
+		{Tag: "personal", Keys: []string{"shared-key"}},
+		{Tag: "work", Keys: []string{"work-key", "shared-key"}},
+		{Tag: ungroupedTag, Keys: []string{"plain-key"}},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("incorrect groups; -got +want: %s", diff)
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	t.Parallel()
+
+	if diff := cmp.Diff(parseTags(" work, ,personal ,"), []string{"work", "personal"}); diff != "" {
+		t.Errorf("incorrect tags; -got +want: %s", diff)
+	}
+	if diff := cmp.Diff(parseTags(""), []string(nil), cmpopts.EquateEmpty()); diff != "" {
+		t.Errorf("incorrect tags; -got +want: %s", diff)
+	}
+}
+
+// renderedGroups returns the names of the keys displayed under each group
+// header, keyed by group name.
+func renderedGroups(keysData js.Value) map[string][]string {
+	result := map[string][]string{}
+	var group string
+	rows := keysData.Get("children")
+	for i := 0; i < rows.Length(); i++ {
+		row := rows.Index(i)
+		if name := dom.QuerySelector(row, ".keyGroupName"); !name.IsNull() {
+			group = dom.TextContent(name)
+			result[group] = []string{}
+			continue
+		}
+		result[group] = append(result[group], dom.TextContent(dom.QuerySelector(row, ".keyName")))
+	}
+	for _, names := range result {
+		sort.Strings(names)
+	}
+	return result
+}
+
+func TestTagGroups(t *testing.T) {
+	t.Parallel()
+
+	h := newHarness()
+	defer h.Release()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		h.waitLoaded(ctx)
+
+		for _, k := range []struct {
+			name string
+			tags []string
+		}{
+			{name: "work-key", tags: []string{"work"}},
+			{name: "shared-key", tags: []string{"work", "personal"}},
+			{name: "plain-key"},
+		} {
+			if err := h.Client.Add(ctx, k.name, testdata.WithoutPassphrase.Private, keys.AddOptions{AllowDuplicate: true, Tags: k.tags}); err != nil {
+				t.Fatalf("failed to add key %s: %v", k.name, err)
+			}
+		}
+		h.UI.updateKeys(ctx)
+
+		// Keys with several tags appear in the group for each.
+		want := map[string][]string{
+			"personal":    {"shared-key"},
+			"work":        {"shared-key", "work-key"},
+			ungroupedName: {"plain-key"},
+		}
+		if diff := cmp.Diff(renderedGroups(h.keysData), want); diff != "" {
+			t.Errorf("incorrect groups; -got +want: %s", diff)
+		}
+
+		// Element IDs remain unique, and refer to the first row
+		// displaying the key.
+		shared := findKey(h.UI.displayedKeys(), "shared-key")
+		matches := h.keysData.Call("querySelectorAll", fmt.Sprintf("[id=%q]", buttonID(LoadButton, shared)))
+		if diff := cmp.Diff(matches.Length(), 1); diff != "" {
+			t.Errorf("incorrect number of load buttons; -got +want: %s", diff)
+		}
+
+		// Collapsing a group hides its keys, and is saved in the
+		// user's preferences.
+		dom.DoClick(h.dom.GetElement(groupToggleID("work")))
+		mustPoll(ctx, func() bool { return len(renderedGroups(h.keysData)["work"]) == 0 })
+		prefs, err := h.Client.Preferences(ctx)
+		if err != nil {
+			t.Fatalf("failed to read preferences: %v", err)
+		}
+		if diff := cmp.Diff(prefs.CollapsedTags, []string{"work"}); diff != "" {
+			t.Errorf("incorrect collapsed tags; -got +want: %s", diff)
+		}
+		if diff := cmp.Diff(dom.GetAttribute(h.dom.GetElement(groupToggleID("work")), "aria-expanded"), "false"); diff != "" {
+			t.Errorf("incorrect expanded state; -got +want: %s", diff)
+		}
+		// The key remains available in its other group.
+		if diff := cmp.Diff(renderedGroups(h.keysData)["personal"], []string{"shared-key"}); diff != "" {
+			t.Errorf("incorrect personal group; -got +want: %s", diff)
+		}
+		if h.dom.GetElement(buttonID(LoadButton, shared)).IsNull() {
+			t.Errorf("load button missing for key in expanded group")
+		}
+
+		// Expanding the group displays its keys again.
+		dom.DoClick(h.dom.GetElement(groupToggleID("work")))
+		mustPoll(ctx, func() bool { return len(renderedGroups(h.keysData)["work"]) == 2 })
+
+		// Tags are edited using a dialog.
+		plain := findKey(h.UI.displayedKeys(), "plain-key")
+		dialog := h.dom.GetElement("tagsDialog")
+		dom.DoClick(h.dom.GetElement(buttonID(TagsButton, plain)))
+		h.waitDialogOpen(ctx, dialog)
+		dom.SetValue(h.dom.GetElement("tagsList"), "home, work")
+		dom.DoClick(h.dom.GetElement("tagsOk"))
+		h.waitDialogClosed(ctx, dialog)
+		mustPoll(ctx, func() bool {
+			k := h.UI.keyByID(plain)
+			return k != nil && cmp.Equal(k.Tags, []string{"home", "work"})
+		})
+		want = map[string][]string{
+			"home":     {"plain-key"},
+			"personal": {"shared-key"},
+			"work":     {"plain-key", "shared-key", "work-key"},
+		}
+		if diff := cmp.Diff(renderedGroups(h.keysData), want); diff != "" {
+			t.Errorf("incorrect groups after editing tags; -got +want: %s", diff)
+		}
+	})
+}
+
+func TestUngroupedKeys(t *testing.T) {
+	t.Parallel()
+
+	h := newHarness()
+	defer h.Release()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		h.waitLoaded(ctx)
+
+		if err := h.Client.Add(ctx, "plain-key", testdata.WithoutPassphrase.Private, keys.AddOptions{}); err != nil {
+			t.Fatalf("failed to add key: %v", err)
+		}
+		h.UI.updateKeys(ctx)
+
+		// No group headers are displayed unless some key has tags.
+		if !h.dom.GetElement(groupToggleID(ungroupedTag)).IsNull() {
+			t.Errorf("group header displayed for untagged keys")
+		}
+		if diff := cmp.Diff(renderedGroups(h.keysData), map[string][]string{"": {"plain-key"}}); diff != "" {
+			t.Errorf("incorrect groups; -got +want: %s", diff)
+		}
+	})
+}
@@ -19,34 +19,256 @@
 package optionsui
 
 import (
+	"bytes"
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"math"
 	"math/big"
+	"net/url"
+	"path"
+	"slices"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall/js"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/chrome-ssh-agent/go/dom"
 	"github.com/google/chrome-ssh-agent/go/jsutil"
 	"github.com/google/chrome-ssh-agent/go/keys"
 	"github.com/google/chrome-ssh-agent/go/keys/testdata"
+	"github.com/google/chrome-ssh-agent/go/reltime"
+	"github.com/google/chrome-ssh-agent/go/storage"
 	"github.com/google/go-cmp/cmp"
 )
 
 // UI implements the behavior underlying the user interface for the extension's
 // options.
 type UI struct {
-	mgr         keys.Manager
-	dom         *dom.Doc
-	addButton   js.Value
-	loadingText js.Value
-	errorText   js.Value
-	keysData    js.Value
-	keys        []*displayedKey
-	cleanup     *jsutil.CleanupFuncs
+	// messages translates user-facing strings.
+	messages catalog
+	// mgr is replaced by a new client if the user reconnects to an
+	// unresponsive agent.
+	mgr                  keys.Manager
+	dom                  *dom.Doc
+	addButton            js.Value
+	loadAllButton        js.Value
+	unloadAllButton      js.Value
+	removeSelectedButton js.Value
+	exportButton         js.Value
+	exportPublicButton   js.Value
+	importButton         js.Value
+	importFile           js.Value
+	confirmUnload        js.Value
+	confirmLoad          js.Value
+	notifyLoad           js.Value
+	keyStorage           js.Value
+	idleUnload           js.Value
+	passphraseCache      js.Value
+	forgetButton         js.Value
+	maxLoaded            js.Value
+	minKeyBits           js.Value
+	refreshSecs          js.Value
+	evictLRU             js.Value
+	showKeyMaterial      js.Value
+	showMD5              js.Value
+	compactView          js.Value
+	keysPerPage          js.Value
+	nativeHost           js.Value
+	nativeStatus         js.Value
+	debugLogging         js.Value
+	debugConsole         js.Value
+	storageUsage         js.Value
+	loadLifetime         js.Value
+	loadOneShot          js.Value
+	loadingText          js.Value
+	statusText           js.Value
+	pendingPane          js.Value
+	pendingText          js.Value
+	pendingCancel        js.Value
+	errorText            js.Value
+	agentStatus          js.Value
+	corruptKeys          js.Value
+	corruptText          js.Value
+	removeCorrupt        js.Value
+	undoRemove           js.Value
+	undoRemoveText       js.Value
+	undoButton           js.Value
+	undoDismiss          js.Value
+	unreachable          js.Value
+	unreachableText      js.Value
+	reloadButton         js.Value
+	reconnectButton      js.Value
+	restartButton        js.Value
+	selfTestButton       js.Value
+	selfTestLog          js.Value
+	selfTestResults      js.Value
+	agentExtensions      js.Value
+	consolePane          js.Value
+	consoleInput         js.Value
+	consoleRun           js.Value
+	consoleOutput        js.Value
+	keysBlobHeader       js.Value
+	nameHeader           js.Value
+	typeHeader           js.Value
+	keysTable            js.Value
+	keysData             js.Value
+	keysPager            js.Value
+	prevPage             js.Value
+	nextPage             js.Value
+	pageText             js.Value
+	externalData         js.Value
+	unloadedData         js.Value
+	clearActivity        js.Value
+	activityData         js.Value
+	activityEmpty        js.Value
+	options              js.Value
+	lockScreen           js.Value
+	unlockField          js.Value
+	unlockButton         js.Value
+	unlockError          js.Value
+	masterButton         js.Value
+	lockButton           js.Value
+	lockAgentButton      js.Value
+	unlockAgentButton    js.Value
+	agentLockedText      js.Value
+	keys                 []*displayedKey
+	// keysCleanup keeps track of any cleanup required before removing the
+	// displayed keys from the UI.
+	keysCleanup *jsutil.CleanupFuncs
+	// keyMaterialShown indicates that the public key material is displayed
+	// for each key, as selected in the user's preferences.
+	keyMaterialShown bool
+	// md5Shown indicates that the legacy MD5 fingerprint is displayed for
+	// each key, as selected in the user's preferences.
+	md5Shown bool
+	// compact indicates that keys are displayed in dense, single-line
+	// rows, as selected in the user's preferences.
+	compact bool
+	// pageSize is the maximum number of keys displayed at once, as
+	// selected in the user's preferences. Zero indicates that all keys
+	// are displayed.
+	pageSize int
+	// page is the index of the page of keys currently displayed.
+	page int
+	// weakKeyBits is the size below which RSA and DSA keys are flagged as
+	// weak, as selected in the user's preferences.
+	weakKeyBits int
+	// sortColumn is the column by which keys are sorted, as selected by
+	// the user. Empty indicates the order arranged by the user.
+	sortColumn string
+	// sortDesc indicates that keys are sorted by sortColumn in descending
+	// order.
+	sortDesc bool
+	// collapsedTags are the tags whose groups of keys are collapsed, as
+	// selected by the user.
+	collapsedTags []string
+	// selected are the IDs of the keys selected by the user, for which
+	// actions (e.g., removal) may be performed at once.
+	selected map[keys.ID]bool
+	// lastSelected is the ID of the key whose checkbox was most recently
+	// clicked, from which a range of keys may be selected.
+	lastSelected keys.ID
+	// loadLimit is the maximum number of keys that may be loaded at once,
+	// as selected in the user's preferences. Zero indicates no limit.
+	loadLimit uint32
+	// undoWindow is the period after a key is removed during which the
+	// removal may be undone. Zero indicates that removal may not be
+	// undone.
+	undoWindow time.Duration
+	// writeClipboard writes text to the clipboard.
+	writeClipboard func(ctx jsutil.AsyncContext, text string) error
+	// dragging is the ID of the key currently being dragged to a new
+	// position.
+	dragging keys.ID
+	// lastFocus is the element that had focus before the currently
+	// displayed dialog was shown; focus is restored to it once the dialog
+	// is closed.
+	lastFocus js.Value
+	cleanup   *jsutil.CleanupFuncs
+	// refreshDebounce coalesces the bursts of storage changes made by bulk
+	// operations into a single refresh.
+	refreshDebounce *jsutil.Debouncer
+
+	// refreshMu guards fields below.
+	refreshMu sync.Mutex
+	// refreshing indicates that the UI is being refreshed in response to
+	// a change in storage.
+	refreshing bool
+	// refreshPending indicates that storage changed again during the
+	// current refresh, so another is required.
+	refreshPending bool
+	// refreshInterval is the interval at which the displayed keys are
+	// refreshed while the page is visible.
+	refreshInterval time.Duration
+	// refreshGen identifies the current periodic refresh, so that it
+	// stops once the interval changes.
+	refreshGen int
+	// countingDown indicates that the remaining lifetime of the displayed
+	// keys is being counted down.
+	countingDown bool
+
+	// removeMu guards fields below.
+	removeMu sync.Mutex
+	// undoable is the ID of a key that the user removed, and whose
+	// removal may still be undone. It is InvalidID if no removal may be
+	// undone.
+	undoable keys.ID
+	// undoGen identifies the removal that may be undone, so that the
+	// expiry of an earlier undo window does not withdraw the offer to
+	// undo a later removal.
+	undoGen int
+
+	// pendingMu guards fields below.
+	pendingMu sync.Mutex
+	// cancelPending abandons the request displayed as pending, if any.
+	// Other requests (e.g., refreshing the displayed keys) are unaffected.
+	cancelPending func()
+	// pendingGen identifies the request displayed as pending, so that the
+	// completion of an earlier request does not withdraw the offer to
+	// cancel a later one.
+	pendingGen int
+
+	// reconnectMu guards fields below.
+	reconnectMu sync.Mutex
+	// reconnecting indicates that the UI is attempting to reconnect to an
+	// unreachable agent.
+	reconnecting bool
+	// newManager returns a new client with which to replace mgr when the
+	// user reconnects. Nil if the UI cannot reconnect.
+	newManager func() keys.Manager
+	// reloadExtension restarts the extension. Nil if the UI cannot
+	// restart the extension.
+	reloadExtension func()
+	// released indicates that the UI has been released, so any attempt
+	// to reconnect should stop.
+	released bool
+
+	// lockMu guards fields below.
+	lockMu sync.Mutex
+	// locked indicates that keys are hidden until the user supplies the
+	// master passphrase. The UI is locked until it is known whether a
+	// master passphrase is set.
+	locked bool
+	// masterSet indicates that a master passphrase is set.
+	masterSet bool
+	// lockGen identifies the current unlocked session, so that the idle
+	// watcher for an earlier session does not lock a later one.
+	lockGen int
+	// lockTimeout is the period of inactivity after which the UI is
+	// locked, as selected in the user's preferences. Zero indicates that
+	// the UI is never locked automatically.
+	lockTimeout time.Duration
+	// lastActivity is the time at which the user last interacted with
+	// the UI.
+	lastActivity time.Time
+	// requestHandled indicates that any key requested via the URL has
+	// been loaded, so it is not loaded again when the UI is next unlocked.
+	requestHandled bool
 }
 
 // signal is a primitive that allows one routine to block until notified.
@@ -77,87 +299,523 @@ func (s *signal) Wait(_ jsutil.AsyncContext) {
 
 // New returns a new UI instance that manages keys using the supplied manager.
 // domObj is the DOM instance corresponding to the document in which the Options
-// UI is displayed.
-func New(mgr keys.Manager, domObj *dom.Doc) *UI {
+// UI is displayed. storageChanged is the event fired when storage changes
+// (see storage.DefaultOnChanged()); the UI is refreshed when keys or
+// preferences are changed elsewhere, such as in another tab.
+func New(mgr keys.Manager, domObj *dom.Doc, storageChanged js.Value) *UI {
 	result := &UI{
-		mgr:         mgr,
-		dom:         domObj,
-		addButton:   domObj.GetElement("add"),
-		loadingText: domObj.GetElement("loadingMessage"),
-		errorText:   domObj.GetElement("errorMessage"),
-		keysData:    domObj.GetElement("keysData"),
-		cleanup:     &jsutil.CleanupFuncs{},
+		mgr:                  mgr,
+		dom:                  domObj,
+		addButton:            domObj.GetElement("add"),
+		loadAllButton:        domObj.GetElement("loadAll"),
+		unloadAllButton:      domObj.GetElement("unloadAll"),
+		removeSelectedButton: domObj.GetElement("removeSelected"),
+		exportButton:         domObj.GetElement("export"),
+		exportPublicButton:   domObj.GetElement("exportPublic"),
+		importButton:         domObj.GetElement("import"),
+		importFile:           domObj.GetElement("importFile"),
+		confirmUnload:        domObj.GetElement("confirmUnload"),
+		confirmLoad:          domObj.GetElement("confirmLoad"),
+		notifyLoad:           domObj.GetElement("notifyLoad"),
+		keyStorage:           domObj.GetElement("keyStorage"),
+		idleUnload:           domObj.GetElement("idleUnload"),
+		passphraseCache:      domObj.GetElement("passphraseCache"),
+		maxLoaded:            domObj.GetElement("maxLoaded"),
+		minKeyBits:           domObj.GetElement("minKeyBits"),
+		refreshSecs:          domObj.GetElement("refreshSecs"),
+		refreshInterval:      keys.DefaultRefreshSecs * time.Second,
+		weakKeyBits:          keys.DefaultMinKeyBits,
+		evictLRU:             domObj.GetElement("evictLRU"),
+		forgetButton:         domObj.GetElement("forgetPassphrases"),
+		showKeyMaterial:      domObj.GetElement("showKeyMaterial"),
+		showMD5:              domObj.GetElement("showMD5Fingerprint"),
+		compactView:          domObj.GetElement("compactView"),
+		keysPerPage:          domObj.GetElement("keysPerPage"),
+		nativeHost:           domObj.GetElement("nativeHost"),
+		nativeStatus:         domObj.GetElement("nativeHostStatus"),
+		debugLogging:         domObj.GetElement("debugLogging"),
+		debugConsole:         domObj.GetElement("debugConsole"),
+		storageUsage:         domObj.GetElement("storageUsage"),
+		loadLifetime:         domObj.GetElement("loadLifetime"),
+		loadOneShot:          domObj.GetElement("loadOneShot"),
+		loadingText:          domObj.GetElement("loadingMessage"),
+		statusText:           domObj.GetElement("statusMessage"),
+		pendingPane:          domObj.GetElement("pendingPane"),
+		pendingText:          domObj.GetElement("pendingText"),
+		pendingCancel:        domObj.GetElement("pendingCancel"),
+		errorText:            domObj.GetElement("errorMessage"),
+		agentStatus:          domObj.GetElement("agentStatus"),
+		corruptKeys:          domObj.GetElement("corruptKeys"),
+		corruptText:          domObj.GetElement("corruptKeysMessage"),
+		removeCorrupt:        domObj.GetElement("removeCorrupt"),
+		undoRemove:           domObj.GetElement("undoRemove"),
+		undoRemoveText:       domObj.GetElement("undoRemoveMessage"),
+		undoButton:           domObj.GetElement("undoRemoveButton"),
+		undoDismiss:          domObj.GetElement("undoRemoveDismiss"),
+		undoWindow:           removeUndoWindow,
+		writeClipboard:       domObj.WriteClipboard,
+		unreachable:          domObj.GetElement("unreachable"),
+		unreachableText:      domObj.GetElement("unreachableMessage"),
+		reloadButton:         domObj.GetElement("reload"),
+		reconnectButton:      domObj.GetElement("reconnect"),
+		restartButton:        domObj.GetElement("restartExtension"),
+		selfTestButton:       domObj.GetElement("selfTest"),
+		selfTestLog:          domObj.GetElement("selfTestLog"),
+		selfTestResults:      domObj.GetElement("selfTestResults"),
+		agentExtensions:      domObj.GetElement("agentExtensions"),
+		consolePane:          domObj.GetElement("consolePane"),
+		consoleInput:         domObj.GetElement("consoleCommand"),
+		consoleRun:           domObj.GetElement("consoleRun"),
+		consoleOutput:        domObj.GetElement("consoleOutput"),
+		keysBlobHeader:       domObj.GetElement("keysBlobHeader"),
+		nameHeader:           domObj.GetElement("keysNameHeader"),
+		typeHeader:           domObj.GetElement("keysTypeHeader"),
+		keysTable:            domObj.GetElement("keysTable"),
+		keysData:             domObj.GetElement("keysData"),
+		keysPager:            domObj.GetElement("keysPager"),
+		prevPage:             domObj.GetElement("prevPage"),
+		nextPage:             domObj.GetElement("nextPage"),
+		pageText:             domObj.GetElement("pageText"),
+		externalData:         domObj.GetElement("reconcileExternal"),
+		unloadedData:         domObj.GetElement("reconcileUnloaded"),
+		clearActivity:        domObj.GetElement("clearActivity"),
+		activityData:         domObj.GetElement("activityData"),
+		activityEmpty:        domObj.GetElement("activityEmpty"),
+		options:              domObj.GetElement("options"),
+		lockScreen:           domObj.GetElement("lockScreen"),
+		unlockField:          domObj.GetElement("unlockPassphrase"),
+		unlockButton:         domObj.GetElement("unlock"),
+		unlockError:          domObj.GetElement("unlockError"),
+		masterButton:         domObj.GetElement("setMasterPassphrase"),
+		lockButton:           domObj.GetElement("lockNow"),
+		lockAgentButton:      domObj.GetElement("lockAgent"),
+		unlockAgentButton:    domObj.GetElement("unlockAgent"),
+		agentLockedText:      domObj.GetElement("agentLockedMessage"),
+		locked:               true,
+		keysCleanup:          &jsutil.CleanupFuncs{},
+		cleanup:              &jsutil.CleanupFuncs{},
 	}
+	// Public key material is hidden until preferences indicate otherwise.
+	dom.Hide(result.keysBlobHeader)
 
 	// Add event handlers.
 	cf := result.cleanup
-	// Populate keys on initial display
-	cf.Add(result.dom.OnDOMContentLoaded(result.updateKeys))
+	// Check that the agent is reachable on initial display
+	cf.Add(result.dom.OnDOMContentLoaded(result.checkReachable))
+	// Reload the page to reconnect to the agent on click
+	cf.Add(dom.OnClick(result.reloadButton, func(ctx jsutil.AsyncContext, _ dom.Event) {
+		result.dom.Reload()
+	}))
+	// Rebuild the connection to the agent, or restart the extension, on
+	// click
+	cf.Add(dom.OnClick(result.reconnectButton, result.rebuildManager))
+	cf.Add(dom.OnClick(result.restartButton, result.restartExtension))
+	// Populate keys on initial display, unless a master passphrase is
+	// required first
+	cf.Add(result.dom.OnDOMContentLoaded(result.checkLock))
+	// Unlock on click, or when Enter is pressed
+	cf.Add(dom.OnClick(result.unlockButton, result.unlock))
+	cf.Add(dom.OnKeyDown(result.unlockField, func(evt dom.Event) {
+		if evt.Key() == "Enter" {
+			evt.PreventDefault()
+			dom.DoClick(result.unlockButton)
+		}
+	}))
+	// Lock or unlock the agent on click
+	cf.Add(dom.OnClick(result.lockAgentButton, result.lockAgent))
+	cf.Add(dom.OnClick(result.unlockAgentButton, result.unlockAgent))
+	// Lock on click
+	cf.Add(dom.OnClick(result.lockButton, func(ctx jsutil.AsyncContext, _ dom.Event) {
+		result.lockNow(ctx)
+	}))
+	// Change the master passphrase on click
+	cf.Add(dom.OnClick(result.masterButton, result.setMasterPassphrase))
+	// Track activity, so that the UI is locked once idle
+	for _, body := range domObj.GetElementsByTag("body") {
+		for _, evt := range []string{"click", "keydown", "input"} {
+			cf.Add(dom.On(body, evt, func(ctx jsutil.AsyncContext, _ dom.Event) {
+				result.markActive()
+			}))
+		}
+	}
+	// Populate preferences on initial display
+	cf.Add(result.dom.OnDOMContentLoaded(result.updatePreferences))
+	// Store preferences when changed
+	cf.Add(dom.OnChange(result.confirmUnload, result.savePreferences))
+	cf.Add(dom.OnChange(result.confirmLoad, result.savePreferences))
+	cf.Add(dom.OnChange(result.notifyLoad, result.savePreferences))
+	cf.Add(dom.OnChange(result.idleUnload, result.savePreferences))
+	cf.Add(dom.OnChange(result.passphraseCache, result.savePreferences))
+	cf.Add(dom.OnChange(result.maxLoaded, result.savePreferences))
+	cf.Add(dom.OnChange(result.minKeyBits, result.savePreferences))
+	cf.Add(dom.OnChange(result.refreshSecs, result.savePreferences))
+	cf.Add(dom.OnChange(result.evictLRU, result.savePreferences))
+	cf.Add(dom.OnChange(result.showKeyMaterial, result.savePreferences))
+	cf.Add(dom.OnChange(result.showMD5, result.savePreferences))
+	cf.Add(dom.OnChange(result.compactView, result.savePreferences))
+	cf.Add(dom.OnChange(result.keysPerPage, result.savePreferences))
+	// Abandon the pending request if the agent has not yet answered.
+	cf.Add(dom.OnClick(result.pendingCancel, func(ctx jsutil.AsyncContext, _ dom.Event) {
+		result.cancelPendingRequest()
+	}))
+	// Move between pages of keys on click
+	cf.Add(dom.OnClick(result.prevPage, func(ctx jsutil.AsyncContext, _ dom.Event) {
+		result.setPage(result.page - 1)
+	}))
+	cf.Add(dom.OnClick(result.nextPage, func(ctx jsutil.AsyncContext, _ dom.Event) {
+		result.setPage(result.page + 1)
+	}))
+	cf.Add(dom.OnChange(result.nativeHost, result.savePreferences))
+	cf.Add(dom.OnChange(result.debugLogging, result.savePreferences))
+	cf.Add(dom.OnChange(result.debugConsole, result.savePreferences))
+	// Move keys to the selected storage area when changed
+	cf.Add(dom.OnChange(result.keyStorage, result.setKeyStorage))
 	// Configure new key on click
 	cf.Add(dom.OnClick(result.addButton, result.add))
+	// Sort keys by the column whose header is clicked
+	cf.Add(dom.OnClick(result.nameHeader, func(ctx jsutil.AsyncContext, _ dom.Event) {
+		result.sortBy(ctx, keys.SortByName)
+	}))
+	cf.Add(dom.OnClick(result.typeHeader, func(ctx jsutil.AsyncContext, _ dom.Event) {
+		result.sortBy(ctx, keys.SortByType)
+	}))
+	// Remove unreadable keys on click
+	cf.Add(dom.OnClick(result.removeCorrupt, result.removeCorruptKeys))
+	// Undo the removal of a key on click
+	cf.Add(dom.OnClick(result.undoButton, result.undoRemoveKey))
+	// Withdraw the offer to undo the removal of a key on click
+	cf.Add(dom.OnClick(result.undoDismiss, func(ctx jsutil.AsyncContext, _ dom.Event) {
+		result.expireUndo(ctx, 0)
+	}))
+	// Load all unloaded keys on click
+	cf.Add(dom.OnClick(result.loadAllButton, result.loadAll))
+	// Unload all loaded keys on click
+	cf.Add(dom.OnClick(result.unloadAllButton, result.unloadAll))
+	// Remove all selected keys on click
+	cf.Add(dom.OnClick(result.removeSelectedButton, result.removeSelected))
+	// Forget cached passphrases on click
+	cf.Add(dom.OnClick(result.forgetButton, result.forgetPassphrases))
+	// Clear the activity log on click
+	cf.Add(dom.OnClick(result.clearActivity, result.clearActivityLog))
+	// Export configured keys on click
+	cf.Add(dom.OnClick(result.exportButton, result.export))
+	// Export public keys on click
+	cf.Add(dom.OnClick(result.exportPublicButton, result.exportPublic))
+	// Select a file from which to import keys on click
+	cf.Add(dom.OnClick(result.importButton, func(ctx jsutil.AsyncContext, _ dom.Event) {
+		dom.DoClick(result.importFile)
+	}))
+	// Import keys once a file is selected
+	cf.Add(dom.OnChange(result.importFile, result.importKeys))
+	// Configure a new key from a file dropped on the keys table
+	cf.Add(dom.OnDragOver(result.keysTable, func(ctx jsutil.AsyncContext, _ dom.Event) {
+		dom.AddClass(result.keysTable, "dropTarget")
+	}))
+	cf.Add(dom.On(result.keysTable, "dragleave", func(ctx jsutil.AsyncContext, _ dom.Event) {
+		dom.RemoveClass(result.keysTable, "dropTarget")
+	}))
+	cf.Add(dom.OnDrop(result.keysTable, result.dropFile))
+	// Run the self test on click
+	cf.Add(dom.OnClick(result.selfTestButton, result.selfTest))
+	// Run debug console commands on click, or when Enter is pressed
+	cf.Add(dom.OnClick(result.consoleRun, result.runConsole))
+	cf.Add(dom.OnKeyDown(result.consoleInput, func(evt dom.Event) {
+		if evt.Key() == "Enter" {
+			evt.PreventDefault()
+			dom.DoClick(result.consoleRun)
+		}
+	}))
+	// Refresh when configured keys (in synced or local storage), loaded
+	// keys (in session storage), preferences or the activity log (in local
+	// storage) change
+	result.refreshDebounce = jsutil.NewDebouncer(refreshDelay, result.refresh)
+	cf.Add(result.refreshDebounce.Stop)
+	// Refresh keys as soon as the page becomes visible, since periodic
+	// refreshes are skipped while it is hidden.
+	cf.Add(domObj.OnVisibilityChange(func(ctx jsutil.AsyncContext, hidden bool) {
+		if !hidden {
+			result.updateKeys(ctx)
+		}
+	}))
+	result.watchAgent()
+	for _, area := range []string{"sync", "local", "session"} {
+		cf.Add(storage.OnChanged(storageChanged, area, result.storageChanged))
+	}
 	return result
 }
 
 // Release cleans up any resources when UI is no longer used.
 func (u *UI) Release() {
+	u.reconnectMu.Lock()
+	u.released = true
+	u.reconnectMu.Unlock()
+
 	u.setKeys(nil)
 	u.cleanup.Do()
 }
 
+// refreshDelay is the window within which storage changes are coalesced into
+// a single refresh.
+const refreshDelay = 50 * time.Millisecond
+
+// storageChanged schedules a refresh of the UI after storage is changed.
+// Changes reported within refreshDelay of each other result in a single
+// refresh; a refresh always follows the last change.
+func (u *UI) storageChanged(_ jsutil.AsyncContext, _ map[string]js.Value) {
+	u.refreshDebounce.Call()
+}
+
+// setRefreshInterval sets the interval at which keys are refreshed while the
+// page is visible, restarting the periodic refresh if it changed.
+func (u *UI) setRefreshInterval(interval time.Duration) {
+	u.refreshMu.Lock()
+	changed := interval != u.refreshInterval
+	u.refreshInterval = interval
+	u.refreshMu.Unlock()
+	if changed {
+		u.watchAgent()
+	}
+}
+
+// watchAgent periodically refreshes the displayed keys, so that the remaining
+// lifetime of loaded keys counts down, and keys loaded or unloaded outside the
+// extension are displayed. Changes to storage are already reported, so
+// preferences are not refreshed; this avoids overwriting a preference while
+// the user is editing it. Refreshes are skipped while the page is hidden. Any
+// earlier watcher stops.
+func (u *UI) watchAgent() {
+	u.refreshMu.Lock()
+	u.refreshGen++
+	gen := u.refreshGen
+	interval := u.refreshInterval
+	u.refreshMu.Unlock()
+
+	jsutil.Async(func(ctx jsutil.AsyncContext) (js.Value, error) {
+		for {
+			time.Sleep(interval)
+			u.reconnectMu.Lock()
+			released := u.released
+			u.reconnectMu.Unlock()
+
+			u.refreshMu.Lock()
+			stale := u.refreshGen != gen
+			u.refreshMu.Unlock()
+			if released || stale {
+				return js.Undefined(), nil
+			}
+			if !u.dom.Hidden() {
+				u.updateKeys(ctx)
+			}
+		}
+	})
+}
+
+// refresh refreshes the UI from storage. Changes made while a refresh is in
+// progress are coalesced into a single additional refresh. Refreshing only
+// reads from storage, so it does not itself trigger further refreshes.
+func (u *UI) refresh(ctx jsutil.AsyncContext) {
+	u.refreshMu.Lock()
+	if u.refreshing {
+		u.refreshPending = true
+		u.refreshMu.Unlock()
+		return
+	}
+	u.refreshing = true
+	u.refreshMu.Unlock()
+
+	for {
+		u.updatePreferences(ctx)
+		u.updateKeys(ctx)
+		u.updateActivity(ctx)
+
+		u.refreshMu.Lock()
+		if !u.refreshPending {
+			u.refreshing = false
+			u.refreshMu.Unlock()
+			return
+		}
+		u.refreshPending = false
+		u.refreshMu.Unlock()
+	}
+}
+
 // setError updates the UI to display the supplied error. If the supplied error
 // is nil, then any displayed error is cleared.
 func (u *UI) setError(err error) {
 	// Clear any existing error
 	dom.RemoveChildren(u.errorText)
 
-	if err != nil {
-		jsutil.LogError("UI.setError(): %v", err)
-		dom.AppendChild(u.errorText, u.dom.NewText(err.Error()), nil)
+	if err == nil {
+		// Hide the element entirely so it doesn't occupy space in the
+		// layout.
+		dom.Hide(u.errorText)
+		return
+	}
+	jsutil.LogError("UI.setError(): %v", err)
+	if keys.IsLocked(err) {
+		// The agent was locked elsewhere (e.g., from another page,
+		// or because the background worker restarted).
+		dom.Hide(u.errorText)
+		u.lock()
+		return
+	}
+	dom.AppendChild(u.errorText, u.dom.NewText(u.errorMessage(err)), nil)
+	dom.Show(u.errorText)
+	if keys.IsUnreachable(err) {
+		u.reconnect()
 	}
 }
 
-// add configures a new key.  It displays a dialog prompting the user for a name
-// and the corresponding private key.  If the user continues, the key is
-// added to the manager.
-func (u *UI) add(ctx jsutil.AsyncContext, _ dom.Event) {
-	ok, name, privateKey := u.promptAdd(ctx)
-	if !ok {
+// checkReachable displays a banner prompting the user to reload the page if
+// the agent cannot be reached (e.g., the background worker failed to start).
+func (u *UI) checkReachable(ctx jsutil.AsyncContext) {
+	u.setUnreachable(u.mgr.Ping(ctx))
+}
+
+// setUnreachable displays the banner indicating that the agent cannot be
+// reached because of the supplied error. If the supplied error is nil, then
+// the banner is hidden.
+func (u *UI) setUnreachable(err error) {
+	dom.RemoveChildren(u.unreachableText)
+	// Reconnecting is only useful while the agent is unreachable.
+	u.reconnectButton.Set("disabled", err == nil)
+
+	if err == nil {
+		dom.Hide(u.unreachable)
+		return
+	}
+	jsutil.LogError("UI.setUnreachable(): %v", err)
+	dom.AppendChild(u.unreachableText, u.dom.NewText(unreachableText(err)), nil)
+	dom.Show(u.unreachable)
+}
+
+const (
+	// reconnectInterval is the time between attempts to reconnect to an
+	// unreachable agent.
+	reconnectInterval = time.Second
+	// reconnectAttempts is the number of attempts made to reconnect to an
+	// unreachable agent before the user is prompted to reload the page.
+	reconnectAttempts = 3
+	// reconnectingText is the message displayed while reconnecting to the
+	// agent.
+	reconnectingText = "Reconnecting to the SSH agent…"
+)
+
+// reconnect displays a banner indicating that the UI is reconnecting to the
+// agent, and pings the agent in the background. Once the agent responds, the
+// banner is hidden and the displayed keys are refreshed. If the agent does
+// not respond after several attempts, the user is prompted to reload the
+// page instead.
+func (u *UI) reconnect() {
+	u.reconnectMu.Lock()
+	if u.reconnecting || u.released {
+		u.reconnectMu.Unlock()
 		return
 	}
+	u.reconnecting = true
+	u.reconnectMu.Unlock()
+
+	dom.RemoveChildren(u.unreachableText)
+	dom.AppendChild(u.unreachableText, u.dom.NewText(reconnectingText), nil)
+	u.reconnectButton.Set("disabled", false)
+	dom.Show(u.unreachable)
+
+	jsutil.Async(func(ctx jsutil.AsyncContext) (js.Value, error) {
+		var err error
+		for i := 0; i < reconnectAttempts; i++ {
+			time.Sleep(reconnectInterval)
+			u.reconnectMu.Lock()
+			released := u.released
+			u.reconnectMu.Unlock()
+			if released {
+				return js.Undefined(), nil
+			}
+
+			if err = u.mgr.Ping(ctx); err == nil {
+				break
+			}
+		}
+
+		u.reconnectMu.Lock()
+		u.reconnecting = false
+		u.reconnectMu.Unlock()
+
+		u.setUnreachable(err)
+		if err == nil {
+			u.setError(nil)
+			u.updateKeys(ctx)
+		}
+		return js.Undefined(), nil
+	})
+}
+
+// SetNewManager enables the user to reconnect to an unresponsive agent.
+// Reconnecting replaces the manager with a new one returned by newManager
+// (e.g., a client with a new connection to the background worker).
+func (u *UI) SetNewManager(newManager func() keys.Manager) {
+	u.reconnectMu.Lock()
+	defer u.reconnectMu.Unlock()
+	u.newManager = newManager
+	dom.SetVisible(u.reconnectButton, newManager != nil)
+}
+
+// SetReloadExtension enables the user to restart the extension if the agent
+// is unresponsive. reloadExtension is invoked to restart it once the user
+// confirms.
+func (u *UI) SetReloadExtension(reloadExtension func()) {
+	u.reconnectMu.Lock()
+	defer u.reconnectMu.Unlock()
+	u.reloadExtension = reloadExtension
+	dom.SetVisible(u.restartButton, reloadExtension != nil)
+}
 
-	if err := u.mgr.Add(ctx, name, privateKey); err != nil {
-		u.setError(fmt.Errorf("failed to add key: %w", err))
+// rebuildManager replaces the manager with a new one, abandoning any
+// requests awaiting a response from the old one, then refreshes the UI if
+// the agent responds to the new one.
+func (u *UI) rebuildManager(ctx jsutil.AsyncContext, _ dom.Event) {
+	u.reconnectMu.Lock()
+	newManager := u.newManager
+	u.reconnectMu.Unlock()
+	if newManager == nil {
 		return
 	}
 
+	jsutil.Log("Reconnecting to the SSH agent")
+	old := u.mgr
+	u.mgr = newManager()
+	// Release the old client so that requests stuck awaiting a response
+	// return, rather than leaving their callbacks registered.
+	keys.CloseClient(old)
+
+	err := u.mgr.Ping(ctx)
+	u.setUnreachable(err)
+	if err != nil {
+		return
+	}
 	u.setError(nil)
 	u.updateKeys(ctx)
+	u.setStatus("Reconnected to the SSH agent.")
 }
 
-// promptAdd displays a dialog prompting the user for a name and private key.
-func (u *UI) promptAdd(ctx jsutil.AsyncContext) (ok bool, name, privateKey string) {
-	dialog := dom.NewDialog(u.dom.GetElement("addDialog"))
-	form := u.dom.GetElement("addForm")
-	nameField := u.dom.GetElement("addName")
-	keyField := u.dom.GetElement("addKey")
-	cancel := u.dom.GetElement("addCancel")
+// promptRestart prompts the user to confirm that the extension should be
+// restarted.
+func (u *UI) promptRestart(ctx jsutil.AsyncContext) (yes bool) {
+	dialog := dom.NewDialog(u.dom.GetElement("restartDialog"))
+	form := u.dom.GetElement("restartForm")
+	no := u.dom.GetElement("restartNo")
 
 	sig := newSignal()
 	var cleanup jsutil.CleanupFuncs
 	cleanup.Add(dom.OnSubmit(form, func(ctx jsutil.AsyncContext, evt dom.Event) {
-		ok = true
-		name = dom.Value(nameField)
-		privateKey = dom.Value(keyField)
+		yes = true
 		dialog.Close()
 		sig.Notify()
 	}))
-	cleanup.Add(dom.OnClick(cancel, func(ctx jsutil.AsyncContext, evt dom.Event) {
+	cleanup.Add(dom.OnClick(no, func(ctx jsutil.AsyncContext, evt dom.Event) {
 		dialog.Close()
 		sig.Notify()
 	}))
 	cleanup.Add(dialog.OnClose(func(ctx jsutil.AsyncContext, evt dom.Event) {
-		dom.SetValue(nameField, "")
-		dom.SetValue(keyField, "")
 		cleanup.Do()
 	}))
 
@@ -166,393 +824,3227 @@ func (u *UI) promptAdd(ctx jsutil.AsyncContext) (ok bool, name, privateKey strin
 	return
 }
 
-// load loads the key with the specified ID.  A dialog prompts the user for a
-// passphrase if the private key is encrypted.
-func (u *UI) load(ctx jsutil.AsyncContext, id keys.ID) {
-	k := u.keyByID(id)
-	if k == nil {
-		u.setError(fmt.Errorf("failed to unload key ID %s: not found", id))
+// restartExtension restarts the extension once the user confirms. This is
+// a last resort if reconnecting fails, since it unloads all keys.
+func (u *UI) restartExtension(ctx jsutil.AsyncContext, _ dom.Event) {
+	u.reconnectMu.Lock()
+	reloadExtension := u.reloadExtension
+	u.reconnectMu.Unlock()
+	if reloadExtension == nil {
 		return
 	}
 
-	var ok bool
-	var passphrase string
-	if k.Encrypted {
-		ok, passphrase = u.promptPassphrase(ctx)
-		if !ok {
-			return
+	if yes := u.promptRestart(ctx); !yes {
+		return
+	}
+	jsutil.Log("Restarting the extension")
+	reloadExtension()
+}
+
+// unreachableText returns the message displayed when the agent cannot be
+// reached.
+func unreachableText(err error) string {
+	return fmt.Sprintf("The SSH agent is not responding (%v). Reload this page to reconnect.", err)
+}
+
+// errorHints identify the message explaining how to resolve each category of
+// error reported by the manager.
+var errorHints = []struct {
+	err error
+	msg string
+}{
+	{keys.ErrBadPassphrase, msgHintBadPassphrase},
+	{keys.ErrDuplicateKey, msgHintDuplicateKey},
+	{keys.ErrNameInUse, msgHintNameInUse},
+	{keys.ErrInvalidName, msgHintInvalidName},
+	{keys.ErrUnsupportedFormat, msgHintUnsupportedFormat},
+	{keys.ErrUnsupportedCipher, msgHintUnsupportedCipher},
+}
+
+// errorMessage returns the message displayed for an error. Errors in a
+// recognized category are preceded by an explanation of how to resolve them;
+// the original error is retained to aid troubleshooting.
+func (u *UI) errorMessage(err error) string {
+	for _, h := range errorHints {
+		if errors.Is(err, h.err) {
+			return fmt.Sprintf("%s (%v)", u.t(h.msg), err)
 		}
 	}
+	return err.Error()
+}
+
+const (
+	// lockPollInterval is the interval at which the UI checks whether it
+	// has been idle long enough to be locked.
+	lockPollInterval = time.Second
+)
+
+// isLocked indicates whether keys are hidden until the user supplies the
+// master passphrase.
+func (u *UI) isLocked() bool {
+	u.lockMu.Lock()
+	defer u.lockMu.Unlock()
+	return u.locked
+}
+
+// checkLock locks the UI if a master passphrase is set; otherwise, keys are
+// displayed immediately. The UI remains locked if it cannot be determined
+// whether a master passphrase is set.
+func (u *UI) checkLock(ctx jsutil.AsyncContext) {
+	set, err := u.mgr.MasterPassphraseSet(ctx)
+	if err != nil {
+		u.lock()
+		dom.AppendChild(u.unlockError, u.dom.NewText(fmt.Sprintf("Failed to check master passphrase: %v", err)), nil)
+		return
+	}
 
-	if err := u.mgr.Load(ctx, id, passphrase); err != nil {
-		u.setError(fmt.Errorf("failed to load key: %w", err))
+	u.setMasterSet(set)
+	if set {
+		u.lock()
 		return
 	}
-	u.setError(nil)
-	u.updateKeys(ctx)
+	u.setUnlocked(ctx)
 }
 
-// promptPassphrase displays a dialog prompting the user for a passphrase.
-func (u *UI) promptPassphrase(ctx jsutil.AsyncContext) (ok bool, passphrase string) {
-	dialog := dom.NewDialog(u.dom.GetElement("passphraseDialog"))
-	form := u.dom.GetElement("passphraseForm")
-	passphraseField := u.dom.GetElement("passphrase")
-	cancel := u.dom.GetElement("passphraseCancel")
+// setMasterSet updates the UI to reflect whether a master passphrase is set.
+func (u *UI) setMasterSet(set bool) {
+	u.lockMu.Lock()
+	u.masterSet = set
+	u.lockMu.Unlock()
 
-	sig := newSignal()
-	var cleanup jsutil.CleanupFuncs
-	cleanup.Add(dom.OnSubmit(form, func(ctx jsutil.AsyncContext, evt dom.Event) {
-		ok = true
-		passphrase = dom.Value(passphraseField)
-		dialog.Close()
-		sig.Notify()
-	}))
-	cleanup.Add(dom.OnClick(cancel, func(ctx jsutil.AsyncContext, evt dom.Event) {
-		dialog.Close()
-		sig.Notify()
-	}))
-	cleanup.Add(dialog.OnClose(func(ctx jsutil.AsyncContext, evt dom.Event) {
-		dom.SetValue(passphraseField, "")
-		cleanup.Do()
-	}))
+	u.lockButton.Set("disabled", !set)
+	dom.RemoveChildren(u.masterButton)
+	text := "Set Master Passphrase"
+	if set {
+		text = "Change Master Passphrase"
+	}
+	dom.AppendChild(u.masterButton, u.dom.NewText(text), nil)
+}
 
-	dialog.ShowModal()
-	sig.Wait(ctx)
-	return
+// setLockTimeout sets the period of inactivity after which the UI is locked.
+func (u *UI) setLockTimeout(timeout time.Duration) {
+	u.lockMu.Lock()
+	defer u.lockMu.Unlock()
+	u.lockTimeout = timeout
 }
 
-// unload unloads the specified key.
-func (u *UI) unload(ctx jsutil.AsyncContext, id keys.ID) {
-	if err := u.mgr.Unload(ctx, id); err != nil {
-		u.setError(fmt.Errorf("failed to unload key ID %s: %w", id, err))
+// markActive records that the user interacted with the UI.
+func (u *UI) markActive() {
+	u.lockMu.Lock()
+	defer u.lockMu.Unlock()
+	u.lastActivity = time.Now()
+}
+
+// lock hides the displayed keys and the activity log, and displays the lock
+// screen prompting the user for the master passphrase.
+func (u *UI) lock() {
+	u.lockMu.Lock()
+	u.locked = true
+	u.lockGen++
+	u.lockMu.Unlock()
+
+	u.setKeys(nil)
+	dom.RemoveChildren(u.activityData)
+	dom.Hide(u.options)
+	dom.RemoveChildren(u.unlockError)
+	dom.SetValue(u.unlockField, "")
+	dom.Show(u.lockScreen)
+	dom.Focus(u.unlockField)
+}
+
+// lockNow locks the UI, and locks the agent so that keys may not be managed
+// from any page until the master passphrase is supplied again.
+func (u *UI) lockNow(ctx jsutil.AsyncContext) {
+	if err := u.mgr.Lock(ctx); err != nil {
+		u.setError(fmt.Errorf("failed to lock agent: %w", err))
+	}
+	u.lock()
+}
+
+// unlock verifies the master passphrase entered on the lock screen, and
+// displays keys if it is correct.
+func (u *UI) unlock(ctx jsutil.AsyncContext, _ dom.Event) {
+	passphrase := dom.Value(u.unlockField)
+	dom.SetValue(u.unlockField, "")
+	dom.RemoveChildren(u.unlockError)
+	if err := u.mgr.VerifyMasterPassphrase(ctx, passphrase); err != nil {
+		dom.AppendChild(u.unlockError, u.dom.NewText(fmt.Sprintf("Failed to unlock: %v", err)), nil)
 		return
 	}
-	u.setError(nil)
+	u.setUnlocked(ctx)
+}
+
+// setUnlocked hides the lock screen and displays keys.
+func (u *UI) setUnlocked(ctx jsutil.AsyncContext) {
+	u.lockMu.Lock()
+	u.locked = false
+	handled := u.requestHandled
+	u.requestHandled = true
+	u.lockMu.Unlock()
+
+	dom.Hide(u.lockScreen)
+	dom.Show(u.options)
 	u.updateKeys(ctx)
+	u.updateActivity(ctx)
+	u.watchIdle()
+	if !handled {
+		// Load any key requested via the URL (e.g., from the context
+		// menu).
+		u.loadRequested(ctx)
+	}
 }
 
-// promptRemove displays a dialog prompting the user to confirm that a key
-// should be removed.
-func (u *UI) promptRemove(ctx jsutil.AsyncContext, id keys.ID) (yes bool) {
-	k := u.keyByID(id)
-	if k == nil {
-		u.setError(fmt.Errorf("failed to remove key ID %s: not found", id))
+// watchIdle locks the UI once the user has not interacted with it for the
+// idle interval selected in the user's preferences. The UI is only locked
+// if a master passphrase is set. Any earlier watcher stops.
+func (u *UI) watchIdle() {
+	u.lockMu.Lock()
+	if u.locked || !u.masterSet {
+		u.lockMu.Unlock()
 		return
 	}
+	u.lockGen++
+	gen := u.lockGen
+	u.lastActivity = time.Now()
+	u.lockMu.Unlock()
 
-	dialog := dom.NewDialog(u.dom.GetElement("removeDialog"))
-	form := u.dom.GetElement("removeForm")
-	name := u.dom.GetElement("removeName")
-	no := u.dom.GetElement("removeNo")
-	dom.AppendChild(name, u.dom.NewText(k.Name), nil)
+	jsutil.Async(func(ctx jsutil.AsyncContext) (js.Value, error) {
+		for {
+			time.Sleep(lockPollInterval)
+			u.reconnectMu.Lock()
+			released := u.released
+			u.reconnectMu.Unlock()
+
+			u.lockMu.Lock()
+			stale := u.locked || !u.masterSet || u.lockGen != gen
+			idle := u.lockTimeout > 0 && time.Since(u.lastActivity) >= u.lockTimeout
+			u.lockMu.Unlock()
+			if released || stale {
+				return js.Undefined(), nil
+			}
+			if idle {
+				u.lockNow(ctx)
+				return js.Undefined(), nil
+			}
+		}
+	})
+}
+
+// promptMasterPassphrase displays a dialog prompting the user for the current
+// and new master passphrases.
+func (u *UI) promptMasterPassphrase(ctx jsutil.AsyncContext) (ok bool, current, passphrase string) {
+	dialogElem := u.dom.GetElement("masterDialog")
+	dialog := dom.NewDialog(dialogElem)
+	form := u.dom.GetElement("masterForm")
+	currentField := u.dom.GetElement("masterCurrent")
+	newField := u.dom.GetElement("masterNew")
+	strength := u.dom.GetElement("masterStrength")
+	feedback := u.dom.GetElement("masterFeedback")
+	okButton := u.dom.GetElement("masterOk")
+	cancel := u.dom.GetElement("masterCancel")
+	u.setPassphraseStrength(strength, feedback, "")
 
 	sig := newSignal()
 	var cleanup jsutil.CleanupFuncs
 	cleanup.Add(dom.OnSubmit(form, func(ctx jsutil.AsyncContext, evt dom.Event) {
-		yes = true
+		ok = true
+		current = dom.Value(currentField)
+		passphrase = dom.Value(newField)
 		dialog.Close()
 		sig.Notify()
 	}))
-	cleanup.Add(dom.OnClick(no, func(ctx jsutil.AsyncContext, evt dom.Event) {
+	cleanup.Add(dom.OnClick(cancel, func(ctx jsutil.AsyncContext, evt dom.Event) {
 		dialog.Close()
 		sig.Notify()
 	}))
+	for _, field := range []js.Value{currentField, newField} {
+		cleanup.Add(onDialogKeys(field, okButton, cancel))
+	}
+	cleanup.Add(dom.OnInput(newField, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		u.setPassphraseStrength(strength, feedback, dom.Value(newField))
+	}))
 	cleanup.Add(dialog.OnClose(func(ctx jsutil.AsyncContext, evt dom.Event) {
-		dom.RemoveChildren(name)
+		dom.SetValue(currentField, "")
+		dom.SetValue(newField, "")
+		dom.RemoveChildren(feedback)
+		strength.Set("value", 0)
 		cleanup.Do()
 	}))
 
-	dialog.ShowModal()
+	cleanup.Add(u.showModal(dialog, dialogElem, currentField))
 	sig.Wait(ctx)
 	return
 }
 
-// remove removes the key with the specified ID.  A dialog prompts the user to
-// confirm that the key should be removed.
-func (u *UI) remove(ctx jsutil.AsyncContext, id keys.ID) {
-	if yes := u.promptRemove(ctx, id); !yes {
+// setMasterPassphrase changes the master passphrase required to view keys. A
+// dialog prompts the user for the current and new master passphrases.
+func (u *UI) setMasterPassphrase(ctx jsutil.AsyncContext, _ dom.Event) {
+	ok, current, passphrase := u.promptMasterPassphrase(ctx)
+	if !ok {
 		return
 	}
 
-	if err := u.mgr.Remove(ctx, id); err != nil {
-		u.setError(fmt.Errorf("failed to remove key ID %s: %w", id, err))
+	if err := u.mgr.SetMasterPassphrase(ctx, current, passphrase); err != nil {
+		u.setError(fmt.Errorf("failed to change master passphrase: %w", err))
 		return
 	}
 	u.setError(nil)
-	u.updateKeys(ctx)
+	u.setMasterSet(passphrase != "")
+	if passphrase == "" {
+		u.setStatus("Master passphrase removed.")
+		return
+	}
+	u.setStatus("Master passphrase changed.")
+	u.watchIdle()
 }
 
-// displayedKey represents a key displayed in the UI.
-type displayedKey struct {
-	// ID is the unique ID corresponding to the key.
-	ID keys.ID
-	// Loaded indicates if the key is currently loaded.
-	Loaded bool
-	// Encrypted indicates if the private key is encrypted and requires a
-	// passphrase to load. This field is only valid if the key is not
-	// loaded.
-	Encrypted bool
-	// Name is the human-readable name assigned to the key.
-	Name string
-	// Type is the type of key (e.g., 'ssh-rsa').
-	Type string
-	// Blob is the public key material for the key.
-	Blob string
-	// Comment is the comment attached to the key in the agent
-	Comment string
-	// cleanup keeps track of any cleanup required before removing this key
-	// from the UI.
-	cleanup jsutil.CleanupFuncs
-}
+// setStatus updates the UI to display the supplied status message. If the
+// supplied message is empty, then any displayed status is cleared.
+func (u *UI) setStatus(msg string) {
+	dom.RemoveChildren(u.statusText)
 
-// LoadedKey returns the corresponding LoadedKey.
-func (d *displayedKey) LoadedKey() (*keys.LoadedKey, error) {
-	blob, err := base64.StdEncoding.DecodeString(d.Blob)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode blob: %w", err)
+	if msg != "" {
+		dom.AppendChild(u.statusText, u.dom.NewText(msg), nil)
 	}
+}
 
-	l := &keys.LoadedKey{
-		Type:    d.Type,
-		Comment: d.Comment,
+// whilePending invokes f, which sends a request to the supplied manager that
+// may take some time to complete. While the request is in flight, a spinner is
+// displayed along with the supplied description, and the user is offered to
+// cancel the request; this allows the user to recover should the agent never
+// respond. Only requests sent through the supplied manager are cancelled.
+func (u *UI) whilePending(desc string, f func(mgr keys.Manager) error) error {
+	mgr, cancel := keys.WithCancel(u.mgr)
+	u.pendingMu.Lock()
+	u.pendingGen++
+	gen := u.pendingGen
+	u.cancelPending = cancel
+	u.pendingMu.Unlock()
+	defer func() {
+		u.pendingMu.Lock()
+		defer u.pendingMu.Unlock()
+		if gen == u.pendingGen {
+			u.cancelPending = nil
+		}
+	}()
+
+	dom.RemoveChildren(u.pendingText)
+	dom.AppendChild(u.pendingText, u.dom.NewText(desc), nil)
+	dom.SetVisible(u.pendingPane, true)
+	defer dom.SetVisible(u.pendingPane, false)
+	return f(mgr)
+}
+
+// cancelPendingRequest abandons the request displayed by whilePending, if
+// any.
+func (u *UI) cancelPendingRequest() {
+	u.pendingMu.Lock()
+	cancel := u.cancelPending
+	u.pendingMu.Unlock()
+	if cancel != nil {
+		cancel()
 	}
-	l.SetBlob(blob)
-	return l, nil
 }
 
-// displayedKeys returns the keys currently displayed in the UI.
-func (u *UI) displayedKeys() []*displayedKey {
-	return u.keys
+// add configures a new key.  It displays a dialog prompting the user for a name
+// and the corresponding private key.  If the user continues, the key is
+// added to the manager.
+func (u *UI) add(ctx jsutil.AsyncContext, _ dom.Event) {
+	u.addWith(ctx, "", "")
 }
 
-func (u *UI) keyByID(id keys.ID) *displayedKey {
-	if id == keys.InvalidID {
-		return nil
+// addWith configures a new key. It displays a dialog prompting the user for a
+// name and the corresponding private key, initialized to the supplied values.
+func (u *UI) addWith(ctx jsutil.AsyncContext, initialName, initialKey string) {
+	ok, name, privateKey, opts := u.promptAdd(ctx, initialName, initialKey)
+	if !ok {
+		return
 	}
 
-	for _, k := range u.keys {
-		if k.ID == id {
-			return k
-		}
+	if keys.IsBundle(privateKey) {
+		u.addBundle(ctx, name, privateKey, opts)
+		return
 	}
-	return nil
-}
 
-func (u *UI) keyByName(name string) *displayedKey {
-	for _, k := range u.keys {
-		if k.Name == name {
-			return k
-		}
+	err := u.whilePending("Adding key...", func(mgr keys.Manager) error {
+		return mgr.Add(ctx, name, privateKey, opts)
+	})
+	if keys.IsCancelled(err) {
+		// The key may yet be added; display the current state.
+		u.updateKeys(ctx)
+		u.setStatus("Cancelled adding key.")
+		return
 	}
-	return nil
+	if err != nil {
+		u.setError(u.failed(msgFailedAdd, err))
+		return
+	}
+
+	u.setError(nil)
+	u.updateKeys(ctx)
 }
 
-// buttonKind is the type of button displayed for a key.
-type buttonKind int
+// addBundle configures a separate key for each PEM block in a bundle pasted
+// by the user. Blocks that cannot be added are skipped, and reported once
+// the remaining keys are added.
+func (u *UI) addBundle(ctx jsutil.AsyncContext, name, bundle string, opts keys.AddOptions) {
+	u.setStatus("")
 
-const (
-	// LoadButton indicates that the button loads the key into the agent.
+	errs, err := u.mgr.AddBundle(ctx, name, bundle, opts)
+	// Updating keys clears any error, so do so first.
+	u.updateKeys(ctx)
+	if err != nil {
+		u.setError(fmt.Errorf("failed to add keys: %w", err))
+		return
+	}
+
+	added := 0
+	var failures []string
+	for i, err := range errs {
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("key %d: %v", i+1, err))
+			continue
+		}
+		added++
+	}
+	u.setStatus(fmt.Sprintf("Added %d keys.", added))
+	if len(failures) > 0 {
+		u.setError(fmt.Errorf("failed to add %d keys: %s", len(failures), strings.Join(failures, "; ")))
+	}
+}
+
+// adopt configures a key that is loaded in the agent, but not configured.
+// The agent does not expose private keys, so a dialog prompts the user for
+// the corresponding private key. The name is initialized from the key's
+// comment.
+func (u *UI) adopt(ctx jsutil.AsyncContext, k *displayedKey) {
+	ok, name, privateKey, opts := u.promptAdd(ctx, k.Comment, "")
+	if !ok {
+		return
+	}
+
+	if err := u.mgr.Add(ctx, name, privateKey, opts); err != nil {
+		u.setError(fmt.Errorf("failed to adopt key: %w", err))
+		return
+	}
+
+	u.setError(nil)
+	u.updateKeys(ctx)
+}
+
+// promptAdoptLoaded displays a dialog prompting the user for the name under
+// which a key loaded in the agent is configured. The name is initialized from
+// the key's comment.
+func (u *UI) promptAdoptLoaded(ctx jsutil.AsyncContext, k *displayedKey) (ok bool, name string) {
+	dialogElem := u.dom.GetElement("adoptLoadedDialog")
+	dialog := dom.NewDialog(dialogElem)
+	form := u.dom.GetElement("adoptLoadedForm")
+	field := u.dom.GetElement("adoptLoadedName")
+	okButton := u.dom.GetElement("adoptLoadedOk")
+	cancel := u.dom.GetElement("adoptLoadedCancel")
+	dom.SetValue(field, k.Comment)
+
+	sig := newSignal()
+	var cleanup jsutil.CleanupFuncs
+	cleanup.Add(dom.OnSubmit(form, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		ok = true
+		name = dom.Value(field)
+		dialog.Close()
+		sig.Notify()
+	}))
+	cleanup.Add(dom.OnClick(cancel, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		dialog.Close()
+		sig.Notify()
+	}))
+	cleanup.Add(onDialogKeys(field, okButton, cancel))
+	cleanup.Add(dialog.OnClose(func(ctx jsutil.AsyncContext, evt dom.Event) {
+		dom.SetValue(field, "")
+		cleanup.Do()
+	}))
+
+	cleanup.Add(u.showModal(dialog, dialogElem, field))
+	sig.Wait(ctx)
+	return
+}
+
+// adoptLoaded configures a key that is loaded in the agent, but not
+// configured, using only its public key. Unlike adopt, the private key is
+// not required; the key is displayed as loaded while the agent holds it, but
+// cannot be loaded from here.
+func (u *UI) adoptLoaded(ctx jsutil.AsyncContext, k *displayedKey) {
+	lk, err := k.LoadedKey()
+	if err != nil {
+		u.setError(fmt.Errorf("failed to adopt key: %w", err))
+		return
+	}
+	ok, name := u.promptAdoptLoaded(ctx, k)
+	if !ok {
+		return
+	}
+
+	if err := u.mgr.AdoptLoaded(ctx, lk, name); err != nil {
+		u.setError(fmt.Errorf("failed to adopt key: %w", err))
+		return
+	}
+
+	u.setError(nil)
+	u.updateKeys(ctx)
+}
+
+// setPreview describes the private key in the preview element, so that the
+// user can verify it before it is configured. Problems parsing the key are
+// displayed instead.
+func (u *UI) setPreview(preview js.Value, privateKey string) {
+	dom.RemoveChildren(preview)
+	preview.Set("className", "keyPreview")
+	if strings.TrimSpace(privateKey) == "" {
+		return
+	}
+
+	if blocks := keys.SplitBundle(privateKey); len(blocks) > 1 {
+		dom.AppendChild(preview, u.dom.NewText(fmt.Sprintf("%d keys; each is added separately", len(blocks))), nil)
+		return
+	}
+
+	info, err := keys.Inspect(privateKey, "")
+	if err != nil {
+		preview.Set("className", "keyPreview keyPreviewError")
+		dom.AppendChild(preview, u.dom.NewText(err.Error()), nil)
+		return
+	}
+	dom.AppendChild(preview, u.dom.NewText(previewText(info)), nil)
+}
+
+// previewText returns a human-readable description of a private key.
+func previewText(info *keys.KeyInfo) string {
+	if info.Fingerprint == "" {
+		return "Encrypted key; details are available once loaded"
+	}
+
+	text := fmt.Sprintf("%s %d-bit %s", info.Type, info.Bits, info.Fingerprint)
+	if info.Comment != "" {
+		text = fmt.Sprintf("%s %s", text, info.Comment)
+	}
+	if info.Encrypted {
+		text = fmt.Sprintf("%s (encrypted)", text)
+	}
+	return text
+}
+
+// onDialogKeys registers keyboard shortcuts for a field in a dialog. Enter
+// submits the dialog by clicking ok, except in multi-line fields where it
+// inserts a newline. Escape cancels the dialog by clicking cancel.
+func onDialogKeys(field, ok, cancel js.Value) jsutil.CleanupFunc {
+	return dom.OnKeyDown(field, func(evt dom.Event) {
+		switch evt.Key() {
+		case "Enter":
+			if field.Get("tagName").String() == "TEXTAREA" {
+				return
+			}
+			evt.PreventDefault()
+			dom.DoClick(ok)
+		case "Escape":
+			evt.PreventDefault()
+			dom.DoClick(cancel)
+		}
+	})
+}
+
+// showModal displays a dialog and moves focus to the specified field. Tab
+// navigation is confined to the dialog while it is open. The returned cleanup
+// function must be invoked once the dialog is closed; it restores focus to
+// the element that had focus before the dialog was displayed.
+func (u *UI) showModal(dialog *dom.Dialog, dialogElem, field js.Value) jsutil.CleanupFunc {
+	u.lastFocus = u.dom.ActiveElement()
+	untrap := dom.TrapFocus(dialogElem)
+	dialog.ShowModal()
+	dom.Focus(field)
+	return func() {
+		untrap()
+		dom.Focus(u.lastFocus)
+		u.lastFocus = js.Undefined()
+	}
+}
+
+// promptAdd displays a dialog prompting the user for a name, private key, and
+// any settings for the key. The name is initialized to initialName. The key
+// cannot be submitted until the name and private key appear valid.
+func (u *UI) promptAdd(ctx jsutil.AsyncContext, initialName, initialKey string) (ok bool, name, privateKey string, opts keys.AddOptions) {
+	dialogElem := u.dom.GetElement("addDialog")
+	dialog := dom.NewDialog(dialogElem)
+	form := u.dom.GetElement("addForm")
+	nameField := u.dom.GetElement("addName")
+	keyField := u.dom.GetElement("addKey")
+	certField := u.dom.GetElement("addCertificate")
+	preview := u.dom.GetElement("addPreview")
+	hint := u.dom.GetElement("addHint")
+	nameHint := u.dom.GetElement("addNameHint")
+	confirmField := u.dom.GetElement("addConfirm")
+	duplicateField := u.dom.GetElement("addAllowDuplicate")
+	okButton := u.dom.GetElement("addOk")
+	cancel := u.dom.GetElement("addCancel")
+	dom.SetValue(nameField, initialName)
+	dom.SetValue(keyField, initialKey)
+	u.setPreview(preview, initialKey)
+
+	validate := func() {
+		err := keys.ValidateNew(dom.Value(nameField), dom.Value(keyField))
+		// Names are generated for each key in a bundle, so only the
+		// names of individual keys may clash.
+		inUse := !keys.IsBundle(dom.Value(keyField)) && u.nameInUse(dom.Value(nameField))
+		okButton.Set("disabled", err != nil || inUse)
+		dom.RemoveChildren(hint)
+		dom.RemoveChildren(nameHint)
+		if inUse {
+			dom.AppendChild(nameHint, u.dom.NewText(u.t(msgHintNameInUse)), nil)
+		}
+		// Don't complain about the key until the user supplies one.
+		if err != nil && strings.TrimSpace(dom.Value(keyField)) != "" {
+			dom.AppendChild(hint, u.dom.NewText(err.Error()), nil)
+		}
+	}
+	validate()
+
+	sig := newSignal()
+	var cleanup jsutil.CleanupFuncs
+	cleanup.Add(dom.OnSubmit(form, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		ok = true
+		name = dom.Value(nameField)
+		privateKey = dom.Value(keyField)
+		opts.Certificate = dom.Value(certField)
+		opts.ConfirmBeforeUse = dom.Checked(confirmField)
+		opts.AllowDuplicate = dom.Checked(duplicateField)
+		dialog.Close()
+		sig.Notify()
+	}))
+	cleanup.Add(dom.OnClick(cancel, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		dialog.Close()
+		sig.Notify()
+	}))
+	for _, field := range []js.Value{nameField, keyField, certField, confirmField, duplicateField} {
+		cleanup.Add(onDialogKeys(field, okButton, cancel))
+	}
+	cleanup.Add(dom.OnInput(nameField, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		validate()
+	}))
+	cleanup.Add(dom.OnInput(keyField, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		u.setPreview(preview, dom.Value(keyField))
+		validate()
+	}))
+	cleanup.Add(dialog.OnClose(func(ctx jsutil.AsyncContext, evt dom.Event) {
+		dom.SetValue(nameField, "")
+		dom.SetValue(keyField, "")
+		dom.SetValue(certField, "")
+		u.setPreview(preview, "")
+		dom.RemoveChildren(hint)
+		dom.RemoveChildren(nameHint)
+		dom.SetChecked(confirmField, false)
+		dom.SetChecked(duplicateField, false)
+		cleanup.Do()
+	}))
+
+	cleanup.Add(u.showModal(dialog, dialogElem, nameField))
+	sig.Wait(ctx)
+	return
+}
+
+var (
+	// errNotTextFile indicates that a file dropped by the user does not
+	// contain text, and therefore cannot contain a private key.
+	errNotTextFile = errors.New("file does not contain text")
+	// errLoadCancelled indicates that the user cancelled loading a key.
+	errLoadCancelled = errors.New("load cancelled by user")
+	// errLoadUnsupported indicates that the key cannot be loaded (e.g.,
+	// it is encrypted using an unsupported cipher).
+	errLoadUnsupported = errors.New("key cannot be loaded")
+	// errInvalidLifetime indicates that the user supplied an invalid
+	// lifetime for loaded keys.
+	errInvalidLifetime = errors.New("invalid lifetime")
+	// errInvalidIdleTimeout indicates that the user supplied an invalid
+	// period after which idle keys are unloaded.
+	errInvalidIdleTimeout = errors.New("invalid idle timeout")
+	// errInvalidPassphraseCache indicates that the user supplied an
+	// invalid period for which passphrases are cached.
+	errInvalidPassphraseCache = errors.New("invalid passphrase cache period")
+	// errInvalidMaxLoaded indicates that the user supplied an invalid
+	// maximum number of loaded keys.
+	errInvalidMaxLoaded = errors.New("invalid maximum number of loaded keys")
+	// errInvalidMinKeyBits indicates that the user supplied an invalid
+	// minimum key size.
+	errInvalidMinKeyBits = errors.New("invalid minimum key size")
+	// errInvalidRefreshSecs indicates that the user supplied an invalid
+	// interval at which the page is refreshed.
+	errInvalidRefreshSecs = errors.New("invalid refresh interval")
+	// errInvalidKeysPerPage indicates that the user supplied an invalid
+	// number of keys displayed per page.
+	errInvalidKeysPerPage = errors.New("invalid number of keys per page")
+)
+
+// loadOptions returns the options to apply when loading keys, as specified
+// by the user.
+func (u *UI) loadOptions() (keys.LoadOptions, error) {
+	var opts keys.LoadOptions
+	opts.OneShot = dom.Checked(u.loadOneShot)
+
+	// An empty lifetime indicates that keys do not expire.
+	lifetime := strings.TrimSpace(dom.Value(u.loadLifetime))
+	if lifetime == "" {
+		return opts, nil
+	}
+	mins, err := strconv.ParseUint(lifetime, 10, 32)
+	if err != nil || mins > math.MaxUint32/60 {
+		return opts, fmt.Errorf("%w: '%s' is not a valid number of minutes", errInvalidLifetime, lifetime)
+	}
+	opts.LifetimeSecs = uint32(mins * 60)
+	return opts, nil
+}
+
+const (
+	// maxPassphraseAttempts is the number of times the user is prompted
+	// for a key's passphrase before an incorrect passphrase is reported
+	// as an error.
+	maxPassphraseAttempts = 3
+)
+
+// loadKey loads the specified key.  A dialog prompts the user for a
+// passphrase if the private key is encrypted; if the passphrase is
+// incorrect, the dialog is displayed again, up to maxPassphraseAttempts
+// times. errLoadCancelled is returned if the user cancels the prompt.
+func (u *UI) loadKey(ctx jsutil.AsyncContext, k *displayedKey) error {
+	opts, err := u.loadOptions()
+	if err != nil {
+		return err
+	}
+
+	if k.Unsupported != "" {
+		return fmt.Errorf("%w: %s", errLoadUnsupported, k.Unsupported)
+	}
+
+	// A cached passphrase is supplied by the manager.
+	prompt := k.Encrypted && !k.PassphraseCached
+	var retryMsg string
+	for attempt := 1; ; attempt++ {
+		var passphrase string
+		if prompt {
+			var ok bool
+			ok, passphrase = u.promptPassphrase(ctx, retryMsg)
+			if !ok {
+				return errLoadCancelled
+			}
+		}
+
+		err := u.whilePending(fmt.Sprintf("Loading key %s...", k.Name), func(mgr keys.Manager) error {
+			return mgr.Load(ctx, k.ID, passphrase, opts)
+		})
+		if !prompt || attempt >= maxPassphraseAttempts || !errors.Is(err, keys.ErrBadPassphrase) {
+			return err
+		}
+		retryMsg = fmt.Sprintf("Incorrect passphrase for key %s, try again.", k.Name)
+	}
+}
+
+// load loads the key with the specified ID.  A dialog prompts the user for a
+// passphrase if the private key is encrypted. If the user has requested it, a
+// dialog first prompts the user to confirm the details of the key.
+func (u *UI) load(ctx jsutil.AsyncContext, id keys.ID) {
+	k := u.keyByID(id)
+	if k == nil {
+		u.setError(fmt.Errorf("%s ID %s: not found", u.t(msgFailedUnload), id))
+		return
+	}
+
+	prefs, err := u.mgr.Preferences(ctx)
+	if err != nil {
+		u.setError(fmt.Errorf("failed to read preferences: %w", err))
+		return
+	}
+	if prefs.ConfirmLoad {
+		if yes := u.promptLoad(ctx, k); !yes {
+			return
+		}
+	}
+
+	if err := u.loadKey(ctx, k); err != nil {
+		if errors.Is(err, errLoadCancelled) {
+			return
+		}
+		if keys.IsCancelled(err) {
+			// The key may yet be loaded; display the current state.
+			u.updateKeys(ctx)
+			u.setStatus("Cancelled loading key.")
+			return
+		}
+		u.setError(u.failed(msgFailedLoad, err))
+		return
+	}
+	u.setError(nil)
+	u.updateKeys(ctx)
+}
+
+// unloadedKeys returns the displayed keys that are configured, but not
+// currently loaded.
+func (u *UI) unloadedKeys() []*displayedKey {
+	_, unloaded := reconcile(u.keys)
+	return unloaded
+}
+
+// reconcile categorizes keys for which the agent and the configured keys
+// disagree. External keys are loaded in the agent, but not configured.
+// Unloaded keys are configured, but not loaded in the agent. Disabled keys are
+// deliberately not loaded, and are therefore not considered unloaded.
+func reconcile(disp []*displayedKey) (external, unloaded []*displayedKey) {
+	for _, k := range disp {
+		switch {
+		case k.ID == keys.InvalidID && k.Loaded:
+			external = append(external, k)
+		case k.ID != keys.InvalidID && !k.Loaded && !k.Disabled:
+			unloaded = append(unloaded, k)
+		}
+	}
+	return
+}
+
+// loadRequested loads the key requested via the page's URL, if any.
+func (u *UI) loadRequested(ctx jsutil.AsyncContext) {
+	u.loadFromQuery(ctx, u.dom.URLQuery())
+}
+
+// loadFromQuery loads the key identified by the 'load' parameter in the
+// supplied query string, if present. This allows a key to be loaded from
+// outside the options page (e.g., from the context menu) when a passphrase
+// is required; a dialog prompts the user for it.
+func (u *UI) loadFromQuery(ctx jsutil.AsyncContext, query string) {
+	values, err := url.ParseQuery(strings.TrimPrefix(query, "?"))
+	if err != nil {
+		jsutil.LogError("ignoring invalid query string %q: %v", query, err)
+		return
+	}
+	id := keys.ID(values.Get("load"))
+	if id == keys.InvalidID {
+		return
+	}
+
+	// The requested key may not yet be displayed.
+	u.updateKeys(ctx)
+	u.load(ctx, id)
+}
+
+// loadAll loads all keys that are not currently loaded, those with a higher
+// priority first. Passphrase prompts for encrypted keys are displayed one at
+// a time. Failure to load an
+// individual key does not prevent loading the remaining keys; all failures
+// are displayed together once finished.
+func (u *UI) loadAll(ctx jsutil.AsyncContext, _ dom.Event) {
+	var errs []error
+	for _, k := range byPriority(u.unloadedKeys()) {
+		if k.Unsupported != "" || k.Error != "" {
+			// The key's Load button is disabled; skip it here too.
+			continue
+		}
+		err := u.loadKey(ctx, k)
+		if errors.Is(err, errLoadCancelled) {
+			continue
+		}
+		if keys.IsCancelled(err) {
+			// The user abandoned the request; load no more keys.
+			break
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", u.t(msgFailedLoad), k.Name, err))
+		}
+	}
+
+	u.updateKeys(ctx)
+	// Set error after updating keys; updating keys clears any error.
+	if len(errs) > 0 {
+		u.setError(errors.Join(errs...))
+	}
+}
+
+// loadedKeys returns the displayed keys that are currently loaded, including
+// those that are not configured.
+func (u *UI) loadedKeys() []*displayedKey {
+	var loaded []*displayedKey
+	for _, k := range u.keys {
+		if k.Loaded {
+			loaded = append(loaded, k)
+		}
+	}
+	return loaded
+}
+
+// unloadAll unloads all keys from the agent, including those that are not
+// configured. The keys are removed in a single operation, so no confirmation
+// is requested.
+func (u *UI) unloadAll(ctx jsutil.AsyncContext, _ dom.Event) {
+	u.setStatus("")
+
+	n := len(u.loadedKeys())
+	err := u.mgr.UnloadAll(ctx)
+	// Update keys regardless, so that the display reflects the agent's
+	// state. Updating keys clears any error, so do so first.
+	u.updateKeys(ctx)
+	if err != nil {
+		u.setError(fmt.Errorf("failed to unload all keys: %w", err))
+		return
+	}
+	u.setStatus(fmt.Sprintf("Unloaded %d keys.", n))
+}
+
+const (
+	// exportFilename is the name of the file to which keys are exported.
+	exportFilename = "chrome-ssh-agent-keys.json"
+	// exportPublicFilename is the name of the file to which public keys
+	// are exported.
+	exportPublicFilename = "chrome-ssh-agent-authorized_keys"
+)
+
+// export downloads a backup of all configured keys.
+func (u *UI) export(ctx jsutil.AsyncContext, _ dom.Event) {
+	data, err := u.mgr.Export(ctx)
+	if err != nil {
+		u.setError(u.failed(msgFailedExport, err))
+		return
+	}
+
+	u.setError(nil)
+	u.dom.DownloadBlob(exportFilename, "application/json", data)
+}
+
+// exportPublic downloads the public keys of all configured keys in
+// authorized_keys format.
+func (u *UI) exportPublic(ctx jsutil.AsyncContext, _ dom.Event) {
+	text, err := u.mgr.ExportPublicKeys(ctx)
+	if err != nil {
+		u.setError(fmt.Errorf("failed to export public keys: %w", err))
+		return
+	}
+
+	u.setError(nil)
+	u.dom.DownloadBlob(exportPublicFilename, "text/plain", []byte(text))
+}
+
+// importKeys configures the keys contained in the file selected by the user.
+func (u *UI) importKeys(ctx jsutil.AsyncContext, _ dom.Event) {
+	files := u.importFile.Get("files")
+	if files.Length() == 0 {
+		return
+	}
+	file := files.Index(0)
+	// Clear the selection so that selecting the same file again is
+	// treated as a change.
+	dom.SetValue(u.importFile, "")
+
+	u.importFrom(ctx, file)
+}
+
+// importFrom configures the keys contained in the supplied file.
+func (u *UI) importFrom(ctx jsutil.AsyncContext, file js.Value) {
+	u.setStatus("")
+
+	data, err := dom.ReadFile(ctx, file)
+	if err != nil {
+		u.setError(u.failed(msgFailedImport, err))
+		return
+	}
+
+	result, err := u.mgr.Import(ctx, data)
+	// Some keys may have been imported before any failure, so update
+	// keys regardless. Updating keys clears any error, so do so first.
+	u.updateKeys(ctx)
+	if err != nil {
+		u.setError(u.failed(msgFailedImport, err))
+		return
+	}
+	u.setStatus(fmt.Sprintf("Imported %d keys; skipped %d keys that were already configured.", result.Imported, result.Skipped))
+}
+
+// maxKeyFileBytes is the maximum size of a file dropped by the user from which
+// a private key is read. Private keys (even RSA keys with a certificate) are
+// far smaller.
+const maxKeyFileBytes = 64 * 1024
+
+// keyFileExtensions are the file extensions removed from the name of a dropped
+// file to form the default name of the key.
+var keyFileExtensions = []string{".pem", ".key", ".ppk"}
+
+// dropFile configures a new key from a file dropped by the user. The contents
+// of the file and its name are supplied as the defaults in the dialog
+// prompting for the new key. Drops that do not include a file (e.g., when
+// reordering keys) are ignored.
+func (u *UI) dropFile(ctx jsutil.AsyncContext, evt dom.Event) {
+	dom.RemoveClass(u.keysTable, "dropTarget")
+
+	filename, data, err := dom.ReadFileFromDrop(ctx, evt, maxKeyFileBytes)
+	if errors.Is(err, dom.ErrNoDroppedFile) {
+		return
+	}
+	if err != nil {
+		u.setError(fmt.Errorf("failed to read dropped file: %w", err))
+		return
+	}
+	if !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0 {
+		u.setError(fmt.Errorf("failed to read dropped file: %w: %s", errNotTextFile, filename))
+		return
+	}
+
+	name := filename
+	for _, ext := range keyFileExtensions {
+		if strings.EqualFold(path.Ext(name), ext) {
+			name = strings.TrimSuffix(name, path.Ext(name))
+			break
+		}
+	}
+	u.setError(nil)
+	u.addWith(ctx, name, string(data))
+}
+
+// promptPassphrase displays a dialog prompting the user for a passphrase.
+// A meter gives advisory feedback on the strength of the passphrase as it is
+// typed. If errMsg is non-empty, it is displayed within the dialog (e.g., to
+// explain that a previously-entered passphrase was incorrect).
+func (u *UI) promptPassphrase(ctx jsutil.AsyncContext, errMsg string) (ok bool, passphrase string) {
+	dialogElem := u.dom.GetElement("passphraseDialog")
+	dialog := dom.NewDialog(dialogElem)
+	form := u.dom.GetElement("passphraseForm")
+	passphraseField := u.dom.GetElement("passphrase")
+	strength := u.dom.GetElement("passphraseStrength")
+	feedback := u.dom.GetElement("passphraseFeedback")
+	errorText := u.dom.GetElement("passphraseError")
+	reveal := u.dom.GetElement("passphraseReveal")
+	okButton := u.dom.GetElement("passphraseOk")
+	cancel := u.dom.GetElement("passphraseCancel")
+
+	// The strength meter is advisory only; it never prevents submission.
+	updateStrength := func() {
+		u.setPassphraseStrength(strength, feedback, dom.Value(passphraseField))
+	}
+	updateStrength()
+
+	// The message is replaced whenever the dialog is displayed, so that it
+	// is never cleared once the dialog has been displayed again.
+	dom.RemoveChildren(errorText)
+	if errMsg != "" {
+		dom.AppendChild(errorText, u.dom.NewText(errMsg), nil)
+		dom.Show(errorText)
+	} else {
+		dom.Hide(errorText)
+	}
+
+	sig := newSignal()
+	var cleanup jsutil.CleanupFuncs
+	cleanup.Add(dom.OnSubmit(form, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		ok = true
+		passphrase = dom.Value(passphraseField)
+		dialog.Close()
+		sig.Notify()
+	}))
+	cleanup.Add(dom.OnClick(cancel, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		dialog.Close()
+		sig.Notify()
+	}))
+	cleanup.Add(onDialogKeys(passphraseField, okButton, cancel))
+	cleanup.Add(revealToggle(passphraseField, reveal))
+	cleanup.Add(dom.OnInput(passphraseField, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		updateStrength()
+	}))
+	cleanup.Add(dialog.OnClose(func(ctx jsutil.AsyncContext, evt dom.Event) {
+		dom.SetValue(passphraseField, "")
+		dom.RemoveChildren(feedback)
+		strength.Set("value", 0)
+		cleanup.Do()
+	}))
+
+	cleanup.Add(u.showModal(dialog, dialogElem, passphraseField))
+	sig.Wait(ctx)
+	return
+}
+
+// setPassphraseStrength updates a meter and accompanying feedback to reflect
+// the strength of the passphrase.
+func (u *UI) setPassphraseStrength(meter, feedback js.Value, passphrase string) {
+	score, text := keys.PassphraseStrength(passphrase)
+	meter.Set("value", score)
+	dom.RemoveChildren(feedback)
+	dom.AppendChild(feedback, u.dom.NewText(text), nil)
+}
+
+// revealToggle configures a button to switch a passphrase field between
+// masked and plain text. The field is masked when the toggle is configured,
+// so that each time a dialog opens the passphrase starts out hidden.
+func revealToggle(field, toggle js.Value) jsutil.CleanupFunc {
+	setRevealed := func(revealed bool) {
+		if revealed {
+			dom.SetType(field, "text")
+			dom.SetAttribute(toggle, "title", "Hide passphrase")
+		} else {
+			dom.SetType(field, "password")
+			dom.SetAttribute(toggle, "title", "Show passphrase")
+		}
+		dom.SetAttribute(toggle, "aria-pressed", fmt.Sprint(revealed))
+	}
+	setRevealed(false)
+	return dom.OnClick(toggle, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		setRevealed(dom.Type(field) == "password")
+	})
+}
+
+// promptReencrypt displays a dialog prompting the user for the current and
+// new passphrases for a key.
+func (u *UI) promptReencrypt(ctx jsutil.AsyncContext, id keys.ID) (ok bool, oldPassphrase, newPassphrase string) {
+	k := u.keyByID(id)
+	if k == nil {
+		u.setError(fmt.Errorf("failed to change passphrase for key ID %s: not found", id))
+		return
+	}
+
+	dialogElem := u.dom.GetElement("reencryptDialog")
+	dialog := dom.NewDialog(dialogElem)
+	form := u.dom.GetElement("reencryptForm")
+	name := u.dom.GetElement("reencryptName")
+	oldField := u.dom.GetElement("reencryptOld")
+	newField := u.dom.GetElement("reencryptNew")
+	oldReveal := u.dom.GetElement("reencryptOldReveal")
+	newReveal := u.dom.GetElement("reencryptNewReveal")
+	strength := u.dom.GetElement("reencryptStrength")
+	feedback := u.dom.GetElement("reencryptFeedback")
+	okButton := u.dom.GetElement("reencryptOk")
+	cancel := u.dom.GetElement("reencryptCancel")
+	dom.AppendChild(name, u.dom.NewText(k.Name), nil)
+	u.setPassphraseStrength(strength, feedback, "")
+
+	sig := newSignal()
+	var cleanup jsutil.CleanupFuncs
+	cleanup.Add(dom.OnSubmit(form, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		ok = true
+		oldPassphrase = dom.Value(oldField)
+		newPassphrase = dom.Value(newField)
+		dialog.Close()
+		sig.Notify()
+	}))
+	cleanup.Add(dom.OnClick(cancel, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		dialog.Close()
+		sig.Notify()
+	}))
+	for _, field := range []js.Value{oldField, newField} {
+		cleanup.Add(onDialogKeys(field, okButton, cancel))
+	}
+	cleanup.Add(revealToggle(oldField, oldReveal))
+	cleanup.Add(revealToggle(newField, newReveal))
+	cleanup.Add(dom.OnInput(newField, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		u.setPassphraseStrength(strength, feedback, dom.Value(newField))
+	}))
+	cleanup.Add(dialog.OnClose(func(ctx jsutil.AsyncContext, evt dom.Event) {
+		dom.RemoveChildren(name)
+		dom.SetValue(oldField, "")
+		dom.SetValue(newField, "")
+		dom.RemoveChildren(feedback)
+		strength.Set("value", 0)
+		cleanup.Do()
+	}))
+
+	cleanup.Add(u.showModal(dialog, dialogElem, oldField))
+	sig.Wait(ctx)
+	return
+}
+
+// reencrypt changes the passphrase protecting the key with the specified ID.
+// A dialog prompts the user for the current and new passphrases.
+func (u *UI) reencrypt(ctx jsutil.AsyncContext, id keys.ID) {
+	ok, oldPassphrase, newPassphrase := u.promptReencrypt(ctx, id)
+	if !ok {
+		return
+	}
+
+	if err := u.mgr.Reencrypt(ctx, id, oldPassphrase, newPassphrase); err != nil {
+		u.setError(u.failed(msgFailedPassphrase, err))
+		return
+	}
+	u.setError(nil)
+	u.updateKeys(ctx)
+}
+
+// promptOrigins displays a dialog prompting the user for the origins of the
+// clients allowed to use a key, one per line.
+func (u *UI) promptOrigins(ctx jsutil.AsyncContext, id keys.ID) (ok bool, origins []string) {
+	k := u.keyByID(id)
+	if k == nil {
+		u.setError(fmt.Errorf("failed to edit allowed sites for key ID %s: not found", id))
+		return
+	}
+
+	dialogElem := u.dom.GetElement("originsDialog")
+	dialog := dom.NewDialog(dialogElem)
+	form := u.dom.GetElement("originsForm")
+	name := u.dom.GetElement("originsName")
+	field := u.dom.GetElement("originsList")
+	okButton := u.dom.GetElement("originsOk")
+	cancel := u.dom.GetElement("originsCancel")
+	dom.AppendChild(name, u.dom.NewText(k.Name), nil)
+	dom.SetValue(field, strings.Join(k.AllowedOrigins, "\n"))
+
+	sig := newSignal()
+	var cleanup jsutil.CleanupFuncs
+	cleanup.Add(dom.OnSubmit(form, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		ok = true
+		origins = strings.Split(dom.Value(field), "\n")
+		dialog.Close()
+		sig.Notify()
+	}))
+	cleanup.Add(dom.OnClick(cancel, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		dialog.Close()
+		sig.Notify()
+	}))
+	cleanup.Add(onDialogKeys(field, okButton, cancel))
+	cleanup.Add(dialog.OnClose(func(ctx jsutil.AsyncContext, evt dom.Event) {
+		dom.RemoveChildren(name)
+		dom.SetValue(field, "")
+		cleanup.Do()
+	}))
+
+	cleanup.Add(u.showModal(dialog, dialogElem, field))
+	sig.Wait(ctx)
+	return
+}
+
+// editOrigins changes the clients allowed to use the key with the specified
+// ID. A dialog prompts the user for the allowed origins.
+func (u *UI) editOrigins(ctx jsutil.AsyncContext, id keys.ID) {
+	ok, origins := u.promptOrigins(ctx, id)
+	if !ok {
+		return
+	}
+
+	if err := u.mgr.SetAllowedOrigins(ctx, id, origins); err != nil {
+		u.setError(fmt.Errorf("failed to set allowed sites: %w", err))
+		return
+	}
+	u.setError(nil)
+	u.updateKeys(ctx)
+}
+
+// loadDetails returns a human-readable description of the key that is about
+// to be loaded, so the user can confirm it is the intended one.
+func loadDetails(k *displayedKey) string {
+	if k.Fingerprint == "" {
+		return "Encrypted key; details are available once loaded"
+	}
+
+	alg := k.Algorithm
+	if k.BitSize > 0 {
+		alg = fmt.Sprintf("%s %d-bit", alg, k.BitSize)
+	}
+	return fmt.Sprintf("%s %s", alg, k.Fingerprint)
+}
+
+// promptLoad displays a dialog prompting the user to confirm that a key
+// should be loaded. The dialog displays the key's name, type and fingerprint.
+func (u *UI) promptLoad(ctx jsutil.AsyncContext, k *displayedKey) (yes bool) {
+	dialog := dom.NewDialog(u.dom.GetElement("loadDialog"))
+	form := u.dom.GetElement("loadForm")
+	name := u.dom.GetElement("loadName")
+	details := u.dom.GetElement("loadDetails")
+	no := u.dom.GetElement("loadNo")
+	dom.AppendChild(name, u.dom.NewText(k.Name), nil)
+	dom.AppendChild(details, u.dom.NewText(loadDetails(k)), nil)
+
+	sig := newSignal()
+	var cleanup jsutil.CleanupFuncs
+	cleanup.Add(dom.OnSubmit(form, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		yes = true
+		dialog.Close()
+		sig.Notify()
+	}))
+	cleanup.Add(dom.OnClick(no, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		dialog.Close()
+		sig.Notify()
+	}))
+	cleanup.Add(dialog.OnClose(func(ctx jsutil.AsyncContext, evt dom.Event) {
+		dom.RemoveChildren(name)
+		dom.RemoveChildren(details)
+		cleanup.Do()
+	}))
+
+	dialog.ShowModal()
+	sig.Wait(ctx)
+	return
+}
+
+// promptUnload displays a dialog prompting the user to confirm that a key
+// should be unloaded.
+func (u *UI) promptUnload(ctx jsutil.AsyncContext, id keys.ID) (yes bool) {
+	k := u.keyByID(id)
+	if k == nil {
+		u.setError(fmt.Errorf("%s ID %s: not found", u.t(msgFailedUnload), id))
+		return
+	}
+
+	dialog := dom.NewDialog(u.dom.GetElement("unloadDialog"))
+	form := u.dom.GetElement("unloadForm")
+	name := u.dom.GetElement("unloadName")
+	no := u.dom.GetElement("unloadNo")
+	dom.AppendChild(name, u.dom.NewText(k.Name), nil)
+
+	sig := newSignal()
+	var cleanup jsutil.CleanupFuncs
+	cleanup.Add(dom.OnSubmit(form, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		yes = true
+		dialog.Close()
+		sig.Notify()
+	}))
+	cleanup.Add(dom.OnClick(no, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		dialog.Close()
+		sig.Notify()
+	}))
+	cleanup.Add(dialog.OnClose(func(ctx jsutil.AsyncContext, evt dom.Event) {
+		dom.RemoveChildren(name)
+		cleanup.Do()
+	}))
+
+	dialog.ShowModal()
+	sig.Wait(ctx)
+	return
+}
+
+// unload unloads the specified key.  If the user has requested it, a dialog
+// prompts the user to confirm that the key should be unloaded.
+func (u *UI) unload(ctx jsutil.AsyncContext, id keys.ID) {
+	prefs, err := u.mgr.Preferences(ctx)
+	if err != nil {
+		u.setError(fmt.Errorf("failed to read preferences: %w", err))
+		return
+	}
+	if prefs.ConfirmUnload {
+		if yes := u.promptUnload(ctx, id); !yes {
+			return
+		}
+	}
+
+	if err := u.mgr.Unload(ctx, id); err != nil {
+		u.setError(fmt.Errorf("%s ID %s: %w", u.t(msgFailedUnload), id, err))
+		return
+	}
+	u.setError(nil)
+	u.updateKeys(ctx)
+}
+
+// setRSASignatureAlgorithm sets the signature algorithm used for an RSA key.
+func (u *UI) setRSASignatureAlgorithm(ctx jsutil.AsyncContext, id keys.ID, alg string) {
+	if err := u.mgr.SetRSASignatureAlgorithm(ctx, id, alg); err != nil {
+		u.setError(fmt.Errorf("failed to set signature algorithm for key ID %s: %w", id, err))
+		return
+	}
+	u.setError(nil)
+	u.updateKeys(ctx)
+}
+
+// setAutoLoad sets whether the key with the specified ID is loaded
+// automatically when the browser starts.
+func (u *UI) setAutoLoad(ctx jsutil.AsyncContext, id keys.ID, autoLoad bool) {
+	if err := u.mgr.SetAutoLoad(ctx, id, autoLoad); err != nil {
+		u.setError(fmt.Errorf("failed to update key ID %s: %w", id, err))
+		return
+	}
+	u.setError(nil)
+	u.updateKeys(ctx)
+}
+
+// forgetPassphrases forgets all passphrases cached when loading keys.
+func (u *UI) forgetPassphrases(ctx jsutil.AsyncContext, _ dom.Event) {
+	if err := u.mgr.ForgetPassphrases(ctx); err != nil {
+		u.setError(fmt.Errorf("failed to forget passphrases: %w", err))
+		return
+	}
+	u.setError(nil)
+	u.setStatus("Cached passphrases forgotten.")
+	u.updateKeys(ctx)
+}
+
+// verify checks that the agent can sign using the supplied loaded key. On
+// success, a checkmark is displayed after btn.
+func (u *UI) verify(ctx jsutil.AsyncContext, k *displayedKey, btn js.Value) {
+	l, err := k.LoadedKey()
+	if err != nil {
+		u.setError(fmt.Errorf("failed to verify key %s: %w", k.Name, err))
+		return
+	}
+	if err := u.mgr.TestSign(ctx, l); err != nil {
+		u.setError(fmt.Errorf("failed to verify key %s: %w", k.Name, err))
+		return
+	}
+	u.setError(nil)
+	if existing := u.dom.GetElement(verifiedID(k.ID)); !existing.IsNull() {
+		return
+	}
+	dom.InsertAfter(btn, u.dom.NewElement("span"), func(mark js.Value) {
+		dom.SetAttribute(mark, "id", verifiedID(k.ID))
+		dom.SetAttribute(mark, "title", "The agent signed data using this key")
+		dom.AddClass(mark, "keyVerified")
+		dom.AppendChild(mark, u.dom.NewText("\u2713"), nil)
+	})
+}
+
+const (
+	// copiedDuration is the period for which confirmation that text was
+	// copied is displayed.
+	copiedDuration = 2 * time.Second
+)
+
+// copyFingerprint copies the fingerprint of the supplied key to the
+// clipboard. On success, a confirmation is briefly displayed after btn.
+func (u *UI) copyFingerprint(ctx jsutil.AsyncContext, k *displayedKey, btn js.Value) {
+	if err := u.writeClipboard(ctx, k.Fingerprint); err != nil {
+		u.setError(fmt.Errorf("failed to copy fingerprint: %w", err))
+		return
+	}
+	u.setError(nil)
+	u.showCopied(btn)
+}
+
+// showCopied briefly displays confirmation after btn that text was copied to
+// the clipboard.
+func (u *UI) showCopied(btn js.Value) {
+	dom.InsertAfter(btn, u.dom.NewElement("span"), func(tip js.Value) {
+		dom.AddClass(tip, "keyCopied")
+		dom.SetAttribute(tip, "role", "status")
+		dom.AppendChild(tip, u.dom.NewText("Copied"), nil)
+		jsutil.SetTimeout(copiedDuration, func() {
+			if parent := tip.Get("parentNode"); !parent.IsNull() {
+				parent.Call("removeChild", tip)
+			}
+		})
+	})
+}
+
+// setDisabled disables or enables the key with the specified ID.
+func (u *UI) setDisabled(ctx jsutil.AsyncContext, id keys.ID, disabled bool) {
+	if err := u.mgr.SetDisabled(ctx, id, disabled); err != nil {
+		u.setError(fmt.Errorf("failed to update key ID %s: %w", id, err))
+		return
+	}
+	u.setError(nil)
+	u.updateKeys(ctx)
+}
+
+// promptRemove displays a dialog prompting the user to confirm that a key
+// should be removed.
+func (u *UI) promptRemove(ctx jsutil.AsyncContext, id keys.ID) (yes bool) {
+	k := u.keyByID(id)
+	if k == nil {
+		u.setError(fmt.Errorf("%s ID %s: not found", u.t(msgFailedRemove), id))
+		return
+	}
+
+	dialog := dom.NewDialog(u.dom.GetElement("removeDialog"))
+	form := u.dom.GetElement("removeForm")
+	name := u.dom.GetElement("removeName")
+	no := u.dom.GetElement("removeNo")
+	dom.AppendChild(name, u.dom.NewText(k.Name), nil)
+
+	sig := newSignal()
+	var cleanup jsutil.CleanupFuncs
+	cleanup.Add(dom.OnSubmit(form, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		yes = true
+		dialog.Close()
+		sig.Notify()
+	}))
+	cleanup.Add(dom.OnClick(no, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		dialog.Close()
+		sig.Notify()
+	}))
+	cleanup.Add(dialog.OnClose(func(ctx jsutil.AsyncContext, evt dom.Event) {
+		dom.RemoveChildren(name)
+		cleanup.Do()
+	}))
+
+	dialog.ShowModal()
+	sig.Wait(ctx)
+	return
+}
+
+const (
+	// removeUndoWindow is the period after a key is removed during which
+	// the removal may be undone.
+	removeUndoWindow = 10 * time.Second
+)
+
+// remove removes the key with the specified ID.  A dialog prompts the user to
+// confirm that the key should be removed.
+//
+// The key is removed from storage immediately. For a short period afterwards,
+// the user is offered to undo the removal, which restores the key. Once the
+// period expires or the offer is dismissed, the removal is committed.
+func (u *UI) remove(ctx jsutil.AsyncContext, id keys.ID) {
+	if yes := u.promptRemove(ctx, id); !yes {
+		return
+	}
+
+	name := string(id)
+	if k := u.keyByID(id); k != nil {
+		name = k.Name
+	}
+	// Only the most recent removal may be undone.
+	u.dismissUndo(0)
+	if err := u.mgr.Remove(ctx, id); err != nil {
+		u.setError(fmt.Errorf("%s ID %s: %w", u.t(msgFailedRemove), id, err))
+		return
+	}
+	u.setError(nil)
+	u.updateKeys(ctx)
+
+	if u.undoWindow <= 0 {
+		return
+	}
+
+	u.removeMu.Lock()
+	u.undoable = id
+	u.undoGen++
+	gen := u.undoGen
+	u.removeMu.Unlock()
+
+	dom.RemoveChildren(u.undoRemoveText)
+	dom.AppendChild(u.undoRemoveText, u.dom.NewText(fmt.Sprintf("Key '%s' removed", name)), nil)
+	dom.Show(u.undoRemove)
+
+	jsutil.Async(func(ctx jsutil.AsyncContext) (js.Value, error) {
+		time.Sleep(u.undoWindow)
+		u.expireUndo(ctx, gen)
+		return js.Undefined(), nil
+	})
+}
+
+// dismissUndo withdraws the offer to undo a removal, and returns the ID of
+// the key whose removal could be undone. gen identifies the expected
+// removal; zero matches any. InvalidID is returned if no matching removal
+// could be undone.
+func (u *UI) dismissUndo(gen int) keys.ID {
+	u.removeMu.Lock()
+	defer u.removeMu.Unlock()
+
+	if u.undoable == keys.InvalidID || (gen != 0 && gen != u.undoGen) {
+		return keys.InvalidID
+	}
+	id := u.undoable
+	u.undoable = keys.InvalidID
+	dom.Hide(u.undoRemove)
+	return id
+}
+
+// expireUndo withdraws the offer to undo a removal without accepting it. The
+// removal is committed by discarding the copy of the removed key retained by
+// the manager. gen is as for dismissUndo.
+func (u *UI) expireUndo(ctx jsutil.AsyncContext, gen int) {
+	u.removeMu.Lock()
+	id := u.undoable
+	if gen != 0 && gen != u.undoGen {
+		id = keys.InvalidID
+	}
+	u.removeMu.Unlock()
+	if id == keys.InvalidID {
+		return
+	}
+
+	// Discard the key before withdrawing the offer, so that the offer is
+	// not withdrawn while the key may still be restored.
+	if err := u.mgr.DiscardRemoved(ctx, id); err != nil {
+		u.setError(fmt.Errorf("failed to discard removed key ID %s: %w", id, err))
+	}
+	u.dismissUndo(gen)
+}
+
+// undoRemoveKey undoes the most recent removal, restoring the removed key.
+func (u *UI) undoRemoveKey(ctx jsutil.AsyncContext, _ dom.Event) {
+	id := u.dismissUndo(0)
+	if id == keys.InvalidID {
+		return
+	}
+	if err := u.mgr.Restore(ctx, id); err != nil {
+		u.setError(fmt.Errorf("failed to restore key ID %s: %w", id, err))
+		return
+	}
+	u.setError(nil)
+	u.updateKeys(ctx)
+}
+
+// displayedKey represents a key displayed in the UI.
+type displayedKey keys.Key
+
+// LoadedKey returns the corresponding LoadedKey.
+func (d *displayedKey) LoadedKey() (*keys.LoadedKey, error) {
+	blob, err := base64.StdEncoding.DecodeString(d.Blob)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode blob: %w", err)
+	}
+
+	l := &keys.LoadedKey{
+		Type:    d.Type,
+		Comment: d.AgentComment,
+	}
+	l.SetBlob(blob)
+	return l, nil
+}
+
+// validate flags the key as erroneous if its public key cannot be decoded,
+// so that it is displayed with a warning rather than as if it were usable.
+func (d *displayedKey) validate() {
+	if d.Error != "" || d.Blob == "" {
+		return
+	}
+	if _, err := d.LoadedKey(); err != nil {
+		d.Error = err.Error()
+	}
+}
+
+// displayedKeys returns the keys currently displayed in the UI.
+func (u *UI) displayedKeys() []*displayedKey {
+	return u.keys
+}
+
+// nameInUse returns true if a configured key already has the supplied name,
+// compared as the name would be stored (see keys.SameName).
+func (u *UI) nameInUse(name string) bool {
+	for _, k := range u.displayedKeys() {
+		if k.ID != keys.InvalidID && keys.SameName(k.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *UI) keyByID(id keys.ID) *displayedKey {
+	if id == keys.InvalidID {
+		return nil
+	}
+
+	for _, k := range u.keys {
+		if k.ID == id {
+			return k
+		}
+	}
+	return nil
+}
+
+func (u *UI) keyByName(name string) *displayedKey {
+	for _, k := range u.keys {
+		if k.Name == name {
+			return k
+		}
+	}
+	return nil
+}
+
+// lifetimeText returns a description of the remaining lifetime of the key.
+// The empty string is returned if the key does not expire.
+func (d *displayedKey) lifetimeText(now time.Time) string {
+	if d.Expiry.IsZero() {
+		return ""
+	}
+
+	remaining := d.Expiry.Sub(now).Round(time.Second)
+	if remaining <= 0 {
+		return "Expired"
+	}
+	return fmt.Sprintf("Expires in %s", remaining)
+}
+
+// lastUsedText returns a description of when the key was last used.
+func (d *displayedKey) lastUsedText(now time.Time) string {
+	switch {
+	case d.ID == keys.InvalidID:
+		// Usage is only recorded for configured keys.
+		return "Unknown"
+	case d.LastUsed.IsZero():
+		return "Never"
+	default:
+		return reltime.Format(d.LastUsed, now)
+	}
+}
+
+// sizeText returns a description of the key's algorithm and size (e.g.,
+// 'RSA, 2048 bits'). The size of ECDSA and Ed25519 keys is implied by the
+// algorithm, so it is omitted. The empty string is returned if the algorithm
+// is unknown.
+func (d *displayedKey) sizeText() string {
+	switch {
+	case d.Algorithm == "":
+		return ""
+	case d.sizeVaries() && d.BitSize > 0:
+		return fmt.Sprintf("%s, %d bits", d.Algorithm, d.BitSize)
+	default:
+		return d.Algorithm
+	}
+}
+
+// sizeVaries indicates that the key's algorithm supports keys of arbitrary
+// size, such that the size determines the key's strength.
+func (d *displayedKey) sizeVaries() bool {
+	return d.Algorithm == "RSA" || d.Algorithm == "DSA"
+}
+
+// weak indicates that the key is smaller than minBits, and warrants
+// replacement.
+func (d *displayedKey) weak(minBits int) bool {
+	return d.sizeVaries() && d.BitSize > 0 && d.BitSize < minBits
+}
+
+// certExpiryWarning is the period before a certificate expires during which
+// the user is warned.
+const certExpiryWarning = 7 * 24 * time.Hour
+
+// certificateText returns a description of the key's certificate, and
+// whether the certificate warrants the user's attention (e.g., it has
+// expired, or will soon). The empty string is returned if the key has no
+// certificate.
+func (d *displayedKey) certificateText(now time.Time) (text string, warn bool) {
+	if !d.Certificate {
+		return "", false
+	}
+
+	principals := "any principal"
+	if len(d.Principals) > 0 {
+		principals = strings.Join(d.Principals, ", ")
+	}
+
+	var validity string
+	switch {
+	case !d.ValidAfter.IsZero() && now.Before(d.ValidAfter):
+		validity, warn = "valid "+reltime.Format(d.ValidAfter, now), true
+	case d.ValidBefore.IsZero():
+		validity = "does not expire"
+	case !now.Before(d.ValidBefore):
+		validity, warn = "expired "+reltime.Format(d.ValidBefore, now), true
+	default:
+		validity = "expires " + reltime.Format(d.ValidBefore, now)
+		warn = d.ValidBefore.Sub(now) < certExpiryWarning
+	}
+	return fmt.Sprintf("Certificate for %s; %s", principals, validity), warn
+}
+
+// buttonKind is the type of button displayed for a key.
+type buttonKind int
+
+const (
+	// LoadButton indicates that the button loads the key into the agent.
 	LoadButton buttonKind = iota
 	// UnloadButton indicates that the button unloads the key from the
 	// agent.
 	UnloadButton
 	// RemoveButton indicates that the button removes the key.
 	RemoveButton
+	// ReconcileLoadButton indicates that the button loads the key into
+	// the agent from the reconcile view.
+	ReconcileLoadButton
+	// ReencryptButton indicates that the button changes the passphrase
+	// protecting the key.
+	ReencryptButton
+	// DisableButton indicates that the button disables or enables the
+	// key.
+	DisableButton
+	// VerifyButton indicates that the button checks that the agent can
+	// sign using the key.
+	VerifyButton
+	// OriginsButton indicates that the button edits the clients allowed
+	// to use the key.
+	OriginsButton
+	// CopyFingerprintButton indicates that the button copies the key's
+	// fingerprint to the clipboard.
+	CopyFingerprintButton
+	// TagsButton indicates that the button edits the tags by which the
+	// key is grouped.
+	TagsButton
+	// PriorityButton indicates that the button edits the priority with
+	// which the key is offered to servers.
+	PriorityButton
+	// ExportPrivateButton indicates that the button displays the key's
+	// private key so that it can be backed up.
+	ExportPrivateButton
+	// SSHConfigButton indicates that the button copies a ~/.ssh/config
+	// snippet using the key to the clipboard.
+	SSHConfigButton
+	// NoteButton indicates that the button edits the note describing the
+	// key.
+	NoteButton
+	// QRButton indicates that the button displays the key's public key as
+	// a QR code.
+	QRButton
+)
+
+// buttonID returns the value of the 'id' attribute to be assigned to the HTML
+// button.
+func buttonID(kind buttonKind, id keys.ID) string {
+	s := "unknown"
+	switch kind {
+	case LoadButton:
+		s = "load"
+	case UnloadButton:
+		s = "unload"
+	case RemoveButton:
+		s = "remove"
+	case ReconcileLoadButton:
+		s = "reconcile-load"
+	case ReencryptButton:
+		s = "reencrypt"
+	case DisableButton:
+		s = "disable"
+	case VerifyButton:
+		s = "verify"
+	case OriginsButton:
+		s = "origins"
+	case CopyFingerprintButton:
+		s = "copy-fingerprint"
+	case TagsButton:
+		s = "tags"
+	case PriorityButton:
+		s = "priority"
+	case ExportPrivateButton:
+		s = "export-private"
+	case SSHConfigButton:
+		s = "ssh-config"
+	case NoteButton:
+		s = "note"
+	case QRButton:
+		s = "qr"
+	}
+	return fmt.Sprintf("%s-%s", s, id)
+}
+
+// adoptButtonID returns the value of the 'id' attribute to be assigned to the
+// HTML button that adopts the i'th external key. External keys have no ID, so
+// they are identified by their position in the reconcile view.
+func adoptButtonID(i int) string {
+	return fmt.Sprintf("adopt-%d", i)
+}
+
+// weakKeyID returns the value of the 'id' attribute to be assigned to the
+// HTML element flagging the key as weak. Keys that are not configured are
+// identified by their public key material.
+func weakKeyID(k *displayedKey) string {
+	if k.ID == keys.InvalidID {
+		return fmt.Sprintf("weak-blob-%s", k.Blob)
+	}
+	return fmt.Sprintf("weak-%s", k.ID)
+}
+
+// adoptLoadedButtonID returns the value of the 'id' attribute to be assigned
+// to the HTML button that adopts a key loaded in the agent, but not
+// configured. Such keys have no ID, so they are identified by their public
+// key material.
+func adoptLoadedButtonID(k *displayedKey) string {
+	return fmt.Sprintf("adoptLoaded-%s", k.Blob)
+}
+
+// keyErrorID returns the value of the 'id' attribute to be assigned to the
+// HTML row explaining why the key could not be read. Keys that are not
+// configured are identified by their public key material.
+func keyErrorID(k *displayedKey) string {
+	if k.ID == keys.InvalidID {
+		return fmt.Sprintf("error-blob-%s", k.Blob)
+	}
+	return fmt.Sprintf("error-%s", k.ID)
+}
+
+// algorithmSelectID returns the value of the 'id' attribute to be assigned to
+// the HTML select element used to choose the key's RSA signature algorithm.
+func algorithmSelectID(id keys.ID) string {
+	return fmt.Sprintf("rsa-algorithm-%s", id)
+}
+
+// autoLoadCheckboxID returns the value of the 'id' attribute to be assigned to
+// the HTML checkbox used to choose whether the key is loaded automatically.
+func autoLoadCheckboxID(id keys.ID) string {
+	return fmt.Sprintf("auto-load-%s", id)
+}
+
+// noteID returns the value of the 'id' attribute to be assigned to the HTML
+// element displaying the note describing the key. Only configured keys have
+// notes.
+func noteID(id keys.ID) string {
+	return fmt.Sprintf("note-%s", id)
+}
+
+// verifiedID returns the value of the 'id' attribute to be assigned to the
+// checkmark indicating that the agent signed data using the key.
+func verifiedID(id keys.ID) string {
+	return fmt.Sprintf("verified-%s", id)
+}
+
+// rowID returns the value of the 'id' attribute to be assigned to the HTML
+// table row displaying the key.
+func rowID(id keys.ID) string {
+	return fmt.Sprintf("row-%s", id)
+}
+
+// reorder returns the IDs of configured keys in the order that results from
+// moving the key with ID moved to the position of the key with ID target.
+// Keys without a valid ID are omitted.
+func reorder(disp []*displayedKey, moved, target keys.ID) []keys.ID {
+	var ids []keys.ID
+	from, to := -1, -1
+	for _, k := range disp {
+		if k.ID == keys.InvalidID {
+			continue
+		}
+		if k.ID == moved {
+			from = len(ids)
+		}
+		if k.ID == target {
+			to = len(ids)
+		}
+		ids = append(ids, k.ID)
+	}
+	if from < 0 || to < 0 || from == to {
+		return ids
+	}
+
+	ids = append(ids[:from], ids[from+1:]...)
+	return append(ids[:to], append([]keys.ID{moved}, ids[to:]...)...)
+}
+
+// drop moves the key currently being dragged to the position of the key with
+// the specified ID.
+func (u *UI) drop(ctx jsutil.AsyncContext, target keys.ID) {
+	moved := u.dragging
+	u.dragging = keys.InvalidID
+	if moved == keys.InvalidID || moved == target {
+		return
+	}
+
+	if err := u.mgr.SetPositions(ctx, reorder(u.displayedKeys(), moved, target)); err != nil {
+		u.setError(fmt.Errorf("failed to reorder keys: %w", err))
+		return
+	}
+	u.updateKeys(ctx)
+}
+
+// setKeys refreshes the UI to reflect the keys that should be
+// displayed.
+func (u *UI) setKeys(newKeys []*displayedKey) {
+	// Cleanup elements and resources for all previous keys.
+	dom.RemoveChildren(u.keysData)
+	dom.RemoveChildren(u.externalData)
+	dom.RemoveChildren(u.unloadedData)
+	u.keysCleanup.Do()
+	u.keysCleanup = &jsutil.CleanupFuncs{}
+
+	// Construct elements for the keys on the current page only, so that
+	// long lists remain responsive.
+	start, end := u.pageBounds(len(newKeys))
+	now := time.Now()
+	u.appendKeyRows(newKeys[start:end], hasTags(newKeys), now)
+	u.setReconcile(newKeys)
+
+	// Update internal state after DOM is updated. Otherwise, callers (e.g.,
+	// our end-to-end test) may look for the new DOM elements before they
+	// are available.
+	u.keys = newKeys
+
+	// Loading all keys is only meaningful if some are not yet loaded.
+	u.loadAllButton.Set("disabled", len(u.unloadedKeys()) == 0)
+	// Likewise, unloading all keys is only meaningful if some are loaded.
+	u.unloadAllButton.Set("disabled", len(u.loadedKeys()) == 0)
+	u.updateSelection()
+
+	u.updateAgentStatus()
+	u.countdown()
+}
+
+// appendKeyRow appends a row displaying the key to the table of keys. A key
+// may be displayed in several rows (e.g., when it is grouped under several
+// tags); element IDs are only assigned in its primary row, so that they remain
+// unique.
+func (u *UI) appendKeyRow(k *displayedKey, now time.Time, primary bool) {
+	setID := func(elem js.Value, id string) {
+		if primary {
+			dom.SetAttribute(elem, "id", id)
+		}
+	}
+
+	dom.AppendChild(u.keysData, u.dom.NewElement("tr"), func(row js.Value) {
+		if k.Disabled {
+			dom.AddClass(row, "keyDisabled")
+		}
+		if k.Error != "" {
+			dom.AddClass(row, "keyError")
+		}
+		if k.ID == keys.InvalidID {
+			dom.AddClass(row, "keyExternal")
+		}
+		if u.compact {
+			dom.AddClass(row, "keyCompact")
+		}
+
+		// Only keys with a valid ID may be reordered, and only
+		// while they are displayed in the order arranged by
+		// the user.
+		if k.ID != keys.InvalidID && u.sortColumn == "" {
+			setID(row, rowID(k.ID))
+			row.Set("draggable", true)
+			u.keysCleanup.Add(dom.OnDragStart(row, func(ctx jsutil.AsyncContext, evt dom.Event) {
+				u.dragging = k.ID
+			}))
+			u.keysCleanup.Add(dom.OnDragOver(row, func(ctx jsutil.AsyncContext, evt dom.Event) {}))
+			u.keysCleanup.Add(dom.OnDrop(row, func(ctx jsutil.AsyncContext, evt dom.Event) {
+				u.drop(ctx, k.ID)
+			}))
+		}
+
+		// Selection and encryption
+		dom.AppendChild(row, u.dom.NewElement("td"), func(cell js.Value) {
+			if k.ID != keys.InvalidID {
+				dom.AppendChild(cell, u.dom.NewElement("input"), func(box js.Value) {
+					dom.SetAttribute(box, "type", "checkbox")
+					setID(box, selectCheckboxID(k.ID))
+					dom.AddClass(box, selectClass)
+					dom.SetAttribute(box, selectKeyAttr, string(k.ID))
+					dom.SetAttribute(box, "title", "Select this key; hold Shift to select a range of keys")
+					dom.SetChecked(box, u.selected[k.ID])
+					u.keysCleanup.Add(dom.OnClick(box, func(ctx jsutil.AsyncContext, evt dom.Event) {
+						u.selectKey(k.ID, dom.Checked(box), evt.ShiftKey())
+					}))
+				})
+			}
+			dom.AppendChild(cell, u.dom.NewElement("div"), func(div js.Value) {
+				dom.AddClass(div, "keyEncrypted")
+				switch {
+				case k.Error != "":
+					dom.SetAttribute(div, "title", fmt.Sprintf("Cannot be used: %s", k.Error))
+					dom.AppendChild(div, u.dom.NewText("\u26A0"), nil)
+				case k.Loaded:
+					// The decrypted key is held by the agent.
+				case k.SecurityKey:
+					dom.SetAttribute(div, "title", fmt.Sprintf("Cannot be loaded: %s", k.Unsupported))
+					dom.AppendChild(div, u.dom.NewText("\u26A0"), nil)
+				case k.Unsupported != "":
+					dom.SetAttribute(div, "title", fmt.Sprintf("Encrypted; cannot be loaded: %s. Re-export it with ssh-keygen -p", k.Unsupported))
+					dom.AppendChild(div, u.dom.NewText("\U0001F512\u26A0"), nil)
+				case k.Encrypted:
+					dom.SetAttribute(div, "title", "Encrypted; requires a passphrase to load")
+					dom.AppendChild(div, u.dom.NewText("\U0001F512"), nil)
+				}
+			})
+		})
+
+		// Key name
+		dom.AppendChild(row, u.dom.NewElement("td"), func(cell js.Value) {
+			dom.AppendChild(cell, u.dom.NewElement("div"), func(div js.Value) {
+				dom.AddClass(div, "keyName")
+				dom.AppendChild(div, u.dom.NewText(k.Name), nil)
+				if k.ID == keys.InvalidID {
+					dom.AppendChild(div, u.dom.NewElement("span"), func(span js.Value) {
+						dom.AddClass(span, "keyBadge")
+						dom.SetAttribute(span, "title", "Loaded in the agent by something other than this extension; not configured here")
+						dom.AppendChild(span, u.dom.NewText("External"), nil)
+					})
+				}
+				if k.PublicOnly {
+					dom.AppendChild(div, u.dom.NewElement("span"), func(span js.Value) {
+						dom.AddClass(span, "keyBadge")
+						dom.SetAttribute(span, "title", "Only the public key is configured; the key is usable while something else holds it in the agent")
+						dom.AppendChild(span, u.dom.NewText("Public key only"), nil)
+					})
+				}
+				if k.SecurityKey {
+					dom.AppendChild(div, u.dom.NewElement("span"), func(span js.Value) {
+						dom.AddClass(span, "keySecurityKey")
+						dom.SetAttribute(span, "title", "Backed by a hardware security key")
+						dom.AppendChild(span, u.dom.NewText("\U0001F511"), nil)
+					})
+				}
+				if k.ConfirmBeforeUse {
+					dom.AppendChild(div, u.dom.NewElement("span"), func(span js.Value) {
+						dom.AddClass(span, "keyConfirm")
+						dom.SetAttribute(span, "title", "Requires confirmation before each use")
+						dom.AppendChild(span, u.dom.NewText("\U0001F6E1"), nil)
+					})
+				}
+				if len(k.AllowedOrigins) > 0 {
+					dom.AppendChild(div, u.dom.NewElement("span"), func(span js.Value) {
+						dom.AddClass(span, "keyRestricted")
+						dom.SetAttribute(span, "title", fmt.Sprintf("Only usable by: %s", strings.Join(k.AllowedOrigins, ", ")))
+						dom.AppendChild(span, u.dom.NewText("\U0001F310"), nil)
+					})
+				}
+			})
+			if lifetime := k.lifetimeText(now); lifetime != "" {
+				dom.AppendChild(cell, u.dom.NewElement("div"), func(div js.Value) {
+					dom.AddClass(div, "keyLifetime")
+					if k.expiring(now) {
+						dom.AddClass(div, "keyExpiring")
+					}
+					// The remaining lifetime counts down without
+					// refreshing all keys; see countdown.
+					dom.SetAttribute(div, expiryAttr, strconv.FormatInt(k.Expiry.UnixMilli(), 10))
+					dom.AppendChild(div, u.dom.NewText(lifetime), nil)
+				})
+			}
+			if k.OneShot {
+				dom.AppendChild(cell, u.dom.NewElement("div"), func(div js.Value) {
+					dom.AddClass(div, "keyLifetime")
+					dom.AppendChild(div, u.dom.NewText("Unloads after next use"), nil)
+				})
+			}
+			if k.Note != "" {
+				dom.AppendChild(cell, u.dom.NewElement("div"), func(div js.Value) {
+					dom.AddClass(div, "keyNote")
+					setID(div, noteID(k.ID))
+					dom.SetAttribute(div, "title", k.Note)
+					dom.AppendChild(div, u.dom.NewText(k.Note), nil)
+				})
+			}
+		})
+
+		// Key comment
+		dom.AppendChild(row, u.dom.NewElement("td"), func(cell js.Value) {
+			dom.AppendChild(cell, u.dom.NewElement("div"), func(div js.Value) {
+				dom.AddClass(div, "keyComment")
+				dom.AppendChild(div, u.dom.NewText(k.Comment), nil)
+			})
+		})
+
+		// Controls
+		dom.AppendChild(row, u.dom.NewElement("td"), func(cell js.Value) {
+			dom.AppendChild(cell, u.dom.NewElement("div"), func(div js.Value) {
+				dom.AddClass(div, "keyControls")
+				// Copy fingerprint button
+				if k.Fingerprint != "" {
+					dom.AppendChild(div, u.dom.NewElement("button"), func(btn js.Value) {
+						dom.SetAttribute(btn, "type", "button")
+						if k.ID != keys.InvalidID {
+							setID(btn, buttonID(CopyFingerprintButton, k.ID))
+						}
+						dom.AddClass(btn, "keyCopyFingerprint")
+						dom.SetAttribute(btn, "title", k.Fingerprint)
+						dom.AppendChild(btn, u.dom.NewText(u.t(msgCopyFingerprint)), nil)
+						u.keysCleanup.Add(dom.OnClick(btn, func(ctx jsutil.AsyncContext, evt dom.Event) {
+							u.copyFingerprint(ctx, k, btn)
+						}))
+					})
+				}
+				if k.ID == keys.InvalidID {
+					// We only control keys with a valid ID, but
+					// keys loaded by something else may be adopted.
+					if k.Loaded && k.Error == "" {
+						dom.AppendChild(div, u.dom.NewElement("button"), func(btn js.Value) {
+							dom.SetAttribute(btn, "type", "button")
+							setID(btn, adoptLoadedButtonID(k))
+							dom.SetAttribute(btn, "title", "Configure this key using its public key, so that it is displayed by name")
+							dom.AppendChild(btn, u.dom.NewText(u.t(msgAdopt)), nil)
+							u.keysCleanup.Add(dom.OnClick(btn, func(ctx jsutil.AsyncContext, evt dom.Event) {
+								u.adoptLoaded(ctx, k)
+							}))
+						})
+					}
+					return
+				}
+
+				if k.Loaded {
+					// Unload button
+					dom.AppendChild(div, u.dom.NewElement("button"), func(btn js.Value) {
+						dom.SetAttribute(btn, "type", "button")
+						setID(btn, buttonID(UnloadButton, k.ID))
+						dom.AppendChild(btn, u.dom.NewText(u.t(msgUnload)), nil)
+						u.keysCleanup.Add(dom.OnClick(btn, func(ctx jsutil.AsyncContext, evt dom.Event) {
+							u.unload(ctx, k.ID)
+						}))
+					})
+					// Verify button
+					dom.AppendChild(div, u.dom.NewElement("button"), func(btn js.Value) {
+						dom.SetAttribute(btn, "type", "button")
+						setID(btn, buttonID(VerifyButton, k.ID))
+						btn.Set("disabled", k.Error != "")
+						dom.SetAttribute(btn, "title", "Check that the agent can sign using this key")
+						dom.AppendChild(btn, u.dom.NewText(u.t(msgVerify)), nil)
+						u.keysCleanup.Add(dom.OnClick(btn, func(ctx jsutil.AsyncContext, evt dom.Event) {
+							u.verify(ctx, k, btn)
+						}))
+					})
+				} else {
+					// Load button
+					dom.AppendChild(div, u.dom.NewElement("button"), func(btn js.Value) {
+						dom.SetAttribute(btn, "type", "button")
+						setID(btn, buttonID(LoadButton, k.ID))
+						btn.Set("disabled", k.Unsupported != "" || k.Disabled || k.Error != "")
+						dom.AppendChild(btn, u.dom.NewText(u.t(msgLoad)), nil)
+						u.keysCleanup.Add(dom.OnClick(btn, func(ctx jsutil.AsyncContext, evt dom.Event) {
+							u.load(ctx, k.ID)
+						}))
+					})
+				}
+
+				// Change passphrase button
+				dom.AppendChild(div, u.dom.NewElement("button"), func(btn js.Value) {
+					dom.SetAttribute(btn, "type", "button")
+					setID(btn, buttonID(ReencryptButton, k.ID))
+					btn.Set("disabled", k.Unsupported != "")
+					dom.AppendChild(btn, u.dom.NewText(u.t(msgChangePassphrase)), nil)
+					u.keysCleanup.Add(dom.OnClick(btn, func(ctx jsutil.AsyncContext, evt dom.Event) {
+						u.reencrypt(ctx, k.ID)
+					}))
+				})
+
+				// Export private key button
+				dom.AppendChild(div, u.dom.NewElement("button"), func(btn js.Value) {
+					dom.SetAttribute(btn, "type", "button")
+					setID(btn, buttonID(ExportPrivateButton, k.ID))
+					btn.Set("disabled", k.Unsupported != "" || k.PublicOnly)
+					dom.SetAttribute(btn, "title", "Display the private key so that it can be backed up")
+					dom.AppendChild(btn, u.dom.NewText(u.t(msgExportPrivate)), nil)
+					u.keysCleanup.Add(dom.OnClick(btn, func(ctx jsutil.AsyncContext, evt dom.Event) {
+						u.exportPrivate(ctx, k.ID)
+					}))
+				})
+
+				// Disable/enable button
+				dom.AppendChild(div, u.dom.NewElement("button"), func(btn js.Value) {
+					dom.SetAttribute(btn, "type", "button")
+					setID(btn, buttonID(DisableButton, k.ID))
+					label := u.t(msgDisable)
+					if k.Disabled {
+						label = u.t(msgEnable)
+					}
+					dom.AppendChild(btn, u.dom.NewText(label), nil)
+					u.keysCleanup.Add(dom.OnClick(btn, func(ctx jsutil.AsyncContext, evt dom.Event) {
+						u.setDisabled(ctx, k.ID, !k.Disabled)
+					}))
+				})
+
+				// Allowed sites button
+				dom.AppendChild(div, u.dom.NewElement("button"), func(btn js.Value) {
+					dom.SetAttribute(btn, "type", "button")
+					setID(btn, buttonID(OriginsButton, k.ID))
+					dom.SetAttribute(btn, "title", "Choose which clients may use this key")
+					dom.AppendChild(btn, u.dom.NewText(u.t(msgAllowedSites)), nil)
+					u.keysCleanup.Add(dom.OnClick(btn, func(ctx jsutil.AsyncContext, evt dom.Event) {
+						u.editOrigins(ctx, k.ID)
+					}))
+				})
+
+				// Tags button
+				dom.AppendChild(div, u.dom.NewElement("button"), func(btn js.Value) {
+					dom.SetAttribute(btn, "type", "button")
+					setID(btn, buttonID(TagsButton, k.ID))
+					dom.SetAttribute(btn, "title", "Choose the groups in which this key is displayed")
+					dom.AppendChild(btn, u.dom.NewText(u.t(msgTags)), nil)
+					u.keysCleanup.Add(dom.OnClick(btn, func(ctx jsutil.AsyncContext, evt dom.Event) {
+						u.editTags(ctx, k.ID)
+					}))
+				})
+
+				// Note button
+				dom.AppendChild(div, u.dom.NewElement("button"), func(btn js.Value) {
+					dom.SetAttribute(btn, "type", "button")
+					setID(btn, buttonID(NoteButton, k.ID))
+					dom.SetAttribute(btn, "title", "Describe what this key is used for")
+					dom.AppendChild(btn, u.dom.NewText(u.t(msgNote)), nil)
+					u.keysCleanup.Add(dom.OnClick(btn, func(ctx jsutil.AsyncContext, evt dom.Event) {
+						u.editNote(ctx, k.ID)
+					}))
+				})
+
+				// Priority button
+				dom.AppendChild(div, u.dom.NewElement("button"), func(btn js.Value) {
+					dom.SetAttribute(btn, "type", "button")
+					setID(btn, buttonID(PriorityButton, k.ID))
+					dom.SetAttribute(btn, "title", "Choose the order in which this key is offered to servers; keys with a higher priority are offered first")
+					dom.AppendChild(btn, u.dom.NewText(u.t(msgPriority)), nil)
+					u.keysCleanup.Add(dom.OnClick(btn, func(ctx jsutil.AsyncContext, evt dom.Event) {
+						u.editPriority(ctx, k.ID)
+					}))
+				})
+
+				// SSH config snippet button
+				dom.AppendChild(div, u.dom.NewElement("button"), func(btn js.Value) {
+					dom.SetAttribute(btn, "type", "button")
+					setID(btn, buttonID(SSHConfigButton, k.ID))
+					dom.SetAttribute(btn, "title", "Copy a ~/.ssh/config block that uses this key")
+					dom.AppendChild(btn, u.dom.NewText(u.t(msgSSHConfig)), nil)
+					u.keysCleanup.Add(dom.OnClick(btn, func(ctx jsutil.AsyncContext, evt dom.Event) {
+						u.copySSHConfig(ctx, k, btn)
+					}))
+				})
+
+				// QR code button
+				dom.AppendChild(div, u.dom.NewElement("button"), func(btn js.Value) {
+					dom.SetAttribute(btn, "type", "button")
+					setID(btn, buttonID(QRButton, k.ID))
+					btn.Set("disabled", k.Blob == "")
+					title := "Display the public key as a QR code"
+					if k.Blob == "" {
+						title = "Load the key to display its public key as a QR code"
+					}
+					dom.SetAttribute(btn, "title", title)
+					dom.AppendChild(btn, u.dom.NewText(u.t(msgShowQR)), nil)
+					u.keysCleanup.Add(dom.OnClick(btn, func(ctx jsutil.AsyncContext, evt dom.Event) {
+						u.showQR(ctx, k.ID)
+					}))
+				})
+
+				// Auto-load checkbox
+				dom.AppendChild(div, u.dom.NewElement("label"), func(label js.Value) {
+					dom.AddClass(label, "keyAutoLoad")
+					title := "Load automatically when the browser starts"
+					if k.Encrypted {
+						title += "; encrypted keys are skipped, since a passphrase is required"
+					}
+					dom.SetAttribute(label, "title", title)
+					dom.AppendChild(label, u.dom.NewElement("input"), func(box js.Value) {
+						dom.SetAttribute(box, "type", "checkbox")
+						setID(box, autoLoadCheckboxID(k.ID))
+						dom.SetChecked(box, k.AutoLoad)
+						u.keysCleanup.Add(dom.OnChange(box, func(ctx jsutil.AsyncContext, evt dom.Event) {
+							u.setAutoLoad(ctx, k.ID, dom.Checked(box))
+						}))
+					})
+					dom.AppendChild(label, u.dom.NewText(u.t(msgAutoLoad)), nil)
+				})
+
+				// Remove button
+				dom.AppendChild(div, u.dom.NewElement("button"), func(btn js.Value) {
+					dom.SetAttribute(btn, "type", "button")
+					setID(btn, buttonID(RemoveButton, k.ID))
+					dom.AppendChild(btn, u.dom.NewText(u.t(msgRemove)), nil)
+					u.keysCleanup.Add(dom.OnClick(btn, func(ctx jsutil.AsyncContext, evt dom.Event) {
+						u.remove(ctx, k.ID)
+					}))
+				})
+			})
+		})
+
+		// Type
+		dom.AppendChild(row, u.dom.NewElement("td"), func(cell js.Value) {
+			dom.AppendChild(cell, u.dom.NewElement("div"), func(div js.Value) {
+				dom.AddClass(div, "keyType")
+				dom.AppendChild(div, u.dom.NewText(k.Type), nil)
+			})
+			if text := k.sizeText(); text != "" {
+				dom.AppendChild(cell, u.dom.NewElement("div"), func(div js.Value) {
+					dom.AddClass(div, "keySize")
+					dom.AppendChild(div, u.dom.NewText(text), nil)
+					if !k.weak(u.weakKeyBits) {
+						return
+					}
+					dom.AppendChild(div, u.dom.NewElement("span"), func(span js.Value) {
+						dom.AddClass(span, "keySizeWarning")
+						setID(span, weakKeyID(k))
+						dom.SetAttribute(span, "title", fmt.Sprintf("Smaller than the recommended minimum of %d bits; consider replacing this key", u.weakKeyBits))
+						dom.AppendChild(span, u.dom.NewText("\u26A0"), nil)
+					})
+				})
+			}
+			if u.md5Shown && k.FingerprintMD5 != "" {
+				dom.AppendChild(cell, u.dom.NewElement("div"), func(div js.Value) {
+					dom.AddClass(div, "keyFingerprintMD5")
+					dom.SetAttribute(div, "title", "Legacy MD5 fingerprint, as displayed by older systems")
+					dom.AppendChild(div, u.dom.NewText(k.FingerprintMD5), nil)
+				})
+			}
+			if text, warn := k.certificateText(now); text != "" {
+				dom.AppendChild(cell, u.dom.NewElement("div"), func(div js.Value) {
+					class := "keyCertificate"
+					if warn {
+						class = "keyCertificateWarning"
+					}
+					dom.AddClass(div, class)
+					dom.AppendChild(div, u.dom.NewText(text), nil)
+				})
+			}
+			if k.ID == keys.InvalidID || k.RSASignatureAlgorithm == "" {
+				return
+			}
+			dom.AppendChild(cell, u.dom.NewElement("select"), func(sel js.Value) {
+				dom.AddClass(sel, "keyAlgorithm")
+				setID(sel, algorithmSelectID(k.ID))
+				dom.SetAttribute(sel, "title", "Signature algorithm used when the client requests a legacy ssh-rsa signature; takes effect when the key is next loaded")
+				for _, alg := range keys.RSASignatureAlgorithms {
+					dom.AppendChild(sel, u.dom.NewElement("option"), func(opt js.Value) {
+						dom.SetAttribute(opt, "value", alg)
+						label := alg
+						if alg == keys.ClientRSASignatureAlgorithm {
+							label = u.t(msgClientAlgorithm)
+						}
+						dom.AppendChild(opt, u.dom.NewText(label), nil)
+					})
+				}
+				dom.SetValue(sel, k.RSASignatureAlgorithm)
+				u.keysCleanup.Add(dom.OnChange(sel, func(ctx jsutil.AsyncContext, evt dom.Event) {
+					u.setRSASignatureAlgorithm(ctx, k.ID, dom.Value(sel))
+				}))
+			})
+		})
+
+		// Last used
+		dom.AppendChild(row, u.dom.NewElement("td"), func(cell js.Value) {
+			dom.AppendChild(cell, u.dom.NewElement("div"), func(div js.Value) {
+				dom.AddClass(div, "keyLastUsed")
+				if !k.LastUsed.IsZero() {
+					dom.SetAttribute(div, "title", k.LastUsed.Format(time.RFC1123))
+				}
+				dom.AppendChild(div, u.dom.NewText(k.lastUsedText(now)), nil)
+			})
+		})
+
+		// Blob
+		if !u.keyMaterialShown {
+			return
+		}
+		dom.AppendChild(row, u.dom.NewElement("td"), func(cell js.Value) {
+			dom.AppendChild(cell, u.dom.NewElement("div"), func(div js.Value) {
+				dom.AddClass(div, "keyBlob")
+				if u.compact {
+					// The blob is truncated; make the full
+					// blob available on hover.
+					dom.SetAttribute(div, "title", k.Blob)
+				}
+				dom.AppendChild(div, u.dom.NewText(k.Blob), nil)
+			})
+		})
+	})
+
+	// A key that could not be read is followed by a row explaining why,
+	// so that corruption is visible rather than mysterious.
+	if k.Error == "" {
+		return
+	}
+	dom.AppendChild(u.keysData, u.dom.NewElement("tr"), func(row js.Value) {
+		dom.AddClass(row, "keyErrorRow")
+		dom.AppendChild(row, u.dom.NewElement("td"), func(cell js.Value) {
+			setID(cell, keyErrorID(k))
+			dom.SetAttribute(cell, "colspan", "7")
+			dom.AppendChild(cell, u.dom.NewText(fmt.Sprintf("\u26A0 This key could not be read: %s", k.Error)), nil)
+		})
+	})
+}
+
+// updateAgentStatus refreshes the summary of loaded keys.
+func (u *UI) updateAgentStatus() {
+	dom.RemoveChildren(u.agentStatus)
+	dom.AppendChild(u.agentStatus, u.dom.NewText(agentStatusText(u.keys, u.loadLimit)), nil)
+}
+
+// agentStatusText summarizes how many of the displayed keys are loaded.
+// limit is the maximum number of keys that may be loaded at once; zero
+// indicates no limit.
+func agentStatusText(disp []*displayedKey, limit uint32) string {
+	loaded := 0
+	for _, k := range disp {
+		if k.Loaded {
+			loaded++
+		}
+	}
+	if limit > 0 {
+		return fmt.Sprintf("%d of %d keys loaded (limit %d)", loaded, len(disp), limit)
+	}
+	return fmt.Sprintf("%d of %d keys loaded", loaded, len(disp))
+}
+
+// setReconcile refreshes the reconcile view to reflect the keys that should
+// be displayed. Any resources are attached to the corresponding key, and are
+// cleaned up along with the rest of the key's resources.
+func (u *UI) setReconcile(newKeys []*displayedKey) {
+	external, unloaded := reconcile(newKeys)
+
+	for i, k := range external {
+		i, k := i, k
+		dom.AppendChild(u.externalData, u.dom.NewElement("li"), func(item js.Value) {
+			label := k.Type
+			if k.Comment != "" {
+				label = fmt.Sprintf("%s (%s)", k.Comment, k.Type)
+			}
+			dom.AppendChild(item, u.dom.NewText(label), nil)
+			dom.AppendChild(item, u.dom.NewElement("button"), func(btn js.Value) {
+				btn.Set("type", "button")
+				btn.Set("id", adoptButtonID(i))
+				dom.AppendChild(btn, u.dom.NewText(u.t(msgAdopt)), nil)
+				u.keysCleanup.Add(dom.OnClick(btn, func(ctx jsutil.AsyncContext, evt dom.Event) {
+					u.adopt(ctx, k)
+				}))
+			})
+		})
+	}
+	if len(external) == 0 {
+		dom.AppendChild(u.externalData, u.dom.NewElement("li"), func(item js.Value) {
+			dom.AppendChild(item, u.dom.NewText(u.t(msgNone)), nil)
+		})
+	}
+
+	for _, k := range unloaded {
+		k := k
+		dom.AppendChild(u.unloadedData, u.dom.NewElement("li"), func(item js.Value) {
+			dom.AppendChild(item, u.dom.NewText(k.Name), nil)
+			dom.AppendChild(item, u.dom.NewElement("button"), func(btn js.Value) {
+				btn.Set("type", "button")
+				btn.Set("id", buttonID(ReconcileLoadButton, k.ID))
+				dom.AppendChild(btn, u.dom.NewText(u.t(msgLoad)), nil)
+				u.keysCleanup.Add(dom.OnClick(btn, func(ctx jsutil.AsyncContext, evt dom.Event) {
+					u.load(ctx, k.ID)
+				}))
+			})
+		})
+	}
+	if len(unloaded) == 0 {
+		dom.AppendChild(u.unloadedData, u.dom.NewElement("li"), func(item js.Value) {
+			dom.AppendChild(item, u.dom.NewText(u.t(msgNone)), nil)
+		})
+	}
+}
+
+// mergeKeys merges configured and loaded keys to create a consolidated list
+// of keys that should be displayed in the UI.
+func mergeKeys(configured []*keys.ConfiguredKey, loaded []*keys.LoadedKey) []*displayedKey {
+	var result []*displayedKey
+	for _, k := range keys.MergeKeys(configured, loaded) {
+		d := (*displayedKey)(k)
+		d.validate()
+		result = append(result, d)
+	}
+	return result
+}
+
+// updateKeys queries the manager for configured and loaded keys, then triggers
+// UI updates to reflect the current state.
+func (u *UI) updateKeys(ctx jsutil.AsyncContext) {
+	if u.isLocked() {
+		return
+	}
+
+	configured, err := u.mgr.Configured(ctx)
+	if err != nil {
+		u.setError(fmt.Errorf("failed to get configured keys: %w", err))
+		return
+	}
+
+	loaded, err := u.mgr.Loaded(ctx)
+	if err != nil {
+		u.setError(fmt.Errorf("failed to get loaded keys: %w", err))
+		return
+	}
+	u.updateStorageUsage(ctx)
+	u.updateCorruptKeys(ctx)
+	u.updateAgentLock(ctx)
+	u.setError(nil)
+	u.setKeys(sortKeys(mergeKeys(configured, loaded), u.sortColumn, u.sortDesc))
+
+	// We have successfully loaded keys. No need for initial status.
+	dom.RemoveChildren(u.loadingText)
+}
+
+// updateAgentLock queries the manager for whether the agent is locked, then
+// updates the UI to reflect it. While the agent is locked, it reports no
+// loaded keys.
+func (u *UI) updateAgentLock(ctx jsutil.AsyncContext) {
+	locked, err := u.mgr.AgentLocked(ctx)
+	if err != nil {
+		// Lock state is informational only; the agent refuses requests
+		// regardless.
+		jsutil.LogError("failed to get agent lock: %v", err)
+		return
+	}
+	dom.SetVisible(u.lockAgentButton, !locked)
+	dom.SetVisible(u.unlockAgentButton, locked)
+	dom.SetVisible(u.agentLockedText, locked)
+}
+
+// promptAgentLock prompts the user for the passphrase with which to lock or
+// unlock the agent. ok is false if the user cancels.
+func (u *UI) promptAgentLock(ctx jsutil.AsyncContext, prompt string) (ok bool, passphrase string) {
+	dialogElem := u.dom.GetElement("agentLockDialog")
+	dialog := dom.NewDialog(dialogElem)
+	form := u.dom.GetElement("agentLockForm")
+	label := u.dom.GetElement("agentLockPrompt")
+	field := u.dom.GetElement("agentLockPassphrase")
+	okButton := u.dom.GetElement("agentLockOk")
+	cancel := u.dom.GetElement("agentLockCancel")
+
+	dom.RemoveChildren(label)
+	dom.AppendChild(label, u.dom.NewText(prompt), nil)
+
+	sig := newSignal()
+	var cleanup jsutil.CleanupFuncs
+	cleanup.Add(dom.OnSubmit(form, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		ok = true
+		passphrase = dom.Value(field)
+		dialog.Close()
+		sig.Notify()
+	}))
+	cleanup.Add(dom.OnClick(cancel, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		dialog.Close()
+		sig.Notify()
+	}))
+	cleanup.Add(onDialogKeys(field, okButton, cancel))
+	cleanup.Add(dialog.OnClose(func(ctx jsutil.AsyncContext, evt dom.Event) {
+		dom.SetValue(field, "")
+		cleanup.Do()
+	}))
+
+	cleanup.Add(u.showModal(dialog, dialogElem, field))
+	sig.Wait(ctx)
+	return
+}
+
+// lockAgent locks the agent with a passphrase supplied by the user, as for
+// 'ssh-add -x'.
+func (u *UI) lockAgent(ctx jsutil.AsyncContext, _ dom.Event) {
+	ok, passphrase := u.promptAgentLock(ctx, "Passphrase with which to lock the agent")
+	if !ok {
+		return
+	}
+	if err := u.mgr.LockAgent(ctx, passphrase); err != nil {
+		u.setError(fmt.Errorf("failed to lock agent: %w", err))
+		return
+	}
+	u.updateKeys(ctx)
+	u.setStatus("Agent locked.")
+}
+
+// unlockAgent unlocks the agent with a passphrase supplied by the user, as
+// for 'ssh-add -X'.
+func (u *UI) unlockAgent(ctx jsutil.AsyncContext, _ dom.Event) {
+	ok, passphrase := u.promptAgentLock(ctx, "Passphrase with which the agent was locked")
+	if !ok {
+		return
+	}
+	if err := u.mgr.UnlockAgent(ctx, passphrase); err != nil {
+		u.setError(fmt.Errorf("failed to unlock agent: %w", err))
+		return
+	}
+	u.updateKeys(ctx)
+	u.setStatus("Agent unlocked.")
+}
+
+// corruptKeysText describes the number of stored keys that could not be
+// read.
+func corruptKeysText(n int) string {
+	if n == 1 {
+		return "1 key could not be read"
+	}
+	return fmt.Sprintf("%d keys could not be read", n)
+}
+
+// updateCorruptKeys queries the manager for the number of stored keys that
+// could not be read, then updates the UI to reflect it.
+func (u *UI) updateCorruptKeys(ctx jsutil.AsyncContext) {
+	dom.RemoveChildren(u.corruptText)
+
+	n, err := u.mgr.CorruptKeys(ctx)
+	if err != nil {
+		// The remaining keys are still usable; don't interrupt the
+		// user.
+		jsutil.LogError("failed to count corrupt keys: %v", err)
+		dom.Hide(u.corruptKeys)
+		return
+	}
+
+	dom.SetVisible(u.corruptKeys, n > 0)
+	if n > 0 {
+		dom.AppendChild(u.corruptText, u.dom.NewText(corruptKeysText(n)), nil)
+	}
+}
+
+// removeCorruptKeys removes all stored keys that could not be read.
+func (u *UI) removeCorruptKeys(ctx jsutil.AsyncContext, _ dom.Event) {
+	n, err := u.mgr.RemoveCorruptKeys(ctx)
+	if err != nil {
+		u.setError(fmt.Errorf("failed to remove unreadable keys: %w", err))
+		return
+	}
+	u.setError(nil)
+	u.setStatus(fmt.Sprintf("Removed %d unreadable keys.", n))
+	u.updateKeys(ctx)
+}
+
+// formatBytes returns a human-readable description of a number of bytes.
+func formatBytes(n int) string {
+	switch {
+	case n < 1024:
+		return fmt.Sprintf("%d bytes", n)
+	case n < 1024*1024:
+		return fmt.Sprintf("%.1f KB", float64(n)/1024)
+	default:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1024*1024))
+	}
+}
+
+const (
+	// storageWarnFraction is the fraction of the storage quota above which
+	// usage is highlighted.
+	storageWarnFraction = 0.8
 )
 
-// buttonID returns the value of the 'id' attribute to be assigned to the HTML
-// button.
-func buttonID(kind buttonKind, id keys.ID) string {
-	s := "unknown"
-	switch kind {
-	case LoadButton:
-		s = "load"
-	case UnloadButton:
-		s = "unload"
-	case RemoveButton:
-		s = "remove"
+// storageUsageText describes the space consumed in the storage area holding
+// configured keys.
+func storageUsageText(usage *keys.StorageUsage) string {
+	area := "synced storage"
+	if usage.Location == keys.KeyStorageLocal {
+		area = "local storage"
+	}
+	if usage.QuotaBytes <= 0 {
+		return fmt.Sprintf("Using %s of %s", formatBytes(usage.BytesInUse), area)
+	}
+
+	text := fmt.Sprintf("Using %s of %s %s (%d%%)",
+		formatBytes(usage.BytesInUse), formatBytes(usage.QuotaBytes), area,
+		usage.BytesInUse*100/usage.QuotaBytes)
+	if usage.QuotaBytesPerItem > 0 {
+		text += fmt.Sprintf("; at most %s per item", formatBytes(usage.QuotaBytesPerItem))
+	}
+	return text
+}
+
+// storageNearQuota indicates if the space consumed is approaching the quota.
+func storageNearQuota(usage *keys.StorageUsage) bool {
+	return usage.QuotaBytes > 0 && float64(usage.BytesInUse) >= storageWarnFraction*float64(usage.QuotaBytes)
+}
+
+// updateStorageUsage queries the manager for the space consumed by configured
+// keys, then updates the UI to reflect it.
+func (u *UI) updateStorageUsage(ctx jsutil.AsyncContext) {
+	dom.RemoveChildren(u.storageUsage)
+	u.storageUsage.Set("className", "storageUsage")
+
+	usage, err := u.mgr.StorageUsage(ctx)
+	if err != nil {
+		// Usage is informational only; don't interrupt the user.
+		jsutil.LogError("failed to get storage usage: %v", err)
+		return
+	}
+
+	if storageNearQuota(usage) {
+		u.storageUsage.Set("className", "storageUsage storageUsageWarning")
+	}
+	dom.AppendChild(u.storageUsage, u.dom.NewText(storageUsageText(usage)), nil)
+}
+
+// activityResultText describes the outcome of a signature request or other
+// operation recorded in the activity log.
+func activityResultText(e *keys.AuditEntry) string {
+	if e.Action == keys.AuditActionExportPrivate {
+		if e.Err != "" {
+			return "Failed to export private key: " + e.Err
+		}
+		return "Exported private key"
+	}
+	if e.Err != "" {
+		return "Failed: " + e.Err
+	}
+	return "Signed"
+}
+
+// updateActivity queries the manager for the activity log, then updates the
+// UI to display its entries, newest first.
+func (u *UI) updateActivity(ctx jsutil.AsyncContext) {
+	if u.isLocked() {
+		return
+	}
+
+	entries, err := u.mgr.AuditLog(ctx)
+	if err != nil {
+		// The log is informational only; don't interrupt the user.
+		jsutil.LogError("failed to get activity log: %v", err)
+		return
+	}
+
+	dom.RemoveChildren(u.activityData)
+	for _, e := range entries {
+		e := e
+		dom.AppendChild(u.activityData, u.dom.NewElement("tr"), func(row js.Value) {
+			if e.Err != "" {
+				dom.AddClass(row, "activityFailed")
+			}
+			for _, text := range []string{
+				time.Unix(e.Time, 0).Format(time.RFC1123),
+				e.Name,
+				e.Fingerprint,
+				e.Origin,
+				activityResultText(e),
+			} {
+				dom.AppendChild(row, u.dom.NewElement("td"), func(cell js.Value) {
+					dom.AppendChild(cell, u.dom.NewText(text), nil)
+				})
+			}
+		})
+	}
+	dom.SetVisible(u.activityEmpty, len(entries) == 0)
+}
+
+// clearActivityLog removes all entries from the activity log.
+func (u *UI) clearActivityLog(ctx jsutil.AsyncContext, _ dom.Event) {
+	if err := u.mgr.ClearAuditLog(ctx); err != nil {
+		u.setError(fmt.Errorf("failed to clear activity log: %w", err))
+		return
+	}
+	u.setError(nil)
+	u.setStatus("Activity log cleared.")
+	u.updateActivity(ctx)
+}
+
+// updatePreferences queries the manager for the user's preferences, then
+// updates the UI to reflect them.
+func (u *UI) updatePreferences(ctx jsutil.AsyncContext) {
+	prefs, err := u.mgr.Preferences(ctx)
+	if err != nil {
+		u.setError(fmt.Errorf("failed to get preferences: %w", err))
+		return
+	}
+
+	dom.SetChecked(u.confirmUnload, prefs.ConfirmUnload)
+	dom.SetChecked(u.confirmLoad, prefs.ConfirmLoad)
+	dom.SetChecked(u.notifyLoad, prefs.NotifyLoad)
+	dom.SetValue(u.idleUnload, minutesText(prefs.IdleUnloadMins))
+	dom.SetValue(u.passphraseCache, minutesText(prefs.PassphraseCacheMins))
+	dom.SetValue(u.maxLoaded, countText(prefs.MaxLoadedKeys))
+	dom.SetChecked(u.evictLRU, prefs.EvictLRU)
+	dom.SetValue(u.minKeyBits, countText(prefs.MinKeyBits))
+	u.setWeakKeyBits(ctx, prefs.WeakKeyBits())
+	dom.SetValue(u.refreshSecs, countText(prefs.RefreshSecs))
+	u.setRefreshInterval(prefs.RefreshInterval())
+	u.loadLimit = prefs.MaxLoadedKeys
+	u.setLockTimeout(prefs.IdleTimeout())
+	u.updateAgentStatus()
+	dom.SetChecked(u.showKeyMaterial, prefs.ShowKeyMaterial)
+	u.setKeyMaterialShown(ctx, prefs.ShowKeyMaterial)
+	dom.SetChecked(u.showMD5, prefs.ShowMD5Fingerprint)
+	u.setMD5Shown(ctx, prefs.ShowMD5Fingerprint)
+	dom.SetChecked(u.compactView, prefs.CompactView)
+	u.setCompact(ctx, prefs.CompactView)
+	dom.SetValue(u.keysPerPage, countText(prefs.KeysPerPage))
+	u.setPageSize(ctx, int(prefs.KeysPerPage))
+	u.setSort(ctx, prefs.SortColumn, prefs.SortDescending)
+	u.setCollapsedTags(ctx, prefs.CollapsedTags)
+	dom.SetChecked(u.nativeHost, prefs.NativeHost)
+	u.updateNativeHostStatus(ctx, prefs.NativeHost)
+	u.updateExtensions(ctx)
+	dom.SetChecked(u.debugLogging, prefs.DebugLogging)
+	dom.SetChecked(u.debugConsole, prefs.DebugConsole)
+	dom.SetVisible(u.consolePane, prefs.DebugConsole)
+	jsutil.SetLogLevel(prefs.LogLevel())
+
+	location, err := u.mgr.KeyStorage(ctx)
+	if err != nil {
+		u.setError(fmt.Errorf("failed to get key storage: %w", err))
+		return
+	}
+	dom.SetValue(u.keyStorage, location)
+}
+
+// nativeHostStatusText describes the connection to the native messaging
+// host. warn indicates that the host is disconnected. The text is empty if
+// the host is not enabled.
+func nativeHostStatusText(enabled bool, status *keys.NativeHostStatus) (text string, warn bool) {
+	switch {
+	case !enabled:
+		return "", false
+	case status.Connected:
+		return "Connected", false
+	case status.Err == "":
+		return "Connecting…", false
+	case status.RetryAt == 0:
+		return fmt.Sprintf("Disconnected: %s", status.Err), true
+	default:
+		retry := time.Unix(status.RetryAt, 0).Format("15:04:05")
+		return fmt.Sprintf("Disconnected: %s; retrying at %s", status.Err, retry), true
+	}
+}
+
+// updateNativeHostStatus queries the manager for the status of the
+// connection to the native messaging host, then updates the UI to reflect
+// it.
+func (u *UI) updateNativeHostStatus(ctx jsutil.AsyncContext, enabled bool) {
+	dom.RemoveChildren(u.nativeStatus)
+	dom.RemoveClass(u.nativeStatus, "nativeHostStatusError")
+
+	status, err := u.mgr.NativeHostStatus(ctx)
+	if err != nil {
+		// Status is informational only; don't interrupt the user.
+		jsutil.LogError("failed to get native host status: %v", err)
+		return
+	}
+
+	text, warn := nativeHostStatusText(enabled, status)
+	if warn {
+		dom.AddClass(u.nativeStatus, "nativeHostStatusError")
+	}
+	dom.AppendChild(u.nativeStatus, u.dom.NewText(text), nil)
+}
+
+// updateExtensions queries the manager for the agent extensions supported
+// by the agent, then lists them in the UI.
+func (u *UI) updateExtensions(ctx jsutil.AsyncContext) {
+	dom.RemoveChildren(u.agentExtensions)
+
+	names, err := u.mgr.Extensions(ctx)
+	if err != nil {
+		// Extensions are informational only; don't interrupt the user.
+		jsutil.LogError("failed to get agent extensions: %v", err)
+		return
+	}
+
+	if len(names) == 0 {
+		names = []string{u.t(msgNone)}
+	}
+	for _, name := range names {
+		dom.AppendChild(u.agentExtensions, u.dom.NewElement("li"), func(item js.Value) {
+			dom.AppendChild(item, u.dom.NewText(name), nil)
+		})
+	}
+}
+
+// setKeyStorage changes where configured keys are stored to the location
+// currently selected in the UI. Existing keys are copied to the new location.
+func (u *UI) setKeyStorage(ctx jsutil.AsyncContext, _ dom.Event) {
+	location := dom.Value(u.keyStorage)
+	if err := u.mgr.SetKeyStorage(ctx, location); err != nil {
+		u.setError(fmt.Errorf("failed to change key storage: %w", err))
+		// Reflect the location that remains in use.
+		u.updatePreferences(ctx)
+		return
+	}
+
+	u.setError(nil)
+	switch location {
+	case keys.KeyStorageLocal:
+		u.setStatus("Keys are now stored on this device only.")
+	default:
+		u.setStatus("Keys are now synced across your devices.")
+	}
+	u.updateKeys(ctx)
+}
+
+// savePreferences stores the preferences currently reflected in the UI.
+func (u *UI) savePreferences(ctx jsutil.AsyncContext, _ dom.Event) {
+	prefs, err := u.mgr.Preferences(ctx)
+	if err != nil {
+		u.setError(fmt.Errorf("failed to get preferences: %w", err))
+		return
+	}
+
+	prefs.ConfirmUnload = dom.Checked(u.confirmUnload)
+	prefs.ConfirmLoad = dom.Checked(u.confirmLoad)
+	prefs.NotifyLoad = dom.Checked(u.notifyLoad)
+	mins, err := parseMinutes(dom.Value(u.idleUnload), errInvalidIdleTimeout)
+	if err != nil {
+		u.setError(err)
+		return
+	}
+	prefs.IdleUnloadMins = mins
+	mins, err = parseMinutes(dom.Value(u.passphraseCache), errInvalidPassphraseCache)
+	if err != nil {
+		u.setError(err)
+		return
+	}
+	prefs.PassphraseCacheMins = mins
+	limit, err := parseCount(dom.Value(u.maxLoaded), errInvalidMaxLoaded)
+	if err != nil {
+		u.setError(err)
+		return
+	}
+	prefs.MaxLoadedKeys = limit
+	prefs.EvictLRU = dom.Checked(u.evictLRU)
+	minBits, err := parseCount(dom.Value(u.minKeyBits), errInvalidMinKeyBits)
+	if err != nil {
+		u.setError(err)
+		return
+	}
+	prefs.MinKeyBits = minBits
+	refreshSecs, err := parseCount(dom.Value(u.refreshSecs), errInvalidRefreshSecs)
+	if err != nil {
+		u.setError(err)
+		return
+	}
+	prefs.RefreshSecs = refreshSecs
+	prefs.ShowKeyMaterial = dom.Checked(u.showKeyMaterial)
+	prefs.ShowMD5Fingerprint = dom.Checked(u.showMD5)
+	prefs.CompactView = dom.Checked(u.compactView)
+	perPage, err := parseCount(dom.Value(u.keysPerPage), errInvalidKeysPerPage)
+	if err != nil {
+		u.setError(err)
+		return
+	}
+	prefs.KeysPerPage = perPage
+	prefs.NativeHost = dom.Checked(u.nativeHost)
+	prefs.DebugLogging = dom.Checked(u.debugLogging)
+	prefs.DebugConsole = dom.Checked(u.debugConsole)
+	if err := u.mgr.SetPreferences(ctx, prefs); err != nil {
+		u.setError(fmt.Errorf("failed to save preferences: %w", err))
+		return
+	}
+	u.setError(nil)
+	u.setKeyMaterialShown(ctx, prefs.ShowKeyMaterial)
+	u.setMD5Shown(ctx, prefs.ShowMD5Fingerprint)
+	u.setCompact(ctx, prefs.CompactView)
+	u.setPageSize(ctx, int(prefs.KeysPerPage))
+	u.setWeakKeyBits(ctx, prefs.WeakKeyBits())
+	u.setRefreshInterval(prefs.RefreshInterval())
+	u.loadLimit = prefs.MaxLoadedKeys
+	u.setLockTimeout(prefs.IdleTimeout())
+	u.updateAgentStatus()
+	dom.SetVisible(u.consolePane, prefs.DebugConsole)
+	jsutil.SetLogLevel(prefs.LogLevel())
+}
+
+// setKeyMaterialShown selects whether the public key material is displayed
+// for each key, refreshing the displayed keys if it changed.
+func (u *UI) setKeyMaterialShown(ctx jsutil.AsyncContext, shown bool) {
+	if shown == u.keyMaterialShown {
+		return
+	}
+	u.keyMaterialShown = shown
+	dom.SetVisible(u.keysBlobHeader, shown)
+	u.updateKeys(ctx)
+}
+
+// setMD5Shown selects whether the legacy MD5 fingerprint is displayed for
+// each key, refreshing the displayed keys if it changed.
+func (u *UI) setMD5Shown(ctx jsutil.AsyncContext, shown bool) {
+	if shown == u.md5Shown {
+		return
+	}
+	u.md5Shown = shown
+	u.updateKeys(ctx)
+}
+
+// nextSort returns the sort order selected when the header for the clicked
+// column is clicked. Clicking a column sorts by it in ascending order,
+// clicking it again reverses the order, and clicking it a third time
+// restores the order arranged by the user.
+func nextSort(column string, desc bool, clicked string) (string, bool) {
+	switch {
+	case column != clicked:
+		return clicked, false
+	case !desc:
+		return clicked, true
+	default:
+		return "", false
 	}
-	return fmt.Sprintf("%s-%s", s, id)
 }
 
-// setKeys refreshes the UI to reflect the keys that should be
-// displayed.
-func (u *UI) setKeys(newKeys []*displayedKey) {
-	// Cleanup elements and resources for all previous keys.
-	dom.RemoveChildren(u.keysData)
-	for _, k := range u.keys {
-		k.cleanup.Do()
+// sortType returns the value by which a key is ordered when sorting by type.
+// The algorithm is used where known, since the key type is only available for
+// loaded keys.
+func sortType(k *displayedKey) string {
+	if k.Algorithm != "" {
+		return k.Algorithm
 	}
+	return k.Type
+}
 
-	// Construct elements for new keys.
-	for _, k := range newKeys {
-		k := k
-		dom.AppendChild(u.keysData, u.dom.NewElement("tr"), func(row js.Value) {
-			// Key name
-			dom.AppendChild(row, u.dom.NewElement("td"), func(cell js.Value) {
-				dom.AppendChild(cell, u.dom.NewElement("div"), func(div js.Value) {
-					div.Set("className", "keyName")
-					dom.AppendChild(div, u.dom.NewText(k.Name), nil)
-				})
-			})
+// sortKeys sorts the displayed keys by the specified column. Keys that are
+// equal in that column remain in their existing order. Keys are left in
+// their existing order if column is empty.
+func sortKeys(disp []*displayedKey, column string, desc bool) []*displayedKey {
+	var less func(a, b *displayedKey) bool
+	switch column {
+	case keys.SortByName:
+		less = func(a, b *displayedKey) bool { return keys.CompareNames(a.Name, b.Name) < 0 }
+	case keys.SortByType:
+		less = func(a, b *displayedKey) bool {
+			if at, bt := sortType(a), sortType(b); at != bt {
+				return at < bt
+			}
+			return a.BitSize < b.BitSize
+		}
+	default:
+		return disp
+	}
 
-			// Controls
-			dom.AppendChild(row, u.dom.NewElement("td"), func(cell js.Value) {
-				dom.AppendChild(cell, u.dom.NewElement("div"), func(div js.Value) {
-					div.Set("className", "keyControls")
-					if k.ID == keys.InvalidID {
-						// We only control keys with a valid ID.
-						return
-					}
+	sort.SliceStable(disp, func(i, j int) bool {
+		if desc {
+			return less(disp[j], disp[i])
+		}
+		return less(disp[i], disp[j])
+	})
+	return disp
+}
 
-					if k.Loaded {
-						// Unload button
-						dom.AppendChild(div, u.dom.NewElement("button"), func(btn js.Value) {
-							btn.Set("type", "button")
-							btn.Set("id", buttonID(UnloadButton, k.ID))
-							dom.AppendChild(btn, u.dom.NewText("Unload"), nil)
-							k.cleanup.Add(dom.OnClick(btn, func(ctx jsutil.AsyncContext, evt dom.Event) {
-								u.unload(ctx, k.ID)
-							}))
-						})
-					} else {
-						// Load button
-						dom.AppendChild(div, u.dom.NewElement("button"), func(btn js.Value) {
-							btn.Set("type", "button")
-							btn.Set("id", buttonID(LoadButton, k.ID))
-							dom.AppendChild(btn, u.dom.NewText("Load"), nil)
-							k.cleanup.Add(dom.OnClick(btn, func(ctx jsutil.AsyncContext, evt dom.Event) {
-								u.load(ctx, k.ID)
-							}))
-						})
-					}
+// sortBy updates the sort order in response to the user clicking the header
+// for the specified column, and saves it in the user's preferences.
+func (u *UI) sortBy(ctx jsutil.AsyncContext, column string) {
+	prefs, err := u.mgr.Preferences(ctx)
+	if err != nil {
+		u.setError(fmt.Errorf("failed to get preferences: %w", err))
+		return
+	}
 
-					// Remove button
-					dom.AppendChild(div, u.dom.NewElement("button"), func(btn js.Value) {
-						btn.Set("type", "button")
-						btn.Set("id", buttonID(RemoveButton, k.ID))
-						dom.AppendChild(btn, u.dom.NewText("Remove"), nil)
-						k.cleanup.Add(dom.OnClick(btn, func(ctx jsutil.AsyncContext, evt dom.Event) {
-							u.remove(ctx, k.ID)
-						}))
-					})
-				})
-			})
+	prefs.SortColumn, prefs.SortDescending = nextSort(u.sortColumn, u.sortDesc, column)
+	if err := u.mgr.SetPreferences(ctx, prefs); err != nil {
+		u.setError(fmt.Errorf("failed to save preferences: %w", err))
+		return
+	}
+	u.setError(nil)
+	u.setSort(ctx, prefs.SortColumn, prefs.SortDescending)
+}
 
-			// Type
-			dom.AppendChild(row, u.dom.NewElement("td"), func(cell js.Value) {
-				dom.AppendChild(cell, u.dom.NewElement("div"), func(div js.Value) {
-					div.Set("className", "keyType")
-					dom.AppendChild(div, u.dom.NewText(k.Type), nil)
-				})
-			})
+// setSortHeader updates the header for a column to indicate whether keys are
+// sorted by it.
+func (u *UI) setSortHeader(header js.Value, label, column string) {
+	dom.RemoveChildren(header)
+	dom.AppendChild(header, u.dom.NewText(label), nil)
+	if column != u.sortColumn {
+		dom.SetAttribute(header, "aria-sort", "none")
+		return
+	}
 
-			// Blob
-			dom.AppendChild(row, u.dom.NewElement("td"), func(cell js.Value) {
-				dom.AppendChild(cell, u.dom.NewElement("div"), func(div js.Value) {
-					div.Set("className", "keyBlob")
-					dom.AppendChild(div, u.dom.NewText(k.Blob), nil)
-				})
-			})
-		})
+	order, arrow := "ascending", "\u25B2"
+	if u.sortDesc {
+		order, arrow = "descending", "\u25BC"
 	}
-	// Update internal state after DOM is updated. Otherwise, callers (e.g.,
-	// our end-to-end test) may look for the new DOM elements before they
-	// are available.
-	u.keys = newKeys
+	dom.SetAttribute(header, "aria-sort", order)
+	dom.AppendChild(header, u.dom.NewElement("span"), func(span js.Value) {
+		dom.AddClass(span, "sortIndicator")
+		dom.AppendChild(span, u.dom.NewText(arrow), nil)
+	})
 }
 
-// mergeKeys merges configured and loaded keys to create a consolidated list
-// of keys that should be displayed in the UI.
-func mergeKeys(configured []*keys.ConfiguredKey, loaded []*keys.LoadedKey) []*displayedKey {
-	// Build map of configured keys for faster lookup
-	configuredMap := make(map[keys.ID]*keys.ConfiguredKey)
-	for _, k := range configured {
-		configuredMap[keys.ID(k.ID)] = k
+// setSort selects the column by which keys are sorted, refreshing the
+// displayed keys if it changed.
+func (u *UI) setSort(ctx jsutil.AsyncContext, column string, desc bool) {
+	if column == u.sortColumn && desc == u.sortDesc {
+		return
 	}
+	u.sortColumn = column
+	u.sortDesc = desc
+	u.setSortHeader(u.nameHeader, "Name", keys.SortByName)
+	u.setSortHeader(u.typeHeader, "Type", keys.SortByType)
+	u.updateKeys(ctx)
+}
 
-	var result []*displayedKey
+// setWeakKeyBits sets the size below which RSA and DSA keys are flagged as
+// weak, refreshing the displayed keys if it changed.
+func (u *UI) setWeakKeyBits(ctx jsutil.AsyncContext, bits int) {
+	if bits == u.weakKeyBits {
+		return
+	}
+	u.weakKeyBits = bits
+	u.updateKeys(ctx)
+}
 
-	// Add all loaded keys. Keep track of the IDs that were detected as
-	// being loaded.
-	loadedIds := make(map[keys.ID]bool)
-	for _, l := range loaded {
-		// Gather basic fields we get for any loaded key.
-		dk := &displayedKey{
-			Loaded:  true,
-			Type:    l.Type,
-			Blob:    base64.StdEncoding.EncodeToString(l.Blob()),
-			Comment: l.Comment,
-		}
-		// Attempt to figure out if this is a key we loaded. If so, fill
-		// in some additional information.  It is possible that a key with
-		// a non-existent ID is loaded (e.g., it was removed while loaded);
-		// in this case we claim we do not have an ID.
-		if id := l.ID(); id != keys.InvalidID {
-			if ak := configuredMap[id]; ak != nil {
-				loadedIds[id] = true
-				dk.ID = id
-				dk.Name = ak.Name
-			}
-		}
-		result = append(result, dk)
+// setCompact selects whether keys are displayed in dense, single-line
+// rows, refreshing the displayed keys if it changed.
+func (u *UI) setCompact(ctx jsutil.AsyncContext, compact bool) {
+	if compact == u.compact {
+		return
 	}
+	u.compact = compact
+	u.updateKeys(ctx)
+}
 
-	// Add all configured keys that are not loaded.
-	for _, a := range configured {
-		// Skip any that we already covered above.
-		if loadedIds[keys.ID(a.ID)] {
-			continue
-		}
+// setPageSize sets the maximum number of keys displayed at once, refreshing
+// the displayed keys if it changed. Zero indicates that all keys are
+// displayed.
+func (u *UI) setPageSize(ctx jsutil.AsyncContext, size int) {
+	if size == u.pageSize {
+		return
+	}
+	u.pageSize = size
+	u.page = 0
+	u.updateKeys(ctx)
+}
 
-		result = append(result, &displayedKey{
-			ID:        keys.ID(a.ID),
-			Loaded:    false,
-			Encrypted: a.Encrypted,
-			Name:      a.Name,
-		})
+// pageCount returns the number of pages required to display n keys.
+func pageCount(n, size int) int {
+	if size <= 0 || n <= size {
+		return 1
 	}
+	return (n + size - 1) / size
+}
 
-	// Sort to ensure consistent ordering.
-	sort.Slice(result, func(i, j int) bool {
-		a, b := result[i], result[j]
-		if a.Name < b.Name {
-			return true
-		}
-		if a.Name > b.Name {
-			return false
-		}
-		if a.Blob < b.Blob {
-			return true
-		}
-		if a.Blob > b.Blob {
-			return false
-		}
-		return a.ID < b.ID
-	})
+// pageBounds returns the range of the n keys that are displayed on the
+// current page, and updates the page controls to match. The current page is
+// clamped to those available, since keys may have been removed.
+func (u *UI) pageBounds(n int) (start, end int) {
+	pages := pageCount(n, u.pageSize)
+	u.page = max(0, min(u.page, pages-1))
 
-	return result
+	dom.SetVisible(u.keysPager, pages > 1)
+	u.prevPage.Set("disabled", u.page == 0)
+	u.nextPage.Set("disabled", u.page == pages-1)
+	dom.RemoveChildren(u.pageText)
+	dom.AppendChild(u.pageText, u.dom.NewText(fmt.Sprintf("Page %d of %d", u.page+1, pages)), nil)
+
+	if u.pageSize <= 0 {
+		return 0, n
+	}
+	start = u.page * u.pageSize
+	return start, min(start+u.pageSize, n)
 }
 
-// updateKeys queries the manager for configured and loaded keys, then triggers
-// UI updates to reflect the current state.
-func (u *UI) updateKeys(ctx jsutil.AsyncContext) {
-	configured, err := u.mgr.Configured(ctx)
-	if err != nil {
-		u.setError(fmt.Errorf("failed to get configured keys: %w", err))
+// setPage displays the page of keys with the supplied index.
+func (u *UI) setPage(page int) {
+	u.page = page
+	u.setKeys(u.keys)
+}
+
+// revealKey displays the page containing the key with the supplied ID, and
+// expands a group containing it if necessary, so that its controls are
+// available.
+func (u *UI) revealKey(id keys.ID) {
+	if k := u.keyByID(id); k != nil && u.hiddenInGroups(k) {
+		// Expand the first of the key's groups, without changing the
+		// user's preferences.
+		tag := keyTags(k)[0]
+		u.collapsedTags = slices.DeleteFunc(slices.Clone(u.collapsedTags), func(t string) bool { return t == tag })
+		u.setKeys(u.keys)
+	}
+	if u.pageSize <= 0 {
 		return
 	}
+	for i, k := range u.keys {
+		if k.ID == id {
+			if page := i / u.pageSize; page != u.page {
+				u.setPage(page)
+			}
+			return
+		}
+	}
+}
 
-	loaded, err := u.mgr.Loaded(ctx)
+// minutesText returns the text displayed for a preference specified in
+// minutes (e.g., the period after which idle keys are unloaded). The empty
+// string indicates that the preference is disabled.
+func minutesText(mins uint32) string {
+	if mins == 0 {
+		return ""
+	}
+	return strconv.FormatUint(uint64(mins), 10)
+}
+
+// parseMinutes parses a preference specified in minutes, as supplied by the
+// user. An empty value indicates that the preference is disabled. errInvalid
+// is wrapped by the error returned if the value is invalid.
+func parseMinutes(text string, errInvalid error) (uint32, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return 0, nil
+	}
+	mins, err := strconv.ParseUint(text, 10, 32)
 	if err != nil {
-		u.setError(fmt.Errorf("failed to get loaded keys: %w", err))
-		return
+		return 0, fmt.Errorf("%w: '%s' is not a valid number of minutes", errInvalid, text)
 	}
-	u.setError(nil)
-	u.setKeys(mergeKeys(configured, loaded))
+	return uint32(mins), nil
+}
 
-	// We have successfully loaded keys. No need for initial status.
-	dom.RemoveChildren(u.loadingText)
+// countText returns the text displayed for a preference specified as a
+// count (e.g., the maximum number of loaded keys). The empty string
+// indicates that there is no limit.
+func countText(n uint32) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.FormatUint(uint64(n), 10)
+}
+
+// parseCount parses a preference specified as a count, as supplied by the
+// user. An empty value indicates that there is no limit. errInvalid is
+// wrapped by the error returned if the value is invalid.
+func parseCount(text string, errInvalid error) (uint32, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(text, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%w: '%s' is not a valid number", errInvalid, text)
+	}
+	return uint32(n), nil
 }
 
 const (
@@ -578,123 +4070,355 @@ func poll(_ jsutil.AsyncContext, done func() bool) bool {
 // EndToEndTest runs a set of tests via the UI.  Failures are returned as a list
 // of errors.
 //
-// No attempt is made to clean up from any intermediate state should the test
-// fail.
+// The key configured by the test is removed when the test completes, even if
+// the test fails.
 func (u *UI) EndToEndTest(ctx jsutil.AsyncContext) []error {
-	var errs []error
+	return u.EndToEndReport(ctx).Errors()
+}
 
-	jsutil.Log("Starting test")
-	defer func() {
-		jsutil.Log("Finished test")
-		for _, err := range errs {
-			jsutil.Log("  Reported Error: %v", err)
-		}
-	}()
+// EndToEndReport runs the same tests as EndToEndTest, and returns a report
+// describing each step of the test, its duration and any failures observed
+// during it.
+func (u *UI) EndToEndReport(ctx jsutil.AsyncContext) *TestReport {
+	r := newTestRecorder(jsutil.Log)
+	u.endToEndTest(ctx, r)
+	return r.finish()
+}
+
+// endToEndTest implements EndToEndTest. Each step of the test, and any
+// failures, are recorded by r.
+func (u *UI) endToEndTest(ctx jsutil.AsyncContext, r *testRecorder) {
+	r.step("Starting test")
 
 	addDialog := u.dom.GetElement("addDialog")
 	addButton := u.dom.GetElement("add")
 	addName := u.dom.GetElement("addName")
 	addKey := u.dom.GetElement("addKey")
 	addOk := u.dom.GetElement("addOk")
-	passphraseDialog := u.dom.GetElement("passphraseDialog")
-	passphraseInput := u.dom.GetElement("passphrase")
-	passphraseOk := u.dom.GetElement("passphraseOk")
 	removeDialog := u.dom.GetElement("removeDialog")
 	removeYes := u.dom.GetElement("removeYes")
 
-	jsutil.Log("Generate random name to use for key")
+	r.step("Generate random name to use for key")
 	i, err := rand.Int(rand.Reader, big.NewInt(math.MaxInt64))
 	if err != nil {
-		errs = append(errs, fmt.Errorf("failed to generate random number: %w", err))
-		return errs
+		r.fail(fmt.Errorf("failed to generate random number: %w", err))
+		return
 	}
 	keyName := fmt.Sprintf("e2e-test-key-%s", i.String())
+	defer u.cleanupTestKey(ctx, r, keyName)
 
-	jsutil.Log("Configure a new key")
+	r.step("Configure a new key")
 	dom.DoClick(addButton)
 	if !poll(ctx, func() bool { return addDialog.Get("open").Bool() }) {
-		errs = append(errs, fmt.Errorf("add dialog failed to open"))
-		return errs
+		r.fail(fmt.Errorf("add dialog failed to open"))
+		return
 	}
 	dom.SetValue(addName, keyName)
 	// Use the long key to exercise storage of large values in Chrome storage.
 	dom.SetValue(addKey, testdata.LongKeyWithPassphrase.Private)
 	dom.DoClick(addOk)
 
-	jsutil.Log("Validate configured keys; ensure new key is present")
+	r.step("Validate configured keys; ensure new key is present")
 	var key *displayedKey
 	if !poll(ctx, func() bool {
 		key = u.keyByName(keyName)
 		return key != nil
 	}) {
-		errs = append(errs, fmt.Errorf("after added: failed to find key"))
-		return errs
+		r.fail(fmt.Errorf("after added: failed to find key"))
+		return
+	}
+
+	r.step("Read preferences that affect the test")
+	prefs, err := u.mgr.Preferences(ctx)
+	if err != nil {
+		r.fail(fmt.Errorf("failed to read preferences: %w", err))
+		return
+	}
+	full, err := u.loadLimitReached(ctx, prefs)
+	if err != nil {
+		r.fail(fmt.Errorf("failed to read loaded keys: %w", err))
+		return
+	}
+	if full {
+		// The key would fail to load, as the user requested.
+		r.step("Skip loading the new key; the maximum number of loaded keys is reached")
+	} else {
+		if ok := u.endToEndLoadUnload(ctx, r, keyName, prefs); !ok {
+			return // Remaining tests have hard dependency on unloaded key.
+		}
+	}
+	if key = u.keyByName(keyName); key == nil {
+		r.fail(fmt.Errorf("before remove: failed to find key"))
+		return
+	}
+
+	r.step("Remove key")
+	u.revealKey(key.ID)
+	dom.DoClick(u.dom.GetElement(buttonID(RemoveButton, key.ID)))
+	if !poll(ctx, func() bool { return removeDialog.Get("open").Bool() }) {
+		r.fail(fmt.Errorf("remove dialog failed to open"))
+		return
+	}
+	dom.DoClick(removeYes)
+
+	r.step("Validate configured keys; ensure key is removed")
+	if !poll(ctx, func() bool {
+		key = u.keyByName(keyName)
+		return key == nil
+	}) {
+		r.fail(fmt.Errorf("after removed: failed to observe key as removed"))
+		return
+	}
+
+	r.step("Dismiss offer to undo removal; ensure key is no longer configured")
+	dom.DoClick(u.undoDismiss)
+	if !poll(ctx, func() bool { return !dom.IsVisible(u.undoRemove) }) {
+		r.fail(fmt.Errorf("after removed: offer to undo removal still displayed"))
+	}
+	configured, err := u.mgr.Configured(ctx)
+	if err != nil {
+		r.fail(fmt.Errorf("after removed: failed to read configured keys: %w", err))
+		return
+	}
+	for _, k := range configured {
+		if k.Name == keyName {
+			r.fail(fmt.Errorf("after removed: key still configured"))
+		}
+	}
+}
+
+// loadLimitReached determines if loading another key would fail because the
+// maximum number of loaded keys selected in the user's preferences has been
+// reached.
+func (u *UI) loadLimitReached(ctx jsutil.AsyncContext, prefs *keys.Preferences) (bool, error) {
+	if prefs.MaxLoadedKeys == 0 || prefs.EvictLRU {
+		return false, nil
+	}
+	loaded, err := u.mgr.Loaded(ctx)
+	if err != nil {
+		return false, err
+	}
+	n := 0
+	for _, l := range loaded {
+		// Only keys loaded from configured keys count towards the
+		// limit.
+		if l.ID() != keys.InvalidID {
+			n++
+		}
+	}
+	return n >= int(prefs.MaxLoadedKeys), nil
+}
+
+// endToEndLoadUnload implements the steps of the end-to-end test that load
+// and then unload the key configured by the test. Failures are recorded by r;
+// ok is false if the key could not be returned to the unloaded state.
+func (u *UI) endToEndLoadUnload(ctx jsutil.AsyncContext, r *testRecorder, keyName string, prefs *keys.Preferences) (ok bool) {
+	passphraseDialog := u.dom.GetElement("passphraseDialog")
+	passphraseInput := u.dom.GetElement("passphrase")
+	passphraseOk := u.dom.GetElement("passphraseOk")
+	loadDialog := u.dom.GetElement("loadDialog")
+	loadYes := u.dom.GetElement("loadYes")
+	unloadDialog := u.dom.GetElement("unloadDialog")
+	unloadYes := u.dom.GetElement("unloadYes")
+
+	key := u.keyByName(keyName)
+	if key == nil {
+		r.fail(fmt.Errorf("before load: failed to find key"))
+		return false
 	}
 
-	jsutil.Log("Load the new key")
+	r.step("Load the new key")
+	u.revealKey(key.ID)
 	dom.DoClick(u.dom.GetElement(buttonID(LoadButton, key.ID)))
+	if prefs.ConfirmLoad {
+		// The user asked to confirm before loading keys.
+		r.step("Confirm loading key")
+		if !poll(ctx, func() bool { return loadDialog.Get("open").Bool() }) {
+			r.fail(fmt.Errorf("load dialog failed to open"))
+			return false
+		}
+		dom.DoClick(loadYes)
+	}
 	if !poll(ctx, func() bool { return passphraseDialog.Get("open").Bool() }) {
-		errs = append(errs, fmt.Errorf("passphrase dialog failed to open"))
-		return errs
+		r.fail(fmt.Errorf("passphrase dialog failed to open"))
+		return false
 	}
 	dom.SetValue(passphraseInput, testdata.LongKeyWithPassphrase.Passphrase)
 	dom.DoClick(passphraseOk)
 
-	jsutil.Log("Validate loaded keys; ensure new key is loaded")
+	r.step("Validate loaded keys; ensure new key is loaded")
 	if !poll(ctx, func() bool {
 		key = u.keyByName(keyName)
 		return key != nil && key.Loaded
 	}) {
-		errs = append(errs, fmt.Errorf("after loaded: failed to find loaded key"))
-		return errs
+		r.fail(fmt.Errorf("after loaded: failed to find loaded key"))
+		return false
 	}
 	if diff := cmp.Diff(key.Loaded, true); diff != "" {
-		errs = append(errs, fmt.Errorf("after load: incorrect loaded state: %s", diff))
+		r.fail(fmt.Errorf("after load: incorrect loaded state: %s", diff))
 	}
 	if diff := cmp.Diff(key.Type, testdata.LongKeyWithPassphrase.Type); diff != "" {
-		errs = append(errs, fmt.Errorf("after load: incorrect type: %s", diff))
+		r.fail(fmt.Errorf("after load: incorrect type: %s", diff))
 	}
 	if diff := cmp.Diff(key.Blob, testdata.LongKeyWithPassphrase.Blob); diff != "" {
-		errs = append(errs, fmt.Errorf("after load: incorrect blob: %s", diff))
+		r.fail(fmt.Errorf("after load: incorrect blob: %s", diff))
 	}
 
-	jsutil.Log("Unload key")
+	r.step("Unload key")
+	u.revealKey(key.ID)
 	dom.DoClick(u.dom.GetElement(buttonID(UnloadButton, key.ID)))
+	if prefs.ConfirmUnload {
+		// The user asked to confirm before unloading keys.
+		r.step("Confirm unloading key")
+		if !poll(ctx, func() bool { return unloadDialog.Get("open").Bool() }) {
+			r.fail(fmt.Errorf("unload dialog failed to open"))
+			return false
+		}
+		dom.DoClick(unloadYes)
+	}
 
-	jsutil.Log("Validate loaded keys; ensure key is unloaded")
+	r.step("Validate loaded keys; ensure key is unloaded")
 	if !poll(ctx, func() bool {
 		key = u.keyByName(keyName)
 		return key != nil && !key.Loaded
 	}) {
-		errs = append(errs, fmt.Errorf("after unload: failed to find unloaded key"))
-		return errs // Remaining tests have hard dependency on unloaded key.
+		r.fail(fmt.Errorf("after unload: failed to find unloaded key"))
+		return false
 	}
 	if diff := cmp.Diff(key.Loaded, false); diff != "" {
-		errs = append(errs, fmt.Errorf("after unload: incorrect loaded state: %s", diff))
+		r.fail(fmt.Errorf("after unload: incorrect loaded state: %s", diff))
 	}
 	if diff := cmp.Diff(key.Type, ""); diff != "" {
-		errs = append(errs, fmt.Errorf("after unload: incorrect type: %s", diff))
+		r.fail(fmt.Errorf("after unload: incorrect type: %s", diff))
 	}
 	if diff := cmp.Diff(key.Blob, ""); diff != "" {
-		errs = append(errs, fmt.Errorf("after unload: incorrect blob: %s", diff))
+		r.fail(fmt.Errorf("after unload: incorrect blob: %s", diff))
 	}
 
-	jsutil.Log("Remove key")
-	dom.DoClick(u.dom.GetElement(buttonID(RemoveButton, key.ID)))
-	if !poll(ctx, func() bool { return removeDialog.Get("open").Bool() }) {
-		errs = append(errs, fmt.Errorf("remove dialog failed to open"))
-		return errs
+	return true
+}
+
+// dismissDialogs cancels any dialog left open by an incomplete end-to-end
+// test. Cancelling (rather than simply closing) the dialog allows the
+// operation waiting on it to complete.
+func (u *UI) dismissDialogs() {
+	for dialog, cancel := range map[string]string{
+		"addDialog":        "addCancel",
+		"loadDialog":       "loadNo",
+		"passphraseDialog": "passphraseCancel",
+		"removeDialog":     "removeNo",
+		"unloadDialog":     "unloadNo",
+	} {
+		if u.dom.GetElement(dialog).Get("open").Bool() {
+			dom.DoClick(u.dom.GetElement(cancel))
+		}
 	}
-	dom.DoClick(removeYes)
+}
 
-	jsutil.Log("Validate configured keys; ensure key is removed")
-	if !poll(ctx, func() bool {
-		key = u.keyByName(keyName)
-		return key == nil
-	}) {
-		errs = append(errs, fmt.Errorf("after removed: failed to observe key as removed"))
-		return errs
+// cleanupTestKey removes the key configured by the end-to-end test, should
+// the test have left it behind. Failures are recorded by r.
+func (u *UI) cleanupTestKey(ctx jsutil.AsyncContext, r *testRecorder, keyName string) {
+	u.dismissDialogs()
+
+	configured, err := u.mgr.Configured(ctx)
+	if err != nil {
+		r.fail(fmt.Errorf("cleanup: failed to read configured keys: %w", err))
+		return
+	}
+
+	for _, k := range configured {
+		if k.Name != keyName {
+			continue
+		}
+		r.step("Clean up key left behind by test")
+		loaded, err := u.mgr.Loaded(ctx)
+		if err != nil {
+			r.fail(fmt.Errorf("cleanup: failed to read loaded keys: %w", err))
+		}
+		for _, l := range loaded {
+			if l.ID() != keys.ID(k.ID) {
+				continue
+			}
+			if err := u.mgr.Unload(ctx, keys.ID(k.ID)); err != nil {
+				r.fail(fmt.Errorf("cleanup: failed to unload key: %w", err))
+			}
+		}
+		if err := u.mgr.Remove(ctx, keys.ID(k.ID)); err != nil {
+			r.fail(fmt.Errorf("cleanup: failed to remove key: %w", err))
+		}
+	}
+	u.updateKeys(ctx)
+}
+
+// promptSelfTest displays a dialog prompting the user to confirm that the
+// self test should be run.
+func (u *UI) promptSelfTest(ctx jsutil.AsyncContext) (yes bool) {
+	dialog := dom.NewDialog(u.dom.GetElement("selfTestDialog"))
+	form := u.dom.GetElement("selfTestForm")
+	no := u.dom.GetElement("selfTestNo")
+
+	sig := newSignal()
+	var cleanup jsutil.CleanupFuncs
+	cleanup.Add(dom.OnSubmit(form, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		yes = true
+		dialog.Close()
+		sig.Notify()
+	}))
+	cleanup.Add(dom.OnClick(no, func(ctx jsutil.AsyncContext, evt dom.Event) {
+		dialog.Close()
+		sig.Notify()
+	}))
+	cleanup.Add(dialog.OnClose(func(ctx jsutil.AsyncContext, evt dom.Event) {
+		cleanup.Do()
+	}))
+
+	dialog.ShowModal()
+	sig.Wait(ctx)
+	return
+}
+
+// selfTestLogLine formats a step reported by the self test for display.
+func selfTestLogLine(t time.Time, msg string) string {
+	return fmt.Sprintf("%s %s", t.Format("15:04:05.000"), msg)
+}
+
+// selfTest runs the end-to-end test against the live manager and displays
+// its progress and results. Because the test configures, loads and removes a
+// temporary key, a dialog prompts the user to confirm before it is run.
+func (u *UI) selfTest(ctx jsutil.AsyncContext, _ dom.Event) {
+	if yes := u.promptSelfTest(ctx); !yes {
+		return
 	}
 
-	return errs
+	u.selfTestButton.Set("disabled", true)
+	defer u.selfTestButton.Set("disabled", false)
+	dom.RemoveChildren(u.selfTestLog)
+	dom.RemoveChildren(u.selfTestResults)
+
+	r := newTestRecorder(func(format string, args ...interface{}) {
+		jsutil.Log(format, args...)
+		dom.AppendChild(u.selfTestLog, u.dom.NewElement("li"), func(item js.Value) {
+			dom.AppendChild(item, u.dom.NewText(selfTestLogLine(time.Now(), fmt.Sprintf(format, args...))), nil)
+		})
+		// Keep the most recent step visible.
+		u.selfTestLog.Set("scrollTop", u.selfTestLog.Get("scrollHeight"))
+	})
+	u.endToEndTest(ctx, r)
+	errs := r.finish().Errors()
+
+	if len(errs) == 0 {
+		dom.AppendChild(u.selfTestResults, u.dom.NewElement("li"), func(item js.Value) {
+			item.Set("className", "selfTestPass")
+			dom.AppendChild(item, u.dom.NewText("PASS: all checks succeeded"), nil)
+		})
+		u.setStatus("Self test passed.")
+		return
+	}
+	for _, err := range errs {
+		dom.AppendChild(u.selfTestResults, u.dom.NewElement("li"), func(item js.Value) {
+			item.Set("className", "selfTestFail")
+			dom.AppendChild(item, u.dom.NewText(fmt.Sprintf("FAIL: %v", err)), nil)
+		})
+	}
+	u.setStatus(fmt.Sprintf("Self test failed with %d errors.", len(errs)))
 }
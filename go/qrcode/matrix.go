@@ -0,0 +1,311 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package qrcode
+
+// newCode returns a code of the given version with all modules light.
+func newCode(version int) *Code {
+	size := 4*version + 17
+	return &Code{
+		Size:     size,
+		modules:  make([]bool, size*size),
+		function: make([]bool, size*size),
+	}
+}
+
+// setFunction sets the color of a module forming part of a function
+// pattern, which is excluded from data placement and masking.
+func (c *Code) setFunction(x, y int, dark bool) {
+	c.modules[y*c.Size+x] = dark
+	c.function[y*c.Size+x] = true
+}
+
+// drawFunctionPatterns draws the finder, timing and alignment patterns and
+// the version information, and reserves space for the format information.
+func (c *Code) drawFunctionPatterns(version int, level Level) {
+	// Timing patterns.
+	for i := 0; i < c.Size; i++ {
+		c.setFunction(6, i, i%2 == 0)
+		c.setFunction(i, 6, i%2 == 0)
+	}
+
+	// Finder patterns, in three corners. These overwrite some of the
+	// timing patterns.
+	c.drawFinder(3, 3)
+	c.drawFinder(c.Size-4, 3)
+	c.drawFinder(3, c.Size-4)
+
+	// Alignment patterns, except where they would overlap the finder
+	// patterns.
+	pos := alignmentPositions(version)
+	for i, x := range pos {
+		for j, y := range pos {
+			if (i == 0 && j == 0) || (i == 0 && j == len(pos)-1) || (i == len(pos)-1 && j == 0) {
+				continue
+			}
+			c.drawAlignment(x, y)
+		}
+	}
+
+	// The format information is drawn once the mask is known; draw
+	// placeholder bits now so that the modules are reserved.
+	c.drawFormatBits(level, 0)
+	c.drawVersion(version)
+}
+
+// drawFinder draws a finder pattern, including its separator, centered on
+// the supplied module.
+func (c *Code) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || yy < 0 || xx >= c.Size || yy >= c.Size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			c.setFunction(xx, yy, dist != 2 && dist != 4)
+		}
+	}
+}
+
+// drawAlignment draws an alignment pattern centered on the supplied module.
+func (c *Code) drawAlignment(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			c.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// alignmentPositions returns the rows (and equivalently columns) on which
+// alignment patterns are centered.
+func alignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	n := version/7 + 2
+	step := (version*8 + n*3 + 5) / (n*4 - 4) * 2
+	result := make([]int, n)
+	result[0] = 6
+	for i, pos := n-1, 4*version+10; i >= 1; i, pos = i-1, pos-step {
+		result[i] = pos
+	}
+	return result
+}
+
+// drawFormatBits draws both copies of the format information, identifying
+// the error correction level and mask.
+func (c *Code) drawFormatBits(level Level, mask int) {
+	data := level.formatBits()<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+
+	// First copy, around the top left finder pattern.
+	for i := 0; i <= 5; i++ {
+		c.setFunction(8, i, bit(bits, i))
+	}
+	c.setFunction(8, 7, bit(bits, 6))
+	c.setFunction(8, 8, bit(bits, 7))
+	c.setFunction(7, 8, bit(bits, 8))
+	for i := 9; i < 15; i++ {
+		c.setFunction(14-i, 8, bit(bits, i))
+	}
+
+	// Second copy, split between the other two finder patterns.
+	for i := 0; i < 8; i++ {
+		c.setFunction(c.Size-1-i, 8, bit(bits, i))
+	}
+	for i := 8; i < 15; i++ {
+		c.setFunction(8, c.Size-15+i, bit(bits, i))
+	}
+	// The module beside the bottom left finder pattern is always dark.
+	c.setFunction(8, c.Size-8, true)
+}
+
+// drawVersion draws both copies of the version information, which is only
+// present from version 7.
+func (c *Code) drawVersion(version int) {
+	if version < 7 {
+		return
+	}
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1f25)
+	}
+	bits := version<<12 | rem
+
+	for i := 0; i < 18; i++ {
+		a, b := c.Size-11+i%3, i/3
+		c.setFunction(a, b, bit(bits, i))
+		c.setFunction(b, a, bit(bits, i))
+	}
+}
+
+// drawCodewords places the codewords in the modules not occupied by
+// function patterns, zigzagging in two-module wide columns from the bottom
+// right corner.
+func (c *Code) drawCodewords(codewords []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		// Skip the vertical timing pattern.
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < c.Size; vert++ {
+			y := vert
+			if upward {
+				y = c.Size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if c.function[y*c.Size+x] || i >= len(codewords)*8 {
+					// Any remainder bits are left light.
+					continue
+				}
+				c.modules[y*c.Size+x] = codewords[i/8]>>(7-i%8)&1 != 0
+				i++
+			}
+		}
+	}
+}
+
+// applyMask inverts the data modules selected by the mask. Applying the same
+// mask again restores the original modules.
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !c.function[y*c.Size+x] {
+				c.modules[y*c.Size+x] = !c.modules[y*c.Size+x]
+			}
+		}
+	}
+}
+
+// finderLike is a sequence of modules resembling part of a finder pattern:
+// dark-light-dark-dark-dark-light-dark, preceded by four light modules.
+var finderLike = []bool{false, false, false, false, true, false, true, true, true, false, true}
+
+// penalty scores the appearance of the code; codes with a lower score are
+// easier to scan. The rules are those the standard uses to choose a mask.
+func (c *Code) penalty() int {
+	result := 0
+
+	// Runs of five or more modules of the same color in a row or column,
+	// and sequences resembling finder patterns.
+	for _, transpose := range []bool{false, true} {
+		at := func(i, j int) bool {
+			if transpose {
+				return c.Dark(j, i)
+			}
+			return c.Dark(i, j)
+		}
+		for j := 0; j < c.Size; j++ {
+			run := 0
+			for i := 0; i < c.Size; i++ {
+				if i > 0 && at(i, j) == at(i-1, j) {
+					run++
+				} else {
+					run = 1
+				}
+				if run == 5 {
+					result += 3
+				} else if run > 5 {
+					result++
+				}
+			}
+			for i := -len(finderLike) + 1; i < c.Size; i++ {
+				if matchesFinder(at, i, j, false) {
+					result += 40
+				}
+				if matchesFinder(at, i, j, true) {
+					result += 40
+				}
+			}
+		}
+	}
+
+	// 2x2 blocks of the same color.
+	for y := 0; y < c.Size-1; y++ {
+		for x := 0; x < c.Size-1; x++ {
+			d := c.Dark(x, y)
+			if d == c.Dark(x+1, y) && d == c.Dark(x, y+1) && d == c.Dark(x+1, y+1) {
+				result += 3
+			}
+		}
+	}
+
+	// Deviation from an equal number of dark and light modules, in steps
+	// of 5%.
+	dark := 0
+	for _, m := range c.modules {
+		if m {
+			dark++
+		}
+	}
+	total := c.Size * c.Size
+	result += 10 * (abs(dark*20-total*10) / total)
+	return result
+}
+
+// matchesFinder returns true if the modules starting at i along line j
+// match finderLike, or its reverse. Modules beyond the edge of the code are
+// light, since the code is surrounded by a light quiet zone.
+func matchesFinder(at func(i, j int) bool, i, j int, reverse bool) bool {
+	for k, want := range finderLike {
+		if reverse {
+			want = finderLike[len(finderLike)-1-k]
+		}
+		if at(i+k, j) != want {
+			return false
+		}
+	}
+	return true
+}
+
+// bit returns true if bit i of v is set.
+func bit(v, i int) bool {
+	return (v>>i)&1 != 0
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
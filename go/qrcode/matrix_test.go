@@ -0,0 +1,150 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package qrcode
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// modules returns the code's modules as one string per row, with '#' for
+// dark modules and '.' for light modules.
+func modules(c *Code) []string {
+	var rows []string
+	for y := 0; y < c.Size; y++ {
+		var b strings.Builder
+		for x := 0; x < c.Size; x++ {
+			if c.Dark(x, y) {
+				b.WriteByte('#')
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		rows = append(rows, b.String())
+	}
+	return rows
+}
+
+func TestKnownVector(t *testing.T) {
+	t.Parallel()
+
+	// "ssh-ed25519" as a version 1-M code with mask 3, as produced by
+	// an independent encoder.
+	want := []string{
+		"#######.##..#.#######",
+		"#.....#.#.##..#.....#",
+		"#.###.#...#...#.###.#",
+		"#.###.#.#.#...#.###.#",
+		"#.###.#..###..#.###.#",
+		"#.....#....##.#.....#",
+		"#######.#.#.#.#######",
+		"........##.##........",
+		"#.##.###..###.#..#.##",
+		"#.####...#####..#.###",
+		"###.###.####.##...###",
+		"..####...###.#...#..#",
+		".....###..#.####.....",
+		"........#..#.#######.",
+		"#######.#.###...#....",
+		"#.....#.###....#..###",
+		"#.###.#..#..##.#.##..",
+		"#.###.#.##...#...###.",
+		"#.###.#.#.#.##...##..",
+		"#.....#..##...##....#",
+		"#######.###.#.###.#..",
+	}
+
+	c, err := encode([]byte("ssh-ed25519"), Medium, 3)
+	if err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+	if diff := cmp.Diff(modules(c), want); diff != "" {
+		t.Errorf("incorrect modules; -got +want: %s", diff)
+	}
+}
+
+func TestAutoMask(t *testing.T) {
+	t.Parallel()
+
+	data := []byte("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGXqjJBm6xcVYOSpbP3wFeWpZMJmVfqXw16sHYJVY8Jb some-key")
+	got, err := Encode(data, Medium)
+	if err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+
+	// The chosen mask must have the lowest penalty, and the code must
+	// otherwise be identical to that using an explicit mask.
+	var matched bool
+	for mask := 0; mask < numMasks; mask++ {
+		c, err := encode(data, Medium, mask)
+		if err != nil {
+			t.Fatalf("failed to encode with mask %d: %v", mask, err)
+		}
+		if c.penalty() < got.penalty() {
+			t.Errorf("mask %d has lower penalty %d than chosen mask (%d)", mask, c.penalty(), got.penalty())
+		}
+		if cmp.Equal(modules(c), modules(got)) {
+			matched = true
+		}
+	}
+	if !matched {
+		t.Errorf("code does not match any mask")
+	}
+}
+
+func TestAlignmentPositions(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		version int
+		want    []int
+	}{
+		{version: 1, want: nil},
+		{version: 2, want: []int{6, 18}},
+		{version: 7, want: []int{6, 22, 38}},
+		{version: 32, want: []int{6, 34, 60, 86, 112, 138}},
+		{version: 40, want: []int{6, 30, 58, 86, 114, 142, 170}},
+	}
+
+	for _, tc := range testcases {
+		if diff := cmp.Diff(alignmentPositions(tc.version), tc.want); diff != "" {
+			t.Errorf("incorrect positions for version %d; -got +want: %s", tc.version, diff)
+		}
+	}
+}
+
+func TestVersionInformation(t *testing.T) {
+	t.Parallel()
+
+	c := newCode(7)
+	c.drawVersion(7)
+
+	// Both copies hold the version and its BCH error correction bits.
+	var bottomLeft, topRight strings.Builder
+	for i := 17; i >= 0; i-- {
+		a, b := c.Size-11+i%3, i/3
+		bottomLeft.WriteString(map[bool]string{false: "0", true: "1"}[c.Dark(b, a)])
+		topRight.WriteString(map[bool]string{false: "0", true: "1"}[c.Dark(a, b)])
+	}
+	const want = "000111110010010100"
+	if diff := cmp.Diff(bottomLeft.String(), want); diff != "" {
+		t.Errorf("incorrect bottom left version information; -got +want: %s", diff)
+	}
+	if diff := cmp.Diff(topRight.String(), want); diff != "" {
+		t.Errorf("incorrect top right version information; -got +want: %s", diff)
+	}
+}
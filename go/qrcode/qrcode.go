@@ -0,0 +1,327 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package qrcode encodes data as a QR code (ISO/IEC 18004), so that it may be
+// displayed and scanned by another device.
+//
+// Only byte mode encoding is supported. This is sufficient for the short,
+// ASCII text (e.g., public keys) encoded by the extension, and the smallest
+// version capable of holding the data is chosen automatically.
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Level is the level of error correction used in a QR code. Higher levels
+// tolerate more damage to the code, at the cost of capacity.
+type Level int
+
+const (
+	// Low recovers from about 7% of the code being damaged.
+	Low Level = iota
+	// Medium recovers from about 15% of the code being damaged.
+	Medium
+	// Quartile recovers from about 25% of the code being damaged.
+	Quartile
+	// High recovers from about 30% of the code being damaged.
+	High
+)
+
+// formatBits returns the bits identifying the level in a code's format
+// information.
+func (l Level) formatBits() int {
+	return [...]int{1, 0, 3, 2}[l]
+}
+
+const (
+	// minVersion and maxVersion are the range of supported versions. The
+	// version determines the size of a code.
+	minVersion = 1
+	maxVersion = 40
+	// autoMask indicates that the mask is to be chosen automatically.
+	autoMask = -1
+	// numMasks is the number of masks defined by the standard.
+	numMasks = 8
+)
+
+var (
+	// ErrTooLong indicates that the data cannot fit in a QR code.
+	ErrTooLong = errors.New("data too long for a QR code")
+	// errInvalidLevel indicates that the error correction level is not
+	// one of those defined.
+	errInvalidLevel = errors.New("invalid error correction level")
+)
+
+var (
+	// eccCodewordsPerBlock is the number of error correction codewords in
+	// each block, indexed by level and version.
+	eccCodewordsPerBlock = [4][maxVersion + 1]int{
+		Low:      {0, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+		Medium:   {0, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+		Quartile: {0, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+		High:     {0, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	}
+	// numBlocks is the number of blocks into which the codewords are
+	// split, indexed by level and version.
+	numBlocks = [4][maxVersion + 1]int{
+		Low:      {0, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+		Medium:   {0, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+		Quartile: {0, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+		High:     {0, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+	}
+)
+
+// Code is a QR code: a square grid of dark and light modules.
+type Code struct {
+	// Size is the number of modules along each side of the code. It does
+	// not include the quiet zone (i.e., the light border) that must
+	// surround the code when it is displayed.
+	Size int
+	// modules holds the color of each module, in row-major order; true is
+	// dark.
+	modules []bool
+	// function indicates modules that are part of a function pattern
+	// (e.g., a finder pattern), rather than holding data. Only used while
+	// the code is built.
+	function []bool
+}
+
+// Dark returns true if the module in column x and row y is dark. Modules
+// outside the code are light.
+func (c *Code) Dark(x, y int) bool {
+	if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+		return false
+	}
+	return c.modules[y*c.Size+x]
+}
+
+// Encode returns a QR code holding data, using the smallest version that
+// can hold it with the requested level of error correction.
+func Encode(data []byte, level Level) (*Code, error) {
+	return encode(data, level, autoMask)
+}
+
+// encode returns a QR code holding data. The supplied mask is applied, or
+// the mask is chosen automatically if it is autoMask.
+func encode(data []byte, level Level, mask int) (*Code, error) {
+	if level < Low || level > High {
+		return nil, errInvalidLevel
+	}
+
+	version := minVersion
+	for ; ; version++ {
+		if version > maxVersion {
+			return nil, fmt.Errorf("%w: %d bytes", ErrTooLong, len(data))
+		}
+		if dataBits(version, len(data)) <= numDataCodewords(version, level)*8 {
+			break
+		}
+	}
+
+	c := newCode(version)
+	c.drawFunctionPatterns(version, level)
+	c.drawCodewords(interleave(dataCodewords(version, level, data), version, level))
+
+	if mask == autoMask {
+		// Choose the mask minimizing the penalty, as the standard
+		// requires. Masks are self-inverse, so each is removed again
+		// by applying it a second time.
+		best := 0
+		for m := 0; m < numMasks; m++ {
+			c.applyMask(m)
+			c.drawFormatBits(level, m)
+			p := c.penalty()
+			if m == 0 || p < best {
+				best, mask = p, m
+			}
+			c.applyMask(m)
+		}
+	}
+	c.applyMask(mask)
+	c.drawFormatBits(level, mask)
+	c.function = nil
+	return c, nil
+}
+
+// charCountBits returns the number of bits used to encode the length of the
+// data in byte mode.
+func charCountBits(version int) int {
+	if version <= 9 {
+		return 8
+	}
+	return 16
+}
+
+// dataBits returns the number of bits required to encode n bytes in byte
+// mode.
+func dataBits(version, n int) int {
+	return 4 + charCountBits(version) + 8*n
+}
+
+// numRawDataModules returns the number of modules available for data and
+// error correction codewords, including any remainder bits.
+func numRawDataModules(version int) int {
+	result := (16*version+128)*version + 64
+	if version >= 2 {
+		numAlign := version/7 + 2
+		result -= (25*numAlign-10)*numAlign - 55
+		if version >= 7 {
+			// Version information.
+			result -= 36
+		}
+	}
+	return result
+}
+
+// numDataCodewords returns the number of data codewords a code may hold,
+// excluding error correction codewords.
+func numDataCodewords(version int, level Level) int {
+	return numRawDataModules(version)/8 - eccCodewordsPerBlock[level][version]*numBlocks[level][version]
+}
+
+// bitWriter accumulates a sequence of bits.
+type bitWriter struct {
+	bits []bool
+}
+
+// write appends the low n bits of v, most significant first.
+func (w *bitWriter) write(v, n int) {
+	for i := n - 1; i >= 0; i-- {
+		w.bits = append(w.bits, (v>>i)&1 != 0)
+	}
+}
+
+// bytes returns the accumulated bits packed into bytes. The number of bits
+// must be a multiple of 8.
+func (w *bitWriter) bytes() []byte {
+	result := make([]byte, len(w.bits)/8)
+	for i, b := range w.bits {
+		if b {
+			result[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	return result
+}
+
+// dataCodewords returns the data codewords for a code holding data: a byte
+// mode segment, followed by a terminator and padding.
+func dataCodewords(version int, level Level, data []byte) []byte {
+	capacity := numDataCodewords(version, level) * 8
+
+	var w bitWriter
+	w.write(0x4, 4) // Byte mode indicator.
+	w.write(len(data), charCountBits(version))
+	for _, b := range data {
+		w.write(int(b), 8)
+	}
+
+	// Terminate the data with up to 4 zero bits, pad to a byte boundary,
+	// and then fill the remaining capacity with alternating pad bytes.
+	w.write(0, min(4, capacity-len(w.bits)))
+	w.write(0, (8-len(w.bits)%8)%8)
+	for pad := 0xec; len(w.bits) < capacity; pad ^= 0xec ^ 0x11 {
+		w.write(pad, 8)
+	}
+	return w.bytes()
+}
+
+// interleave splits the data codewords into blocks, appends error
+// correction codewords to each, and interleaves the blocks into the final
+// sequence of codewords.
+func interleave(data []byte, version int, level Level) []byte {
+	blocks := numBlocks[level][version]
+	eccLen := eccCodewordsPerBlock[level][version]
+	rawCodewords := numRawDataModules(version) / 8
+	// Blocks differ in length by at most one data codeword; the short
+	// blocks come first.
+	numShort := blocks - rawCodewords%blocks
+	shortLen := rawCodewords / blocks
+	divisor := reedSolomonDivisor(eccLen)
+
+	// Short blocks include a placeholder so that all blocks have the
+	// same length; the placeholders are skipped when interleaving.
+	var all [][]byte
+	for i, k := 0, 0; i < blocks; i++ {
+		n := shortLen - eccLen
+		if i >= numShort {
+			n++
+		}
+		block := make([]byte, shortLen+1)
+		copy(block, data[k:k+n])
+		copy(block[shortLen+1-eccLen:], reedSolomonRemainder(data[k:k+n], divisor))
+		k += n
+		all = append(all, block)
+	}
+
+	result := make([]byte, 0, rawCodewords)
+	for i := 0; i < shortLen+1; i++ {
+		for j, block := range all {
+			if i != shortLen-eccLen || j >= numShort {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
+
+// gfMultiply returns the product of x and y in GF(2^8), modulo the
+// polynomial x^8 + x^4 + x^3 + x^2 + 1 used by QR codes.
+func gfMultiply(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11d)
+		z ^= int(y>>i&1) * int(x)
+	}
+	return byte(z)
+}
+
+// reedSolomonDivisor returns the coefficients of the generator polynomial
+// of the given degree, from highest to lowest power, excluding the leading
+// coefficient (which is always 1).
+func reedSolomonDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	// Multiply by (x - r^i) for each i, where r = 0x02 generates the
+	// field.
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+// reedSolomonRemainder returns the error correction codewords for data:
+// the remainder of dividing it by the generator polynomial.
+func reedSolomonRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, d := range divisor {
+			result[i] ^= gfMultiply(d, factor)
+		}
+	}
+	return result
+}
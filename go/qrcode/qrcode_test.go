@@ -0,0 +1,114 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReedSolomonRemainder(t *testing.T) {
+	t.Parallel()
+
+	// Data codewords for "HELLO WORLD" as a version 1-M code, and the
+	// corresponding error correction codewords.
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+
+	got := reedSolomonRemainder(data, reedSolomonDivisor(len(want)))
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("incorrect error correction codewords; -got +want: %s", diff)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		data        []byte
+		level       Level
+		wantSize    int
+		wantErr     error
+	}{
+		{
+			description: "empty",
+			level:       Medium,
+			wantSize:    21,
+		},
+		{
+			description: "fills version 1",
+			data:        bytes.Repeat([]byte{'a'}, 17),
+			level:       Low,
+			wantSize:    21,
+		},
+		{
+			description: "exceeds version 1",
+			data:        bytes.Repeat([]byte{'a'}, 18),
+			level:       Low,
+			wantSize:    25,
+		},
+		{
+			description: "higher level requires larger version",
+			data:        bytes.Repeat([]byte{'a'}, 17),
+			level:       High,
+			wantSize:    29,
+		},
+		{
+			description: "wider character count from version 10",
+			data:        bytes.Repeat([]byte{'a'}, 231),
+			level:       Low,
+			wantSize:    57,
+		},
+		{
+			description: "fills version 40",
+			data:        bytes.Repeat([]byte{'a'}, 2953),
+			level:       Low,
+			wantSize:    177,
+		},
+		{
+			description: "too long",
+			data:        bytes.Repeat([]byte{'a'}, 2954),
+			level:       Low,
+			wantErr:     ErrTooLong,
+		},
+		{
+			description: "invalid level",
+			level:       Level(4),
+			wantErr:     errInvalidLevel,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			c, err := Encode(tc.data, tc.level)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("incorrect error; got %v, want %v", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(c.Size, tc.wantSize); diff != "" {
+				t.Errorf("incorrect size; -got +want: %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package reltime formats times relative to the present, in a form suitable
+// for display to the user (e.g., '2 hours ago').
+package reltime
+
+import (
+	"fmt"
+	"time"
+)
+
+// units are the units in which relative times are described, from largest
+// to smallest.
+var units = []struct {
+	name string
+	d    time.Duration
+}{
+	{"year", 365 * 24 * time.Hour},
+	{"month", 30 * 24 * time.Hour},
+	{"week", 7 * 24 * time.Hour},
+	{"day", 24 * time.Hour},
+	{"hour", time.Hour},
+	{"minute", time.Minute},
+}
+
+// Format describes t relative to now, using the largest unit that applies
+// (e.g., '2 hours ago' or 'in 3 days'). Times within a minute of now are
+// described as 'just now'.
+func Format(t, now time.Time) string {
+	d := now.Sub(t)
+	future := d < 0
+	if future {
+		d = -d
+	}
+
+	for _, u := range units {
+		n := int64(d / u.d)
+		if n < 1 {
+			continue
+		}
+		s := fmt.Sprintf("%d %s", n, u.name)
+		if n != 1 {
+			s += "s"
+		}
+		if future {
+			return "in " + s
+		}
+		return s + " ago"
+	}
+	return "just now"
+}
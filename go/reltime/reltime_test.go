@@ -0,0 +1,91 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reltime
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFormat(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	testcases := []struct {
+		description string
+		t           time.Time
+		want        string
+	}{
+		{
+			description: "now",
+			t:           now,
+			want:        "just now",
+		},
+		{
+			description: "seconds ago",
+			t:           now.Add(-59 * time.Second),
+			want:        "just now",
+		},
+		{
+			description: "one minute ago",
+			t:           now.Add(-time.Minute),
+			want:        "1 minute ago",
+		},
+		{
+			description: "hours ago",
+			t:           now.Add(-2*time.Hour - 30*time.Minute),
+			want:        "2 hours ago",
+		},
+		{
+			description: "days ago",
+			t:           now.Add(-3 * 24 * time.Hour),
+			want:        "3 days ago",
+		},
+		{
+			description: "weeks ago",
+			t:           now.Add(-15 * 24 * time.Hour),
+			want:        "2 weeks ago",
+		},
+		{
+			description: "months ago",
+			t:           now.Add(-95 * 24 * time.Hour),
+			want:        "3 months ago",
+		},
+		{
+			description: "one year ago",
+			t:           now.Add(-400 * 24 * time.Hour),
+			want:        "1 year ago",
+		},
+		{
+			description: "future",
+			t:           now.Add(5 * time.Minute),
+			want:        "in 5 minutes",
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			if diff := cmp.Diff(Format(tc.t, now), tc.want); diff != "" {
+				t.Errorf("incorrect text; -got +want: %s", diff)
+			}
+		})
+	}
+}
@@ -17,6 +17,7 @@
 package storage
 
 import (
+	"errors"
 	"syscall/js"
 
 	"github.com/google/chrome-ssh-agent/go/jsutil"
@@ -41,3 +42,71 @@ type Area interface {
 	// error will be returned).
 	Delete(ctx jsutil.AsyncContext, keys []string) error
 }
+
+// Usage describes the space consumed in a storage area, along with the quotas
+// that apply to it.
+type Usage struct {
+	// BytesInUse is the total space used by all items in the area.
+	BytesInUse int
+	// QuotaBytes is the maximum total space permitted in the area. It is
+	// zero if the area does not report a quota.
+	QuotaBytes int
+	// QuotaBytesPerItem is the maximum space permitted for a single item
+	// in the area. It is zero if the area does not limit individual items.
+	QuotaBytesPerItem int
+}
+
+// UsageArea is implemented by Area implementations that can report the space
+// they consume.
+type UsageArea interface {
+	Area
+
+	// Usage returns the space consumed in the area.
+	Usage(ctx jsutil.AsyncContext) (*Usage, error)
+}
+
+// KeysArea is implemented by Area implementations that can read a subset of
+// the stored items without reading all of them.
+type KeysArea interface {
+	Area
+
+	// GetKeys reads the items stored with the specified keys. Keys that
+	// are not found in storage are omitted from the returned data.
+	GetKeys(ctx jsutil.AsyncContext, keys []string) (map[string]js.Value, error)
+}
+
+// AreaGetKeys reads the items stored with the specified keys in the supplied
+// storage area. If the area cannot read a subset of the stored items, all
+// items are read and those with other keys are discarded.
+func AreaGetKeys(ctx jsutil.AsyncContext, area Area, keys []string) (map[string]js.Value, error) {
+	if ka, ok := area.(KeysArea); ok {
+		return ka.GetKeys(ctx, keys)
+	}
+
+	data, err := area.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := map[string]js.Value{}
+	for _, k := range keys {
+		if v, present := data[k]; present {
+			res[k] = v
+		}
+	}
+	return res, nil
+}
+
+var (
+	// ErrUsageUnsupported indicates that a storage area cannot report the
+	// space it consumes.
+	ErrUsageUnsupported = errors.New("storage area does not report usage")
+)
+
+// AreaUsage returns the space consumed in the supplied storage area.
+func AreaUsage(ctx jsutil.AsyncContext, area Area) (*Usage, error) {
+	ua, ok := area.(UsageArea)
+	if !ok {
+		return nil, ErrUsageUnsupported
+	}
+	return ua.Usage(ctx)
+}
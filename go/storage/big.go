@@ -200,32 +200,87 @@ func (b *Big) Get(ctx jsutil.AsyncContext) (map[string]js.Value, error) {
 			continue
 		}
 
-		// Attempt to read as a manifest.
-		var manifest bigValueManifest
-		if err := vert.ValueOf(v).AssignTo(&manifest); err == nil && manifest.Valid() {
-			// Concatenate chunks and parse the JSON.
-			var json strings.Builder
-			for _, chunkKey := range manifest.ChunkKeys {
-				chunkVal, present := data[chunkKey]
-				if !present {
-					return nil, fmt.Errorf("failed to read data; chunk key %s missing", chunkKey)
-				}
-				dec, err := base64.StdEncoding.DecodeString(chunkVal.String())
-				if err != nil {
-					return nil, fmt.Errorf("failed to read data; base64 decode failed: %w", err)
-				}
+		uv, err := unchunk(v, data)
+		if err != nil {
+			return nil, err
+		}
+		unchunked[k] = uv
+	}
 
-				json.WriteString(string(dec))
-			}
+	return unchunked, nil
+}
 
-			unchunked[k] = jsutil.FromJSON(json.String())
-			continue
-		}
+// readManifest returns the manifest stored as the specified value, or nil if
+// the value is not a manifest.
+func readManifest(v js.Value) *bigValueManifest {
+	var manifest bigValueManifest
+	if err := vert.ValueOf(v).AssignTo(&manifest); err != nil || !manifest.Valid() {
+		return nil
+	}
+	return &manifest
+}
 
+// unchunk returns the original value for the specified stored value. If the
+// stored value is a manifest, the value is reassembled from the chunks it
+// references, which must be present in chunks.
+func unchunk(v js.Value, chunks map[string]js.Value) (js.Value, error) {
+	manifest := readManifest(v)
+	if manifest == nil {
 		// This is just a simple key.
-		unchunked[k] = v
+		return v, nil
 	}
 
+	// Concatenate chunks and parse the JSON.
+	var json strings.Builder
+	for _, chunkKey := range manifest.ChunkKeys {
+		chunkVal, present := chunks[chunkKey]
+		if !present {
+			return js.Undefined(), fmt.Errorf("failed to read data; chunk key %s missing", chunkKey)
+		}
+		dec, err := base64.StdEncoding.DecodeString(chunkVal.String())
+		if err != nil {
+			return js.Undefined(), fmt.Errorf("failed to read data; base64 decode failed: %w", err)
+		}
+
+		json.WriteString(string(dec))
+	}
+	return jsutil.FromJSON(json.String()), nil
+}
+
+// GetKeys implements KeysArea.GetKeys(). Only the requested items, and the
+// chunks referenced by them, are read from the underlying storage.
+func (b *Big) GetKeys(ctx jsutil.AsyncContext, keys []string) (map[string]js.Value, error) {
+	var data, chunks map[string]js.Value
+	var err error
+	_, aerr := lock.Async(lockResourceID, func(ctx jsutil.AsyncContext) {
+		data, err = AreaGetKeys(ctx, b.s, keys)
+		if err != nil {
+			return
+		}
+
+		var chunkKeys []string
+		for _, v := range data {
+			if manifest := readManifest(v); manifest != nil {
+				chunkKeys = append(chunkKeys, manifest.ChunkKeys...)
+			}
+		}
+		chunks, err = AreaGetKeys(ctx, b.s, chunkKeys)
+	}).Await(ctx)
+	if aerr != nil {
+		return nil, aerr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	unchunked := map[string]js.Value{}
+	for k, v := range data {
+		uv, err := unchunk(v, chunks)
+		if err != nil {
+			return nil, err
+		}
+		unchunked[k] = uv
+	}
 	return unchunked, nil
 }
 
@@ -258,8 +313,8 @@ func (b *Big) Delete(ctx jsutil.AsyncContext, keys []string) error {
 
 			// Remove those that are referenced by a manifest.
 			for _, v := range data {
-				var manifest bigValueManifest
-				if err := vert.ValueOf(v).AssignTo(&manifest); err != nil || !manifest.Valid() {
+				manifest := readManifest(v)
+				if manifest == nil {
 					continue // This is not a manifest.
 				}
 				for _, chunkKey := range manifest.ChunkKeys {
@@ -283,3 +338,10 @@ func (b *Big) Delete(ctx jsutil.AsyncContext, keys []string) error {
 	}
 	return derr
 }
+
+// Usage implements UsageArea.Usage(). The usage of the underlying storage area
+// is reported, including its per-item quota; values larger than the per-item
+// quota are nonetheless stored by splitting them into chunks.
+func (b *Big) Usage(ctx jsutil.AsyncContext) (*Usage, error) {
+	return AreaUsage(ctx, b.s)
+}
@@ -24,6 +24,7 @@ import (
 	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
 	st "github.com/google/chrome-ssh-agent/go/storage/testing"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/norunners/vert"
 )
 
@@ -273,3 +274,108 @@ func TestDelete(t *testing.T) {
 		})
 	}
 }
+
+func TestGetKeys(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		b := NewBig(200, NewRaw(st.NewMemArea()))
+		err := b.Set(ctx, map[string]js.Value{
+			"small":  js.ValueOf(2),
+			"big":    js.ValueOf(strings.Repeat("a", 200)),
+			"unread": js.ValueOf(strings.Repeat("b", 200)),
+		})
+		if err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+
+		got, err := b.GetKeys(ctx, []string{"small", "big", "missing"})
+		if err != nil {
+			t.Fatalf("GetKeys failed: %v", err)
+		}
+		want := map[string]string{
+			"small": "2",
+			"big":   fmt.Sprintf(`"%s"`, strings.Repeat("a", 200)),
+		}
+		if diff := cmp.Diff(dataToJSON(got), want); diff != "" {
+			t.Errorf("incorrect data: -got +want: %s", diff)
+		}
+	})
+}
+
+// getOnlyArea wraps an Area, hiding any optional interfaces (e.g., KeysArea)
+// implemented by it.
+type getOnlyArea struct {
+	Area
+}
+
+func TestAreaGetKeysUnsupported(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		area := getOnlyArea{Area: NewRaw(st.NewMemArea())}
+		err := area.Set(ctx, map[string]js.Value{
+			"key1": js.ValueOf(1),
+			"key2": js.ValueOf(2),
+		})
+		if err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+
+		// All items are read, and the requested ones returned.
+		got, err := AreaGetKeys(ctx, area, []string{"key2", "missing"})
+		if err != nil {
+			t.Fatalf("AreaGetKeys failed: %v", err)
+		}
+		want := map[string]string{
+			"key2": "2",
+		}
+		if diff := cmp.Diff(dataToJSON(got), want); diff != "" {
+			t.Errorf("incorrect data: -got +want: %s", diff)
+		}
+	})
+}
+
+func TestBigUsage(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		area := st.NewMemArea()
+		area.Set("QUOTA_BYTES", 1000)
+		area.Set("QUOTA_BYTES_PER_ITEM", 100)
+		raw := NewRaw(area)
+		b := NewBig(100, raw)
+
+		// Store a value that must be split into chunks.
+		if err := b.Set(ctx, map[string]js.Value{"key": js.ValueOf(strings.Repeat("a", 200))}); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+
+		got, err := b.Usage(ctx)
+		if err != nil {
+			t.Fatalf("Usage failed: %v", err)
+		}
+		want, err := raw.Usage(ctx)
+		if err != nil {
+			t.Fatalf("Usage failed: %v", err)
+		}
+		if diff := cmp.Diff(got, want); diff != "" {
+			t.Errorf("incorrect usage; -got +want: %s", diff)
+		}
+		if got.BytesInUse <= 200 {
+			t.Errorf("usage does not include chunks: %d bytes", got.BytesInUse)
+		}
+	})
+}
+
+func TestAreaUsageUnsupported(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		view := NewView([]string{"prefix"}, NewRaw(st.NewMemArea()))
+		_, err := AreaUsage(ctx, view)
+		if diff := cmp.Diff(err, ErrUsageUnsupported, cmpopts.EquateErrors()); diff != "" {
+			t.Errorf("incorrect error; -got +want: %s", diff)
+		}
+	})
+}
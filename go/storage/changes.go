@@ -0,0 +1,82 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"syscall/js"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+)
+
+// DefaultOnChanged returns the event fired when data in any of Chrome's
+// storage areas changes.  See:
+//
+//	https://developer.chrome.com/docs/extensions/reference/storage/#event-onChanged
+func DefaultOnChanged() js.Value {
+	return js.Global().Get("chrome").Get("storage").Get("onChanged")
+}
+
+// changed indicates if a StorageChange object modifies the item's value.
+func changed(change js.Value) bool {
+	return jsutil.ToJSON(change.Get("oldValue")) != jsutil.ToJSON(change.Get("newValue"))
+}
+
+// OnChanged registers a callback to be invoked when data in the storage area
+// named areaName (e.g., 'sync') changes. event must implement the API of
+// chrome.storage.onChanged; see DefaultOnChanged().
+//
+// The callback receives a StorageChange object for each modified item,
+// indexed by key.  Writes that leave an item's value unmodified are omitted;
+// if no changes remain, the callback is not invoked.  This avoids a loop
+// where a callback that rewrites data triggers itself.
+//
+// The returned cleanup function must be invoked to cleanup the callback.
+func OnChanged(event js.Value, areaName string, callback func(ctx jsutil.AsyncContext, changes map[string]js.Value)) jsutil.CleanupFunc {
+	fo := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		var changesObj, area js.Value
+		jsutil.ExpandArgs(args, &changesObj, &area)
+		if area.Type() != js.TypeString || area.String() != areaName {
+			return nil
+		}
+
+		all, err := valueToData(changesObj)
+		if err != nil {
+			jsutil.LogError("failed to parse storage changes: %v", err)
+			return nil
+		}
+		changes := map[string]js.Value{}
+		for k, v := range all {
+			if changed(v) {
+				changes[k] = v
+			}
+		}
+		if len(changes) == 0 {
+			return nil
+		}
+
+		jsutil.Async(func(ctx jsutil.AsyncContext) (js.Value, error) {
+			callback(ctx, changes)
+			return js.Undefined(), nil
+		})
+		return nil
+	})
+	event.Call("addListener", fo)
+	return func() {
+		event.Call("removeListener", fo)
+		fo.Release()
+	}
+}
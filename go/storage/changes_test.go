@@ -0,0 +1,108 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"syscall/js"
+	"testing"
+	"time"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOnChanged(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		areaName    string
+		oldValue    js.Value
+		newValue    js.Value
+		want        map[string]string
+	}{
+		{
+			description: "value changed",
+			areaName:    "sync",
+			oldValue:    js.ValueOf(1),
+			newValue:    js.ValueOf(2),
+			want: map[string]string{
+				"key": `{"oldValue":1,"newValue":2}`,
+			},
+		},
+		{
+			description: "value added",
+			areaName:    "sync",
+			oldValue:    js.Undefined(),
+			newValue:    js.ValueOf(2),
+			want: map[string]string{
+				"key": `{"newValue":2}`,
+			},
+		},
+		{
+			description: "ignore other area",
+			areaName:    "local",
+			oldValue:    js.ValueOf(1),
+			newValue:    js.ValueOf(2),
+		},
+		{
+			description: "ignore unmodified value",
+			areaName:    "sync",
+			oldValue:    js.ValueOf(1),
+			newValue:    js.ValueOf(1),
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			event := st.NewChangeEvent()
+			got := make(chan map[string]string, 1)
+			cleanup := OnChanged(event, "sync", func(ctx jsutil.AsyncContext, changes map[string]js.Value) {
+				got <- dataToJSON(changes)
+			})
+			defer cleanup()
+
+			st.DispatchChange(event, tc.areaName, "key", tc.oldValue, tc.newValue)
+			select {
+			case changes := <-got:
+				if diff := cmp.Diff(changes, tc.want); diff != "" {
+					t.Errorf("incorrect changes; -got +want: %s", diff)
+				}
+			case <-time.After(time.Second):
+				if tc.want != nil {
+					t.Errorf("callback not invoked")
+				}
+			}
+		})
+	}
+}
+
+func TestOnChangedCleanup(t *testing.T) {
+	t.Parallel()
+
+	event := st.NewChangeEvent()
+	cleanup := OnChanged(event, "sync", func(ctx jsutil.AsyncContext, changes map[string]js.Value) {})
+	if !event.Call("hasListeners").Bool() {
+		t.Errorf("listener not registered")
+	}
+	cleanup()
+	if event.Call("hasListeners").Bool() {
+		t.Errorf("listener not removed")
+	}
+}
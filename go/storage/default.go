@@ -30,6 +30,15 @@ func DefaultSync() Area {
 	return NewBig(maxItemBytes, NewRaw(area))
 }
 
+// DefaultLocal returns an Area that can store and retrieve data that is
+// persisted on the local device only.  See:
+//
+//	https://developer.chrome.com/docs/extensions/reference/storage/#property-local
+func DefaultLocal() Area {
+	area := js.Global().Get("chrome").Get("storage").Get("local")
+	return NewRaw(area)
+}
+
 // DefaultSession returns an Area that can store and retrieve in-memory data.
 // The data is not written to disk.  See:
 //
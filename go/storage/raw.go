@@ -17,8 +17,11 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"syscall/js"
+	"time"
 
 	"github.com/google/chrome-ssh-agent/go/jsutil"
 	"github.com/norunners/vert"
@@ -29,13 +32,81 @@ import (
 // Raw implements the Area interface.
 type Raw struct {
 	o js.Value
+	// retries is the number of times a write that fails with a transient
+	// error is retried.
+	retries int
+	// retryDelay is the delay before the first retry. The delay doubles
+	// for each subsequent retry.
+	retryDelay time.Duration
 }
 
+const (
+	// defaultRetries is the number of times a write that fails with a
+	// transient error is retried.
+	defaultRetries = 5
+	// defaultRetryDelay is the delay before the first retry. With
+	// defaultRetries, writes are retried for up to half a minute.
+	defaultRetryDelay = time.Second
+)
+
 // NewRaw returns a Raw for storing and retrieving data.  The specified area
 // must point to an object implmenting the StorageArea API.
 func NewRaw(area js.Value) *Raw {
 	return &Raw{
-		o: area,
+		o:          area,
+		retries:    defaultRetries,
+		retryDelay: defaultRetryDelay,
+	}
+}
+
+var (
+	// ErrItemQuotaExceeded indicates that an item exceeds the maximum
+	// size permitted for a single item in the storage area.
+	ErrItemQuotaExceeded = errors.New("item exceeds per-item storage quota")
+	// ErrQuotaExceeded indicates that the total size of the data exceeds
+	// the maximum permitted in the storage area.
+	ErrQuotaExceeded = errors.New("storage quota exceeded")
+)
+
+// quotaError classifies errors returned by the StorageArea API when a quota
+// is exceeded. The API reports these only via the error message, which names
+// the quota (e.g., 'QUOTA_BYTES_PER_ITEM quota exceeded'). Other errors are
+// returned unchanged.
+func quotaError(err error) error {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "QUOTA_BYTES_PER_ITEM"):
+		return fmt.Errorf("%w: %w", ErrItemQuotaExceeded, err)
+	case strings.Contains(msg, "QUOTA_BYTES"):
+		return fmt.Errorf("%w: %w", ErrQuotaExceeded, err)
+	}
+	return err
+}
+
+// transientError indicates whether an error returned by the StorageArea API
+// is expected to clear if the operation is retried shortly after. Chrome
+// limits the rate of writes to synced storage, and reports exceeding the
+// limit only via the error message, which names the limit (e.g., 'This
+// request exceeds the MAX_WRITE_OPERATIONS_PER_MINUTE quota.').
+func transientError(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "MAX_WRITE_OPERATIONS_PER_MINUTE") ||
+		strings.Contains(msg, "MAX_SUSTAINED_WRITE_OPERATIONS_PER_MINUTE")
+}
+
+// withRetry invokes the supplied write operation, retrying with exponential
+// backoff if it fails with a transient error. The error from the final
+// attempt is returned.
+func (r *Raw) withRetry(op string, write func() error) error {
+	delay := r.retryDelay
+	for attempt := 0; ; attempt++ {
+		err := write()
+		if err == nil || !transientError(err) || attempt >= r.retries {
+			return err
+		}
+		jsutil.LogDebug("RawStorage.%s: retrying in %s after transient error: %v", op, delay, err)
+		time.Sleep(delay)
+		delay *= 2
 	}
 }
 
@@ -66,9 +137,12 @@ func (r *Raw) Set(ctx jsutil.AsyncContext, data map[string]js.Value) error {
 	defer jsutil.LogDebug("RawStorage.Set: finished")
 
 	jsutil.LogDebug("RawStorage.Set: setting data in storage")
-	_, err := jsutil.AsPromise(r.o.Call("set", dataToValue(data))).Await(ctx)
+	err := r.withRetry("Set", func() error {
+		_, err := jsutil.AsPromise(r.o.Call("set", dataToValue(data))).Await(ctx)
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("failed to set data: %w", err)
+		return fmt.Errorf("failed to set data: %w", quotaError(err))
 	}
 	return nil
 }
@@ -94,6 +168,31 @@ func (r *Raw) Get(ctx jsutil.AsyncContext) (map[string]js.Value, error) {
 	return data, nil
 }
 
+// GetKeys implements KeysArea.GetKeys().
+func (r *Raw) GetKeys(ctx jsutil.AsyncContext, keys []string) (map[string]js.Value, error) {
+	jsutil.LogDebug("RawStorage.GetKeys: reading %d values", len(keys))
+	defer jsutil.LogDebug("RawStorage.GetKeys: finished")
+
+	if len(keys) == 0 {
+		return map[string]js.Value{}, nil // Nothing to do.
+	}
+
+	jsutil.LogDebug("RawStorage.GetKeys: read data from storage")
+	val, err := jsutil.AsPromise(r.o.Call("get", vert.ValueOf(keys).JSValue())).Await(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data: %w", err)
+	}
+
+	jsutil.LogDebug("RawStorage.GetKeys: parse data")
+	data, err := valueToData(val)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse data: %w", err)
+	}
+
+	jsutil.LogDebug("RawStorage.GetKeys: return %d values", len(data))
+	return data, nil
+}
+
 // Delete implements Area.Delete().
 func (r *Raw) Delete(ctx jsutil.AsyncContext, keys []string) error {
 	jsutil.LogDebug("RawStorage.Delete: deleting %d values", len(keys))
@@ -104,7 +203,10 @@ func (r *Raw) Delete(ctx jsutil.AsyncContext, keys []string) error {
 	}
 
 	jsutil.LogDebug("RawStorage.Delete: removing from storage")
-	_, err := jsutil.AsPromise(r.o.Call("remove", vert.ValueOf(keys).JSValue())).Await(ctx)
+	err := r.withRetry("Delete", func() error {
+		_, err := jsutil.AsPromise(r.o.Call("remove", vert.ValueOf(keys).JSValue())).Await(ctx)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("failed to delete data: %w", err)
 	}
@@ -112,3 +214,43 @@ func (r *Raw) Delete(ctx jsutil.AsyncContext, keys []string) error {
 	jsutil.LogDebug("RawStorage.Delete: finished")
 	return nil
 }
+
+// BytesInUse returns the space used by the items with the specified keys.  If
+// keys is nil, the space used by all items is returned.
+func (r *Raw) BytesInUse(ctx jsutil.AsyncContext, keys []string) (int, error) {
+	arg := js.Null()
+	if keys != nil {
+		arg = vert.ValueOf(keys).JSValue()
+	}
+	val, err := jsutil.AsPromise(r.o.Call("getBytesInUse", arg)).Await(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get bytes in use: %w", err)
+	}
+	if val.Type() != js.TypeNumber {
+		return 0, fmt.Errorf("failed to get bytes in use: got type %s", val.Type())
+	}
+	return val.Int(), nil
+}
+
+// quota returns the named quota (e.g., 'QUOTA_BYTES') of the StorageArea,
+// or zero if the StorageArea does not have the quota.
+func (r *Raw) quota(name string) int {
+	val := r.o.Get(name)
+	if val.Type() != js.TypeNumber {
+		return 0
+	}
+	return val.Int()
+}
+
+// Usage implements UsageArea.Usage().
+func (r *Raw) Usage(ctx jsutil.AsyncContext) (*Usage, error) {
+	bytes, err := r.BytesInUse(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Usage{
+		BytesInUse:        bytes,
+		QuotaBytes:        r.quota("QUOTA_BYTES"),
+		QuotaBytesPerItem: r.quota("QUOTA_BYTES_PER_ITEM"),
+	}, nil
+}
@@ -15,13 +15,17 @@
 package storage
 
 import (
+	"errors"
+	"fmt"
 	"syscall/js"
 	"testing"
+	"time"
 
 	"github.com/google/chrome-ssh-agent/go/jsutil"
 	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
 	st "github.com/google/chrome-ssh-agent/go/storage/testing"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/norunners/vert"
 )
 
@@ -102,6 +106,71 @@ func TestRawSetAndGet(t *testing.T) {
 	}
 }
 
+func TestRawGetKeys(t *testing.T) {
+	t.Parallel()
+
+	init := map[string]js.Value{
+		"key1": js.ValueOf(1),
+		"key2": js.ValueOf(2),
+		"key3": js.ValueOf(3),
+	}
+
+	testcases := []struct {
+		description string
+		keys        []string
+		want        map[string]js.Value
+	}{
+		{
+			description: "single key",
+			keys:        []string{"key2"},
+			want: map[string]js.Value{
+				"key2": js.ValueOf(2),
+			},
+		},
+		{
+			description: "multiple keys",
+			keys:        []string{"key1", "key3"},
+			want: map[string]js.Value{
+				"key1": js.ValueOf(1),
+				"key3": js.ValueOf(3),
+			},
+		},
+		{
+			description: "missing key",
+			keys:        []string{"key1", "missing"},
+			want: map[string]js.Value{
+				"key1": js.ValueOf(1),
+			},
+		},
+		{
+			description: "no keys",
+			keys:        []string{},
+			want:        map[string]js.Value{},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				s := NewRaw(st.NewMemArea())
+				if err := s.Set(ctx, init); err != nil {
+					t.Fatalf("Set failed: %v", err)
+				}
+				got, err := s.GetKeys(ctx, tc.keys)
+				if err != nil {
+					t.Fatalf("GetKeys failed: %v", err)
+				}
+				if diff := cmp.Diff(dataToJSON(got), dataToJSON(tc.want)); diff != "" {
+					t.Errorf("incorrect data; -got +want: %s", diff)
+				}
+			})
+		})
+	}
+}
+
 func TestRawDelete(t *testing.T) {
 	t.Parallel()
 
@@ -183,3 +252,188 @@ func TestRawDelete(t *testing.T) {
 		})
 	}
 }
+
+// newFailingArea returns an object implementing the StorageArea API whose
+// set() method always fails with the supplied message.
+func newFailingArea(msg string) js.Value {
+	area := js.Global().Get("Object").New()
+	area.Set("set", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		return js.Global().Get("Promise").Call("reject", js.Global().Get("Error").New(msg))
+	}))
+	return area
+}
+
+func TestRawSetQuota(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		msg         string
+		wantErr     error
+	}{
+		{
+			description: "per-item quota exceeded",
+			msg:         "QUOTA_BYTES_PER_ITEM quota exceeded",
+			wantErr:     ErrItemQuotaExceeded,
+		},
+		{
+			description: "total quota exceeded",
+			msg:         "QUOTA_BYTES quota exceeded",
+			wantErr:     ErrQuotaExceeded,
+		},
+		{
+			description: "other failure",
+			msg:         "something else went wrong",
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				s := NewRaw(newFailingArea(tc.msg))
+				err := s.Set(ctx, map[string]js.Value{"key": js.ValueOf(1)})
+				if err == nil {
+					t.Fatalf("Set unexpectedly succeeded")
+				}
+				for _, quotaErr := range []error{ErrItemQuotaExceeded, ErrQuotaExceeded} {
+					if errors.Is(err, quotaErr) && quotaErr != tc.wantErr {
+						t.Errorf("error incorrectly classified as %v: %v", quotaErr, err)
+					}
+				}
+				if tc.wantErr != nil {
+					if diff := cmp.Diff(err, tc.wantErr, cmpopts.EquateErrors()); diff != "" {
+						t.Errorf("incorrect error; -got +want: %s", diff)
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestRawRetry(t *testing.T) {
+	t.Parallel()
+
+	const transient = "This request exceeds the MAX_WRITE_OPERATIONS_PER_MINUTE quota."
+	testcases := []struct {
+		description  string
+		msg          string
+		failures     int
+		wantErr      bool
+		wantAttempts int
+	}{
+		{
+			description:  "no failures",
+			msg:          transient,
+			wantAttempts: 1,
+		},
+		{
+			description:  "transient failures retried",
+			msg:          transient,
+			failures:     2,
+			wantAttempts: 3,
+		},
+		{
+			description:  "retries exhausted",
+			msg:          transient,
+			failures:     defaultRetries + 3,
+			wantErr:      true,
+			wantAttempts: defaultRetries + 1,
+		},
+		{
+			description:  "other failure not retried",
+			msg:          "something else went wrong",
+			failures:     2,
+			wantErr:      true,
+			wantAttempts: 1,
+		},
+	}
+
+	ops := map[string]func(ctx jsutil.AsyncContext, s *Raw) error{
+		"set": func(ctx jsutil.AsyncContext, s *Raw) error {
+			return s.Set(ctx, map[string]js.Value{"key": js.ValueOf(1)})
+		},
+		"delete": func(ctx jsutil.AsyncContext, s *Raw) error {
+			return s.Delete(ctx, []string{"key"})
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		for name, op := range ops {
+			op := op
+			t.Run(fmt.Sprintf("%s/%s", tc.description, name), func(t *testing.T) {
+				t.Parallel()
+
+				jut.DoSync(func(ctx jsutil.AsyncContext) {
+					flaky := st.NewFlakyArea(st.NewMemArea(), tc.msg)
+					s := NewRaw(flaky.Area())
+					s.retryDelay = time.Millisecond
+
+					flaky.FailNext(tc.failures)
+					err := op(ctx, s)
+					if gotErr := err != nil; gotErr != tc.wantErr {
+						t.Errorf("incorrect error; got %v, wantErr %v", err, tc.wantErr)
+					}
+					if diff := cmp.Diff(tc.failures-flaky.Failures(), min(tc.failures, tc.wantAttempts)); diff != "" {
+						t.Errorf("incorrect failed attempts; -got +want: %s", diff)
+					}
+				})
+			})
+		}
+	}
+}
+
+func TestRawUsage(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		area := st.NewMemArea()
+		s := NewRaw(area)
+
+		usage, err := s.Usage(ctx)
+		if err != nil {
+			t.Fatalf("Usage failed: %v", err)
+		}
+		if diff := cmp.Diff(usage, &Usage{}); diff != "" {
+			t.Errorf("incorrect usage for empty area; -got +want: %s", diff)
+		}
+
+		data := map[string]js.Value{
+			"key1": js.ValueOf("value1"),
+			"key2": js.ValueOf("some longer value"),
+		}
+		if err := s.Set(ctx, data); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+		area.Set("QUOTA_BYTES", 1000)
+		area.Set("QUOTA_BYTES_PER_ITEM", 100)
+
+		total, err := s.BytesInUse(ctx, nil)
+		if err != nil {
+			t.Fatalf("BytesInUse failed: %v", err)
+		}
+		one, err := s.BytesInUse(ctx, []string{"key1"})
+		if err != nil {
+			t.Fatalf("BytesInUse failed: %v", err)
+		}
+		if one <= 0 || one >= total {
+			t.Errorf("incorrect bytes in use for single item: got %d, total %d", one, total)
+		}
+
+		usage, err = s.Usage(ctx)
+		if err != nil {
+			t.Fatalf("Usage failed: %v", err)
+		}
+		want := &Usage{
+			BytesInUse:        total,
+			QuotaBytes:        1000,
+			QuotaBytesPerItem: 100,
+		}
+		if diff := cmp.Diff(usage, want); diff != "" {
+			t.Errorf("incorrect usage; -got +want: %s", diff)
+		}
+	})
+}
@@ -0,0 +1,65 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testing
+
+import (
+	"syscall/js"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+)
+
+var changeEventClass = js.Global().Call("eval", `{
+	class ChangeEvent {
+		constructor() {
+			this.listeners = new Set();
+		}
+		addListener(callback) {
+			this.listeners.add(callback);
+		}
+		removeListener(callback) {
+			this.listeners.delete(callback);
+		}
+		hasListeners() {
+			return this.listeners.size > 0;
+		}
+		dispatch(changes, areaName) {
+			for (const callback of this.listeners) {
+				callback(changes, areaName);
+			}
+		}
+	}
+	ChangeEvent;
+}`)
+
+// NewChangeEvent returns an object implementing the API of
+// chrome.storage.onChanged. Changes are only reported when simulated using
+// DispatchChange().
+func NewChangeEvent() js.Value {
+	return changeEventClass.New()
+}
+
+// DispatchChange simulates a change to the item with the specified key in the
+// storage area named areaName. Listeners registered with the event are
+// invoked.
+func DispatchChange(event js.Value, areaName, key string, oldValue, newValue js.Value) {
+	change := jsutil.NewObject()
+	change.Set("oldValue", oldValue)
+	change.Set("newValue", newValue)
+	changes := jsutil.NewObject()
+	changes.Set(key, change)
+	event.Call("dispatch", changes, areaName)
+}
@@ -28,3 +28,54 @@ var storageArea = js.Global().Call("eval", `{
 func NewMemArea() js.Value {
 	return storageArea.New()
 }
+
+var newFlakyArea = js.Global().Call("eval", `(function(area, message) {
+	const state = {failures: 0};
+	const proxy = new Proxy(area, {
+		get(target, prop) {
+			if ((prop === "set" || prop === "remove") && state.failures > 0) {
+				return () => {
+					state.failures--;
+					return Promise.reject(new Error(message));
+				};
+			}
+			const val = target[prop];
+			return typeof val === "function" ? val.bind(target) : val;
+		},
+	});
+	return {proxy, state};
+})`)
+
+// FlakyArea wraps a storage area, allowing failures to be injected into
+// writes.
+type FlakyArea struct {
+	area  js.Value
+	state js.Value
+}
+
+// NewFlakyArea returns a FlakyArea wrapping the supplied storage area. Writes
+// that fail do so with the specified error message.
+func NewFlakyArea(area js.Value, message string) *FlakyArea {
+	res := newFlakyArea.Invoke(area, message)
+	return &FlakyArea{
+		area:  res.Get("proxy"),
+		state: res.Get("state"),
+	}
+}
+
+// Area returns an object implementing the StorageArea API. Its set() and
+// remove() methods fail while injected failures remain; other methods are
+// passed to the wrapped area.
+func (f *FlakyArea) Area() js.Value {
+	return f.area
+}
+
+// FailNext causes the next n writes to fail.
+func (f *FlakyArea) FailNext(n int) {
+	f.state.Set("failures", n)
+}
+
+// Failures returns the number of injected failures remaining.
+func (f *FlakyArea) Failures() int {
+	return f.state.Get("failures").Int()
+}
@@ -21,15 +21,32 @@ import (
 	"fmt"
 	"math"
 	"math/big"
+	"sort"
 	"syscall/js"
 
 	"github.com/google/chrome-ssh-agent/go/jsutil"
 	"github.com/norunners/vert"
 )
 
+// Migrator may be implemented by a value type whose stored representation
+// changes over time. Migrate is invoked on each value after it is read, and
+// should upgrade values stored in an older format in place.
+type Migrator interface {
+	Migrate()
+}
+
+// Validator may be implemented by a value type to reject values that
+// deserialize successfully, but are nonetheless malformed (e.g., a required
+// field is missing). Validate is invoked on each value after migration.
+type Validator interface {
+	Validate() error
+}
+
 // Typed reads and writes typed values. They are serialized upon writing,
 // and deserialized upon reading.  If deserialization fails for a given value,
-// it is ignored.
+// it is ignored. Values whose type implements Migrator are migrated after
+// deserialization. Values whose type implements Validator are ignored if
+// they fail validation.
 type Typed[V any] struct {
 	store Area
 }
@@ -49,18 +66,73 @@ func (t *Typed[V]) readAllItems(ctx jsutil.AsyncContext) (map[string]*V, error)
 	if err != nil {
 		return nil, err
 	}
+	return t.parseItems(data), nil
+}
 
+// parseItem deserializes a single value.
+func (t *Typed[V]) parseItem(v js.Value) (*V, error) {
+	var tv V
+	if err := vert.ValueOf(v).AssignTo(&tv); err != nil {
+		return nil, err
+	}
+	if m, ok := any(&tv).(Migrator); ok {
+		m.Migrate()
+	}
+	if vv, ok := any(&tv).(Validator); ok {
+		if err := vv.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return &tv, nil
+}
+
+// parseItems deserializes the supplied data. Values that fail to
+// deserialize are dropped.
+func (t *Typed[V]) parseItems(data map[string]js.Value) map[string]*V {
 	values := map[string]*V{}
 	for k, v := range data {
-		var tv V
-		if err := vert.ValueOf(v).AssignTo(&tv); err != nil {
-			jsutil.LogError("failed to parse value %s; dropping", k)
+		tv, err := t.parseItem(v)
+		if err != nil {
+			jsutil.LogError("failed to parse value %s; dropping: %v", k, err)
 			continue
 		}
+		values[k] = tv
+	}
+	return values
+}
 
-		values[k] = &tv
+// Corrupt returns the keys of stored values that fail to deserialize, and
+// are therefore ignored when reading values.
+func (t *Typed[V]) Corrupt(ctx jsutil.AsyncContext) ([]string, error) {
+	data, err := t.store.Get(ctx)
+	if err != nil {
+		return nil, err
 	}
-	return values, nil
+
+	var keys []string
+	for k, v := range data {
+		if _, err := t.parseItem(v); err != nil {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// DeleteCorrupt removes all stored values that fail to deserialize. The
+// number of values removed is returned.
+func (t *Typed[V]) DeleteCorrupt(ctx jsutil.AsyncContext) (int, error) {
+	keys, err := t.Corrupt(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to enumerate values: %w", err)
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	if err := t.store.Delete(ctx, keys); err != nil {
+		return 0, err
+	}
+	return len(keys), nil
 }
 
 // ReadAll returns all the stored values.
@@ -95,6 +167,27 @@ func (t *Typed[V]) Read(ctx jsutil.AsyncContext, test func(v *V) bool) (*V, erro
 	return nil, nil
 }
 
+// ReadKey returns the value stored with the specified key. Unlike Read, only
+// the requested value is read from the underlying store. If the value is not
+// found, a nil value is returned.
+func (t *Typed[V]) ReadKey(ctx jsutil.AsyncContext, key string) (*V, error) {
+	data, err := AreaGetKeys(ctx, t.store, []string{key})
+	if err != nil {
+		return nil, err
+	}
+	return t.parseItems(data)[key], nil
+}
+
+// WriteKey writes a value to storage with the specified key, such that it may
+// subsequently be read using ReadKey. Any value already stored with the key is
+// overwritten.
+func (t *Typed[V]) WriteKey(ctx jsutil.AsyncContext, key string, value *V) error {
+	data := map[string]js.Value{
+		key: vert.ValueOf(value).JSValue(),
+	}
+	return t.store.Set(ctx, data)
+}
+
 // Write writes a new value to storage.
 func (t *Typed[V]) Write(ctx jsutil.AsyncContext, value *V) error {
 	// Generate a unique key under which value will be stored.
@@ -108,6 +201,53 @@ func (t *Typed[V]) Write(ctx jsutil.AsyncContext, value *V) error {
 	return t.store.Set(ctx, data)
 }
 
+// WriteAll writes multiple new values to storage with a single write to the
+// underlying store. Either all values are written, or none are.
+func (t *Typed[V]) WriteAll(ctx jsutil.AsyncContext, values []*V) error {
+	data := map[string]js.Value{}
+	for _, value := range values {
+		// Generate a unique key under which value will be stored.
+		key, err := rand.Int(rand.Reader, big.NewInt(math.MaxInt64))
+		if err != nil {
+			return fmt.Errorf("failed to generate new ID: %w", err)
+		}
+		data[key.String()] = vert.ValueOf(value).JSValue()
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return t.store.Set(ctx, data)
+}
+
+// Update applies the supplied update function to each stored value. Values
+// for which the update function returns true are written back to storage.
+//
+// If any of the values to be written back was modified concurrently, an error
+// wrapping ErrConflict is returned and no values are written. The update may
+// then be retried, in which case the update function is applied to the values
+// as modified.
+func (t *Typed[V]) Update(ctx jsutil.AsyncContext, update func(v *V) bool) error {
+	raw, err := t.store.Get(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to enumerate values: %w", err)
+	}
+	data := t.parseItems(raw)
+
+	updated := map[string]js.Value{}
+	expected := map[string]string{}
+	for k, v := range data {
+		if update(v) {
+			updated[k] = vert.ValueOf(v).JSValue()
+			expected[k] = Version(raw[k])
+		}
+	}
+	if len(updated) == 0 {
+		return nil
+	}
+
+	return SetIfUnchanged(ctx, t.store, updated, expected)
+}
+
 // Delete removes the value that matches the supplied test function. If multiple
 // values match, all matching values are removed.
 func (t *Typed[V]) Delete(ctx jsutil.AsyncContext, test func(v *V) bool) error {
@@ -15,6 +15,7 @@
 package storage
 
 import (
+	"errors"
 	"syscall/js"
 	"testing"
 
@@ -97,6 +98,50 @@ func TestTypedReadAll(t *testing.T) {
 	}
 }
 
+// versionedStruct is a value whose stored representation is upgraded when
+// read.
+type versionedStruct struct {
+	Version     int    `js:"version"`
+	StringField string `js:"stringField"`
+}
+
+// Migrate implements Migrator.
+func (v *versionedStruct) Migrate() {
+	if v.Version < 1 {
+		v.StringField = "default"
+	}
+	v.Version = 1
+}
+
+func TestTypedReadAllMigrate(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		store := NewRaw(st.NewMemArea())
+		init := map[string]js.Value{
+			testKeyPrefix + "." + "1": js.ValueOf(map[string]any{}),
+			testKeyPrefix + "." + "2": vert.ValueOf(&versionedStruct{Version: 1, StringField: "foo"}).JSValue(),
+		}
+		if err := store.Set(ctx, init); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+
+		ts := NewTyped[versionedStruct](store, testKeyPrefixes)
+		got, err := ts.ReadAll(ctx)
+		if err != nil {
+			t.Fatalf("ReadAll failed: %v", err)
+		}
+		want := []*versionedStruct{
+			{Version: 1, StringField: "default"},
+			{Version: 1, StringField: "foo"},
+		}
+		less := func(a, b *versionedStruct) bool { return a.StringField < b.StringField }
+		if diff := cmp.Diff(got, want, cmpopts.SortSlices(less)); diff != "" {
+			t.Errorf("incorrect result: -got +want: %s", diff)
+		}
+	})
+}
+
 func TestTypedRead(t *testing.T) {
 	t.Parallel()
 
@@ -152,6 +197,55 @@ func TestTypedRead(t *testing.T) {
 	}
 }
 
+func TestTypedReadWriteKey(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		store := NewRaw(st.NewMemArea())
+		err := store.Set(ctx, map[string]js.Value{
+			testKeyPrefix + "." + "1": vert.ValueOf(&myStruct{IntField: 42}).JSValue(),
+			testKeyPrefix + "." + "2": js.ValueOf("invalid"),
+		})
+		if err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+
+		ts := NewTyped[myStruct](store, testKeyPrefixes)
+		if err := ts.WriteKey(ctx, "3", &myStruct{StringField: "foo"}); err != nil {
+			t.Fatalf("WriteKey failed: %v", err)
+		}
+
+		testcases := []struct {
+			key  string
+			want *myStruct
+		}{
+			{key: "1", want: &myStruct{IntField: 42}},
+			{key: "2", want: nil}, // Fails to parse; dropped.
+			{key: "3", want: &myStruct{StringField: "foo"}},
+			{key: "missing", want: nil},
+		}
+		for _, tc := range testcases {
+			got, err := ts.ReadKey(ctx, tc.key)
+			if err != nil {
+				t.Errorf("ReadKey(%s) failed: %v", tc.key, err)
+				continue
+			}
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Errorf("incorrect result for key %s: -got +want: %s", tc.key, diff)
+			}
+		}
+
+		// The written value is also visible when enumerating values.
+		all, err := ts.ReadAll(ctx)
+		if err != nil {
+			t.Fatalf("ReadAll failed: %v", err)
+		}
+		if diff := cmp.Diff(all, []*myStruct{{IntField: 42}, {StringField: "foo"}}, cmpopts.SortSlices(myStructLess)); diff != "" {
+			t.Errorf("incorrect values: -got +want: %s", diff)
+		}
+	})
+}
+
 func TestTypedWrite(t *testing.T) {
 	t.Parallel()
 
@@ -220,6 +314,217 @@ func TestTypedWrite(t *testing.T) {
 	}
 }
 
+func TestTypedWriteAll(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		init        map[string]js.Value
+		write       []*myStruct
+		want        []*myStruct
+	}{
+		{
+			description: "write no values",
+			init: map[string]js.Value{
+				testKeyPrefix + "." + "1": vert.ValueOf(&myStruct{IntField: 42}).JSValue(),
+			},
+			write: nil,
+			want: []*myStruct{
+				{IntField: 42},
+			},
+		},
+		{
+			description: "write multiple values",
+			init: map[string]js.Value{
+				testKeyPrefix + "." + "1": vert.ValueOf(&myStruct{IntField: 42}).JSValue(),
+			},
+			write: []*myStruct{
+				{IntField: 42},
+				{IntField: 100},
+				{StringField: "foo"},
+			},
+			want: []*myStruct{
+				{IntField: 42},
+				{IntField: 42},
+				{IntField: 100},
+				{StringField: "foo"},
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				store := NewRaw(st.NewMemArea())
+				if err := store.Set(ctx, tc.init); err != nil {
+					t.Fatalf("Set failed: %v", err)
+				}
+
+				ts := NewTyped[myStruct](store, testKeyPrefixes)
+
+				if err := ts.WriteAll(ctx, tc.write); err != nil {
+					t.Errorf("WriteAll failed: %v", err)
+				}
+
+				got, err := ts.ReadAll(ctx)
+				if err != nil {
+					t.Fatalf("ReadAll failed: %v", err)
+				}
+				if diff := cmp.Diff(got, tc.want, cmpopts.SortSlices(myStructLess)); diff != "" {
+					t.Errorf("incorrect result: -got +want: %s", diff)
+				}
+			})
+		})
+	}
+}
+
+func TestTypedUpdate(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		description string
+		init        map[string]js.Value
+		update      func(v *myStruct) bool
+		want        []*myStruct
+		wantErr     error
+	}{
+		{
+			description: "update single value",
+			init: map[string]js.Value{
+				testKeyPrefix + "." + "1": vert.ValueOf(&myStruct{IntField: 42}).JSValue(),
+				testKeyPrefix + "." + "2": vert.ValueOf(&myStruct{StringField: "foo"}).JSValue(),
+			},
+			update: func(v *myStruct) bool {
+				if v.IntField != 42 {
+					return false
+				}
+				v.StringField = "bar"
+				return true
+			},
+			want: []*myStruct{
+				{IntField: 42, StringField: "bar"},
+				{StringField: "foo"},
+			},
+		},
+		{
+			description: "update multiple values",
+			init: map[string]js.Value{
+				testKeyPrefix + "." + "1": vert.ValueOf(&myStruct{IntField: 42}).JSValue(),
+				testKeyPrefix + "." + "2": vert.ValueOf(&myStruct{IntField: 100}).JSValue(),
+			},
+			update: func(v *myStruct) bool {
+				v.IntField++
+				return true
+			},
+			want: []*myStruct{
+				{IntField: 43},
+				{IntField: 101},
+			},
+		},
+		{
+			description: "discard changes to values that are not updated",
+			init: map[string]js.Value{
+				testKeyPrefix + "." + "1": vert.ValueOf(&myStruct{IntField: 42}).JSValue(),
+			},
+			update: func(v *myStruct) bool {
+				v.IntField++
+				return false
+			},
+			want: []*myStruct{
+				{IntField: 42},
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				store := NewRaw(st.NewMemArea())
+				if err := store.Set(ctx, tc.init); err != nil {
+					t.Fatalf("Set failed: %v", err)
+				}
+
+				ts := NewTyped[myStruct](store, testKeyPrefixes)
+
+				err := ts.Update(ctx, tc.update)
+				if diff := cmp.Diff(err, tc.wantErr, cmpopts.EquateErrors()); diff != "" {
+					t.Errorf("incorrect error: -got +want: %s", diff)
+				}
+
+				got, err := ts.ReadAll(ctx)
+				if err != nil {
+					t.Fatalf("ReadAll failed: %v", err)
+				}
+				if diff := cmp.Diff(got, tc.want, cmpopts.SortSlices(myStructLess)); diff != "" {
+					t.Errorf("incorrect result: -got +want: %s", diff)
+				}
+			})
+		})
+	}
+}
+
+func TestTypedUpdateConflict(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		store := NewRaw(st.NewMemArea())
+		err := store.Set(ctx, map[string]js.Value{
+			testKeyPrefix + "." + "1": vert.ValueOf(&myStruct{IntField: 42}).JSValue(),
+		})
+		if err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+
+		ts := NewTyped[myStruct](store, testKeyPrefixes)
+
+		// Modify the value concurrently with the update.
+		err = ts.Update(ctx, func(v *myStruct) bool {
+			concurrent := map[string]js.Value{
+				testKeyPrefix + "." + "1": vert.ValueOf(&myStruct{IntField: 100}).JSValue(),
+			}
+			if err := store.Set(ctx, concurrent); err != nil {
+				t.Fatalf("Set failed: %v", err)
+			}
+			v.StringField = "foo"
+			return true
+		})
+		if diff := cmp.Diff(err, ErrConflict, cmpopts.EquateErrors()); diff != "" {
+			t.Errorf("incorrect error: -got +want: %s", diff)
+		}
+
+		// The concurrent modification is preserved.
+		got, err := ts.ReadAll(ctx)
+		if err != nil {
+			t.Fatalf("ReadAll failed: %v", err)
+		}
+		if diff := cmp.Diff(got, []*myStruct{{IntField: 100}}); diff != "" {
+			t.Errorf("incorrect result after conflict: -got +want: %s", diff)
+		}
+
+		// Retrying applies the update to the modified value.
+		err = ts.Update(ctx, func(v *myStruct) bool {
+			v.StringField = "foo"
+			return true
+		})
+		if err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		got, err = ts.ReadAll(ctx)
+		if err != nil {
+			t.Fatalf("ReadAll failed: %v", err)
+		}
+		if diff := cmp.Diff(got, []*myStruct{{IntField: 100, StringField: "foo"}}); diff != "" {
+			t.Errorf("incorrect result after retry: -got +want: %s", diff)
+		}
+	})
+}
+
 func TestTypedDelete(t *testing.T) {
 	t.Parallel()
 
@@ -284,3 +589,73 @@ func TestTypedDelete(t *testing.T) {
 		})
 	}
 }
+
+// validatedStruct is a value that is malformed unless a field is set.
+type validatedStruct struct {
+	StringField string `js:"stringField"`
+}
+
+// Validate implements Validator.
+func (v *validatedStruct) Validate() error {
+	if v.StringField == "" {
+		return errors.New("missing stringField")
+	}
+	return nil
+}
+
+func TestTypedCorrupt(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		store := NewRaw(st.NewMemArea())
+		init := map[string]js.Value{
+			testKeyPrefix + "." + "1": vert.ValueOf(&validatedStruct{StringField: "foo"}).JSValue(),
+			testKeyPrefix + "." + "2": js.ValueOf(42),
+			testKeyPrefix + "." + "3": js.ValueOf(map[string]any{}),
+			"wrong.4":                 js.ValueOf(42),
+		}
+		if err := store.Set(ctx, init); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+
+		ts := NewTyped[validatedStruct](store, testKeyPrefixes)
+
+		// Malformed values are skipped when reading.
+		got, err := ts.ReadAll(ctx)
+		if err != nil {
+			t.Fatalf("ReadAll failed: %v", err)
+		}
+		if diff := cmp.Diff(got, []*validatedStruct{{StringField: "foo"}}); diff != "" {
+			t.Errorf("incorrect result: -got +want: %s", diff)
+		}
+
+		corrupt, err := ts.Corrupt(ctx)
+		if err != nil {
+			t.Fatalf("Corrupt failed: %v", err)
+		}
+		if diff := cmp.Diff(corrupt, []string{"2", "3"}); diff != "" {
+			t.Errorf("incorrect corrupt keys: -got +want: %s", diff)
+		}
+
+		n, err := ts.DeleteCorrupt(ctx)
+		if err != nil {
+			t.Fatalf("DeleteCorrupt failed: %v", err)
+		}
+		if diff := cmp.Diff(n, 2); diff != "" {
+			t.Errorf("incorrect number deleted: -got +want: %s", diff)
+		}
+
+		// Valid values, and those belonging to others, are retained.
+		raw, err := store.Get(ctx)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		var remaining []string
+		for k := range raw {
+			remaining = append(remaining, k)
+		}
+		if diff := cmp.Diff(remaining, []string{testKeyPrefix + ".1", "wrong.4"}, cmpopts.SortSlices(func(a, b string) bool { return a < b })); diff != "" {
+			t.Errorf("incorrect remaining values: -got +want: %s", diff)
+		}
+	})
+}
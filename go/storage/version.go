@@ -0,0 +1,102 @@
+//go:build js
+
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"syscall/js"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	"github.com/google/chrome-ssh-agent/go/lock"
+)
+
+var (
+	// ErrConflict indicates that a write was rejected because an item was
+	// modified concurrently (e.g., by another extension context). The
+	// error is retryable; callers should read the items again, reapply
+	// their changes, and retry the write.
+	ErrConflict = errors.New("storage item modified concurrently")
+)
+
+const (
+	// casLockResourceID identifies the lock taken while checking versions
+	// and writing in SetIfUnchanged. It serializes conditional writes
+	// across all extension contexts.
+	casLockResourceID = "storage-cas-lock"
+)
+
+// Version returns a token identifying the contents of a stored value. The
+// token changes whenever the value is modified. A missing value (i.e.,
+// undefined) has an empty version.
+func Version(val js.Value) string {
+	if val.IsUndefined() {
+		return ""
+	}
+	h := sha256.Sum256([]byte(jsutil.ToJSON(val)))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// Versions returns the version of each of the supplied values.
+func Versions(data map[string]js.Value) map[string]string {
+	res := map[string]string{}
+	for k, v := range data {
+		res[k] = Version(v)
+	}
+	return res
+}
+
+// SetIfUnchanged stores new data in the storage area, provided that the items
+// with the keys in expected still have the expected versions, as returned by
+// Version. An item expected to be missing from storage has an empty version.
+//
+// If any item has been modified, an error wrapping ErrConflict is returned and
+// no data is written. Conditional writes are serialized across extension
+// contexts; writes using Area.Set are not, but any modifications they make
+// before the versions are checked are detected.
+func SetIfUnchanged(ctx jsutil.AsyncContext, area Area, data map[string]js.Value, expected map[string]string) error {
+	var keys []string
+	for k := range expected {
+		keys = append(keys, k)
+	}
+
+	var err error
+	_, aerr := lock.Async(casLockResourceID, func(ctx jsutil.AsyncContext) {
+		err = func() error {
+			current, err := AreaGetKeys(ctx, area, keys)
+			if err != nil {
+				return fmt.Errorf("failed to read current versions: %w", err)
+			}
+			for k, want := range expected {
+				val, present := current[k]
+				if !present {
+					val = js.Undefined()
+				}
+				if got := Version(val); got != want {
+					return fmt.Errorf("%w: key %s", ErrConflict, k)
+				}
+			}
+			return area.Set(ctx, data)
+		}()
+	}).Await(ctx)
+	if aerr != nil {
+		return aerr
+	}
+	return err
+}
@@ -0,0 +1,126 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"syscall/js"
+	"testing"
+
+	"github.com/google/chrome-ssh-agent/go/jsutil"
+	jut "github.com/google/chrome-ssh-agent/go/jsutil/testing"
+	st "github.com/google/chrome-ssh-agent/go/storage/testing"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestVersion(t *testing.T) {
+	t.Parallel()
+
+	if got := Version(js.Undefined()); got != "" {
+		t.Errorf("incorrect version for missing value; got %q, want empty", got)
+	}
+	if Version(js.ValueOf(1)) == Version(js.ValueOf(2)) {
+		t.Errorf("different values have the same version")
+	}
+	if Version(js.ValueOf("foo")) != Version(js.ValueOf("foo")) {
+		t.Errorf("equal values have different versions")
+	}
+}
+
+func TestSetIfUnchanged(t *testing.T) {
+	t.Parallel()
+
+	init := map[string]js.Value{
+		"key1": js.ValueOf(1),
+		"key2": js.ValueOf(2),
+	}
+
+	testcases := []struct {
+		description string
+		expected    map[string]string
+		want        map[string]js.Value
+		wantErr     error
+	}{
+		{
+			description: "unchanged",
+			expected: map[string]string{
+				"key1": Version(js.ValueOf(1)),
+			},
+			want: map[string]js.Value{
+				"key1": js.ValueOf(10),
+				"key2": js.ValueOf(2),
+			},
+		},
+		{
+			description: "no expected versions",
+			want: map[string]js.Value{
+				"key1": js.ValueOf(10),
+				"key2": js.ValueOf(2),
+			},
+		},
+		{
+			description: "modified",
+			expected: map[string]string{
+				"key1": Version(js.ValueOf(1)),
+				"key2": Version(js.ValueOf(3)),
+			},
+			want:    init,
+			wantErr: ErrConflict,
+		},
+		{
+			description: "expected missing",
+			expected: map[string]string{
+				"key1": "",
+			},
+			want:    init,
+			wantErr: ErrConflict,
+		},
+		{
+			description: "expected present",
+			expected: map[string]string{
+				"missing": Version(js.ValueOf(1)),
+			},
+			want:    init,
+			wantErr: ErrConflict,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			jut.DoSync(func(ctx jsutil.AsyncContext) {
+				s := NewRaw(st.NewMemArea())
+				if err := s.Set(ctx, init); err != nil {
+					t.Fatalf("Set failed: %v", err)
+				}
+
+				err := SetIfUnchanged(ctx, s, map[string]js.Value{"key1": js.ValueOf(10)}, tc.expected)
+				if diff := cmp.Diff(err, tc.wantErr, cmpopts.EquateErrors()); diff != "" {
+					t.Errorf("incorrect error; -got +want: %s", diff)
+				}
+
+				got, err := s.Get(ctx)
+				if err != nil {
+					t.Fatalf("Get failed: %v", err)
+				}
+				if diff := cmp.Diff(dataToJSON(got), dataToJSON(tc.want)); diff != "" {
+					t.Errorf("incorrect data; -got +want: %s", diff)
+				}
+			})
+		})
+	}
+}
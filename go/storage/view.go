@@ -108,6 +108,32 @@ func (v *View) Get(ctx jsutil.AsyncContext) (map[string]js.Value, error) {
 	return ndata, nil
 }
 
+// GetKeys implements KeysArea.GetKeys().
+func (v *View) GetKeys(ctx jsutil.AsyncContext, keys []string) (map[string]js.Value, error) {
+	var nkeys []string
+	for _, k := range keys {
+		for _, prefix := range v.prefixes {
+			nkeys = append(nkeys, v.makeKey(prefix, k))
+		}
+	}
+	data, err := AreaGetKeys(ctx, v.s, nkeys)
+	if err != nil {
+		return nil, err
+	}
+
+	ndata := map[string]js.Value{}
+	for _, k := range keys {
+		// First prefix takes precedence.
+		for _, prefix := range v.prefixes {
+			if val, present := data[v.makeKey(prefix, k)]; present {
+				ndata[k] = val
+				break
+			}
+		}
+	}
+	return ndata, nil
+}
+
 // Delete implements Area.Delete().
 func (v *View) Delete(ctx jsutil.AsyncContext, keys []string) error {
 	var nkeys []string
@@ -230,6 +230,39 @@ func TestViewGet(t *testing.T) {
 	}
 }
 
+func TestViewGetKeys(t *testing.T) {
+	t.Parallel()
+
+	jut.DoSync(func(ctx jsutil.AsyncContext) {
+		raw := NewRaw(st.NewMemArea())
+		err := raw.Set(ctx, map[string]js.Value{
+			"foo-new.my-key":    js.ValueOf(4),
+			"foo-old.my-key":    js.ValueOf(2),
+			"foo-old.other-key": js.ValueOf("some-val"),
+			"foo-new.unread":    js.ValueOf(3),
+			"bar.missing":       js.ValueOf(5), // Different prefix
+		})
+		if err != nil {
+			t.Fatalf("initial Set failed: %v", err)
+		}
+
+		view := NewView([]string{"foo-new", "foo-old"}, raw)
+		got, err := view.GetKeys(ctx, []string{"my-key", "other-key", "missing"})
+		if err != nil {
+			t.Fatalf("View.GetKeys failed: %v", err)
+		}
+
+		// Earlier prefix takes precedence.
+		want := map[string]string{
+			"my-key":    "4",
+			"other-key": `"some-val"`,
+		}
+		if diff := cmp.Diff(dataToJSON(got), want); diff != "" {
+			t.Errorf("incorrect result; -got +want: %s", diff)
+		}
+	})
+}
+
 func TestViewDelete(t *testing.T) {
 	t.Parallel()
 
@@ -70,3 +70,16 @@ func WriteResults(d *dom.Doc, errs []error) {
 		})
 	})
 }
+
+// WriteReport adds an element to the supplied DOM containing a structured
+// report of the test results, serialized as JSON. The element is given an
+// identifier such that the report can be parsed by automation. The following
+// element is added:
+//   - report: a pre element, whose contained text is the JSON report.
+func WriteReport(d *dom.Doc, report string) {
+	dom.AppendChild(getBody(d), d.NewElement("pre"), func(pre js.Value) {
+		// Allow element to be read by automation.
+		pre.Set("id", "report")
+		dom.AppendChild(pre, d.NewText(report), nil)
+	})
+}
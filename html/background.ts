@@ -20,6 +20,10 @@ const app = new WASMApp("../go/background/background.wasm");
 declare function handleOnMessage(message: any, sender: chrome.runtime.MessageSender, sendResponse: (message: any) => void): Promise<void>;
 declare function handleConnectionMessage(port: chrome.runtime.Port, message: any): Promise<void>;
 declare function handleConnectionDisconnect(port: chrome.runtime.Port): Promise<void>;
+declare function handleNotificationButtonClicked(notificationId: string, buttonIndex: number): Promise<void>;
+declare function handleNotificationClosed(notificationId: string, byUser: boolean): Promise<void>;
+declare function handleContextMenuClicked(menuItemId: string): Promise<void>;
+declare function handleAlarm(name: string): Promise<void>;
 
 // Workaround for https://github.com/w3c/ServiceWorker/issues/1499#issuecomment-578730536.
 // The cited issue illustrates limitation for Rust, but we have the same in Go.
@@ -56,3 +60,34 @@ chrome.runtime.onConnectExternal.addListener((port: chrome.runtime.Port) => {
 	port.onMessage.addListener((msg: any) => onConnectionMessage(port, msg));
 	port.onDisconnect.addListener((port: chrome.runtime.Port) => onConnectionDisconnect(port));
 });
+
+async function onNotificationButtonClicked(notificationId: string, buttonIndex: number) {
+	await app.waitInit()
+	return handleNotificationButtonClicked(notificationId, buttonIndex);
+}
+
+async function onNotificationClosed(notificationId: string, byUser: boolean) {
+	await app.waitInit()
+	return handleNotificationClosed(notificationId, byUser);
+}
+
+// Notifications prompt the user to confirm use of keys that require it.
+chrome.notifications.onButtonClicked.addListener((notificationId: string, buttonIndex: number) => onNotificationButtonClicked(notificationId, buttonIndex));
+chrome.notifications.onClosed.addListener((notificationId: string, byUser: boolean) => onNotificationClosed(notificationId, byUser));
+
+async function onContextMenuClicked(menuItemId: string) {
+	await app.waitInit()
+	return handleContextMenuClicked(menuItemId);
+}
+
+// The context menu offers to load configured keys from any page.
+chrome.contextMenus.onClicked.addListener((info: chrome.contextMenus.OnClickData) => onContextMenuClicked(String(info.menuItemId)));
+
+async function onAlarm(name: string) {
+	await app.waitInit()
+	return handleAlarm(name);
+}
+
+// Alarms unload keys once the agent is idle, even if the background worker
+// was suspended in the meantime.
+chrome.alarms.onAlarm.addListener((alarm: chrome.alarms.Alarm) => onAlarm(alarm.name));